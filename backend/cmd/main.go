@@ -2,11 +2,11 @@ package main
 
 import (
 	"log"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/michaellanpart/flowgen/backend/internal/api"
 	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
 )
 
 func main() {
@@ -16,36 +16,41 @@ func main() {
 	// Setup Gin router
 	r := gin.Default()
 
-	// Add CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	})
-
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "ok",
-			"service": "flowgen-backend",
-			"version": "0.1.0",
-		})
-	})
+	// CORS, health check and API routes
+	api.SetupServer(r)
 
 	// Serve static files from the new frontend directory
 	r.Static("/static", "../frontend")
 	r.StaticFile("/", "../frontend/index.html")
 	r.StaticFile("/flowchart-display.html", "../frontend/index.html")
 
-	// API routes
-	api.SetupRoutes(r)
+	// Example diagrams from FLOWGEN_SEED_DIR, in development only
+	services.SeedDevelopmentDiagrams()
+
+	// Configuration checks, and parsing of all diagrams in the background
+	services.RunStartupChecks()
+	if err := services.RequireCleanDiagrams(); err != nil {
+		log.Fatal("Diagrams failed strict loading: ", err)
+	}
+	services.StartDiagramIndexWarming()
+
+	// Scheduled pull/push of the diagrams repository, if configured
+	services.StartGitSync()
+
+	// Scheduled report digests mailed over SMTP, if configured
+	services.StartReportScheduler()
+
+	// Reminders to owners of diagrams that have not been updated for a while
+	services.StartStaleReminders()
+
+	// Periodic re-validation of all diagrams and their cross-references
+	services.StartConsistencyChecks()
+
+	// Detection of diagram files edited outside the API, e.g. in a text editor
+	services.StartExternalChangeWatch()
+
+	// Retention of the access log of diagram reads, if enabled
+	services.StartAccessLogRetention()
 
 	// Start server
 	log.Printf("Starting FlowGen backend server on port %s", cfg.Port)
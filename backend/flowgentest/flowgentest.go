@@ -0,0 +1,283 @@
+// Package flowgentest runs the FlowGen API in-process for integration tests
+// of tools built on it, without Docker or a separately started server.
+//
+//	func TestExport(t *testing.T) {
+//		srv := flowgentest.New(t)
+//		srv.SeedYAML(checkoutYAML)
+//		resp, body := srv.Do(http.MethodGet, "/api/v1/diagrams/checkout/export/mermaid", nil)
+//		...
+//		srv.AssertStored("checkout", "nodes[0].name", "Start")
+//	}
+//
+// Each server stores diagrams, releases, preferences, workspaces and the
+// glossary in its own temporary directory, which is removed when the test
+// ends. FlowGen reads its configuration from the environment, so a server
+// holds a process-wide lock from New until the test ends: tests may call New
+// in parallel, but their servers run one after another. Requests to a
+// server may be made concurrently. Background jobs such as Git sync and
+// scheduled reports are not started.
+package flowgentest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/api"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// serverMu is held by the running server, as servers share the environment
+var serverMu sync.Mutex
+
+// Server is a FlowGen API served in-process
+type Server struct {
+	*httptest.Server
+	Dir string // Temporary directory holding the server's state
+	t   testing.TB
+}
+
+// Option configures a Server
+type Option func(env map[string]string)
+
+// WithEnv sets a configuration variable, e.g. NODE_CATALOG_PATH, for the
+// lifetime of the server
+func WithEnv(key, value string) Option {
+	return func(env map[string]string) { env[key] = value }
+}
+
+// New starts a server with an empty diagrams directory. It is closed and
+// its directory removed when the test ends. A test can have only one
+// server at a time; a second call to New waits for the first to end.
+func New(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	env := map[string]string{
+		"DIAGRAMS_PATH":           filepath.Join(dir, "diagrams"),
+		"RELEASES_PATH":           filepath.Join(dir, "releases"),
+		"PREFERENCES_PATH":        filepath.Join(dir, "preferences"),
+		"WORKSPACES_PATH":         filepath.Join(dir, "workspaces"),
+		"GLOSSARY_PATH":           filepath.Join(dir, "glossary.yaml"),
+		"CONSISTENCY_REPORT_PATH": filepath.Join(dir, "consistency-report.json"),
+		"JIRA_OAUTH_TOKEN_PATH":   filepath.Join(dir, "jira-oauth.json"),
+		"GIT_SYNC_PUSH":           "off",
+	}
+	for _, opt := range opts {
+		opt(env)
+	}
+	if err := os.MkdirAll(env["DIAGRAMS_PATH"], 0o755); err != nil {
+		t.Fatalf("flowgentest: %v", err)
+	}
+
+	serverMu.Lock()
+	restore := setEnv(env)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	api.SetupServer(r)
+
+	s := &Server{Server: httptest.NewServer(r), Dir: dir, t: t}
+	t.Cleanup(func() {
+		s.Close()
+		restore()
+		serverMu.Unlock()
+	})
+	return s
+}
+
+// setEnv sets environment variables and returns a function restoring them
+func setEnv(env map[string]string) func() {
+	previous := make(map[string]*string, len(env))
+	for key, value := range env {
+		if old, ok := os.LookupEnv(key); ok {
+			previous[key] = &old
+		} else {
+			previous[key] = nil
+		}
+		os.Setenv(key, value)
+	}
+	return func() {
+		for key, old := range previous {
+			if old == nil {
+				os.Unsetenv(key)
+			} else {
+				os.Setenv(key, *old)
+			}
+		}
+	}
+}
+
+// Do sends a request to the server and returns the response with its body
+// read. A non-nil body is sent as JSON, or as is when it is a string or
+// []byte. Transport errors fail the test.
+func (s *Server) Do(method, path string, body interface{}) (*http.Response, []byte) {
+	s.t.Helper()
+	var reader io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader, contentType = strings.NewReader(b), "application/yaml"
+	case []byte:
+		reader, contentType = bytes.NewReader(b), "application/yaml"
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("flowgentest: encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		s.t.Fatalf("flowgentest: %v", err)
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("flowgentest: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("flowgentest: read response of %s %s: %v", method, path, err)
+	}
+	return resp, data
+}
+
+// Seed creates a diagram through the API, so it is validated and stamped
+// like any other, and returns its ID. diagram is anything that encodes to
+// the JSON of a diagram, such as a map or a struct of your own.
+func (s *Server) Seed(diagram interface{}) string {
+	s.t.Helper()
+	resp, body := s.Do(http.MethodPost, "/api/v1/diagrams", diagram)
+	if resp.StatusCode != http.StatusCreated {
+		s.t.Fatalf("flowgentest: seed diagram: %s: %s", resp.Status, body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		s.t.Fatalf("flowgentest: seed diagram: %v", err)
+	}
+	return created.ID
+}
+
+// SeedYAML writes a diagram file straight into the diagrams directory, the
+// way one would be committed to a diagrams repository, and returns its ID.
+// Unlike Seed it bypasses validation, so fixtures may be invalid.
+func (s *Server) SeedYAML(text string) string {
+	s.t.Helper()
+	var header struct {
+		ID string `yaml:"id"`
+	}
+	if err := yaml.Unmarshal([]byte(text), &header); err != nil || header.ID == "" {
+		s.t.Fatalf("flowgentest: seed YAML needs an id: %v", err)
+	}
+	path := filepath.Join(s.Dir, "diagrams", filepath.Base(header.ID)+".yaml")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		s.t.Fatalf("flowgentest: %v", err)
+	}
+	return header.ID
+}
+
+// StoredYAML returns the YAML file of a diagram as stored on disk
+func (s *Server) StoredYAML(id string) string {
+	s.t.Helper()
+	diagram, err := services.NewDiagramService().GetByID(id)
+	if err != nil {
+		s.t.Fatalf("flowgentest: diagram %s: %v", id, err)
+	}
+	data, err := os.ReadFile(diagram.FilePath)
+	if err != nil {
+		s.t.Fatalf("flowgentest: %v", err)
+	}
+	return string(data)
+}
+
+// Stored returns a value from the stored YAML of a diagram. path uses dots
+// and indexes, e.g. "nodes[2].style.fill"; "" returns the whole document.
+// The second result is false when the path does not exist.
+func (s *Server) Stored(id, path string) (interface{}, bool) {
+	s.t.Helper()
+	var document interface{}
+	if err := yaml.Unmarshal([]byte(s.StoredYAML(id)), &document); err != nil {
+		s.t.Fatalf("flowgentest: parse stored YAML of %s: %v", id, err)
+	}
+	return lookup(document, path)
+}
+
+// AssertStored fails the test unless the value at path in the stored YAML
+// of a diagram equals want. Values are compared by their JSON encoding, so
+// an int matches the same number read from YAML.
+func (s *Server) AssertStored(id, path string, want interface{}) {
+	s.t.Helper()
+	got, ok := s.Stored(id, path)
+	if !ok {
+		s.t.Errorf("flowgentest: diagram %s has no %s", id, path)
+		return
+	}
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		s.t.Fatalf("flowgentest: %v", err)
+	}
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		s.t.Fatalf("flowgentest: %v", err)
+	}
+	if !bytes.Equal(gotJSON, wantJSON) {
+		s.t.Errorf("flowgentest: diagram %s %s = %s, want %s", id, path, gotJSON, wantJSON)
+	}
+}
+
+// pathSegment matches a key with optional indexes, e.g. nodes[2]
+var pathSegment = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+
+// lookup follows a path like nodes[2].name into decoded YAML
+func lookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+	for _, segment := range strings.Split(path, ".") {
+		match := pathSegment.FindStringSubmatch(segment)
+		if match == nil {
+			return nil, false
+		}
+		if match[1] != "" {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = m[match[1]]; !ok {
+				return nil, false
+			}
+		}
+		for _, index := range strings.Split(strings.Trim(match[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			i, _ := strconv.Atoi(index)
+			list, ok := value.([]interface{})
+			if !ok || i >= len(list) {
+				return nil, false
+			}
+			value = list[i]
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// defaultViewsPeriod is how far back most-viewed counts without ?since=
+const defaultViewsPeriod = 30 * 24 * time.Hour
+
+// LogDiagramAccess records successful reads of a diagram in the access log,
+// named by the route below the diagram, e.g. get, yaml or export
+func LogDiagramAccess(c *gin.Context) {
+	c.Next()
+
+	id := c.Param("id")
+	if c.Request.Method != http.MethodGet || id == "" || c.Writer.Status() >= http.StatusBadRequest {
+		return
+	}
+	operation := "get"
+	if rest := strings.Trim(strings.TrimPrefix(c.FullPath(), "/api/v1/diagrams/:id"), "/"); rest != "" {
+		operation = strings.SplitN(rest, "/", 2)[0]
+	}
+	if err := services.NewAccessLogService().Record(id, operation, c.Request.Header); err != nil {
+		log.Printf("Access log: %v", err)
+	}
+}
+
+// GetMostViewedDiagrams returns the diagrams read most often. ?since=
+// (RFC 3339) defaults to 30 days ago and ?limit= to 10.
+func GetMostViewedDiagrams(c *gin.Context) {
+	since := time.Now().Add(-defaultViewsPeriod)
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid since timestamp",
+				"details": err.Error(),
+			})
+			return
+		}
+		since = parsed
+	}
+	limit := 10
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid limit",
+				"details": err.Error(),
+			})
+			return
+		}
+		limit = n
+	}
+
+	accessLogService := services.NewAccessLogService()
+
+	views, err := accessLogService.MostViewed(since, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotConfigured):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Access logging is not configured",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidOptions):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid limit",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to read access log",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diagrams": views,
+		"since":    since.UTC(),
+	})
+}
+
+// GetDiagramUsage returns how often each diagram was read over ?window=
+// (default 30d; also e.g. 2w or 12h), with unique viewers and the trend
+// against the window before
+func GetDiagramUsage(c *gin.Context) {
+	window, err := services.ParseUsageWindow(c.DefaultQuery("window", "30d"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid window",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	accessLogService := services.NewAccessLogService()
+
+	report, err := accessLogService.Usage(window)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotConfigured):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Access logging is not configured",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidOptions):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid window",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to read access log",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
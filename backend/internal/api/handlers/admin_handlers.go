@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// SeedDiagrams loads the example diagrams of FLOWGEN_SEED_DIR. ?wipe=true
+// removes all other diagrams first, for a known state before e2e runs.
+func SeedDiagrams(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	result, err := diagramService.Seed(c.Query("wipe") == "true")
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotConfigured):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Seeding is not configured",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrSeedingDisabled):
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Seeding is disabled",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidDiagram):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Invalid seed diagram",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to seed diagrams",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetServerStatus reports the startup checks, the progress of warming the
+// diagram index and the diagram files that could not be parsed
+func GetServerStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, services.NewDiagramService().Status())
+}
+
+// GetDiagramLoadErrors lists the quarantined diagram files: those that
+// could not be parsed, with the parse error and modification time
+func GetDiagramLoadErrors(c *gin.Context) {
+	loadErrors, err := services.NewDiagramService().LoadErrors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to check diagram files",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files": loadErrors,
+		"count": len(loadErrors),
+	})
+}
+
+// ReorganizeDiagrams moves diagram files to the paths the file policy gives
+// them (DIAGRAM_FOLDERS) and reports IDs breaking DIAGRAM_NAMING.
+// ?dryRun=true only reports the moves.
+func ReorganizeDiagrams(c *gin.Context) {
+	result, err := services.NewDiagramService().Reorganize(c.Query("dryRun") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to reorganize diagrams",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetDiagramTiming returns best and worst-case end-to-end durations per path
+// and the steps that breach their SLA
+func GetDiagramTiming(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	report, err := diagramService.Timing(id)
+	if err != nil {
+		respondAnalysisError(c, err, "timing")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetDiagramCosts rolls up node costs per path and per diagram, weighted by
+// branch probabilities
+func GetDiagramCosts(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	report, err := diagramService.Costs(id)
+	if err != nil {
+		respondAnalysisError(c, err, "cost")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// respondAnalysisError maps analysis errors: unknown diagrams are 404 and
+// bad options or annotations the analysis cannot use are 400
+func respondAnalysisError(c *gin.Context, err error, analysis string) {
+	if err == services.ErrDiagramNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Diagram not found",
+		})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidOptions) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid " + analysis + " options",
+			"details": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidDiagram) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid " + analysis + " data",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Failed to analyze " + analysis,
+		"details": err.Error(),
+	})
+}
+
+// MonteCarloRequest selects the diagram to simulate
+type MonteCarloRequest struct {
+	DiagramID string `json:"diagramId" binding:"required"`
+	services.MonteCarloOptions
+}
+
+// SimulateMonteCarlo runs a Monte Carlo simulation of a diagram and returns
+// duration and cost statistics with path frequencies
+func SimulateMonteCarlo(c *gin.Context) {
+	var req MonteCarloRequest
+	if !bindJSON(c, &req, "Invalid simulation request") {
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	result, err := diagramService.MonteCarlo(req.DiagramID, req.MonteCarloOptions)
+	if err != nil {
+		respondAnalysisError(c, err, "simulation")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
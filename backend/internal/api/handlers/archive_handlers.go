@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// ArchiveDiagram moves a diagram out of the active listing into the archive
+func ArchiveDiagram(c *gin.Context) {
+	diagramService := diagramServiceFor(c)
+
+	diagram, err := diagramService.Archive(c.Param("id"))
+	if err != nil {
+		respondArchiveError(c, err)
+		return
+	}
+	if respondDryRun(c, diagramService, diagram) {
+		return
+	}
+
+	c.JSON(http.StatusOK, diagram)
+}
+
+// UnarchiveDiagram returns an archived diagram to the active listing
+func UnarchiveDiagram(c *gin.Context) {
+	diagramService := diagramServiceFor(c)
+
+	diagram, err := diagramService.Unarchive(c.Param("id"))
+	if err != nil {
+		respondArchiveError(c, err)
+		return
+	}
+	if respondDryRun(c, diagramService, diagram) {
+		return
+	}
+
+	c.JSON(http.StatusOK, diagram)
+}
+
+func respondArchiveError(c *gin.Context, err error) {
+	switch {
+	case err == services.ErrDiagramNotFound:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Diagram not found",
+		})
+	case errors.Is(err, services.ErrDiagramExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Cannot move diagram",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Diagram is read-only",
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to move diagram",
+			"details": err.Error(),
+		})
+	}
+}
+
+// includeArchived reports whether a list or search asks for archived
+// diagrams as well
+func includeArchived(c *gin.Context) bool {
+	return c.Query("includeArchived") == "true"
+}
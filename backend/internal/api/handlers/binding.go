@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// fieldError is one problem with a field of a JSON request body
+type fieldError struct {
+	Field   string `json:"field"` // JSON path, e.g. nodes[2].decription
+	Message string `json:"message"`
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// bindJSON decodes the request body into obj like ShouldBindJSON, but
+// rejects fields obj does not have unless ?lenient=true, so that typos do
+// not go unnoticed. On failure it answers 400 with the problems listed per
+// field and returns false.
+func bindJSON(c *gin.Context, obj interface{}, message string) bool {
+	fields, err := decodeJSONBody(c.Request.Body, obj, c.Query("lenient") != "true")
+	if err == nil {
+		return true
+	}
+	response := gin.H{
+		"error":   message,
+		"details": err.Error(),
+	}
+	if len(fields) > 0 {
+		response["fields"] = fields
+	}
+	c.JSON(http.StatusBadRequest, response)
+	return false
+}
+
+func decodeJSONBody(body io.Reader, obj interface{}, strict bool) ([]fieldError, error) {
+	if body == nil {
+		return nil, errors.New("request body is empty")
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, errors.New("request body is empty")
+	}
+
+	if strict {
+		var raw interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		fields := []fieldError{}
+		findUnknownFields(raw, reflect.TypeOf(obj), "", &fields)
+		if len(fields) > 0 {
+			names := make([]string, len(fields))
+			for i, field := range fields {
+				names[i] = field.Field
+			}
+			return fields, fmt.Errorf("unknown fields: %s (use ?lenient=true to ignore them)", strings.Join(names, ", "))
+		}
+	}
+
+	if err := json.Unmarshal(data, obj); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return []fieldError{{Field: typeErr.Field, Message: "must be " + typeErr.Type.String()}}, err
+		}
+		return nil, err
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return nil, err
+		}
+		fields := make([]fieldError, len(validationErrs))
+		for i, validationErr := range validationErrs {
+			fields[i] = fieldError{
+				Field:   jsonPath(reflect.TypeOf(obj), validationErr.StructNamespace()),
+				Message: validationMessage(validationErr),
+			}
+		}
+		return fields, err
+	}
+	return nil, nil
+}
+
+// findUnknownFields walks decoded JSON alongside the Go type it is bound
+// to and records the object keys that no field matches
+func findUnknownFields(value interface{}, t reflect.Type, path string, fields *[]fieldError) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		known := jsonFields(t)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldType, ok := known[key]
+			if !ok {
+				// encoding/json matches keys case-insensitively
+				for name, candidate := range known {
+					if strings.EqualFold(name, key) {
+						fieldType, ok = candidate, true
+						break
+					}
+				}
+			}
+			if !ok {
+				*fields = append(*fields, fieldError{Field: joinJSONPath(path, key), Message: "unknown field"})
+				continue
+			}
+			findUnknownFields(object[key], fieldType, joinJSONPath(path, key), fields)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			findUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, item := range object {
+			findUnknownFields(item, t.Elem(), joinJSONPath(path, key), fields)
+		}
+	}
+}
+
+// jsonFields returns the JSON names of a struct's fields, including those
+// promoted from embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		embedded := field.Type
+		if embedded.Kind() == reflect.Ptr {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			for promoted, promotedType := range jsonFields(embedded) {
+				if _, ok := fields[promoted]; !ok {
+					fields[promoted] = promotedType
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// jsonPath turns a validator namespace of Go field names (Type.Field[0].Sub)
+// into the JSON path the client sent (field[0].sub)
+func jsonPath(t reflect.Type, namespace string) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// Anonymous request structs have no type name in the namespace
+	namespace = strings.TrimPrefix(strings.TrimPrefix(namespace, t.Name()), ".")
+	path := ""
+	for _, segment := range strings.Split(namespace, ".") {
+		name, index, _ := strings.Cut(segment, "[")
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		jsonName := name
+		if t.Kind() == reflect.Struct {
+			if field, ok := t.FieldByName(name); ok {
+				if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+					jsonName = tag
+				}
+				t = field.Type
+			}
+		}
+		path = joinJSONPath(path, jsonName)
+		if index != "" {
+			path += "[" + index
+			for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+	}
+	return path
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func validationMessage(err validator.FieldError) string {
+	if err.Tag() == "required" {
+		return "is required"
+	}
+	if err.Param() != "" {
+		return fmt.Sprintf("must satisfy %s=%s", err.Tag(), err.Param())
+	}
+	return "must satisfy " + err.Tag()
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// BulkEditMetadata sets, removes or transfers metadata and ownership across
+// the diagrams matching a filter, all or nothing. ?dryRun=true, or dryRun
+// in the body, lists the changes without saving. When a changed diagram is
+// read-only or would not validate, nothing is saved and 422 lists the
+// failures.
+func BulkEditMetadata(c *gin.Context) {
+	var edit services.BulkMetadataEdit
+	if !bindJSON(c, &edit, "Invalid bulk metadata request") {
+		return
+	}
+	edit.DryRun = edit.DryRun || c.Query("dryRun") == "true"
+
+	diagramService := services.NewDiagramService().ActingFor(c.Request.Header)
+
+	result, err := diagramService.BulkEditMetadata(edit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid bulk metadata request",
+				"details": err.Error(),
+			})
+			return
+		}
+		if respondSaveRejected(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to edit diagram metadata",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if result.Failed > 0 && !result.DryRun {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Bulk metadata edit rejected",
+			"details": fmt.Sprintf("%d diagrams cannot be saved; no diagram was changed", result.Failed),
+			"result":  result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// ListCatalogNodes returns the canonical nodes of the node catalog
+func ListCatalogNodes(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	catalog, err := diagramService.CatalogNodes()
+	if err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nodes": catalog.Nodes,
+		"count": len(catalog.Nodes),
+	})
+}
+
+// GetCatalogNode returns one catalog node
+func GetCatalogNode(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	entry, err := diagramService.CatalogNode(c.Param("id"))
+	if err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetCatalogNodeUsage lists the diagram nodes that reference a catalog node,
+// each with the fields that have drifted from it
+func GetCatalogNodeUsage(c *gin.Context) {
+	id := c.Param("id")
+	diagramService := services.NewDiagramService()
+
+	usages, err := diagramService.CatalogUsage(id)
+	if err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	drifted := 0
+	for _, usage := range usages {
+		if len(usage.Drift) > 0 {
+			drifted++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"catalogId": id,
+		"usages":    usages,
+		"count":     len(usages),
+		"drifted":   drifted,
+	})
+}
+
+// PropagateCatalogNode updates the name and metadata of every node that
+// references the catalog node to match it, after the catalog changed.
+// ?dryRun=true lists the changes without saving.
+func PropagateCatalogNode(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	result, err := diagramService.PropagateCatalogNode(c.Param("id"), c.Query("dryRun") == "true")
+	if err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondCatalogError maps node catalog errors to responses
+func respondCatalogError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Node catalog is not configured",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrCatalogNodeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Catalog node not found",
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read node catalog",
+			"details": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetConsistencyReport returns the latest consistency report
+func GetConsistencyReport(c *gin.Context) {
+	consistencyService := services.NewConsistencyService()
+
+	report, err := consistencyService.Latest()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load consistency report",
+			"details": err.Error(),
+		})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No consistency check has run yet",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// RunConsistencyCheck re-validates all diagrams and their references now
+func RunConsistencyCheck(c *gin.Context) {
+	consistencyService := services.NewConsistencyService()
+
+	report, err := consistencyService.Run()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Consistency check failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// DeprecateDiagram marks a diagram as deprecated, optionally pointing to
+// the diagram that supersedes it. Deprecating again updates the reason and
+// successor but keeps the original date.
+func DeprecateDiagram(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		SupersededBy string `json:"supersededBy"`
+		Reason       string `json:"reason"`
+	}
+	if !bindJSON(c, &request, "Invalid deprecation request") {
+		return
+	}
+
+	diagramService := diagramServiceFor(c)
+
+	diagram, err := diagramService.Deprecate(id, services.DeprecateOptions{
+		SupersededBy: request.SupersededBy,
+		Reason:       request.Reason,
+	})
+	if err != nil {
+		respondDeprecationError(c, err)
+		return
+	}
+	if respondDryRun(c, diagramService, diagram) {
+		return
+	}
+
+	setDeprecationHeaders(c, diagram, "/api/v1/diagrams/")
+	c.JSON(http.StatusOK, diagram)
+}
+
+// UndeprecateDiagram returns a deprecated diagram to normal use
+func UndeprecateDiagram(c *gin.Context) {
+	id := c.Param("id")
+
+	diagramService := diagramServiceFor(c)
+
+	diagram, err := diagramService.Undeprecate(id)
+	if err != nil {
+		respondDeprecationError(c, err)
+		return
+	}
+	if respondDryRun(c, diagramService, diagram) {
+		return
+	}
+
+	c.JSON(http.StatusOK, diagram)
+}
+
+func respondDeprecationError(c *gin.Context, err error) {
+	switch {
+	case err == services.ErrDiagramNotFound:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Diagram not found",
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid deprecation request",
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update deprecation",
+			"details": err.Error(),
+		})
+	}
+}
+
+// setDeprecationHeaders announces a deprecated diagram with a Deprecation
+// header (RFC 9745) and links its successor, addressed below basePath
+func setDeprecationHeaders(c *gin.Context, diagram *models.FlowDiagram, basePath string) {
+	if diagram.Deprecated == nil {
+		return
+	}
+	c.Header("Deprecation", fmt.Sprintf("@%d", diagram.Deprecated.Since.Unix()))
+	if diagram.Deprecated.SupersededBy != nil {
+		c.Header("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, basePath, *diagram.Deprecated.SupersededBy))
+	}
+}
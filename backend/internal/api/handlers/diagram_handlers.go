@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/michaellanpart/flowgen/backend/internal/models"
@@ -13,7 +16,7 @@ import (
 func ListDiagrams(c *gin.Context) {
 	diagramService := services.NewDiagramService()
 
-	diagrams, err := diagramService.ListAll()
+	diagrams, partial, err := diagramService.ListAvailable(includeArchived(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to list diagrams",
@@ -22,10 +25,39 @@ func ListDiagrams(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	if status := c.Query("validation"); status != "" {
+		diagrams, err = diagramService.FilterByValidation(diagrams, status)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidOptions) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid validation filter",
+					"details": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to filter diagrams",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	lang := c.Query("lang")
+	for i := range diagrams {
+		diagramService.Localize(&diagrams[i], lang)
+	}
+
+	response := gin.H{
 		"diagrams": diagrams,
 		"count":    len(diagrams),
-	})
+	}
+	if partial {
+		// Still starting up: only the diagrams indexed so far
+		response["partial"] = true
+		response["index"] = services.DiagramIndexStatus()
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // GetDiagram returns a specific diagram by ID
@@ -55,6 +87,10 @@ func GetDiagram(c *gin.Context) {
 		return
 	}
 
+	diagramService.Localize(diagram, c.Query("lang"))
+
+	setDeprecationHeaders(c, diagram, "/api/v1/diagrams/")
+	c.Header("ETag", strconv.Quote(diagram.ContentHash))
 	c.JSON(http.StatusOK, diagram)
 }
 
@@ -62,18 +98,17 @@ func GetDiagram(c *gin.Context) {
 func CreateDiagram(c *gin.Context) {
 	var diagram models.FlowDiagram
 
-	if err := c.ShouldBindJSON(&diagram); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid diagram data",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &diagram, "Invalid diagram data") {
 		return
 	}
 
-	diagramService := services.NewDiagramService()
+	diagramService := diagramServiceFor(c)
 
 	createdDiagram, err := diagramService.Create(&diagram)
 	if err != nil {
+		if respondSaveRejected(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create diagram",
 			"details": err.Error(),
@@ -81,6 +116,10 @@ func CreateDiagram(c *gin.Context) {
 		return
 	}
 
+	setValidationWarnings(c, diagramService, createdDiagram)
+	if respondDryRun(c, diagramService, createdDiagram) {
+		return
+	}
 	c.JSON(http.StatusCreated, createdDiagram)
 }
 
@@ -96,18 +135,29 @@ func UpdateDiagram(c *gin.Context) {
 
 	var diagram models.FlowDiagram
 
-	if err := c.ShouldBindJSON(&diagram); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid diagram data",
-			"details": err.Error(),
-		})
+	if !bindJSON(c, &diagram, "Invalid diagram data") {
 		return
 	}
 
 	// Ensure the ID matches
 	diagram.ID = id
+	if match := c.GetHeader("If-Match"); match != "" {
+		diagram.ContentHash = strings.Trim(match, `"`)
+	}
 
-	diagramService := services.NewDiagramService()
+	switch c.Query("mode") {
+	case "":
+	case "propose":
+		proposeDiagramChange(c, &diagram)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid mode: " + c.Query("mode"),
+		})
+		return
+	}
+
+	diagramService := diagramServiceFor(c)
 
 	updatedDiagram, err := diagramService.Update(&diagram)
 	if err != nil {
@@ -117,6 +167,9 @@ func UpdateDiagram(c *gin.Context) {
 			})
 			return
 		}
+		if respondSaveRejected(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update diagram",
 			"details": err.Error(),
@@ -124,9 +177,64 @@ func UpdateDiagram(c *gin.Context) {
 		return
 	}
 
+	setValidationWarnings(c, diagramService, updatedDiagram)
+	if respondDryRun(c, diagramService, updatedDiagram) {
+		return
+	}
+	c.Header("ETag", strconv.Quote(updatedDiagram.ContentHash))
 	c.JSON(http.StatusOK, updatedDiagram)
 }
 
+// proposeDiagramChange opens a pull request with the updated diagram
+// instead of saving it. ?title=, ?description= and ?branch= customize it.
+func proposeDiagramChange(c *gin.Context, diagram *models.FlowDiagram) {
+	opts := services.ProposeOptions{
+		Title:       c.Query("title"),
+		Description: c.Query("description"),
+		Branch:      c.Query("branch"),
+	}
+
+	proposalService := services.NewProposalService()
+
+	proposal, err := proposalService.Propose(diagram, opts)
+	if err != nil {
+		switch {
+		case err == services.ErrDiagramNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+		case errors.Is(err, services.ErrInvalidDiagram):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid diagram",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidOptions):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid proposal",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrBranchExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Branch already exists",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrNotConfigured):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Change proposals not configured",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":   "Failed to propose change",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, proposal)
+}
+
 // DeleteDiagram deletes a diagram
 func DeleteDiagram(c *gin.Context) {
 	id := c.Param("id")
@@ -137,7 +245,7 @@ func DeleteDiagram(c *gin.Context) {
 		return
 	}
 
-	diagramService := services.NewDiagramService()
+	diagramService := diagramServiceFor(c)
 
 	err := diagramService.Delete(id)
 	if err != nil {
@@ -147,6 +255,13 @@ func DeleteDiagram(c *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, services.ErrReadOnly) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Diagram is read-only",
+				"details": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to delete diagram",
 			"details": err.Error(),
@@ -154,6 +269,9 @@ func DeleteDiagram(c *gin.Context) {
 		return
 	}
 
+	if respondDryRun(c, diagramService, nil) {
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Diagram deleted successfully",
 	})
@@ -195,6 +313,18 @@ func ValidateDiagram(c *gin.Context) {
 		return
 	}
 
+	if err := diagramService.RecordValidation(diagram, validationResult); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to record validation",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	locale := services.NegotiateValidationLocale(c.GetHeader("Accept-Language"))
+	services.LocalizeValidation(validationResult, locale)
+	c.Header("Content-Language", locale)
+
 	c.JSON(http.StatusOK, validationResult)
 }
 
@@ -228,29 +358,194 @@ func UpdateDiagramYAML(c *gin.Context) {
 		return
 	}
 
-	// Read raw text body
-	body, err := io.ReadAll(c.Request.Body)
+	svc := diagramServiceFor(c)
+
+	// Read raw text body, stopping past the size quota
+	body, err := io.ReadAll(svc.LimitYAML(c.Request.Body))
 	if err != nil {
 		c.String(http.StatusBadRequest, "failed to read request body: %v", err)
 		return
 	}
 
 	yamlText := string(body)
-	svc := services.NewDiagramService()
 
-	// Save and validate
-	if err := svc.SaveYAMLByID(id, yamlText); err != nil {
+	// Save and validate, as the JSON update does
+	saved, err := svc.SaveYAMLByID(id, yamlText, strings.Trim(c.GetHeader("If-Match"), `"`))
+	if err != nil {
 		if err == services.ErrDiagramNotFound {
 			c.String(http.StatusNotFound, "diagram not found")
 			return
 		}
-		c.String(http.StatusBadRequest, "invalid yaml: %v", err)
+		if respondSaveRejected(c, err) || respondYAMLError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidOptions) {
+			c.String(http.StatusBadRequest, "invalid yaml: %v", err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to save diagram",
+			"details": err.Error(),
+		})
 		return
 	}
 
+	if respondDryRun(c, svc, saved) {
+		return
+	}
+	c.Header("ETag", strconv.Quote(saved.ContentHash))
 	c.String(http.StatusOK, "ok")
 }
 
+// CreateDiagramYAML creates a diagram from a raw YAML body and answers with
+// the YAML it was stored as
+func CreateDiagramYAML(c *gin.Context) {
+	svc := diagramServiceFor(c)
+
+	// Read raw text body, stopping past the size quota
+	body, err := io.ReadAll(svc.LimitYAML(c.Request.Body))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	diagram, stored, err := svc.CreateYAML(string(body))
+	if err != nil {
+		if respondSaveRejected(c, err) || respondYAMLError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrDiagramExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Diagram already exists",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidOptions):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid YAML",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create diagram",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	setValidationWarnings(c, svc, diagram)
+	if respondDryRun(c, svc, gin.H{"diagram": diagram, "yaml": string(stored)}) {
+		return
+	}
+	c.Header("Location", "/api/v1/diagrams/"+diagram.ID)
+	c.Header("ETag", strconv.Quote(diagram.ContentHash))
+	c.Data(http.StatusCreated, "application/yaml; charset=utf-8", stored)
+}
+
+// respondYAMLError answers 400 with the position of each problem when the
+// YAML did not parse, so the editor can jump to it, and reports whether it
+// did
+func respondYAMLError(c *gin.Context, err error) bool {
+	var yamlErr *services.YAMLError
+	if !errors.As(err, &yamlErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":    "Invalid YAML",
+		"details":  err.Error(),
+		"problems": yamlErr.Problems,
+	})
+	return true
+}
+
+// FormatDiagramYAML returns the YAML body in the server's canonical style
+// without saving it, so that pre-commit hooks format diagrams exactly as
+// the server would
+func FormatDiagramYAML(c *gin.Context) {
+	svc := services.NewDiagramService()
+
+	body, err := io.ReadAll(svc.LimitYAML(c.Request.Body))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	formatted, err := svc.FormatYAML(string(body))
+	if err != nil {
+		var yamlErr *services.YAMLError
+		switch {
+		case errors.Is(err, services.ErrQuotaExceeded):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Quota exceeded",
+				"details": err.Error(),
+			})
+		case errors.As(err, &yamlErr):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    "Invalid YAML",
+				"details":  err.Error(),
+				"problems": yamlErr.Problems,
+			})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Failed to format YAML",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", formatted)
+}
+
+// LintDiagramYAML returns style diagnostics for diagram YAML: the request
+// body, or the stored YAML when the body is empty. Nothing is saved.
+func LintDiagramYAML(c *gin.Context) {
+	svc := services.NewDiagramService()
+
+	body, err := io.ReadAll(svc.LimitYAML(c.Request.Body))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	diagnostics, err := svc.LintYAML(c.Param("id"), string(body))
+	if err != nil {
+		switch {
+		case err == services.ErrDiagramNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+		case errors.Is(err, services.ErrQuotaExceeded):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Quota exceeded",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to lint YAML",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diagramId":   c.Param("id"),
+		"diagnostics": diagnostics,
+		"count":       len(diagnostics),
+	})
+}
+
 // SearchDiagrams searches for diagrams based on query parameters
 func SearchDiagrams(c *gin.Context) {
 	query := c.Query("q")
@@ -258,7 +553,7 @@ func SearchDiagrams(c *gin.Context) {
 
 	diagramService := services.NewDiagramService()
 
-	results, err := diagramService.Search(query, tags)
+	results, err := diagramService.Search(query, tags, includeArchived(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to search diagrams",
@@ -267,6 +562,11 @@ func SearchDiagrams(c *gin.Context) {
 		return
 	}
 
+	lang := c.Query("lang")
+	for i := range results {
+		diagramService.Localize(&results[i].Diagram, lang)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"results": results,
 		"count":   len(results),
@@ -275,14 +575,50 @@ func SearchDiagrams(c *gin.Context) {
 	})
 }
 
-// SearchNodes searches for nodes across all diagrams
+// SearchNodes searches for nodes across all diagrams.
+// Results describe their diagram with a summary by default; pass
+// ?include=diagram to embed the full parent diagram as well.
 func SearchNodes(c *gin.Context) {
 	query := c.Query("q")
 	nodeType := c.Query("type")
 
+	includeDiagram := false
+	for _, include := range strings.Split(c.DefaultQuery("include", "diagramSummary"), ",") {
+		switch strings.TrimSpace(include) {
+		case "diagram":
+			includeDiagram = true
+		case "diagramSummary", "":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid include option",
+				"details": "supported values are diagramSummary and diagram",
+			})
+			return
+		}
+	}
+
+	role := c.Query("role")
+	validRole := role == ""
+	for _, r := range models.OwnershipRoles {
+		validRole = validRole || r == role
+	}
+	if !validRole {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid role",
+			"details": "supported values are " + strings.Join(models.OwnershipRoles, ", "),
+		})
+		return
+	}
+
 	diagramService := services.NewDiagramService()
 
-	results, err := diagramService.SearchNodes(query, nodeType)
+	results, err := diagramService.SearchNodes(query, services.NodeSearchOptions{
+		Type:            nodeType,
+		Owner:           c.Query("owner"),
+		Role:            role,
+		IncludeDiagram:  includeDiagram,
+		IncludeArchived: includeArchived(c),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to search nodes",
@@ -291,6 +627,21 @@ func SearchNodes(c *gin.Context) {
 		return
 	}
 
+	if lang := c.Query("lang"); lang != "" {
+		for i := range results {
+			localized := models.FlowDiagram{Nodes: []models.FlowNode{results[i].Node}}
+			diagramService.Localize(&localized, lang)
+			results[i].Node = localized.Nodes[0]
+			if results[i].Diagram != nil {
+				diagram := *results[i].Diagram
+				diagram.Nodes = append([]models.FlowNode{}, diagram.Nodes...)
+				diagram.Edges = append([]models.FlowEdge{}, diagram.Edges...)
+				diagramService.Localize(&diagram, lang)
+				results[i].Diagram = &diagram
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"results": results,
 		"count":   len(results),
@@ -298,3 +649,71 @@ func SearchNodes(c *gin.Context) {
 		"type":    nodeType,
 	})
 }
+
+// SearchContent searches the raw YAML of all diagrams and returns file/line positions
+func SearchContent(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Query parameter q is required",
+		})
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	results, err := diagramService.SearchContent(query, includeArchived(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search content",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"count":   len(results),
+		"query":   query,
+	})
+}
+
+// GetDiagramView returns a filtered projection of a diagram.
+// ?nodeTypes=process,decision and ?tags=payment select the nodes to keep;
+// paths through removed nodes become synthesized pass-through edges.
+func GetDiagramView(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	selector := services.ElementSelector{
+		NodeTypes: queryList(c, "nodeTypes"),
+		Tags:      queryList(c, "tags"),
+		Owners:    queryList(c, "owners"),
+	}
+
+	diagramService := services.NewDiagramService()
+
+	view, err := diagramService.View(id, selector, queryList(c, "layers"))
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to build diagram view",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	diagramService.Localize(view, c.Query("lang"))
+
+	c.JSON(http.StatusOK, view)
+}
@@ -0,0 +1,279 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/flowgentest"
+)
+
+// checkoutDiagram is a valid two-node diagram
+func checkoutDiagram() map[string]interface{} {
+	return map[string]interface{}{
+		"id":      "checkout",
+		"name":    "Checkout",
+		"version": "1.0.0",
+		"nodes": []map[string]interface{}{
+			{"id": "start", "name": "Start", "type": "start", "position": map[string]int{"x": 0, "y": 0}},
+			{"id": "pay", "name": "Pay", "type": "process", "position": map[string]int{"x": 200, "y": 0}},
+		},
+		"edges": []map[string]interface{}{
+			{"id": "start_pay", "type": "sequence", "from": "start", "to": "pay"},
+		},
+	}
+}
+
+// timestamps returns the created and updated times of a stored diagram
+func timestamps(t *testing.T, srv *flowgentest.Server, id string) (time.Time, time.Time) {
+	t.Helper()
+	resp, body := srv.Do(http.MethodGet, "/api/v1/diagrams/"+id, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get %s: %s: %s", id, resp.Status, body)
+	}
+	var diagram struct {
+		Created time.Time `json:"created"`
+		Updated time.Time `json:"updated"`
+	}
+	if err := json.Unmarshal(body, &diagram); err != nil {
+		t.Fatal(err)
+	}
+	return diagram.Created, diagram.Updated
+}
+
+// updates sends the same diagram through the JSON and the YAML update
+// endpoints
+var updates = []struct {
+	name string
+	put  func(srv *flowgentest.Server, diagram map[string]interface{}) (*http.Response, []byte)
+}{
+	{"json", func(srv *flowgentest.Server, diagram map[string]interface{}) (*http.Response, []byte) {
+		return srv.Do(http.MethodPut, "/api/v1/diagrams/"+diagram["id"].(string), diagram)
+	}},
+	{"yaml", func(srv *flowgentest.Server, diagram map[string]interface{}) (*http.Response, []byte) {
+		data, err := yaml.Marshal(diagram)
+		if err != nil {
+			panic(err)
+		}
+		return srv.Do(http.MethodPut, "/api/v1/diagrams/"+diagram["id"].(string)+"/yaml", data)
+	}},
+}
+
+func TestUpdateKeepsCreatedAndBumpsUpdated(t *testing.T) {
+	for _, update := range updates {
+		t.Run(update.name, func(t *testing.T) {
+			srv := flowgentest.New(t)
+			id := srv.Seed(checkoutDiagram())
+			created, updated := timestamps(t, srv, id)
+			time.Sleep(10 * time.Millisecond)
+
+			diagram := checkoutDiagram()
+			diagram["name"] = "Checkout v2"
+			diagram["created"] = "2001-02-03T04:05:06Z" // Clients cannot rewrite history
+			diagram["updated"] = "2001-02-03T04:05:06Z"
+			if resp, body := update.put(srv, diagram); resp.StatusCode != http.StatusOK {
+				t.Fatalf("update: %s: %s", resp.Status, body)
+			}
+
+			gotCreated, gotUpdated := timestamps(t, srv, id)
+			if !gotCreated.Equal(created) {
+				t.Errorf("created = %v, want %v", gotCreated, created)
+			}
+			if !gotUpdated.After(updated) {
+				t.Errorf("updated = %v, want after %v", gotUpdated, updated)
+			}
+			srv.AssertStored(id, "name", "Checkout v2")
+		})
+	}
+}
+
+func TestUpdateValidatesAlike(t *testing.T) {
+	details := make(map[string]string)
+	for _, update := range updates {
+		t.Run(update.name, func(t *testing.T) {
+			srv := flowgentest.New(t)
+			id := srv.Seed(checkoutDiagram())
+
+			diagram := checkoutDiagram()
+			diagram["edges"] = []map[string]interface{}{
+				{"id": "start_missing", "type": "sequence", "from": "start", "to": "missing"},
+			}
+			resp, body := update.put(srv, diagram)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("update with a dangling edge: %s: %s", resp.Status, body)
+			}
+			var rejected struct {
+				Error   string `json:"error"`
+				Details string `json:"details"`
+			}
+			if err := json.Unmarshal(body, &rejected); err != nil {
+				t.Fatalf("decode %s: %v", body, err)
+			}
+			if rejected.Details == "" {
+				t.Errorf("rejection without details: %s", body)
+			}
+			details[update.name] = rejected.Details
+			srv.AssertStored(id, "edges[0].to", "pay")
+		})
+	}
+	if details["json"] != details["yaml"] {
+		t.Errorf("validation differs:\njson: %s\nyaml: %s", details["json"], details["yaml"])
+	}
+}
+
+func TestCreateRefusesExistingID(t *testing.T) {
+	srv := flowgentest.New(t)
+	id := srv.Seed(checkoutDiagram())
+	created, _ := timestamps(t, srv, id)
+
+	diagram := checkoutDiagram()
+	diagram["name"] = "Another checkout"
+	if resp, body := srv.Do(http.MethodPost, "/api/v1/diagrams", diagram); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("create with a taken ID: %s: %s", resp.Status, body)
+	}
+
+	if gotCreated, _ := timestamps(t, srv, id); !gotCreated.Equal(created) {
+		t.Errorf("created = %v, want %v", gotCreated, created)
+	}
+	srv.AssertStored(id, "name", "Checkout")
+}
+
+func TestConcurrentUpdatesOfOneVersionConflict(t *testing.T) {
+	// A slow validation webhook keeps saves between their edit check and
+	// their write long enough to overlap
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	srv := flowgentest.New(t, flowgentest.WithEnv("VALIDATION_WEBHOOK_URL", hook.URL))
+	id := srv.Seed(checkoutDiagram())
+	resp, body := srv.Do(http.MethodGet, "/api/v1/diagrams/"+id, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get %s: %s: %s", id, resp.Status, body)
+	}
+	var read struct {
+		ContentHash string `json:"contentHash"`
+	}
+	if err := json.Unmarshal(body, &read); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 8
+	statuses := make(chan int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			diagram := checkoutDiagram()
+			diagram["name"] = "Checkout " + string(rune('A'+i))
+			diagram["contentHash"] = read.ContentHash
+			resp, _ := srv.Do(http.MethodPut, "/api/v1/diagrams/"+id, diagram)
+			statuses <- resp.StatusCode
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	saved := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			saved++
+		case http.StatusConflict:
+		default:
+			t.Errorf("update: status %d, want 200 or 409", status)
+		}
+	}
+	if saved != 1 {
+		t.Errorf("%d updates of one version saved, want 1", saved)
+	}
+}
+
+func TestConcurrentCreatesOfOneIDConflict(t *testing.T) {
+	// A slow validation webhook keeps creates between their existence
+	// check and their write long enough to overlap
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	srv := flowgentest.New(t, flowgentest.WithEnv("VALIDATION_WEBHOOK_URL", hook.URL))
+
+	const writers = 8
+	statuses := make(chan int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			diagram := checkoutDiagram()
+			diagram["name"] = "Checkout " + string(rune('A'+i))
+			resp, _ := srv.Do(http.MethodPost, "/api/v1/diagrams", diagram)
+			statuses <- resp.StatusCode
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	created := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("create: status %d, want 201 or 409", status)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d creates of one ID succeeded, want 1", created)
+	}
+}
+
+func TestMoveNodesRestoresTargetWhenSourceSaveFails(t *testing.T) {
+	// Once frozen, the validation webhook vetoes saves of the source, which
+	// is written after the target
+	var frozen atomic.Bool
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Diagram struct {
+				ID string `json:"id"`
+			} `json:"diagram"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if frozen.Load() && payload.Diagram.ID == "inbox" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"errors": [{"message": "inbox is frozen"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	srv := flowgentest.New(t, flowgentest.WithEnv("VALIDATION_WEBHOOK_URL", hook.URL))
+	srv.Seed(checkoutDiagram())
+	inbox := checkoutDiagram()
+	inbox["id"], inbox["name"] = "inbox", "Inbox"
+	srv.Seed(inbox)
+	frozen.Store(true)
+
+	resp, body := srv.Do(http.MethodPost, "/api/v1/diagrams/checkout/nodes/move", map[string]interface{}{
+		"sourceId": "inbox",
+		"nodeIds":  []string{"pay"},
+	})
+	if resp.StatusCode == http.StatusOK {
+		t.Fatalf("move out of a vetoed source succeeded: %s", body)
+	}
+
+	if _, ok := srv.Stored("checkout", "nodes[2]"); ok {
+		t.Errorf("moved node left in the target:\n%s", srv.StoredYAML("checkout"))
+	}
+	srv.AssertStored("inbox", "nodes[1].id", "pay")
+}
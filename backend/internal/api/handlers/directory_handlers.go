@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetDirectory returns the people and teams that ownership fields may
+// reference. The directory is empty when none is configured.
+func GetDirectory(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	directory, err := diagramService.Directory()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load directory",
+			"details": err.Error(),
+		})
+		return
+	}
+	if directory == nil {
+		directory = &models.Directory{People: []models.Person{}, Teams: []models.Team{}}
+	}
+
+	c.JSON(http.StatusOK, directory)
+}
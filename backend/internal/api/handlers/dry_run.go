@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// diagramServiceFor returns the diagram service for a request that changes
+// diagrams: acting for the request's user and, with ?dryRun=true, writing
+// nothing
+func diagramServiceFor(c *gin.Context) *services.DiagramService {
+	diagramService := services.NewDiagramService().ActingFor(c.Request.Header)
+	if c.Query("dryRun") == "true" {
+		return diagramService.DryRun()
+	}
+	return diagramService
+}
+
+// respondDryRun answers a dry run that passed every check with the changes
+// it would have made and the result the request would have returned, and
+// reports whether it did
+func respondDryRun(c *gin.Context, diagramService *services.DiagramService, result interface{}) bool {
+	changes := diagramService.DryRunChanges()
+	if changes == nil {
+		return false
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"dryRun":  true,
+		"changes": changes,
+		"result":  result,
+	})
+	return true
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment so that
+// proxies do not close it
+const eventKeepAlive = 30 * time.Second
+
+// StreamEvents sends server events, such as diagrams changed outside the
+// API, as a Server-Sent Events stream. ?diagram= limits it to one diagram.
+func StreamEvents(c *gin.Context) {
+	events, unsubscribe := services.SubscribeEvents()
+	defer unsubscribe()
+	diagramID := c.Query("diagram")
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+			return true
+		case event := <-events:
+			if diagramID == "" || event.DiagramID == diagramID {
+				c.SSEvent(event.Type, event)
+			}
+			return true
+		}
+	})
+}
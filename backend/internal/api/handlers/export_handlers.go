@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// ExportDiagram renders a diagram in the requested format.
+// ?layers=a,b restricts the export to the given layers. PDF exports accept
+// ?paper=a4|a3|letter|legal, ?orientation=landscape, and ?tile=true with
+// ?scale= and ?overlap= (points) to split large diagrams across pages.
+// HTML exports accept ?tree=true to bundle the diagram and its descendants
+// as a zip of linked pages.
+func ExportDiagram(c *gin.Context) {
+	id := c.Param("id")
+	format := c.Param("format")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+	// HTML bundles are made for handing diagrams out
+	if format == services.ExportFormatHTML && !requireFeature(c, models.FeaturePublicSharing) {
+		return
+	}
+
+	pdfOptions, err := pdfOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid PDF options",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	exportService := services.NewExportService()
+
+	var export *services.Export
+	if format == services.ExportFormatHTML && c.Query("tree") == "true" {
+		export, err = exportService.ExportHTMLTree(id, c.Query("lang"))
+	} else {
+		export, err = exportService.Export(id, format, services.ExportOptions{
+			Layers: queryList(c, "layers"),
+			PDF:    pdfOptions,
+			Lang:   c.Query("lang"),
+		})
+	}
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrUnsupportedFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Unsupported export format",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to export diagram",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", "attachment; filename=\""+id+"."+export.Extension+"\"")
+	}
+	c.Data(http.StatusOK, export.ContentType, export.Data)
+}
+
+// ExportSubsetRequest selects the nodes of a subset export
+type ExportSubsetRequest struct {
+	NodeIDs []string `json:"nodeIds" binding:"required"`
+	Format  string   `json:"format"` // Defaults to json
+	Layers  []string `json:"layers"`
+	Lang    string   `json:"lang"`
+}
+
+// ExportSubset renders just the selected nodes of a diagram as a standalone
+// diagram, for focused discussion snippets. Edges between the selection and
+// the rest of the diagram are kept, leading to dashed external stubs and
+// marked with "boundary" metadata. Accepts ?download=true.
+func ExportSubset(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	var req ExportSubsetRequest
+	if !bindJSON(c, &req, "Invalid subset export request") {
+		return
+	}
+	if req.Format == "" {
+		req.Format = services.ExportFormatJSON
+	}
+	if req.Format == services.ExportFormatHTML && !requireFeature(c, models.FeaturePublicSharing) {
+		return
+	}
+
+	export, err := services.NewExportService().ExportSubset(id, req.NodeIDs, req.Format, services.ExportOptions{
+		Layers: req.Layers,
+		Lang:   req.Lang,
+	})
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrUnsupportedFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Unsupported export format",
+				"details": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid selection",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to export diagram",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", "attachment; filename=\""+id+"-subset."+export.Extension+"\"")
+	}
+	c.Data(http.StatusOK, export.ContentType, export.Data)
+}
+
+// ExportHierarchyPDF renders a diagram and all its descendants as a single
+// PDF with a cover page, table of contents and page cross-references.
+// Accepts ?paper=, ?orientation=landscape, ?lang= and ?download=true.
+func ExportHierarchyPDF(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	pdfOptions, err := pdfOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid PDF options",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	exportService := services.NewExportService()
+
+	data, err := exportService.HierarchyBook(id, c.Query("lang"), pdfOptions)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to export hierarchy",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", "attachment; filename=\""+id+"-hierarchy.pdf\"")
+	}
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// queryList reads a list query parameter given either as repeated values or
+// as a single comma-separated value
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(key) {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// pdfOptionsFromQuery reads PDF page setup from the query string
+func pdfOptionsFromQuery(c *gin.Context) (services.PDFOptions, error) {
+	opts := services.PDFOptions{
+		Paper:     c.Query("paper"),
+		Landscape: c.Query("orientation") == "landscape",
+		Tile:      c.Query("tile") == "true",
+	}
+	if raw := c.Query("scale"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(v > 0 && v <= services.MaxPDFScale) {
+			return opts, fmt.Errorf("scale must be a positive number up to %g", services.MaxPDFScale)
+		}
+		opts.Scale = v
+	}
+	if raw := c.Query("overlap"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(v >= 0) {
+			return opts, fmt.Errorf("overlap must be a non-negative number")
+		}
+		opts.Overlap = v
+	}
+	return opts, nil
+}
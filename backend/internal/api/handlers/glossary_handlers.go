@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// ListGlossaryTerms returns all glossary terms
+func ListGlossaryTerms(c *gin.Context) {
+	glossaryService := services.NewGlossaryService()
+
+	terms, err := glossaryService.List()
+	if err != nil {
+		respondGlossaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"terms": terms,
+		"count": len(terms),
+	})
+}
+
+// GetGlossaryTerm returns one glossary term
+func GetGlossaryTerm(c *gin.Context) {
+	glossaryService := services.NewGlossaryService()
+
+	term, err := glossaryService.Get(c.Param("id"))
+	if err != nil {
+		respondGlossaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, term)
+}
+
+// CreateGlossaryTerm adds a glossary term
+func CreateGlossaryTerm(c *gin.Context) {
+	var term models.GlossaryTerm
+	if !bindJSON(c, &term, "Invalid glossary term") {
+		return
+	}
+
+	glossaryService := services.NewGlossaryService()
+
+	created, err := glossaryService.Create(term)
+	if err != nil {
+		respondGlossaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateGlossaryTerm replaces a glossary term
+func UpdateGlossaryTerm(c *gin.Context) {
+	var term models.GlossaryTerm
+	if !bindJSON(c, &term, "Invalid glossary term") {
+		return
+	}
+
+	glossaryService := services.NewGlossaryService()
+
+	updated, err := glossaryService.Update(c.Param("id"), term)
+	if err != nil {
+		respondGlossaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteGlossaryTerm removes a glossary term
+func DeleteGlossaryTerm(c *gin.Context) {
+	glossaryService := services.NewGlossaryService()
+
+	if err := glossaryService.Delete(c.Param("id")); err != nil {
+		respondGlossaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Glossary term deleted successfully",
+	})
+}
+
+// GetDiagramGlossary returns the glossary terms found in the descriptions
+// of a diagram and its nodes, as annotations with UTF-16 offsets for
+// rendering hover definitions. ?lang= annotates a translation.
+func GetDiagramGlossary(c *gin.Context) {
+	id := c.Param("id")
+	glossaryService := services.NewGlossaryService()
+
+	annotations, terms, err := glossaryService.Annotate(id, c.Query("lang"))
+	if err != nil {
+		respondGlossaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diagramId":   id,
+		"annotations": annotations,
+		"terms":       terms,
+	})
+}
+
+// respondGlossaryError maps glossary errors to responses
+func respondGlossaryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTermNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Glossary term not found",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrDiagramNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Diagram not found",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrTermExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Glossary term already exists",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid glossary term",
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to access glossary",
+			"details": err.Error(),
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
 	"github.com/michaellanpart/flowgen/backend/internal/services"
 )
 
@@ -68,6 +73,35 @@ func GetParentDiagram(c *gin.Context) {
 	})
 }
 
+// GetParentDiagrams returns every parent of a diagram, the primary parent
+// first
+func GetParentDiagrams(c *gin.Context) {
+	id := c.Param("id")
+
+	hierarchyService := services.NewHierarchyService()
+
+	parents, err := hierarchyService.GetParents(id)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get parent diagrams",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"child":   id,
+		"parents": parents,
+		"count":   len(parents),
+	})
+}
+
 // LinkDiagrams creates a hierarchical relationship between diagrams
 func LinkDiagrams(c *gin.Context) {
 	parentID := c.Param("id")
@@ -83,9 +117,130 @@ func LinkDiagrams(c *gin.Context) {
 		NodeID  string `json:"nodeId"` // Optional: specific node for drill-down
 	}
 
-	if err := c.ShouldBindJSON(&linkRequest); err != nil {
+	if !bindJSON(c, &linkRequest, "Invalid link request") {
+		return
+	}
+
+	diagramService := diagramServiceFor(c)
+	hierarchyService := services.NewHierarchyService().Using(diagramService)
+
+	err := hierarchyService.LinkDiagrams(parentID, linkRequest.ChildID, linkRequest.NodeID)
+	var cycle *services.HierarchyCycleError
+	if errors.As(err, &cycle) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Link would create a hierarchy cycle",
+			"details": err.Error(),
+			"cycle":   cycle.Path,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to link diagrams",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	link := gin.H{
+		"parent": parentID,
+		"child":  linkRequest.ChildID,
+		"node":   linkRequest.NodeID,
+	}
+	if respondDryRun(c, diagramService, link) {
+		return
+	}
+	link["message"] = "Diagrams linked successfully"
+	c.JSON(http.StatusOK, link)
+}
+
+// ReparentDiagram moves a diagram and its subtree below another parent
+func ReparentDiagram(c *gin.Context) {
+	var request struct {
+		ParentID     string `json:"parentId" binding:"required"`
+		FromParentID string `json:"fromParentId"` // Optional: move a shared sub-flow away from this parent only
+		NodeID       string `json:"nodeId"`       // Optional: node of the new parent drilling down into the diagram
+	}
+	if !bindJSON(c, &request, "Invalid reparent request") {
+		return
+	}
+
+	diagramService := diagramServiceFor(c)
+	hierarchyService := services.NewHierarchyService().Using(diagramService)
+
+	result, err := hierarchyService.Reparent(c.Param("id"), request.ParentID, request.FromParentID, request.NodeID)
+	if err != nil {
+		var cycle *services.HierarchyCycleError
+		switch {
+		case errors.As(err, &cycle):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Move would create a hierarchy cycle",
+				"details": err.Error(),
+				"cycle":   cycle.Path,
+			})
+		case err == services.ErrDiagramNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+		case errors.Is(err, services.ErrInvalidOptions):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid reparent request",
+				"details": err.Error(),
+			})
+		case respondSaveRejected(c, err):
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to move diagram",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	if respondDryRun(c, diagramService, result) {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSystemMap returns a generated high-level map of a diagram's children
+func GetSystemMap(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	hierarchyService := services.NewHierarchyService()
+
+	systemMap, err := hierarchyService.GenerateSystemMap(id)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to generate system map",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, systemMap)
+}
+
+// GetHierarchyTree returns the hierarchy below a diagram, expanded
+// ?expand=level1 (the default) to levelN levels or all of them, with one
+// page of children per expanded diagram (?childrenPage=, ?pageSize=)
+func GetHierarchyTree(c *gin.Context) {
+	opts, err := treeOptionsFromQuery(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid link request",
+			"error":   "Invalid tree options",
 			"details": err.Error(),
 		})
 		return
@@ -93,19 +248,159 @@ func LinkDiagrams(c *gin.Context) {
 
 	hierarchyService := services.NewHierarchyService()
 
-	err := hierarchyService.LinkDiagrams(parentID, linkRequest.ChildID, linkRequest.NodeID)
+	tree, err := hierarchyService.GetHierarchyTreePage(c.Param("id"), opts)
 	if err != nil {
+		switch {
+		case err == services.ErrDiagramNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+		case errors.Is(err, services.ErrInvalidOptions):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid tree options",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to get hierarchy tree",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// treeOptionsFromQuery reads the expansion depth and children page from the
+// query string
+func treeOptionsFromQuery(c *gin.Context) (services.HierarchyTreeOptions, error) {
+	opts := services.HierarchyTreeOptions{Depth: 1}
+	switch expand := c.Query("expand"); {
+	case expand == "all":
+		opts.Depth = 0
+	case strings.HasPrefix(expand, "level"):
+		v, err := strconv.Atoi(strings.TrimPrefix(expand, "level"))
+		if err != nil || v < 1 {
+			return opts, fmt.Errorf("expand must be all or levelN with N at least 1")
+		}
+		opts.Depth = v
+	case expand != "":
+		return opts, fmt.Errorf("expand must be all or levelN with N at least 1")
+	}
+	if raw := c.Query("childrenPage"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			return opts, fmt.Errorf("childrenPage must be a positive integer")
+		}
+		opts.Page = v
+	}
+	if raw := c.Query("pageSize"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > services.MaxTreePageSize {
+			return opts, fmt.Errorf("pageSize must be between 1 and %d", services.MaxTreePageSize)
+		}
+		opts.PageSize = v
+	}
+	return opts, nil
+}
+
+// GetDiagramRelations returns the relations a diagram declares and those
+// pointing at it. ?type= restricts both to one relation type.
+func GetDiagramRelations(c *gin.Context) {
+	id := c.Param("id")
+
+	hierarchyService := services.NewHierarchyService()
+
+	relations, err := hierarchyService.GetRelations(id, models.RelationType(c.Query("type")))
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to link diagrams",
+			"error":   "Failed to get relations",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Diagrams linked successfully",
-		"parent":  parentID,
-		"child":   linkRequest.ChildID,
-		"node":    linkRequest.NodeID,
-	})
+	c.JSON(http.StatusOK, relations)
+}
+
+// AddDiagramRelation adds a typed relation to another diagram, or updates
+// the description of an existing one
+func AddDiagramRelation(c *gin.Context) {
+	id := c.Param("id")
+
+	var relation models.Relation
+	if !bindJSON(c, &relation, "Invalid relation") {
+		return
+	}
+
+	diagramService := diagramServiceFor(c)
+	hierarchyService := services.NewHierarchyService().Using(diagramService)
+
+	diagram, err := hierarchyService.AddRelation(id, relation)
+	if err != nil {
+		respondRelationError(c, err)
+		return
+	}
+
+	relations := gin.H{
+		"id":        diagram.ID,
+		"relations": diagram.Relations,
+	}
+	if respondDryRun(c, diagramService, relations) {
+		return
+	}
+	c.JSON(http.StatusOK, relations)
+}
+
+// RemoveDiagramRelation removes a relation identified by type and target
+func RemoveDiagramRelation(c *gin.Context) {
+	id := c.Param("id")
+
+	diagramService := diagramServiceFor(c)
+	hierarchyService := services.NewHierarchyService().Using(diagramService)
+
+	diagram, err := hierarchyService.RemoveRelation(id, models.RelationType(c.Param("type")), c.Param("target"))
+	if err != nil {
+		respondRelationError(c, err)
+		return
+	}
+
+	relations := gin.H{
+		"id":        diagram.ID,
+		"relations": diagram.Relations,
+	}
+	if respondDryRun(c, diagramService, relations) {
+		return
+	}
+	c.JSON(http.StatusOK, relations)
+}
+
+func respondRelationError(c *gin.Context, err error) {
+	switch {
+	case err == services.ErrDiagramNotFound:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Diagram not found",
+		})
+	case errors.Is(err, services.ErrRelationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Relation not found",
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid relation",
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update relations",
+			"details": err.Error(),
+		})
+	}
 }
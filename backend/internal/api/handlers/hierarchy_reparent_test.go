@@ -0,0 +1,62 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/michaellanpart/flowgen/backend/flowgentest"
+)
+
+// hierarchyDiagram is a one-node diagram placed in the hierarchy
+func hierarchyDiagram(id string, fields map[string]interface{}) map[string]interface{} {
+	diagram := map[string]interface{}{
+		"id":      id,
+		"name":    id,
+		"version": "1.0.0",
+		"nodes": []map[string]interface{}{
+			{"id": "start", "name": "Start", "type": "start", "position": map[string]int{"x": 0, "y": 0}},
+		},
+		"edges": []map[string]interface{}{},
+	}
+	for key, value := range fields {
+		diagram[key] = value
+	}
+	return diagram
+}
+
+func TestReparentRestoresWrittenDiagramsWhenAWriteFails(t *testing.T) {
+	// Once frozen, the validation webhook vetoes saves of the new parent,
+	// which is written after the moved diagram
+	var frozen atomic.Bool
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Diagram struct {
+				ID string `json:"id"`
+			} `json:"diagram"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if frozen.Load() && payload.Diagram.ID == "billing" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"errors": [{"message": "billing is frozen"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	srv := flowgentest.New(t, flowgentest.WithEnv("VALIDATION_WEBHOOK_URL", hook.URL))
+	srv.Seed(hierarchyDiagram("orders", map[string]interface{}{"children": []string{"invoice"}}))
+	srv.Seed(hierarchyDiagram("invoice", map[string]interface{}{"parent": "orders"}))
+	srv.Seed(hierarchyDiagram("billing", nil))
+	frozen.Store(true)
+
+	resp, body := srv.Do(http.MethodPost, "/api/v1/hierarchy/invoice/reparent", map[string]string{"parentId": "billing"})
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("reparent below a vetoed parent: %s: %s", resp.Status, body)
+	}
+
+	srv.AssertStored("invoice", "parent", "orders")
+	srv.AssertStored("orders", "children[0]", "invoice")
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// maxHookBodySize bounds the body of webhook calls
+const maxHookBodySize = 1 << 20
+
+// RegenerateFromSources re-imports the configured external sources into
+// their diagrams. ?diagram=a,b limits the run to some diagrams. The call
+// must carry the hooks secret, see RegenerateService.Authorize.
+func RegenerateFromSources(c *gin.Context) {
+	// Read one byte past the limit so that a cut body is refused rather
+	// than failing its signature check
+	body, _ := io.ReadAll(io.LimitReader(c.Request.Body, maxHookBodySize+1))
+	if len(body) > maxHookBodySize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Webhook body too large",
+			"details": fmt.Sprintf("the body must be at most %d bytes", maxHookBodySize),
+		})
+		return
+	}
+
+	regenerateService := services.NewRegenerateService()
+
+	token := c.GetHeader("X-FlowGen-Secret")
+	if token == "" {
+		token = c.GetHeader("X-Gitlab-Token")
+	}
+	if err := regenerateService.Authorize(body, token, c.GetHeader("X-Hub-Signature-256")); err != nil {
+		if errors.Is(err, services.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Webhooks not configured",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid webhook secret",
+		})
+		return
+	}
+
+	var only []string
+	if value := c.Query("diagram"); value != "" {
+		only = strings.Split(value, ",")
+	}
+
+	results, err := regenerateService.Regenerate(only)
+	if err != nil {
+		if errors.Is(err, services.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Import sources not configured",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to regenerate diagrams",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// maxImportSize bounds the documents accepted by importers
+const maxImportSize = 32 << 20
+
+// ImportTerraform generates a diagram from Terraform plan or state JSON
+func ImportTerraform(c *gin.Context) {
+	runImport(c, "Terraform", (*services.DiagramService).ImportTerraform)
+}
+
+// ImportOpenAPI generates a diagram from an OpenAPI or Swagger document
+func ImportOpenAPI(c *gin.Context) {
+	runImport(c, "OpenAPI", (*services.DiagramService).ImportOpenAPI)
+}
+
+// ImportGitHubActions generates a diagram from a GitHub Actions workflow
+func ImportGitHubActions(c *gin.Context) {
+	runImport(c, "workflow", (*services.DiagramService).ImportGitHubActions)
+}
+
+// ImportBPMN generates a diagram from BPMN 2.0 XML
+func ImportBPMN(c *gin.Context) {
+	runImport(c, "BPMN", (*services.DiagramService).ImportBPMN)
+}
+
+// runImport reads the raw request body, runs the importer and optionally
+// saves the result. Query parameters: id, name, mode and save=true.
+func runImport(c *gin.Context, source string, run services.Importer) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportSize))
+	if err != nil || len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request body must contain the " + source + " document",
+		})
+		return
+	}
+
+	opts := services.ImportOptions{
+		ID:   c.Query("id"),
+		Name: c.Query("name"),
+		Mode: c.Query("mode"),
+	}
+
+	diagramService := diagramServiceFor(c)
+
+	result, err := run(diagramService, data, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to import " + source + " document",
+			"details": err.Error(),
+		})
+		return
+	}
+	services.RecordImportSource(&result.Diagram, "", data)
+
+	if c.Query("save") == "true" {
+		created, err := diagramService.Create(&result.Diagram)
+		if errors.Is(err, services.ErrDiagramExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":    "Diagram already exists",
+				"details":  err.Error(),
+				"warnings": result.Warnings,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":    "Failed to save imported diagram",
+				"details":  err.Error(),
+				"warnings": result.Warnings,
+			})
+			return
+		}
+		result.Diagram = *created
+		if respondDryRun(c, diagramService, result) {
+			return
+		}
+		c.JSON(http.StatusCreated, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -1,13 +1,22 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
 )
 
 // GetJiraProjects returns available Jira projects
 func GetJiraProjects(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
 	// TODO: Implement Jira integration
 	c.JSON(http.StatusNotImplemented, gin.H{
 		"error":   "Jira integration not yet implemented",
@@ -17,6 +26,10 @@ func GetJiraProjects(c *gin.Context) {
 
 // GetJiraIssue returns a specific Jira issue
 func GetJiraIssue(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
 	issueKey := c.Param("key")
 	if issueKey == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -32,8 +45,47 @@ func GetJiraIssue(c *gin.Context) {
 	})
 }
 
+// SearchJiraIssues proxies a JQL search (?jql=) to Jira, so that browsers
+// need no Jira credentials. ?startAt= and ?maxResults= (default 20, at
+// most 100) page through the results, trimmed to the fields an issue
+// picker shows.
+func SearchJiraIssues(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
+	startAt, err := strconv.Atoi(c.DefaultQuery("startAt", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid startAt: " + c.Query("startAt"),
+		})
+		return
+	}
+	maxResults, err := strconv.Atoi(c.DefaultQuery("maxResults", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid maxResults: " + c.Query("maxResults"),
+		})
+		return
+	}
+
+	jiraService := services.NewJiraService()
+
+	result, err := jiraService.Search(c.Query("jql"), startAt, maxResults)
+	if err != nil {
+		respondJiraError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // CreateJiraIssue creates a new Jira issue
 func CreateJiraIssue(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
 	var issueRequest struct {
 		Summary     string `json:"summary" binding:"required"`
 		Description string `json:"description"`
@@ -42,18 +94,301 @@ func CreateJiraIssue(c *gin.Context) {
 		Priority    string `json:"priority"`
 	}
 
-	if err := c.ShouldBindJSON(&issueRequest); err != nil {
+	if !bindJSON(c, &issueRequest, "Invalid issue request") {
+		return
+	}
+
+	jiraService := services.NewJiraService()
+
+	issue, err := jiraService.CreateIssue(services.JiraIssueRequest{
+		Project:     issueRequest.Project,
+		IssueType:   issueRequest.IssueType,
+		Summary:     issueRequest.Summary,
+		Description: issueRequest.Description,
+		Priority:    issueRequest.Priority,
+	})
+	if err != nil {
+		respondJiraError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, issue)
+}
+
+// CreateNodeJiraIssue creates a Jira issue for a node, pre-filled from the
+// node, and saves its key in the node's Jira integration. The optional
+// body overrides project, issueType, summary, description and priority.
+// ?replace=true creates a new issue for a node already linked to one.
+func CreateNodeJiraIssue(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
+	var issueRequest services.JiraIssueRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &issueRequest, "Invalid issue request") {
+		return
+	}
+
+	jiraService := services.NewJiraService().Using(services.NewDiagramService().ActingFor(c.Request.Header))
+
+	result, err := jiraService.CreateNodeIssue(c.Param("id"), c.Param("nodeId"), issueRequest, c.Query("replace") == "true")
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrJiraIssueLinked) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Node already linked to a Jira issue",
+				"details": err.Error(),
+			})
+			return
+		}
+		if respondSaveRejected(c, err) {
+			return
+		}
+		respondJiraError(c, err)
+		return
+	}
+
+	c.Header("ETag", strconv.Quote(result.ContentHash))
+	c.JSON(http.StatusCreated, result)
+}
+
+// GenerateJiraDiagram generates a dependency diagram from the issues of a
+// Jira epic (?epic=) and their blocking links, and optionally saves it.
+// Query parameters: epic, id, name and save=true.
+func GenerateJiraDiagram(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
+	opts := services.ImportOptions{
+		ID:   c.Query("id"),
+		Name: c.Query("name"),
+	}
+
+	diagramService := diagramServiceFor(c)
+	jiraService := services.NewJiraService().Using(diagramService)
+
+	result, err := jiraService.GenerateEpicDiagram(c.Query("epic"), opts)
+	if err != nil {
+		respondJiraError(c, err)
+		return
+	}
+
+	if c.Query("save") == "true" {
+		created, err := diagramService.Create(&result.Diagram)
+		if errors.Is(err, services.ErrDiagramExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":    "Diagram already exists",
+				"details":  err.Error(),
+				"warnings": result.Warnings,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":    "Failed to save generated diagram",
+				"details":  err.Error(),
+				"warnings": result.Warnings,
+			})
+			return
+		}
+		result.Diagram = *created
+		if respondDryRun(c, diagramService, result) {
+			return
+		}
+		c.JSON(http.StatusCreated, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// AuthorizeJira starts connecting Jira Cloud with Atlassian OAuth 2.0
+// (3LO) by redirecting to Atlassian's consent page. ?return= is a path of
+// this server the callback redirects to once Jira is connected.
+func AuthorizeJira(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
+	jiraService := services.NewJiraService()
+
+	authorizeURL, err := jiraService.AuthorizeURL(c.Query("return"), c.Request.Header)
+	if err != nil {
+		respondJiraError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, authorizeURL)
+}
+
+// JiraOAuthCallback completes connecting Jira when Atlassian redirects back
+// with an authorization code. It redirects to the path given when the
+// connection was started, or returns the connection.
+func JiraOAuthCallback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid issue request",
+			"error":   "Jira access was not granted",
+			"details": reason + ": " + c.Query("error_description"),
+		})
+		return
+	}
+
+	jiraService := services.NewJiraService()
+
+	connection, returnTo, err := jiraService.CompleteOAuth(c.Query("code"), c.Query("state"))
+	if err != nil {
+		respondJiraError(c, err)
+		return
+	}
+
+	if returnTo != "" {
+		c.Redirect(http.StatusFound, returnTo)
+		return
+	}
+	c.JSON(http.StatusOK, connection)
+}
+
+// GetJiraConnection returns how the server reaches Jira: with OAuth or an
+// API token, and whether it is connected
+func GetJiraConnection(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
+	jiraService := services.NewJiraService()
+
+	connection, err := jiraService.Connection()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read Jira connection",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	// TODO: Implement Jira integration
-	c.JSON(http.StatusNotImplemented, gin.H{
-		"error":   "Jira integration not yet implemented",
-		"message": "This endpoint will create a Jira issue",
-		"request": issueRequest,
+	c.JSON(http.StatusOK, connection)
+}
+
+// DisconnectJira forgets the stored OAuth tokens
+func DisconnectJira(c *gin.Context) {
+	if !requireFeature(c, models.FeatureJiraSync) {
+		return
+	}
+
+	jiraService := services.NewJiraService()
+
+	if err := jiraService.Disconnect(); err != nil {
+		respondJiraError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondJiraError maps Jira integration errors to responses
+func respondJiraError(c *gin.Context, err error) {
+	var jiraErr *services.JiraError
+	switch {
+	case errors.Is(err, services.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Jira integration not configured",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Jira request",
+			"details": err.Error(),
+		})
+	case errors.As(err, &jiraErr):
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Jira rejected the request",
+			"details": jiraErr.Messages,
+			"status":  jiraErr.Status,
+		})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to reach Jira",
+			"details": err.Error(),
+		})
+	}
+}
+
+// GetPrometheusOverlay evaluates the PromQL queries declared in node
+// metadata (`promql`) and returns the values keyed by node ID. ?time=
+// (RFC 3339) evaluates them at a past instant.
+func GetPrometheusOverlay(c *gin.Context) {
+	id := c.Param("id")
+
+	var at *time.Time
+	if value := c.Query("time"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid time",
+				"details": err.Error(),
+			})
+			return
+		}
+		at = &parsed
+	}
+
+	prometheusService := services.NewPrometheusService()
+
+	overlays, err := prometheusService.Overlay(id, at)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Prometheus integration not configured",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to evaluate Prometheus overlay",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diagramId": id,
+		"nodes":     overlays,
 	})
 }
+
+// GetHealthOverlay returns the live up/degraded/down status of every node
+// that references a health endpoint or Kubernetes deployment
+func GetHealthOverlay(c *gin.Context) {
+	id := c.Param("id")
+
+	healthService := services.NewHealthService()
+
+	overlay, err := healthService.Overlay(id)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to check node health",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, overlay)
+}
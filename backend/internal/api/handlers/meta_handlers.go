@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetMetaSchema returns the workspace schema for diagram meta sections so
+// editors can render the governed fields. Fields are empty when no schema
+// is configured.
+func GetMetaSchema(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	schema, err := diagramService.MetaSchema()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load meta schema",
+			"details": err.Error(),
+		})
+		return
+	}
+	if schema == nil {
+		schema = &models.MetaSchema{Fields: map[string]models.MetaField{}}
+	}
+	if schema.Policy == "" && len(schema.Fields) > 0 {
+		schema.Policy = models.MetaPolicyReject
+	}
+
+	c.JSON(http.StatusOK, schema)
+}
+
+// setValidationWarnings reports validation warnings of a saved diagram,
+// such as meta fields violating a warn-only schema, as Warning headers
+func setValidationWarnings(c *gin.Context, diagramService *services.DiagramService, diagram *models.FlowDiagram) {
+	result, err := diagramService.Validate(diagram)
+	if err != nil {
+		return
+	}
+	for _, warning := range result.Warnings {
+		c.Writer.Header().Add("Warning", `199 flowgen "`+warning.Path+": "+warning.Message+`"`)
+	}
+}
+
+// respondSaveRejected answers 400 when the diagram does not validate, 403
+// when it is in a read-only root, 409 when it changed since the client read
+// it or a new diagram's ID is stored in another file, 422 when a pre-save hook
+// or the validation webhook (with their validation errors) or a quota
+// rejected it, and 503 when the validation webhook failed closed, and
+// reports whether it did
+func respondSaveRejected(c *gin.Context, err error) bool {
+	var veto *services.SaveVetoError
+	var conflict *services.EditConflictError
+	switch {
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Diagram changed since it was read",
+			"details":   err.Error(),
+			"current":   conflict.Current,
+			"submitted": conflict.Submitted,
+			"diff":      conflict.Diff,
+		})
+	case errors.Is(err, services.ErrInvalidDiagram):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid diagram",
+			"details": err.Error(),
+		})
+	case errors.As(err, &veto):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Save rejected by hook " + veto.Hook,
+			"details": veto.Errors,
+		})
+	case errors.Is(err, services.ErrDiagramExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Diagram already exists",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Diagram is read-only",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrFeatureDisabled):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Feature disabled",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrQuotaExceeded):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Quota exceeded",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrValidationUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "External validation unavailable",
+			"details": err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+)
+
+// msgpackContentType is sent with MessagePack responses
+const msgpackContentType = "application/msgpack"
+
+// mapStringType makes decoded MessagePack maps encodable as JSON objects
+var mapStringType = reflect.TypeOf(map[string]interface{}(nil))
+
+// NegotiateMsgPack lets clients exchange MessagePack instead of JSON, which
+// is smaller and faster to parse for very large diagrams. Request bodies
+// sent as application/msgpack (or application/x-msgpack) reach handlers as
+// JSON, and JSON responses are re-encoded when the Accept header prefers
+// MessagePack. Other responses, such as exports and event streams, pass
+// through unchanged.
+func NegotiateMsgPack(c *gin.Context) {
+	if isMsgPack(c.ContentType()) && c.Request.Body != nil {
+		body, err := msgpackToJSON(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid MessagePack body",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+	}
+
+	// The same URL answers in either encoding
+	c.Writer.Header().Add("Vary", "Accept")
+	if !prefersMsgPack(c.GetHeader("Accept")) {
+		c.Next()
+		return
+	}
+	writer := &msgpackWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	writer.finish()
+}
+
+// isMsgPack reports whether a media type is MessagePack
+func isMsgPack(mediaType string) bool {
+	return mediaType == "application/msgpack" || mediaType == "application/x-msgpack"
+}
+
+// prefersMsgPack reports whether an Accept header ranks MessagePack above
+// JSON; on equal quality the type listed first wins
+func prefersMsgPack(accept string) bool {
+	best, bestQuality := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if (isMsgPack(mediaType) || mediaType == "application/json") && quality > bestQuality {
+			best, bestQuality = mediaType, quality
+		}
+	}
+	return isMsgPack(best)
+}
+
+// msgpackWriter holds back JSON responses so they can be re-encoded once
+// the handler is done; anything else is written straight through
+type msgpackWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	decided   bool
+	buffering bool
+}
+
+func (w *msgpackWriter) decide() {
+	if !w.decided {
+		w.decided = true
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		w.buffering = mediaType == "application/json"
+	}
+}
+
+func (w *msgpackWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *msgpackWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// finish writes a held-back JSON response as MessagePack, or as it was
+// should it not convert
+func (w *msgpackWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	w.Header().Del("Content-Length")
+	data, err := jsonToMsgPack(w.buf.Bytes())
+	if err != nil {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", msgpackContentType)
+	w.ResponseWriter.Write(data)
+}
+
+// jsonToMsgPack re-encodes a JSON document. Integers stay integers, so the
+// smallest MessagePack integer type is used for them.
+func jsonToMsgPack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var out []byte
+	handle := codec.MsgpackHandle{WriteExt: true}
+	if err := codec.NewEncoderBytes(&out, &handle).Encode(msgpackValue(value)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// msgpackValue replaces the JSON numbers in a decoded document by int64 or
+// float64 values
+func msgpackValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = msgpackValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = msgpackValue(item)
+		}
+	}
+	return value
+}
+
+// msgpackToJSON re-encodes a MessagePack request body as JSON
+func msgpackToJSON(body io.Reader) ([]byte, error) {
+	var handle codec.MsgpackHandle
+	handle.RawToString = true
+	handle.MapType = mapStringType
+	var value interface{}
+	if err := codec.NewDecoder(body, &handle).Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
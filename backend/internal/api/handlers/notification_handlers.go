@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetNotificationPreferences returns which notifications a person receives
+// and the diagrams they are subscribed to
+func GetNotificationPreferences(c *gin.Context) {
+	preferencesService := services.NewPreferencesService()
+
+	preferences, err := preferencesService.Get(c.Param("person"))
+	if err != nil {
+		respondNotificationError(c, err, "Failed to load preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences.Notifications)
+}
+
+// UpdateNotificationPreferences replaces a person's notification
+// preferences
+func UpdateNotificationPreferences(c *gin.Context) {
+	var preferences models.NotificationPreferences
+	if !bindJSON(c, &preferences, "Invalid preferences") {
+		return
+	}
+
+	notificationService := services.NewNotificationService()
+
+	saved, err := notificationService.SaveNotificationPreferences(c.Param("person"), preferences)
+	if err != nil {
+		respondNotificationError(c, err, "Failed to save preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, saved.Notifications)
+}
+
+// GetDiagramSubscribers lists the people notified of changes to a diagram
+func GetDiagramSubscribers(c *gin.Context) {
+	notificationService := services.NewNotificationService()
+
+	subscribers, err := notificationService.Subscribers(c.Param("id"))
+	if err != nil {
+		respondNotificationError(c, err, "Failed to list subscribers")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diagramId":   c.Param("id"),
+		"subscribers": subscribers,
+		"count":       len(subscribers),
+	})
+}
+
+// SubscribeToDiagram subscribes a person to changes of a diagram
+func SubscribeToDiagram(c *gin.Context) {
+	var request struct {
+		Person string `json:"person" binding:"required"`
+	}
+	if !bindJSON(c, &request, "Invalid subscription") {
+		return
+	}
+
+	notificationService := services.NewNotificationService()
+
+	if err := notificationService.Subscribe(c.Param("id"), request.Person); err != nil {
+		respondNotificationError(c, err, "Failed to subscribe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Subscribed",
+		"diagramId": c.Param("id"),
+		"person":    request.Person,
+	})
+}
+
+// UnsubscribeFromDiagram stops change notifications of a diagram for a
+// person
+func UnsubscribeFromDiagram(c *gin.Context) {
+	notificationService := services.NewNotificationService()
+
+	if err := notificationService.Unsubscribe(c.Param("id"), c.Param("person")); err != nil {
+		respondNotificationError(c, err, "Failed to unsubscribe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Unsubscribed",
+		"diagramId": c.Param("id"),
+		"person":    c.Param("person"),
+	})
+}
+
+// RequestDiagramReview mails reviewers, people or whole teams, asking them
+// to review a diagram
+func RequestDiagramReview(c *gin.Context) {
+	var request struct {
+		Reviewers   []string `json:"reviewers" binding:"required"`
+		RequestedBy string   `json:"requestedBy"`
+		Message     string   `json:"message"`
+	}
+	if !bindJSON(c, &request, "Invalid review request") {
+		return
+	}
+
+	notificationService := services.NewNotificationService()
+
+	notified, err := notificationService.RequestReview(c.Param("id"), services.ReviewRequest{
+		Reviewers:   request.Reviewers,
+		RequestedBy: request.RequestedBy,
+		Message:     request.Message,
+	})
+	if err != nil {
+		respondNotificationError(c, err, "Failed to request review")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diagramId": c.Param("id"),
+		"notified":  notified,
+	})
+}
+
+// SendStaleReminders mails stale-diagram reminders to owners now instead
+// of waiting for the schedule
+func SendStaleReminders(c *gin.Context) {
+	notificationService := services.NewNotificationService()
+
+	notified, err := notificationService.SendStaleReminders()
+	if err != nil {
+		respondNotificationError(c, err, "Failed to send reminders")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notified": notified,
+		"count":    len(notified),
+	})
+}
+
+func respondNotificationError(c *gin.Context, err error, message string) {
+	switch {
+	case err == services.ErrDiagramNotFound:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Diagram not found",
+			"details": c.Param("id"),
+		})
+	case errors.Is(err, services.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Notifications are not configured",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetMyPreferences returns the preferences of the signed-in person
+func GetMyPreferences(c *gin.Context) {
+	preferencesService := services.NewPreferencesService()
+
+	person, err := preferencesService.Identify(c.Request.Header)
+	if err != nil {
+		respondPreferencesError(c, err, "Failed to identify user")
+		return
+	}
+
+	preferences, err := preferencesService.Get(person)
+	if err != nil {
+		respondPreferencesError(c, err, "Failed to load preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdateMyPreferences replaces the preferences of the signed-in person.
+// Omitted settings revert to their defaults.
+func UpdateMyPreferences(c *gin.Context) {
+	preferencesService := services.NewPreferencesService()
+
+	person, err := preferencesService.Identify(c.Request.Header)
+	if err != nil {
+		respondPreferencesError(c, err, "Failed to identify user")
+		return
+	}
+
+	// Subscriptions left out of the body are kept
+	preferences := models.DefaultUserPreferences()
+	preferences.Notifications.Subscriptions = nil
+	if !bindJSON(c, &preferences, "Invalid preferences") {
+		return
+	}
+
+	saved, err := preferencesService.Save(person, preferences)
+	if err != nil {
+		respondPreferencesError(c, err, "Failed to save preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+func respondPreferencesError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUnauthorized):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "User preferences are not configured",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// CreateRelease captures a labeled snapshot of all diagrams, or of the
+// diagrams listed in the request
+func CreateRelease(c *gin.Context) {
+	var request struct {
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description"`
+		Diagrams    []string `json:"diagrams"`
+	}
+	if !bindJSON(c, &request, "Invalid release request") {
+		return
+	}
+
+	releaseService := services.NewReleaseService()
+
+	release, err := releaseService.Create(services.ReleaseOptions{
+		Name:        request.Name,
+		Description: request.Description,
+		Diagrams:    request.Diagrams,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReleaseExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Release already exists",
+				"details": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidOptions):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid release request",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create release",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, release)
+}
+
+// ListReleases returns all releases, newest first
+func ListReleases(c *gin.Context) {
+	releaseService := services.NewReleaseService()
+
+	releases, err := releaseService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list releases",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"releases": releases,
+		"count":    len(releases),
+	})
+}
+
+// GetRelease returns a release manifest listing the captured diagrams
+func GetRelease(c *gin.Context) {
+	releaseService := services.NewReleaseService()
+
+	release, err := releaseService.Get(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, services.ErrReleaseNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Release not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get release",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, release)
+}
+
+// GetReleaseDiagram returns a diagram as it was when the release was created
+func GetReleaseDiagram(c *gin.Context) {
+	releaseService := services.NewReleaseService()
+
+	diagram, err := releaseService.GetDiagram(c.Param("name"), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReleaseNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Release not found",
+			})
+		case err == services.ErrDiagramNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found in release",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to get diagram",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	services.NewDiagramService().Localize(diagram, c.Query("lang"))
+
+	c.JSON(http.StatusOK, diagram)
+}
+
+// GetReleaseDiff reports which diagrams were added, removed or changed
+// between two releases, with a semantic diff per changed diagram.
+// ?format=markdown returns a change report instead of JSON.
+func GetReleaseDiff(c *gin.Context) {
+	releaseService := services.NewReleaseService()
+
+	diff, err := releaseService.Diff(c.Param("name"), c.Param("other"))
+	if err != nil {
+		if errors.Is(err, services.ErrReleaseNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Release not found",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to diff releases",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, diff)
+	case "markdown":
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", diff.Markdown())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported format, use json or markdown",
+		})
+	}
+}
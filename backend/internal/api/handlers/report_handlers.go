@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetControlsReport exports the controls-to-process-steps matrix as JSON,
+// or as CSV with ?format=csv (one row per control and step)
+func GetControlsReport(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report format",
+			"details": "supported values are json and csv",
+		})
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	matrix, err := diagramService.ControlMatrix(c.Query("framework"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build controls report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "csv" {
+		c.Header("Content-Disposition", `attachment; filename="controls.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", controlMatrixCSV(matrix))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"controls": matrix,
+		"count":    len(matrix),
+	})
+}
+
+// GetCostsReport lists the expected cost per execution of every diagram.
+// Diagrams whose costs cannot be rolled up carry an error instead.
+func GetCostsReport(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	diagrams, err := diagramService.ListAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list diagrams",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rows := []gin.H{}
+	for _, diagram := range diagrams {
+		row := gin.H{
+			"diagramId": diagram.ID,
+			"name":      diagram.Name,
+		}
+		report, err := diagramService.Costs(diagram.ID)
+		if err != nil {
+			row["error"] = err.Error()
+		} else {
+			row["currency"] = report.Currency
+			row["expectedCost"] = report.ExpectedCost
+			row["minPathCost"] = report.MinPathCost
+			row["maxPathCost"] = report.MaxPathCost
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diagrams": rows,
+		"count":    len(rows),
+	})
+}
+
+// ListScheduledReports returns the scheduled report definitions with their
+// next and last runs
+func ListScheduledReports(c *gin.Context) {
+	reportService := services.NewReportScheduleService()
+
+	reports, err := reportService.Status()
+	if err != nil {
+		respondScheduledReportError(c, err, "Failed to load scheduled reports")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+		"count":   len(reports),
+	})
+}
+
+// PreviewScheduledReport renders a scheduled report as it would be mailed,
+// without sending it
+func PreviewScheduledReport(c *gin.Context) {
+	reportService := services.NewReportScheduleService()
+
+	report, err := reportService.Find(c.Param("name"))
+	if err != nil {
+		respondScheduledReportError(c, err, "Failed to load scheduled report")
+		return
+	}
+
+	export, err := reportService.Render(report)
+	if err != nil {
+		respondScheduledReportError(c, err, "Failed to render scheduled report")
+		return
+	}
+
+	c.Data(http.StatusOK, export.ContentType, export.Data)
+}
+
+// SendScheduledReport mails a scheduled report to its recipients now
+func SendScheduledReport(c *gin.Context) {
+	reportService := services.NewReportScheduleService()
+
+	report, err := reportService.Find(c.Param("name"))
+	if err != nil {
+		respondScheduledReportError(c, err, "Failed to load scheduled report")
+		return
+	}
+
+	if err := reportService.Send(report); err != nil {
+		if errors.Is(err, services.ErrNotConfigured) || errors.Is(err, services.ErrInvalidOptions) {
+			respondScheduledReportError(c, err, "Failed to send scheduled report")
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to send scheduled report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Report sent",
+		"name":       report.Name,
+		"recipients": report.Recipients,
+	})
+}
+
+func respondScheduledReportError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Scheduled reports are not configured",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrReportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Report not found",
+			"details": c.Param("name"),
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
+
+func controlMatrixCSV(matrix []models.ControlMatrixRow) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"controlId", "framework", "name", "diagramId", "diagramName", "nodeId", "nodeName", "evidence"})
+	for _, row := range matrix {
+		if len(row.Steps) == 0 {
+			w.Write([]string{row.ControlID, row.Framework, row.Name, "", "", "", "", ""})
+			continue
+		}
+		for _, step := range row.Steps {
+			evidence := ""
+			if step.Evidence != nil {
+				evidence = *step.Evidence
+			}
+			w.Write([]string{row.ControlID, row.Framework, row.Name, step.DiagramID, step.DiagramName, step.NodeID, step.NodeName, evidence})
+		}
+	}
+	w.Flush()
+	return buf.Bytes()
+}
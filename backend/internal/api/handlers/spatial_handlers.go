@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetDiagramWindow returns the part of a diagram inside the viewport given
+// by ?x=&y=&w=&h=, so editors of very large diagrams load only what is
+// shown
+func GetDiagramWindow(c *gin.Context) {
+	window, err := windowFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid window",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	result, err := diagramService.Window(c.Param("id"), window)
+	if err != nil {
+		respondSpatialError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// windowFromQuery reads a viewport rectangle from the query string; x and
+// y default to 0
+func windowFromQuery(c *gin.Context) (services.Rect, error) {
+	var window services.Rect
+	var err error
+	if window.X, err = queryFloat(c, "x", false); err != nil {
+		return window, err
+	}
+	if window.Y, err = queryFloat(c, "y", false); err != nil {
+		return window, err
+	}
+	if window.W, err = queryFloat(c, "w", true); err != nil {
+		return window, err
+	}
+	window.H, err = queryFloat(c, "h", true)
+	return window, err
+}
+
+// queryFloat reads a number from the query string; optional numbers
+// default to 0
+func queryFloat(c *gin.Context, name string, required bool) (float64, error) {
+	raw := c.Query(name)
+	if raw == "" && !required {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, errors.New(name + " must be a number")
+	}
+	return v, nil
+}
+
+// HitTestDiagram returns the elements under the point ?x=&y=, topmost
+// first. ?tolerance= sets how close to an edge's line counts as a hit.
+func HitTestDiagram(c *gin.Context) {
+	point, tolerance, err := pointFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid point",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	hits, err := diagramService.HitTest(c.Param("id"), point, tolerance)
+	if err != nil {
+		respondSpatialError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hits": hits,
+	})
+}
+
+// pointFromQuery reads ?x=&y= and the optional ?tolerance=
+func pointFromQuery(c *gin.Context) (models.Position, float64, error) {
+	var point models.Position
+	var err error
+	if point.X, err = queryFloat(c, "x", true); err != nil {
+		return point, 0, err
+	}
+	if point.Y, err = queryFloat(c, "y", true); err != nil {
+		return point, 0, err
+	}
+	tolerance, err := queryFloat(c, "tolerance", false)
+	return point, tolerance, err
+}
+
+// GetElementsWithin returns the elements lying inside the rectangle with
+// corners ?x1=&y1= and ?x2=&y2=, or with ?touching=true those intersecting
+// it
+func GetElementsWithin(c *gin.Context) {
+	var corners [4]float64
+	var err error
+	for i, name := range []string{"x1", "y1", "x2", "y2"} {
+		if corners[i], err = queryFloat(c, name, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid rectangle",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+	r := services.Rect{
+		X: math.Min(corners[0], corners[2]),
+		Y: math.Min(corners[1], corners[3]),
+		W: math.Abs(corners[2] - corners[0]),
+		H: math.Abs(corners[3] - corners[1]),
+	}
+
+	diagramService := services.NewDiagramService()
+
+	selection, err := diagramService.Within(c.Param("id"), r, c.Query("touching") == "true")
+	if err != nil {
+		respondSpatialError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, selection)
+}
+
+// respondSpatialError answers a failed window, hit-test or within query
+func respondSpatialError(c *gin.Context, err error) {
+	if err == services.ErrDiagramNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Diagram not found",
+		})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidOptions) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid spatial query",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Failed to query diagram geometry",
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetSyncStatus reports how the diagrams repository relates to its remote,
+// including conflicts left by the last pull
+func GetSyncStatus(c *gin.Context) {
+	gitSyncService := services.NewGitSyncService()
+
+	status, err := gitSyncService.Status()
+	if err != nil {
+		respondSyncError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// PullSync pulls and merges the remote now. ?strategy=ours|theirs resolves
+// conflicting hunks in favor of local or remote changes.
+func PullSync(c *gin.Context) {
+	gitSyncService := services.NewGitSyncService()
+
+	status, err := gitSyncService.Pull(c.Query("strategy"))
+	if err != nil {
+		respondSyncError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// PushSync commits local changes and pushes them now
+func PushSync(c *gin.Context) {
+	gitSyncService := services.NewGitSyncService()
+
+	status, err := gitSyncService.Push()
+	if err != nil {
+		respondSyncError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// ResolveSync settles the conflicts of the last pull, choosing ours or
+// theirs per conflict path from the conflict report
+func ResolveSync(c *gin.Context) {
+	var request struct {
+		Resolutions map[string]string `json:"resolutions" binding:"required"`
+	}
+	if !bindJSON(c, &request, "Invalid resolution request") {
+		return
+	}
+
+	gitSyncService := services.NewGitSyncService()
+
+	status, err := gitSyncService.Resolve(request.Resolutions)
+	if err != nil {
+		respondSyncError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func respondSyncError(c *gin.Context, status *services.GitSyncStatus, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Git sync not configured",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid sync request",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrSyncConflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Merge conflict with remote",
+			"details": err.Error(),
+			"status":  status,
+		})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Git sync failed",
+			"details": err.Error(),
+			"status":  status,
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// PostTelemetry ingests a batch of runtime counters and latencies keyed by
+// node ID
+func PostTelemetry(c *gin.Context) {
+	id := c.Param("id")
+
+	var batch services.TelemetryBatch
+	if !bindJSON(c, &batch, "Invalid telemetry data") {
+		return
+	}
+
+	telemetryService := services.NewTelemetryService()
+
+	ignored, err := telemetryService.Record(id, batch)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid telemetry data",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to record telemetry",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"accepted": len(batch.Nodes) - len(ignored),
+		"ignored":  ignored,
+	})
+}
+
+// GetTelemetry returns the buffered telemetry of a diagram aggregated per
+// node, with a relative heat value for overlays. ?since= (RFC 3339)
+// restricts it to recent batches.
+func GetTelemetry(c *gin.Context) {
+	id := c.Param("id")
+
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid since timestamp",
+				"details": err.Error(),
+			})
+			return
+		}
+		since = parsed
+	}
+
+	telemetryService := services.NewTelemetryService()
+
+	summary, err := telemetryService.Summary(id, since)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to summarize telemetry",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
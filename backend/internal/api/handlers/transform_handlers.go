@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// MergeDiagrams combines two diagrams into a new diagram
+func MergeDiagrams(c *gin.Context) {
+	var mergeRequest struct {
+		FirstID   string `json:"firstId" binding:"required"`
+		SecondID  string `json:"secondId" binding:"required"`
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Namespace *bool  `json:"namespace"` // Optional: defaults to true
+		Stitch    string `json:"stitch"`    // Optional: none, matching or all
+		Save      bool   `json:"save"`      // Optional: persist the merged diagram
+	}
+
+	if !bindJSON(c, &mergeRequest, "Invalid merge request") {
+		return
+	}
+
+	opts := services.MergeOptions{
+		ID:        mergeRequest.ID,
+		Name:      mergeRequest.Name,
+		Namespace: mergeRequest.Namespace == nil || *mergeRequest.Namespace,
+		Stitch:    mergeRequest.Stitch,
+	}
+
+	diagramService := diagramServiceFor(c)
+
+	result, err := diagramService.Merge(mergeRequest.FirstID, mergeRequest.SecondID, opts)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to merge diagrams",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if mergeRequest.Save {
+		created, err := diagramService.Create(&result.Diagram)
+		if errors.Is(err, services.ErrDiagramExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     "Diagram already exists",
+				"details":   err.Error(),
+				"conflicts": result.Conflicts,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":     "Failed to save merged diagram",
+				"details":   err.Error(),
+				"conflicts": result.Conflicts,
+			})
+			return
+		}
+		result.Diagram = *created
+		if respondDryRun(c, diagramService, result) {
+			return
+		}
+		c.JSON(http.StatusCreated, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ExtractSubgraph moves a set of nodes into a new child diagram
+func ExtractSubgraph(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	var extractRequest struct {
+		NodeIDs   []string `json:"nodeIds" binding:"required"`
+		ChildID   string   `json:"childId" binding:"required"`
+		ChildName string   `json:"childName"`
+		NodeID    string   `json:"nodeId"`   // Optional: replacement node ID, defaults to childId
+		NodeName  string   `json:"nodeName"` // Optional: replacement node name, defaults to childName
+	}
+
+	if !bindJSON(c, &extractRequest, "Invalid extract request") {
+		return
+	}
+
+	diagramService := diagramServiceFor(c)
+
+	result, err := diagramService.Extract(id, services.ExtractOptions{
+		NodeIDs:   extractRequest.NodeIDs,
+		ChildID:   extractRequest.ChildID,
+		ChildName: extractRequest.ChildName,
+		NodeID:    extractRequest.NodeID,
+		NodeName:  extractRequest.NodeName,
+	})
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to extract subgraph",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if respondDryRun(c, diagramService, result) {
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// CopyNodes copies nodes from a source diagram into the diagram in the path
+func CopyNodes(c *gin.Context) {
+	transferNodes(c, false)
+}
+
+// MoveNodes moves nodes from a source diagram into the diagram in the path
+func MoveNodes(c *gin.Context) {
+	transferNodes(c, true)
+}
+
+func transferNodes(c *gin.Context, move bool) {
+	targetID := c.Param("id")
+	if targetID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	var transferRequest struct {
+		SourceID string   `json:"sourceId"` // Optional: defaults to the target diagram
+		NodeIDs  []string `json:"nodeIds" binding:"required"`
+		Offset   struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+		} `json:"offset"`
+		IncludeEdges *bool `json:"includeEdges"` // Optional: defaults to true
+	}
+
+	if !bindJSON(c, &transferRequest, "Invalid node transfer request") {
+		return
+	}
+
+	opts := services.NodeTransferOptions{
+		SourceID:     transferRequest.SourceID,
+		NodeIDs:      transferRequest.NodeIDs,
+		IncludeEdges: transferRequest.IncludeEdges == nil || *transferRequest.IncludeEdges,
+	}
+	opts.Offset.X = transferRequest.Offset.X
+	opts.Offset.Y = transferRequest.Offset.Y
+
+	diagramService := diagramServiceFor(c)
+
+	var result *services.NodeTransferResult
+	var err error
+	if move {
+		result, err = diagramService.MoveNodes(targetID, opts)
+	} else {
+		result, err = diagramService.CopyNodes(targetID, opts)
+	}
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to transfer nodes",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if respondDryRun(c, diagramService, result) {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RestyleDiagram applies a style patch to all elements matching a selector
+func RestyleDiagram(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	var restyleRequest struct {
+		Selector services.ElementSelector `json:"selector"`
+		Style    *models.Style            `json:"style" binding:"required"`
+	}
+
+	if !bindJSON(c, &restyleRequest, "Invalid restyle request") {
+		return
+	}
+
+	diagramService := diagramServiceFor(c)
+
+	result, err := diagramService.Restyle(id, restyleRequest.Selector, *restyleRequest.Style)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to restyle diagram",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if respondDryRun(c, diagramService, result) {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ExecuteDiagramCommands applies a batch of editing commands atomically.
+// The diagram is saved only if every command succeeds and the result
+// validates; otherwise it is left unchanged. dryRun in the body or
+// ?dryRun=true returns the result without saving.
+func ExecuteDiagramCommands(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Diagram ID is required",
+		})
+		return
+	}
+
+	var commandRequest struct {
+		Commands []services.DiagramCommand `json:"commands" binding:"required"`
+		DryRun   bool                      `json:"dryRun"` // Optional: return the result without saving
+	}
+
+	if !bindJSON(c, &commandRequest, "Invalid command request") {
+		return
+	}
+
+	diagramService := services.NewDiagramService().ActingFor(c.Request.Header)
+
+	dryRun := commandRequest.DryRun || c.Query("dryRun") == "true"
+	result, err := diagramService.ExecuteCommands(id, commandRequest.Commands, dryRun)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to execute commands",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if result.Validation != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "Commands produce an invalid diagram",
+			"validation": result.Validation,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ConvertNode changes the type of a node (?type=decision), adjusting its
+// size and colors to the new type. ?dryRun=true returns the result without
+// saving.
+func ConvertNode(c *gin.Context) {
+	diagramService := services.NewDiagramService().ActingFor(c.Request.Header)
+
+	result, err := diagramService.ConvertNode(c.Param("id"), c.Param("nodeId"), models.NodeType(c.Query("type")), c.Query("dryRun") == "true")
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid node conversion",
+				"details": err.Error(),
+			})
+			return
+		}
+		if respondSaveRejected(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to convert node",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if result.Validation != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "Conversion produces an invalid diagram",
+			"validation": result.Validation,
+			"warnings":   result.Warnings,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SimplifyDiagram removes redundant edge waypoints. The body is optional:
+// {"tolerance": 2, "mode": "auto|douglas-peucker|orthogonal", "edgeIds": [...],
+// "dryRun": true}; ?dryRun=true also works without a body.
+func SimplifyDiagram(c *gin.Context) {
+	var opts services.SimplifyOptions
+	if c.Request.ContentLength != 0 && !bindJSON(c, &opts, "Invalid simplify request") {
+		return
+	}
+	opts.DryRun = opts.DryRun || c.Query("dryRun") == "true"
+
+	diagramService := services.NewDiagramService().ActingFor(c.Request.Header)
+
+	result, err := diagramService.Simplify(c.Param("id"), opts)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Diagram not found",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid simplify options",
+				"details": err.Error(),
+			})
+			return
+		}
+		if respondSaveRejected(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to simplify diagram",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
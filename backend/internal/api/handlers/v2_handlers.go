@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// API v2 wraps every response in the same envelope and exposes opaque IDs.
+// v1 handlers are left untouched so existing clients keep working.
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// envelope is the response shape shared by all v2 endpoints
+type envelope struct {
+	Data   interface{}     `json:"data"`
+	Meta   *envelopeMeta   `json:"meta,omitempty"`
+	Errors []envelopeError `json:"errors"`
+}
+
+// envelopeMeta carries pagination information for collection responses
+type envelopeMeta struct {
+	Cursor *string `json:"cursor"`
+	Total  int     `json:"total"`
+}
+
+// envelopeError describes a single error in a v2 response
+type envelopeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+// v2SearchResult is a diagram search hit without the full diagram payload
+type v2SearchResult struct {
+	Diagram   models.DiagramSummary `json:"diagram"`
+	Score     float64               `json:"score"`
+	MatchType string                `json:"matchType"`
+}
+
+// v2NodeSearchResult is a node search hit referencing its diagram by summary
+type v2NodeSearchResult struct {
+	Node      models.FlowNode       `json:"node"`
+	Diagram   models.DiagramSummary `json:"diagram"`
+	Score     float64               `json:"score"`
+	MatchType string                `json:"matchType"`
+}
+
+// ListDiagramsV2 returns a page of diagram summaries with their stats,
+// ordered by ID or by the sort query parameter
+func ListDiagramsV2(c *gin.Context) {
+	diagramService := services.NewDiagramService()
+
+	summaries, err := diagramService.Summaries(c.Query("sort"), includeArchived(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOptions) {
+			respondV2Error(c, http.StatusBadRequest, "INVALID_SORT", "Sort order is not supported", err)
+			return
+		}
+		respondV2Error(c, http.StatusInternalServerError, "INTERNAL", "Failed to list diagrams", err)
+		return
+	}
+	if status := c.Query("validation"); status != "" {
+		filtered := summaries[:0]
+		for _, summary := range summaries {
+			if summary.Stats.Validation == status {
+				filtered = append(filtered, summary)
+			}
+		}
+		summaries = filtered
+	}
+	for i := range summaries {
+		summaries[i] = opaqueSummary(summaries[i])
+	}
+
+	respondV2Page(c, summaries)
+}
+
+// GetDiagramV2 returns a single diagram addressed by its opaque ID
+func GetDiagramV2(c *gin.Context) {
+	id, ok := decodeOpaqueID(c.Param("id"))
+	if !ok {
+		respondV2Error(c, http.StatusBadRequest, "INVALID_ID", "Diagram ID is malformed", nil)
+		return
+	}
+
+	diagramService := services.NewDiagramService()
+
+	diagram, err := diagramService.GetByID(id)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			respondV2Error(c, http.StatusNotFound, "NOT_FOUND", "Diagram not found", nil)
+			return
+		}
+		respondV2Error(c, http.StatusInternalServerError, "INTERNAL", "Failed to get diagram", err)
+		return
+	}
+
+	opaque := opaqueDiagram(*diagram)
+	setDeprecationHeaders(c, &opaque, "/api/v2/diagrams/")
+	respondV2(c, http.StatusOK, opaque)
+}
+
+// SearchDiagramsV2 searches diagrams and returns summary hits
+func SearchDiagramsV2(c *gin.Context) {
+	query := c.Query("q")
+	tags := c.QueryArray("tags")
+
+	diagramService := services.NewDiagramService()
+
+	results, err := diagramService.Search(query, tags, includeArchived(c))
+	if err != nil {
+		respondV2Error(c, http.StatusInternalServerError, "INTERNAL", "Failed to search diagrams", err)
+		return
+	}
+
+	hits := make([]v2SearchResult, 0, len(results))
+	for i := range results {
+		hits = append(hits, v2SearchResult{
+			Diagram:   opaqueSummary(results[i].Diagram.Summary()),
+			Score:     results[i].Score,
+			MatchType: results[i].MatchType,
+		})
+	}
+
+	respondV2Page(c, hits)
+}
+
+// SearchNodesV2 searches nodes across diagrams without embedding full diagrams
+func SearchNodesV2(c *gin.Context) {
+	query := c.Query("q")
+	nodeType := c.Query("type")
+
+	diagramService := services.NewDiagramService()
+
+	results, err := diagramService.SearchNodes(query, services.NodeSearchOptions{Type: nodeType, IncludeArchived: includeArchived(c)})
+	if err != nil {
+		respondV2Error(c, http.StatusInternalServerError, "INTERNAL", "Failed to search nodes", err)
+		return
+	}
+
+	hits := make([]v2NodeSearchResult, 0, len(results))
+	for i := range results {
+		node := results[i].Node
+		if node.DrillDown != nil {
+			drillDown := encodeOpaqueID(*node.DrillDown)
+			node.DrillDown = &drillDown
+		}
+		hits = append(hits, v2NodeSearchResult{
+			Node:      node,
+			Diagram:   opaqueSummary(*results[i].DiagramSummary),
+			Score:     results[i].Score,
+			MatchType: results[i].MatchType,
+		})
+	}
+
+	respondV2Page(c, hits)
+}
+
+// GetChildDiagramsV2 returns a page of child diagram summaries
+func GetChildDiagramsV2(c *gin.Context) {
+	id, ok := decodeOpaqueID(c.Param("id"))
+	if !ok {
+		respondV2Error(c, http.StatusBadRequest, "INVALID_ID", "Diagram ID is malformed", nil)
+		return
+	}
+
+	hierarchyService := services.NewHierarchyService()
+
+	children, err := hierarchyService.GetChildren(id)
+	if err != nil {
+		if err == services.ErrDiagramNotFound {
+			respondV2Error(c, http.StatusNotFound, "NOT_FOUND", "Diagram not found", nil)
+			return
+		}
+		respondV2Error(c, http.StatusInternalServerError, "INTERNAL", "Failed to get child diagrams", err)
+		return
+	}
+
+	summaries := make([]models.DiagramSummary, 0, len(children))
+	for i := range children {
+		summaries = append(summaries, opaqueSummary(children[i].Summary()))
+	}
+
+	respondV2Page(c, summaries)
+}
+
+// Envelope helpers
+
+func respondV2(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, envelope{
+		Data:   data,
+		Errors: []envelopeError{},
+	})
+}
+
+func respondV2Error(c *gin.Context, status int, code, message string, err error) {
+	e := envelopeError{Code: code, Message: message}
+	if err != nil {
+		e.Details = err.Error()
+	}
+	c.JSON(status, envelope{
+		Data:   nil,
+		Errors: []envelopeError{e},
+	})
+}
+
+// respondV2Page slices items according to the cursor and limit query
+// parameters and writes the page with its pagination metadata
+func respondV2Page[T any](c *gin.Context, items []T) {
+	offset, err := decodeCursor(c.Query("cursor"))
+	if err != nil {
+		respondV2Error(c, http.StatusBadRequest, "INVALID_CURSOR", "Cursor is malformed", nil)
+		return
+	}
+
+	limit := defaultPageSize
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			respondV2Error(c, http.StatusBadRequest, "INVALID_LIMIT", "Limit must be a positive integer", nil)
+			return
+		}
+		limit = n
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	total := len(items)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	meta := &envelopeMeta{Total: total}
+	if end < total {
+		next := encodeCursor(end)
+		meta.Cursor = &next
+	}
+
+	c.JSON(http.StatusOK, envelope{
+		Data:   items[offset:end],
+		Meta:   meta,
+		Errors: []envelopeError{},
+	})
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	value, found := strings.CutPrefix(string(raw), "o:")
+	if !found {
+		return 0, errInvalidCursor
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, errInvalidCursor
+	}
+	return offset, nil
+}
+
+// Opaque IDs hide the file-derived diagram identifiers from v2 clients
+
+func encodeOpaqueID(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func decodeOpaqueID(opaque string) (string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(opaque)
+	if err != nil || len(raw) == 0 {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// opaqueIDs encodes a list of diagram IDs, keeping an empty list empty
+func opaqueIDs(ids []string) []string {
+	if len(ids) == 0 {
+		return ids
+	}
+	opaque := make([]string, len(ids))
+	for i, id := range ids {
+		opaque[i] = encodeOpaqueID(id)
+	}
+	return opaque
+}
+
+func opaqueSummary(summary models.DiagramSummary) models.DiagramSummary {
+	summary.ID = encodeOpaqueID(summary.ID)
+	if summary.Parent != nil {
+		parent := encodeOpaqueID(*summary.Parent)
+		summary.Parent = &parent
+	}
+	summary.Parents = opaqueIDs(summary.Parents)
+	return summary
+}
+
+func opaqueDiagram(diagram models.FlowDiagram) models.FlowDiagram {
+	diagram.ID = encodeOpaqueID(diagram.ID)
+	if diagram.Parent != nil {
+		parent := encodeOpaqueID(*diagram.Parent)
+		diagram.Parent = &parent
+	}
+	diagram.Parents = opaqueIDs(diagram.Parents)
+
+	children := make([]string, 0, len(diagram.Children))
+	for _, childID := range diagram.Children {
+		children = append(children, encodeOpaqueID(childID))
+	}
+	diagram.Children = children
+
+	relations := make([]models.Relation, len(diagram.Relations))
+	copy(relations, diagram.Relations)
+	for i := range relations {
+		relations[i].Target = encodeOpaqueID(relations[i].Target)
+	}
+	diagram.Relations = relations
+
+	if diagram.Deprecated != nil && diagram.Deprecated.SupersededBy != nil {
+		deprecated := *diagram.Deprecated
+		successor := encodeOpaqueID(*deprecated.SupersededBy)
+		deprecated.SupersededBy = &successor
+		diagram.Deprecated = &deprecated
+	}
+
+	nodes := make([]models.FlowNode, len(diagram.Nodes))
+	copy(nodes, diagram.Nodes)
+	for i := range nodes {
+		if nodes[i].DrillDown != nil {
+			drillDown := encodeOpaqueID(*nodes[i].DrillDown)
+			nodes[i].DrillDown = &drillDown
+		}
+	}
+	diagram.Nodes = nodes
+	diagram.FilePath = ""
+
+	return diagram
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// GetWorkspaceSettings returns the settings and feature flags of a
+// workspace
+func GetWorkspaceSettings(c *gin.Context) {
+	workspaceService := services.NewWorkspaceService()
+
+	settings, err := workspaceService.Settings(c.Param("id"))
+	if err != nil {
+		respondWorkspaceError(c, err, "Failed to load workspace settings")
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateWorkspaceSettings replaces the settings of a workspace. Omitted
+// settings revert to their defaults.
+func UpdateWorkspaceSettings(c *gin.Context) {
+	settings := models.DefaultWorkspaceSettings()
+	if !bindJSON(c, &settings, "Invalid workspace settings") {
+		return
+	}
+
+	workspaceService := services.NewWorkspaceService()
+
+	saved, err := workspaceService.SaveSettings(c.Param("id"), settings)
+	if err != nil {
+		respondWorkspaceError(c, err, "Failed to save workspace settings")
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// requireFeature responds with 403 and returns false when a feature is
+// switched off in the workspace of the request
+func requireFeature(c *gin.Context, feature string) bool {
+	workspaceService := services.NewWorkspaceService()
+
+	if err := workspaceService.RequireFeature(c.Request.Header, feature); err != nil {
+		respondWorkspaceError(c, err, "Failed to load workspace settings")
+		return false
+	}
+	return true
+}
+
+func respondWorkspaceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrFeatureDisabled):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Feature disabled",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidOptions):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
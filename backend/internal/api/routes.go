@@ -7,20 +7,64 @@ import (
 
 // SetupRoutes configures all API routes
 func SetupRoutes(r *gin.Engine) {
-	api := r.Group("/api/v1")
+	// Clients may exchange MessagePack instead of JSON
+	api := r.Group("/api/v1", handlers.NegotiateMsgPack)
 	{
-		// Diagram routes
-		diagrams := api.Group("/diagrams")
+		// Diagram routes; reads are recorded in the access log
+		diagrams := api.Group("/diagrams", handlers.LogDiagramAccess)
 		{
 			diagrams.GET("", handlers.ListDiagrams)
 			diagrams.POST("", handlers.CreateDiagram)
+			diagrams.GET("/errors", handlers.GetDiagramLoadErrors)
+			diagrams.POST("/yaml", handlers.CreateDiagramYAML)
+			diagrams.POST("/merge", handlers.MergeDiagrams)
+			diagrams.POST("/bulk/metadata", handlers.BulkEditMetadata)
+			diagrams.POST("/import/terraform", handlers.ImportTerraform)
+			diagrams.POST("/import/openapi", handlers.ImportOpenAPI)
+			diagrams.POST("/import/github-actions", handlers.ImportGitHubActions)
+			diagrams.POST("/import/bpmn", handlers.ImportBPMN)
+			diagrams.POST("/generate/jira", handlers.GenerateJiraDiagram)
 			diagrams.GET("/:id", handlers.GetDiagram)
 			diagrams.PUT("/:id", handlers.UpdateDiagram)
 			diagrams.DELETE("/:id", handlers.DeleteDiagram)
 			diagrams.POST("/:id/validate", handlers.ValidateDiagram)
+			diagrams.POST("/:id/deprecate", handlers.DeprecateDiagram)
+			diagrams.DELETE("/:id/deprecate", handlers.UndeprecateDiagram)
+			diagrams.POST("/:id/archive", handlers.ArchiveDiagram)
+			diagrams.DELETE("/:id/archive", handlers.UnarchiveDiagram)
+			diagrams.GET("/:id/view", handlers.GetDiagramView)
+			diagrams.GET("/:id/window", handlers.GetDiagramWindow)
+			diagrams.GET("/:id/hittest", handlers.HitTestDiagram)
+			diagrams.GET("/:id/within", handlers.GetElementsWithin)
+			diagrams.POST("/:id/extract", handlers.ExtractSubgraph)
+			diagrams.POST("/:id/nodes/copy", handlers.CopyNodes)
+			diagrams.POST("/:id/nodes/move", handlers.MoveNodes)
+			diagrams.POST("/:id/nodes/:nodeId/convert", handlers.ConvertNode)
+			diagrams.POST("/:id/nodes/:nodeId/jira", handlers.CreateNodeJiraIssue)
+			diagrams.POST("/:id/restyle", handlers.RestyleDiagram)
+			diagrams.POST("/:id/simplify", handlers.SimplifyDiagram)
+			diagrams.POST("/:id/commands", handlers.ExecuteDiagramCommands)
+			diagrams.GET("/:id/timing", handlers.GetDiagramTiming)
+			diagrams.GET("/:id/costs", handlers.GetDiagramCosts)
+			// Live runtime overlay pushed from event pipelines
+			diagrams.POST("/:id/telemetry", handlers.PostTelemetry)
+			diagrams.GET("/:id/telemetry", handlers.GetTelemetry)
+			diagrams.GET("/:id/overlays/prometheus", handlers.GetPrometheusOverlay)
+			diagrams.GET("/:id/overlays/health", handlers.GetHealthOverlay)
 			// Raw YAML access for Git-friendly workflows
 			diagrams.GET("/:id/yaml", handlers.GetDiagramYAML)
 			diagrams.PUT("/:id/yaml", handlers.UpdateDiagramYAML)
+			diagrams.POST("/:id/yaml/lint", handlers.LintDiagramYAML)
+			// Rendered exports (json, yaml, svg, mermaid, pdf, a11y, html, excalidraw, structurizr)
+			diagrams.GET("/:id/export/:format", handlers.ExportDiagram)
+			diagrams.POST("/:id/export/subset", handlers.ExportSubset)
+			// Change notifications and review requests by email
+			diagrams.GET("/:id/subscribers", handlers.GetDiagramSubscribers)
+			diagrams.POST("/:id/subscribers", handlers.SubscribeToDiagram)
+			diagrams.DELETE("/:id/subscribers/:person", handlers.UnsubscribeFromDiagram)
+			diagrams.POST("/:id/review-requests", handlers.RequestDiagramReview)
+			// Glossary terms found in descriptions, for hover definitions
+			diagrams.GET("/:id/glossary", handlers.GetDiagramGlossary)
 		}
 
 		// Hierarchy routes for drill-down functionality
@@ -28,7 +72,106 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			hierarchy.GET("/:id/children", handlers.GetChildDiagrams)
 			hierarchy.GET("/:id/parent", handlers.GetParentDiagram)
+			hierarchy.GET("/:id/parents", handlers.GetParentDiagrams)
 			hierarchy.POST("/:id/link", handlers.LinkDiagrams)
+			hierarchy.POST("/:id/reparent", handlers.ReparentDiagram)
+			hierarchy.GET("/:id/map", handlers.GetSystemMap)
+			hierarchy.GET("/:id/tree", handlers.GetHierarchyTree)
+			hierarchy.GET("/:id/export/pdf", handlers.ExportHierarchyPDF)
+			hierarchy.GET("/:id/relations", handlers.GetDiagramRelations)
+			hierarchy.POST("/:id/relations", handlers.AddDiagramRelation)
+			hierarchy.DELETE("/:id/relations/:type/:target", handlers.RemoveDiagramRelation)
+		}
+
+		// Git sync of the diagrams repository
+		sync := api.Group("/sync")
+		{
+			sync.GET("", handlers.GetSyncStatus)
+			sync.POST("/pull", handlers.PullSync)
+			sync.POST("/push", handlers.PushSync)
+			sync.POST("/resolve", handlers.ResolveSync)
+		}
+
+		// Checks of all diagrams and the references between them
+		consistency := api.Group("/consistency")
+		{
+			consistency.GET("", handlers.GetConsistencyReport)
+			consistency.POST("/run", handlers.RunConsistencyCheck)
+		}
+
+		// Server-Sent Events, e.g. diagrams changed outside the API
+		api.GET("/events", handlers.StreamEvents)
+
+		// Named snapshots of the diagram set
+		releases := api.Group("/releases")
+		{
+			releases.GET("", handlers.ListReleases)
+			releases.POST("", handlers.CreateRelease)
+			releases.GET("/:name", handlers.GetRelease)
+			releases.GET("/:name/diagrams/:id", handlers.GetReleaseDiagram)
+			releases.GET("/:name/diff/:other", handlers.GetReleaseDiff)
+		}
+
+		// Webhooks for CI and source repositories
+		hooks := api.Group("/hooks")
+		{
+			hooks.POST("/regenerate", handlers.RegenerateFromSources)
+		}
+
+		// People and teams referenced by ownership fields
+		api.GET("/directory", handlers.GetDirectory)
+
+		// Preferences of the signed-in person (identified by USER_HEADER)
+		api.GET("/me/preferences", handlers.GetMyPreferences)
+		api.PUT("/me/preferences", handlers.UpdateMyPreferences)
+
+		// Email notification preferences of people in the directory
+		notifications := api.Group("/notifications")
+		{
+			notifications.GET("/preferences/:person", handlers.GetNotificationPreferences)
+			notifications.PUT("/preferences/:person", handlers.UpdateNotificationPreferences)
+			notifications.POST("/stale-reminders", handlers.SendStaleReminders)
+		}
+
+		// Per-workspace settings and feature flags
+		workspaces := api.Group("/workspaces")
+		{
+			workspaces.GET("/:id/settings", handlers.GetWorkspaceSettings)
+			workspaces.PUT("/:id/settings", handlers.UpdateWorkspaceSettings)
+		}
+
+		// Canonical YAML formatting, e.g. for pre-commit hooks
+		api.POST("/format", handlers.FormatDiagramYAML)
+
+		// Workspace schema for diagram meta sections
+		api.GET("/meta/schema", handlers.GetMetaSchema)
+
+		// Catalog of canonical nodes shared by diagrams
+		catalog := api.Group("/catalog")
+		{
+			catalog.GET("/nodes", handlers.ListCatalogNodes)
+			catalog.GET("/nodes/:id", handlers.GetCatalogNode)
+			catalog.GET("/nodes/:id/usage", handlers.GetCatalogNodeUsage)
+			catalog.POST("/nodes/:id/propagate", handlers.PropagateCatalogNode)
+		}
+
+		// Example diagrams for demo and CI environments, server status and
+		// maintenance of the diagrams path
+		admin := api.Group("/admin")
+		{
+			admin.POST("/seed", handlers.SeedDiagrams)
+			admin.GET("/status", handlers.GetServerStatus)
+			admin.POST("/reorganize", handlers.ReorganizeDiagrams)
+		}
+
+		// Glossary of terms with definitions
+		glossary := api.Group("/glossary")
+		{
+			glossary.GET("", handlers.ListGlossaryTerms)
+			glossary.POST("", handlers.CreateGlossaryTerm)
+			glossary.GET("/:id", handlers.GetGlossaryTerm)
+			glossary.PUT("/:id", handlers.UpdateGlossaryTerm)
+			glossary.DELETE("/:id", handlers.DeleteGlossaryTerm)
 		}
 
 		// Integration routes
@@ -38,15 +181,71 @@ func SetupRoutes(r *gin.Engine) {
 			{
 				jira.GET("/projects", handlers.GetJiraProjects)
 				jira.GET("/issues/:key", handlers.GetJiraIssue)
+				jira.GET("/search", handlers.SearchJiraIssues)
+				// Atlassian OAuth 2.0 (3LO) connection to Jira Cloud
+				jira.GET("/oauth", handlers.GetJiraConnection)
+				jira.DELETE("/oauth", handlers.DisconnectJira)
+				jira.GET("/oauth/authorize", handlers.AuthorizeJira)
+				jira.GET("/oauth/callback", handlers.JiraOAuthCallback)
 				jira.POST("/issues", handlers.CreateJiraIssue)
 			}
 		}
 
+		// Compliance and audit reports
+		reports := api.Group("/reports")
+		{
+			reports.GET("/controls", handlers.GetControlsReport)
+			reports.GET("/costs", handlers.GetCostsReport)
+			reports.GET("/scheduled", handlers.ListScheduledReports)
+			reports.GET("/scheduled/:name/preview", handlers.PreviewScheduledReport)
+			reports.POST("/scheduled/:name/send", handlers.SendScheduledReport)
+		}
+
+		// Process simulation
+		simulate := api.Group("/simulate")
+		{
+			simulate.POST("/montecarlo", handlers.SimulateMonteCarlo)
+		}
+
+		// Usage analytics from the access log
+		analytics := api.Group("/analytics")
+		{
+			analytics.GET("/most-viewed", handlers.GetMostViewedDiagrams)
+			analytics.GET("/usage", handlers.GetDiagramUsage)
+		}
+
 		// Search and analytics
 		search := api.Group("/search")
 		{
 			search.GET("/diagrams", handlers.SearchDiagrams)
 			search.GET("/nodes", handlers.SearchNodes)
+			search.GET("/content", handlers.SearchContent)
+		}
+	}
+
+	setupV2Routes(r)
+}
+
+// setupV2Routes configures the /api/v2 routes, which share a consistent
+// response envelope, cursor pagination and opaque IDs
+func setupV2Routes(r *gin.Engine) {
+	v2 := r.Group("/api/v2")
+	{
+		diagrams := v2.Group("/diagrams")
+		{
+			diagrams.GET("", handlers.ListDiagramsV2)
+			diagrams.GET("/:id", handlers.GetDiagramV2)
+		}
+
+		hierarchy := v2.Group("/hierarchy")
+		{
+			hierarchy.GET("/:id/children", handlers.GetChildDiagramsV2)
+		}
+
+		search := v2.Group("/search")
+		{
+			search.GET("/diagrams", handlers.SearchDiagramsV2)
+			search.GET("/nodes", handlers.SearchNodesV2)
 		}
 	}
 }
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/michaellanpart/flowgen/backend/internal/services"
+)
+
+// SetupServer adds the CORS headers, the health and readiness checks and
+// all API routes to the router
+func SetupServer(r *gin.Engine) {
+	// Add CORS middleware
+	r.Use(func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Change-Summary")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	})
+
+	// Health check endpoint
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "ok",
+			"service": "flowgen-backend",
+			"version": "0.1.0",
+		})
+	})
+
+	// Readiness: reads are served while the diagram index warms, so this
+	// answers 200 throughout unless ?full=true asks for a complete index.
+	// In strict loading mode it also waits for the index, and fails while
+	// any diagram file does not parse or validate.
+	r.GET("/readyz", func(c *gin.Context) {
+		diagramService := services.NewDiagramService()
+		strict := diagramService.LoadingMode() == services.LoadingStrict
+		index := services.DiagramIndexStatus()
+		code := http.StatusOK
+		if (c.Query("full") == "true" || strict) && index.State == services.IndexWarming {
+			code = http.StatusServiceUnavailable
+		}
+		response := gin.H{
+			"status": index.State,
+			"index":  index,
+		}
+		if strict && code == http.StatusOK {
+			check, err := diagramService.CheckLoading()
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error":   "Failed to check diagrams",
+					"details": err.Error(),
+				})
+				return
+			}
+			if !check.Clean {
+				code = http.StatusServiceUnavailable
+				response["status"] = "failed"
+			}
+			response["loading"] = check
+		}
+		c.JSON(code, response)
+	})
+
+	// API routes
+	SetupRoutes(r)
+}
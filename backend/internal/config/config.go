@@ -2,29 +2,233 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds application configuration
 type Config struct {
-	Port         string
-	Environment  string
-	DatabaseURL  string
-	DiagramsPath string
-	JiraBaseURL  string
-	JiraUsername string
-	JiraAPIToken string
+	Port           string
+	Environment    string
+	DatabaseURL    string
+	DiagramsPath   string
+	JiraBaseURL    string
+	JiraUsername   string
+	JiraAPIToken   string
+	DefaultLocale  string
+	DirectoryPath  string
+	ControlsPath   string
+	TelemetrySize  int // Telemetry batches kept per diagram
+	PrometheusURL  string
+	HealthTTL      time.Duration // How long health check results are cached
+	HealthHosts    []string      // Hosts health URLs may target; public addresses only when empty
+	K8sAPIURL      string        // Kubernetes API server; detected in-cluster when empty
+	K8sToken       string        // Bearer token; the service account token in-cluster
+	GitRemote      string        // Remote synced with when the diagrams path is a Git work tree
+	GitBranch      string        // Defaults to the checked-out branch
+	GitInterval    time.Duration // Scheduled sync period; 0 disables the schedule
+	GitPush        string        // off, schedule or save
+	GitAuthor      string        // Author of commits made for API changes
+	GitProvider    string        // github or gitlab; detected from the remote URL when empty
+	GitToken       string        // Token for opening pull/merge requests
+	GitAPIURL      string        // Provider API base; derived from the remote host when empty
+	SourcesPath    string        // Import sources regenerated by the webhook
+	HooksSecret    string        // Shared secret required by webhook endpoints
+	MetaSchemaPath string        // Workspace schema for diagram meta sections
+	StyleThemePath string        // Named styles shared by all diagrams
+	SaveHooksPath  string        // Commands and URLs called before and after saves
+	ReleasesPath   string        // Directory holding release snapshots
+	ReportsPath    string        // Scheduled report definitions
+	SMTPHost       string
+	SMTPPort       int
+	SMTPUsername   string
+	SMTPPassword   string
+	SMTPFrom       string // Sender of outgoing mail
+
+	// Atlassian OAuth 2.0 (3LO) for Jira Cloud, used instead of JIRA_USERNAME
+	// and JIRA_API_TOKEN when a client ID is set
+	JiraOAuthClientID     string
+	JiraOAuthClientSecret string
+	JiraOAuthRedirectURL  string // Callback registered with the app, ending in /api/v1/integrations/jira/oauth/callback
+	JiraOAuthScopes       string // Space-separated; offline_access is needed for refresh tokens
+	JiraOAuthTokenPath    string // File the connection's tokens are stored in
+	JiraOAuthAuthURL      string // Atlassian authorization server
+	JiraOAuthAPIURL       string // Atlassian API gateway
+
+	// Users and notifications
+	UserHeader                string        // Request header carrying the directory ID of the signed-in person
+	PreferencesPath           string        // Directory holding per-person preference files
+	NotificationTemplatesPath string        // Overrides for the built-in notification templates
+	StaleAfter                time.Duration // Age after which owners are reminded of a diagram; 0 disables
+	StaleSchedule             string        // Cron schedule of stale-diagram reminders
+
+	// Workspaces
+	WorkspacesPath   string // Directory holding per-workspace settings files
+	WorkspaceHeader  string // Request header selecting the workspace
+	DefaultWorkspace string // Workspace of requests that name none
+
+	// Quotas; 0 means unlimited
+	QuotaMaxNodes     int // Nodes per diagram
+	QuotaMaxEdges     int // Edges per diagram
+	QuotaMaxYAMLBytes int // Size of a diagram's YAML
+	QuotaMaxDiagrams  int // Diagrams in the workspace
+
+	// Plugins
+	WasmPluginsPath string // Sandboxed WASM validation and transform plugins
+
+	// Consistency checks of the whole diagram set
+	ConsistencyInterval   time.Duration // Period of the background check; 0 disables it
+	ConsistencyReportPath string        // File the latest report is written to
+
+	// Walking of the diagram roots
+	DiagramsRootName     string   // Name of the DiagramsPath root; its folder name when empty
+	DiagramRoots         []string // Further directories diagrams are read from, as path or name=path; new diagrams go to DiagramsPath
+	DiagramReadOnlyRoots []string // Names of roots whose diagrams cannot be changed
+	DiagramWalkIgnore    []string // Names of files and folders skipped, as filepath.Match patterns
+
+	// Detection of diagram files changed outside the API
+	DiagramWatchInterval time.Duration // Period of the scan of the diagrams path; 0 disables it
+
+	// Diagram files that fail to parse
+	DiagramLoadErrorLog string // Logging of load failures: new (when a file starts failing), always or off
+	DiagramLoading      string // lenient skips failing files; strict fails startup and readiness on them
+
+	// File layout of the diagrams path
+	DiagramNaming  string // any, or kebab-case to require kebab-case IDs of new diagrams
+	DiagramFolders string // flat, tag (folder of the first tag) or parent (nested folders of primary parents)
+
+	// Changelog kept in each diagram
+	ChangelogLimit int // Entries kept, newest last; 0 disables the changelog
+
+	// External validation of diagrams on save
+	ValidationWebhookURL     string        // Receives each diagram before it is saved; empty disables it
+	ValidationWebhookTimeout time.Duration // How long a save waits for it
+	ValidationWebhookFailure string        // open saves, closed refuses, when it cannot be reached
+
+	// Access logging of diagram reads
+	AccessLogPath      string        // JSON lines file of diagram reads; empty disables logging
+	AccessLogRetention time.Duration // Age after which entries are pruned
+	AccessLogViewers   string        // keep, hash or omit the viewer's ID
+	AccessLogSalt      string        // Key for hashed viewer IDs; random per process when empty
+
+	// Shared catalog of canonical nodes
+	NodeCatalogPath string // YAML file of catalog nodes; empty disables the catalog
+
+	// Glossary of terms detected in descriptions
+	GlossaryPath string // YAML file the glossary is stored in
+
+	// Spelling and terminology lint of labels
+	TerminologyPath string // YAML file of dictionaries and term lists; empty disables the lint
+
+	// Waypoint cleanup on save
+	SimplifyWaypointsAbove int // Edges with more waypoints are simplified on save; 0 disables
+
+	// Example diagrams for demo and CI environments
+	SeedDir string // Directory of diagram YAML files; loaded on startup in development
 }
 
 // Load reads configuration from environment variables with defaults
 func Load() *Config {
 	return &Config{
-		Port:         getEnv("PORT", "3001"),
-		Environment:  getEnv("ENVIRONMENT", "development"),
-		DatabaseURL:  getEnv("DATABASE_URL", ""),
-		DiagramsPath: getEnv("DIAGRAMS_PATH", "./diagrams"),
-		JiraBaseURL:  getEnv("JIRA_BASE_URL", ""),
-		JiraUsername: getEnv("JIRA_USERNAME", ""),
-		JiraAPIToken: getEnv("JIRA_API_TOKEN", ""),
+		Port:           getEnv("PORT", "3001"),
+		Environment:    getEnv("ENVIRONMENT", "development"),
+		DatabaseURL:    getEnv("DATABASE_URL", ""),
+		DiagramsPath:   getEnv("DIAGRAMS_PATH", "./diagrams"),
+		JiraBaseURL:    getEnv("JIRA_BASE_URL", ""),
+		JiraUsername:   getEnv("JIRA_USERNAME", ""),
+		JiraAPIToken:   getEnv("JIRA_API_TOKEN", ""),
+		DefaultLocale:  getEnv("DEFAULT_LOCALE", "en"),
+		DirectoryPath:  getEnv("DIRECTORY_PATH", ""),
+		ControlsPath:   getEnv("CONTROLS_PATH", ""),
+		TelemetrySize:  getEnvInt("TELEMETRY_BUFFER_SIZE", 1000),
+		PrometheusURL:  getEnv("PROMETHEUS_URL", ""),
+		HealthTTL:      getEnvDuration("HEALTH_CACHE_TTL", 30*time.Second),
+		HealthHosts:    getEnvList("HEALTH_ALLOWED_HOSTS", nil),
+		K8sAPIURL:      getEnv("KUBERNETES_API_URL", ""),
+		K8sToken:       getEnv("KUBERNETES_TOKEN", ""),
+		GitRemote:      getEnv("GIT_SYNC_REMOTE", "origin"),
+		GitBranch:      getEnv("GIT_SYNC_BRANCH", ""),
+		GitInterval:    getEnvDuration("GIT_SYNC_INTERVAL", 0),
+		GitPush:        getEnv("GIT_SYNC_PUSH", "off"),
+		GitAuthor:      getEnv("GIT_SYNC_AUTHOR", "FlowGen <flowgen@localhost>"),
+		GitProvider:    getEnv("GIT_PROVIDER", ""),
+		GitToken:       getEnv("GIT_PROVIDER_TOKEN", ""),
+		GitAPIURL:      getEnv("GIT_PROVIDER_API_URL", ""),
+		SourcesPath:    getEnv("IMPORT_SOURCES_PATH", ""),
+		HooksSecret:    getEnv("HOOKS_SECRET", ""),
+		MetaSchemaPath: getEnv("META_SCHEMA_PATH", ""),
+		StyleThemePath: getEnv("STYLE_THEME_PATH", ""),
+		SaveHooksPath:  getEnv("SAVE_HOOKS_PATH", ""),
+		ReleasesPath:   getEnv("RELEASES_PATH", "./releases"),
+		ReportsPath:    getEnv("REPORT_SCHEDULES_PATH", ""),
+		SMTPHost:       getEnv("SMTP_HOST", ""),
+		SMTPPort:       getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:   getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:       getEnv("SMTP_FROM", "FlowGen <flowgen@localhost>"),
+
+		JiraOAuthClientID:     getEnv("JIRA_OAUTH_CLIENT_ID", ""),
+		JiraOAuthClientSecret: getEnv("JIRA_OAUTH_CLIENT_SECRET", ""),
+		JiraOAuthRedirectURL:  getEnv("JIRA_OAUTH_REDIRECT_URL", ""),
+		JiraOAuthScopes:       getEnv("JIRA_OAUTH_SCOPES", "read:jira-work write:jira-work read:jira-user offline_access"),
+		JiraOAuthTokenPath:    getEnv("JIRA_OAUTH_TOKEN_PATH", "./jira-oauth.json"),
+		JiraOAuthAuthURL:      getEnv("JIRA_OAUTH_AUTH_URL", "https://auth.atlassian.com"),
+		JiraOAuthAPIURL:       getEnv("JIRA_OAUTH_API_URL", "https://api.atlassian.com"),
+
+		UserHeader:                getEnv("USER_HEADER", "X-FlowGen-User"),
+		PreferencesPath:           getEnv("PREFERENCES_PATH", "./preferences"),
+		NotificationTemplatesPath: getEnv("NOTIFICATION_TEMPLATES_PATH", ""),
+		StaleAfter:                getEnvDuration("STALE_DIAGRAM_AGE", 0),
+		StaleSchedule:             getEnv("STALE_REMINDER_SCHEDULE", "0 9 * * 1"),
+
+		WorkspacesPath:   getEnv("WORKSPACES_PATH", "./workspaces"),
+		WorkspaceHeader:  getEnv("WORKSPACE_HEADER", "X-FlowGen-Workspace"),
+		DefaultWorkspace: getEnv("DEFAULT_WORKSPACE", "default"),
+
+		QuotaMaxNodes:     getEnvCount("QUOTA_MAX_NODES", 0),
+		QuotaMaxEdges:     getEnvCount("QUOTA_MAX_EDGES", 0),
+		QuotaMaxYAMLBytes: getEnvCount("QUOTA_MAX_YAML_BYTES", 2<<20),
+		QuotaMaxDiagrams:  getEnvCount("QUOTA_MAX_DIAGRAMS", 0),
+
+		WasmPluginsPath: getEnv("WASM_PLUGINS_PATH", ""),
+
+		ConsistencyInterval:   getEnvDuration("CONSISTENCY_INTERVAL", 0),
+		ConsistencyReportPath: getEnv("CONSISTENCY_REPORT_PATH", "./consistency-report.json"),
+
+		DiagramsRootName:     getEnv("DIAGRAMS_ROOT_NAME", ""),
+		DiagramRoots:         getEnvList("DIAGRAM_ROOTS", nil),
+		DiagramReadOnlyRoots: getEnvList("DIAGRAM_READONLY_ROOTS", nil),
+		DiagramWalkIgnore:    getEnvList("DIAGRAM_WALK_IGNORE", []string{".git", "node_modules", ".trash"}),
+
+		DiagramWatchInterval: getEnvDuration("DIAGRAM_WATCH_INTERVAL", 2*time.Second),
+
+		DiagramLoadErrorLog: getEnv("DIAGRAM_LOAD_ERROR_LOG", "new"),
+		DiagramLoading:      getEnv("DIAGRAM_LOADING", "lenient"),
+
+		DiagramNaming:  getEnv("DIAGRAM_NAMING", "any"),
+		DiagramFolders: getEnv("DIAGRAM_FOLDERS", "flat"),
+
+		ChangelogLimit: getEnvCount("DIAGRAM_CHANGELOG_LIMIT", 20),
+
+		ValidationWebhookURL:     getEnv("VALIDATION_WEBHOOK_URL", ""),
+		ValidationWebhookTimeout: getEnvDuration("VALIDATION_WEBHOOK_TIMEOUT", 5*time.Second),
+		ValidationWebhookFailure: getEnv("VALIDATION_WEBHOOK_FAILURE", "closed"),
+
+		AccessLogPath:      getEnv("ACCESS_LOG_PATH", ""),
+		AccessLogRetention: getEnvDuration("ACCESS_LOG_RETENTION", 30*24*time.Hour),
+		AccessLogViewers:   getEnv("ACCESS_LOG_VIEWERS", "hash"),
+		AccessLogSalt:      getEnv("ACCESS_LOG_SALT", ""),
+
+		NodeCatalogPath: getEnv("NODE_CATALOG_PATH", ""),
+
+		GlossaryPath: getEnv("GLOSSARY_PATH", "./glossary.yaml"),
+
+		TerminologyPath: getEnv("TERMINOLOGY_PATH", ""),
+
+		SimplifyWaypointsAbove: getEnvInt("SIMPLIFY_WAYPOINTS_ABOVE", 0),
+
+		SeedDir: getEnv("FLOWGEN_SEED_DIR", ""),
 	}
 }
 
@@ -34,3 +238,40 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvCount reads a limit or count for which 0 is meaningful, such as
+// "none" or "unlimited"; only negative and malformed values fall back
+func getEnvCount(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,38 @@
+package models
+
+// CatalogNode is a canonical node, such as a shared system or team, that
+// diagram nodes reuse by referencing its ID
+type CatalogNode struct {
+	ID          string                 `json:"id" yaml:"id"`
+	Name        string                 `json:"name" yaml:"name"`
+	Type        NodeType               `json:"type,omitempty" yaml:"type,omitempty"`
+	Description *string                `json:"description,omitempty" yaml:"description,omitempty"`
+	Style       *Style                 `json:"style,omitempty" yaml:"style,omitempty"` // Default style; fields set on the node win
+	Metadata    map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Tags        []string               `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// NodeCatalog lists the canonical nodes that diagram nodes may reference
+type NodeCatalog struct {
+	Nodes []CatalogNode `json:"nodes" yaml:"nodes"`
+}
+
+// Find returns the catalog node with the given ID
+func (c *NodeCatalog) Find(id string) (*CatalogNode, bool) {
+	for i := range c.Nodes {
+		if c.Nodes[i].ID == id {
+			return &c.Nodes[i], true
+		}
+	}
+	return nil, false
+}
+
+// CatalogUsage is a diagram node referencing a catalog node
+type CatalogUsage struct {
+	DiagramID   string   `json:"diagramId"`
+	DiagramName string   `json:"diagramName"`
+	NodeID      string   `json:"nodeId"`
+	NodeName    string   `json:"nodeName"`
+	Archived    bool     `json:"archived,omitempty"`
+	Drift       []string `json:"drift,omitempty"` // Fields that differ from the catalog node
+}
@@ -0,0 +1,41 @@
+package models
+
+// Control is an entry in the compliance control catalog
+type Control struct {
+	ID          string  `json:"id" yaml:"id"`
+	Framework   string  `json:"framework" yaml:"framework"` // e.g. SOX, ISO27001
+	Name        string  `json:"name" yaml:"name"`
+	Description *string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// ControlCatalog lists the controls that nodes may reference
+type ControlCatalog struct {
+	Controls []Control `json:"controls" yaml:"controls"`
+}
+
+// Find returns the catalog control with the given ID
+func (c *ControlCatalog) Find(id string) (*Control, bool) {
+	for i := range c.Controls {
+		if c.Controls[i].ID == id {
+			return &c.Controls[i], true
+		}
+	}
+	return nil, false
+}
+
+// ControlStep is a process step that implements a control
+type ControlStep struct {
+	DiagramID   string  `json:"diagramId"`
+	DiagramName string  `json:"diagramName"`
+	NodeID      string  `json:"nodeId"`
+	NodeName    string  `json:"nodeName"`
+	Evidence    *string `json:"evidence,omitempty"`
+}
+
+// ControlMatrixRow maps one control to the process steps implementing it
+type ControlMatrixRow struct {
+	ControlID string        `json:"controlId"`
+	Framework string        `json:"framework"`
+	Name      string        `json:"name,omitempty"`
+	Steps     []ControlStep `json:"steps"`
+}
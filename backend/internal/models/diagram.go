@@ -2,13 +2,82 @@ package models
 
 import "time"
 
-// FlowEntity represents the base entity with common properties
+// LocalizedText holds per-locale variants of a text field keyed by language tag
+type LocalizedText map[string]string
+
+// FlowEntity represents the base entity with common properties.
+// Name and Description hold the default-locale text; per-locale variants
+// written in YAML as `name: {en: ..., de: ...}` are kept in Translations.
 type FlowEntity struct {
-	ID          string                 `json:"id" yaml:"id"`
-	Name        string                 `json:"name" yaml:"name"`
-	Description *string                `json:"description,omitempty" yaml:"description,omitempty"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
-	Tags        []string               `json:"tags,omitempty" yaml:"tags,omitempty"`
+	ID           string                   `json:"id" yaml:"id"`
+	Name         string                   `json:"name" yaml:"name"`
+	Description  *string                  `json:"description,omitempty" yaml:"description,omitempty"`
+	Metadata     map[string]interface{}   `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Tags         []string                 `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Translations map[string]LocalizedText `json:"translations,omitempty" yaml:"translations,omitempty"` // Field name to locale variants
+	Ownership    *Ownership               `json:"ownership,omitempty" yaml:"ownership,omitempty"`
+}
+
+// Ownership roles
+const (
+	RoleOwner       = "owner"
+	RoleResponsible = "responsible"
+	RoleAccountable = "accountable"
+	RoleConsulted   = "consulted"
+	RoleInformed    = "informed"
+)
+
+// OwnershipRoles lists the ownership roles in RACI order
+var OwnershipRoles = []string{RoleOwner, RoleResponsible, RoleAccountable, RoleConsulted, RoleInformed}
+
+// Ownership records who owns an element and its RACI assignments. Values
+// are person or team IDs from the configured directory.
+type Ownership struct {
+	Owner       string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Responsible []string `json:"responsible,omitempty" yaml:"responsible,omitempty"`
+	Accountable string   `json:"accountable,omitempty" yaml:"accountable,omitempty"`
+	Consulted   []string `json:"consulted,omitempty" yaml:"consulted,omitempty"`
+	Informed    []string `json:"informed,omitempty" yaml:"informed,omitempty"`
+}
+
+// Assignments returns the IDs assigned to a role
+func (o *Ownership) Assignments(role string) []string {
+	if o == nil {
+		return nil
+	}
+	single := func(id string) []string {
+		if id == "" {
+			return nil
+		}
+		return []string{id}
+	}
+	switch role {
+	case RoleOwner:
+		return single(o.Owner)
+	case RoleResponsible:
+		return o.Responsible
+	case RoleAccountable:
+		return single(o.Accountable)
+	case RoleConsulted:
+		return o.Consulted
+	case RoleInformed:
+		return o.Informed
+	}
+	return nil
+}
+
+// Roles returns the roles held by a person or team ID
+func (o *Ownership) Roles(id string) []string {
+	var roles []string
+	for _, role := range OwnershipRoles {
+		for _, assigned := range o.Assignments(role) {
+			if assigned == id {
+				roles = append(roles, role)
+				break
+			}
+		}
+	}
+	return roles
 }
 
 // Position represents X,Y coordinates
@@ -23,17 +92,46 @@ type Dimensions struct {
 	Height float64 `json:"height" yaml:"height"`
 }
 
-// Style represents visual styling options
+// Style represents visual styling options. Ref names a style defined in
+// the diagram's styles or the style theme; fields set alongside it
+// override the named style.
 type Style struct {
-	Fill            *string  `json:"fill,omitempty" yaml:"fill,omitempty"`
-	Stroke          *string  `json:"stroke,omitempty" yaml:"stroke,omitempty"`
-	StrokeWidth     *float64 `json:"strokeWidth,omitempty" yaml:"strokeWidth,omitempty"`
-	StrokeDasharray *string  `json:"strokeDasharray,omitempty" yaml:"strokeDasharray,omitempty"`
-	Opacity         *float64 `json:"opacity,omitempty" yaml:"opacity,omitempty"`
-	FontSize        *float64 `json:"fontSize,omitempty" yaml:"fontSize,omitempty"`
-	FontFamily      *string  `json:"fontFamily,omitempty" yaml:"fontFamily,omitempty"`
-	FontWeight      *string  `json:"fontWeight,omitempty" yaml:"fontWeight,omitempty"`
-	TextColor       *string  `json:"textColor,omitempty" yaml:"textColor,omitempty"`
+	Ref             string     `json:"ref,omitempty" yaml:"ref,omitempty"`
+	Fill            *string    `json:"fill,omitempty" yaml:"fill,omitempty"`
+	Stroke          *string    `json:"stroke,omitempty" yaml:"stroke,omitempty"`
+	StrokeWidth     *float64   `json:"strokeWidth,omitempty" yaml:"strokeWidth,omitempty"`
+	StrokeDasharray *string    `json:"strokeDasharray,omitempty" yaml:"strokeDasharray,omitempty"`
+	Opacity         *float64   `json:"opacity,omitempty" yaml:"opacity,omitempty"`
+	FontSize        *float64   `json:"fontSize,omitempty" yaml:"fontSize,omitempty"`
+	FontFamily      *string    `json:"fontFamily,omitempty" yaml:"fontFamily,omitempty"`
+	FontWeight      *string    `json:"fontWeight,omitempty" yaml:"fontWeight,omitempty"`
+	TextColor       *string    `json:"textColor,omitempty" yaml:"textColor,omitempty"`
+	ArrowStart      *Arrowhead `json:"arrowStart,omitempty" yaml:"arrowStart,omitempty"` // Edges only; none by default
+	ArrowEnd        *Arrowhead `json:"arrowEnd,omitempty" yaml:"arrowEnd,omitempty"`     // Edges only; a triangle by default
+}
+
+// Arrowhead types
+const (
+	ArrowheadNone     = "none"
+	ArrowheadTriangle = "triangle"
+	ArrowheadOpen     = "open" // Unfilled V
+	ArrowheadDiamond  = "diamond"
+	ArrowheadCircle   = "circle"
+	ArrowheadBar      = "bar"
+)
+
+// ArrowheadTypes lists the arrowhead types
+var ArrowheadTypes = []string{ArrowheadNone, ArrowheadTriangle, ArrowheadOpen, ArrowheadDiamond, ArrowheadCircle, ArrowheadBar}
+
+// Arrowhead configures the marker at one end of an edge
+type Arrowhead struct {
+	Type string   `json:"type" yaml:"type"`
+	Size *float64 `json:"size,omitempty" yaml:"size,omitempty"` // Length in diagram units
+}
+
+// StyleTheme holds named styles shared by all diagrams
+type StyleTheme struct {
+	Styles map[string]Style `json:"styles" yaml:"styles"`
 }
 
 // NodeType represents different types of nodes
@@ -50,6 +148,15 @@ const (
 	NodeTypeCustom     NodeType = "custom"
 )
 
+// Valid reports whether t is one of the known node types
+func (t NodeType) Valid() bool {
+	switch t {
+	case NodeTypeProcess, NodeTypeDecision, NodeTypeStart, NodeTypeEnd, NodeTypeSubprocess, NodeTypeData, NodeTypeExternal, NodeTypeCustom:
+		return true
+	}
+	return false
+}
+
 // ConnectionType represents different types of connections
 type ConnectionType string
 
@@ -68,32 +175,109 @@ type JiraIntegration struct {
 	ProjectKey *string `json:"projectKey,omitempty" yaml:"projectKey,omitempty"`
 }
 
+// HealthIntegration points a node at the service it represents so its
+// live status can be shown. Set either URL or Kubernetes.
+type HealthIntegration struct {
+	URL        *string           `json:"url,omitempty" yaml:"url,omitempty"` // HTTP health endpoint
+	Kubernetes *KubernetesTarget `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty"`
+}
+
+// KubernetesTarget identifies a deployment whose replica readiness gives
+// the node status
+type KubernetesTarget struct {
+	Namespace  string `json:"namespace,omitempty" yaml:"namespace,omitempty"` // Defaults to "default"
+	Deployment string `json:"deployment" yaml:"deployment"`
+}
+
 // Integrations represents external system integrations
 type Integrations struct {
 	Jira   *JiraIntegration       `json:"jira,omitempty" yaml:"jira,omitempty"`
+	Health *HealthIntegration     `json:"health,omitempty" yaml:"health,omitempty"`
 	Custom map[string]interface{} `json:"custom,omitempty" yaml:"custom,omitempty"`
 }
 
 // FlowNode represents a node in the flow diagram
 type FlowNode struct {
-	FlowEntity   `yaml:",inline"`
-	Type         NodeType      `json:"type" yaml:"type"`
-	Position     Position      `json:"position" yaml:"position"`
-	Dimensions   *Dimensions   `json:"dimensions,omitempty" yaml:"dimensions,omitempty"`
-	Style        *Style        `json:"style,omitempty" yaml:"style,omitempty"`
-	DrillDown    *string       `json:"drillDown,omitempty" yaml:"drillDown,omitempty"`
-	Integrations *Integrations `json:"integrations,omitempty" yaml:"integrations,omitempty"`
+	FlowEntity     `yaml:",inline"`
+	Type           NodeType        `json:"type" yaml:"type"`
+	Position       Position        `json:"position" yaml:"position"`
+	Dimensions     *Dimensions     `json:"dimensions,omitempty" yaml:"dimensions,omitempty"`
+	Pinned         bool            `json:"pinned,omitempty" yaml:"pinned,omitempty"` // Position and size are kept by automated layout and sizing
+	ZIndex         int             `json:"zIndex,omitempty" yaml:"zIndex,omitempty"` // Stacking order; higher is drawn on top
+	Style          *Style          `json:"style,omitempty" yaml:"style,omitempty"`
+	DrillDown      *string         `json:"drillDown,omitempty" yaml:"drillDown,omitempty"`
+	DrillDownFocus *DrillDownFocus `json:"drillDownFocus,omitempty" yaml:"drillDownFocus,omitempty"` // Where drilling down lands in the target diagram
+	Catalog        *string         `json:"catalog,omitempty" yaml:"catalog,omitempty"`               // ID of the catalog node this node stands for
+	Integrations   *Integrations   `json:"integrations,omitempty" yaml:"integrations,omitempty"`
+	Layers         []string        `json:"layers,omitempty" yaml:"layers,omitempty"`
+	Controls       []ControlRef    `json:"controls,omitempty" yaml:"controls,omitempty"`
+	Duration       *Duration       `json:"duration,omitempty" yaml:"duration,omitempty"`
+	SLA            *string         `json:"sla,omitempty" yaml:"sla,omitempty"` // Maximum allowed duration
+	Cost           *Cost           `json:"cost,omitempty" yaml:"cost,omitempty"`
+}
+
+// DrillDownFocus is where drilling down into a node lands in the target
+// diagram, so a large child opens at the relevant step rather than fitted
+// to the screen. Any combination of the fields may be set.
+type DrillDownFocus struct {
+	Node     string    `json:"node,omitempty" yaml:"node,omitempty"`         // Node to select and center
+	Layer    string    `json:"layer,omitempty" yaml:"layer,omitempty"`       // Layer to show
+	Viewport *Viewport `json:"viewport,omitempty" yaml:"viewport,omitempty"` // Area to show; a focused node is centered within it
+}
+
+// Viewport is a visible area of a diagram: its center in diagram
+// coordinates and a zoom factor
+type Viewport struct {
+	X    float64 `json:"x" yaml:"x"`
+	Y    float64 `json:"y" yaml:"y"`
+	Zoom float64 `json:"zoom,omitempty" yaml:"zoom,omitempty"` // Defaults to 1
+}
+
+// Cost is the cost of executing a step once
+type Cost struct {
+	Amount   float64 `json:"amount" yaml:"amount"`
+	Currency string  `json:"currency,omitempty" yaml:"currency,omitempty"` // ISO 4217 code, e.g. USD
+}
+
+// Duration is the expected time spent on a step or transition, given as
+// best and worst case (e.g. "30m", "2h", "1d12h"). A single bound is used
+// for both. Distribution shapes how simulations sample between the bounds.
+type Duration struct {
+	Min          string `json:"min,omitempty" yaml:"min,omitempty"`
+	Max          string `json:"max,omitempty" yaml:"max,omitempty"`
+	Distribution string `json:"distribution,omitempty" yaml:"distribution,omitempty"` // uniform (default), triangular or fixed
+	Mode         string `json:"mode,omitempty" yaml:"mode,omitempty"`                 // Most likely value for triangular
+}
+
+// Duration distributions
+const (
+	DistributionUniform    = "uniform"
+	DistributionTriangular = "triangular"
+	DistributionFixed      = "fixed"
+)
+
+// ControlRef attaches a compliance control from the control catalog to a
+// process step
+type ControlRef struct {
+	ID        string  `json:"id" yaml:"id"`
+	Framework string  `json:"framework,omitempty" yaml:"framework,omitempty"` // e.g. SOX, ISO27001
+	Evidence  *string `json:"evidence,omitempty" yaml:"evidence,omitempty"`   // Link to evidence
 }
 
 // FlowEdge represents an edge/connection in the flow diagram
 type FlowEdge struct {
-	FlowEntity `yaml:",inline"`
-	Type       ConnectionType `json:"type" yaml:"type"`
-	From       string         `json:"from" yaml:"from"`
-	To         string         `json:"to" yaml:"to"`
-	Condition  *string        `json:"condition,omitempty" yaml:"condition,omitempty"`
-	Style      *Style         `json:"style,omitempty" yaml:"style,omitempty"`
-	Waypoints  []Position     `json:"waypoints,omitempty" yaml:"waypoints,omitempty"`
+	FlowEntity      `yaml:",inline"`
+	Type            ConnectionType `json:"type" yaml:"type"`
+	From            string         `json:"from" yaml:"from"`
+	To              string         `json:"to" yaml:"to"`
+	Condition       *string        `json:"condition,omitempty" yaml:"condition,omitempty"`
+	Style           *Style         `json:"style,omitempty" yaml:"style,omitempty"`
+	Waypoints       []Position     `json:"waypoints,omitempty" yaml:"waypoints,omitempty"`
+	Layers          []string       `json:"layers,omitempty" yaml:"layers,omitempty"`
+	Duration        *Duration      `json:"duration,omitempty" yaml:"duration,omitempty"` // Wait or hand-off time
+	SLA             *string        `json:"sla,omitempty" yaml:"sla,omitempty"`
+	Probability     *float64       `json:"probability,omitempty" yaml:"probability,omitempty"`         // Chance this branch is taken (0-1)
+	RoutingPriority int            `json:"routingPriority,omitempty" yaml:"routingPriority,omitempty"` // Higher is drawn over overlapping edges
 }
 
 // LayoutDirection represents diagram layout direction
@@ -106,6 +290,15 @@ const (
 	LayoutDirectionRightLeft LayoutDirection = "right-left"
 )
 
+// Valid reports whether d is one of the known layout directions
+func (d LayoutDirection) Valid() bool {
+	switch d {
+	case LayoutDirectionTopBottom, LayoutDirectionBottomTop, LayoutDirectionLeftRight, LayoutDirectionRightLeft:
+		return true
+	}
+	return false
+}
+
 // LayoutSpacing represents spacing configuration
 type LayoutSpacing struct {
 	Node *float64 `json:"node,omitempty" yaml:"node,omitempty"`
@@ -118,18 +311,122 @@ type Layout struct {
 	Spacing   *LayoutSpacing   `json:"spacing,omitempty" yaml:"spacing,omitempty"`
 }
 
+// Layer represents a named, toggleable group of nodes and edges. Elements
+// without layers belong to the base diagram and are always shown.
+type Layer struct {
+	ID          string  `json:"id" yaml:"id"`
+	Name        string  `json:"name" yaml:"name"`
+	Description *string `json:"description,omitempty" yaml:"description,omitempty"`
+	Visible     *bool   `json:"visible,omitempty" yaml:"visible,omitempty"` // Defaults to true
+}
+
+// IsVisible reports whether the layer is shown by default
+func (l Layer) IsVisible() bool {
+	return l.Visible == nil || *l.Visible
+}
+
+// RelationType represents the kind of relation between two diagrams
+type RelationType string
+
+const (
+	RelationRelatedTo  RelationType = "relatedTo"
+	RelationDependsOn  RelationType = "dependsOn"
+	RelationSupersedes RelationType = "supersedes"
+	RelationVariantOf  RelationType = "variantOf"
+)
+
+// Relation is a typed link from a diagram to another diagram outside the
+// parent/child hierarchy
+type Relation struct {
+	Type        RelationType `json:"type" yaml:"type"`
+	Target      string       `json:"target" yaml:"target"` // ID of the related diagram
+	Description *string      `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Deprecation marks a diagram as no longer maintained
+type Deprecation struct {
+	Since        time.Time `json:"since" yaml:"since"`
+	Reason       *string   `json:"reason,omitempty" yaml:"reason,omitempty"`
+	SupersededBy *string   `json:"supersededBy,omitempty" yaml:"supersededBy,omitempty"` // ID of the successor diagram
+}
+
+// Provenance methods
+const (
+	ProvenanceHandAuthored = "hand-authored"
+	ProvenanceImported     = "imported"  // Converted from a source document, e.g. Terraform or BPMN
+	ProvenanceGenerated    = "generated" // Generated by AI
+)
+
+// Provenance records how a diagram was produced. For imported and generated
+// diagrams, ContentChecksum covers the elements as produced so that manual
+// edits, which regeneration would overwrite, can be detected.
+type Provenance struct {
+	Method          string    `json:"method" yaml:"method"`
+	Format          string    `json:"format,omitempty" yaml:"format,omitempty"`       // Source format, e.g. terraform, bpmn or drawio
+	Source          string    `json:"source,omitempty" yaml:"source,omitempty"`       // URL or path of the source document
+	Generator       string    `json:"generator,omitempty" yaml:"generator,omitempty"` // Tool or model that generated the diagram
+	SourceChecksum  string    `json:"sourceChecksum,omitempty" yaml:"sourceChecksum,omitempty"`
+	ContentChecksum string    `json:"contentChecksum,omitempty" yaml:"contentChecksum,omitempty"`
+	ProducedAt      time.Time `json:"producedAt" yaml:"producedAt"`
+}
+
+// ChangelogEntry records one save of a diagram
+type ChangelogEntry struct {
+	Time    time.Time `json:"time" yaml:"time"`
+	Actor   string    `json:"actor,omitempty" yaml:"actor,omitempty"`     // Directory ID of whoever saved, when known
+	Summary string    `json:"summary" yaml:"summary"`                     // Derived from the semantic diff
+	Message string    `json:"message,omitempty" yaml:"message,omitempty"` // Change summary given by the client
+}
+
 // FlowDiagram represents a complete flow diagram
 type FlowDiagram struct {
 	FlowEntity `yaml:",inline"`
-	Version    string     `json:"version" yaml:"version"`
-	Nodes      []FlowNode `json:"nodes" yaml:"nodes"`
-	Edges      []FlowEdge `json:"edges" yaml:"edges"`
-	Layout     *Layout    `json:"layout,omitempty" yaml:"layout,omitempty"`
-	Parent     *string    `json:"parent,omitempty" yaml:"parent,omitempty"`
-	Children   []string   `json:"children,omitempty" yaml:"children,omitempty"`
-	Created    time.Time  `json:"created" yaml:"created"`
-	Updated    time.Time  `json:"updated" yaml:"updated"`
-	FilePath   string     `json:"filePath,omitempty" yaml:"-"` // Internal use only
+	Version    string                 `json:"version" yaml:"version"`
+	Meta       map[string]interface{} `json:"meta,omitempty" yaml:"meta,omitempty"`     // Governed fields checked against the workspace meta schema
+	Styles     map[string]Style       `json:"styles,omitempty" yaml:"styles,omitempty"` // Named styles; override theme styles of the same name
+	Nodes      []FlowNode             `json:"nodes" yaml:"nodes"`
+	Edges      []FlowEdge             `json:"edges" yaml:"edges"`
+	Layout     *Layout                `json:"layout,omitempty" yaml:"layout,omitempty"`
+	Layers     []Layer                `json:"layers,omitempty" yaml:"layers,omitempty"`
+	Parent     *string                `json:"parent,omitempty" yaml:"parent,omitempty"`   // Primary parent, followed by breadcrumbs and exports
+	Parents    []string               `json:"parents,omitempty" yaml:"parents,omitempty"` // Further parents drilling into a shared sub-flow
+	Children   []string               `json:"children,omitempty" yaml:"children,omitempty"`
+	Relations  []Relation             `json:"relations,omitempty" yaml:"relations,omitempty"`
+	Deprecated *Deprecation           `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Provenance *Provenance            `json:"provenance,omitempty" yaml:"provenance,omitempty"`
+	Changelog  []ChangelogEntry       `json:"changelog,omitempty" yaml:"changelog,omitempty"`   // Maintained on save; recent changes, oldest first
+	Validation *ValidationStatus      `json:"validation,omitempty" yaml:"validation,omitempty"` // Recorded on save and on explicit validation
+	Created    time.Time              `json:"created" yaml:"created"`
+	Updated    time.Time              `json:"updated" yaml:"updated"`
+	FilePath   string                 `json:"filePath,omitempty" yaml:"-"` // Internal use only
+
+	// ChangedExternally is when the diagram's file was last changed outside
+	// the API, unless the API has written it since; editors holding an older
+	// copy should offer to reload before saving over it
+	ChangedExternally *time.Time `json:"changedExternally,omitempty" yaml:"-"`
+	// ContentHash identifies the stored version; sent back on update, the
+	// save is refused if the diagram changed since
+	ContentHash string `json:"contentHash,omitempty" yaml:"-"`
+	// ChangeSummary describes a submitted change; it goes into the
+	// changelog, the Git commit, notifications and post-save hooks and is
+	// not stored
+	ChangeSummary string `json:"changeSummary,omitempty" yaml:"-"`
+	// Archived diagrams are stored in the archive area and left out of
+	// lists and searches by default
+	Archived bool `json:"archived,omitempty" yaml:"-"`
+	// Root names the diagram root the diagram was loaded from; diagrams in
+	// a read-only root cannot be saved, moved or deleted
+	Root     string `json:"root,omitempty" yaml:"-"`
+	ReadOnly bool   `json:"readOnly,omitempty" yaml:"-"`
+	// Legacy lists the deprecated fields the diagram was loaded with; they
+	// are upgraded on load and written in their current form on save
+	Legacy []LegacyField `json:"-" yaml:"-"`
+}
+
+// LegacyField is a deprecated field found in diagram YAML
+type LegacyField struct {
+	Path        string // Where it was found, e.g. nodes[2].size
+	Replacement string // The field that replaces it, e.g. nodes[2].dimensions
 }
 
 // ValidationError represents a validation error
@@ -147,6 +444,24 @@ type ValidationResult struct {
 	Warnings []ValidationError `json:"warnings,omitempty"`
 }
 
+// Validation statuses of a diagram
+const (
+	ValidationValid    = "valid"    // No errors or warnings
+	ValidationWarnings = "warnings" // Warnings only
+	ValidationErrors   = "errors"
+)
+
+// ValidationStatus is the outcome of the latest validation of a stored
+// diagram. RulesVersion identifies the built-in rules it was checked
+// against, so that outcomes from older rules can be told apart.
+type ValidationStatus struct {
+	Status       string    `json:"status" yaml:"status"` // valid, warnings or errors
+	Errors       int       `json:"errors" yaml:"errors"`
+	Warnings     int       `json:"warnings" yaml:"warnings"`
+	CheckedAt    time.Time `json:"checkedAt" yaml:"checkedAt"`
+	RulesVersion int       `json:"rulesVersion" yaml:"rulesVersion"`
+}
+
 // SearchResult represents a search result item
 type SearchResult struct {
 	Diagram   FlowDiagram `json:"diagram"`
@@ -154,11 +469,87 @@ type SearchResult struct {
 	MatchType string      `json:"matchType"` // "name", "description", "tag", "node", etc.
 }
 
-// NodeSearchResult represents a node search result
+// NodeSearchResult represents a node search result. The parent diagram is
+// described by a summary; the full diagram is only attached on request.
 type NodeSearchResult struct {
-	Node      FlowNode    `json:"node"`
-	DiagramID string      `json:"diagramId"`
-	Diagram   FlowDiagram `json:"diagram"`
-	Score     float64     `json:"score"`
-	MatchType string      `json:"matchType"`
+	Node           FlowNode        `json:"node"`
+	DiagramID      string          `json:"diagramId"`
+	DiagramSummary *DiagramSummary `json:"diagramSummary,omitempty"`
+	Diagram        *FlowDiagram    `json:"diagram,omitempty"`
+	Score          float64         `json:"score"`
+	MatchType      string          `json:"matchType"`
+}
+
+// DiagramSummary is a lightweight view of a diagram used where the full
+// node and edge lists are not needed
+type DiagramSummary struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description *string       `json:"description,omitempty"`
+	Version     string        `json:"version"`
+	Tags        []string      `json:"tags,omitempty"`
+	Parent      *string       `json:"parent,omitempty"`
+	Parents     []string      `json:"parents,omitempty"`
+	Deprecated  bool          `json:"deprecated,omitempty"`
+	Archived    bool          `json:"archived,omitempty"`
+	NodeCount   int           `json:"nodeCount"`
+	EdgeCount   int           `json:"edgeCount"`
+	Updated     time.Time     `json:"updated"`
+	Stats       *DiagramStats `json:"stats,omitempty"` // Set in diagram lists
+}
+
+// DiagramStats are figures computed from a diagram for catalog views to
+// sort and filter by
+type DiagramStats struct {
+	MaxDepth   int    `json:"maxDepth"`   // Nodes on the longest path, ignoring loops
+	Validation string `json:"validation"` // valid, warnings or errors
+	Errors     int    `json:"errors"`
+	Warnings   int    `json:"warnings"`
+}
+
+// ParentIDs returns the primary parent followed by the further parents of a
+// shared sub-flow
+func (d *FlowDiagram) ParentIDs() []string {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if d.Parent != nil {
+		add(*d.Parent)
+	}
+	for _, id := range d.Parents {
+		add(id)
+	}
+	return ids
+}
+
+// Summary returns the summary view of the diagram
+func (d *FlowDiagram) Summary() DiagramSummary {
+	return DiagramSummary{
+		ID:          d.ID,
+		Name:        d.Name,
+		Description: d.Description,
+		Version:     d.Version,
+		Tags:        d.Tags,
+		Parent:      d.Parent,
+		Parents:     d.Parents,
+		Deprecated:  d.Deprecated != nil,
+		Archived:    d.Archived,
+		NodeCount:   len(d.Nodes),
+		EdgeCount:   len(d.Edges),
+		Updated:     d.Updated,
+	}
+}
+
+// ContentSearchResult represents a match in the raw YAML text of a diagram file
+type ContentSearchResult struct {
+	DiagramID string `json:"diagramId,omitempty"`
+	FilePath  string `json:"filePath"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Text      string `json:"text"`
 }
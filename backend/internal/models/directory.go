@@ -0,0 +1,36 @@
+package models
+
+// Person is an individual who can own or be assigned to process steps
+type Person struct {
+	ID    string  `json:"id" yaml:"id"`
+	Name  string  `json:"name" yaml:"name"`
+	Email *string `json:"email,omitempty" yaml:"email,omitempty"`
+}
+
+// Team is a named group of people that can be assigned as a whole
+type Team struct {
+	ID      string   `json:"id" yaml:"id"`
+	Name    string   `json:"name" yaml:"name"`
+	Members []string `json:"members,omitempty" yaml:"members,omitempty"` // Person IDs
+}
+
+// Directory lists the people and teams that ownership fields may reference
+type Directory struct {
+	People []Person `json:"people" yaml:"people"`
+	Teams  []Team   `json:"teams" yaml:"teams"`
+}
+
+// Has reports whether id names a person or team in the directory
+func (d *Directory) Has(id string) bool {
+	for _, p := range d.People {
+		if p.ID == id {
+			return true
+		}
+	}
+	for _, t := range d.Teams {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
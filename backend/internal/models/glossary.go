@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// GlossaryTerm is a term with its definition. Aliases are other spellings
+// or abbreviations that are detected as the same term.
+type GlossaryTerm struct {
+	ID         string    `json:"id" yaml:"id"`
+	Term       string    `json:"term" yaml:"term" binding:"required"`
+	Definition string    `json:"definition" yaml:"definition" binding:"required"`
+	Aliases    []string  `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Link       *string   `json:"link,omitempty" yaml:"link,omitempty"` // Further reading
+	Updated    time.Time `json:"updated" yaml:"updated"`
+}
+
+// Glossary is the workspace's list of terms
+type Glossary struct {
+	Terms []GlossaryTerm `json:"terms" yaml:"terms"`
+}
+
+// TermAnnotation marks an occurrence of a glossary term in a description.
+// Start and End are UTF-16 offsets, so they can be used as JavaScript
+// string indices.
+type TermAnnotation struct {
+	Path   string `json:"path"`             // description or nodes[i].description
+	NodeID string `json:"nodeId,omitempty"` // Set for node descriptions
+	TermID string `json:"termId"`
+	Text   string `json:"text"` // The matched text as written
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
@@ -0,0 +1,39 @@
+package models
+
+// Meta schema enforcement policies
+const (
+	MetaPolicyReject = "reject" // Violations are errors and block saving
+	MetaPolicyWarn   = "warn"   // Violations are reported as warnings
+)
+
+// Meta field types
+const (
+	MetaTypeString  = "string"
+	MetaTypeNumber  = "number"
+	MetaTypeInteger = "integer"
+	MetaTypeBoolean = "boolean"
+	MetaTypeDate    = "date" // YYYY-MM-DD
+	MetaTypeList    = "list"
+	MetaTypeObject  = "object"
+)
+
+// MetaField describes one field of the diagram meta section
+type MetaField struct {
+	Type        string   `json:"type,omitempty" yaml:"type,omitempty"` // Any type when empty
+	Required    bool     `json:"required,omitempty" yaml:"required,omitempty"`
+	Enum        []string `json:"enum,omitempty" yaml:"enum,omitempty"`       // Allowed values for strings
+	Pattern     string   `json:"pattern,omitempty" yaml:"pattern,omitempty"` // Regular expression for strings
+	Description *string  `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// MetaSchema is the workspace schema for diagram meta sections
+type MetaSchema struct {
+	Policy          string               `json:"policy" yaml:"policy"` // reject (default) or warn
+	Fields          map[string]MetaField `json:"fields" yaml:"fields"`
+	AllowAdditional *bool                `json:"allowAdditional,omitempty" yaml:"allowAdditional,omitempty"` // Defaults to true
+}
+
+// Rejects reports whether violations block saving
+func (s *MetaSchema) Rejects() bool {
+	return s.Policy != MetaPolicyWarn
+}
@@ -0,0 +1,52 @@
+package models
+
+// Notification events
+const (
+	EventDiagramChanged     = "diagram_changed"     // A subscribed diagram was saved
+	EventDiagramDeleted     = "diagram_deleted"     // A subscribed diagram was deleted
+	EventReviewRequested    = "review_requested"    // Someone asked for a review
+	EventStaleDiagram       = "stale_diagram"       // An owned diagram has not been updated for a while
+	EventConsistencyChanged = "consistency_changed" // The error count of an owned or followed diagram changed
+	EventDiagramUnreadable  = "diagram_unreadable"  // The file of an owned or followed diagram no longer parses
+)
+
+// NotificationEvents lists all notification events
+var NotificationEvents = []string{EventDiagramChanged, EventDiagramDeleted, EventReviewRequested, EventStaleDiagram, EventConsistencyChanged, EventDiagramUnreadable}
+
+// NotificationPreferences controls which notifications a person receives
+type NotificationPreferences struct {
+	Email         bool     `json:"email" yaml:"email"`                 // Deliver notifications by email
+	Events        []string `json:"events" yaml:"events"`               // Events to receive
+	Subscriptions []string `json:"subscriptions" yaml:"subscriptions"` // Diagram IDs whose changes are sent
+}
+
+// Editor themes
+const (
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system" // Follow the operating system
+)
+
+// Themes lists the editor themes
+var Themes = []string{ThemeLight, ThemeDark, ThemeSystem}
+
+// UserPreferences are the settings stored for a person in the directory,
+// so that they follow the person across machines
+type UserPreferences struct {
+	LayoutDirection  *LayoutDirection        `json:"layoutDirection,omitempty" yaml:"layoutDirection,omitempty"` // For new diagrams
+	Theme            string                  `json:"theme,omitempty" yaml:"theme,omitempty"`
+	DefaultWorkspace string                  `json:"defaultWorkspace,omitempty" yaml:"defaultWorkspace,omitempty"`
+	Notifications    NotificationPreferences `json:"notifications" yaml:"notifications"`
+}
+
+// DefaultUserPreferences returns the preferences of a person who has not
+// saved any: email notifications for every event, no subscriptions
+func DefaultUserPreferences() UserPreferences {
+	return UserPreferences{
+		Notifications: NotificationPreferences{
+			Email:         true,
+			Events:        append([]string(nil), NotificationEvents...),
+			Subscriptions: []string{},
+		},
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Release is a labeled snapshot of diagrams, frozen at the revisions they
+// had when it was created
+type Release struct {
+	Name        string           `json:"name" yaml:"name"`
+	Description *string          `json:"description,omitempty" yaml:"description,omitempty"`
+	Created     time.Time        `json:"created" yaml:"created"`
+	Revision    string           `json:"revision,omitempty" yaml:"revision,omitempty"` // Git commit of the diagrams path, when it is a work tree
+	Modified    bool             `json:"modified,omitempty" yaml:"modified,omitempty"` // Uncommitted changes were captured on top of Revision
+	Diagrams    []ReleaseDiagram `json:"diagrams" yaml:"diagrams"`
+}
+
+// ReleaseDiagram identifies a diagram captured in a release
+type ReleaseDiagram struct {
+	ID      string    `json:"id" yaml:"id"`
+	Name    string    `json:"name" yaml:"name"`
+	Version string    `json:"version" yaml:"version"`
+	Updated time.Time `json:"updated" yaml:"updated"`
+}
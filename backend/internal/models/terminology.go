@@ -0,0 +1,20 @@
+package models
+
+// Terminology configures the spelling and terminology lint of node names
+// and descriptions
+type Terminology struct {
+	// Word lists, one word per line; hunspell .dic files work too, as
+	// affix flags after a slash are ignored. Relative paths are resolved
+	// against the terminology file.
+	Dictionaries []string          `json:"dictionaries,omitempty" yaml:"dictionaries,omitempty"`
+	Words        []string          `json:"words,omitempty" yaml:"words,omitempty"`         // Additional accepted words, such as product names
+	Preferred    map[string]string `json:"preferred,omitempty" yaml:"preferred,omitempty"` // Variant to the preferred term, e.g. K8s: Kubernetes
+	Banned       []BannedTerm      `json:"banned,omitempty" yaml:"banned,omitempty"`
+}
+
+// BannedTerm is a term that must not be used
+type BannedTerm struct {
+	Term        string   `json:"term" yaml:"term"`
+	Reason      string   `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty" yaml:"suggestions,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+// Features that can be switched on and off per workspace
+const (
+	FeatureJiraSync      = "jiraSync"      // Jira integration endpoints
+	FeatureAIGeneration  = "aiGeneration"  // Saving diagrams generated by AI
+	FeaturePublicSharing = "publicSharing" // HTML exports handed to people outside the workspace
+)
+
+// WorkspaceFeatures are the feature flags of a workspace
+type WorkspaceFeatures struct {
+	JiraSync      bool `json:"jiraSync" yaml:"jiraSync"`
+	AIGeneration  bool `json:"aiGeneration" yaml:"aiGeneration"`
+	PublicSharing bool `json:"publicSharing" yaml:"publicSharing"`
+}
+
+// Enabled reports whether a feature is switched on
+func (f WorkspaceFeatures) Enabled(feature string) bool {
+	switch feature {
+	case FeatureJiraSync:
+		return f.JiraSync
+	case FeatureAIGeneration:
+		return f.AIGeneration
+	case FeaturePublicSharing:
+		return f.PublicSharing
+	}
+	return false
+}
+
+// WorkspaceSettings are the settings of a workspace
+type WorkspaceSettings struct {
+	ID       string            `json:"id" yaml:"-"`
+	Features WorkspaceFeatures `json:"features" yaml:"features"`
+}
+
+// DefaultWorkspaceSettings returns the settings of a workspace that has
+// not saved any: every feature on
+func DefaultWorkspaceSettings() WorkspaceSettings {
+	return WorkspaceSettings{
+		Features: WorkspaceFeatures{
+			JiraSync:      true,
+			AIGeneration:  true,
+			PublicSharing: true,
+		},
+	}
+}
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+)
+
+// How viewers are recorded in the access log
+const (
+	AccessViewersKeep = "keep" // The viewer's directory ID
+	AccessViewersHash = "hash" // A keyed hash of it, which still counts distinct viewers
+	AccessViewersOmit = "omit" // Nothing
+)
+
+var accessViewerModes = []string{AccessViewersKeep, AccessViewersHash, AccessViewersOmit}
+
+// AccessEntry is one read of a diagram
+type AccessEntry struct {
+	Time      time.Time `json:"time"`
+	DiagramID string    `json:"diagramId"`
+	Operation string    `json:"operation"`        // get, yaml, view, export, ...
+	Viewer    string    `json:"viewer,omitempty"` // Absent for anonymous reads and when omitted
+}
+
+// DiagramViews counts the reads of one diagram
+type DiagramViews struct {
+	DiagramID string `json:"diagramId"`
+	Name      string `json:"name,omitempty"` // Empty for diagrams deleted since
+	Views     int    `json:"views"`
+	Viewers   int    `json:"viewers"` // Distinct recorded viewers
+}
+
+// Writes and pruning of the access log do not interleave. Without
+// ACCESS_LOG_SALT, hashed viewers are keyed per process, so they only
+// count as the same viewer until a restart.
+var (
+	accessLogMu   sync.Mutex
+	accessLogSalt = randomAccessLogSalt()
+)
+
+func randomAccessLogSalt() []byte {
+	salt := make([]byte, 32)
+	_, _ = rand.Read(salt)
+	return salt
+}
+
+// AccessLogService records reads of diagrams and reports on them
+type AccessLogService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+}
+
+// NewAccessLogService creates a new access log service
+func NewAccessLogService() *AccessLogService {
+	return &AccessLogService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+	}
+}
+
+// Record appends a read of a diagram by the person named in the request's
+// USER_HEADER, if any. Nothing is logged unless ACCESS_LOG_PATH is set.
+func (s *AccessLogService) Record(diagramID, operation string, header http.Header) error {
+	if s.cfg.AccessLogPath == "" {
+		return nil
+	}
+	entry := AccessEntry{
+		Time:      time.Now().UTC(),
+		DiagramID: diagramID,
+		Operation: operation,
+		Viewer:    s.viewer(header.Get(s.cfg.UserHeader)),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	accessLogMu.Lock()
+	defer accessLogMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.cfg.AccessLogPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create access log directory: %w", err)
+	}
+	f, err := os.OpenFile(s.cfg.AccessLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open access log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write access log: %w", err)
+	}
+	return nil
+}
+
+// viewer is what the log records for a viewer ID. Unknown modes hash,
+// as the more private choice.
+func (s *AccessLogService) viewer(id string) string {
+	if id == "" {
+		return ""
+	}
+	switch s.cfg.AccessLogViewers {
+	case AccessViewersKeep:
+		return id
+	case AccessViewersOmit:
+		return ""
+	}
+	key := accessLogSalt
+	if s.cfg.AccessLogSalt != "" {
+		key = []byte(s.cfg.AccessLogSalt)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return "anon:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// MostViewed returns the diagrams read most often at or after since, most
+// viewed first, at most limit of them
+func (s *AccessLogService) MostViewed(since time.Time, limit int) ([]DiagramViews, error) {
+	if s.cfg.AccessLogPath == "" {
+		return nil, fmt.Errorf("%w: set ACCESS_LOG_PATH", ErrNotConfigured)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidOptions)
+	}
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+	counts := countViews(entries, since, time.Now())
+	names, err := s.diagramNames()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]DiagramViews, 0, len(counts))
+	for id, views := range counts {
+		views.Name = names[id]
+		result = append(result, *views)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return moreViewed(result[i], result[j])
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// countViews counts the reads of each diagram from from up to to
+func countViews(entries []AccessEntry, from, to time.Time) map[string]*DiagramViews {
+	counts := make(map[string]*DiagramViews)
+	viewers := make(map[string]map[string]bool)
+	for _, entry := range entries {
+		if entry.Time.Before(from) || !entry.Time.Before(to) {
+			continue
+		}
+		views, ok := counts[entry.DiagramID]
+		if !ok {
+			views = &DiagramViews{DiagramID: entry.DiagramID}
+			counts[entry.DiagramID] = views
+			viewers[entry.DiagramID] = make(map[string]bool)
+		}
+		views.Views++
+		if entry.Viewer != "" && !viewers[entry.DiagramID][entry.Viewer] {
+			viewers[entry.DiagramID][entry.Viewer] = true
+			views.Viewers++
+		}
+	}
+	return counts
+}
+
+// moreViewed orders diagrams most viewed first, then by ID
+func moreViewed(a, b DiagramViews) bool {
+	if a.Views != b.Views {
+		return a.Views > b.Views
+	}
+	return a.DiagramID < b.DiagramID
+}
+
+func (s *AccessLogService) diagramNames() (map[string]string, error) {
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(diagrams))
+	for _, diagram := range diagrams {
+		names[diagram.ID] = diagram.Name
+	}
+	return names, nil
+}
+
+// entries reads the access log, skipping lines that do not parse
+func (s *AccessLogService) entries() ([]AccessEntry, error) {
+	accessLogMu.Lock()
+	data, err := os.ReadFile(s.cfg.AccessLogPath)
+	accessLogMu.Unlock()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access log: %w", err)
+	}
+	entries := []AccessEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry AccessEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Prune removes entries older than ACCESS_LOG_RETENTION and returns how
+// many it removed
+func (s *AccessLogService) Prune() (int, error) {
+	if s.cfg.AccessLogPath == "" {
+		return 0, nil
+	}
+	accessLogMu.Lock()
+	defer accessLogMu.Unlock()
+	data, err := os.ReadFile(s.cfg.AccessLogPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read access log: %w", err)
+	}
+
+	cutoff := time.Now().Add(-s.cfg.AccessLogRetention)
+	var kept bytes.Buffer
+	removed := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry AccessEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Time.Before(cutoff) {
+			removed++
+			continue
+		}
+		kept.WriteString(line)
+		kept.WriteByte('\n')
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := os.WriteFile(s.cfg.AccessLogPath, kept.Bytes(), 0o600); err != nil {
+		return 0, fmt.Errorf("failed to write access log: %w", err)
+	}
+	return removed, nil
+}
+
+// StartAccessLogRetention prunes the access log at startup and then hourly
+// when ACCESS_LOG_PATH is set
+func StartAccessLogRetention() {
+	s := NewAccessLogService()
+	if s.cfg.AccessLogPath == "" {
+		return
+	}
+	if !containsValue(accessViewerModes, s.cfg.AccessLogViewers) {
+		log.Printf("Unknown ACCESS_LOG_VIEWERS %q, hashing viewers; use one of %s", s.cfg.AccessLogViewers, strings.Join(accessViewerModes, ", "))
+	}
+	log.Printf("Logging diagram reads to %s, kept for %s", s.cfg.AccessLogPath, s.cfg.AccessLogRetention)
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if removed, err := NewAccessLogService().Prune(); err != nil {
+				log.Printf("Access log pruning failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("Pruned %d access log entries", removed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Trends of a diagram's views against the window before
+const (
+	UsageTrendUp   = "up"
+	UsageTrendDown = "down"
+	UsageTrendFlat = "flat"
+	UsageTrendNew  = "new" // Not viewed in the window before
+)
+
+// UsageReport shows how much each diagram is consulted over a window
+type UsageReport struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Bucket   string         `json:"bucket"`  // day or hour, the period of each series value
+	Views    int            `json:"views"`   // All reads in the window
+	Viewers  int            `json:"viewers"` // Distinct recorded viewers across diagrams
+	Diagrams []DiagramUsage `json:"diagrams"`
+}
+
+// DiagramUsage is the use of one diagram over the window
+type DiagramUsage struct {
+	DiagramViews
+	PreviousViews int    `json:"previousViews"` // Reads in the window of equal length before
+	Trend         string `json:"trend"`
+	Series        []int  `json:"series"` // Reads per bucket, oldest first
+}
+
+// Usage reports the reads of every diagram in the window ending now, with
+// their trend against the window before. Active diagrams nobody read are
+// included with zero views, so unused processes show up too.
+func (s *AccessLogService) Usage(window time.Duration) (*UsageReport, error) {
+	if s.cfg.AccessLogPath == "" {
+		return nil, fmt.Errorf("%w: set ACCESS_LOG_PATH", ErrNotConfigured)
+	}
+	if window < time.Hour {
+		return nil, fmt.Errorf("%w: window must be at least 1h", ErrInvalidOptions)
+	}
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, bucketName := 24*time.Hour, "day"
+	if window < 48*time.Hour {
+		bucket, bucketName = time.Hour, "hour"
+	}
+	to := time.Now().UTC()
+	from := to.Add(-window)
+	buckets := int((window + bucket - 1) / bucket)
+	report := &UsageReport{From: from, To: to, Bucket: bucketName, Diagrams: []DiagramUsage{}}
+
+	current := countViews(entries, from, to)
+	previous := countViews(entries, from.Add(-window), from)
+	series := make(map[string][]int)
+	viewers := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Time.Before(from) || !entry.Time.Before(to) {
+			continue
+		}
+		report.Views++
+		if entry.Viewer != "" {
+			viewers[entry.Viewer] = true
+		}
+		if series[entry.DiagramID] == nil {
+			series[entry.DiagramID] = make([]int, buckets)
+		}
+		if i := int(entry.Time.Sub(from) / bucket); i < buckets {
+			series[entry.DiagramID][i]++
+		}
+	}
+	report.Viewers = len(viewers)
+
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, diagram := range diagrams {
+		names[diagram.ID] = diagram.Name
+		if _, ok := current[diagram.ID]; !ok && !diagram.Archived {
+			current[diagram.ID] = &DiagramViews{DiagramID: diagram.ID}
+		}
+	}
+	for id, views := range current {
+		views.Name = names[id]
+		usage := DiagramUsage{DiagramViews: *views, Series: series[id]}
+		if usage.Series == nil {
+			usage.Series = make([]int, buckets)
+		}
+		if before, ok := previous[id]; ok {
+			usage.PreviousViews = before.Views
+		}
+		switch {
+		case usage.PreviousViews == 0 && usage.Views > 0:
+			usage.Trend = UsageTrendNew
+		case usage.Views > usage.PreviousViews:
+			usage.Trend = UsageTrendUp
+		case usage.Views < usage.PreviousViews:
+			usage.Trend = UsageTrendDown
+		default:
+			usage.Trend = UsageTrendFlat
+		}
+		report.Diagrams = append(report.Diagrams, usage)
+	}
+	sort.Slice(report.Diagrams, func(i, j int) bool {
+		return moreViewed(report.Diagrams[i].DiagramViews, report.Diagrams[j].DiagramViews)
+	})
+	return report, nil
+}
+
+// ParseUsageWindow parses a window such as 30d, 2w or a Go duration like 12h
+func ParseUsageWindow(value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); err == nil && strings.HasSuffix(value, suffix) {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: window must be a number of days (30d), weeks (2w) or a duration (12h)", ErrInvalidOptions)
+	}
+	return window, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// archiveDir is the area of the diagrams path that archived diagrams are
+// moved to. They stay retrievable by ID, keep their history in Git and
+// still resolve as references, but lists and searches leave them out
+// unless asked for.
+const archiveDir = "archive"
+
+// archivePathOf returns the archive of the diagram root holding a path
+func (s *DiagramService) archivePathOf(path string) string {
+	return filepath.Join(s.rootOf(path).Path, archiveDir)
+}
+
+// isArchivedPath reports whether a diagram file is in the archive
+func (s *DiagramService) isArchivedPath(path string) bool {
+	rel, err := filepath.Rel(s.archivePathOf(path), path)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
+
+// List returns the diagrams in the active listing, and the archived ones
+// as well if includeArchived is set
+func (s *DiagramService) List(includeArchived bool) ([]models.FlowDiagram, error) {
+	diagrams, err := s.ListAll()
+	if err != nil || includeArchived {
+		return diagrams, err
+	}
+	active := []models.FlowDiagram{}
+	for _, diagram := range diagrams {
+		if !diagram.Archived {
+			active = append(active, diagram)
+		}
+	}
+	return active, nil
+}
+
+// Archive moves a diagram into the archive, keeping its path below the
+// diagrams path. Archiving an archived diagram changes nothing; a dry run
+// only records the move.
+func (s *DiagramService) Archive(id string) (*models.FlowDiagram, error) {
+	diagram, err := s.GetByID(id)
+	if err != nil || diagram.Archived {
+		return diagram, err
+	}
+	rel, err := filepath.Rel(s.rootOf(diagram.FilePath).Path, diagram.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate diagram file: %w", err)
+	}
+	if err := s.moveDiagram(diagram, filepath.Join(s.archivePathOf(diagram.FilePath), rel)); err != nil {
+		return nil, err
+	}
+	if s.dryRun == nil {
+		gitSyncAfterSave(s.cfg, "Archive diagram "+id)
+	}
+	return diagram, nil
+}
+
+// Unarchive moves an archived diagram back to where it was before it was
+// archived
+func (s *DiagramService) Unarchive(id string) (*models.FlowDiagram, error) {
+	diagram, err := s.GetByID(id)
+	if err != nil || !diagram.Archived {
+		return diagram, err
+	}
+	rel, err := filepath.Rel(s.archivePathOf(diagram.FilePath), diagram.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate diagram file: %w", err)
+	}
+	if err := s.moveDiagram(diagram, filepath.Join(s.rootOf(diagram.FilePath).Path, rel)); err != nil {
+		return nil, err
+	}
+	if s.dryRun == nil {
+		gitSyncAfterSave(s.cfg, "Unarchive diagram "+id)
+	}
+	return diagram, nil
+}
+
+func (s *DiagramService) moveDiagram(diagram *models.FlowDiagram, target string) error {
+	for _, path := range []string{diagram.FilePath, target} {
+		if err := s.checkWritable(path); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("%w: %s is taken by another file", ErrDiagramExists, target)
+	}
+	if s.dryRun != nil {
+		before := *diagram
+		diagram.FilePath = target
+		s.annotateDiagram(diagram, target)
+		// Diffs leave out where a diagram is stored, so the move is
+		// recorded as a change of its archived flag
+		diff := DiffDiagrams(&before, diagram)
+		diff.Status = DiffChanged
+		diff.Fields = append(diff.Fields, FieldChange{Field: "archived", Before: before.Archived, After: diagram.Archived})
+		s.dryRun.add(diff)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := moveDiagramFile(diagram.FilePath, target); err != nil {
+		return fmt.Errorf("failed to move diagram file: %w", err)
+	}
+	diagram.FilePath = target
+	s.annotateDiagram(diagram, target)
+	return nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// branchProbabilities returns the probability of taking each edge, indexed
+// like diagram.Edges, once its source node is reached. Edges without an
+// explicit probability share what their siblings leave over equally.
+func branchProbabilities(diagram *models.FlowDiagram) []float64 {
+	probs := make([]float64, len(diagram.Edges))
+	explicit := make(map[string]float64)
+	implicit := make(map[string]int)
+	for _, edge := range diagram.Edges {
+		if edge.Probability != nil {
+			explicit[edge.From] += *edge.Probability
+		} else {
+			implicit[edge.From]++
+		}
+	}
+	for i, edge := range diagram.Edges {
+		if edge.Probability != nil {
+			probs[i] = *edge.Probability
+			continue
+		}
+		probs[i] = math.Max(0, 1-explicit[edge.From]) / float64(implicit[edge.From])
+	}
+	return probs
+}
+
+// validateBranching checks edge probabilities: each must lie in [0, 1] and
+// the explicit probabilities leaving a node may not add up to more than 1
+func validateBranching(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	sums := make(map[string]float64)
+	for i, edge := range diagram.Edges {
+		if edge.Probability == nil {
+			continue
+		}
+		if p := *edge.Probability; p < 0 || p > 1 {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    fmt.Sprintf("edges[%d].probability", i),
+				Message: fmt.Sprintf("Probability must be between 0 and 1: %v", p),
+				Code:    "INVALID_PROBABILITY",
+				Value:   p,
+			})
+			continue
+		}
+		sums[edge.From] += *edge.Probability
+	}
+	for i, node := range diagram.Nodes {
+		if sums[node.ID] > 1+1e-9 {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    fmt.Sprintf("nodes[%d]", i),
+				Message: fmt.Sprintf("Outgoing branch probabilities add up to more than 1: %s", node.ID),
+				Code:    "PROBABILITY_SUM_EXCEEDED",
+				Value:   node.ID,
+			})
+		}
+	}
+}
+
+// expectedVisits returns how often each node is reached per execution,
+// starting once from the entry nodes (split evenly) and following branch
+// probabilities, including around loops
+func expectedVisits(diagram *models.FlowDiagram) (map[string]float64, error) {
+	probs := branchProbabilities(diagram)
+	entries := entryNodes(diagram)
+	entry := make(map[string]float64)
+	for _, id := range entries {
+		entry[id] += 1 / float64(len(entries))
+	}
+
+	visits := make(map[string]float64)
+	for iteration := 0; iteration < 10000; iteration++ {
+		next := make(map[string]float64, len(diagram.Nodes))
+		for _, node := range diagram.Nodes {
+			next[node.ID] = entry[node.ID]
+		}
+		for i, edge := range diagram.Edges {
+			if _, ok := next[edge.To]; ok {
+				next[edge.To] += visits[edge.From] * probs[i]
+			}
+		}
+
+		delta := 0.0
+		for id, v := range next {
+			delta = math.Max(delta, math.Abs(v-visits[id]))
+		}
+		visits = next
+		if delta < 1e-9 {
+			return visits, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: loops never exit, so expected visits diverge", ErrInvalidDiagram)
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// DiagramFilter selects diagrams by ID or by what they carry. All
+// populated criteria must match.
+type DiagramFilter struct {
+	IDs             []string               `json:"ids"`
+	Tags            []string               `json:"tags"`     // Diagram must carry every tag
+	Metadata        map[string]interface{} `json:"metadata"` // Key/value predicates; "*" matches any value
+	Owners          []string               `json:"owners"`   // Diagram must have one of these in any ownership role
+	IncludeArchived bool                   `json:"includeArchived"`
+}
+
+func (f DiagramFilter) empty() bool {
+	return len(f.IDs) == 0 && len(f.Tags) == 0 && len(f.Metadata) == 0 && len(f.Owners) == 0
+}
+
+func (f DiagramFilter) matches(diagram *models.FlowDiagram) bool {
+	if len(f.IDs) > 0 && !containsValue(f.IDs, diagram.ID) {
+		return false
+	}
+	selector := ElementSelector{Tags: f.Tags, Metadata: f.Metadata, Owners: f.Owners}
+	return selector.matchesEntity(&diagram.FlowEntity)
+}
+
+// OwnershipTransfer hands every ownership role one person or team holds in
+// a diagram to another
+type OwnershipTransfer struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BulkMetadataEdit changes the metadata and ownership of the diagrams
+// matching a filter
+type BulkMetadataEdit struct {
+	Filter   DiagramFilter          `json:"filter"`
+	Set      map[string]interface{} `json:"set"`               // Metadata keys set or replaced
+	Remove   []string               `json:"remove"`            // Metadata keys removed
+	Transfer *OwnershipTransfer     `json:"transferOwnership"` // Optional
+	DryRun   bool                   `json:"dryRun"`
+}
+
+// BulkDiagramChange lists the changes made to one diagram. Error is set
+// when the diagram would not save, which stops the whole edit.
+type BulkDiagramChange struct {
+	DiagramID   string               `json:"diagramId"`
+	DiagramName string               `json:"diagramName"`
+	Changes     []CatalogFieldChange `json:"changes"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// BulkMetadataResult is the outcome of a bulk metadata edit
+type BulkMetadataResult struct {
+	DryRun   bool                `json:"dryRun"`
+	Matched  int                 `json:"matched"` // Diagrams matching the filter, changed or not
+	Diagrams []BulkDiagramChange `json:"diagrams"`
+	Updated  int                 `json:"updated"`
+	Failed   int                 `json:"failed"`
+}
+
+// BulkEditMetadata applies one metadata and ownership edit to every
+// diagram matching the filter, all or nothing: when any changed diagram is
+// read-only or would not validate, nothing is saved and the failures are
+// reported, and a save failing midway restores the diagrams already saved.
+// With DryRun the changes are only reported.
+func (s *DiagramService) BulkEditMetadata(edit BulkMetadataEdit) (*BulkMetadataResult, error) {
+	if edit.Filter.empty() {
+		return nil, fmt.Errorf("%w: filter selects every diagram; give ids, tags, metadata or owners", ErrInvalidOptions)
+	}
+	if len(edit.Set) == 0 && len(edit.Remove) == 0 && edit.Transfer == nil {
+		return nil, fmt.Errorf("%w: nothing to change; give set, remove or transferOwnership", ErrInvalidOptions)
+	}
+	if edit.Transfer != nil && (edit.Transfer.From == "" || edit.Transfer.To == "") {
+		return nil, fmt.Errorf("%w: transferOwnership needs from and to", ErrInvalidOptions)
+	}
+	for _, key := range edit.Remove {
+		if _, ok := edit.Set[key]; ok {
+			return nil, fmt.Errorf("%w: metadata key %s is both set and removed", ErrInvalidOptions, key)
+		}
+	}
+
+	diagrams, err := s.List(edit.Filter.IncludeArchived)
+	if err != nil {
+		return nil, err
+	}
+	result := &BulkMetadataResult{DryRun: edit.DryRun, Diagrams: []BulkDiagramChange{}}
+	var changed []*models.FlowDiagram
+	for i := range diagrams {
+		diagram := &diagrams[i]
+		if !edit.Filter.matches(diagram) {
+			continue
+		}
+		result.Matched++
+		change := BulkDiagramChange{DiagramID: diagram.ID, DiagramName: diagram.Name}
+		if change.Changes = applyBulkMetadata(diagram, edit); len(change.Changes) == 0 {
+			continue
+		}
+		if err := s.checkWritable(diagram.FilePath); err != nil {
+			change.Error = err.Error()
+		} else if err := s.validateDiagram(diagram); err != nil {
+			change.Error = err.Error()
+		}
+		if change.Error != "" {
+			result.Failed++
+		}
+		result.Diagrams = append(result.Diagrams, change)
+		changed = append(changed, diagram)
+	}
+	if edit.DryRun || result.Failed > 0 {
+		return result, nil
+	}
+
+	message := fmt.Sprintf("Edit metadata of %d diagrams", len(changed))
+	var paths []string
+	for i, diagram := range changed {
+		original, err := s.GetByID(diagram.ID)
+		if err == nil {
+			_, err = s.save(diagram, SaveEventUpdate, message)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to update diagram %s: %w", diagram.ID, err)
+			return nil, errors.Join(err, s.restoreDiagrams(changed[:i]))
+		}
+		changed[i] = original // Saved diagrams are kept as they were, for restoring
+		if path, err := filepath.Abs(diagram.FilePath); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	result.Updated = len(changed)
+	gitRecordChange(s.cfg, message, paths)
+	return result, nil
+}
+
+// restoreDiagrams saves diagrams back over the versions saved since they
+// were read, and returns the restores that failed
+func (s *DiagramService) restoreDiagrams(originals []*models.FlowDiagram) error {
+	var errs []error
+	for i := len(originals) - 1; i >= 0; i-- {
+		original := originals[i]
+		original.ContentHash = ""
+		if _, err := s.save(original, SaveEventUpdate, "Restore diagram "+original.ID); err != nil {
+			log.Printf("Restoring diagram %s failed: %v", original.ID, err)
+			errs = append(errs, fmt.Errorf("failed to restore diagram %s: %w", original.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyBulkMetadata makes the edit on a diagram and returns what changed
+func applyBulkMetadata(diagram *models.FlowDiagram, edit BulkMetadataEdit) []CatalogFieldChange {
+	var changes []CatalogFieldChange
+	for _, key := range sortedKeys(edit.Set) {
+		value := edit.Set[key]
+		current, ok := diagram.Metadata[key]
+		if ok && sameJSON(current, value) {
+			continue
+		}
+		if diagram.Metadata == nil {
+			diagram.Metadata = make(map[string]interface{})
+		}
+		changes = append(changes, CatalogFieldChange{Field: "metadata." + key, From: current, To: value})
+		diagram.Metadata[key] = value
+	}
+	for _, key := range edit.Remove {
+		if current, ok := diagram.Metadata[key]; ok {
+			changes = append(changes, CatalogFieldChange{Field: "metadata." + key, From: current, To: nil})
+			delete(diagram.Metadata, key)
+		}
+	}
+	if edit.Transfer != nil && diagram.Ownership != nil {
+		changes = append(changes, transferOwnership(diagram.Ownership, edit.Transfer.From, edit.Transfer.To)...)
+	}
+	return changes
+}
+
+// transferOwnership replaces from with to in every role it holds
+func transferOwnership(ownership *models.Ownership, from, to string) []CatalogFieldChange {
+	var changes []CatalogFieldChange
+	single := func(role string, id *string) {
+		if *id == from {
+			changes = append(changes, CatalogFieldChange{Field: "ownership." + role, From: from, To: to})
+			*id = to
+		}
+	}
+	list := func(role string, ids *[]string) {
+		if !containsValue(*ids, from) {
+			return
+		}
+		replaced := []string{}
+		for _, id := range *ids {
+			if id == from {
+				id = to
+			}
+			if !containsValue(replaced, id) {
+				replaced = append(replaced, id)
+			}
+		}
+		changes = append(changes, CatalogFieldChange{Field: "ownership." + role, From: *ids, To: replaced})
+		*ids = replaced
+	}
+	single(models.RoleOwner, &ownership.Owner)
+	list(models.RoleResponsible, &ownership.Responsible)
+	single(models.RoleAccountable, &ownership.Accountable)
+	list(models.RoleConsulted, &ownership.Consulted)
+	list(models.RoleInformed, &ownership.Informed)
+	return changes
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrCatalogNodeNotFound is returned for an ID missing from the node catalog
+var ErrCatalogNodeNotFound = errors.New("catalog node not found")
+
+// NodeCatalog loads the configured catalog of canonical nodes. It returns
+// nil without error when no catalog is configured, in which case catalog
+// references are not checked.
+func (s *DiagramService) NodeCatalog() (*models.NodeCatalog, error) {
+	if s.cfg.NodeCatalogPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.cfg.NodeCatalogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node catalog: %w", err)
+	}
+	var catalog models.NodeCatalog
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse node catalog: %w", err)
+	}
+	return &catalog, nil
+}
+
+// CatalogNodes returns the catalog, failing when none is configured
+func (s *DiagramService) CatalogNodes() (*models.NodeCatalog, error) {
+	catalog, err := s.NodeCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: set NODE_CATALOG_PATH", ErrNotConfigured)
+	}
+	return catalog, nil
+}
+
+// CatalogNode returns one catalog node
+func (s *DiagramService) CatalogNode(id string) (*models.CatalogNode, error) {
+	catalog, err := s.CatalogNodes()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := catalog.Find(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogNodeNotFound, id)
+	}
+	return entry, nil
+}
+
+// CatalogUsage lists every diagram node referencing the catalog node, with
+// the fields that have drifted from it
+func (s *DiagramService) CatalogUsage(id string) ([]models.CatalogUsage, error) {
+	entry, err := s.CatalogNode(id)
+	if err != nil {
+		return nil, err
+	}
+	diagrams, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []models.CatalogUsage{}
+	for _, diagram := range diagrams {
+		for i := range diagram.Nodes {
+			node := &diagram.Nodes[i]
+			if node.Catalog == nil || *node.Catalog != id {
+				continue
+			}
+			usages = append(usages, models.CatalogUsage{
+				DiagramID:   diagram.ID,
+				DiagramName: diagram.Name,
+				NodeID:      node.ID,
+				NodeName:    node.Name,
+				Archived:    diagram.Archived,
+				Drift:       catalogDrift(node, entry),
+			})
+		}
+	}
+	return usages, nil
+}
+
+// applyCatalogDefaults fills in the name, type, description and metadata
+// that nodes referencing the catalog leave unset. Styles are not copied;
+// the catalog style applies when rendering so changes to it carry over.
+func (s *DiagramService) applyCatalogDefaults(diagram *models.FlowDiagram) error {
+	catalog, err := s.NodeCatalog()
+	if err != nil || catalog == nil {
+		return err
+	}
+	for i := range diagram.Nodes {
+		node := &diagram.Nodes[i]
+		if node.Catalog == nil {
+			continue
+		}
+		entry, ok := catalog.Find(*node.Catalog)
+		if !ok {
+			continue
+		}
+		if node.Name == "" {
+			node.Name = entry.Name
+		}
+		if node.Type == "" {
+			node.Type = entry.Type
+		}
+		if node.Description == nil && entry.Description != nil {
+			description := *entry.Description
+			node.Description = &description
+		}
+		for key, value := range entry.Metadata {
+			if _, ok := node.Metadata[key]; ok {
+				continue
+			}
+			if node.Metadata == nil {
+				node.Metadata = make(map[string]interface{})
+			}
+			node.Metadata[key] = value
+		}
+	}
+	return nil
+}
+
+// validateCatalogRef checks a node's catalog reference and warns about
+// fields that have drifted from the catalog node
+func validateCatalogRef(result *models.ValidationResult, path string, node *models.FlowNode, catalog *models.NodeCatalog) {
+	if node.Catalog == nil || catalog == nil {
+		return
+	}
+	entry, ok := catalog.Find(*node.Catalog)
+	if !ok {
+		result.Errors = append(result.Errors, models.ValidationError{
+			Path:    path + ".catalog",
+			Message: fmt.Sprintf("Unknown catalog node: %s", *node.Catalog),
+			Code:    "UNKNOWN_CATALOG_NODE",
+			Value:   *node.Catalog,
+		})
+		return
+	}
+	for _, field := range catalogDrift(node, entry) {
+		result.Warnings = append(result.Warnings, models.ValidationError{
+			Path:    path + "." + field,
+			Message: fmt.Sprintf("Differs from catalog node %s", entry.ID),
+			Code:    "CATALOG_DRIFT",
+			Value:   entry.ID,
+		})
+	}
+}
+
+// catalogDrift returns the fields in which a node differs from the catalog
+// node it references. Style fields the node leaves unset inherit the
+// catalog style and do not count.
+func catalogDrift(node *models.FlowNode, entry *models.CatalogNode) []string {
+	var drift []string
+	if node.Name != entry.Name {
+		drift = append(drift, "name")
+	}
+	if entry.Type != "" && node.Type != entry.Type {
+		drift = append(drift, "type")
+	}
+	if entry.Description != nil && (node.Description == nil || *node.Description != *entry.Description) {
+		drift = append(drift, "description")
+	}
+	if entry.Style != nil && node.Style != nil {
+		want := reflect.ValueOf(entry.Style).Elem()
+		got := reflect.ValueOf(node.Style).Elem()
+		for f := 0; f < want.NumField(); f++ {
+			w, g := want.Field(f), got.Field(f)
+			if w.Kind() != reflect.Ptr || w.IsNil() || g.IsNil() || reflect.DeepEqual(w.Interface(), g.Interface()) {
+				continue
+			}
+			name := strings.Split(want.Type().Field(f).Tag.Get("json"), ",")[0]
+			drift = append(drift, "style."+name)
+		}
+	}
+	for _, key := range sortedKeys(entry.Metadata) {
+		if value, ok := node.Metadata[key]; !ok || !sameJSON(value, entry.Metadata[key]) {
+			drift = append(drift, "metadata."+key)
+		}
+	}
+	return drift
+}
+
+// sameJSON compares values by their JSON encoding, so numbers read from
+// YAML and JSON compare equal
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// withCatalogStyle returns the node's style laid over the default style of
+// the catalog node it references
+func withCatalogStyle(node *models.FlowNode, catalog *models.NodeCatalog, named map[string]models.Style) *models.Style {
+	if node.Catalog == nil || catalog == nil {
+		return node.Style
+	}
+	entry, ok := catalog.Find(*node.Catalog)
+	if !ok || entry.Style == nil {
+		return node.Style
+	}
+	base, err := resolveStyle(entry.Style, named)
+	if err != nil {
+		return node.Style
+	}
+	merged := &models.Style{}
+	overlayStyle(merged, base)
+	if node.Style != nil {
+		overlayStyle(merged, node.Style)
+	}
+	return merged
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// CatalogFieldChange is one field of a node brought in line with the catalog
+type CatalogFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to"`
+}
+
+// CatalogNodeChange lists the changes made to one referencing node
+type CatalogNodeChange struct {
+	NodeID  string               `json:"nodeId"`
+	Changes []CatalogFieldChange `json:"changes"`
+}
+
+// CatalogDiagramChange lists the changes made to one diagram. Error is set
+// when the diagram could not be saved, e.g. because it no longer validates.
+type CatalogDiagramChange struct {
+	DiagramID   string              `json:"diagramId"`
+	DiagramName string              `json:"diagramName"`
+	Nodes       []CatalogNodeChange `json:"nodes"`
+	Saved       bool                `json:"saved"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// CatalogPropagation is the outcome of propagating a catalog node
+type CatalogPropagation struct {
+	CatalogID string                 `json:"catalogId"`
+	DryRun    bool                   `json:"dryRun"`
+	Diagrams  []CatalogDiagramChange `json:"diagrams"`
+	Updated   int                    `json:"updated"` // Diagrams saved
+	Failed    int                    `json:"failed"`  // Diagrams that could not be, or on a dry run would not be, saved
+}
+
+// PropagateCatalogNode brings the name and metadata of every node that
+// references the catalog node in line with it, after the catalog entry was
+// renamed or its metadata changed. Metadata keys the catalog does not
+// define are left alone. Archived diagrams are not touched. Each diagram is
+// saved like any other update, so one that fails to save is reported and
+// the others still go through, and the change is committed to the Git
+// history of the diagrams it touched. With dryRun nothing is saved and the
+// diagrams that would fail validation are reported as failed.
+func (s *DiagramService) PropagateCatalogNode(id string, dryRun bool) (*CatalogPropagation, error) {
+	entry, err := s.CatalogNode(id)
+	if err != nil {
+		return nil, err
+	}
+	diagrams, err := s.List(false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CatalogPropagation{CatalogID: id, DryRun: dryRun, Diagrams: []CatalogDiagramChange{}}
+	message := fmt.Sprintf("Propagate catalog node %s (%s)", id, entry.Name)
+	var paths []string
+	for i := range diagrams {
+		diagram := &diagrams[i]
+		change := CatalogDiagramChange{DiagramID: diagram.ID, DiagramName: diagram.Name}
+		for j := range diagram.Nodes {
+			node := &diagram.Nodes[j]
+			if node.Catalog == nil || *node.Catalog != id {
+				continue
+			}
+			if changes := propagateCatalogFields(node, entry); len(changes) > 0 {
+				change.Nodes = append(change.Nodes, CatalogNodeChange{NodeID: node.ID, Changes: changes})
+			}
+		}
+		if len(change.Nodes) == 0 {
+			continue
+		}
+
+		if dryRun {
+			// Report diagrams that would be refused, as saving would
+			if validation, err := s.Validate(diagram); err == nil && !validation.Valid {
+				change.Error = fmt.Sprintf("%v: validation failed with %d errors", ErrInvalidDiagram, len(validation.Errors))
+				result.Failed++
+			}
+		} else {
+			if _, err := s.save(diagram, SaveEventUpdate, message); err != nil {
+				change.Error = err.Error()
+				result.Failed++
+			} else {
+				change.Saved = true
+				result.Updated++
+				if path, err := filepath.Abs(diagram.FilePath); err == nil {
+					paths = append(paths, path)
+				}
+			}
+		}
+		result.Diagrams = append(result.Diagrams, change)
+	}
+
+	gitRecordChange(s.cfg, message, paths)
+	return result, nil
+}
+
+// propagateCatalogFields copies the catalog node's name and metadata onto
+// the node and returns what changed
+func propagateCatalogFields(node *models.FlowNode, entry *models.CatalogNode) []CatalogFieldChange {
+	var changes []CatalogFieldChange
+	if node.Name != entry.Name {
+		changes = append(changes, CatalogFieldChange{Field: "name", From: node.Name, To: entry.Name})
+		node.Name = entry.Name
+	}
+	for _, key := range sortedKeys(entry.Metadata) {
+		value := entry.Metadata[key]
+		current, ok := node.Metadata[key]
+		if ok && sameJSON(current, value) {
+			continue
+		}
+		changes = append(changes, CatalogFieldChange{Field: "metadata." + key, From: current, To: value})
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]interface{})
+		}
+		node.Metadata[key] = value
+	}
+	return changes
+}
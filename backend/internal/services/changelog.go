@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// changelogListedIDs is how many element IDs a changelog summary names
+// before counting the rest
+const changelogListedIDs = 3
+
+// ChangeSummaryHeader carries a client's description of a change, for
+// requests whose body is not a JSON diagram with a changeSummary
+const ChangeSummaryHeader = "X-Change-Summary"
+
+// ActingFor returns a diagram service whose saves are recorded in the
+// changelog as made by the person named in the request's USER_HEADER, and
+// described by the request's change summary header
+func (s *DiagramService) ActingFor(header http.Header) *DiagramService {
+	acting := *s
+	acting.actor = header.Get(s.cfg.UserHeader)
+	acting.summary = strings.TrimSpace(header.Get(ChangeSummaryHeader))
+	acting.header = header
+	return &acting
+}
+
+// commitMessage leads a Git commit message with the change summary, if any
+func commitMessage(summary, message string) string {
+	if summary == "" {
+		return message
+	}
+	return summary + "\n\n" + message
+}
+
+// recordChangelog appends an entry summarizing a save to the diagram's
+// changelog with the client's change summary, dropping the oldest entries
+// past DIAGRAM_CHANGELOG_LIMIT. The stored changelog is kept over the one
+// submitted; previous is nil when the diagram is created. Saves that change
+// nothing are not recorded.
+func (s *DiagramService) recordChangelog(diagram, previous *models.FlowDiagram, now time.Time, message string) {
+	if s.cfg.ChangelogLimit <= 0 {
+		diagram.Changelog = nil
+		if previous != nil {
+			diagram.Changelog = previous.Changelog
+		}
+		return
+	}
+	summary := "Created"
+	var changelog []models.ChangelogEntry
+	if previous != nil {
+		diff := DiffDiagrams(previous, diagram)
+		if diff.Status == DiffUnchanged {
+			diagram.Changelog = previous.Changelog
+			return
+		}
+		summary = summarizeDiff(diff)
+		changelog = append(changelog, previous.Changelog...)
+	}
+	changelog = append(changelog, models.ChangelogEntry{Time: now.UTC(), Actor: s.actor, Summary: summary, Message: message})
+	if extra := len(changelog) - s.cfg.ChangelogLimit; extra > 0 {
+		changelog = changelog[extra:]
+	}
+	diagram.Changelog = changelog
+}
+
+// summarizeDiff describes a diagram diff in one line, e.g. "Added nodes
+// cache, queue; changed node api (name, style); changed description"
+func summarizeDiff(diff DiagramDiff) string {
+	var parts []string
+	for _, elements := range []struct {
+		kind string
+		diff ElementDiff
+	}{{"node", diff.Nodes}, {"edge", diff.Edges}} {
+		if len(elements.diff.Added) > 0 {
+			parts = append(parts, "added "+listIDs(elements.kind, elements.diff.Added))
+		}
+		if len(elements.diff.Removed) > 0 {
+			parts = append(parts, "removed "+listIDs(elements.kind, elements.diff.Removed))
+		}
+		switch changed := elements.diff.Changed; len(changed) {
+		case 0:
+		case 1:
+			fields := make([]string, 0, len(changed[0].Changes))
+			for _, change := range changed[0].Changes {
+				fields = append(fields, change.Field)
+			}
+			parts = append(parts, fmt.Sprintf("changed %s %s (%s)", elements.kind, changed[0].ID, strings.Join(fields, ", ")))
+		default:
+			ids := make([]string, 0, len(changed))
+			for _, change := range changed {
+				ids = append(ids, change.ID)
+			}
+			parts = append(parts, "changed "+listIDs(elements.kind, ids))
+		}
+	}
+	if len(diff.Fields) > 0 {
+		fields := make([]string, 0, len(diff.Fields))
+		for _, change := range diff.Fields {
+			fields = append(fields, change.Field)
+		}
+		parts = append(parts, "changed "+strings.Join(fields, ", "))
+	}
+	summary := strings.Join(parts, "; ")
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+// listIDs names up to changelogListedIDs elements, e.g. "nodes a, b, c
+// and 2 more"
+func listIDs(kind string, ids []string) string {
+	if len(ids) > 1 {
+		kind += "s"
+	}
+	if len(ids) <= changelogListedIDs {
+		return kind + " " + strings.Join(ids, ", ")
+	}
+	return fmt.Sprintf("%s %s and %d more", kind, strings.Join(ids[:changelogListedIDs], ", "), len(ids)-changelogListedIDs)
+}
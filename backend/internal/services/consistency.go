@@ -0,0 +1,371 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ConsistencyReport is the outcome of re-validating every diagram together
+// with the references between diagrams, which single-diagram validation
+// does not check
+type ConsistencyReport struct {
+	CheckedAt  time.Time            `json:"checkedAt"`
+	Diagrams   int                  `json:"diagrams"`
+	Errors     int                  `json:"errors"`
+	Warnings   int                  `json:"warnings"`
+	Problems   []ConsistencyProblem `json:"problems"`   // Diagrams with errors or warnings
+	Unreadable []UnreadableDiagram  `json:"unreadable"` // Files that do not load
+	Changes    []ConsistencyChange  `json:"changes"`    // Error counts that differ from the previous report
+}
+
+// ConsistencyProblem lists the errors and warnings of one diagram
+type ConsistencyProblem struct {
+	DiagramID string                   `json:"diagramId"`
+	Name      string                   `json:"name"`
+	Path      string                   `json:"path"` // Relative to the diagrams path
+	Errors    []models.ValidationError `json:"errors"`
+	Warnings  []models.ValidationError `json:"warnings"`
+}
+
+// UnreadableDiagram is a diagram file that does not load; each counts as
+// one error
+type UnreadableDiagram struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ConsistencyChange is a diagram or file whose error count changed since
+// the previous report
+type ConsistencyChange struct {
+	DiagramID string `json:"diagramId,omitempty"` // Empty for unreadable files
+	Name      string `json:"name,omitempty"`
+	Path      string `json:"path"`
+	Before    int    `json:"before"`
+	After     int    `json:"after"`
+}
+
+// The latest report is kept in memory and in CONSISTENCY_REPORT_PATH; runs
+// do not overlap
+var (
+	consistencyMu     sync.Mutex
+	consistencyReport *ConsistencyReport
+)
+
+// ConsistencyService checks the diagram set as a whole
+type ConsistencyService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+}
+
+// NewConsistencyService creates a new consistency service
+func NewConsistencyService() *ConsistencyService {
+	return &ConsistencyService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+	}
+}
+
+// Latest returns the latest report, from the report file if no check ran
+// since the server started. It returns nil when there is none.
+func (s *ConsistencyService) Latest() (*ConsistencyReport, error) {
+	consistencyMu.Lock()
+	defer consistencyMu.Unlock()
+	return s.latest()
+}
+
+func (s *ConsistencyService) latest() (*ConsistencyReport, error) {
+	if consistencyReport != nil || s.cfg.ConsistencyReportPath == "" {
+		return consistencyReport, nil
+	}
+	data, err := os.ReadFile(s.cfg.ConsistencyReportPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consistency report: %w", err)
+	}
+	var report ConsistencyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse consistency report: %w", err)
+	}
+	consistencyReport = &report
+	return consistencyReport, nil
+}
+
+// Run re-validates all diagrams and their references, records each
+// diagram's validation status, writes the report and notifies owners and
+// subscribers of diagrams whose error count changed
+func (s *ConsistencyService) Run() (*ConsistencyReport, error) {
+	consistencyMu.Lock()
+	defer consistencyMu.Unlock()
+
+	previous, err := s.latest()
+	if err != nil {
+		log.Printf("Consistency check ignores the previous report: %v", err)
+	}
+	report, diagrams, err := s.check()
+	if err != nil {
+		return nil, err
+	}
+	report.Changes = consistencyChanges(previous, report)
+
+	if s.cfg.ConsistencyReportPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal consistency report: %w", err)
+		}
+		if err := os.WriteFile(s.cfg.ConsistencyReportPath, data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write consistency report: %w", err)
+		}
+	}
+	consistencyReport = report
+
+	if previous != nil && len(report.Changes) > 0 {
+		log.Printf("Consistency check: %d errors in %d diagrams, %d changed since %s",
+			report.Errors, report.Diagrams, len(report.Changes), previous.CheckedAt.Format(time.RFC3339))
+		s.notifyChanges(report, diagrams)
+	}
+	return report, nil
+}
+
+// check builds a report without comparing it to the previous one
+func (s *ConsistencyService) check() (*ConsistencyReport, map[string]*models.FlowDiagram, error) {
+	report := &ConsistencyReport{CheckedAt: time.Now().UTC(), Problems: []ConsistencyProblem{}, Unreadable: []UnreadableDiagram{}, Changes: []ConsistencyChange{}}
+	var loaded []*models.FlowDiagram
+	err := s.diagramService.walkDiagramFiles(func(path string, diagram *models.FlowDiagram, err error) {
+		if err != nil {
+			report.Unreadable = append(report.Unreadable, UnreadableDiagram{Path: s.relativePath(path), Error: err.Error()})
+			return
+		}
+		loaded = append(loaded, diagram)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[string]*models.FlowDiagram, len(loaded))
+	for _, diagram := range loaded {
+		if _, ok := byID[diagram.ID]; !ok {
+			byID[diagram.ID] = diagram
+		}
+	}
+	cycles := hierarchyCycles(byID)
+	for _, diagram := range loaded {
+		result, err := s.diagramService.Validate(diagram)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.diagramService.RecordValidation(diagram, result); err != nil {
+			log.Printf("Recording validation of %s failed: %v", diagram.ID, err)
+		}
+		errs := append(result.Errors, crossReferenceErrors(diagram, byID)...)
+		if cycle, ok := cycles[diagram.ID]; ok && byID[diagram.ID] == diagram {
+			errs = append(errs, models.ValidationError{
+				Path:    "children",
+				Message: fmt.Sprintf("Diagram is part of the hierarchy cycle %s", strings.Join(cycle, " → ")),
+				Code:    "HIERARCHY_CYCLE",
+				Value:   cycle,
+			})
+		}
+		report.Errors += len(errs)
+		report.Warnings += len(result.Warnings)
+		if len(errs) > 0 || len(result.Warnings) > 0 {
+			report.Problems = append(report.Problems, ConsistencyProblem{
+				DiagramID: diagram.ID,
+				Name:      diagram.Name,
+				Path:      s.relativePath(diagram.FilePath),
+				Errors:    errs,
+				Warnings:  result.Warnings,
+			})
+		}
+	}
+	report.Diagrams = len(loaded)
+	report.Errors += len(report.Unreadable)
+	sort.Slice(report.Problems, func(i, j int) bool { return report.Problems[i].Path < report.Problems[j].Path })
+	sort.Slice(report.Unreadable, func(i, j int) bool { return report.Unreadable[i].Path < report.Unreadable[j].Path })
+	return report, byID, nil
+}
+
+func (s *ConsistencyService) relativePath(path string) string {
+	if rel, err := filepath.Rel(s.cfg.DiagramsPath, path); err == nil && isBelow(s.cfg.DiagramsPath, path) {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// crossReferenceErrors checks that the diagrams a diagram refers to exist
+// and that its children name it as their parent. IDs used by more than one
+// file are reported on every file after the first.
+func crossReferenceErrors(diagram *models.FlowDiagram, byID map[string]*models.FlowDiagram) []models.ValidationError {
+	errs := []models.ValidationError{}
+	missing := func(path, code, what, id string) {
+		errs = append(errs, models.ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("%s %s does not exist", what, id),
+			Code:    code,
+			Value:   id,
+		})
+	}
+
+	if first := byID[diagram.ID]; first != diagram {
+		errs = append(errs, models.ValidationError{
+			Path:    "id",
+			Message: fmt.Sprintf("Diagram ID %s is also used by %s", diagram.ID, filepath.Base(first.FilePath)),
+			Code:    "DUPLICATE_DIAGRAM_ID",
+			Value:   diagram.ID,
+		})
+	}
+	if diagram.Parent != nil && *diagram.Parent != "" && byID[*diagram.Parent] == nil {
+		missing("parent", "UNKNOWN_PARENT", "Parent diagram", *diagram.Parent)
+	}
+	for i, id := range diagram.Parents {
+		if id != "" && byID[id] == nil {
+			missing(fmt.Sprintf("parents[%d]", i), "UNKNOWN_PARENT", "Parent diagram", id)
+		}
+	}
+	for i, id := range diagram.Children {
+		child := byID[id]
+		if child == nil {
+			missing(fmt.Sprintf("children[%d]", i), "UNKNOWN_CHILD", "Child diagram", id)
+		} else if !containsValue(child.ParentIDs(), diagram.ID) {
+			errs = append(errs, models.ValidationError{
+				Path:    fmt.Sprintf("children[%d]", i),
+				Message: fmt.Sprintf("Child diagram %s does not name %s as its parent", id, diagram.ID),
+				Code:    "CHILD_PARENT_MISMATCH",
+				Value:   id,
+			})
+		}
+	}
+	for i, relation := range diagram.Relations {
+		if relation.Target != "" && byID[relation.Target] == nil {
+			missing(fmt.Sprintf("relations[%d].target", i), "UNKNOWN_RELATION_TARGET", "Related diagram", relation.Target)
+		}
+	}
+	for i, node := range diagram.Nodes {
+		if node.DrillDown == nil || *node.DrillDown == "" {
+			continue
+		}
+		if target := byID[*node.DrillDown]; target == nil {
+			missing(fmt.Sprintf("nodes[%d].drillDown", i), "UNKNOWN_DRILL_DOWN", "Drill-down diagram", *node.DrillDown)
+		} else if node.DrillDownFocus != nil {
+			errs = append(errs, drillDownFocusErrors(fmt.Sprintf("nodes[%d].drillDownFocus", i), node.DrillDownFocus, target)...)
+		}
+	}
+	if diagram.Deprecated != nil && diagram.Deprecated.SupersededBy != nil && *diagram.Deprecated.SupersededBy != "" &&
+		byID[*diagram.Deprecated.SupersededBy] == nil {
+		missing("deprecated.supersededBy", "UNKNOWN_SUCCESSOR", "Successor diagram", *diagram.Deprecated.SupersededBy)
+	}
+	return errs
+}
+
+// consistencyChanges compares the error counts per file of two reports
+func consistencyChanges(previous, report *ConsistencyReport) []ConsistencyChange {
+	changes := []ConsistencyChange{}
+	if previous == nil {
+		return changes
+	}
+	counts := func(r *ConsistencyReport) map[string]ConsistencyChange {
+		byPath := map[string]ConsistencyChange{}
+		for _, problem := range r.Problems {
+			byPath[problem.Path] = ConsistencyChange{DiagramID: problem.DiagramID, Name: problem.Name, Path: problem.Path, After: len(problem.Errors)}
+		}
+		for _, file := range r.Unreadable {
+			byPath[file.Path] = ConsistencyChange{Path: file.Path, After: 1}
+		}
+		return byPath
+	}
+	before, after := counts(previous), counts(report)
+	for path, old := range before {
+		current, ok := after[path]
+		if !ok {
+			current = ConsistencyChange{DiagramID: old.DiagramID, Name: old.Name, Path: path}
+		}
+		if current.After != old.After {
+			current.Before = old.After
+			changes = append(changes, current)
+		}
+	}
+	for path, current := range after {
+		if _, ok := before[path]; !ok && current.After > 0 {
+			changes = append(changes, current)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// notifyChanges mails the owners and subscribers of diagrams whose error
+// count changed, one message per person. It does nothing unless SMTP and
+// a directory are configured.
+func (s *ConsistencyService) notifyChanges(report *ConsistencyReport, diagrams map[string]*models.FlowDiagram) {
+	if s.cfg.SMTPHost == "" || s.cfg.DirectoryPath == "" {
+		return
+	}
+	notifications := NewNotificationService()
+	directory, err := requireDirectory(s.diagramService)
+	if err != nil {
+		log.Printf("Consistency notifications failed: %v", err)
+		return
+	}
+
+	byPerson := make(map[string][]ConsistencyChange)
+	for _, change := range report.Changes {
+		if change.DiagramID == "" {
+			continue
+		}
+		recipients := []string{}
+		if diagram := diagrams[change.DiagramID]; diagram != nil {
+			recipients = append(diagram.Ownership.Assignments(models.RoleOwner), diagram.Ownership.Assignments(models.RoleAccountable)...)
+		}
+		if subscribers, err := notifications.Subscribers(change.DiagramID); err == nil {
+			recipients = append(recipients, subscribers...)
+		}
+		for _, id := range expandRecipients(directory, recipients) {
+			byPerson[id] = append(byPerson[id], change)
+		}
+	}
+
+	people := make([]string, 0, len(byPerson))
+	for id := range byPerson {
+		people = append(people, id)
+	}
+	sort.Strings(people)
+	for _, id := range people {
+		data := NotificationData{Changes: byPerson[id], Time: report.CheckedAt}
+		if _, err := notifications.Notify(models.EventConsistencyChanged, []string{id}, data); err != nil {
+			log.Printf("Consistency notification to %s failed: %v", id, err)
+		}
+	}
+}
+
+// StartConsistencyChecks checks the diagram set at startup and then every
+// CONSISTENCY_INTERVAL when it is set
+func StartConsistencyChecks() {
+	s := NewConsistencyService()
+	if s.cfg.ConsistencyInterval <= 0 {
+		return
+	}
+	log.Printf("Consistency checks every %s, reported to %s", s.cfg.ConsistencyInterval, s.cfg.ConsistencyReportPath)
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.ConsistencyInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := NewConsistencyService().Run(); err != nil {
+				log.Printf("Consistency check failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// SearchContent searches the raw YAML text of every diagram file, including
+// comments, metadata values and edge conditions that the structured searches
+// do not cover. Matching is case-insensitive and reports 1-based positions.
+// Archived diagrams are left out unless includeArchived is set.
+func (s *DiagramService) SearchContent(query string, includeArchived bool) ([]models.ContentSearchResult, error) {
+	results := []models.ContentSearchResult{}
+	if query == "" {
+		return results, nil
+	}
+	// Lowercasing can change the byte length of a line, so matching is done
+	// on the line itself to keep columns right
+	needle := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+
+	err := s.walkDiagramPaths(func(path string) error {
+		if !includeArchived && s.isArchivedPath(path) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error reading diagram file %s: %v\n", path, err)
+			return nil
+		}
+
+		// Resolve the diagram ID if the file parses; matches in broken
+		// files are still reported so they can be located and fixed
+		var header struct {
+			ID string `yaml:"id"`
+		}
+		_ = yaml.Unmarshal(data, &header)
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+		lineNo := 0
+		for scanner.Scan() {
+			lineNo++
+			line := scanner.Text()
+			for _, match := range needle.FindAllStringIndex(line, -1) {
+				results = append(results, models.ContentSearchResult{
+					DiagramID: header.ID,
+					FilePath:  path,
+					Line:      lineNo,
+					Column:    match[0] + 1,
+					Text:      strings.TrimRight(line, "\r"),
+				})
+			}
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search diagram content: %w", err)
+	}
+
+	return results, nil
+}
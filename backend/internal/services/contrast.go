@@ -0,0 +1,197 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// WCAG AA minimum contrast ratios between text and its background
+const (
+	minContrastNormal = 4.5
+	minContrastLarge  = 3.0 // Text of at least 24px, or 18.66px in bold
+)
+
+// nodeTextColors returns the effective fill and text color of a node with a
+// resolved style, and whether its label counts as large text
+func nodeTextColors(node *models.FlowNode) (fill, text string, large bool) {
+	fill, _ = nodeColors(node.Type)
+	text = "#ffffff"
+	fontSize, bold := 14.0, false
+	if st := node.Style; st != nil {
+		if st.Fill != nil {
+			fill = *st.Fill
+		}
+		if st.TextColor != nil {
+			text = *st.TextColor
+		}
+		if st.FontSize != nil {
+			fontSize = *st.FontSize
+		}
+		if st.FontWeight != nil {
+			weight, err := strconv.Atoi(*st.FontWeight)
+			bold = *st.FontWeight == "bold" || *st.FontWeight == "bolder" || (err == nil && weight >= 700)
+		}
+	}
+	return fill, text, fontSize >= 24 || (bold && fontSize >= 18.66)
+}
+
+// requiredContrast is the WCAG AA ratio for normal or large text
+func requiredContrast(large bool) float64 {
+	if large {
+		return minContrastLarge
+	}
+	return minContrastNormal
+}
+
+// hexColor parses a CSS hex color into RGB components between 0 and 1
+func hexColor(color string) (rgb [3]float64, ok bool) {
+	hex := strings.TrimSpace(color)
+	if !strings.HasPrefix(hex, "#") {
+		return rgb, false
+	}
+	hex = hex[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return rgb, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return rgb, false
+	}
+	return [3]float64{float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255}, true
+}
+
+func formatHexColor(rgb [3]float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round(rgb[0]*255)), int(math.Round(rgb[1]*255)), int(math.Round(rgb[2]*255)))
+}
+
+// relativeLuminance follows the WCAG 2 definition
+func relativeLuminance(rgb [3]float64) float64 {
+	var linear [3]float64
+	for i, c := range rgb {
+		if c <= 0.03928 {
+			linear[i] = c / 12.92
+		} else {
+			linear[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*linear[0] + 0.7152*linear[1] + 0.0722*linear[2]
+}
+
+func contrastRatio(a, b [3]float64) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// compliantTextColor returns the color closest to text that reaches the
+// required contrast against fill, found by mixing text with black or with
+// white as little as possible. One of the two always reaches AA.
+func compliantTextColor(text, fill [3]float64, required float64) [3]float64 {
+	mix := func(target float64, t float64) [3]float64 {
+		var out [3]float64
+		for i := range text {
+			out[i] = text[i] + (target-text[i])*t
+		}
+		// Round to what formatHexColor writes so the check holds for the saved color
+		for i := range out {
+			out[i] = math.Round(out[i]*255) / 255
+		}
+		return out
+	}
+	best, bestT := [3]float64{}, math.Inf(1)
+	for _, target := range []float64{0, 1} {
+		for step := 0; step <= 100; step++ {
+			t := float64(step) / 100
+			if t >= bestT {
+				break
+			}
+			if candidate := mix(target, t); contrastRatio(candidate, fill) >= required {
+				best, bestT = candidate, t
+				break
+			}
+		}
+	}
+	return best
+}
+
+// validateContrast warns about node labels whose text color does not
+// contrast enough with the node's fill. Only nodes that set a fill or text
+// color are checked, since the default palette is not configurable per
+// diagram; colors other than hex colors are skipped.
+func validateContrast(result *models.ValidationResult, diagram *models.FlowDiagram, theme *models.StyleTheme) {
+	named := namedStyles(diagram, theme)
+	for i := range diagram.Nodes {
+		node := diagram.Nodes[i]
+		style, err := resolveStyle(node.Style, named)
+		if err != nil || style == nil || (style.Fill == nil && style.TextColor == nil) {
+			continue
+		}
+		node.Style = style
+		fill, text, large := nodeTextColors(&node)
+		fillRGB, okFill := hexColor(fill)
+		textRGB, okText := hexColor(text)
+		if !okFill || !okText {
+			continue
+		}
+		ratio := contrastRatio(textRGB, fillRGB)
+		if required := requiredContrast(large); ratio < required {
+			result.Warnings = append(result.Warnings, models.ValidationError{
+				Path:    fmt.Sprintf("nodes[%d].style", i),
+				Message: fmt.Sprintf("Text color %s on fill %s has a contrast ratio of %.2f:1; WCAG AA requires %.1f:1", text, fill, ratio, required),
+				Code:    "LOW_CONTRAST",
+				Value:   math.Round(ratio*100) / 100,
+			})
+		}
+	}
+}
+
+// fixContrast sets the text color of the given nodes, or of the nodes
+// validation checks, to the nearest color that meets WCAG AA against their
+// fill where the current one does not
+func fixContrast(diagram *models.FlowDiagram, nodeIDs []string, theme *models.StyleTheme) error {
+	selected := map[string]bool{}
+	for _, id := range nodeIDs {
+		if findNode(diagram, id) == nil {
+			return fmt.Errorf("node %q not found", id)
+		}
+		selected[id] = true
+	}
+	named := namedStyles(diagram, theme)
+	for i := range diagram.Nodes {
+		node := &diagram.Nodes[i]
+		if len(selected) > 0 && !selected[node.ID] {
+			continue
+		}
+		style, err := resolveStyle(node.Style, named)
+		if err != nil || (len(selected) == 0 && (style == nil || (style.Fill == nil && style.TextColor == nil))) {
+			continue
+		}
+		resolved := *node
+		resolved.Style = style
+		fill, text, large := nodeTextColors(&resolved)
+		fillRGB, okFill := hexColor(fill)
+		textRGB, okText := hexColor(text)
+		if !okFill || !okText || contrastRatio(textRGB, fillRGB) >= requiredContrast(large) {
+			continue
+		}
+		color := formatHexColor(compliantTextColor(textRGB, fillRGB, requiredContrast(large)))
+		if node.Style == nil {
+			node.Style = &models.Style{}
+		} else {
+			// The style may be shared with other copies of the diagram
+			copied := *node.Style
+			node.Style = &copied
+		}
+		node.Style.TextColor = &color
+	}
+	return nil
+}
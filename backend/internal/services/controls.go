@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// controlsFile caches the parsed control catalog, which every validation
+// reads
+var controlsFile cachedYAMLFile[models.ControlCatalog]
+
+// ControlCatalog loads the configured compliance control catalog. It returns
+// nil without error when no catalog is configured, in which case control
+// references are not checked. The catalog is shared between callers and
+// must not be modified.
+func (s *DiagramService) ControlCatalog() (*models.ControlCatalog, error) {
+	if s.cfg.ControlsPath == "" {
+		return nil, nil
+	}
+	return controlsFile.load(s.cfg.ControlsPath, "control catalog")
+}
+
+// validateControls checks a node's control references against the catalog
+func validateControls(result *models.ValidationResult, path string, controls []models.ControlRef, catalog *models.ControlCatalog) {
+	for i, ref := range controls {
+		refPath := fmt.Sprintf("%s.controls[%d]", path, i)
+		if ref.ID == "" {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    refPath + ".id",
+				Message: "Control ID is required",
+				Code:    "MISSING_CONTROL_ID",
+			})
+			continue
+		}
+		if catalog == nil {
+			continue
+		}
+		control, ok := catalog.Find(ref.ID)
+		if !ok {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    refPath + ".id",
+				Message: fmt.Sprintf("Unknown control: %s", ref.ID),
+				Code:    "UNKNOWN_CONTROL",
+				Value:   ref.ID,
+			})
+			continue
+		}
+		if ref.Framework != "" && !strings.EqualFold(ref.Framework, control.Framework) {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    refPath + ".framework",
+				Message: fmt.Sprintf("Control %s belongs to framework %s", ref.ID, control.Framework),
+				Code:    "CONTROL_FRAMEWORK_MISMATCH",
+				Value:   ref.Framework,
+			})
+		}
+	}
+}
+
+// ControlMatrix maps each control to the process steps that implement it,
+// across all diagrams. Catalog controls without any step are included with
+// an empty step list so gaps show up in the report. framework optionally
+// restricts the matrix to one framework.
+func (s *DiagramService) ControlMatrix(framework string) ([]models.ControlMatrixRow, error) {
+	catalog, err := s.ControlCatalog()
+	if err != nil {
+		return nil, err
+	}
+	diagrams, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]*models.ControlMatrixRow)
+	row := func(id, fw string) *models.ControlMatrixRow {
+		if r, ok := rows[id]; ok {
+			return r
+		}
+		r := &models.ControlMatrixRow{ControlID: id, Framework: fw, Steps: []models.ControlStep{}}
+		if catalog != nil {
+			if control, ok := catalog.Find(id); ok {
+				r.Framework = control.Framework
+				r.Name = control.Name
+			}
+		}
+		rows[id] = r
+		return r
+	}
+
+	if catalog != nil {
+		for _, control := range catalog.Controls {
+			row(control.ID, control.Framework)
+		}
+	}
+	for _, diagram := range diagrams {
+		for _, node := range diagram.Nodes {
+			for _, ref := range node.Controls {
+				if ref.ID == "" {
+					continue
+				}
+				r := row(ref.ID, ref.Framework)
+				r.Steps = append(r.Steps, models.ControlStep{
+					DiagramID:   diagram.ID,
+					DiagramName: diagram.Name,
+					NodeID:      node.ID,
+					NodeName:    node.Name,
+					Evidence:    ref.Evidence,
+				})
+			}
+		}
+	}
+
+	matrix := []models.ControlMatrixRow{}
+	for _, r := range rows {
+		if framework != "" && !strings.EqualFold(r.Framework, framework) {
+			continue
+		}
+		matrix = append(matrix, *r)
+	}
+	sort.Slice(matrix, func(i, j int) bool {
+		if matrix[i].Framework != matrix[j].Framework {
+			return matrix[i].Framework < matrix[j].Framework
+		}
+		return matrix[i].ControlID < matrix[j].ControlID
+	})
+	return matrix, nil
+}
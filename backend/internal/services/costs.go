@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// PathCost is the cost of one execution along a path
+type PathCost struct {
+	Nodes       []string `json:"nodes"`
+	Cost        float64  `json:"cost"`
+	Probability float64  `json:"probability"` // Chance an execution takes this path
+}
+
+// NodeCost is a node's share of the expected cost per execution
+type NodeCost struct {
+	NodeID         string  `json:"nodeId"`
+	Cost           float64 `json:"cost"`                // Cost of one visit
+	FromChild      string  `json:"fromChild,omitempty"` // Drill-down diagram the cost was rolled up from
+	ExpectedVisits float64 `json:"expectedVisits"`      // Visits per execution, counting loops
+	ExpectedCost   float64 `json:"expectedCost"`        // Cost × expected visits
+}
+
+// CostReport rolls up node costs for a diagram
+type CostReport struct {
+	DiagramID    string     `json:"diagramId"`
+	Currency     string     `json:"currency,omitempty"`
+	ExpectedCost float64    `json:"expectedCost"` // Probability-weighted cost per execution
+	MinPathCost  float64    `json:"minPathCost"`
+	MaxPathCost  float64    `json:"maxPathCost"`
+	Paths        []PathCost `json:"paths"`
+	Nodes        []NodeCost `json:"nodes"`
+	Truncated    bool       `json:"truncated,omitempty"`
+}
+
+// Costs sums node costs per path and per diagram. Branches are weighted by
+// edge probabilities, split evenly where none are given. Subprocess nodes
+// without a cost of their own take the expected cost of their drill-down
+// diagram.
+func (s *DiagramService) Costs(id string) (*CostReport, error) {
+	return s.costs(id, map[string]bool{})
+}
+
+func (s *DiagramService) costs(id string, visiting map[string]bool) (*CostReport, error) {
+	if visiting[id] {
+		return nil, fmt.Errorf("%w: drill-down cycle through %s", ErrInvalidDiagram, id)
+	}
+	visiting[id] = true
+	defer delete(visiting, id)
+
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CostReport{
+		DiagramID: diagram.ID,
+		Paths:     []PathCost{},
+	}
+	report.Nodes, report.Currency, err = s.nodeCosts(diagram, visiting)
+	if err != nil {
+		return nil, err
+	}
+	nodeCosts := make(map[string]float64)
+	for _, entry := range report.Nodes {
+		nodeCosts[entry.NodeID] = entry.Cost
+	}
+
+	visits, err := expectedVisits(diagram)
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Nodes {
+		entry := &report.Nodes[i]
+		entry.ExpectedVisits = visits[entry.NodeID]
+		entry.ExpectedCost = entry.Cost * entry.ExpectedVisits
+		report.ExpectedCost += entry.ExpectedCost
+	}
+
+	probs := branchProbabilities(diagram)
+	edgeProb := make(map[*models.FlowEdge]float64)
+	for i := range diagram.Edges {
+		edgeProb[&diagram.Edges[i]] = probs[i]
+	}
+
+	entries := len(entryNodes(diagram))
+	paths, truncated := enumeratePaths(diagram)
+	report.Truncated = truncated
+	for i, path := range paths {
+		cost := 0.0
+		for _, node := range path.Nodes {
+			cost += nodeCosts[node.ID]
+		}
+		probability := 1 / float64(entries)
+		for _, edge := range path.Edges {
+			probability *= edgeProb[edge]
+		}
+		report.Paths = append(report.Paths, PathCost{
+			Nodes:       path.NodeIDs(),
+			Cost:        cost,
+			Probability: probability,
+		})
+		if i == 0 || cost < report.MinPathCost {
+			report.MinPathCost = cost
+		}
+		if cost > report.MaxPathCost {
+			report.MaxPathCost = cost
+		}
+	}
+
+	return report, nil
+}
+
+// nodeCosts resolves the cost of one visit to each node, rolling up
+// drill-down diagrams for subprocesses without a cost of their own, and
+// returns the currency they share
+func (s *DiagramService) nodeCosts(diagram *models.FlowDiagram, visiting map[string]bool) ([]NodeCost, string, error) {
+	entries := []NodeCost{}
+	currency := ""
+	useCurrency := func(c string) error {
+		switch {
+		case c == "":
+		case currency == "":
+			currency = c
+		case c != currency:
+			return fmt.Errorf("%w: mixed currencies %s and %s", ErrInvalidDiagram, currency, c)
+		}
+		return nil
+	}
+
+	for _, node := range diagram.Nodes {
+		entry := NodeCost{NodeID: node.ID}
+		switch {
+		case node.Cost != nil:
+			entry.Cost = node.Cost.Amount
+			if err := useCurrency(node.Cost.Currency); err != nil {
+				return nil, "", err
+			}
+		case node.DrillDown != nil && *node.DrillDown != "":
+			child, err := s.costs(*node.DrillDown, visiting)
+			if err == ErrDiagramNotFound {
+				break
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			entry.Cost = child.ExpectedCost
+			entry.FromChild = child.DiagramID
+			if err := useCurrency(child.Currency); err != nil {
+				return nil, "", err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, currency, nil
+}
+
+// validateCost checks that a node cost is not negative
+func validateCost(result *models.ValidationResult, path string, cost *models.Cost) {
+	if cost != nil && cost.Amount < 0 {
+		result.Errors = append(result.Errors, models.ValidationError{
+			Path:    path + ".cost.amount",
+			Message: fmt.Sprintf("Cost cannot be negative: %v", cost.Amount),
+			Code:    "INVALID_COST",
+			Value:   cost.Amount,
+		})
+	}
+}
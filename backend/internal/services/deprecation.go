@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// deprecatedScoreFactor scales the search score of deprecated diagrams so
+// their successors rank first
+const deprecatedScoreFactor = 0.25
+
+// DeprecateOptions describes why a diagram is deprecated
+type DeprecateOptions struct {
+	SupersededBy string // ID of the successor diagram; optional
+	Reason       string
+}
+
+// Deprecate marks a diagram as deprecated. When a successor is given it
+// also records a supersedes relation on the successor, replacing the one
+// left by an earlier deprecation.
+func (s *DiagramService) Deprecate(id string, opts DeprecateOptions) (*models.FlowDiagram, error) {
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SupersededBy == id {
+		return nil, fmt.Errorf("%w: a diagram cannot supersede itself", ErrInvalidOptions)
+	}
+	if opts.SupersededBy != "" {
+		if _, err := s.GetByID(opts.SupersededBy); err != nil {
+			if err == ErrDiagramNotFound {
+				return nil, fmt.Errorf("%w: successor diagram %s not found", ErrInvalidOptions, opts.SupersededBy)
+			}
+			return nil, err
+		}
+	}
+
+	previous := diagram.Deprecated
+	deprecation := &models.Deprecation{Since: time.Now().UTC().Truncate(time.Second)}
+	if previous != nil {
+		deprecation.Since = previous.Since
+	}
+	if opts.Reason != "" {
+		deprecation.Reason = &opts.Reason
+	}
+	if opts.SupersededBy != "" {
+		deprecation.SupersededBy = &opts.SupersededBy
+	}
+	diagram.Deprecated = deprecation
+
+	updated, err := s.Update(diagram)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil && previous.SupersededBy != nil && *previous.SupersededBy != opts.SupersededBy {
+		if err := s.setSupersedes(*previous.SupersededBy, id, false); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SupersededBy != "" {
+		if err := s.setSupersedes(opts.SupersededBy, id, true); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// Undeprecate clears the deprecation of a diagram and the supersedes
+// relation of its successor
+func (s *DiagramService) Undeprecate(id string) (*models.FlowDiagram, error) {
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	previous := diagram.Deprecated
+	if previous == nil {
+		return diagram, nil
+	}
+	diagram.Deprecated = nil
+
+	updated, err := s.Update(diagram)
+	if err != nil {
+		return nil, err
+	}
+	if previous.SupersededBy != nil {
+		if err := s.setSupersedes(*previous.SupersededBy, id, false); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// setSupersedes adds or removes the supersedes relation from a successor to
+// the diagram it replaces. A successor that has since been deleted is
+// ignored.
+func (s *DiagramService) setSupersedes(successorID, id string, present bool) error {
+	successor, err := s.GetByID(successorID)
+	if err != nil {
+		if err == ErrDiagramNotFound {
+			return nil
+		}
+		return err
+	}
+
+	relations := []models.Relation{}
+	found := false
+	for _, relation := range successor.Relations {
+		if relation.Type == models.RelationSupersedes && relation.Target == id {
+			found = true
+			continue
+		}
+		relations = append(relations, relation)
+	}
+	if found == present {
+		return nil
+	}
+	if present {
+		relations = append(relations, models.Relation{Type: models.RelationSupersedes, Target: id})
+	}
+	successor.Relations = relations
+	_, err = s.Update(successor)
+	return err
+}
+
+// validateDeprecation checks that a deprecated diagram does not name itself
+// as its successor
+func validateDeprecation(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	if diagram.Deprecated == nil || diagram.Deprecated.SupersededBy == nil {
+		return
+	}
+	if *diagram.Deprecated.SupersededBy == diagram.ID {
+		result.Errors = append(result.Errors, models.ValidationError{
+			Path:    "deprecated.supersededBy",
+			Message: fmt.Sprintf("Diagram cannot supersede itself: %s", diagram.ID),
+			Code:    "INVALID_SUCCESSOR",
+			Value:   diagram.ID,
+		})
+	}
+}
+
+// deprecationNotice is the line exports print for a deprecated diagram, or
+// empty when the diagram is current
+func deprecationNotice(diagram *models.FlowDiagram) string {
+	if diagram.Deprecated == nil {
+		return ""
+	}
+	notice := "Deprecated"
+	if diagram.Deprecated.SupersededBy != nil {
+		notice += ", superseded by " + *diagram.Deprecated.SupersededBy
+	}
+	if diagram.Deprecated.Reason != nil && *diagram.Deprecated.Reason != "" {
+		notice += ": " + *diagram.Deprecated.Reason
+	}
+	return notice
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Editor commands
+const (
+	CommandInsertNodeAfter = "insert-node-after" // Insert a node between a node and its successors
+	CommandSplitEdge       = "split-edge"        // Insert a node in the middle of an edge
+	CommandReroute         = "reroute"           // Change the endpoints of an edge
+	CommandRemoveNode      = "remove-node"       // Delete a node, connecting its predecessors to its successors
+	CommandAlignSelection  = "align-selection"   // Line up nodes on a common edge or center; pinned nodes stay put
+	CommandNormalizeOrder  = "normalize-order"   // Compact z-indexes and routing priorities
+	CommandFixContrast     = "fix-contrast"      // Pick WCAG AA compliant text colors for node labels
+)
+
+// Alignments accepted by align-selection
+var commandAlignments = []string{"left", "center", "right", "top", "middle", "bottom"}
+
+// DiagramCommand is one editing operation. The fields used depend on Op.
+type DiagramCommand struct {
+	Op      string           `json:"op"`
+	NodeID  string           `json:"nodeId,omitempty"`  // insert-node-after: node to insert after; remove-node: node to remove
+	EdgeID  string           `json:"edgeId,omitempty"`  // split-edge, reroute
+	Node    *models.FlowNode `json:"node,omitempty"`    // insert-node-after, split-edge: node to insert
+	From    string           `json:"from,omitempty"`    // reroute: new source, unchanged when empty
+	To      string           `json:"to,omitempty"`      // reroute: new target, unchanged when empty
+	NodeIDs []string         `json:"nodeIds,omitempty"` // align-selection, fix-contrast
+	Align   string           `json:"align,omitempty"`   // align-selection: left, center, right, top, middle or bottom
+}
+
+// CommandResult is the diagram after a batch of commands. When the result
+// does not validate nothing is saved and Validation lists the problems.
+type CommandResult struct {
+	Diagram    models.FlowDiagram       `json:"diagram"`
+	Applied    int                      `json:"applied"`
+	Created    []string                 `json:"created"` // IDs of the nodes and edges added
+	Validation *models.ValidationResult `json:"validation,omitempty"`
+	Saved      bool                     `json:"saved"`
+}
+
+// ExecuteCommands applies editing commands in order and saves the diagram
+// once at the end. The batch is atomic: if any command fails or the result
+// does not validate, the diagram is left unchanged. With dryRun the result
+// is returned without saving.
+func (s *DiagramService) ExecuteCommands(id string, commands []DiagramCommand, dryRun bool) (*CommandResult, error) {
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("%w: at least one command is required", ErrInvalidOptions)
+	}
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	theme, err := s.StyleTheme()
+	if err != nil {
+		return nil, err
+	}
+	edgeIDs := newIDAllocator()
+	for _, edge := range diagram.Edges {
+		edgeIDs.used[edge.ID] = true
+	}
+	result := &CommandResult{Created: []string{}}
+	for i, command := range commands {
+		created, err := applyCommand(diagram, command, edgeIDs, theme)
+		if err != nil {
+			return nil, fmt.Errorf("%w: command %d (%s): %v", ErrInvalidOptions, i+1, command.Op, err)
+		}
+		result.Created = append(result.Created, created...)
+		result.Applied++
+	}
+
+	validation, err := s.Validate(diagram)
+	if err != nil {
+		return nil, err
+	}
+	if !validation.Valid {
+		result.Diagram, result.Validation = *diagram, validation
+		return result, nil
+	}
+	if dryRun {
+		result.Diagram = *diagram
+		return result, nil
+	}
+
+	updated, err := s.Update(diagram)
+	if err != nil {
+		return nil, err
+	}
+	result.Diagram, result.Saved = *updated, true
+	return result, nil
+}
+
+// applyCommand performs one command on the diagram in memory and returns
+// the IDs of the elements it created
+func applyCommand(diagram *models.FlowDiagram, command DiagramCommand, edgeIDs *idAllocator, theme *models.StyleTheme) ([]string, error) {
+	switch command.Op {
+	case CommandInsertNodeAfter:
+		anchor := findNode(diagram, command.NodeID)
+		if anchor == nil {
+			return nil, fmt.Errorf("node %q not found", command.NodeID)
+		}
+		node, err := newCommandNode(diagram, command.Node)
+		if err != nil {
+			return nil, err
+		}
+		if node.Position == (models.Position{}) {
+			node.Position = nextRankPosition(diagram, anchor)
+		}
+		anchorID := anchor.ID
+		for i := range diagram.Edges {
+			if diagram.Edges[i].From == anchorID {
+				diagram.Edges[i].From = node.ID
+				diagram.Edges[i].Waypoints = nil
+			}
+		}
+		edge := sequenceEdge(edgeIDs, anchorID, node.ID)
+		diagram.Nodes = append(diagram.Nodes, node)
+		diagram.Edges = append(diagram.Edges, edge)
+		return []string{node.ID, edge.ID}, nil
+
+	case CommandSplitEdge:
+		edge := findEdge(diagram, command.EdgeID)
+		if edge == nil {
+			return nil, fmt.Errorf("edge %q not found", command.EdgeID)
+		}
+		node, err := newCommandNode(diagram, command.Node)
+		if err != nil {
+			return nil, err
+		}
+		from, to := findNode(diagram, edge.From), findNode(diagram, edge.To)
+		if from == nil || to == nil {
+			return nil, fmt.Errorf("edge %q connects missing nodes", edge.ID)
+		}
+		if node.Position == (models.Position{}) {
+			fromX, fromY, fromW, fromH := nodeBounds(from)
+			toX, toY, toW, toH := nodeBounds(to)
+			_, _, w, h := nodeBounds(&node)
+			node.Position = models.Position{
+				X: ((fromX+fromW/2)+(toX+toW/2))/2 - w/2,
+				Y: ((fromY+fromH/2)+(toY+toH/2))/2 - h/2,
+			}
+		}
+		// The original edge keeps its label and style and now ends at the
+		// new node; a plain sequence edge continues to the old target
+		second := sequenceEdge(edgeIDs, node.ID, edge.To)
+		edge.To = node.ID
+		edge.Waypoints = nil
+		diagram.Nodes = append(diagram.Nodes, node)
+		diagram.Edges = append(diagram.Edges, second)
+		return []string{node.ID, second.ID}, nil
+
+	case CommandReroute:
+		edge := findEdge(diagram, command.EdgeID)
+		if edge == nil {
+			return nil, fmt.Errorf("edge %q not found", command.EdgeID)
+		}
+		if command.From == "" && command.To == "" {
+			return nil, fmt.Errorf("from or to is required")
+		}
+		for _, id := range []string{command.From, command.To} {
+			if id != "" && findNode(diagram, id) == nil {
+				return nil, fmt.Errorf("node %q not found", id)
+			}
+		}
+		if command.From != "" {
+			edge.From = command.From
+		}
+		if command.To != "" {
+			edge.To = command.To
+		}
+		edge.Waypoints = nil
+		return nil, nil
+
+	case CommandRemoveNode:
+		if findNode(diagram, command.NodeID) == nil {
+			return nil, fmt.Errorf("node %q not found", command.NodeID)
+		}
+		return removeNodeBridged(diagram, command.NodeID, edgeIDs), nil
+
+	case CommandAlignSelection:
+		return nil, alignNodes(diagram, command.NodeIDs, command.Align)
+
+	case CommandNormalizeOrder:
+		normalizeZOrder(diagram)
+		return nil, nil
+
+	case CommandFixContrast:
+		return nil, fixContrast(diagram, command.NodeIDs, theme)
+	}
+	return nil, fmt.Errorf("unknown command %q", command.Op)
+}
+
+// removeNodeBridged deletes a node and reconnects its predecessors to its
+// successors. A single outgoing edge is replaced by moving the incoming
+// edges to the successor, and a single incoming edge by moving the
+// outgoing edges to the predecessor, so labels and conditions survive;
+// otherwise every predecessor gets a sequence edge to every successor.
+// Self-loops and connections that already exist are not created. Moved
+// edges keep their place in the diagram. It returns the IDs of the edges
+// added.
+func removeNodeBridged(diagram *models.FlowDiagram, nodeID string, edgeIDs *idAllocator) []string {
+	var incoming, outgoing []*models.FlowEdge
+	connected := make(map[[2]string]bool)
+	for i := range diagram.Edges {
+		edge := &diagram.Edges[i]
+		switch {
+		case edge.From == nodeID && edge.To == nodeID:
+		case edge.To == nodeID:
+			incoming = append(incoming, edge)
+		case edge.From == nodeID:
+			outgoing = append(outgoing, edge)
+		default:
+			connected[[2]string{edge.From, edge.To}] = true
+		}
+	}
+	bridge := func(from, to string) bool {
+		key := [2]string{from, to}
+		if from == to || connected[key] {
+			return false
+		}
+		connected[key] = true
+		return true
+	}
+
+	moved := make(map[*models.FlowEdge]bool)
+	var added []models.FlowEdge
+	switch {
+	case len(outgoing) == 1:
+		for _, edge := range incoming {
+			if bridge(edge.From, outgoing[0].To) {
+				edge.To, edge.Waypoints = outgoing[0].To, nil
+				moved[edge] = true
+			}
+		}
+	case len(incoming) == 1:
+		for _, edge := range outgoing {
+			if bridge(incoming[0].From, edge.To) {
+				edge.From, edge.Waypoints = incoming[0].From, nil
+				moved[edge] = true
+			}
+		}
+	default:
+		for _, in := range incoming {
+			for _, out := range outgoing {
+				if bridge(in.From, out.To) {
+					added = append(added, sequenceEdge(edgeIDs, in.From, out.To))
+				}
+			}
+		}
+	}
+
+	edges := []models.FlowEdge{}
+	for i := range diagram.Edges {
+		edge := &diagram.Edges[i]
+		if moved[edge] || (edge.From != nodeID && edge.To != nodeID) {
+			edges = append(edges, *edge)
+		}
+	}
+	created := []string{}
+	for _, edge := range added {
+		edges = append(edges, edge)
+		created = append(created, edge.ID)
+	}
+	diagram.Edges = edges
+
+	nodes := []models.FlowNode{}
+	for _, node := range diagram.Nodes {
+		if node.ID != nodeID {
+			nodes = append(nodes, node)
+		}
+	}
+	diagram.Nodes = nodes
+	return created
+}
+
+func findNode(diagram *models.FlowDiagram, id string) *models.FlowNode {
+	for i := range diagram.Nodes {
+		if diagram.Nodes[i].ID == id {
+			return &diagram.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func findEdge(diagram *models.FlowDiagram, id string) *models.FlowEdge {
+	for i := range diagram.Edges {
+		if diagram.Edges[i].ID == id {
+			return &diagram.Edges[i]
+		}
+	}
+	return nil
+}
+
+// newCommandNode checks a node to be inserted; the type defaults to process
+func newCommandNode(diagram *models.FlowDiagram, node *models.FlowNode) (models.FlowNode, error) {
+	if node == nil || node.ID == "" {
+		return models.FlowNode{}, fmt.Errorf("node with an id is required")
+	}
+	if findNode(diagram, node.ID) != nil {
+		return models.FlowNode{}, fmt.Errorf("node %q already exists", node.ID)
+	}
+	inserted := *node
+	if inserted.Type == "" {
+		inserted.Type = models.NodeTypeProcess
+	}
+	if inserted.Name == "" {
+		inserted.Name = inserted.ID
+	}
+	return inserted, nil
+}
+
+func sequenceEdge(edgeIDs *idAllocator, from, to string) models.FlowEdge {
+	return models.FlowEdge{
+		FlowEntity: models.FlowEntity{ID: edgeIDs.allocate(from + "_" + to)},
+		Type:       models.ConnectionTypeSequence,
+		From:       from,
+		To:         to,
+	}
+}
+
+// nextRankPosition places a node one rank after anchor in the diagram's
+// layout direction
+func nextRankPosition(diagram *models.FlowDiagram, anchor *models.FlowNode) models.Position {
+	direction, gap := models.LayoutDirectionTopBottom, defaultRankSpacing
+	if layout := diagram.Layout; layout != nil {
+		if layout.Direction != nil {
+			direction = *layout.Direction
+		}
+		if layout.Spacing != nil && layout.Spacing.Rank != nil {
+			gap = *layout.Spacing.Rank
+		}
+	}
+	x, y, w, h := nodeBounds(anchor)
+	switch direction {
+	case models.LayoutDirectionBottomTop:
+		return models.Position{X: x, Y: y - defaultNodeHeight - gap}
+	case models.LayoutDirectionLeftRight:
+		return models.Position{X: x + w + gap, Y: y}
+	case models.LayoutDirectionRightLeft:
+		return models.Position{X: x - defaultNodeWidth - gap, Y: y}
+	}
+	return models.Position{X: x, Y: y + h + gap}
+}
+
+// alignNodes lines nodes up on the outermost edge (left, right, top,
+// bottom) or the average center (center, middle) of the selection. When
+// the selection contains pinned nodes only they determine the line, and
+// they are not moved.
+func alignNodes(diagram *models.FlowDiagram, ids []string, align string) error {
+	if len(ids) < 2 {
+		return fmt.Errorf("at least two nodes are required")
+	}
+	if !containsValue(commandAlignments, align) {
+		return fmt.Errorf("unknown alignment %q", align)
+	}
+	nodes := make([]*models.FlowNode, 0, len(ids))
+	for _, id := range ids {
+		node := findNode(diagram, id)
+		if node == nil {
+			return fmt.Errorf("node %q not found", id)
+		}
+		nodes = append(nodes, node)
+	}
+
+	var pinned []*models.FlowNode
+	for _, node := range nodes {
+		if node.Pinned {
+			pinned = append(pinned, node)
+		}
+	}
+	anchors := nodes
+	if len(pinned) > 0 {
+		anchors = pinned
+	}
+	values := make([]float64, len(anchors))
+	for i, node := range anchors {
+		x, y, w, h := nodeBounds(node)
+		values[i] = map[string]float64{
+			"left": x, "center": x + w/2, "right": x + w,
+			"top": y, "middle": y + h/2, "bottom": y + h,
+		}[align]
+	}
+	target := values[0]
+	for _, v := range values[1:] {
+		switch align {
+		case "left", "top":
+			target = math.Min(target, v)
+		case "right", "bottom":
+			target = math.Max(target, v)
+		default:
+			target += v
+		}
+	}
+	if align == "center" || align == "middle" {
+		target /= float64(len(values))
+	}
+
+	for _, node := range nodes {
+		if node.Pinned {
+			continue
+		}
+		_, _, w, h := nodeBounds(node)
+		switch align {
+		case "left":
+			node.Position.X = target
+		case "right":
+			node.Position.X = target - w
+		case "center":
+			node.Position.X = target - w/2
+		case "top":
+			node.Position.Y = target
+		case "bottom":
+			node.Position.Y = target - h
+		case "middle":
+			node.Position.Y = target - h/2
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Diff statuses
+const (
+	DiffAdded     = "added"
+	DiffRemoved   = "removed"
+	DiffChanged   = "changed"
+	DiffUnchanged = "unchanged"
+)
+
+// FieldChange is a field whose value differs between two versions
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// ElementChange lists the changed fields of a node or edge present in both
+// versions
+type ElementChange struct {
+	ID      string        `json:"id"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// ElementDiff compares the nodes or edges of two diagram versions by ID
+type ElementDiff struct {
+	Added   []string        `json:"added"`
+	Removed []string        `json:"removed"`
+	Changed []ElementChange `json:"changed"`
+}
+
+// Empty reports whether no element changed
+func (d ElementDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiagramDiff is the semantic difference between two versions of a
+// diagram. Fields covers diagram-level properties; timestamps, the recorded
+// validation status and the content hash are ignored so re-saving without
+// edits is not a change.
+type DiagramDiff struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Status string        `json:"status"`
+	Fields []FieldChange `json:"fields"`
+	Nodes  ElementDiff   `json:"nodes"`
+	Edges  ElementDiff   `json:"edges"`
+}
+
+// diffIgnoredFields are diagram properties that are not compared field by
+// field: nodes and edges are compared by ID, the rest is bookkeeping
+var diffIgnoredFields = map[string]bool{
+	"nodes": true, "edges": true, "created": true, "updated": true, "filePath": true,
+	"validation": true, "changedExternally": true, "contentHash": true, "changelog": true,
+	"archived": true, "root": true, "readOnly": true, "changeSummary": true,
+}
+
+// DiffDiagrams compares two versions of a diagram
+func DiffDiagrams(before, after *models.FlowDiagram) DiagramDiff {
+	diff := DiagramDiff{
+		ID:     after.ID,
+		Name:   after.Name,
+		Status: DiffUnchanged,
+		Fields: diffFields(toMap(before), toMap(after), diffIgnoredFields),
+	}
+
+	beforeNodes, afterNodes := []keyedElement{}, []keyedElement{}
+	for _, node := range before.Nodes {
+		beforeNodes = append(beforeNodes, keyedElement{node.ID, toMap(node)})
+	}
+	for _, node := range after.Nodes {
+		afterNodes = append(afterNodes, keyedElement{node.ID, toMap(node)})
+	}
+	diff.Nodes = diffElements(beforeNodes, afterNodes)
+
+	beforeEdges, afterEdges := []keyedElement{}, []keyedElement{}
+	for _, edge := range before.Edges {
+		beforeEdges = append(beforeEdges, keyedElement{edge.ID, toMap(edge)})
+	}
+	for _, edge := range after.Edges {
+		afterEdges = append(afterEdges, keyedElement{edge.ID, toMap(edge)})
+	}
+	diff.Edges = diffElements(beforeEdges, afterEdges)
+
+	if len(diff.Fields) > 0 || !diff.Nodes.Empty() || !diff.Edges.Empty() {
+		diff.Status = DiffChanged
+	}
+	return diff
+}
+
+// keyedElement is a node or edge in its JSON object form
+type keyedElement struct {
+	id     string
+	fields map[string]interface{}
+}
+
+// diffElements compares elements by ID. Added and changed elements are
+// listed in their order in the newer version, removed ones in their order
+// in the older version.
+func diffElements(before, after []keyedElement) ElementDiff {
+	diff := ElementDiff{Added: []string{}, Removed: []string{}, Changed: []ElementChange{}}
+	beforeByID := make(map[string]map[string]interface{})
+	for _, element := range before {
+		beforeByID[element.id] = element.fields
+	}
+	afterIDs := make(map[string]bool)
+	for _, element := range after {
+		afterIDs[element.id] = true
+		old, ok := beforeByID[element.id]
+		if !ok {
+			diff.Added = append(diff.Added, element.id)
+			continue
+		}
+		if changes := diffFields(old, element.fields, nil); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, ElementChange{ID: element.id, Changes: changes})
+		}
+	}
+	for _, element := range before {
+		if !afterIDs[element.id] {
+			diff.Removed = append(diff.Removed, element.id)
+		}
+	}
+	return diff
+}
+
+// diffFields compares two JSON object representations key by key
+func diffFields(before, after map[string]interface{}, ignored map[string]bool) []FieldChange {
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	changes := []FieldChange{}
+	for _, key := range sortedKeys(keys) {
+		if ignored[key] {
+			continue
+		}
+		if !reflect.DeepEqual(before[key], after[key]) {
+			changes = append(changes, FieldChange{Field: key, Before: before[key], After: after[key]})
+		}
+	}
+	return changes
+}
+
+// toMap converts a value to its JSON object form so that fields compare
+// the way API clients see them
+func toMap(v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	_ = json.Unmarshal(data, &m)
+	return m
+}
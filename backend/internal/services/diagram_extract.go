@@ -0,0 +1,219 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ExtractOptions describes a subgraph to move into a new child diagram
+type ExtractOptions struct {
+	NodeIDs   []string `json:"nodeIds"`
+	ChildID   string   `json:"childId"`
+	ChildName string   `json:"childName"`
+	NodeID    string   `json:"nodeId"`   // ID of the replacement subprocess node
+	NodeName  string   `json:"nodeName"` // Name of the replacement subprocess node
+}
+
+// ExtractResult holds both diagrams after an extraction
+type ExtractResult struct {
+	Parent models.FlowDiagram `json:"parent"`
+	Child  models.FlowDiagram `json:"child"`
+}
+
+// Extract moves the given nodes out of a diagram into a new child diagram.
+// The nodes are replaced in the parent by a subprocess node that drills down
+// into the child, and edges crossing the selection boundary are rewired to
+// that node. Inside the child, boundary edges become edges from a generated
+// start node and to a generated end node.
+func (s *DiagramService) Extract(parentID string, opts ExtractOptions) (*ExtractResult, error) {
+	if len(opts.NodeIDs) == 0 {
+		return nil, fmt.Errorf("at least one node ID is required")
+	}
+	if opts.ChildID == "" {
+		return nil, fmt.Errorf("child diagram ID is required")
+	}
+	if opts.ChildID == parentID {
+		return nil, fmt.Errorf("child diagram ID must differ from the parent")
+	}
+	if _, err := s.GetByID(opts.ChildID); err == nil {
+		return nil, fmt.Errorf("diagram %s already exists", opts.ChildID)
+	}
+
+	parent, err := s.GetByID(parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, id := range opts.NodeIDs {
+		selected[id] = true
+	}
+
+	existing := make(map[string]bool)
+	var moved []models.FlowNode
+	var kept []models.FlowNode
+	for _, node := range parent.Nodes {
+		existing[node.ID] = true
+		if selected[node.ID] {
+			moved = append(moved, node)
+		} else {
+			kept = append(kept, node)
+		}
+	}
+	for _, id := range opts.NodeIDs {
+		if !existing[id] {
+			return nil, fmt.Errorf("node %s not found in diagram %s", id, parentID)
+		}
+	}
+
+	if opts.NodeID == "" {
+		opts.NodeID = opts.ChildID
+	}
+	if existing[opts.NodeID] && !selected[opts.NodeID] {
+		return nil, fmt.Errorf("node ID %s is already used in diagram %s", opts.NodeID, parentID)
+	}
+	if opts.ChildName == "" {
+		opts.ChildName = opts.ChildID
+	}
+	if opts.NodeName == "" {
+		opts.NodeName = opts.ChildName
+	}
+
+	// Bounding box of the selection, used to place the replacement node and
+	// to move the extracted nodes to the child's origin
+	minX, minY := moved[0].Position.X, moved[0].Position.Y
+	maxX, maxY := minX, minY
+	for _, node := range moved {
+		x, y := node.Position.X, node.Position.Y
+		w, h := 0.0, 0.0
+		if node.Dimensions != nil {
+			w, h = node.Dimensions.Width, node.Dimensions.Height
+		}
+		if x < minX {
+			minX = x
+		}
+		if y < minY {
+			minY = y
+		}
+		if x+w > maxX {
+			maxX = x + w
+		}
+		if y+h > maxY {
+			maxY = y + h
+		}
+	}
+
+	const margin = 120.0
+	childID := opts.ChildID
+	child := models.FlowDiagram{
+		FlowEntity: models.FlowEntity{
+			ID:   childID,
+			Name: opts.ChildName,
+			Tags: parent.Tags,
+		},
+		Version: parent.Version,
+		Layout:  parent.Layout,
+		Parent:  &parent.ID,
+		Nodes:   []models.FlowNode{},
+		Edges:   []models.FlowEdge{},
+	}
+	for _, node := range moved {
+		node.Position.X = node.Position.X - minX + margin
+		node.Position.Y = node.Position.Y - minY + margin
+		child.Nodes = append(child.Nodes, node)
+	}
+
+	var parentEdges []models.FlowEdge
+	var incoming, outgoing []models.FlowEdge
+	for _, edge := range parent.Edges {
+		fromIn, toIn := selected[edge.From], selected[edge.To]
+		switch {
+		case fromIn && toIn:
+			child.Edges = append(child.Edges, edge)
+		case toIn:
+			incoming = append(incoming, edge)
+			rewired := edge
+			rewired.To = opts.NodeID
+			parentEdges = append(parentEdges, rewired)
+		case fromIn:
+			outgoing = append(outgoing, edge)
+			rewired := edge
+			rewired.From = opts.NodeID
+			parentEdges = append(parentEdges, rewired)
+		default:
+			parentEdges = append(parentEdges, edge)
+		}
+	}
+
+	// Boundary edges become entry and exit edges of the child
+	childNodeIDs := make(map[string]bool)
+	for _, node := range child.Nodes {
+		childNodeIDs[node.ID] = true
+	}
+	uniqueNodeID := func(base string) string {
+		id := base
+		for i := 2; childNodeIDs[id]; i++ {
+			id = fmt.Sprintf("%s_%d", base, i)
+		}
+		childNodeIDs[id] = true
+		return id
+	}
+	if len(incoming) > 0 {
+		startID := uniqueNodeID("start")
+		child.Nodes = append(child.Nodes, models.FlowNode{
+			FlowEntity: models.FlowEntity{ID: startID, Name: "Start"},
+			Type:       models.NodeTypeStart,
+			Position:   models.Position{X: margin, Y: 0},
+		})
+		for _, edge := range incoming {
+			edge.From = startID
+			child.Edges = append(child.Edges, edge)
+		}
+	}
+	if len(outgoing) > 0 {
+		endID := uniqueNodeID("end")
+		child.Nodes = append(child.Nodes, models.FlowNode{
+			FlowEntity: models.FlowEntity{ID: endID, Name: "End"},
+			Type:       models.NodeTypeEnd,
+			Position:   models.Position{X: margin, Y: maxY - minY + 2*margin},
+		})
+		for _, edge := range outgoing {
+			edge.To = endID
+			child.Edges = append(child.Edges, edge)
+		}
+	}
+
+	replacement := models.FlowNode{
+		FlowEntity: models.FlowEntity{ID: opts.NodeID, Name: opts.NodeName},
+		Type:       models.NodeTypeSubprocess,
+		Position:   models.Position{X: minX, Y: minY},
+		DrillDown:  &childID,
+	}
+	parent.Nodes = append(kept, replacement)
+	parent.Edges = parentEdges
+	parent.Children = append(parent.Children, childID)
+
+	// Validate both sides before writing anything
+	for _, diagram := range []*models.FlowDiagram{&child, parent} {
+		if err := s.validateDiagram(diagram); err != nil {
+			return nil, fmt.Errorf("%s: %w", diagram.ID, err)
+		}
+	}
+
+	createdChild, err := s.Create(&child)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create child diagram: %w", err)
+	}
+	updatedParent, err := s.Update(parent)
+	if err != nil {
+		// Roll back the child so a failed extraction leaves no orphan behind
+		_ = s.Delete(childID)
+		return nil, fmt.Errorf("failed to update parent diagram: %w", err)
+	}
+
+	return &ExtractResult{
+		Parent: *updatedParent,
+		Child:  *createdChild,
+	}, nil
+}
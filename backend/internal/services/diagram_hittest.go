@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// DefaultHitTolerance is how close, in diagram units, a point must be to an
+// edge's line to hit it
+const DefaultHitTolerance = 4.0
+
+// Element kinds in hit-test results
+const (
+	HitNode = "node"
+	HitEdge = "edge"
+)
+
+// Hit is an element under a point
+type Hit struct {
+	Kind     string  `json:"kind"` // node or edge
+	ID       string  `json:"id"`
+	Distance float64 `json:"distance"` // From an edge's line; 0 for nodes
+}
+
+// SpatialSelection lists the elements in a rectangle
+type SpatialSelection struct {
+	Nodes []string `json:"nodes"`
+	Edges []string `json:"edges"`
+}
+
+// HitTest returns the elements under a point, topmost first as renderers
+// draw them: nodes above edges, then by z-index or routing priority and
+// document order. Edges hit when the point is within tolerance of their
+// drawn line; 0 uses DefaultHitTolerance.
+func (s *DiagramService) HitTest(id string, point models.Position, tolerance float64) ([]Hit, error) {
+	if tolerance < 0 {
+		return nil, fmt.Errorf("%w: tolerance must not be negative", ErrInvalidOptions)
+	}
+	if tolerance == 0 {
+		tolerance = DefaultHitTolerance
+	}
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	index := spatialIndexOf(diagram)
+
+	var nodes []int
+	edges := make(map[int]float64)
+	probe := Rect{X: point.X - tolerance, Y: point.Y - tolerance, W: 2 * tolerance, H: 2 * tolerance}
+	index.tree.search(probe, func(entry rtreeEntry) {
+		if entry.node >= 0 {
+			box := index.nodes[entry.node]
+			if point.X >= box.X && point.X <= box.X+box.W && point.Y >= box.Y && point.Y <= box.Y+box.H {
+				nodes = append(nodes, entry.node)
+			}
+			return
+		}
+		points := index.edges[entry.edge]
+		d := segmentDistance(point, points[entry.segment], points[entry.segment+1])
+		if previous, ok := edges[entry.edge]; d <= tolerance && (!ok || d < previous) {
+			edges[entry.edge] = d
+		}
+	})
+
+	sort.Slice(nodes, func(a, b int) bool {
+		na, nb := &diagram.Nodes[nodes[a]], &diagram.Nodes[nodes[b]]
+		if na.ZIndex != nb.ZIndex {
+			return na.ZIndex > nb.ZIndex
+		}
+		return nodes[a] > nodes[b]
+	})
+	edgeOrder := make([]int, 0, len(edges))
+	for i := range edges {
+		edgeOrder = append(edgeOrder, i)
+	}
+	sort.Slice(edgeOrder, func(a, b int) bool {
+		ea, eb := &diagram.Edges[edgeOrder[a]], &diagram.Edges[edgeOrder[b]]
+		if ea.RoutingPriority != eb.RoutingPriority {
+			return ea.RoutingPriority > eb.RoutingPriority
+		}
+		return edgeOrder[a] > edgeOrder[b]
+	})
+
+	hits := make([]Hit, 0, len(nodes)+len(edgeOrder))
+	for _, i := range nodes {
+		hits = append(hits, Hit{Kind: HitNode, ID: diagram.Nodes[i].ID})
+	}
+	for _, i := range edgeOrder {
+		hits = append(hits, Hit{Kind: HitEdge, ID: diagram.Edges[i].ID, Distance: math.Round(edges[i]*100) / 100})
+	}
+	return hits, nil
+}
+
+// Within returns the elements lying entirely inside a rectangle, as a
+// marquee selection does, or with touching those merely intersecting it.
+// Elements are listed in document order.
+func (s *DiagramService) Within(id string, r Rect, touching bool) (*SpatialSelection, error) {
+	if r.W < 0 || r.H < 0 {
+		return nil, fmt.Errorf("%w: rectangle must not be inverted", ErrInvalidOptions)
+	}
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	index := spatialIndexOf(diagram)
+	nodes, edges := index.query(r)
+
+	selection := &SpatialSelection{Nodes: []string{}, Edges: []string{}}
+	for _, i := range nodes {
+		if touching || rectContains(r, index.nodes[i]) {
+			selection.Nodes = append(selection.Nodes, diagram.Nodes[i].ID)
+		}
+	}
+	for _, i := range edges {
+		if touching || polylineInside(index.edges[i], r) {
+			selection.Edges = append(selection.Edges, diagram.Edges[i].ID)
+		}
+	}
+	return selection, nil
+}
+
+// rectContains reports whether inner lies entirely inside outer
+func rectContains(outer, inner Rect) bool {
+	return inner.X >= outer.X && inner.Y >= outer.Y && inner.X+inner.W <= outer.X+outer.W && inner.Y+inner.H <= outer.Y+outer.H
+}
+
+// polylineInside reports whether every point of a polyline lies inside r
+func polylineInside(points []models.Position, r Rect) bool {
+	for _, p := range points {
+		if !rectContains(r, Rect{X: p.X, Y: p.Y}) {
+			return false
+		}
+	}
+	return true
+}
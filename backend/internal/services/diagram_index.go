@@ -0,0 +1,247 @@
+package services
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Diagram index states
+const (
+	IndexIdle    = "idle"    // Not warmed; files are parsed as they are read
+	IndexWarming = "warming" // Being filled in the background
+	IndexReady   = "ready"
+)
+
+// Parsed diagram files are indexed by path and reused until the file
+// changes, so that requests do not parse every diagram again. Callers get
+// copies, as they are free to modify what they load. The index is warmed
+// in the background on startup.
+var (
+	diagramIndexMu sync.Mutex
+	diagramIndex   = make(map[string]indexedDiagram)
+	indexStatus    = IndexStatus{State: IndexIdle}
+)
+
+type indexedDiagram struct {
+	modTime time.Time
+	size    int64
+	diagram *models.FlowDiagram
+	err     error
+	since   time.Time // When the file started failing to parse
+}
+
+// IndexStatus reports the progress of warming the diagram index
+type IndexStatus struct {
+	State     string     `json:"state"`
+	Total     int        `json:"total"`   // Diagram files found when warming started
+	Indexed   int        `json:"indexed"` // Files parsed so far, including failed ones
+	Failed    int        `json:"failed"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	ReadyAt   *time.Time `json:"readyAt,omitempty"`
+}
+
+// DiagramIndexStatus returns the progress of warming the diagram index
+func DiagramIndexStatus() IndexStatus {
+	diagramIndexMu.Lock()
+	defer diagramIndexMu.Unlock()
+	return indexStatus
+}
+
+// StartDiagramIndexWarming parses every diagram file in the background, so
+// the first requests after a cold start do not each parse them all
+func StartDiagramIndexWarming() {
+	s := NewDiagramService()
+	var paths []string
+	s.walkDiagramPaths(func(path string) error {
+		paths = append(paths, path)
+		return nil
+	})
+	started := time.Now()
+	diagramIndexMu.Lock()
+	indexStatus = IndexStatus{State: IndexWarming, Total: len(paths), StartedAt: &started}
+	diagramIndexMu.Unlock()
+
+	go func() {
+		for _, path := range paths {
+			_, err := s.loadDiagramFromFile(path)
+			diagramIndexMu.Lock()
+			indexStatus.Indexed++
+			if err != nil {
+				indexStatus.Failed++
+			}
+			diagramIndexMu.Unlock()
+		}
+		ready := time.Now()
+		diagramIndexMu.Lock()
+		indexStatus.State, indexStatus.ReadyAt = IndexReady, &ready
+		diagramIndexMu.Unlock()
+		log.Printf("Indexed %d diagrams in %s", len(paths), ready.Sub(started).Round(time.Millisecond))
+	}()
+}
+
+// isDiagramFileName reports whether a file name is that of a diagram file
+func isDiagramFileName(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
+
+// loadDiagramFromFile returns the diagram in a file, from the index while
+// the file is unchanged
+func (s *DiagramService) loadDiagramFromFile(filePath string) (*models.FlowDiagram, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return s.parseDiagramFile(filePath)
+	}
+	diagramIndexMu.Lock()
+	entry, ok := diagramIndex[filePath]
+	diagramIndexMu.Unlock()
+	if !ok || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		previous := entry
+		entry = indexedDiagram{modTime: info.ModTime(), size: info.Size()}
+		entry.diagram, entry.err = s.parseDiagramFile(filePath)
+		if entry.err != nil && ok && previous.err != nil {
+			entry.since = previous.since
+		} else if entry.err != nil {
+			entry.since = time.Now().UTC()
+		}
+		diagramIndexMu.Lock()
+		diagramIndex[filePath] = entry
+		diagramIndexMu.Unlock()
+		if entry.err != nil && entry.since != previous.since {
+			s.quarantine(filePath, entry, previous.diagram)
+		}
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return cloneDiagram(entry.diagram), nil
+}
+
+// forgetIndexedDiagram drops a file the API is about to change from the
+// index; its modification time alone may not tell the change apart
+func forgetIndexedDiagram(path string) {
+	diagramIndexMu.Lock()
+	delete(diagramIndex, path)
+	diagramIndexMu.Unlock()
+}
+
+// indexedPath returns the file the index last found a diagram in, or ""
+func (s *DiagramService) indexedPath(id string) string {
+	diagramIndexMu.Lock()
+	defer diagramIndexMu.Unlock()
+	for path, entry := range diagramIndex {
+		if entry.diagram != nil && entry.diagram.ID == id && s.inDiagramRoots(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// indexedDiagrams returns the diagrams indexed so far below the diagram
+// roots, in path order, without reading any file
+func (s *DiagramService) indexedDiagrams() []models.FlowDiagram {
+	diagramIndexMu.Lock()
+	paths := make([]string, 0, len(diagramIndex))
+	entries := make(map[string]*models.FlowDiagram, len(diagramIndex))
+	for path, entry := range diagramIndex {
+		if entry.err == nil && s.inDiagramRoots(path) {
+			paths = append(paths, path)
+			entries[path] = entry.diagram
+		}
+	}
+	diagramIndexMu.Unlock()
+
+	sort.Strings(paths)
+	diagrams := make([]models.FlowDiagram, 0, len(paths))
+	for _, path := range paths {
+		diagram := cloneDiagram(entries[path])
+		s.annotateDiagram(diagram, path)
+		diagrams = append(diagrams, *diagram)
+	}
+	return diagrams
+}
+
+// ListAvailable lists the diagrams like List, but while the index is still
+// warming returns only those indexed so far instead of parsing the rest.
+// partial reports whether the list may be incomplete.
+func (s *DiagramService) ListAvailable(includeArchived bool) (diagrams []models.FlowDiagram, partial bool, err error) {
+	if DiagramIndexStatus().State != IndexWarming {
+		diagrams, err = s.List(includeArchived)
+		return diagrams, false, err
+	}
+	diagrams = []models.FlowDiagram{}
+	for _, diagram := range s.indexedDiagrams() {
+		if includeArchived || !diagram.Archived {
+			diagrams = append(diagrams, diagram)
+		}
+	}
+	return diagrams, true, nil
+}
+
+// isBelow reports whether path lies inside dir
+func isBelow(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// cloneDiagram returns a deep copy of a diagram
+func cloneDiagram(diagram *models.FlowDiagram) *models.FlowDiagram {
+	clone := cloneValue(reflect.ValueOf(diagram).Elem()).Interface().(models.FlowDiagram)
+	return &clone
+}
+
+// cloneValue deep-copies the exported parts of a value; unexported fields,
+// such as those of time.Time, are copied as they are
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.New(v.Type().Elem())
+		clone.Elem().Set(cloneValue(v.Elem()))
+		return clone
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(cloneValue(v.Elem()))
+		return clone
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			clone.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return clone
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			clone.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if clone.Field(i).CanSet() {
+				clone.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+		return clone
+	}
+	return v
+}
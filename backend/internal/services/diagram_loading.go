@@ -0,0 +1,94 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Diagram loading modes
+const (
+	LoadingLenient = "lenient" // Files that fail to load are skipped
+	LoadingStrict  = "strict"  // Any file that fails to parse or validate fails startup and readiness
+)
+
+// InvalidDiagram is a diagram that parses but has validation errors
+type InvalidDiagram struct {
+	ID     string                   `json:"id"`
+	Path   string                   `json:"path"` // Relative to the diagrams path
+	Errors []models.ValidationError `json:"errors"`
+}
+
+// LoadingCheck is the outcome of checking that every diagram file loads
+type LoadingCheck struct {
+	Mode       string             `json:"mode"`
+	Clean      bool               `json:"clean"`
+	LoadErrors []DiagramLoadError `json:"loadErrors"`
+	Invalid    []InvalidDiagram   `json:"invalid"`
+}
+
+// LoadingMode returns the configured diagram loading mode
+func (s *DiagramService) LoadingMode() string {
+	return s.cfg.DiagramLoading
+}
+
+// CheckLoading parses and validates every diagram file, archived ones
+// included, and reports those that fail
+func (s *DiagramService) CheckLoading() (*LoadingCheck, error) {
+	loadErrors, err := s.LoadErrors()
+	if err != nil {
+		return nil, err
+	}
+	diagrams, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	check := &LoadingCheck{Mode: s.cfg.DiagramLoading, LoadErrors: loadErrors, Invalid: []InvalidDiagram{}}
+	for i := range diagrams {
+		diagram := &diagrams[i]
+		stats, err := s.Stats(diagram)
+		if err != nil {
+			return nil, err
+		}
+		if stats.Validation != models.ValidationErrors {
+			continue
+		}
+		result, err := s.Validate(diagram)
+		if err != nil {
+			return nil, err
+		}
+		check.Invalid = append(check.Invalid, InvalidDiagram{
+			ID:     diagram.ID,
+			Path:   s.relativeDiagramPath(diagram.FilePath),
+			Errors: result.Errors,
+		})
+	}
+	check.Clean = len(check.LoadErrors) == 0 && len(check.Invalid) == 0
+	return check, nil
+}
+
+// RequireCleanDiagrams fails in strict loading mode when a diagram file
+// does not parse or validate, logging each one; it does nothing in lenient
+// mode
+func RequireCleanDiagrams() error {
+	s := NewDiagramService()
+	if s.cfg.DiagramLoading != LoadingStrict {
+		return nil
+	}
+	check, err := s.CheckLoading()
+	if err != nil {
+		return err
+	}
+	if check.Clean {
+		return nil
+	}
+	for _, loadError := range check.LoadErrors {
+		log.Printf("Strict loading: %s does not parse: %s", loadError.Path, loadError.Error)
+	}
+	for _, invalid := range check.Invalid {
+		log.Printf("Strict loading: %s (%s) has %d validation errors, first: %s", invalid.Path, invalid.ID, len(invalid.Errors), invalid.Errors[0].Message)
+	}
+	return fmt.Errorf("%d diagram files do not parse and %d diagrams are invalid", len(check.LoadErrors), len(check.Invalid))
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Stitch modes for merging diagrams
+const (
+	// StitchNone keeps both diagrams side by side without connecting them
+	StitchNone = "none"
+	// StitchMatching joins end nodes of the first diagram to start nodes of
+	// the second when their names match (case-insensitive)
+	StitchMatching = "matching"
+	// StitchAll joins every end node of the first diagram to every start
+	// node of the second
+	StitchAll = "all"
+)
+
+// namespaceSeparator joins the source diagram ID and element ID when merging
+const namespaceSeparator = "__"
+
+// MergeOptions controls how two diagrams are combined
+type MergeOptions struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Namespace bool   `json:"namespace"`
+	Stitch    string `json:"stitch"`
+}
+
+// MergeConflict describes a problem encountered while merging
+type MergeConflict struct {
+	Code    string `json:"code"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// MergeResult is the outcome of merging two diagrams
+type MergeResult struct {
+	Diagram   models.FlowDiagram `json:"diagram"`
+	Stitched  []string           `json:"stitched"`
+	Conflicts []MergeConflict    `json:"conflicts"`
+}
+
+// Merge combines two diagrams into a new one. Element IDs are prefixed with
+// their source diagram ID when namespacing is enabled; otherwise colliding IDs
+// from the second diagram are renamed and reported as conflicts. The merged
+// diagram is returned but not saved.
+func (s *DiagramService) Merge(firstID, secondID string, opts MergeOptions) (*MergeResult, error) {
+	if firstID == secondID {
+		return nil, fmt.Errorf("cannot merge diagram %s with itself", firstID)
+	}
+	switch opts.Stitch {
+	case "":
+		opts.Stitch = StitchNone
+	case StitchNone, StitchMatching, StitchAll:
+	default:
+		return nil, fmt.Errorf("unknown stitch mode: %s", opts.Stitch)
+	}
+
+	first, err := s.GetByID(firstID)
+	if err != nil {
+		return nil, err
+	}
+	second, err := s.GetByID(secondID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ID == "" {
+		opts.ID = first.ID + "-" + second.ID
+	}
+	if opts.Name == "" {
+		opts.Name = first.Name + " + " + second.Name
+	}
+
+	result := &MergeResult{
+		Diagram: models.FlowDiagram{
+			FlowEntity: models.FlowEntity{
+				ID:   opts.ID,
+				Name: opts.Name,
+				Tags: mergeTags(first.Tags, second.Tags),
+				Metadata: map[string]interface{}{
+					"mergedFrom": []string{first.ID, second.ID},
+				},
+			},
+			Version: first.Version,
+			Layout:  first.Layout,
+			Nodes:   []models.FlowNode{},
+			Edges:   []models.FlowEdge{},
+		},
+		Stitched:  []string{},
+		Conflicts: []MergeConflict{},
+	}
+	merged := &result.Diagram
+
+	nodeIDs := make(map[string]bool)
+	edgeIDs := make(map[string]bool)
+
+	// Place the second diagram to the right of the first to avoid overlap
+	offsetX := 0.0
+	for _, node := range first.Nodes {
+		right := node.Position.X
+		if node.Dimensions != nil {
+			right += node.Dimensions.Width
+		}
+		if right > offsetX {
+			offsetX = right
+		}
+	}
+	offsetX += 100
+
+	firstNodes := s.appendMergedElements(result, first, 0, opts.Namespace, nodeIDs, edgeIDs)
+	secondNodes := s.appendMergedElements(result, second, offsetX, opts.Namespace, nodeIDs, edgeIDs)
+
+	// Report nodes with the same display name in both diagrams; they are
+	// likely duplicates of the same step
+	names := make(map[string]string)
+	for _, node := range first.Nodes {
+		names[strings.ToLower(node.Name)] = node.ID
+	}
+	for _, node := range second.Nodes {
+		if node.Type == models.NodeTypeStart || node.Type == models.NodeTypeEnd {
+			continue
+		}
+		if otherID, ok := names[strings.ToLower(node.Name)]; ok {
+			result.Conflicts = append(result.Conflicts, MergeConflict{
+				Code:    "DUPLICATE_NODE_NAME",
+				Path:    "nodes." + secondNodes[node.ID],
+				Message: fmt.Sprintf("Node %q also exists in %s as %s", node.Name, first.ID, firstNodes[otherID]),
+			})
+		}
+	}
+
+	if opts.Stitch != StitchNone {
+		s.stitchMerged(result, first, second, firstNodes, secondNodes, opts.Stitch, edgeIDs)
+	}
+
+	validation, err := s.Validate(merged)
+	if err != nil {
+		return nil, err
+	}
+	for _, validationErr := range validation.Errors {
+		result.Conflicts = append(result.Conflicts, MergeConflict{
+			Code:    validationErr.Code,
+			Path:    validationErr.Path,
+			Message: validationErr.Message,
+		})
+	}
+
+	return result, nil
+}
+
+// appendMergedElements copies the nodes and edges of a source diagram into the
+// merge result and returns the mapping from original to merged node IDs
+func (s *DiagramService) appendMergedElements(result *MergeResult, source *models.FlowDiagram, offsetX float64, namespace bool, nodeIDs, edgeIDs map[string]bool) map[string]string {
+	merged := &result.Diagram
+	mapping := make(map[string]string)
+
+	uniqueID := func(id string, taken map[string]bool, kind string) string {
+		if namespace {
+			id = source.ID + namespaceSeparator + id
+		}
+		if !taken[id] {
+			taken[id] = true
+			return id
+		}
+		renamed := id
+		for i := 2; taken[renamed]; i++ {
+			renamed = fmt.Sprintf("%s_%d", id, i)
+		}
+		taken[renamed] = true
+		result.Conflicts = append(result.Conflicts, MergeConflict{
+			Code:    "DUPLICATE_" + strings.ToUpper(kind) + "_ID",
+			Path:    kind + "s." + renamed,
+			Message: fmt.Sprintf("ID %s from %s already exists; renamed to %s", id, source.ID, renamed),
+		})
+		return renamed
+	}
+
+	for _, node := range source.Nodes {
+		newID := uniqueID(node.ID, nodeIDs, "node")
+		mapping[node.ID] = newID
+		node.ID = newID
+		node.Position.X += offsetX
+		merged.Nodes = append(merged.Nodes, node)
+	}
+
+	for _, edge := range source.Edges {
+		edge.ID = uniqueID(edge.ID, edgeIDs, "edge")
+		if id, ok := mapping[edge.From]; ok {
+			edge.From = id
+		}
+		if id, ok := mapping[edge.To]; ok {
+			edge.To = id
+		}
+		merged.Edges = append(merged.Edges, edge)
+	}
+
+	return mapping
+}
+
+// stitchMerged joins end nodes of the first diagram to start nodes of the
+// second. Each joined pair is removed and the incoming edges of the end node
+// are reconnected to the successors of the start node.
+func (s *DiagramService) stitchMerged(result *MergeResult, first, second *models.FlowDiagram, firstNodes, secondNodes map[string]string, mode string, edgeIDs map[string]bool) {
+	merged := &result.Diagram
+
+	var ends, starts []models.FlowNode
+	for _, node := range first.Nodes {
+		if node.Type == models.NodeTypeEnd {
+			ends = append(ends, node)
+		}
+	}
+	for _, node := range second.Nodes {
+		if node.Type == models.NodeTypeStart {
+			starts = append(starts, node)
+		}
+	}
+
+	removed := make(map[string]bool)
+	type bridge struct{ end, start string }
+	var bridges []bridge
+
+	for _, end := range ends {
+		matched := false
+		for _, start := range starts {
+			if mode == StitchMatching && !strings.EqualFold(strings.TrimSpace(end.Name), strings.TrimSpace(start.Name)) {
+				continue
+			}
+			bridges = append(bridges, bridge{end: firstNodes[end.ID], start: secondNodes[start.ID]})
+			removed[firstNodes[end.ID]] = true
+			removed[secondNodes[start.ID]] = true
+			matched = true
+		}
+		if !matched {
+			result.Conflicts = append(result.Conflicts, MergeConflict{
+				Code:    "UNSTITCHED_END_NODE",
+				Path:    "nodes." + firstNodes[end.ID],
+				Message: fmt.Sprintf("End node %q has no matching start node in %s", end.Name, second.ID),
+			})
+		}
+	}
+
+	if len(bridges) == 0 {
+		return
+	}
+
+	var newEdges []models.FlowEdge
+	for _, b := range bridges {
+		for _, in := range merged.Edges {
+			if in.To != b.end {
+				continue
+			}
+			for _, out := range merged.Edges {
+				if out.From != b.start {
+					continue
+				}
+				edge := in
+				edge.ID = in.ID + "_" + out.ID
+				for i := 2; edgeIDs[edge.ID]; i++ {
+					edge.ID = fmt.Sprintf("%s_%s_%d", in.ID, out.ID, i)
+				}
+				edgeIDs[edge.ID] = true
+				edge.To = out.To
+				newEdges = append(newEdges, edge)
+			}
+		}
+		result.Stitched = append(result.Stitched, b.end+" -> "+b.start)
+	}
+
+	nodes := merged.Nodes[:0]
+	for _, node := range merged.Nodes {
+		if !removed[node.ID] {
+			nodes = append(nodes, node)
+		}
+	}
+	merged.Nodes = nodes
+
+	edges := []models.FlowEdge{}
+	for _, edge := range merged.Edges {
+		if !removed[edge.From] && !removed[edge.To] {
+			edges = append(edges, edge)
+		}
+	}
+	merged.Edges = append(edges, newEdges...)
+}
+
+func mergeTags(a, b []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range append(append([]string{}, a...), b...) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
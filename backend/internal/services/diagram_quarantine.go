@@ -0,0 +1,100 @@
+package services
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Logging of diagram files that fail to parse
+const (
+	LoadErrorLogNew    = "new"    // Once, when a file starts failing
+	LoadErrorLogAlways = "always" // Also whenever a listing skips the file
+	LoadErrorLogOff    = "off"
+)
+
+// DiagramLoadError is a quarantined diagram file: one that could not be
+// parsed and is left out of lists until it changes
+type DiagramLoadError struct {
+	Path    string    `json:"path"` // Relative to the diagrams path
+	Error   string    `json:"error"`
+	ModTime time.Time `json:"modTime"`
+	Since   time.Time `json:"since"` // When the file started failing
+}
+
+// LoadErrors checks every diagram file and returns those that could not be
+// parsed, in path order
+func (s *DiagramService) LoadErrors() ([]DiagramLoadError, error) {
+	if err := s.walkDiagramFiles(func(string, *models.FlowDiagram, error) {}); err != nil {
+		return nil, err
+	}
+	return s.quarantined(), nil
+}
+
+// quarantined returns the indexed diagram files below the diagram roots
+// that failed to parse and still exist, without parsing any file
+func (s *DiagramService) quarantined() []DiagramLoadError {
+	diagramIndexMu.Lock()
+	paths := make(map[string]indexedDiagram)
+	for path, entry := range diagramIndex {
+		if entry.err != nil && s.inDiagramRoots(path) {
+			paths[path] = entry
+		}
+	}
+	diagramIndexMu.Unlock()
+
+	loadErrors := []DiagramLoadError{}
+	for path, entry := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		loadErrors = append(loadErrors, DiagramLoadError{
+			Path:    s.relativeDiagramPath(path),
+			Error:   entry.err.Error(),
+			ModTime: entry.modTime.UTC(),
+			Since:   entry.since,
+		})
+	}
+	sort.Slice(loadErrors, func(i, j int) bool { return loadErrors[i].Path < loadErrors[j].Path })
+	return loadErrors
+}
+
+// quarantine reports a diagram file that started failing to parse: it is
+// logged, sent to event stream clients and, when the file held a diagram
+// before, mailed to that diagram's owners and subscribers
+func (s *DiagramService) quarantine(path string, entry indexedDiagram, previous *models.FlowDiagram) {
+	if !s.inDiagramRoots(path) {
+		return
+	}
+	rel := s.relativeDiagramPath(path)
+	if s.cfg.DiagramLoadErrorLog != LoadErrorLogOff {
+		log.Printf("Diagram file %s cannot be read and is skipped until it changes: %v", rel, entry.err)
+	}
+	event := Event{
+		Type:  EventDiagramUnreadable,
+		Path:  rel,
+		Error: entry.err.Error(),
+		Time:  entry.since,
+	}
+	if previous != nil {
+		event.DiagramID = previous.ID
+		notifyDiagramUnreadable(s.cfg, previous, entry.err)
+	}
+	publishEvent(event)
+}
+
+// relativeDiagramPath returns a path relative to the diagrams path, or as
+// it is when it is in another diagram root
+func (s *DiagramService) relativeDiagramPath(path string) string {
+	if !isBelow(s.cfg.DiagramsPath, path) {
+		return path
+	}
+	if rel, err := filepath.Rel(s.cfg.DiagramsPath, path); err == nil {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// RestyleResult reports which elements a restyle touched
+type RestyleResult struct {
+	Diagram models.FlowDiagram `json:"diagram"`
+	NodeIDs []string           `json:"nodeIds"`
+	EdgeIDs []string           `json:"edgeIds"`
+}
+
+// Restyle applies a style patch to every element matched by the selector and
+// saves the diagram. Only the fields set in the patch are changed.
+func (s *DiagramService) Restyle(id string, selector ElementSelector, patch models.Style) (*RestyleResult, error) {
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RestyleResult{
+		NodeIDs: []string{},
+		EdgeIDs: []string{},
+	}
+
+	for i := range diagram.Nodes {
+		node := &diagram.Nodes[i]
+		if selector.MatchesNode(node) {
+			node.Style = applyStylePatch(node.Style, patch)
+			result.NodeIDs = append(result.NodeIDs, node.ID)
+		}
+	}
+	for i := range diagram.Edges {
+		edge := &diagram.Edges[i]
+		if selector.MatchesEdge(edge) {
+			edge.Style = applyStylePatch(edge.Style, patch)
+			result.EdgeIDs = append(result.EdgeIDs, edge.ID)
+		}
+	}
+
+	if len(result.NodeIDs) == 0 && len(result.EdgeIDs) == 0 {
+		result.Diagram = *diagram
+		return result, nil
+	}
+
+	updated, err := s.Update(diagram)
+	if err != nil {
+		return nil, err
+	}
+	result.Diagram = *updated
+
+	return result, nil
+}
+
+// applyStylePatch returns style with every non-nil field of patch applied
+func applyStylePatch(style *models.Style, patch models.Style) *models.Style {
+	merged := models.Style{}
+	if style != nil {
+		merged = *style
+	}
+	if patch.Fill != nil {
+		merged.Fill = patch.Fill
+	}
+	if patch.Stroke != nil {
+		merged.Stroke = patch.Stroke
+	}
+	if patch.StrokeWidth != nil {
+		merged.StrokeWidth = patch.StrokeWidth
+	}
+	if patch.StrokeDasharray != nil {
+		merged.StrokeDasharray = patch.StrokeDasharray
+	}
+	if patch.Opacity != nil {
+		merged.Opacity = patch.Opacity
+	}
+	if patch.FontSize != nil {
+		merged.FontSize = patch.FontSize
+	}
+	if patch.FontFamily != nil {
+		merged.FontFamily = patch.FontFamily
+	}
+	if patch.FontWeight != nil {
+		merged.FontWeight = patch.FontWeight
+	}
+	if patch.TextColor != nil {
+		merged.TextColor = patch.TextColor
+	}
+	return &merged
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"bytes"
 	"io/ioutil"
+	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,13 +19,24 @@ import (
 )
 
 var (
-	ErrDiagramNotFound = errors.New("diagram not found")
-	ErrInvalidDiagram  = errors.New("invalid diagram")
+	ErrDiagramNotFound   = errors.New("diagram not found")
+	ErrInvalidDiagram    = errors.New("invalid diagram")
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+	ErrInvalidOptions    = errors.New("invalid options")
+	ErrNotConfigured     = errors.New("integration not configured")
+	ErrSyncConflict      = errors.New("merge conflict")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrDiagramExists     = errors.New("diagram already exists")
+	ErrReadOnly          = errors.New("diagram is read-only")
 )
 
 // DiagramService handles diagram operations
 type DiagramService struct {
-	cfg *config.Config
+	cfg     *config.Config
+	actor   string      // Directory ID recorded in the changelog of saved diagrams
+	summary string      // Change summary of saves and deletes, unless the diagram brings its own
+	header  http.Header // Headers of the request acted for, resolving its workspace
+	dryRun  *dryRunRecorder
 }
 
 // NewDiagramService creates a new diagram service
@@ -32,37 +46,63 @@ func NewDiagramService() *DiagramService {
 	}
 }
 
-// ListAll returns all diagrams
+// ListAll returns all diagrams, archived ones included
 func (s *DiagramService) ListAll() ([]models.FlowDiagram, error) {
 	diagrams := []models.FlowDiagram{}
 
-	err := filepath.Walk(s.cfg.DiagramsPath, func(path string, info os.FileInfo, err error) error {
+	err := s.walkDiagramFiles(func(path string, diagram *models.FlowDiagram, err error) {
 		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
-			diagram, err := s.loadDiagramFromFile(path)
-			if err != nil {
-				// Log error but continue with other files
-				fmt.Printf("Error loading diagram from %s: %v\n", path, err)
-				return nil
+			// The file is quarantined; continue with the others
+			if s.cfg.DiagramLoadErrorLog == LoadErrorLogAlways {
+				log.Printf("Skipping diagram file %s: %v", path, err)
 			}
-			diagrams = append(diagrams, *diagram)
+			return
 		}
+		diagrams = append(diagrams, *diagram)
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	return diagrams, nil
+}
+
+// walkDiagramFiles calls fn with each diagram file in the diagrams path and
+// the diagram loaded from it, or the error loading it
+func (s *DiagramService) walkDiagramFiles(fn func(path string, diagram *models.FlowDiagram, err error)) error {
+	err := s.walkDiagramPaths(func(path string) error {
+		diagram, err := s.loadDiagramFromFile(path)
+		if err == nil {
+			s.annotateDiagram(diagram, path)
+		}
+		fn(path, diagram, err)
 		return nil
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan diagrams directory: %w", err)
+		return fmt.Errorf("failed to scan diagrams directory: %w", err)
 	}
-
-	return diagrams, nil
+	return nil
 }
 
 // GetByID returns a diagram by ID
 func (s *DiagramService) GetByID(id string) (*models.FlowDiagram, error) {
+	// A diagram is usually stored in a file named after it, or in a file
+	// the index knows, which spares loading all the others
+	paths := []string{
+		filepath.Join(s.cfg.DiagramsPath, filepath.Base(id)+".yaml"),
+		filepath.Join(s.cfg.DiagramsPath, filepath.Base(id)+".yml"),
+	}
+	if path := s.indexedPath(id); path != "" {
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if diagram, err := s.loadDiagramFromFile(path); err == nil && diagram.ID == id {
+			s.annotateDiagram(diagram, path)
+			return diagram, nil
+		}
+	}
+
 	diagrams, err := s.ListAll()
 	if err != nil {
 		return nil, err
@@ -79,56 +119,124 @@ func (s *DiagramService) GetByID(id string) (*models.FlowDiagram, error) {
 
 // Create creates a new diagram
 func (s *DiagramService) Create(diagram *models.FlowDiagram) (*models.FlowDiagram, error) {
-	// Set timestamps
+	return s.save(diagram, SaveEventCreate, "")
+}
+
+// Update updates an existing diagram
+func (s *DiagramService) Update(diagram *models.FlowDiagram) (*models.FlowDiagram, error) {
+	return s.save(diagram, SaveEventUpdate, "")
+}
+
+// save stores a new or changed diagram. Every save goes through it,
+// whether the diagram came as JSON or as YAML, so that all keep the
+// creation time, bump the update time and run the same checks and hooks.
+// On update, the diagram's content hash is the version the client read.
+// message describes the change in the Git history; empty uses a generic
+// create or update message.
+func (s *DiagramService) save(diagram *models.FlowDiagram, event, message string) (*models.FlowDiagram, error) {
 	now := time.Now()
-	diagram.Created = now
+	base := diagram.ContentHash
+	summary := diagram.ChangeSummary
+	if summary == "" {
+		summary = s.summary
+	}
+	diagram.ChangeSummary = ""
+	var existing *models.FlowDiagram
+	if event == SaveEventCreate {
+		if err := s.checkFileNaming(diagram); err != nil {
+			return nil, err
+		}
+		// Creates of one ID would all find it free before any of them
+		// wrote, so the check and the write are done under the ID's lock
+		unlock := lockDiagram(diagram.ID)
+		defer unlock()
+		path, err := s.newDiagramPath(diagram)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkWritable(path); err != nil {
+			return nil, err
+		}
+		diagram.Created = now
+		diagram.FilePath = path
+	} else {
+		var err error
+		if existing, err = s.GetByID(diagram.ID); err != nil {
+			return nil, err
+		}
+		if err := s.checkWritable(existing.FilePath); err != nil {
+			return nil, err
+		}
+		// Preserve creation time and file path
+		diagram.Created = existing.Created
+		diagram.FilePath = existing.FilePath
+		// Concurrent saves over the same version would all pass the check
+		// before any of them wrote, so the check and the write are done
+		// under the diagram's lock
+		unlock := lockDiagram(diagram.ID)
+		defer unlock()
+		if err := s.checkEditBase(diagram, base); err != nil {
+			return nil, err
+		}
+	}
 	diagram.Updated = now
-
-	// Validate diagram
-	if err := s.validateDiagram(diagram); err != nil {
+	if err := s.checkAIGeneration(diagram, existing); err != nil {
 		return nil, err
 	}
+	// Deprecated fields are written in their current form, so the recorded
+	// validation does not warn about them
+	diagram.Legacy = nil
 
-	// Generate file path
-	filename := fmt.Sprintf("%s.yaml", diagram.ID)
-	filePath := filepath.Join(s.cfg.DiagramsPath, filename)
-	diagram.FilePath = filePath
-
-	// Ensure directory exists
-	if err := os.MkdirAll(s.cfg.DiagramsPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create diagrams directory: %w", err)
+	if err := applyTransformPlugins(s.cfg, diagram); err != nil {
+		return nil, err
 	}
-
-	// Save to file
-	if err := s.saveDiagramToFile(diagram, filePath); err != nil {
+	if err := s.applyCatalogDefaults(diagram); err != nil {
 		return nil, err
 	}
-
-	return diagram, nil
-}
-
-// Update updates an existing diagram
-func (s *DiagramService) Update(diagram *models.FlowDiagram) (*models.FlowDiagram, error) {
-	// Check if diagram exists
-	existing, err := s.GetByID(diagram.ID)
-	if err != nil {
+	s.simplifyCrowdedEdges(diagram)
+	if err := s.checkQuotas(diagram, event == SaveEventCreate); err != nil {
 		return nil, err
 	}
 
-	// Preserve creation time and file path
-	diagram.Created = existing.Created
-	diagram.Updated = time.Now()
-	diagram.FilePath = existing.FilePath
-
 	// Validate diagram
 	if err := s.validateDiagram(diagram); err != nil {
 		return nil, err
 	}
+	if err := runValidationWebhook(s.cfg, diagram, event); err != nil {
+		return nil, err
+	}
+	if err := runPreSaveHooks(s.cfg, diagram, event); err != nil {
+		return nil, err
+	}
+	if err := stampProvenance(diagram, event == SaveEventCreate); err != nil {
+		return nil, err
+	}
+	s.recordChangelog(diagram, existing, now, summary)
+	if s.dryRun != nil {
+		s.recordDryRun(existing, diagram)
+		return diagram, nil
+	}
+
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(diagram.FilePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create diagrams directory: %w", err)
+	}
 
 	// Save to file
 	if err := s.saveDiagramToFile(diagram, diagram.FilePath); err != nil {
 		return nil, err
 	}
+	if message == "" {
+		message = "Update diagram " + diagram.ID
+		if event == SaveEventCreate {
+			message = "Create diagram " + diagram.ID
+		}
+	}
+	gitSyncAfterSave(s.cfg, commitMessage(summary, message))
+	if event == SaveEventUpdate {
+		notifyDiagramChange(s.cfg, diagram, models.EventDiagramChanged, summary)
+	}
+	runPostSaveHooks(s.cfg, diagram, event, summary)
 
 	return diagram, nil
 }
@@ -139,11 +247,21 @@ func (s *DiagramService) Delete(id string) error {
 	if err != nil {
 		return err
 	}
+	if err := s.checkWritable(diagram.FilePath); err != nil {
+		return err
+	}
+	if s.dryRun != nil {
+		s.recordDryRun(diagram, nil)
+		return nil
+	}
 
 	// Remove file
-	if err := os.Remove(diagram.FilePath); err != nil {
+	if err := removeDiagramFile(diagram.FilePath); err != nil {
 		return fmt.Errorf("failed to delete diagram file: %w", err)
 	}
+	gitSyncAfterSave(s.cfg, commitMessage(s.summary, "Delete diagram "+id))
+	notifyDiagramChange(s.cfg, diagram, models.EventDiagramDeleted, s.summary)
+	runPostSaveHooks(s.cfg, diagram, SaveEventDelete, s.summary)
 
 	return nil
 }
@@ -156,6 +274,27 @@ func (s *DiagramService) Validate(diagram *models.FlowDiagram) (*models.Validati
 		Warnings: []models.ValidationError{},
 	}
 
+	directory, err := s.Directory()
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := s.ControlCatalog()
+	if err != nil {
+		return nil, err
+	}
+	nodeCatalog, err := s.NodeCatalog()
+	if err != nil {
+		return nil, err
+	}
+	metaSchema, err := s.MetaSchema()
+	if err != nil {
+		return nil, err
+	}
+	theme, err := s.StyleTheme()
+	if err != nil {
+		return nil, err
+	}
+
 	// Basic validation
 	if diagram.ID == "" {
 		result.Errors = append(result.Errors, models.ValidationError{
@@ -181,6 +320,38 @@ func (s *DiagramService) Validate(diagram *models.FlowDiagram) (*models.Validati
 		})
 	}
 
+	validateOwnership(result, "", diagram.Ownership, directory)
+	validateMeta(result, diagram.Meta, metaSchema)
+	validateRelations(result, diagram)
+	validateParents(result, diagram)
+	validateDrillDownFocus(result, diagram)
+	validateDeprecation(result, diagram)
+	validateProvenance(result, diagram)
+	validateZOrder(result, diagram)
+	validateStyles(result, diagram, theme)
+	validateContrast(result, diagram, theme)
+
+	// Validate layers
+	layerIDs := make(map[string]bool)
+	for i, layer := range diagram.Layers {
+		if layer.ID == "" {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    fmt.Sprintf("layers[%d].id", i),
+				Message: "Layer ID is required",
+				Code:    "MISSING_LAYER_ID",
+			})
+		} else if layerIDs[layer.ID] {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    fmt.Sprintf("layers[%d].id", i),
+				Message: fmt.Sprintf("Duplicate layer ID: %s", layer.ID),
+				Code:    "DUPLICATE_LAYER_ID",
+				Value:   layer.ID,
+			})
+		} else {
+			layerIDs[layer.ID] = true
+		}
+	}
+
 	// Validate nodes
 	nodeIDs := make(map[string]bool)
 	for i, node := range diagram.Nodes {
@@ -195,6 +366,7 @@ func (s *DiagramService) Validate(diagram *models.FlowDiagram) (*models.Validati
 				Path:    fmt.Sprintf("nodes[%d].id", i),
 				Message: fmt.Sprintf("Duplicate node ID: %s", node.ID),
 				Code:    "DUPLICATE_NODE_ID",
+				Value:   node.ID,
 			})
 		} else {
 			nodeIDs[node.ID] = true
@@ -207,6 +379,24 @@ func (s *DiagramService) Validate(diagram *models.FlowDiagram) (*models.Validati
 				Code:    "MISSING_NODE_NAME",
 			})
 		}
+
+		validateOwnership(result, fmt.Sprintf("nodes[%d]", i), node.Ownership, directory)
+		validateControls(result, fmt.Sprintf("nodes[%d]", i), node.Controls, catalog)
+		validateCatalogRef(result, fmt.Sprintf("nodes[%d]", i), &node, nodeCatalog)
+		validateTiming(result, fmt.Sprintf("nodes[%d]", i), node.Duration, node.SLA)
+		validateCost(result, fmt.Sprintf("nodes[%d]", i), node.Cost)
+		validateHealth(result, fmt.Sprintf("nodes[%d]", i), node.Integrations)
+
+		for j, layerID := range node.Layers {
+			if !layerIDs[layerID] {
+				result.Errors = append(result.Errors, models.ValidationError{
+					Path:    fmt.Sprintf("nodes[%d].layers[%d]", i, j),
+					Message: fmt.Sprintf("Node references undefined layer: %s", layerID),
+					Code:    "INVALID_LAYER",
+					Value:   layerID,
+				})
+			}
+		}
 	}
 
 	// Validate edges
@@ -223,6 +413,7 @@ func (s *DiagramService) Validate(diagram *models.FlowDiagram) (*models.Validati
 				Path:    fmt.Sprintf("edges[%d].id", i),
 				Message: fmt.Sprintf("Duplicate edge ID: %s", edge.ID),
 				Code:    "DUPLICATE_EDGE_ID",
+				Value:   edge.ID,
 			})
 		} else {
 			edgeIDs[edge.ID] = true
@@ -233,6 +424,7 @@ func (s *DiagramService) Validate(diagram *models.FlowDiagram) (*models.Validati
 				Path:    fmt.Sprintf("edges[%d].from", i),
 				Message: fmt.Sprintf("Edge references non-existent from node: %s", edge.From),
 				Code:    "INVALID_FROM_NODE",
+				Value:   edge.From,
 			})
 		}
 
@@ -241,17 +433,37 @@ func (s *DiagramService) Validate(diagram *models.FlowDiagram) (*models.Validati
 				Path:    fmt.Sprintf("edges[%d].to", i),
 				Message: fmt.Sprintf("Edge references non-existent to node: %s", edge.To),
 				Code:    "INVALID_TO_NODE",
+				Value:   edge.To,
 			})
 		}
+
+		validateOwnership(result, fmt.Sprintf("edges[%d]", i), edge.Ownership, directory)
+		validateTiming(result, fmt.Sprintf("edges[%d]", i), edge.Duration, edge.SLA)
+
+		for j, layerID := range edge.Layers {
+			if !layerIDs[layerID] {
+				result.Errors = append(result.Errors, models.ValidationError{
+					Path:    fmt.Sprintf("edges[%d].layers[%d]", i, j),
+					Message: fmt.Sprintf("Edge references undefined layer: %s", layerID),
+					Code:    "INVALID_LAYER",
+					Value:   layerID,
+				})
+			}
+		}
 	}
 
+	validateBranching(result, diagram)
+	validateLegacyFields(result, diagram)
+	runValidationPlugins(s.cfg, result, diagram)
+
 	result.Valid = len(result.Errors) == 0
 	return result, nil
 }
 
-// Search searches for diagrams
-func (s *DiagramService) Search(query string, tags []string) ([]models.SearchResult, error) {
-	diagrams, err := s.ListAll()
+// Search searches for diagrams, leaving out archived ones unless
+// includeArchived is set
+func (s *DiagramService) Search(query string, tags []string, includeArchived bool) ([]models.SearchResult, error) {
+	diagrams, err := s.List(includeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -308,6 +520,10 @@ func (s *DiagramService) Search(query string, tags []string) ([]models.SearchRes
 			}
 		}
 
+		if diagram.Deprecated != nil {
+			score *= deprecatedScoreFactor
+		}
+
 		if score > 0 || len(tags) > 0 {
 			results = append(results, models.SearchResult{
 				Diagram:   diagram,
@@ -317,12 +533,25 @@ func (s *DiagramService) Search(query string, tags []string) ([]models.SearchRes
 		}
 	}
 
+	// Deprecated diagrams rank below current ones
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
 	return results, nil
 }
 
-// SearchNodes searches for nodes across all diagrams
-func (s *DiagramService) SearchNodes(query string, nodeType string) ([]models.NodeSearchResult, error) {
-	diagrams, err := s.ListAll()
+// NodeSearchOptions narrows a node search
+type NodeSearchOptions struct {
+	Type            string // Node type to match
+	Owner           string // Person or team ID holding an ownership role
+	Role            string // Restricts Owner to one role; empty matches any role
+	IncludeDiagram  bool   // Attach the full parent diagram to each result
+	IncludeArchived bool   // Search archived diagrams too
+}
+
+// SearchNodes searches for nodes across all diagrams. Each result carries a
+// summary of its diagram; IncludeDiagram also attaches the full diagram.
+func (s *DiagramService) SearchNodes(query string, opts NodeSearchOptions) ([]models.NodeSearchResult, error) {
+	diagrams, err := s.List(opts.IncludeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -330,13 +559,21 @@ func (s *DiagramService) SearchNodes(query string, nodeType string) ([]models.No
 	results := []models.NodeSearchResult{}
 	query = strings.ToLower(query)
 
-	for _, diagram := range diagrams {
+	for i := range diagrams {
+		diagram := &diagrams[i]
+		summary := diagram.Summary()
+
 		for _, node := range diagram.Nodes {
 			score := 0.0
 			matchType := ""
 
 			// Filter by node type if specified
-			if nodeType != "" && string(node.Type) != nodeType {
+			if opts.Type != "" && string(node.Type) != opts.Type {
+				continue
+			}
+
+			// Filter by owner if specified
+			if opts.Owner != "" && !ownedBy(node.Ownership, opts.Owner, opts.Role) {
 				continue
 			}
 
@@ -354,35 +591,53 @@ func (s *DiagramService) SearchNodes(query string, nodeType string) ([]models.No
 				}
 			}
 
-			if score > 0 || nodeType != "" {
-				results = append(results, models.NodeSearchResult{
-					Node:      node,
-					DiagramID: diagram.ID,
-					Diagram:   diagram,
-					Score:     score,
-					MatchType: matchType,
-				})
+			if opts.Owner != "" && (matchType == "" || query == "") {
+				matchType = "owner"
+			}
+
+			if diagram.Deprecated != nil {
+				score *= deprecatedScoreFactor
+			}
+
+			if score > 0 || opts.Type != "" || opts.Owner != "" {
+				result := models.NodeSearchResult{
+					Node:           node,
+					DiagramID:      diagram.ID,
+					DiagramSummary: &summary,
+					Score:          score,
+					MatchType:      matchType,
+				}
+				if opts.IncludeDiagram {
+					result.Diagram = diagram
+				}
+				results = append(results, result)
 			}
 		}
 	}
 
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
 	return results, nil
 }
 
 // Private helper methods
 
-func (s *DiagramService) loadDiagramFromFile(filePath string) (*models.FlowDiagram, error) {
+// parseDiagramFile reads and parses a diagram file
+func (s *DiagramService) parseDiagramFile(filePath string) (*models.FlowDiagram, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	var diagram models.FlowDiagram
-	if err := yaml.Unmarshal(data, &diagram); err != nil {
+	if err := s.unmarshalDiagramYAML(data, &diagram); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
 	diagram.FilePath = filePath
+	if diagram.ContentHash, err = diagramContentHash(&diagram); err != nil {
+		return nil, err
+	}
 	return &diagram, nil
 }
 
@@ -392,9 +647,18 @@ func (s *DiagramService) saveDiagramToFile(diagram *models.FlowDiagram, filePath
 		return fmt.Errorf("failed to marshal diagram to YAML: %w", err)
 	}
 
-	if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+	if err := writeDiagramFile(filePath, data); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	// Hash what was written, as a later read will
+	var saved models.FlowDiagram
+	if err := s.unmarshalDiagramYAML(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse written YAML: %w", err)
+	}
+	diagram.ContentHash, err = diagramContentHash(&saved)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -406,8 +670,9 @@ func (s *DiagramService) validateDiagram(diagram *models.FlowDiagram) error {
 	}
 
 	if !result.Valid {
-		return fmt.Errorf("diagram validation failed: %d errors", len(result.Errors))
+		return fmt.Errorf("%w: validation failed with %d errors", ErrInvalidDiagram, len(result.Errors))
 	}
+	diagram.Validation = validationStatus(result)
 
 	return nil
 }
@@ -427,7 +692,12 @@ func (s *DiagramService) LoadYAMLByID(id string) (string, error) {
 		}
 	}
 	if found == "" {
-		return "", ErrDiagramNotFound
+		// Stored elsewhere, e.g. in a folder or the archive
+		diagram, err := s.GetByID(id)
+		if err != nil {
+			return "", err
+		}
+		found = diagram.FilePath
 	}
 	b, err := os.ReadFile(found)
 	if err != nil {
@@ -436,40 +706,102 @@ func (s *DiagramService) LoadYAMLByID(id string) (string, error) {
 	return string(b), nil
 }
 
-// SaveYAMLByID writes YAML content to the diagram file, validating it first
-func (s *DiagramService) SaveYAMLByID(id, yamlText string) error {
+// SaveYAMLByID saves a diagram given as YAML, creating it if it does not
+// exist. It is saved like a diagram given as JSON: the timestamps in the
+// YAML are replaced, and base, if not empty, is the content hash of the
+// version the client read.
+func (s *DiagramService) SaveYAMLByID(id, yamlText, base string) (*models.FlowDiagram, error) {
+	if err := s.checkYAMLSize(len(yamlText)); err != nil {
+		return nil, err
+	}
+
 	// Parse YAML to ensure validity and that ID matches
 	var diagram models.FlowDiagram
-	if err := yaml.Unmarshal([]byte(yamlText), &diagram); err != nil {
-		return fmt.Errorf("failed to parse YAML: %w", err)
+	if err := s.unmarshalDiagramYAML([]byte(yamlText), &diagram); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 	if diagram.ID == "" {
 		// If no ID in YAML, set from path
 		diagram.ID = id
 	} else if diagram.ID != id {
-		return fmt.Errorf("diagram id mismatch: yaml has '%s', path has '%s'", diagram.ID, id)
+		return nil, fmt.Errorf("%w: diagram id mismatch: yaml has '%s', path has '%s'", ErrInvalidOptions, diagram.ID, id)
 	}
+	diagram.ContentHash = base
 
-	// Validate semantic model
-	if err := s.validateDiagram(&diagram); err != nil {
-		return err
+	event := SaveEventUpdate
+	if _, err := s.GetByID(id); err == ErrDiagramNotFound {
+		event = SaveEventCreate
+	} else if err != nil {
+		return nil, err
 	}
+	return s.save(&diagram, event, "")
+}
 
-	// Determine file path (prefer .yaml)
-	if err := os.MkdirAll(s.cfg.DiagramsPath, 0o755); err != nil {
-		return fmt.Errorf("failed to ensure diagrams dir: %w", err)
+// CreateYAML creates a diagram given as YAML and returns it with the YAML
+// it was stored as. Without an ID, one is derived from the name, or
+// "diagram", made unique among the existing diagrams.
+func (s *DiagramService) CreateYAML(yamlText string) (*models.FlowDiagram, []byte, error) {
+	if strings.TrimSpace(yamlText) == "" {
+		return nil, nil, fmt.Errorf("%w: YAML body is empty", ErrInvalidOptions)
+	}
+	if err := s.checkYAMLSize(len(yamlText)); err != nil {
+		return nil, nil, err
+	}
+	var diagram models.FlowDiagram
+	if err := s.unmarshalDiagramYAML([]byte(yamlText), &diagram); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
-	filePath := filepath.Join(s.cfg.DiagramsPath, id+".yaml")
 
-	// Marshal back to canonical YAML to keep formatting consistent
-	out, err := s.marshalDiagramYAML(&diagram)
+	existing, err := s.ListAll()
 	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
+		return nil, nil, err
 	}
-	if err := os.WriteFile(filePath, out, 0o644); err != nil {
-		return fmt.Errorf("failed to write YAML: %w", err)
+	ids := newIDAllocator()
+	for _, d := range existing {
+		if d.ID == diagram.ID {
+			return nil, nil, fmt.Errorf("%w: %s", ErrDiagramExists, diagram.ID)
+		}
+		ids.used[d.ID] = true
 	}
-	return nil
+	if diagram.ID == "" {
+		name := strings.ToLower(diagram.Name)
+		if strings.TrimSpace(name) == "" {
+			name = "diagram"
+		}
+		diagram.ID = ids.allocate(name)
+	}
+	diagram.ContentHash = ""
+
+	created, err := s.save(&diagram, SaveEventCreate, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.dryRun != nil {
+		stored, err := s.marshalDiagramYAML(created)
+		return created, stored, err
+	}
+	stored, err := os.ReadFile(created.FilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read stored YAML: %w", err)
+	}
+	return created, stored, nil
+}
+
+// FormatYAML returns diagram YAML in the canonical style the server saves
+// it in, without saving it. Fields the model does not know are dropped,
+// as they would be on save.
+func (s *DiagramService) FormatYAML(yamlText string) ([]byte, error) {
+	if strings.TrimSpace(yamlText) == "" {
+		return nil, fmt.Errorf("%w: YAML body is empty", ErrInvalidOptions)
+	}
+	if err := s.checkYAMLSize(len(yamlText)); err != nil {
+		return nil, err
+	}
+	var diagram models.FlowDiagram
+	if err := s.unmarshalDiagramYAML([]byte(yamlText), &diagram); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	return s.marshalDiagramYAML(&diagram)
 }
 
 // marshalDiagramYAML marshals the diagram to YAML and normalizes key styles for consistency.
@@ -487,6 +819,10 @@ func (s *DiagramService) marshalDiagramYAML(diagram *models.FlowDiagram) ([]byte
 	}
 	// Walk and normalize key styles
 	normalizeMapKeyStyles(&root)
+	// Write translations back as locale maps on their fields
+	for _, entity := range entityMappings(&root) {
+		collapseLocalizedFields(entity, s.cfg.DefaultLocale)
+	}
 	// Encode with a stable indent
 	var buf bytes.Buffer
 	enc := yaml.NewEncoder(&buf)
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Orders for diagram summary lists
+const (
+	SummarySortID         = "id"
+	SummarySortComplexity = "complexity" // Most nodes and edges first, then deepest
+	SummarySortHealth     = "health"     // Most errors first, then most warnings
+)
+
+var summarySorts = []string{SummarySortID, SummarySortComplexity, SummarySortHealth}
+
+// Diagram stats are indexed by file and reused until the file changes, so
+// that listing does not validate every diagram each time
+var (
+	statsMu    sync.Mutex
+	statsIndex = make(map[string]statsEntry)
+)
+
+type statsEntry struct {
+	modTime time.Time
+	size    int64
+	stats   models.DiagramStats
+}
+
+// Summaries returns the summaries of the listed diagrams with their stats,
+// in the given order
+func (s *DiagramService) Summaries(order string, includeArchived bool) ([]models.DiagramSummary, error) {
+	if order == "" {
+		order = SummarySortID
+	}
+	if !containsValue(summarySorts, order) {
+		return nil, fmt.Errorf("%w: sort must be one of %s", ErrInvalidOptions, strings.Join(summarySorts, ", "))
+	}
+	diagrams, err := s.List(includeArchived)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.DiagramSummary, 0, len(diagrams))
+	for i := range diagrams {
+		stats, err := s.Stats(&diagrams[i])
+		if err != nil {
+			return nil, err
+		}
+		summary := diagrams[i].Summary()
+		summary.Stats = stats
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		switch order {
+		case SummarySortComplexity:
+			if a.NodeCount+a.EdgeCount != b.NodeCount+b.EdgeCount {
+				return a.NodeCount+a.EdgeCount > b.NodeCount+b.EdgeCount
+			}
+			if a.Stats.MaxDepth != b.Stats.MaxDepth {
+				return a.Stats.MaxDepth > b.Stats.MaxDepth
+			}
+		case SummarySortHealth:
+			if a.Stats.Errors != b.Stats.Errors {
+				return a.Stats.Errors > b.Stats.Errors
+			}
+			if a.Stats.Warnings != b.Stats.Warnings {
+				return a.Stats.Warnings > b.Stats.Warnings
+			}
+		}
+		return a.ID < b.ID
+	})
+	return summaries, nil
+}
+
+// Stats returns the stats of a stored diagram, from the index while its
+// file is unchanged
+func (s *DiagramService) Stats(diagram *models.FlowDiagram) (*models.DiagramStats, error) {
+	info, err := os.Stat(diagram.FilePath)
+	if err != nil {
+		return s.computeStats(diagram)
+	}
+	statsMu.Lock()
+	entry, ok := statsIndex[diagram.FilePath]
+	statsMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		stats := entry.stats
+		return &stats, nil
+	}
+
+	stats, err := s.computeStats(diagram)
+	if err != nil {
+		return nil, err
+	}
+	statsMu.Lock()
+	statsIndex[diagram.FilePath] = statsEntry{modTime: info.ModTime(), size: info.Size(), stats: *stats}
+	statsMu.Unlock()
+	return stats, nil
+}
+
+func (s *DiagramService) computeStats(diagram *models.FlowDiagram) (*models.DiagramStats, error) {
+	result, err := s.Validate(diagram)
+	if err != nil {
+		return nil, err
+	}
+	status := validationStatus(result)
+	return &models.DiagramStats{
+		MaxDepth:   maxDepth(diagram),
+		Validation: status.Status,
+		Errors:     status.Errors,
+		Warnings:   status.Warnings,
+	}, nil
+}
+
+// maxDepth is the number of nodes on the longest path through the diagram,
+// not counting edges that close a loop
+func maxDepth(diagram *models.FlowDiagram) int {
+	if len(diagram.Nodes) == 0 {
+		return 0
+	}
+	_, rank, _ := rankNodes(diagram)
+	depth := 0
+	for _, r := range rank {
+		if r+1 > depth {
+			depth = r + 1
+		}
+	}
+	return depth
+}
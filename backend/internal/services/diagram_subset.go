@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Boundary edge directions, recorded as the "boundary" metadata of edges
+// that cross the edge of a subset
+const (
+	BoundaryIncoming = "incoming"
+	BoundaryOutgoing = "outgoing"
+)
+
+// Subset returns a standalone diagram of the selected nodes and the edges
+// between them, for discussing one part of a large diagram. Edges crossing
+// the selection are kept and marked: the node outside becomes a dashed
+// external stub, and the edge is dashed with "boundary" metadata saying
+// whether it enters or leaves the selection. The subset validates on its
+// own and is never saved.
+func (s *DiagramService) Subset(id string, nodeIDs []string) (*models.FlowDiagram, error) {
+	if len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("%w: at least one node is required", ErrInvalidOptions)
+	}
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if findNode(diagram, nodeID) == nil {
+			return nil, fmt.Errorf("%w: node %s not found", ErrInvalidOptions, nodeID)
+		}
+		selected[nodeID] = true
+	}
+
+	description := fmt.Sprintf("Selection of %d nodes from %s", len(selected), diagram.Name)
+	subset := &models.FlowDiagram{
+		FlowEntity: models.FlowEntity{
+			ID:          diagram.ID + "-subset",
+			Name:        diagram.Name + " (selection)",
+			Description: &description,
+			Tags:        diagram.Tags,
+			Metadata: map[string]interface{}{
+				"generated": true,
+				"source":    diagram.ID,
+			},
+		},
+		Version: diagram.Version,
+		Styles:  diagram.Styles,
+		Layout:  diagram.Layout,
+		Layers:  diagram.Layers,
+		Nodes:   []models.FlowNode{},
+		Edges:   []models.FlowEdge{},
+		Created: time.Now(),
+		Updated: time.Now(),
+	}
+
+	stubbed := make(map[string]bool)
+	var stubs []models.FlowNode
+	dashed := "4,4"
+	for _, edge := range diagram.Edges {
+		var boundary, outside string
+		switch {
+		case selected[edge.From] && selected[edge.To]:
+		case selected[edge.To]:
+			boundary, outside = BoundaryIncoming, edge.From
+		case selected[edge.From]:
+			boundary, outside = BoundaryOutgoing, edge.To
+		default:
+			continue
+		}
+		if boundary != "" {
+			if !stubbed[outside] {
+				stubbed[outside] = true
+				if node := findNode(diagram, outside); node != nil {
+					stubs = append(stubs, boundaryStub(node))
+				}
+			}
+			edge.Metadata = withMetadata(edge.Metadata, "boundary", boundary)
+			style := models.Style{}
+			if edge.Style != nil {
+				style = *edge.Style
+			}
+			style.StrokeDasharray = &dashed
+			edge.Style = &style
+		}
+		subset.Edges = append(subset.Edges, edge)
+	}
+	for _, node := range diagram.Nodes {
+		if selected[node.ID] {
+			subset.Nodes = append(subset.Nodes, node)
+		}
+	}
+	subset.Nodes = append(subset.Nodes, stubs...)
+	return subset, nil
+}
+
+// boundaryStub stands in for a node outside a subset that an edge of the
+// selection connects to
+func boundaryStub(node *models.FlowNode) models.FlowNode {
+	dashed, gray := "4,4", "#95a5a6"
+	return models.FlowNode{
+		FlowEntity: models.FlowEntity{
+			ID:   node.ID,
+			Name: node.Name,
+			Metadata: map[string]interface{}{
+				"boundary": true,
+				"type":     string(node.Type),
+			},
+		},
+		Type:       models.NodeTypeExternal,
+		Position:   node.Position,
+		Dimensions: node.Dimensions,
+		Style:      &models.Style{Stroke: &gray, StrokeDasharray: &dashed},
+		Layers:     node.Layers,
+	}
+}
+
+// withMetadata returns a copy of metadata with key set
+func withMetadata(metadata map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// NodeTransferOptions describes nodes to copy or move into a target diagram
+type NodeTransferOptions struct {
+	SourceID     string          `json:"sourceId"`
+	NodeIDs      []string        `json:"nodeIds"`
+	Offset       models.Position `json:"offset"`
+	IncludeEdges bool            `json:"includeEdges"` // Copy edges between the selected nodes
+}
+
+// NodeTransferResult reports the outcome of a copy or move
+type NodeTransferResult struct {
+	Target  models.FlowDiagram  `json:"target"`
+	Source  *models.FlowDiagram `json:"source,omitempty"` // Set for moves only
+	NodeIDs map[string]string   `json:"nodeIds"`          // Original to new node IDs
+	EdgeIDs map[string]string   `json:"edgeIds"`          // Original to new edge IDs
+}
+
+// CopyNodes copies nodes from a source diagram into the target diagram.
+// IDs that collide with existing elements in the target are remapped.
+func (s *DiagramService) CopyNodes(targetID string, opts NodeTransferOptions) (*NodeTransferResult, error) {
+	return s.transferNodes(targetID, opts, false)
+}
+
+// MoveNodes moves nodes from a source diagram into the target diagram. The
+// nodes and every edge touching them are removed from the source, which
+// defaults to the target like for copies and must differ from it.
+func (s *DiagramService) MoveNodes(targetID string, opts NodeTransferOptions) (*NodeTransferResult, error) {
+	if opts.SourceID == "" {
+		opts.SourceID = targetID
+	}
+	if opts.SourceID == targetID {
+		return nil, fmt.Errorf("source and target diagram must differ when moving nodes")
+	}
+	return s.transferNodes(targetID, opts, true)
+}
+
+func (s *DiagramService) transferNodes(targetID string, opts NodeTransferOptions, move bool) (*NodeTransferResult, error) {
+	if opts.SourceID == "" {
+		opts.SourceID = targetID
+	}
+	if len(opts.NodeIDs) == 0 {
+		return nil, fmt.Errorf("at least one node ID is required")
+	}
+
+	target, err := s.GetByID(targetID)
+	if err != nil {
+		return nil, err
+	}
+	source := target
+	if opts.SourceID != targetID {
+		if source, err = s.GetByID(opts.SourceID); err != nil {
+			return nil, err
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, id := range opts.NodeIDs {
+		selected[id] = true
+	}
+
+	takenNodes := make(map[string]bool)
+	for _, node := range target.Nodes {
+		takenNodes[node.ID] = true
+	}
+	takenEdges := make(map[string]bool)
+	for _, edge := range target.Edges {
+		takenEdges[edge.ID] = true
+	}
+	remap := func(id string, taken map[string]bool) string {
+		newID := id
+		if taken[newID] {
+			newID = id + "_copy"
+			for i := 2; taken[newID]; i++ {
+				newID = fmt.Sprintf("%s_copy%d", id, i)
+			}
+		}
+		taken[newID] = true
+		return newID
+	}
+
+	result := &NodeTransferResult{
+		NodeIDs: make(map[string]string),
+		EdgeIDs: make(map[string]string),
+	}
+
+	var nodes []models.FlowNode
+	for _, node := range source.Nodes {
+		if !selected[node.ID] {
+			continue
+		}
+		newID := remap(node.ID, takenNodes)
+		result.NodeIDs[node.ID] = newID
+		node.ID = newID
+		node.Position.X += opts.Offset.X
+		node.Position.Y += opts.Offset.Y
+		nodes = append(nodes, node)
+	}
+	for _, id := range opts.NodeIDs {
+		if _, ok := result.NodeIDs[id]; !ok {
+			return nil, fmt.Errorf("node %s not found in diagram %s", id, source.ID)
+		}
+	}
+
+	var edges []models.FlowEdge
+	if opts.IncludeEdges {
+		for _, edge := range source.Edges {
+			if !selected[edge.From] || !selected[edge.To] {
+				continue
+			}
+			newID := remap(edge.ID, takenEdges)
+			result.EdgeIDs[edge.ID] = newID
+			edge.ID = newID
+			edge.From = result.NodeIDs[edge.From]
+			edge.To = result.NodeIDs[edge.To]
+			edges = append(edges, edge)
+		}
+	}
+
+	target.Nodes = append(target.Nodes, nodes...)
+	target.Edges = append(target.Edges, edges...)
+
+	if move {
+		var keptNodes []models.FlowNode
+		for _, node := range source.Nodes {
+			if !selected[node.ID] {
+				keptNodes = append(keptNodes, node)
+			}
+		}
+		var keptEdges []models.FlowEdge
+		for _, edge := range source.Edges {
+			if !selected[edge.From] && !selected[edge.To] {
+				keptEdges = append(keptEdges, edge)
+			}
+		}
+		source.Nodes = keptNodes
+		source.Edges = keptEdges
+
+		if err := s.validateDiagram(source); err != nil {
+			return nil, fmt.Errorf("%s: %w", source.ID, err)
+		}
+	}
+	if err := s.validateDiagram(target); err != nil {
+		return nil, fmt.Errorf("%s: %w", target.ID, err)
+	}
+
+	// A move writes the target first; it is put back if the source save fails
+	original, err := s.GetByID(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	updatedTarget, err := s.Update(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update target diagram: %w", err)
+	}
+	result.Target = *updatedTarget
+
+	if move {
+		updatedSource, err := s.Update(source)
+		if err != nil {
+			err = fmt.Errorf("failed to update source diagram: %w", err)
+			return nil, errors.Join(err, s.restoreDiagrams([]*models.FlowDiagram{original}))
+		}
+		result.Source = updatedSource
+	}
+
+	return result, nil
+}
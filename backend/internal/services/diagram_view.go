@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// View returns a filtered projection of a diagram containing only the nodes
+// matched by the selector. Connectivity through removed nodes is preserved
+// with synthesized pass-through edges. The projection is never saved.
+func (s *DiagramService) View(id string, selector ElementSelector, layers []string) (*models.FlowDiagram, error) {
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	diagram, err = FilterLayers(diagram, layers)
+	if err != nil {
+		return nil, err
+	}
+
+	selector.Elements = SelectNodes
+	keep := make(map[string]bool)
+	for i := range diagram.Nodes {
+		if selector.MatchesNode(&diagram.Nodes[i]) {
+			keep[diagram.Nodes[i].ID] = true
+		}
+	}
+
+	return projectDiagram(diagram, keep), nil
+}
+
+// projectDiagram keeps only the given nodes. Edges between kept nodes are
+// retained; paths that pass through removed nodes are replaced by a single
+// synthesized edge between the kept endpoints.
+func projectDiagram(diagram *models.FlowDiagram, keep map[string]bool) *models.FlowDiagram {
+	projection := *diagram
+	projection.Nodes = []models.FlowNode{}
+	projection.Edges = []models.FlowEdge{}
+
+	for _, node := range diagram.Nodes {
+		if keep[node.ID] {
+			projection.Nodes = append(projection.Nodes, node)
+		}
+	}
+
+	outgoing := make(map[string][]models.FlowEdge)
+	for _, edge := range diagram.Edges {
+		outgoing[edge.From] = append(outgoing[edge.From], edge)
+	}
+
+	direct := make(map[[2]string]bool)
+	for _, edge := range diagram.Edges {
+		if keep[edge.From] && keep[edge.To] {
+			projection.Edges = append(projection.Edges, edge)
+			direct[[2]string{edge.From, edge.To}] = true
+		}
+	}
+
+	edgeIDs := make(map[string]bool)
+	for _, edge := range diagram.Edges {
+		edgeIDs[edge.ID] = true
+	}
+
+	for _, node := range projection.Nodes {
+		// Walk forward through removed nodes to find the kept nodes reachable
+		// from this one without passing another kept node
+		reached := make(map[string][]string)
+		visited := map[string]bool{node.ID: true}
+		type step struct {
+			id  string
+			via []string
+		}
+		var queue []step
+		for _, edge := range outgoing[node.ID] {
+			if !keep[edge.To] && !visited[edge.To] {
+				visited[edge.To] = true
+				queue = append(queue, step{id: edge.To, via: []string{edge.To}})
+			}
+		}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, edge := range outgoing[current.id] {
+				if keep[edge.To] {
+					if _, ok := reached[edge.To]; !ok {
+						reached[edge.To] = current.via
+					}
+					continue
+				}
+				if visited[edge.To] {
+					continue
+				}
+				visited[edge.To] = true
+				via := append(append([]string{}, current.via...), edge.To)
+				queue = append(queue, step{id: edge.To, via: via})
+			}
+		}
+
+		targets := make([]string, 0, len(reached))
+		for target := range reached {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			if direct[[2]string{node.ID, target}] {
+				continue
+			}
+			id := node.ID + "_via_" + target
+			for i := 2; edgeIDs[id]; i++ {
+				id = fmt.Sprintf("%s_via_%s_%d", node.ID, target, i)
+			}
+			edgeIDs[id] = true
+			projection.Edges = append(projection.Edges, models.FlowEdge{
+				FlowEntity: models.FlowEntity{
+					ID:   id,
+					Name: "",
+					Metadata: map[string]interface{}{
+						"synthesized": true,
+						"via":         reached[target],
+					},
+				},
+				Type: models.ConnectionTypeSequence,
+				From: node.ID,
+				To:   target,
+			})
+			direct[[2]string{node.ID, target}] = true
+		}
+	}
+
+	return &projection
+}
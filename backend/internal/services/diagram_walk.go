@@ -0,0 +1,177 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// DiagramRoot is a directory diagrams are read from
+type DiagramRoot struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	ReadOnly bool   `json:"readOnly,omitempty"` // Diagrams in it cannot be saved, moved or deleted
+}
+
+// diagramRoots returns the directories diagrams are read from: the
+// diagrams path, where new diagrams are written, followed by the further
+// DIAGRAM_ROOTS, given as paths or as name=path. A root is named after its
+// folder unless named explicitly. Roots inside another root are walked as
+// part of it.
+func (s *DiagramService) diagramRoots() []DiagramRoot {
+	roots := make([]DiagramRoot, 0, len(s.cfg.DiagramRoots)+1)
+	for i, entry := range append([]string{s.cfg.DiagramsPath}, s.cfg.DiagramRoots...) {
+		root := DiagramRoot{Path: entry}
+		if i == 0 {
+			root.Name = s.cfg.DiagramsRootName
+		} else if name, path, ok := strings.Cut(entry, "="); ok {
+			root.Name, root.Path = name, path
+		}
+		root.Path = filepath.Clean(root.Path)
+		if root.Name == "" {
+			root.Name = filepath.Base(root.Path)
+		}
+		nested := false
+		for _, other := range roots {
+			nested = nested || isBelow(other.Path, root.Path)
+		}
+		if nested {
+			continue
+		}
+		for _, readOnly := range s.cfg.DiagramReadOnlyRoots {
+			root.ReadOnly = root.ReadOnly || readOnly == root.Name
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// rootOf returns the diagram root holding a path, or the diagrams path
+func (s *DiagramService) rootOf(path string) DiagramRoot {
+	roots := s.diagramRoots()
+	for _, root := range roots {
+		if isBelow(root.Path, path) {
+			return root
+		}
+	}
+	return roots[0]
+}
+
+// inDiagramRoots reports whether a path is below one of the diagram roots
+func (s *DiagramService) inDiagramRoots(path string) bool {
+	for _, root := range s.diagramRoots() {
+		if isBelow(root.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWritable refuses changes to a diagram file in a read-only root
+func (s *DiagramService) checkWritable(path string) error {
+	if root := s.rootOf(path); root.ReadOnly {
+		return fmt.Errorf("%w: %s is in the read-only root %s", ErrReadOnly, s.relativeDiagramPath(path), root.Name)
+	}
+	return nil
+}
+
+// annotateDiagram sets the fields describing where a diagram was loaded
+// from
+func (s *DiagramService) annotateDiagram(diagram *models.FlowDiagram, path string) {
+	root := s.rootOf(path)
+	diagram.ChangedExternally = changedExternallyAt(path)
+	diagram.Archived = s.isArchivedPath(path)
+	diagram.Root, diagram.ReadOnly = root.Name, root.ReadOnly
+}
+
+// ignoredByWalk reports whether a file or folder name matches one of the
+// DIAGRAM_WALK_IGNORE patterns
+func (s *DiagramService) ignoredByWalk(name string) bool {
+	for _, pattern := range s.cfg.DiagramWalkIgnore {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// walkDiagramPaths calls fn with every diagram file below the diagram
+// roots, in path order within each root. Folders matching an ignore
+// pattern are skipped. Symbolic links to folders are followed, but a
+// folder reached again, through a link cycle or overlapping roots, is not
+// walked twice. A missing or unreadable diagrams path fails the walk;
+// missing further roots, reported by the startup checks, and unreadable
+// folders are skipped. An error from fn ends the walk.
+func (s *DiagramService) walkDiagramPaths(fn func(path string) error) error {
+	visited := make(map[string]bool) // Real paths of the folders walked
+	for i, diagramRoot := range s.diagramRoots() {
+		root := diagramRoot.Path
+		info, err := os.Stat(root)
+		if err != nil && i > 0 {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", root)
+		}
+		real, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return err
+		}
+		if err := s.walkDiagramFolder(root, real, true, visited, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DiagramService) walkDiagramFolder(dir, real string, root bool, visited map[string]bool, fn func(path string) error) error {
+	if visited[real] {
+		return nil
+	}
+	visited[real] = true
+	entries, err := os.ReadDir(dir)
+	if err != nil && root {
+		return err
+	} else if err != nil {
+		log.Printf("Skipping unreadable folder %s: %v", dir, err)
+		return nil
+	}
+
+	for _, entry := range entries {
+		if s.ignoredByWalk(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		isDir, realPath := entry.IsDir(), filepath.Join(real, entry.Name())
+		if entry.Type()&os.ModeSymlink != 0 {
+			// Broken links are left alone; links to folders are resolved
+			// so that cycles are noticed
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			isDir = info.IsDir()
+			if isDir {
+				if realPath, err = filepath.EvalSymlinks(path); err != nil {
+					continue
+				}
+			}
+		}
+		if isDir {
+			if err := s.walkDiagramFolder(path, realPath, false, visited, fn); err != nil {
+				return err
+			}
+		} else if isDiagramFileName(entry.Name()) {
+			if err := fn(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Rect is an axis-aligned rectangle in diagram coordinates
+type Rect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+func (r Rect) intersects(o Rect) bool {
+	return r.X <= o.X+o.W && o.X <= r.X+r.W && r.Y <= o.Y+o.H && o.Y <= r.Y+r.H
+}
+
+// DiagramWindow is the part of a diagram inside a viewport
+type DiagramWindow struct {
+	Diagram    models.FlowDiagram `json:"diagram"` // Only the nodes and edges in the window
+	Window     Rect               `json:"window"`
+	Bounds     Rect               `json:"bounds"`  // Of the whole diagram
+	Anchors    []models.FlowNode  `json:"anchors"` // Nodes outside the window that its edges connect to
+	TotalNodes int                `json:"totalNodes"`
+	TotalEdges int                `json:"totalEdges"`
+}
+
+// Spatial indexes are kept per diagram file and rebuilt when the file
+// changes, so panning around a large diagram does not scan every element
+var (
+	windowMu    sync.Mutex
+	windowIndex = make(map[string]*spatialIndex)
+)
+
+// spatialIndex is an R-tree over the node bounds and edge segments of a
+// diagram, which refer to them by their position in its Nodes and Edges
+type spatialIndex struct {
+	modTime time.Time
+	size    int64
+	nodes   []Rect
+	edges   [][]models.Position // Drawn polylines
+	tree    *rtree
+	bounds  Rect
+}
+
+// Window returns the nodes intersecting a viewport and the edges crossing
+// it, for editors showing diagrams with thousands of nodes. Nodes outside
+// the viewport that those edges connect to are returned as anchors.
+func (s *DiagramService) Window(id string, window Rect) (*DiagramWindow, error) {
+	if window.W <= 0 || window.H <= 0 {
+		return nil, fmt.Errorf("%w: window width and height must be positive", ErrInvalidOptions)
+	}
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	index := spatialIndexOf(diagram)
+	nodeIDs, edgeIDs := index.query(window)
+
+	result := &DiagramWindow{
+		Diagram:    *diagram,
+		Window:     window,
+		Bounds:     index.bounds,
+		Anchors:    []models.FlowNode{},
+		TotalNodes: len(diagram.Nodes),
+		TotalEdges: len(diagram.Edges),
+	}
+	nodesByID := make(map[string]*models.FlowNode, len(diagram.Nodes))
+	for i := range diagram.Nodes {
+		nodesByID[diagram.Nodes[i].ID] = &diagram.Nodes[i]
+	}
+	result.Diagram.Nodes = make([]models.FlowNode, 0, len(nodeIDs))
+	inWindow := make(map[string]bool, len(nodeIDs))
+	for _, i := range nodeIDs {
+		result.Diagram.Nodes = append(result.Diagram.Nodes, diagram.Nodes[i])
+		inWindow[diagram.Nodes[i].ID] = true
+	}
+	result.Diagram.Edges = make([]models.FlowEdge, 0, len(edgeIDs))
+	anchored := make(map[string]bool)
+	for _, i := range edgeIDs {
+		edge := diagram.Edges[i]
+		result.Diagram.Edges = append(result.Diagram.Edges, edge)
+		for _, end := range []string{edge.From, edge.To} {
+			if inWindow[end] || anchored[end] {
+				continue
+			}
+			anchored[end] = true
+			if node, ok := nodesByID[end]; ok {
+				result.Anchors = append(result.Anchors, *node)
+			}
+		}
+	}
+	return result, nil
+}
+
+// spatialIndexOf returns the index of a stored diagram, from the cache
+// while its file is unchanged
+func spatialIndexOf(diagram *models.FlowDiagram) *spatialIndex {
+	info, err := os.Stat(diagram.FilePath)
+	if err != nil {
+		return buildSpatialIndex(diagram)
+	}
+	windowMu.Lock()
+	index, ok := windowIndex[diagram.FilePath]
+	windowMu.Unlock()
+	if ok && index.modTime.Equal(info.ModTime()) && index.size == info.Size() {
+		return index
+	}
+
+	index = buildSpatialIndex(diagram)
+	index.modTime, index.size = info.ModTime(), info.Size()
+	windowMu.Lock()
+	windowIndex[diagram.FilePath] = index
+	windowMu.Unlock()
+	return index
+}
+
+func buildSpatialIndex(diagram *models.FlowDiagram) *spatialIndex {
+	index := &spatialIndex{
+		nodes: make([]Rect, len(diagram.Nodes)),
+		edges: make([][]models.Position, len(diagram.Edges)),
+	}
+	minX, minY, maxX, maxY := diagramBounds(diagram)
+	index.bounds = Rect{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}
+
+	var entries []rtreeEntry
+	nodesByID := make(map[string]*models.FlowNode, len(diagram.Nodes))
+	for i := range diagram.Nodes {
+		node := &diagram.Nodes[i]
+		nodesByID[node.ID] = node
+		x, y, w, h := nodeBounds(node)
+		index.nodes[i] = Rect{X: x, Y: y, W: w, H: h}
+		entries = append(entries, rtreeEntry{box: index.nodes[i], node: i, edge: -1})
+	}
+	for i := range diagram.Edges {
+		points := edgePoints(&diagram.Edges[i], nodesByID)
+		index.edges[i] = points
+		for j := 1; j < len(points); j++ {
+			entries = append(entries, rtreeEntry{box: segmentBox(points[j-1], points[j]), node: -1, edge: i, segment: j - 1})
+		}
+	}
+	index.tree = newRTree(entries)
+	return index
+}
+
+// segmentBox is the bounding box of the segment from a to b
+func segmentBox(a, b models.Position) Rect {
+	return Rect{X: math.Min(a.X, b.X), Y: math.Min(a.Y, b.Y), W: math.Abs(a.X - b.X), H: math.Abs(a.Y - b.Y)}
+}
+
+// query returns the positions of the nodes intersecting a window and of the
+// edges crossing it, in document order
+func (x *spatialIndex) query(window Rect) (nodes, edges []int) {
+	seenEdges := make(map[int]bool)
+	x.tree.search(window, func(entry rtreeEntry) {
+		if entry.node >= 0 {
+			nodes = append(nodes, entry.node)
+			return
+		}
+		points := x.edges[entry.edge]
+		if !seenEdges[entry.edge] && segmentIntersects(points[entry.segment], points[entry.segment+1], window) {
+			seenEdges[entry.edge] = true
+			edges = append(edges, entry.edge)
+		}
+	})
+	sort.Ints(nodes)
+	sort.Ints(edges)
+	return nodes, edges
+}
+
+// segmentIntersects clips the segment from a to b against a rectangle
+// (Liang-Barsky) and reports whether anything is left
+func segmentIntersects(a, b models.Position, r Rect) bool {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t0, t1 := 0.0, 1.0
+	for _, edge := range [][2]float64{
+		{-dx, a.X - r.X}, {dx, r.X + r.W - a.X},
+		{-dy, a.Y - r.Y}, {dy, r.Y + r.H - a.Y},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return false
+			}
+			t1 = math.Min(t1, t)
+		}
+	}
+	return t0 <= t1
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// directoryFile caches the parsed directory, which every validation reads
+var directoryFile cachedYAMLFile[models.Directory]
+
+// Directory loads the configured people/teams directory. It returns nil
+// without error when no directory is configured, in which case ownership
+// references are not checked. The directory is shared between callers and
+// must not be modified.
+func (s *DiagramService) Directory() (*models.Directory, error) {
+	if s.cfg.DirectoryPath == "" {
+		return nil, nil
+	}
+	return directoryFile.load(s.cfg.DirectoryPath, "directory")
+}
+
+// cachedYAMLFile holds a parsed YAML file and parses it again only when
+// its path, modification time or size changes
+type cachedYAMLFile[T any] struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	value   *T
+}
+
+// load returns the parsed file, naming it as what in errors
+func (c *cachedYAMLFile[T]) load(path, what string) (*T, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.value != nil && c.path == path && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.value, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	value := new(T)
+	if err := yaml.Unmarshal(data, value); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", what, err)
+	}
+	c.path, c.modTime, c.size, c.value = path, info.ModTime(), info.Size(), value
+	return value, nil
+}
+
+// validateOwnership checks that every ownership reference exists in the
+// directory
+func validateOwnership(result *models.ValidationResult, path string, ownership *models.Ownership, directory *models.Directory) {
+	if ownership == nil || directory == nil {
+		return
+	}
+	for _, role := range models.OwnershipRoles {
+		for i, id := range ownership.Assignments(role) {
+			if directory.Has(id) {
+				continue
+			}
+			rolePath := "ownership." + role
+			if path != "" {
+				rolePath = path + "." + rolePath
+			}
+			if role != models.RoleOwner && role != models.RoleAccountable {
+				rolePath = fmt.Sprintf("%s[%d]", rolePath, i)
+			}
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    rolePath,
+				Message: fmt.Sprintf("Unknown person or team: %s", id),
+				Code:    "UNKNOWN_OWNER",
+				Value:   id,
+			})
+		}
+	}
+}
+
+// ownedBy reports whether id holds role on the ownership, or any role when
+// role is empty
+func ownedBy(ownership *models.Ownership, id, role string) bool {
+	for _, held := range ownership.Roles(id) {
+		if role == "" || held == role {
+			return true
+		}
+	}
+	return false
+}
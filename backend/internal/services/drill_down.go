@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// setDrillDown points a node at a diagram, dropping a focus set for a
+// different one
+func setDrillDown(node *models.FlowNode, diagramID string) {
+	if node.DrillDown == nil || *node.DrillDown != diagramID {
+		node.DrillDownFocus = nil
+	}
+	node.DrillDown = &diagramID
+}
+
+// validateDrillDownFocus checks the focus of drill-down nodes on its own.
+// Whether the focused node and layer exist in the target diagram is
+// checked with the other references between diagrams, by the consistency
+// check.
+func validateDrillDownFocus(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	for i, node := range diagram.Nodes {
+		focus := node.DrillDownFocus
+		if focus == nil {
+			continue
+		}
+		path := fmt.Sprintf("nodes[%d].drillDownFocus", i)
+		if node.DrillDown == nil || *node.DrillDown == "" {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path,
+				Message: "Drill-down focus requires a drillDown target",
+				Code:    "DRILL_DOWN_FOCUS_NO_TARGET",
+			})
+		}
+		if v := focus.Viewport; v != nil && (!finite(v.X) || !finite(v.Y) || !finite(v.Zoom) || v.Zoom < 0) {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".viewport",
+				Message: "Viewport needs finite coordinates and a positive zoom",
+				Code:    "INVALID_VIEWPORT",
+				Value:   *v,
+			})
+		}
+	}
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// drillDownFocusErrors checks that the node and layer a drill-down focuses
+// on exist in its target diagram
+func drillDownFocusErrors(path string, focus *models.DrillDownFocus, target *models.FlowDiagram) []models.ValidationError {
+	var errs []models.ValidationError
+	if focus.Node != "" && findNode(target, focus.Node) == nil {
+		errs = append(errs, models.ValidationError{
+			Path:    path + ".node",
+			Message: fmt.Sprintf("Drill-down node %s does not exist in %s", focus.Node, target.ID),
+			Code:    "UNKNOWN_DRILL_DOWN_NODE",
+			Value:   focus.Node,
+		})
+	}
+	if focus.Layer != "" {
+		found := false
+		for _, layer := range target.Layers {
+			found = found || layer.ID == focus.Layer
+		}
+		if !found {
+			errs = append(errs, models.ValidationError{
+				Path:    path + ".layer",
+				Message: fmt.Sprintf("Drill-down layer %s does not exist in %s", focus.Layer, target.ID),
+				Code:    "UNKNOWN_DRILL_DOWN_LAYER",
+				Value:   focus.Layer,
+			})
+		}
+	}
+	return errs
+}
+
+// drillDownFragment encodes a focus as the URL fragment of an exported
+// page, e.g. #node=payment&x=120&y=80&zoom=2. Layers are not part of it,
+// as exported pages show the visible-by-default layers only.
+func drillDownFragment(focus *models.DrillDownFocus) string {
+	if focus == nil {
+		return ""
+	}
+	params := url.Values{}
+	if focus.Node != "" {
+		params.Set("node", focus.Node)
+	}
+	if v := focus.Viewport; v != nil {
+		params.Set("x", strconv.FormatFloat(v.X, 'f', -1, 64))
+		params.Set("y", strconv.FormatFloat(v.Y, 'f', -1, 64))
+		if v.Zoom > 0 {
+			params.Set("zoom", strconv.FormatFloat(v.Zoom, 'f', -1, 64))
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "#" + params.Encode()
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// dryRunRecorder collects the changes the saves and deletes of a dry run
+// would have made
+type dryRunRecorder struct {
+	mu      sync.Mutex
+	changes []DiagramDiff
+}
+
+// DryRun returns a diagram service whose saves and deletes run every
+// check, validation and pre-save hooks included, but write nothing.
+// DryRunChanges then returns the changes they would have made.
+func (s *DiagramService) DryRun() *DiagramService {
+	dryRun := *s
+	dryRun.dryRun = &dryRunRecorder{changes: []DiagramDiff{}}
+	return &dryRun
+}
+
+// DryRunChanges returns the semantic diff of every diagram a dry run would
+// have created, changed or deleted, in order; nil when not in a dry run
+func (s *DiagramService) DryRunChanges() []DiagramDiff {
+	if s.dryRun == nil {
+		return nil
+	}
+	s.dryRun.mu.Lock()
+	defer s.dryRun.mu.Unlock()
+	return append([]DiagramDiff{}, s.dryRun.changes...)
+}
+
+// recordDryRun notes a save or delete instead of making it. before is nil
+// for a created diagram, after nil for a deleted one.
+func (s *DiagramService) recordDryRun(before, after *models.FlowDiagram) {
+	var diff DiagramDiff
+	switch {
+	case before == nil:
+		diff = DiffDiagrams(&models.FlowDiagram{}, after)
+		diff.Status = DiffAdded
+	case after == nil:
+		diff = DiffDiagrams(before, &models.FlowDiagram{})
+		diff.ID, diff.Name, diff.Status = before.ID, before.Name, DiffRemoved
+	default:
+		diff = DiffDiagrams(before, after)
+	}
+	s.dryRun.add(diff)
+}
+
+// add notes the change a save, delete or move would have made
+func (r *dryRunRecorder) add(diff DiagramDiff) {
+	r.mu.Lock()
+	r.changes = append(r.changes, diff)
+	r.mu.Unlock()
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// diagramLocks holds a mutex per diagram ID while saves of it hold or
+// wait for it, serializing the saves of one diagram
+var diagramLocks = struct {
+	sync.Mutex
+	held map[string]*diagramLock
+}{held: make(map[string]*diagramLock)}
+
+type diagramLock struct {
+	sync.Mutex
+	users int // Saves holding or waiting for the lock
+}
+
+// lockDiagram locks a diagram ID against other saves and returns the
+// function unlocking it. The lock is dropped when its last user unlocks.
+func lockDiagram(id string) func() {
+	diagramLocks.Lock()
+	lock, ok := diagramLocks.held[id]
+	if !ok {
+		lock = &diagramLock{}
+		diagramLocks.held[id] = lock
+	}
+	lock.users++
+	diagramLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		diagramLocks.Lock()
+		defer diagramLocks.Unlock()
+		if lock.users--; lock.users == 0 {
+			delete(diagramLocks.held, id)
+		}
+	}
+}
+
+// ErrEditConflict is returned when a diagram is saved over a version other
+// than the one the client read
+var ErrEditConflict = errors.New("diagram changed since it was read")
+
+// EditConflictError carries the stored version of a diagram that changed
+// since the client read it, e.g. through a Git commit, and how the
+// client's version differs from it
+type EditConflictError struct {
+	Current   *models.FlowDiagram
+	Submitted *models.FlowDiagram
+	Diff      DiagramDiff // From the current to the submitted version
+}
+
+func (e *EditConflictError) Error() string {
+	return fmt.Sprintf("%v: diagram %s is now at %s", ErrEditConflict, e.Current.ID, e.Current.ContentHash)
+}
+
+func (e *EditConflictError) Unwrap() error {
+	return ErrEditConflict
+}
+
+// diagramContentHash identifies a version of a diagram. The recorded
+// validation status is left out, as it is rewritten by validation without
+// anyone editing the diagram.
+func diagramContentHash(diagram *models.FlowDiagram) (string, error) {
+	content := *diagram
+	content.Validation = nil
+	content.FilePath = ""
+	content.ChangedExternally = nil
+	content.ContentHash = ""
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute content hash: %w", err)
+	}
+	return checksum(data), nil
+}
+
+// checkEditBase returns an EditConflictError when the stored diagram is
+// no longer at the content hash the client read, if the client sent one
+func (s *DiagramService) checkEditBase(diagram *models.FlowDiagram, base string) error {
+	if base == "" {
+		return nil
+	}
+	current, err := s.loadDiagramFromFile(diagram.FilePath)
+	if err != nil {
+		return err
+	}
+	if current.ContentHash == base {
+		return nil
+	}
+	return &EditConflictError{Current: current, Submitted: diagram, Diff: DiffDiagrams(current, diagram)}
+}
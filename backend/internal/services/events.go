@@ -0,0 +1,55 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of events sent to clients of the event stream
+const (
+	EventDiagramChangedExternally = "diagram_changed_externally"
+	EventDiagramUnreadable        = "diagram_unreadable"
+)
+
+// Event is a message sent to clients of the event stream
+type Event struct {
+	Type      string    `json:"type"`
+	DiagramID string    `json:"diagramId,omitempty"`
+	Path      string    `json:"path,omitempty"` // Relative to the diagrams path
+	Deleted   bool      `json:"deleted,omitempty"`
+	Error     string    `json:"error,omitempty"` // Why a file could not be read
+	Time      time.Time `json:"time"`
+}
+
+// Subscribers of the event stream each have a buffered channel; events are
+// dropped for subscribers that do not keep up rather than blocking
+var (
+	eventsMu          sync.Mutex
+	eventSubscribers  = make(map[chan Event]struct{})
+	eventBufferLength = 16
+)
+
+// SubscribeEvents returns a channel receiving events from now on, and a
+// function that ends the subscription
+func SubscribeEvents() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferLength)
+	eventsMu.Lock()
+	eventSubscribers[ch] = struct{}{}
+	eventsMu.Unlock()
+	return ch, func() {
+		eventsMu.Lock()
+		delete(eventSubscribers, ch)
+		eventsMu.Unlock()
+	}
+}
+
+func publishEvent(event Event) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	for ch := range eventSubscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Export formats
+const (
+	ExportFormatJSON        = "json"
+	ExportFormatYAML        = "yaml"
+	ExportFormatSVG         = "svg"
+	ExportFormatMermaid     = "mermaid"
+	ExportFormatPDF         = "pdf"
+	ExportFormatA11y        = "a11y"
+	ExportFormatHTML        = "html"
+	ExportFormatExcalidraw  = "excalidraw"
+	ExportFormatStructurizr = "structurizr"
+)
+
+// ExportOptions controls how a diagram is exported
+type ExportOptions struct {
+	Layers []string   // Layers to include; empty means the visible-by-default layers
+	PDF    PDFOptions // Page setup for PDF exports
+	Lang   string     // Language for localized names and descriptions
+}
+
+// Export is a rendered diagram ready to be served
+type Export struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// ExportService renders diagrams into external formats
+type ExportService struct {
+	diagramService *DiagramService
+}
+
+// NewExportService creates a new export service
+func NewExportService() *ExportService {
+	return &ExportService{
+		diagramService: NewDiagramService(),
+	}
+}
+
+// Export renders the diagram with the given ID in the requested format
+func (s *ExportService) Export(id, format string, opts ExportOptions) (*Export, error) {
+	diagram, err := s.diagramService.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Render(diagram, format, opts)
+}
+
+// ExportSubset renders a standalone diagram of the selected nodes, with the
+// edges crossing the selection marked; see DiagramService.Subset
+func (s *ExportService) ExportSubset(id string, nodeIDs []string, format string, opts ExportOptions) (*Export, error) {
+	subset, err := s.diagramService.Subset(id, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Render(subset, format, opts)
+}
+
+// Render renders an already loaded diagram in the requested format
+func (s *ExportService) Render(diagram *models.FlowDiagram, format string, opts ExportOptions) (*Export, error) {
+	view, err := FilterLayers(diagram, opts.Layers)
+	if err != nil {
+		return nil, err
+	}
+	s.diagramService.Localize(view, opts.Lang)
+	switch format {
+	case ExportFormatSVG, ExportFormatPDF, ExportFormatHTML, ExportFormatExcalidraw:
+		if view, err = s.diagramService.withResolvedStyles(view); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case ExportFormatJSON:
+		data, err := json.MarshalIndent(view, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal diagram to JSON: %w", err)
+		}
+		return &Export{Data: data, ContentType: "application/json", Extension: "json"}, nil
+	case ExportFormatYAML:
+		data, err := s.diagramService.marshalDiagramYAML(view)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal diagram to YAML: %w", err)
+		}
+		return &Export{Data: data, ContentType: "application/yaml", Extension: "yaml"}, nil
+	case ExportFormatSVG:
+		return &Export{Data: renderSVG(view), ContentType: "image/svg+xml", Extension: "svg"}, nil
+	case ExportFormatMermaid:
+		return &Export{Data: renderMermaid(view), ContentType: "text/plain; charset=utf-8", Extension: "mmd"}, nil
+	case ExportFormatPDF:
+		data, err := renderPDF(view, opts.PDF)
+		if err != nil {
+			return nil, err
+		}
+		return &Export{Data: data, ContentType: "application/pdf", Extension: "pdf"}, nil
+	case ExportFormatA11y:
+		return &Export{Data: renderAccessibleText(view), ContentType: "text/plain; charset=utf-8", Extension: "txt"}, nil
+	case ExportFormatHTML:
+		return &Export{Data: renderHTML(view, htmlLinks{}), ContentType: "text/html; charset=utf-8", Extension: "html"}, nil
+	case ExportFormatExcalidraw:
+		return &Export{Data: renderExcalidraw(view), ContentType: "application/vnd.excalidraw+json", Extension: "excalidraw"}, nil
+	case ExportFormatStructurizr:
+		data, err := renderStructurizr(view)
+		if err != nil {
+			return nil, err
+		}
+		return &Export{Data: data, ContentType: "text/plain; charset=utf-8", Extension: "dsl"}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// ExportHTMLTree renders a diagram and all its descendants as a zip of HTML
+// pages, one per diagram and named after its ID, with drill-down nodes and
+// parent links pointing at the sibling pages. index.html opens the root.
+// Each page shows its diagram's visible-by-default layers.
+func (s *ExportService) ExportHTMLTree(rootID, lang string) (*Export, error) {
+	tree, err := NewHierarchyService().GetHierarchyTree(rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A diagram reached through several branches gets one page, linking
+	// up to the parent it was first reached from
+	var diagrams []*models.FlowDiagram
+	parents := make(map[string]*models.FlowDiagram)
+	included := make(map[string]bool)
+	var walk func(node *HierarchyNode, parent *models.FlowDiagram)
+	walk = func(node *HierarchyNode, parent *models.FlowDiagram) {
+		if !included[node.Diagram.ID] {
+			included[node.Diagram.ID] = true
+			diagrams = append(diagrams, &node.Diagram)
+			parents[node.Diagram.ID] = parent
+		}
+		for _, child := range node.Children {
+			walk(child, &node.Diagram)
+		}
+	}
+	walk(tree, nil)
+
+	page := func(id string) string { return id + ".html" }
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, diagram := range diagrams {
+		view, err := FilterLayers(diagram, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.diagramService.Localize(view, lang)
+		if view, err = s.diagramService.withResolvedStyles(view); err != nil {
+			return nil, err
+		}
+
+		links := htmlLinks{DrillDown: make(map[string]string)}
+		if parent := parents[diagram.ID]; parent != nil {
+			links.Parent = page(parent.ID)
+			links.ParentName = parent.Name
+		}
+		for _, node := range view.Nodes {
+			if node.DrillDown != nil && included[*node.DrillDown] {
+				links.DrillDown[node.ID] = page(*node.DrillDown) + drillDownFragment(node.DrillDownFocus)
+			}
+		}
+
+		w, err := archive.Create(page(diagram.ID))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(renderHTML(view, links)); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := archive.Create("index.html")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<meta charset=\"utf-8\">\n<meta http-equiv=\"refresh\" content=\"0; url=%s\">\n<a href=\"%s\">%s</a>\n",
+		html.EscapeString(page(rootID)), html.EscapeString(page(rootID)), html.EscapeString(tree.Diagram.Name))
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Export{Data: buf.Bytes(), ContentType: "application/zip", Extension: "zip"}, nil
+}
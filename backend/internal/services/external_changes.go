@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The watcher remembers the content of every diagram file it has seen or
+// the API has written, so that a file whose content differs from what it
+// remembers was changed by something else: an editor, a Git pull or a
+// script. Writes by the API hold watchMu so that the watcher never reads a
+// file between the write and its being remembered.
+var (
+	watchMu         sync.Mutex
+	watchedFiles    = make(map[string]watchedFile)
+	externalChanges = make(map[string]time.Time) // By path; cleared when the API writes the file
+)
+
+type watchedFile struct {
+	modTime   time.Time
+	size      int64
+	checksum  [sha256.Size]byte
+	diagramID string
+}
+
+// writeDiagramFile writes a diagram file on behalf of the API
+func writeDiagramFile(path string, data []byte) error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	forgetIndexedDiagram(path)
+	entry := watchedFile{checksum: sha256.Sum256(data), diagramID: watchedFiles[path].diagramID}
+	if info, err := os.Stat(path); err == nil {
+		entry.modTime, entry.size = info.ModTime(), info.Size()
+	}
+	watchedFiles[path] = entry
+	delete(externalChanges, path)
+	return nil
+}
+
+// removeDiagramFile deletes a diagram file on behalf of the API
+func removeDiagramFile(path string) error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	forgetIndexedDiagram(path)
+	delete(watchedFiles, path)
+	delete(externalChanges, path)
+	return nil
+}
+
+// moveDiagramFile moves a diagram file on behalf of the API
+func moveDiagramFile(from, to string) error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	forgetIndexedDiagram(from)
+	forgetIndexedDiagram(to)
+	if entry, ok := watchedFiles[from]; ok {
+		watchedFiles[to] = entry
+	}
+	delete(watchedFiles, from)
+	delete(externalChanges, from)
+	return nil
+}
+
+// changedExternallyAt returns when the file at path was last seen changed
+// outside the API since the API last wrote it
+func changedExternallyAt(path string) *time.Time {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if at, ok := externalChanges[path]; ok {
+		return &at
+	}
+	return nil
+}
+
+// scanExternalChanges compares the diagram files with what the watcher
+// remembers. Changes are flagged and published unless this is the first
+// scan, which only takes stock.
+func (s *DiagramService) scanExternalChanges(report bool) error {
+	seen := make(map[string]bool)
+	err := s.walkDiagramPaths(func(path string) error {
+		seen[path] = true
+		s.checkDiagramFile(path, report)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan diagrams directory: %w", err)
+	}
+
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	for path, entry := range watchedFiles {
+		if seen[path] {
+			continue
+		}
+		delete(watchedFiles, path)
+		delete(externalChanges, path)
+		if report {
+			s.publishExternalChange(path, entry.diagramID, true)
+		}
+	}
+	return nil
+}
+
+func (s *DiagramService) checkDiagramFile(path string, report bool) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	entry, known := watchedFiles[path]
+	if known && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	checksum := sha256.Sum256(data)
+	changed := !known || entry.checksum != checksum
+	entry = watchedFile{modTime: info.ModTime(), size: info.Size(), checksum: checksum, diagramID: entry.diagramID}
+	if changed {
+		entry.diagramID = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".yaml"), ".yml")
+		if diagram, err := s.loadDiagramFromFile(path); err == nil && diagram.ID != "" {
+			entry.diagramID = diagram.ID
+		}
+	}
+	watchedFiles[path] = entry
+	if changed && report {
+		externalChanges[path] = time.Now().UTC()
+		s.publishExternalChange(path, entry.diagramID, false)
+	}
+}
+
+func (s *DiagramService) publishExternalChange(path, diagramID string, deleted bool) {
+	rel := s.relativeDiagramPath(path)
+	log.Printf("Diagram %s changed outside the API (%s)", diagramID, rel)
+	publishEvent(Event{
+		Type:      EventDiagramChangedExternally,
+		DiagramID: diagramID,
+		Path:      rel,
+		Deleted:   deleted,
+		Time:      time.Now().UTC(),
+	})
+}
+
+// StartExternalChangeWatch polls the diagrams path every
+// DIAGRAM_WATCH_INTERVAL for files changed outside the API, so that open
+// editors can offer to reload before overwriting them
+func StartExternalChangeWatch() {
+	s := NewDiagramService()
+	if s.cfg.DiagramWatchInterval <= 0 {
+		return
+	}
+	log.Printf("Watching %s for external changes every %s", s.cfg.DiagramsPath, s.cfg.DiagramWatchInterval)
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.DiagramWatchInterval)
+		defer ticker.Stop()
+		for report := false; ; report = true {
+			if err := s.scanExternalChanges(report); err != nil {
+				log.Printf("External change scan failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
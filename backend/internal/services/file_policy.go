@@ -0,0 +1,228 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Diagram ID naming policies
+const (
+	NamingAny   = "any"
+	NamingKebab = "kebab-case" // IDs of new diagrams must be kebab-case
+)
+
+// Folder conventions of the diagrams path
+const (
+	FoldersFlat   = "flat"   // <id>.yaml
+	FoldersTag    = "tag"    // <first tag>/<id>.yaml
+	FoldersParent = "parent" // <root>/.../<parent>/<id>.yaml, following primary parents
+)
+
+var kebabCaseID = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// FileMove is a diagram file moved, or to be moved, to the path the policy
+// gives it. Paths are relative to the diagrams path.
+type FileMove struct {
+	DiagramID string `json:"diagramId"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Error     string `json:"error,omitempty"`
+}
+
+// MisnamedDiagram is a diagram whose ID breaks the naming policy. IDs are
+// referenced by other diagrams, so they are reported rather than renamed.
+type MisnamedDiagram struct {
+	DiagramID string `json:"diagramId"`
+	Suggested string `json:"suggested"`
+}
+
+// Reorganization reports the files moved to follow the file policy
+type Reorganization struct {
+	Naming   string            `json:"naming"`
+	Folders  string            `json:"folders"`
+	DryRun   bool              `json:"dryRun"`
+	Moves    []FileMove        `json:"moves"`
+	Moved    int               `json:"moved"`
+	Failed   int               `json:"failed"`
+	Misnamed []MisnamedDiagram `json:"misnamed"`
+}
+
+// kebabCase turns an ID into kebab-case: words split at separators and at
+// lower-to-upper case changes, lowercased and joined by hyphens
+func kebabCase(id string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range id {
+		switch {
+		case unicode.IsUpper(r):
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				b.WriteByte('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case r < unicode.MaxASCII && (unicode.IsLower(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+		prev = r
+	}
+	words := strings.FieldsFunc(b.String(), func(r rune) bool { return r == '-' })
+	return strings.Join(words, "-")
+}
+
+// checkFileNaming refuses a new diagram whose ID breaks the naming policy
+func (s *DiagramService) checkFileNaming(diagram *models.FlowDiagram) error {
+	if s.cfg.DiagramNaming == NamingKebab && !kebabCaseID.MatchString(diagram.ID) {
+		return fmt.Errorf("%w: diagram ID %s is not kebab-case, e.g. %s", ErrInvalidDiagram, diagram.ID, kebabCase(diagram.ID))
+	}
+	return nil
+}
+
+// policyPath is the file a diagram belongs in under the folder policy.
+// parentOf returns the primary parent of a diagram ID, or "" for a root.
+// Archived diagrams keep the same layout below the archive.
+func (s *DiagramService) policyPath(diagram *models.FlowDiagram, parentOf func(string) string) string {
+	var folders []string
+	switch s.cfg.DiagramFolders {
+	case FoldersTag:
+		if len(diagram.Tags) > 0 {
+			folders = append(folders, folderName(diagram.Tags[0]))
+		}
+	case FoldersParent:
+		seen := map[string]bool{diagram.ID: true}
+		for parent := parentOf(diagram.ID); parent != "" && !seen[parent]; parent = parentOf(parent) {
+			seen[parent] = true
+			folders = append([]string{folderName(parent)}, folders...)
+		}
+	}
+
+	root := s.rootOf(diagram.FilePath).Path
+	if diagram.Archived {
+		root = s.archivePathOf(diagram.FilePath)
+	}
+	ext := ".yaml"
+	if strings.HasSuffix(diagram.FilePath, ".yml") {
+		ext = ".yml"
+	}
+	return filepath.Join(append(append([]string{root}, folders...), filepath.Base(diagram.ID)+ext)...)
+}
+
+// folderName makes a tag or ID safe as a single path element
+func folderName(name string) string {
+	if safe := strings.Trim(invalidIDChars.ReplaceAllString(name, "-"), "-"); safe != "" {
+		return safe
+	}
+	return "_"
+}
+
+// newDiagramPath is the file a diagram being created is stored in. It
+// fails when a diagram with the ID is already stored, so a create never
+// overwrites one.
+func (s *DiagramService) newDiagramPath(diagram *models.FlowDiagram) (string, error) {
+	path := s.policyPath(diagram, func(id string) string {
+		if id == diagram.ID {
+			return stringValue(diagram.Parent)
+		}
+		if parent, err := s.GetByID(id); err == nil {
+			return stringValue(parent.Parent)
+		}
+		return ""
+	})
+	if existing, err := s.GetByID(diagram.ID); err == nil {
+		return "", fmt.Errorf("%w: %s is stored in %s", ErrDiagramExists, diagram.ID, s.relativeDiagramPath(existing.FilePath))
+	}
+	return path, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Reorganize moves every diagram file to the path the folder policy gives
+// it and reports the IDs that break the naming policy. A move is skipped
+// when another file is in the way. With dryRun nothing is moved.
+func (s *DiagramService) Reorganize(dryRun bool) (*Reorganization, error) {
+	diagrams, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[string]string, len(diagrams))
+	for _, diagram := range diagrams {
+		parents[diagram.ID] = stringValue(diagram.Parent)
+	}
+	parentOf := func(id string) string { return parents[id] }
+	sort.Slice(diagrams, func(i, j int) bool { return diagrams[i].FilePath < diagrams[j].FilePath })
+
+	result := &Reorganization{
+		Naming:   s.cfg.DiagramNaming,
+		Folders:  s.cfg.DiagramFolders,
+		DryRun:   dryRun,
+		Moves:    []FileMove{},
+		Misnamed: []MisnamedDiagram{},
+	}
+	targets := make(map[string]string) // Target path to the diagram moved there
+	var paths []string
+	for i := range diagrams {
+		diagram := &diagrams[i]
+		if s.cfg.DiagramNaming == NamingKebab && !kebabCaseID.MatchString(diagram.ID) {
+			result.Misnamed = append(result.Misnamed, MisnamedDiagram{DiagramID: diagram.ID, Suggested: kebabCase(diagram.ID)})
+		}
+		target := s.policyPath(diagram, parentOf)
+		if target == diagram.FilePath || diagram.ReadOnly {
+			continue
+		}
+		from := diagram.FilePath
+		move := FileMove{DiagramID: diagram.ID, From: s.relativeDiagramPath(from), To: s.relativeDiagramPath(target)}
+		if other, taken := targets[target]; taken {
+			move.Error = fmt.Sprintf("%s also belongs in %s", other, move.To)
+		} else if _, err := os.Stat(target); err == nil {
+			move.Error = fmt.Sprintf("%s is taken by another file", move.To)
+		} else if !dryRun {
+			if err := s.moveDiagram(diagram, target); err != nil {
+				move.Error = err.Error()
+			} else {
+				s.removeEmptyFolders(filepath.Dir(from))
+				for _, path := range []string{from, target} {
+					if abs, err := filepath.Abs(path); err == nil {
+						paths = append(paths, abs)
+					}
+				}
+			}
+		}
+		targets[target] = diagram.ID
+		if move.Error != "" {
+			result.Failed++
+		} else {
+			result.Moved++
+		}
+		result.Moves = append(result.Moves, move)
+	}
+
+	if len(paths) > 0 {
+		message := fmt.Sprintf("Reorganize %d diagram files", result.Moved)
+		gitSyncAfterSave(s.cfg, message)
+		gitRecordChange(s.cfg, message, paths)
+	}
+	return result, nil
+}
+
+// removeEmptyFolders removes a folder left empty by a move, and its parents
+// up to its diagram root and the archive
+func (s *DiagramService) removeEmptyFolders(dir string) {
+	root, archive := s.rootOf(dir).Path, s.archivePathOf(dir)
+	for dir = filepath.Clean(dir); isBelow(root, dir) && dir != root && dir != archive; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
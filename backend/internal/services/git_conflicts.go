@@ -0,0 +1,305 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Sync conflict kinds
+const (
+	ConflictContent         = "content"          // Both sides changed or added the diagram differently
+	ConflictDeletedModified = "deleted-modified" // One side deleted the diagram, the other changed it
+	ConflictRenamed         = "renamed"          // One side renamed the file, the other changed or renamed it
+)
+
+// SyncConflict is a diagram that could not be merged on pull, with both
+// versions so that a resolution can be chosen for it
+type SyncConflict struct {
+	Path      string       `json:"path"` // Path in the merge base; the key to resolve the conflict by
+	Kind      string       `json:"kind"`
+	DiagramID string       `json:"diagramId,omitempty"`
+	Ours      ConflictSide `json:"ours"`
+	Theirs    ConflictSide `json:"theirs"`
+	Changes   *DiagramDiff `json:"changes,omitempty"` // What taking theirs changes compared to ours
+	files     []string     // Unmerged files of the conflict
+	stages    map[string]stageSet
+}
+
+// ConflictSide is the local or the remote version of a conflicting diagram
+type ConflictSide struct {
+	Path    string `json:"path,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	Nodes   int    `json:"nodes"`
+	Edges   int    `json:"edges"`
+	Error   string `json:"error,omitempty"` // Set when the version does not parse
+}
+
+// stageSet records which index stages an unmerged file has: the merge base
+// (1), ours (2) and theirs (3)
+type stageSet [4]bool
+
+// conflictReport describes the conflicts of the merge in progress against
+// target, grouping the unmerged files that stem from the same base file
+func (s *GitSyncService) conflictReport(target string) ([]SyncConflict, error) {
+	entries, err := s.lines("ls-files", "-u")
+	if err != nil {
+		return nil, err
+	}
+	stages := map[string]stageSet{}
+	for _, entry := range entries {
+		info, file, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		set := stages[file]
+		switch fields[2] {
+		case "1":
+			set[1] = true
+		case "2":
+			set[2] = true
+		case "3":
+			set[3] = true
+		}
+		stages[file] = set
+	}
+
+	base, err := s.git("merge-base", "HEAD", target)
+	if err != nil {
+		return nil, err
+	}
+	ours, err := s.fileChanges(base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := s.fileChanges(base, target)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*SyncConflict{}
+	for file, set := range stages {
+		origin := file
+		if renamed, ok := ours.renamedFrom[file]; ok {
+			origin = renamed
+		} else if renamed, ok := theirs.renamedFrom[file]; ok {
+			origin = renamed
+		}
+		conflict, ok := groups[origin]
+		if !ok {
+			conflict = &SyncConflict{Path: origin, stages: map[string]stageSet{}}
+			groups[origin] = conflict
+		}
+		conflict.files = append(conflict.files, file)
+		conflict.stages[file] = set
+	}
+
+	diagramService := &DiagramService{cfg: s.cfg}
+	report := make([]SyncConflict, 0, len(groups))
+	for origin, conflict := range groups {
+		sort.Strings(conflict.files)
+		oursDiagram := s.conflictSide(diagramService, &conflict.Ours, conflict, ours, origin, 2, "HEAD")
+		theirsDiagram := s.conflictSide(diagramService, &conflict.Theirs, conflict, theirs, origin, 3, target)
+
+		switch {
+		case ours.renamedTo[origin] != "" || theirs.renamedTo[origin] != "":
+			conflict.Kind = ConflictRenamed
+		case conflict.Ours.Deleted || conflict.Theirs.Deleted:
+			conflict.Kind = ConflictDeletedModified
+		default:
+			conflict.Kind = ConflictContent
+		}
+		if oursDiagram != nil {
+			conflict.DiagramID = oursDiagram.ID
+		} else if theirsDiagram != nil {
+			conflict.DiagramID = theirsDiagram.ID
+		}
+		if oursDiagram != nil && theirsDiagram != nil {
+			changes := DiffDiagrams(oursDiagram, theirsDiagram)
+			conflict.Changes = &changes
+		}
+		report = append(report, *conflict)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Path < report[j].Path })
+	return report, nil
+}
+
+// conflictSide fills in one side of a conflict from the commit rev and
+// returns its diagram when it parses
+func (s *GitSyncService) conflictSide(diagramService *DiagramService, side *ConflictSide, conflict *SyncConflict, changes fileChanges, origin string, stage int, rev string) *models.FlowDiagram {
+	side.Path = origin
+	if renamed := changes.renamedTo[origin]; renamed != "" {
+		side.Path = renamed
+	}
+	present := false
+	for _, file := range conflict.files {
+		if conflict.stages[file][stage] {
+			present = true
+		}
+	}
+	if !present || changes.deleted[origin] {
+		side.Path = ""
+		side.Deleted = true
+		return nil
+	}
+
+	content, err := s.git("show", rev+":./"+side.Path)
+	if err != nil {
+		side.Error = err.Error()
+		return nil
+	}
+	var diagram models.FlowDiagram
+	if err := diagramService.unmarshalDiagramYAML([]byte(content), &diagram); err != nil {
+		side.Error = err.Error()
+		return nil
+	}
+	side.Name = diagram.Name
+	side.Version = diagram.Version
+	side.Nodes = len(diagram.Nodes)
+	side.Edges = len(diagram.Edges)
+	return &diagram
+}
+
+// fileChanges are the files one side of a merge deleted or renamed since
+// the merge base
+type fileChanges struct {
+	deleted     map[string]bool
+	renamedTo   map[string]string // Base path to new path
+	renamedFrom map[string]string // New path to base path
+}
+
+func (s *GitSyncService) fileChanges(base, rev string) (fileChanges, error) {
+	changes := fileChanges{deleted: map[string]bool{}, renamedTo: map[string]string{}, renamedFrom: map[string]string{}}
+	entries, err := s.lines("diff", "--name-status", "-M", "--relative", base, rev)
+	if err != nil {
+		return changes, err
+	}
+	for _, entry := range entries {
+		fields := strings.Split(entry, "\t")
+		switch {
+		case strings.HasPrefix(fields[0], "R") && len(fields) == 3:
+			changes.renamedTo[fields[1]] = fields[2]
+			changes.renamedFrom[fields[2]] = fields[1]
+		case fields[0] == "D" && len(fields) == 2:
+			changes.deleted[fields[1]] = true
+		}
+	}
+	return changes, nil
+}
+
+// Resolve merges the remote commit of the last conflicting pull again and
+// settles each conflict with the chosen side, keyed by the conflict's
+// path: ours keeps the local version of its files, theirs takes the remote
+// one, deleting files that side does not have. Conflicts without a
+// resolution abort the merge and return ErrSyncConflict with the current
+// report, as do conflicts that appeared since the report was made.
+func (s *GitSyncService) Resolve(resolutions map[string]string) (*GitSyncStatus, error) {
+	for conflictPath, resolution := range resolutions {
+		if resolution != GitStrategyOurs && resolution != GitStrategyTheirs {
+			return nil, fmt.Errorf("%w: resolution for %s must be %s or %s", ErrInvalidOptions, conflictPath, GitStrategyOurs, GitStrategyTheirs)
+		}
+	}
+
+	gitMu.Lock()
+	defer gitMu.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	if gitConflictTarget == "" {
+		return nil, fmt.Errorf("%w: there are no conflicts to resolve; pull first", ErrInvalidOptions)
+	}
+	if err := s.resolve(resolutions); err != nil {
+		s.recordError(err)
+		return s.statusAfter(err)
+	}
+	return s.status()
+}
+
+func (s *GitSyncService) resolve(resolutions map[string]string) error {
+	if err := s.commitLocal("Save local diagram changes before sync"); err != nil {
+		return err
+	}
+	target := gitConflictTarget
+	if _, err := s.git("merge", "--no-edit", target); err == nil {
+		s.clearConflicts()
+		return nil
+	}
+
+	report, err := s.conflictReport(target)
+	if err != nil || len(report) == 0 {
+		s.git("merge", "--abort")
+		if err == nil {
+			err = fmt.Errorf("merge with %s failed without conflicts", target)
+		}
+		return err
+	}
+	var unresolved []string
+	for _, conflict := range report {
+		if resolutions[conflict.Path] == "" {
+			unresolved = append(unresolved, conflict.Path)
+		}
+	}
+	if len(unresolved) > 0 {
+		s.git("merge", "--abort")
+		s.recordConflicts(report, target)
+		return fmt.Errorf("%w: no resolution for %s", ErrSyncConflict, strings.Join(unresolved, ", "))
+	}
+
+	for _, conflict := range report {
+		stage := 2
+		if resolutions[conflict.Path] == GitStrategyTheirs {
+			stage = 3
+		}
+		for _, file := range conflict.files {
+			var err error
+			if conflict.stages[file][stage] {
+				if _, err = s.git("checkout", "--"+resolutions[conflict.Path], "--", file); err == nil {
+					_, err = s.git("add", "--", file)
+				}
+			} else {
+				_, err = s.git("rm", "-q", "-f", "--ignore-unmatch", "--", file)
+			}
+			if err != nil {
+				s.git("merge", "--abort")
+				return err
+			}
+		}
+	}
+	message := fmt.Sprintf("Merge %s, resolving %d conflicts", gitConflictRef, len(report))
+	if _, err := s.git("commit", "-m", message); err != nil {
+		s.git("merge", "--abort")
+		return err
+	}
+	s.clearConflicts()
+	return nil
+}
+
+// recordConflicts stores the conflicts of a failed merge for the status
+// and a later Resolve
+func (s *GitSyncService) recordConflicts(report []SyncConflict, target string) {
+	files := []string{}
+	for _, conflict := range report {
+		files = append(files, conflict.files...)
+	}
+	sort.Strings(files)
+	gitConflicts = files
+	gitConflictReport = report
+	gitConflictTarget = target
+	gitConflictRef, _ = s.git("rev-parse", "--short", target)
+}
+
+func (s *GitSyncService) clearConflicts() {
+	now := time.Now().UTC()
+	gitLastPull = &now
+	gitLastError = ""
+	gitConflicts = []string{}
+	gitConflictReport = []SyncConflict{}
+	gitConflictTarget = ""
+	gitConflictRef = ""
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+)
+
+// Git push modes
+const (
+	GitPushOff      = "off"      // Only pull
+	GitPushSchedule = "schedule" // Commit and push local changes on every scheduled sync
+	GitPushSave     = "save"     // Commit and push after every API save
+)
+
+// Merge strategies for resolving conflicts on pull
+const (
+	GitStrategyOurs   = "ours"   // Keep local changes in conflicting hunks
+	GitStrategyTheirs = "theirs" // Take remote changes in conflicting hunks
+)
+
+// GitSyncStatus describes the state of the diagrams repository
+type GitSyncStatus struct {
+	Remote         string         `json:"remote"`
+	Branch         string         `json:"branch"`
+	PushMode       string         `json:"pushMode"`
+	Interval       string         `json:"interval,omitempty"`
+	Ahead          int            `json:"ahead"`  // Local commits not on the remote
+	Behind         int            `json:"behind"` // Remote commits not merged locally
+	LocalChanges   []string       `json:"localChanges"`
+	Conflicts      []string       `json:"conflicts"` // Files that failed to merge on the last pull
+	ConflictReport []SyncConflict `json:"conflictReport"`
+	ConflictCommit string         `json:"conflictCommit,omitempty"`
+	LastPull       *time.Time     `json:"lastPull,omitempty"`
+	LastPush       *time.Time     `json:"lastPush,omitempty"`
+	LastError      string         `json:"lastError,omitempty"`
+}
+
+// Git operations are serialized across requests, the scheduler and save
+// hooks; the outcome of the last sync is shared by all of them
+var (
+	gitMu             sync.Mutex
+	gitConflicts      = []string{}
+	gitConflictReport = []SyncConflict{}
+	gitConflictRef    string
+	gitConflictTarget string // Full hash of the remote commit that conflicted
+	gitLastPull       *time.Time
+	gitLastPush       *time.Time
+	gitLastError      string
+)
+
+// GitSyncService keeps the diagrams directory in sync with a Git remote
+type GitSyncService struct {
+	cfg *config.Config
+}
+
+// NewGitSyncService creates a new Git sync service
+func NewGitSyncService() *GitSyncService {
+	return &GitSyncService{
+		cfg: config.Load(),
+	}
+}
+
+// StartGitSync runs the scheduled sync in the background when an interval
+// is configured: it pulls, then pushes local changes if the push mode is
+// schedule
+func StartGitSync() {
+	s := NewGitSyncService()
+	if s.cfg.GitInterval <= 0 {
+		return
+	}
+	if err := s.ready(); err != nil {
+		log.Printf("Git sync disabled: %v", err)
+		return
+	}
+	log.Printf("Git sync every %s with %s (push: %s)", s.cfg.GitInterval, s.cfg.GitRemote, s.cfg.GitPush)
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.GitInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			_, err := s.Pull("")
+			if err == nil && s.cfg.GitPush == GitPushSchedule {
+				_, err = s.Push()
+			}
+			if err != nil {
+				log.Printf("Scheduled Git sync failed: %v", err)
+			}
+		}
+	}()
+}
+
+// gitSyncAfterSave commits and pushes a change made through the API when
+// the push mode is save. It runs in the background so saves do not wait on
+// the remote.
+func gitSyncAfterSave(cfg *config.Config, message string) {
+	if cfg.GitPush != GitPushSave {
+		return
+	}
+	go func() {
+		s := &GitSyncService{cfg: cfg}
+		if err := s.ready(); err != nil {
+			return
+		}
+		if _, err := s.push(message); err != nil {
+			log.Printf("Git push after save failed: %v", err)
+		}
+	}()
+}
+
+// gitRecordChange commits the given diagram files with a message
+// describing the change, so changes made in bulk show up in the history of
+// each diagram they touched. With the save push mode the saves already
+// committed and pushed them; otherwise the commit stays local until the
+// next sync. Nothing is recorded when the path is not a Git work tree.
+func gitRecordChange(cfg *config.Config, message string, paths []string) {
+	if cfg.GitPush == GitPushSave || len(paths) == 0 {
+		return
+	}
+	s := &GitSyncService{cfg: cfg}
+	if _, err := s.git("rev-parse", "--is-inside-work-tree"); err != nil {
+		return
+	}
+	gitMu.Lock()
+	defer gitMu.Unlock()
+	if _, err := s.git(append([]string{"add", "--"}, paths...)...); err != nil {
+		log.Printf("Git commit of %q failed: %v", message, err)
+		return
+	}
+	if _, err := s.git(append([]string{"commit", "-m", message, "--"}, paths...)...); err != nil {
+		log.Printf("Git commit of %q failed: %v", message, err)
+	}
+}
+
+// Status reports how the diagrams repository relates to the remote
+func (s *GitSyncService) Status() (*GitSyncStatus, error) {
+	gitMu.Lock()
+	defer gitMu.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	return s.status()
+}
+
+// Pull fetches the remote and merges it. Local uncommitted changes are
+// committed first so they take part in the merge. On conflict the merge is
+// aborted, the conflicting files and a report of the conflicting diagrams
+// are recorded in the status and ErrSyncConflict is returned; Resolve then
+// settles each conflict with a chosen side, while pulling again with the
+// ours or theirs strategy resolves all conflicting hunks in one direction.
+func (s *GitSyncService) Pull(strategy string) (*GitSyncStatus, error) {
+	if strategy != "" && strategy != GitStrategyOurs && strategy != GitStrategyTheirs {
+		return nil, fmt.Errorf("%w: strategy must be %s or %s", ErrInvalidOptions, GitStrategyOurs, GitStrategyTheirs)
+	}
+
+	gitMu.Lock()
+	defer gitMu.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	if err := s.pull(strategy); err != nil {
+		s.recordError(err)
+		return s.statusAfter(err)
+	}
+	return s.status()
+}
+
+// Push commits local changes and pushes them, merging the remote first if
+// the push is rejected
+func (s *GitSyncService) Push() (*GitSyncStatus, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	return s.push("Update diagrams")
+}
+
+func (s *GitSyncService) push(message string) (*GitSyncStatus, error) {
+	gitMu.Lock()
+	defer gitMu.Unlock()
+
+	if err := s.commitLocal(message); err != nil {
+		s.recordError(err)
+		return s.statusAfter(err)
+	}
+	branch, err := s.branch()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.git("push", s.cfg.GitRemote, "HEAD:refs/heads/"+branch); err != nil {
+		// Usually rejected because the remote moved on
+		if err := s.pull(""); err != nil {
+			s.recordError(err)
+			return s.statusAfter(err)
+		}
+		if _, err := s.git("push", s.cfg.GitRemote, "HEAD:refs/heads/"+branch); err != nil {
+			s.recordError(err)
+			return s.statusAfter(err)
+		}
+	}
+	now := time.Now().UTC()
+	gitLastPush = &now
+	gitLastError = ""
+	return s.status()
+}
+
+func (s *GitSyncService) pull(strategy string) error {
+	branch, err := s.branch()
+	if err != nil {
+		return err
+	}
+	if _, err := s.git("fetch", s.cfg.GitRemote, branch); err != nil {
+		return err
+	}
+	if err := s.commitLocal("Save local diagram changes before sync"); err != nil {
+		return err
+	}
+
+	args := []string{"merge", "--no-edit"}
+	if strategy != "" {
+		args = append(args, "-X", strategy)
+	}
+	remoteRef := s.cfg.GitRemote + "/" + branch
+	if _, err := s.git(append(args, remoteRef)...); err != nil {
+		conflicts, _ := s.lines("diff", "--name-only", "--diff-filter=U")
+		if len(conflicts) == 0 {
+			return err
+		}
+		target, _ := s.git("rev-parse", remoteRef)
+		report, reportErr := s.conflictReport(target)
+		s.git("merge", "--abort")
+		if reportErr != nil {
+			return reportErr
+		}
+		s.recordConflicts(report, target)
+		return fmt.Errorf("%w: %d files conflict with %s", ErrSyncConflict, len(conflicts), remoteRef)
+	}
+
+	s.clearConflicts()
+	return nil
+}
+
+// commitLocal commits pending changes under the diagrams path
+func (s *GitSyncService) commitLocal(message string) error {
+	if _, err := s.git("add", "-A", "--", "."); err != nil {
+		return err
+	}
+	if _, err := s.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	_, err := s.git("commit", "-m", message)
+	return err
+}
+
+func (s *GitSyncService) status() (*GitSyncStatus, error) {
+	branch, err := s.branch()
+	if err != nil {
+		return nil, err
+	}
+	status := &GitSyncStatus{
+		Remote:         s.cfg.GitRemote,
+		Branch:         branch,
+		PushMode:       s.cfg.GitPush,
+		LocalChanges:   []string{},
+		Conflicts:      gitConflicts,
+		ConflictReport: gitConflictReport,
+		ConflictCommit: gitConflictRef,
+		LastPull:       gitLastPull,
+		LastPush:       gitLastPush,
+		LastError:      gitLastError,
+	}
+	if s.cfg.GitInterval > 0 {
+		status.Interval = s.cfg.GitInterval.String()
+	}
+
+	if counts, err := s.git("rev-list", "--left-right", "--count", "HEAD..."+s.cfg.GitRemote+"/"+branch); err == nil {
+		if fields := strings.Fields(counts); len(fields) == 2 {
+			status.Ahead, _ = strconv.Atoi(fields[0])
+			status.Behind, _ = strconv.Atoi(fields[1])
+		}
+	}
+	if changes, err := s.lines("status", "--porcelain", "--", "."); err == nil {
+		for _, change := range changes {
+			if len(change) > 3 {
+				status.LocalChanges = append(status.LocalChanges, change[3:])
+			}
+		}
+	}
+	return status, nil
+}
+
+// statusAfter returns the status alongside the error of a failed sync so
+// callers can show what conflicted
+func (s *GitSyncService) statusAfter(err error) (*GitSyncStatus, error) {
+	status, statusErr := s.status()
+	if statusErr != nil {
+		return nil, err
+	}
+	return status, err
+}
+
+func (s *GitSyncService) recordError(err error) {
+	gitLastError = err.Error()
+}
+
+// ready checks that the diagrams path is a Git work tree with the remote
+func (s *GitSyncService) ready() error {
+	if _, err := os.Stat(s.cfg.DiagramsPath); err != nil {
+		return fmt.Errorf("%w: diagrams path %s does not exist", ErrNotConfigured, s.cfg.DiagramsPath)
+	}
+	if _, err := s.git("rev-parse", "--is-inside-work-tree"); err != nil {
+		return fmt.Errorf("%w: diagrams path %s is not a Git work tree", ErrNotConfigured, s.cfg.DiagramsPath)
+	}
+	if _, err := s.git("remote", "get-url", s.cfg.GitRemote); err != nil {
+		return fmt.Errorf("%w: Git remote %s is not set up (GIT_SYNC_REMOTE)", ErrNotConfigured, s.cfg.GitRemote)
+	}
+	return nil
+}
+
+func (s *GitSyncService) branch() (string, error) {
+	if s.cfg.GitBranch != "" {
+		return s.cfg.GitBranch, nil
+	}
+	branch, err := s.git("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if branch == "HEAD" {
+		return "", fmt.Errorf("%w: detached HEAD; set GIT_SYNC_BRANCH", ErrNotConfigured)
+	}
+	return branch, nil
+}
+
+// git runs a Git command in the diagrams path, committing as the
+// configured author
+func (s *GitSyncService) git(args ...string) (string, error) {
+	return s.gitWith(nil, nil, args...)
+}
+
+// gitWith runs a Git command with extra environment variables and input
+func (s *GitSyncService) gitWith(env []string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", s.cfg.DiagramsPath}, args...)...)
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if author, err := mail.ParseAddress(s.cfg.GitAuthor); err == nil {
+		name := author.Name
+		if name == "" {
+			name = author.Address
+		}
+		cmd.Env = append(cmd.Env,
+			"GIT_AUTHOR_NAME="+name, "GIT_AUTHOR_EMAIL="+author.Address,
+			"GIT_COMMITTER_NAME="+name, "GIT_COMMITTER_EMAIL="+author.Address)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+func (s *GitSyncService) lines(args ...string) ([]string, error) {
+	out, err := s.git(args...)
+	if err != nil || out == "" {
+		return []string{}, err
+	}
+	return strings.Split(out, "\n"), nil
+}
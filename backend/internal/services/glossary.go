@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+var (
+	ErrTermNotFound = errors.New("glossary term not found")
+	ErrTermExists   = errors.New("glossary term already exists")
+)
+
+// glossaryMu serializes read-modify-write cycles on the glossary file
+var glossaryMu sync.Mutex
+
+// glossaryTermID is the pattern term IDs must match
+var glossaryTermID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+var wordChar = regexp.MustCompile(`\w`)
+
+// GlossaryService stores the glossary in the YAML file at GLOSSARY_PATH and
+// finds its terms in diagram descriptions
+type GlossaryService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+}
+
+// NewGlossaryService creates a new glossary service
+func NewGlossaryService() *GlossaryService {
+	return &GlossaryService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+	}
+}
+
+// List returns all terms sorted by term
+func (s *GlossaryService) List() ([]models.GlossaryTerm, error) {
+	glossary, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return glossary.Terms, nil
+}
+
+// Get returns one term
+func (s *GlossaryService) Get(id string) (*models.GlossaryTerm, error) {
+	glossary, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range glossary.Terms {
+		if glossary.Terms[i].ID == id {
+			return &glossary.Terms[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTermNotFound, id)
+}
+
+// Create adds a term. Without an ID one is derived from the term.
+func (s *GlossaryService) Create(term models.GlossaryTerm) (*models.GlossaryTerm, error) {
+	if term.ID == "" {
+		term.ID = strings.ToLower(strings.Trim(invalidIDChars.ReplaceAllString(term.Term, "-"), "-"))
+	}
+	if err := checkGlossaryTerm(&term); err != nil {
+		return nil, err
+	}
+	err := s.update(func(glossary *models.Glossary) error {
+		for _, existing := range glossary.Terms {
+			if existing.ID == term.ID {
+				return fmt.Errorf("%w: %s", ErrTermExists, term.ID)
+			}
+		}
+		glossary.Terms = append(glossary.Terms, term)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+// Update replaces a term
+func (s *GlossaryService) Update(id string, term models.GlossaryTerm) (*models.GlossaryTerm, error) {
+	term.ID = id
+	if err := checkGlossaryTerm(&term); err != nil {
+		return nil, err
+	}
+	err := s.update(func(glossary *models.Glossary) error {
+		for i := range glossary.Terms {
+			if glossary.Terms[i].ID == id {
+				glossary.Terms[i] = term
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrTermNotFound, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+// Delete removes a term
+func (s *GlossaryService) Delete(id string) error {
+	return s.update(func(glossary *models.Glossary) error {
+		for i := range glossary.Terms {
+			if glossary.Terms[i].ID == id {
+				glossary.Terms = append(glossary.Terms[:i], glossary.Terms[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrTermNotFound, id)
+	})
+}
+
+// checkGlossaryTerm validates a term and normalizes it for storage
+func checkGlossaryTerm(term *models.GlossaryTerm) error {
+	if !glossaryTermID.MatchString(term.ID) {
+		return fmt.Errorf("%w: invalid term ID %q", ErrInvalidOptions, term.ID)
+	}
+	term.Term = strings.TrimSpace(term.Term)
+	term.Definition = strings.TrimSpace(term.Definition)
+	if term.Term == "" || term.Definition == "" {
+		return fmt.Errorf("%w: term and definition are required", ErrInvalidOptions)
+	}
+	aliases := term.Aliases[:0]
+	for _, alias := range term.Aliases {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	term.Aliases = aliases
+	term.Updated = time.Now()
+	return nil
+}
+
+// Annotate finds glossary terms in the descriptions of a diagram and its
+// nodes, in the given language when translations exist, so the UI can show
+// their definitions on hover. It returns the annotations along with the
+// terms they refer to.
+func (s *GlossaryService) Annotate(diagramID, lang string) ([]models.TermAnnotation, []models.GlossaryTerm, error) {
+	diagram, err := s.diagramService.GetByID(diagramID)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.diagramService.Localize(diagram, lang)
+	glossary, err := s.load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matcher, termOf := glossaryMatcher(glossary.Terms)
+	annotations := []models.TermAnnotation{}
+	used := make(map[string]bool)
+	annotate := func(path, nodeID string, text *string) {
+		if matcher == nil || text == nil {
+			return
+		}
+		for _, match := range matcher.FindAllStringIndex(*text, -1) {
+			found := (*text)[match[0]:match[1]]
+			id, ok := termOf[strings.ToLower(found)]
+			if !ok {
+				continue
+			}
+			used[id] = true
+			annotations = append(annotations, models.TermAnnotation{
+				Path:   path,
+				NodeID: nodeID,
+				TermID: id,
+				Text:   found,
+				Start:  utf16Len((*text)[:match[0]]),
+				End:    utf16Len((*text)[:match[1]]),
+			})
+		}
+	}
+	annotate("description", "", diagram.Description)
+	for i, node := range diagram.Nodes {
+		annotate(fmt.Sprintf("nodes[%d].description", i), node.ID, node.Description)
+	}
+
+	terms := []models.GlossaryTerm{}
+	for _, term := range glossary.Terms {
+		if used[term.ID] {
+			terms = append(terms, term)
+		}
+	}
+	return annotations, terms, nil
+}
+
+// glossaryMatcher builds a case-insensitive, whole-word pattern matching
+// every term and alias, longest first so "order line" wins over "order".
+// It also returns the term ID for each lower-cased spelling.
+func glossaryMatcher(terms []models.GlossaryTerm) (*regexp.Regexp, map[string]string) {
+	termOf := make(map[string]string)
+	for _, term := range terms {
+		for _, spelling := range append([]string{term.Term}, term.Aliases...) {
+			if _, ok := termOf[strings.ToLower(spelling)]; !ok {
+				termOf[strings.ToLower(spelling)] = term.ID
+			}
+		}
+	}
+	return termPattern(sortedKeys(termOf)), termOf
+}
+
+// termPattern builds a case-insensitive, whole-word pattern matching any of
+// the terms, longest first. It returns nil when there are no terms.
+func termPattern(terms []string) *regexp.Regexp {
+	var alternatives []string
+	for _, term := range terms {
+		if term != "" {
+			alternatives = append(alternatives, term)
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil
+	}
+	sort.SliceStable(alternatives, func(i, j int) bool { return len(alternatives[i]) > len(alternatives[j]) })
+	for i, term := range alternatives {
+		alternatives[i] = wordBoundary(term[:1]) + regexp.QuoteMeta(term) + wordBoundary(term[len(term)-1:])
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+}
+
+// wordBoundary returns \b when the term starts or ends with a word
+// character, so "API" does not match inside "APIs" but ".NET" still matches
+func wordBoundary(edge string) string {
+	if wordChar.MatchString(edge) {
+		return `\b`
+	}
+	return ""
+}
+
+// utf16Len returns the length of text in UTF-16 code units
+func utf16Len(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+func (s *GlossaryService) load() (*models.Glossary, error) {
+	glossary := &models.Glossary{Terms: []models.GlossaryTerm{}}
+	data, err := os.ReadFile(s.cfg.GlossaryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return glossary, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read glossary: %w", err)
+	}
+	if err := yaml.Unmarshal(data, glossary); err != nil {
+		return nil, fmt.Errorf("failed to parse glossary: %w", err)
+	}
+	if glossary.Terms == nil {
+		glossary.Terms = []models.GlossaryTerm{}
+	}
+	sort.Slice(glossary.Terms, func(i, j int) bool {
+		return strings.ToLower(glossary.Terms[i].Term) < strings.ToLower(glossary.Terms[j].Term)
+	})
+	return glossary, nil
+}
+
+// update applies a change to the stored glossary
+func (s *GlossaryService) update(change func(*models.Glossary) error) error {
+	glossaryMu.Lock()
+	defer glossaryMu.Unlock()
+	glossary, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := change(glossary); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(glossary)
+	if err != nil {
+		return fmt.Errorf("failed to encode glossary: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cfg.GlossaryPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create glossary directory: %w", err)
+	}
+	if err := os.WriteFile(s.cfg.GlossaryPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write glossary: %w", err)
+	}
+	return nil
+}
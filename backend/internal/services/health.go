@@ -0,0 +1,408 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Health statuses
+const (
+	HealthUp       = "up"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+	HealthUnknown  = "unknown"
+)
+
+// healthSlowThreshold marks a responding endpoint as degraded
+const healthSlowThreshold = 2 * time.Second
+
+// In-cluster service account files
+const (
+	serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCA    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// HealthStatus is the live status of one node
+type HealthStatus struct {
+	Status    string    `json:"status"` // up, degraded, down or unknown
+	Target    string    `json:"target"`
+	Message   string    `json:"message,omitempty"`
+	LatencyMs *float64  `json:"latencyMs,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// HealthOverlay is the live status board of a diagram
+type HealthOverlay struct {
+	DiagramID string                  `json:"diagramId"`
+	Nodes     map[string]HealthStatus `json:"nodes"`
+	Summary   map[string]int          `json:"summary"` // Node count per status
+}
+
+// Check results are cached per target and shared by all requests so that
+// dashboards polling the overlay do not hammer the checked services
+var (
+	healthMu    sync.Mutex
+	healthCache = make(map[string]HealthStatus)
+)
+
+// The in-cluster client trusts the service account CA. It is built once,
+// so that its connections to the API server are reused across checks.
+var (
+	inClusterClientOnce sync.Once
+	inClusterClient     *http.Client
+)
+
+// HealthService aggregates node health from HTTP endpoints and Kubernetes
+type HealthService struct {
+	cfg            *config.Config
+	client         *http.Client
+	diagramService *DiagramService
+}
+
+// NewHealthService creates a new health service
+func NewHealthService() *HealthService {
+	cfg := config.Load()
+	return &HealthService{
+		cfg:            cfg,
+		client:         newHealthClient(cfg.HealthHosts),
+		diagramService: NewDiagramService(),
+	}
+}
+
+// errHealthTargetRefused marks health URLs the server will not contact
+var errHealthTargetRefused = errors.New("health target refused")
+
+// newHealthClient builds the client for health URLs, which come from
+// diagram authors. With an allowlist only the listed hosts are contacted,
+// redirects included; without one every dialed address must be public, so
+// that neither a hostname nor a redirect reaches internal services.
+func newHealthClient(allowed []string) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if len(allowed) == 0 {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if err := healthHostAllowed(allowed, req.URL); err != nil {
+				return fmt.Errorf("%w: %v", errHealthTargetRefused, err)
+			}
+			return nil
+		},
+	}
+}
+
+// healthHostAllowed reports whether a URL's host is in the allowlist.
+// Entries starting with "*." also match every subdomain.
+func healthHostAllowed(allowed []string, target *url.URL) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	host := strings.ToLower(target.Hostname())
+	for _, entry := range allowed {
+		entry = strings.ToLower(entry)
+		if host == entry || (strings.HasPrefix(entry, "*.") && strings.HasSuffix(host, entry[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not in HEALTH_ALLOWED_HOSTS", host)
+}
+
+// refusePrivateAddress runs after name resolution, so it also catches
+// public names resolving to loopback, private or link-local addresses
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s is not a public address; list internal hosts in HEALTH_ALLOWED_HOSTS", errHealthTargetRefused, host)
+	}
+	return nil
+}
+
+// Overlay checks every node with a health integration and returns the
+// per-node status. Results younger than the configured TTL are reused.
+func (s *HealthService) Overlay(diagramID string) (*HealthOverlay, error) {
+	diagram, err := s.diagramService.GetByID(diagramID)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay := &HealthOverlay{
+		DiagramID: diagram.ID,
+		Nodes:     make(map[string]HealthStatus),
+		Summary:   map[string]int{HealthUp: 0, HealthDegraded: 0, HealthDown: 0, HealthUnknown: 0},
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, node := range diagram.Nodes {
+		if node.Integrations == nil || node.Integrations.Health == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(nodeID string, target *models.HealthIntegration) {
+			defer wg.Done()
+			status := s.check(target)
+			mu.Lock()
+			overlay.Nodes[nodeID] = status
+			overlay.Summary[status.Status]++
+			mu.Unlock()
+		}(node.ID, node.Integrations.Health)
+	}
+	wg.Wait()
+
+	return overlay, nil
+}
+
+// check returns the cached status of a target or checks it afresh
+func (s *HealthService) check(target *models.HealthIntegration) HealthStatus {
+	key := healthTargetKey(target)
+
+	healthMu.Lock()
+	cached, ok := healthCache[key]
+	healthMu.Unlock()
+	if ok && time.Since(cached.CheckedAt) < s.cfg.HealthTTL {
+		return cached
+	}
+
+	var status HealthStatus
+	switch {
+	case target.URL != nil && *target.URL != "":
+		status = s.checkURL(*target.URL)
+	case target.Kubernetes != nil:
+		status = s.checkDeployment(target.Kubernetes)
+	default:
+		status = HealthStatus{Status: HealthUnknown, Message: "no health target configured"}
+	}
+	status.Target = key
+	status.CheckedAt = time.Now().UTC()
+
+	healthMu.Lock()
+	// Targets removed from diagrams would otherwise stay cached forever
+	for cachedKey, entry := range healthCache {
+		if time.Since(entry.CheckedAt) >= s.cfg.HealthTTL {
+			delete(healthCache, cachedKey)
+		}
+	}
+	healthCache[key] = status
+	healthMu.Unlock()
+	return status
+}
+
+func healthTargetKey(target *models.HealthIntegration) string {
+	if target.URL != nil && *target.URL != "" {
+		return *target.URL
+	}
+	if k := target.Kubernetes; k != nil {
+		return "k8s:" + kubernetesNamespace(k) + "/" + k.Deployment
+	}
+	return ""
+}
+
+func kubernetesNamespace(target *models.KubernetesTarget) string {
+	if target.Namespace == "" {
+		return "default"
+	}
+	return target.Namespace
+}
+
+// checkURL treats 2xx responses as up unless the body reports a degraded
+// state or the endpoint is slow, and anything else as down
+func (s *HealthService) checkURL(endpoint string) HealthStatus {
+	target, err := url.Parse(endpoint)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return HealthStatus{Status: HealthUnknown, Message: "health URL must be http or https"}
+	}
+	if err := healthHostAllowed(s.cfg.HealthHosts, target); err != nil {
+		return HealthStatus{Status: HealthUnknown, Message: err.Error()}
+	}
+	started := time.Now()
+	resp, err := s.client.Get(endpoint)
+	if errors.Is(err, errHealthTargetRefused) {
+		return HealthStatus{Status: HealthUnknown, Message: err.Error()}
+	}
+	if err != nil {
+		return HealthStatus{Status: HealthDown, Message: err.Error()}
+	}
+	defer resp.Body.Close()
+	latency := float64(time.Since(started).Microseconds()) / 1000
+	status := HealthStatus{LatencyMs: &latency}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		status.Status = HealthDown
+		status.Message = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return status
+	}
+
+	status.Status = HealthUp
+	var body struct {
+		Status string `json:"status"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) == nil {
+		switch strings.ToLower(body.Status) {
+		case "degraded", "warn", "warning", "partial":
+			status.Status = HealthDegraded
+			status.Message = "endpoint reports " + body.Status
+		case "down", "fail", "error", "unhealthy":
+			status.Status = HealthDown
+			status.Message = "endpoint reports " + body.Status
+		}
+	}
+	if status.Status == HealthUp && time.Since(started) > healthSlowThreshold {
+		status.Status = HealthDegraded
+		status.Message = "slow response"
+	}
+	return status
+}
+
+// checkDeployment compares ready and desired replicas: all ready is up,
+// some ready is degraded and none ready is down
+func (s *HealthService) checkDeployment(target *models.KubernetesTarget) HealthStatus {
+	client, apiURL, token, err := s.kubernetesClient()
+	if err != nil {
+		return HealthStatus{Status: HealthUnknown, Message: err.Error()}
+	}
+
+	endpoint := fmt.Sprintf("%s/apis/apps/v1/namespaces/%s/deployments/%s",
+		strings.TrimRight(apiURL, "/"), url.PathEscape(kubernetesNamespace(target)), url.PathEscape(target.Deployment))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return HealthStatus{Status: HealthUnknown, Message: err.Error()}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return HealthStatus{Status: HealthUnknown, Message: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return HealthStatus{Status: HealthDown, Message: "deployment not found"}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return HealthStatus{Status: HealthUnknown, Message: fmt.Sprintf("Kubernetes API returned HTTP %d", resp.StatusCode)}
+	}
+
+	var deployment struct {
+		Spec struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			ReadyReplicas int `json:"readyReplicas"`
+		} `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&deployment); err != nil {
+		return HealthStatus{Status: HealthUnknown, Message: "invalid Kubernetes response"}
+	}
+
+	desired := 1
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	ready := deployment.Status.ReadyReplicas
+	status := HealthStatus{Message: fmt.Sprintf("%d/%d replicas ready", ready, desired)}
+	switch {
+	case desired == 0:
+		status.Status = HealthDown
+		status.Message = "scaled to zero"
+	case ready >= desired:
+		status.Status = HealthUp
+	case ready > 0:
+		status.Status = HealthDegraded
+	default:
+		status.Status = HealthDown
+	}
+	return status
+}
+
+// kubernetesClient returns the API server URL and credentials, falling back
+// to the in-cluster service account when none are configured
+func (s *HealthService) kubernetesClient() (*http.Client, string, string, error) {
+	apiURL, token := s.cfg.K8sAPIURL, s.cfg.K8sToken
+	inCluster := apiURL == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+	if inCluster {
+		apiURL = "https://" + net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+	}
+	if apiURL == "" {
+		return nil, "", "", fmt.Errorf("%w: set KUBERNETES_API_URL", ErrNotConfigured)
+	}
+	if token == "" {
+		if data, err := os.ReadFile(serviceAccountToken); err == nil {
+			token = strings.TrimSpace(string(data))
+		}
+	}
+
+	// The API server is configured by the operator, so unlike health URLs
+	// it may live at a private address
+	client := &http.Client{Timeout: s.client.Timeout}
+	if inCluster {
+		inClusterClientOnce.Do(func() {
+			if ca, err := os.ReadFile(serviceAccountCA); err == nil {
+				pool := x509.NewCertPool()
+				pool.AppendCertsFromPEM(ca)
+				inClusterClient = &http.Client{
+					Timeout:   s.client.Timeout,
+					Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+				}
+			}
+		})
+		if inClusterClient != nil {
+			client = inClusterClient
+		}
+	}
+	return client, apiURL, token, nil
+}
+
+// validateHealth checks the health integration of a node
+func validateHealth(result *models.ValidationResult, path string, integrations *models.Integrations) {
+	if integrations == nil || integrations.Health == nil {
+		return
+	}
+	health := integrations.Health
+	if health.URL != nil {
+		if u, err := url.Parse(*health.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".integrations.health.url",
+				Message: fmt.Sprintf("Health URL must be an http(s) URL: %s", *health.URL),
+				Code:    "INVALID_HEALTH_URL",
+				Value:   *health.URL,
+			})
+		}
+	}
+	if health.Kubernetes != nil && health.Kubernetes.Deployment == "" {
+		result.Errors = append(result.Errors, models.ValidationError{
+			Path:    path + ".integrations.health.kubernetes.deployment",
+			Message: "Kubernetes deployment name is required",
+			Code:    "MISSING_DEPLOYMENT",
+		})
+	}
+}
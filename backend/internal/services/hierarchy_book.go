@@ -0,0 +1,303 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+const (
+	bookTOCRowHeight = 16.0
+	bookRefRowHeight = 12.0
+	bookIndent       = 14.0
+)
+
+// bookSection is one diagram of a hierarchy book
+type bookSection struct {
+	diagram *models.FlowDiagram
+	number  string // Section number in hierarchy order, e.g. 1.2.1
+	depth   int
+	parent  int // Index of the parent section, -1 for the root
+	page    int // Index of the section's page in the document
+}
+
+// bookRef is a page reference printed at the foot of a diagram page
+type bookRef struct {
+	text string
+	page int // -1 for a label without a link
+}
+
+// HierarchyBook renders a diagram and all its descendants as one PDF: a
+// cover page with the hierarchy's metadata, a table of contents and one
+// page per diagram in hierarchy order. Each page references its parent and
+// children by page number, and drill-down nodes are marked with the page of
+// the diagram they open.
+func (s *ExportService) HierarchyBook(rootID, lang string, opts PDFOptions) ([]byte, error) {
+	pageW, pageH, err := paperSize(opts.Paper, opts.Landscape)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := NewHierarchyService().GetHierarchyTree(rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []*bookSection
+	var walk func(node *HierarchyNode, number string, depth, parent int) error
+	walk = func(node *HierarchyNode, number string, depth, parent int) error {
+		view, err := FilterLayers(&node.Diagram, nil)
+		if err != nil {
+			return err
+		}
+		s.diagramService.Localize(view, lang)
+		if view, err = s.diagramService.withResolvedStyles(view); err != nil {
+			return err
+		}
+		index := len(sections)
+		sections = append(sections, &bookSection{diagram: view, number: number, depth: depth, parent: parent})
+		for i, child := range node.Children {
+			if err := walk(child, fmt.Sprintf("%s.%d", number, i+1), depth+1, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(tree, "1", 0, -1); err != nil {
+		return nil, err
+	}
+
+	// Page numbers are known up front so the contents and cross-references
+	// can be written as the pages are drawn
+	rowsPerPage := max(1, int((pageH-2*pdfMargin-pdfHeaderHeight-pdfFooterHeight-8)/bookTOCRowHeight))
+	tocPages := roundUp(float64(len(sections)) / float64(rowsPerPage))
+	pageOf := make(map[string]int)
+	for i, section := range sections {
+		section.page = 1 + tocPages + i
+		if _, ok := pageOf[section.diagram.ID]; !ok {
+			pageOf[section.diagram.ID] = section.page
+		}
+	}
+	total := 1 + tocPages + len(sections)
+
+	doc := newPDFDocument(tree.Diagram.Name)
+	cover := doc.AddPage(pageW, pageH)
+	drawBookCover(cover, sections, pageW, pageH)
+	drawBookPageNumber(cover, 0, total)
+
+	for p := 0; p < tocPages; p++ {
+		canvas := doc.AddPage(pageW, pageH)
+		subtitle := ""
+		if tocPages > 1 {
+			subtitle = fmt.Sprintf("%d of %d", p+1, tocPages)
+		}
+		drawPDFTitle(canvas, pageW, "Contents", subtitle)
+		end := min(len(sections), (p+1)*rowsPerPage)
+		for i, section := range sections[p*rowsPerPage : end] {
+			drawBookTOCRow(canvas, section, pdfMargin+pdfHeaderHeight+8+float64(i+1)*bookTOCRowHeight, pageW)
+		}
+		drawBookPageNumber(canvas, 1+p, total)
+	}
+
+	for _, section := range sections {
+		canvas := doc.AddPage(pageW, pageH)
+		drawBookSection(canvas, section, sections, pageOf, pageW, pageH)
+		drawBookPageNumber(canvas, section.page, total)
+	}
+
+	return doc.Bytes(), nil
+}
+
+// drawBookCover writes the title page with the metadata auditors ask for:
+// what the book covers, who owns it and how current it is
+func drawBookCover(c *pdfCanvas, sections []*bookSection, pageW, pageH float64) {
+	root := sections[0].diagram
+	top := pageH / 4
+
+	c.SetFill("#222222")
+	c.Text(pdfMargin, top, 26, true, "left", root.Name)
+	c.SetFill("#555555")
+	c.Text(pdfMargin, top+24, 14, false, "left", "Process hierarchy")
+	y := top + 48
+	if root.Description != nil && *root.Description != "" {
+		c.SetFill("#333333")
+		for _, line := range wrapLabel(*root.Description, pageW-2*pdfMargin, 11) {
+			c.Text(pdfMargin, y, 11, false, "left", line)
+			y += 14
+		}
+	}
+
+	var updated time.Time
+	levels, deprecated := 0, 0
+	for _, section := range sections {
+		if section.diagram.Updated.After(updated) {
+			updated = section.diagram.Updated
+		}
+		levels = max(levels, section.depth+1)
+		if section.diagram.Deprecated != nil {
+			deprecated++
+		}
+	}
+
+	rows := [][2]string{{"Root diagram", root.ID}}
+	if root.Version != "" {
+		rows = append(rows, [2]string{"Version", root.Version})
+	}
+	if owner := root.Ownership; owner != nil {
+		if owner.Owner != "" {
+			rows = append(rows, [2]string{"Owner", owner.Owner})
+		}
+		if owner.Accountable != "" {
+			rows = append(rows, [2]string{"Accountable", owner.Accountable})
+		}
+	}
+	rows = append(rows,
+		[2]string{"Diagrams", fmt.Sprintf("%d in %d levels", len(sections), levels)},
+		[2]string{"Last updated", updated.UTC().Format("2006-01-02 15:04 MST")},
+		[2]string{"Generated", time.Now().UTC().Format("2006-01-02 15:04 MST")},
+	)
+	if deprecated > 0 {
+		rows = append(rows, [2]string{"Deprecated", fmt.Sprintf("%d diagrams", deprecated)})
+	}
+	if len(root.Tags) > 0 {
+		rows = append(rows, [2]string{"Tags", strings.Join(root.Tags, ", ")})
+	}
+
+	y += 24
+	c.SetStroke("#cccccc")
+	c.SetLineWidth(0.5)
+	c.Polyline([][2]float64{{pdfMargin, y - 14}, {pageW - pdfMargin, y - 14}})
+	for _, row := range rows {
+		c.SetFill("#555555")
+		c.Text(pdfMargin, y, 10, true, "left", row[0])
+		c.SetFill("#222222")
+		c.Text(pdfMargin+110, y, 10, false, "left", row[1])
+		y += 16
+	}
+}
+
+// drawBookTOCRow writes one contents entry with a dotted leader to its page
+// number; the whole row links to the section
+func drawBookTOCRow(c *pdfCanvas, section *bookSection, y, pageW float64) {
+	size := 10.0
+	bold := section.depth == 0
+	x := pdfMargin + float64(section.depth)*bookIndent
+	title := section.number + "  " + section.diagram.Name
+	if section.diagram.Deprecated != nil {
+		title += " (deprecated)"
+	}
+	page := fmt.Sprintf("%d", section.page+1)
+
+	c.SetFill("#222222")
+	c.Text(x, y, size, bold, "left", title)
+	c.Text(pageW-pdfMargin, y, size, bold, "right", page)
+
+	from := x + textWidth(title, size) + 6
+	to := pageW - pdfMargin - textWidth(page, size) - 6
+	if to > from {
+		c.Save()
+		c.SetStroke("#999999")
+		c.SetLineWidth(0.75)
+		c.SetDash("1,3")
+		c.Polyline([][2]float64{{from, y}, {to, y}})
+		c.Restore()
+	}
+	c.Link(x, y-size, pageW-pdfMargin-x, bookTOCRowHeight, section.page)
+}
+
+// drawBookSection draws a diagram page: the diagram fitted above a block of
+// page references to its parent and children
+func drawBookSection(c *pdfCanvas, section *bookSection, sections []*bookSection, pageOf map[string]int, pageW, pageH float64) {
+	diagram := section.diagram
+	drawPDFWatermark(c, diagram, pageW, pageH)
+	drawPDFTitle(c, pageW, section.number+"  "+diagram.Name, diagram.ID)
+
+	var refs []bookRef
+	if section.parent >= 0 {
+		parent := sections[section.parent]
+		refs = append(refs, bookRef{text: fmt.Sprintf("Up: %s %s (p. %d)", parent.number, parent.diagram.Name, parent.page+1), page: parent.page})
+	}
+	var children []bookRef
+	for _, other := range sections {
+		if other.parent >= 0 && sections[other.parent] == section {
+			children = append(children, bookRef{text: fmt.Sprintf("%s %s (p. %d)", other.number, other.diagram.Name, other.page+1), page: other.page})
+		}
+	}
+	if len(children) > 0 {
+		refs = append(refs, bookRef{text: "Contains:", page: -1})
+		refs = append(refs, children...)
+	}
+	lines := layoutBookRefs(refs, pageW-2*pdfMargin, 8)
+
+	top := pdfMargin + pdfHeaderHeight
+	refsTop := pageH - pdfMargin - pdfFooterHeight - float64(len(lines))*bookRefRowHeight
+	tf, scale, ok := fitDiagram(diagram, pdfMargin, top, pageW-2*pdfMargin, refsTop-top-6)
+	if !ok {
+		c.SetFill("#555555")
+		c.Text(pdfMargin, top+20, 10, false, "left", "This diagram has no nodes.")
+	} else {
+		drawDiagramPDF(c, diagram, tf, scale)
+
+		// Drill-down nodes point to the page of the diagram they open
+		for i := range diagram.Nodes {
+			node := &diagram.Nodes[i]
+			if node.DrillDown == nil {
+				continue
+			}
+			page, ok := pageOf[*node.DrillDown]
+			if !ok {
+				continue
+			}
+			nx, ny, nw, nh := nodeBounds(node)
+			x, y := tf(nx, ny)
+			w, h := nw*scale, nh*scale
+			c.SetFill("#c0392b")
+			c.Text(x+w, y+h+9, 8, true, "right", fmt.Sprintf("see p. %d", page+1))
+			c.Link(x, y, w, h, page)
+		}
+	}
+
+	for i, line := range lines {
+		y := refsTop + float64(i+1)*bookRefRowHeight - 2
+		x := pdfMargin
+		for _, ref := range line {
+			w := textWidth(ref.text, 8)
+			if ref.page < 0 {
+				c.SetFill("#555555")
+			} else {
+				c.SetFill("#1f5fa8")
+				c.Link(x, y-8, w, bookRefRowHeight, ref.page)
+			}
+			c.Text(x, y, 8, ref.page < 0, "left", ref.text)
+			x += w + 10
+		}
+	}
+}
+
+// layoutBookRefs wraps references into lines that fit the given width
+func layoutBookRefs(refs []bookRef, width, size float64) [][]bookRef {
+	var lines [][]bookRef
+	var line []bookRef
+	used := 0.0
+	for _, ref := range refs {
+		w := textWidth(ref.text, size) + 10
+		if len(line) > 0 && used+w > width {
+			lines = append(lines, line)
+			line, used = nil, 0
+		}
+		line = append(line, ref)
+		used += w
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// drawBookPageNumber writes the page number in the footer
+func drawBookPageNumber(c *pdfCanvas, index, total int) {
+	c.SetFill("#555555")
+	c.Text(c.page.width-pdfMargin, c.page.height-pdfMargin+4, 8, false, "right", fmt.Sprintf("Page %d of %d", index+1, total))
+}
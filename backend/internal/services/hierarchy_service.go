@@ -1,7 +1,12 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+
 	"github.com/michaellanpart/flowgen/backend/internal/models"
 )
 
@@ -17,6 +22,12 @@ func NewHierarchyService() *HierarchyService {
 	}
 }
 
+// Using returns a hierarchy service that reads and saves diagrams through
+// the given diagram service, e.g. one in a dry run
+func (s *HierarchyService) Using(diagramService *DiagramService) *HierarchyService {
+	return &HierarchyService{diagramService: diagramService}
+}
+
 // GetChildren returns child diagrams for a given parent
 func (s *HierarchyService) GetChildren(parentID string) ([]models.FlowDiagram, error) {
 	parent, err := s.diagramService.GetByID(parentID)
@@ -39,21 +50,46 @@ func (s *HierarchyService) GetChildren(parentID string) ([]models.FlowDiagram, e
 	return children, nil
 }
 
-// GetParent returns the parent diagram for a given child
+// GetParent returns the primary parent diagram for a given child
 func (s *HierarchyService) GetParent(childID string) (*models.FlowDiagram, error) {
 	child, err := s.diagramService.GetByID(childID)
 	if err != nil {
 		return nil, err
 	}
 
-	if child.Parent == nil {
+	parentIDs := child.ParentIDs()
+	if len(parentIDs) == 0 {
 		return nil, fmt.Errorf("diagram has no parent")
 	}
 
-	return s.diagramService.GetByID(*child.Parent)
+	return s.diagramService.GetByID(parentIDs[0])
+}
+
+// GetParents returns every parent of a diagram, the primary parent first,
+// for sub-flows shared between several processes
+func (s *HierarchyService) GetParents(childID string) ([]models.FlowDiagram, error) {
+	child, err := s.diagramService.GetByID(childID)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := []models.FlowDiagram{}
+
+	for _, parentID := range child.ParentIDs() {
+		parent, err := s.diagramService.GetByID(parentID)
+		if err != nil {
+			// Log error but continue with other parents
+			fmt.Printf("Error getting parent diagram %s: %v\n", parentID, err)
+			continue
+		}
+		parents = append(parents, *parent)
+	}
+
+	return parents, nil
 }
 
-// LinkDiagrams creates a hierarchical relationship between diagrams
+// LinkDiagrams creates a hierarchical relationship between diagrams. A
+// child that already has a parent keeps it and is shared between both.
 func (s *HierarchyService) LinkDiagrams(parentID, childID, nodeID string) error {
 	// Get parent diagram
 	parent, err := s.diagramService.GetByID(parentID)
@@ -67,6 +103,14 @@ func (s *HierarchyService) LinkDiagrams(parentID, childID, nodeID string) error
 		return fmt.Errorf("failed to get child diagram: %w", err)
 	}
 
+	cycle, err := s.linkCycle(parentID, childID)
+	if err != nil {
+		return err
+	}
+	if cycle != nil {
+		return &HierarchyCycleError{Path: cycle}
+	}
+
 	// Update parent to include child
 	childExists := false
 	for _, existingChildID := range parent.Children {
@@ -85,7 +129,7 @@ func (s *HierarchyService) LinkDiagrams(parentID, childID, nodeID string) error
 		nodeFound := false
 		for i, node := range parent.Nodes {
 			if node.ID == nodeID {
-				parent.Nodes[i].DrillDown = &childID
+				setDrillDown(&parent.Nodes[i], childID)
 				nodeFound = true
 				break
 			}
@@ -97,7 +141,7 @@ func (s *HierarchyService) LinkDiagrams(parentID, childID, nodeID string) error
 	}
 
 	// Update child to reference parent
-	child.Parent = &parentID
+	addParent(child, parentID)
 
 	// Save both diagrams
 	if _, err := s.diagramService.Update(parent); err != nil {
@@ -111,6 +155,201 @@ func (s *HierarchyService) LinkDiagrams(parentID, childID, nodeID string) error
 	return nil
 }
 
+// ErrHierarchyCycle is returned when a link would make a diagram its own
+// ancestor
+var ErrHierarchyCycle = errors.New("link would create a hierarchy cycle")
+
+// HierarchyCycleError carries the diagrams a link would join into a cycle,
+// from the child down through the parent and back to the child
+type HierarchyCycleError struct {
+	Path []string
+}
+
+func (e *HierarchyCycleError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrHierarchyCycle, strings.Join(e.Path, " → "))
+}
+
+func (e *HierarchyCycleError) Unwrap() error {
+	return ErrHierarchyCycle
+}
+
+// linkCycle returns the cycle that linking childID below parentID would
+// close, or nil. It walks the ancestors of the parent breadth-first, so the
+// shortest cycle is reported.
+func (s *HierarchyService) linkCycle(parentID, childID string) ([]string, error) {
+	if parentID == childID {
+		return []string{childID, childID}, nil
+	}
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.FlowDiagram, len(diagrams))
+	for i := range diagrams {
+		if _, ok := byID[diagrams[i].ID]; !ok {
+			byID[diagrams[i].ID] = &diagrams[i]
+		}
+	}
+	parentsOf := make(map[string][]string)
+	links := hierarchyLinks(byID)
+	for _, id := range sortedKeys(links) {
+		for _, child := range links[id] {
+			parentsOf[child] = append(parentsOf[child], id)
+		}
+	}
+
+	below := map[string]string{parentID: ""} // Ancestor to the diagram it was reached from
+	queue := []string{parentID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, ancestor := range parentsOf[id] {
+			if _, seen := below[ancestor]; seen {
+				continue
+			}
+			below[ancestor] = id
+			if ancestor == childID {
+				path := []string{childID}
+				for step := id; step != ""; step = below[step] {
+					path = append(path, step)
+				}
+				return append(path, childID), nil
+			}
+			queue = append(queue, ancestor)
+		}
+	}
+	return nil, nil
+}
+
+// hierarchyLinks returns the children of each diagram, taken from both
+// children lists and parent fields, as either one alone may be edited in Git
+func hierarchyLinks(byID map[string]*models.FlowDiagram) map[string][]string {
+	links := make(map[string][]string)
+	add := func(parent, child string) {
+		if parent == "" || child == "" || containsValue(links[parent], child) {
+			return
+		}
+		links[parent] = append(links[parent], child)
+	}
+	for _, id := range sortedKeys(byID) {
+		diagram := byID[id]
+		for _, child := range diagram.Children {
+			add(id, child)
+		}
+		for _, parent := range diagram.ParentIDs() {
+			add(parent, id)
+		}
+	}
+	for _, children := range links {
+		sort.Strings(children)
+	}
+	return links
+}
+
+// hierarchyCycles finds the cycles in the hierarchy. It returns, for each
+// diagram in a cycle, the cycle starting and ending at that diagram.
+func hierarchyCycles(byID map[string]*models.FlowDiagram) map[string][]string {
+	links := hierarchyLinks(byID)
+	cycles := make(map[string][]string)
+	done := make(map[string]bool)
+	var stack []string
+	onStack := make(map[string]int) // Diagram to its index in stack
+
+	var visit func(id string)
+	visit = func(id string) {
+		onStack[id] = len(stack)
+		stack = append(stack, id)
+		for _, child := range links[id] {
+			if start, ok := onStack[child]; ok {
+				members := stack[start:]
+				for i, member := range members {
+					if _, found := cycles[member]; found {
+						continue
+					}
+					cycle := append(append([]string{}, members[i:]...), members[:i]...)
+					cycles[member] = append(cycle, member)
+				}
+			} else if !done[child] {
+				visit(child)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		delete(onStack, id)
+		done[id] = true
+	}
+	for _, id := range sortedKeys(links) {
+		if !done[id] {
+			visit(id)
+		}
+	}
+	return cycles
+}
+
+// addParent records parentID as a parent of a diagram. The first parent is
+// the primary one; later ones are added as shared parents.
+func addParent(diagram *models.FlowDiagram, parentID string) {
+	if containsValue(diagram.ParentIDs(), parentID) {
+		return
+	}
+	if diagram.Parent == nil || *diagram.Parent == "" {
+		diagram.Parent = &parentID
+		return
+	}
+	diagram.Parents = append(diagram.Parents, parentID)
+}
+
+// removeParent drops parentID from the parents of a diagram, promoting the
+// first shared parent when the primary one is removed
+func removeParent(diagram *models.FlowDiagram, parentID string) {
+	var remaining []string
+	for _, id := range diagram.ParentIDs() {
+		if id != parentID {
+			remaining = append(remaining, id)
+		}
+	}
+	diagram.Parent, diagram.Parents = nil, nil
+	if len(remaining) > 0 {
+		diagram.Parent = &remaining[0]
+	}
+	if len(remaining) > 1 {
+		diagram.Parents = remaining[1:]
+	}
+}
+
+// validateParents checks that a diagram is not its own parent and lists
+// each parent once
+func validateParents(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	seen := make(map[string]bool)
+	check := func(path, id string) {
+		if id == "" {
+			return
+		}
+		if id == diagram.ID {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("Diagram cannot be its own parent: %s", id),
+				Code:    "SELF_PARENT",
+				Value:   id,
+			})
+		}
+		if seen[id] {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("Duplicate parent: %s", id),
+				Code:    "DUPLICATE_PARENT",
+				Value:   id,
+			})
+		}
+		seen[id] = true
+	}
+	if diagram.Parent != nil {
+		check("parent", *diagram.Parent)
+	}
+	for i, id := range diagram.Parents {
+		check(fmt.Sprintf("parents[%d]", i), id)
+	}
+}
+
 // UnlinkDiagrams removes a hierarchical relationship
 func (s *HierarchyService) UnlinkDiagrams(parentID, childID string) error {
 	// Get parent diagram
@@ -137,12 +376,12 @@ func (s *HierarchyService) UnlinkDiagrams(parentID, childID string) error {
 	// Remove drill-down references from nodes
 	for i, node := range parent.Nodes {
 		if node.DrillDown != nil && *node.DrillDown == childID {
-			parent.Nodes[i].DrillDown = nil
+			parent.Nodes[i].DrillDown, parent.Nodes[i].DrillDownFocus = nil, nil
 		}
 	}
 
 	// Remove parent reference from child
-	child.Parent = nil
+	removeParent(child, parentID)
 
 	// Save both diagrams
 	if _, err := s.diagramService.Update(parent); err != nil {
@@ -156,6 +395,114 @@ func (s *HierarchyService) UnlinkDiagrams(parentID, childID string) error {
 	return nil
 }
 
+// ReparentResult lists the diagrams changed by moving a diagram
+type ReparentResult struct {
+	Diagram    models.FlowDiagram   `json:"diagram"`
+	Parent     models.FlowDiagram   `json:"parent"`
+	OldParents []models.FlowDiagram `json:"oldParents"` // Diagrams the diagram was detached from
+}
+
+// Reparent moves a diagram, with its subtree, below another parent. It is
+// removed from the children and drill-down nodes of its current parents, or
+// only of fromParentID when a shared sub-flow is moved away from one of
+// them, and added to the new parent, optionally drilling down from nodeID. Everything
+// is validated before anything is written, and diagrams already written are
+// restored if a later write fails.
+func (s *HierarchyService) Reparent(childID, parentID, fromParentID, nodeID string) (*ReparentResult, error) {
+	child, err := s.diagramService.GetByID(childID)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := s.diagramService.GetByID(parentID)
+	if err != nil {
+		return nil, err
+	}
+	cycle, err := s.linkCycle(parentID, childID)
+	if err != nil {
+		return nil, err
+	}
+	if cycle != nil {
+		return nil, &HierarchyCycleError{Path: cycle}
+	}
+
+	// Current parents are named by the child or list it as a child
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	var oldParents []*models.FlowDiagram
+	for i := range diagrams {
+		candidate := &diagrams[i]
+		if candidate.ID == parentID || candidate.ID == childID || (fromParentID != "" && candidate.ID != fromParentID) {
+			continue
+		}
+		if containsValue(candidate.Children, childID) || containsValue(child.ParentIDs(), candidate.ID) {
+			oldParents = append(oldParents, candidate)
+		}
+	}
+	if fromParentID != "" && fromParentID != parentID && len(oldParents) == 0 {
+		return nil, fmt.Errorf("%w: %s is not a parent of %s", ErrInvalidOptions, fromParentID, childID)
+	}
+
+	for _, old := range oldParents {
+		children := []string{}
+		for _, id := range old.Children {
+			if id != childID {
+				children = append(children, id)
+			}
+		}
+		old.Children = children
+		for i, node := range old.Nodes {
+			if node.DrillDown != nil && *node.DrillDown == childID {
+				old.Nodes[i].DrillDown, old.Nodes[i].DrillDownFocus = nil, nil
+			}
+		}
+	}
+	if !containsValue(parent.Children, childID) {
+		parent.Children = append(parent.Children, childID)
+	}
+	if nodeID != "" {
+		node := findNode(parent, nodeID)
+		if node == nil {
+			return nil, fmt.Errorf("%w: node %s not found in diagram %s", ErrInvalidOptions, nodeID, parentID)
+		}
+		setDrillDown(node, childID)
+	}
+	if fromParentID == "" {
+		child.Parent, child.Parents = &parentID, nil
+	} else if fromParentID != parentID {
+		removeParent(child, fromParentID)
+		addParent(child, parentID)
+	}
+
+	changed := append([]*models.FlowDiagram{child, parent}, oldParents...)
+	originals := make([]*models.FlowDiagram, len(changed))
+	for i, diagram := range changed {
+		if err := s.diagramService.validateDiagram(diagram); err != nil {
+			return nil, fmt.Errorf("%s: %w", diagram.ID, err)
+		}
+		if originals[i], err = s.diagramService.GetByID(diagram.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	saved := make([]models.FlowDiagram, 0, len(changed))
+	for i, diagram := range changed {
+		updated, err := s.diagramService.Update(diagram)
+		if err != nil {
+			err = fmt.Errorf("failed to update diagram %s: %w", diagram.ID, err)
+			return nil, errors.Join(err, s.diagramService.restoreDiagrams(originals[:i]))
+		}
+		saved = append(saved, *updated)
+	}
+
+	return &ReparentResult{
+		Diagram:    saved[0],
+		Parent:     saved[1],
+		OldParents: saved[2:],
+	}, nil
+}
+
 // GetHierarchyTree returns the complete hierarchy tree starting from a root diagram
 func (s *HierarchyService) GetHierarchyTree(rootID string) (*HierarchyNode, error) {
 	return s.buildHierarchyNode(rootID, make(map[string]bool))
@@ -200,3 +547,277 @@ func (s *HierarchyService) buildHierarchyNode(diagramID string, visited map[stri
 
 	return node, nil
 }
+
+// Defaults for partial hierarchy trees
+const (
+	DefaultTreePageSize = 50
+	MaxTreePageSize     = 500
+)
+
+// HierarchyTreeOptions limits how much of a hierarchy tree is expanded
+type HierarchyTreeOptions struct {
+	Depth    int // Levels of children to expand below the root; 0 expands all
+	Page     int // 1-based page of the root's children
+	PageSize int // Children listed per expanded diagram
+}
+
+// HierarchyTreeNode is a diagram in a partially expanded hierarchy tree.
+// Children holds one page of the diagram's children when it was expanded;
+// ChildCount and HasMoreChildren tell the client whether to request more.
+// SkippedChildren lists child IDs that are missing or would close a cycle.
+// Shared sub-flows appear below each of their parents and are marked Shared.
+type HierarchyTreeNode struct {
+	Diagram         models.FlowDiagram   `json:"diagram"`
+	Shared          bool                 `json:"shared,omitempty"`
+	Children        []*HierarchyTreeNode `json:"children"`
+	ChildCount      int                  `json:"childCount"`
+	Expanded        bool                 `json:"expanded"`
+	ChildrenPage    int                  `json:"childrenPage,omitempty"`
+	HasMoreChildren bool                 `json:"hasMoreChildren"`
+	SkippedChildren []string             `json:"skippedChildren,omitempty"`
+}
+
+// GetHierarchyTreePage returns the hierarchy below a diagram expanded to a
+// limited depth, with the children of each expanded diagram paginated. Only
+// the root's children follow opts.Page; deeper diagrams list their first
+// page, and clients fetch further pages with the deeper diagram as root.
+// Diagrams are read once per call rather than once per child.
+func (s *HierarchyService) GetHierarchyTreePage(rootID string, opts HierarchyTreeOptions) (*HierarchyTreeNode, error) {
+	if opts.Depth < 0 || opts.Page < 0 || opts.PageSize < 0 || opts.PageSize > MaxTreePageSize {
+		return nil, fmt.Errorf("%w: depth, page and page size must be positive, with at most %d children per page", ErrInvalidOptions, MaxTreePageSize)
+	}
+	if opts.Page == 0 {
+		opts.Page = 1
+	}
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultTreePageSize
+	}
+
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.FlowDiagram, len(diagrams))
+	for i := range diagrams {
+		if _, ok := byID[diagrams[i].ID]; !ok {
+			byID[diagrams[i].ID] = &diagrams[i]
+		}
+	}
+	root, ok := byID[rootID]
+	if !ok {
+		return nil, ErrDiagramNotFound
+	}
+	return buildTreePage(root, byID, opts, 0, opts.Page, map[string]bool{}), nil
+}
+
+func buildTreePage(diagram *models.FlowDiagram, byID map[string]*models.FlowDiagram, opts HierarchyTreeOptions, level, page int, visited map[string]bool) *HierarchyTreeNode {
+	visited[diagram.ID] = true
+	defer delete(visited, diagram.ID)
+
+	// Missing children and those closing a cycle are left out of the count
+	// and listed as skipped
+	var children []*models.FlowDiagram
+	var skipped []string
+	for _, childID := range diagram.Children {
+		child, ok := byID[childID]
+		if !ok || visited[childID] {
+			skipped = append(skipped, childID)
+			continue
+		}
+		children = append(children, child)
+	}
+
+	node := &HierarchyTreeNode{
+		Diagram:         *diagram,
+		Shared:          len(diagram.ParentIDs()) > 1,
+		Children:        []*HierarchyTreeNode{},
+		ChildCount:      len(children),
+		SkippedChildren: skipped,
+	}
+	if opts.Depth > 0 && level >= opts.Depth {
+		node.HasMoreChildren = len(children) > 0
+		return node
+	}
+
+	node.Expanded = true
+	node.ChildrenPage = page
+	start := min((page-1)*opts.PageSize, len(children))
+	end := min(start+opts.PageSize, len(children))
+	for _, child := range children[start:end] {
+		node.Children = append(node.Children, buildTreePage(child, byID, opts, level+1, 1, visited))
+	}
+	node.HasMoreChildren = end < len(children)
+	return node
+}
+
+// GenerateSystemMap builds a high-level diagram for a parent with one node per
+// child diagram. Edges are derived from cross-diagram references: edges in the
+// parent between nodes drilling into different children, and nodes inside a
+// child that drill down into a sibling. Relations declared by children become
+// edges named after the relation type; related diagrams outside the hierarchy
+// are added as external nodes. The map is generated on every call so it
+// always reflects the current hierarchy.
+func (s *HierarchyService) GenerateSystemMap(rootID string) (*models.FlowDiagram, error) {
+	root, err := s.diagramService.GetByID(rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := s.GetChildren(rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	const (
+		nodeWidth  = 180.0
+		nodeHeight = 70.0
+		spacingX   = 240.0
+		spacingY   = 140.0
+	)
+	columns := int(math.Ceil(math.Sqrt(float64(len(children)))))
+	if columns == 0 {
+		columns = 1
+	}
+
+	mapID := root.ID + "-map"
+	description := fmt.Sprintf("Generated system map of %s", root.Name)
+	systemMap := &models.FlowDiagram{
+		FlowEntity: models.FlowEntity{
+			ID:          mapID,
+			Name:        root.Name + " System Map",
+			Description: &description,
+			Metadata: map[string]interface{}{
+				"generated": true,
+				"source":    root.ID,
+			},
+		},
+		Version: root.Version,
+		Nodes:   []models.FlowNode{},
+		Edges:   []models.FlowEdge{},
+		Created: root.Created,
+		Updated: root.Updated,
+	}
+
+	childSet := make(map[string]bool)
+	for i, child := range children {
+		childID := child.ID
+		childSet[childID] = true
+		systemMap.Nodes = append(systemMap.Nodes, models.FlowNode{
+			FlowEntity: models.FlowEntity{
+				ID:          childID,
+				Name:        child.Name,
+				Description: child.Description,
+				Tags:        child.Tags,
+			},
+			Type: models.NodeTypeSubprocess,
+			Position: models.Position{
+				X: float64(i%columns) * spacingX,
+				Y: float64(i/columns) * spacingY,
+			},
+			Dimensions: &models.Dimensions{Width: nodeWidth, Height: nodeHeight},
+			DrillDown:  &childID,
+		})
+	}
+
+	seen := make(map[string]bool)
+	addEdge := func(from, to, name string) {
+		if from == to || !childSet[from] || !childSet[to] {
+			return
+		}
+		edgeID := from + "_to_" + to
+		if seen[edgeID] {
+			return
+		}
+		seen[edgeID] = true
+		systemMap.Edges = append(systemMap.Edges, models.FlowEdge{
+			FlowEntity: models.FlowEntity{ID: edgeID, Name: name},
+			Type:       models.ConnectionTypeAssociation,
+			From:       from,
+			To:         to,
+		})
+	}
+
+	// Edges in the parent between nodes that drill into different children
+	drillTargets := make(map[string]string)
+	for _, node := range root.Nodes {
+		if node.DrillDown != nil {
+			drillTargets[node.ID] = *node.DrillDown
+		}
+	}
+	for _, edge := range root.Edges {
+		from, okFrom := drillTargets[edge.From]
+		to, okTo := drillTargets[edge.To]
+		if okFrom && okTo {
+			name := edge.Name
+			if name == "" {
+				name = "flows to"
+			}
+			addEdge(from, to, name)
+		}
+	}
+
+	// Nodes inside a child that reference a sibling diagram
+	for _, child := range children {
+		for _, node := range child.Nodes {
+			if node.DrillDown != nil {
+				addEdge(child.ID, *node.DrillDown, "references")
+			}
+		}
+	}
+
+	// Relations declared by children, to siblings or to related diagrams
+	// outside the hierarchy
+	externalCount := 0
+	rows := (len(children) + columns - 1) / columns
+	for _, child := range children {
+		for _, relation := range child.Relations {
+			target := relation.Target
+			if target == child.ID {
+				continue
+			}
+			if !childSet[target] {
+				related, err := s.diagramService.GetByID(target)
+				if err != nil {
+					fmt.Printf("Error getting related diagram %s: %v\n", target, err)
+					continue
+				}
+				childSet[target] = true
+				systemMap.Nodes = append(systemMap.Nodes, models.FlowNode{
+					FlowEntity: models.FlowEntity{
+						ID:          target,
+						Name:        related.Name,
+						Description: related.Description,
+						Tags:        related.Tags,
+						Metadata:    map[string]interface{}{"related": true},
+					},
+					Type: models.NodeTypeExternal,
+					Position: models.Position{
+						X: float64(externalCount) * spacingX,
+						Y: float64(rows) * spacingY,
+					},
+					Dimensions: &models.Dimensions{Width: nodeWidth, Height: nodeHeight},
+					DrillDown:  &target,
+				})
+				externalCount++
+			}
+			edgeID := child.ID + "_" + string(relation.Type) + "_" + target
+			if seen[edgeID] {
+				continue
+			}
+			seen[edgeID] = true
+			systemMap.Edges = append(systemMap.Edges, models.FlowEdge{
+				FlowEntity: models.FlowEntity{
+					ID:          edgeID,
+					Name:        string(relation.Type),
+					Description: relation.Description,
+					Metadata:    map[string]interface{}{"relation": relation.Type},
+				},
+				Type: models.ConnectionTypeAssociation,
+				From: child.ID,
+				To:   target,
+			})
+		}
+	}
+
+	return systemMap, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ImportOptions names the diagram produced by an importer. Empty fields
+// fall back to importer-specific defaults.
+type ImportOptions struct {
+	ID   string
+	Name string
+	Mode string // Importer-specific variant, e.g. endpoints or flow for OpenAPI
+}
+
+// ImportResult is a generated diagram along with anything the importer
+// could not map
+type ImportResult struct {
+	Diagram  models.FlowDiagram `json:"diagram"`
+	Warnings []string           `json:"warnings"`
+}
+
+// Importer converts a source document into a diagram
+type Importer func(s *DiagramService, data []byte, opts ImportOptions) (*ImportResult, error)
+
+// Importers maps source types to their importers
+var Importers = map[string]Importer{
+	"terraform":      (*DiagramService).ImportTerraform,
+	"openapi":        (*DiagramService).ImportOpenAPI,
+	"github-actions": (*DiagramService).ImportGitHubActions,
+	"bpmn":           (*DiagramService).ImportBPMN,
+}
+
+var invalidIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// idAllocator turns arbitrary names into unique diagram element IDs that
+// satisfy the schema's ID pattern
+type idAllocator struct {
+	used map[string]bool
+}
+
+func newIDAllocator() *idAllocator {
+	return &idAllocator{used: make(map[string]bool)}
+}
+
+func (a *idAllocator) allocate(name string) string {
+	base := strings.Trim(invalidIDChars.ReplaceAllString(name, "_"), "_")
+	if base == "" || !isLetter(base[0]) {
+		base = "n_" + base
+	}
+	id := base
+	for i := 2; a.used[id]; i++ {
+		id = fmt.Sprintf("%s_%d", base, i)
+	}
+	a.used[id] = true
+	return id
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// newImportedDiagram returns an empty diagram with the requested or
+// default identity
+func newImportedDiagram(opts ImportOptions, defaultID, defaultName, source string) models.FlowDiagram {
+	id, name := opts.ID, opts.Name
+	if id == "" {
+		id = defaultID
+	}
+	if name == "" {
+		name = defaultName
+	}
+	now := time.Now()
+	return models.FlowDiagram{
+		FlowEntity: models.FlowEntity{
+			ID:       id,
+			Name:     name,
+			Metadata: map[string]interface{}{"importedFrom": source},
+			Tags:     []string{"imported", source},
+		},
+		Version:    "1.0.0",
+		Nodes:      []models.FlowNode{},
+		Edges:      []models.FlowEdge{},
+		Provenance: &models.Provenance{Method: models.ProvenanceImported, Format: source, ProducedAt: now},
+		Created:    now,
+		Updated:    now,
+	}
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// xmlElement is a generic XML tree; BPMN files mix many element types and
+// namespaces so they are walked rather than decoded into fixed structs
+type xmlElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Children []xmlElement `xml:",any"`
+	Text     string       `xml:",chardata"`
+}
+
+func (e *xmlElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (e *xmlElement) child(name string) *xmlElement {
+	for i := range e.Children {
+		if e.Children[i].XMLName.Local == name {
+			return &e.Children[i]
+		}
+	}
+	return nil
+}
+
+// bpmnNodeTypes maps BPMN flow elements onto node types; elements missing
+// here and not handled elsewhere are reported as warnings
+var bpmnNodeTypes = map[string]models.NodeType{
+	"startEvent":             models.NodeTypeStart,
+	"endEvent":               models.NodeTypeEnd,
+	"intermediateCatchEvent": models.NodeTypeCustom,
+	"intermediateThrowEvent": models.NodeTypeCustom,
+	"boundaryEvent":          models.NodeTypeCustom,
+	"task":                   models.NodeTypeProcess,
+	"userTask":               models.NodeTypeProcess,
+	"serviceTask":            models.NodeTypeProcess,
+	"scriptTask":             models.NodeTypeProcess,
+	"manualTask":             models.NodeTypeProcess,
+	"businessRuleTask":       models.NodeTypeProcess,
+	"sendTask":               models.NodeTypeProcess,
+	"receiveTask":            models.NodeTypeProcess,
+	"callActivity":           models.NodeTypeSubprocess,
+	"subProcess":             models.NodeTypeSubprocess,
+	"transaction":            models.NodeTypeSubprocess,
+	"adHocSubProcess":        models.NodeTypeSubprocess,
+	"exclusiveGateway":       models.NodeTypeDecision,
+	"inclusiveGateway":       models.NodeTypeDecision,
+	"parallelGateway":        models.NodeTypeDecision,
+	"eventBasedGateway":      models.NodeTypeDecision,
+	"complexGateway":         models.NodeTypeDecision,
+	"dataObjectReference":    models.NodeTypeData,
+	"dataStoreReference":     models.NodeTypeData,
+}
+
+// BPMN elements that carry no diagram content of their own
+var bpmnIgnored = map[string]bool{
+	"laneSet": true, "dataObject": true, "documentation": true, "extensionElements": true,
+	"incoming": true, "outgoing": true, "ioSpecification": true, "property": true, "textAnnotation": true,
+}
+
+// bpmnImport holds the state of one BPMN import
+type bpmnImport struct {
+	result  *ImportResult
+	ids     *idAllocator
+	nodeIDs map[string]string // BPMN ID to node ID
+	edgeIDs map[string]string // BPMN ID to edge ID
+	lanes   map[string]string // BPMN flow node ID to layer ID
+	pools   map[string]string // Process ID to participant name
+}
+
+// ImportBPMN converts BPMN 2.0 XML into a diagram. Events, tasks, gateways
+// and data references become nodes; sequence flows, message flows and data
+// associations become edges; lanes become layers. Shape bounds and edge
+// waypoints from BPMN DI are kept, otherwise the diagram is auto-laid out.
+// Embedded subprocesses are imported as single subprocess nodes.
+func (s *DiagramService) ImportBPMN(data []byte, opts ImportOptions) (*ImportResult, error) {
+	var definitions xmlElement
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	if err := decoder.Decode(&definitions); err != nil {
+		return nil, fmt.Errorf("%w: invalid BPMN XML: %v", ErrInvalidOptions, err)
+	}
+	if definitions.XMLName.Local != "definitions" {
+		return nil, fmt.Errorf("%w: expected a BPMN definitions element, got %s", ErrInvalidOptions, definitions.XMLName.Local)
+	}
+
+	name := definitions.attr("name")
+	var processes []*xmlElement
+	var collaborations []*xmlElement
+	var diagrams []*xmlElement
+	for i := range definitions.Children {
+		child := &definitions.Children[i]
+		switch child.XMLName.Local {
+		case "process":
+			processes = append(processes, child)
+			if name == "" {
+				name = child.attr("name")
+			}
+		case "collaboration":
+			collaborations = append(collaborations, child)
+		case "BPMNDiagram":
+			diagrams = append(diagrams, child)
+		}
+	}
+	if len(processes) == 0 && len(collaborations) == 0 {
+		return nil, fmt.Errorf("%w: BPMN document has no process", ErrInvalidOptions)
+	}
+	if name == "" {
+		name = "BPMN Process"
+	}
+
+	imp := &bpmnImport{
+		result: &ImportResult{
+			Diagram:  newImportedDiagram(opts, "bpmn_import", name, "bpmn"),
+			Warnings: []string{},
+		},
+		ids:     newIDAllocator(),
+		nodeIDs: make(map[string]string),
+		edgeIDs: make(map[string]string),
+		lanes:   make(map[string]string),
+		pools:   make(map[string]string),
+	}
+	diagram := &imp.result.Diagram
+	direction := models.LayoutDirectionLeftRight
+	diagram.Layout = &models.Layout{Direction: &direction}
+
+	// Participants either name a process (a pool) or stand for an external
+	// party drawn as a collapsed pool
+	for _, collaboration := range collaborations {
+		for i := range collaboration.Children {
+			participant := &collaboration.Children[i]
+			if participant.XMLName.Local != "participant" {
+				continue
+			}
+			if ref := participant.attr("processRef"); ref != "" {
+				imp.pools[ref] = participant.attr("name")
+				continue
+			}
+			imp.addNode(participant, models.NodeTypeExternal, "")
+		}
+	}
+
+	for _, process := range processes {
+		imp.lanesOf(process)
+		imp.flowElements(process, process.attr("id"))
+	}
+	for _, process := range processes {
+		imp.connections(process)
+	}
+	for _, collaboration := range collaborations {
+		imp.connections(collaboration)
+	}
+
+	if !imp.applyDI(diagrams) {
+		AutoLayout(diagram)
+	}
+	return imp.result, nil
+}
+
+// lanesOf turns the lanes of a process, including nested lane sets, into
+// layers and records which layer each flow node belongs to
+func (imp *bpmnImport) lanesOf(process *xmlElement) {
+	var walk func(e *xmlElement)
+	walk = func(e *xmlElement) {
+		for i := range e.Children {
+			child := &e.Children[i]
+			switch child.XMLName.Local {
+			case "laneSet", "childLaneSet":
+				walk(child)
+			case "lane":
+				name := child.attr("name")
+				if name == "" {
+					name = child.attr("id")
+				}
+				layerID := imp.ids.allocate("lane_" + child.attr("id"))
+				imp.result.Diagram.Layers = append(imp.result.Diagram.Layers, models.Layer{ID: layerID, Name: name})
+				for _, ref := range child.Children {
+					if ref.XMLName.Local == "flowNodeRef" {
+						imp.lanes[strings.TrimSpace(ref.Text)] = layerID
+					}
+				}
+				walk(child)
+			}
+		}
+	}
+	walk(process)
+}
+
+// flowElements adds a node for every flow element of a process
+func (imp *bpmnImport) flowElements(process *xmlElement, processID string) {
+	for i := range process.Children {
+		element := &process.Children[i]
+		kind := element.XMLName.Local
+		nodeType, ok := bpmnNodeTypes[kind]
+		switch {
+		case ok:
+			node := imp.addNode(element, nodeType, processID)
+			if kind == "subProcess" || kind == "transaction" || kind == "adHocSubProcess" {
+				if inner := len(element.Children); inner > 0 {
+					imp.result.Warnings = append(imp.result.Warnings,
+						fmt.Sprintf("%s %s: embedded content imported as a single subprocess node", kind, element.attr("id")))
+				}
+			}
+			if kind == "callActivity" {
+				if called := element.attr("calledElement"); called != "" {
+					node.Metadata["calledElement"] = called
+				}
+			}
+		case kind == "sequenceFlow" || kind == "association" || bpmnIgnored[kind]:
+		default:
+			imp.result.Warnings = append(imp.result.Warnings, fmt.Sprintf("unsupported BPMN element %s %s", kind, element.attr("id")))
+		}
+	}
+}
+
+func (imp *bpmnImport) addNode(element *xmlElement, nodeType models.NodeType, processID string) *models.FlowNode {
+	bpmnID := element.attr("id")
+	name := element.attr("name")
+	if name == "" {
+		name = bpmnID
+	}
+	node := models.FlowNode{
+		FlowEntity: models.FlowEntity{
+			ID:   imp.ids.allocate(bpmnID),
+			Name: name,
+			Metadata: map[string]interface{}{
+				"bpmnId":   bpmnID,
+				"bpmnType": element.XMLName.Local,
+			},
+		},
+		Type: nodeType,
+	}
+	if doc := element.child("documentation"); doc != nil {
+		if text := strings.TrimSpace(doc.Text); text != "" {
+			node.Description = &text
+		}
+	}
+	for _, child := range element.Children {
+		if strings.HasSuffix(child.XMLName.Local, "EventDefinition") {
+			node.Metadata["eventDefinition"] = strings.TrimSuffix(child.XMLName.Local, "EventDefinition")
+		}
+	}
+	if attached := element.attr("attachedToRef"); attached != "" {
+		node.Metadata["attachedTo"] = attached
+	}
+	if pool := imp.pools[processID]; pool != "" {
+		node.Metadata["pool"] = pool
+	}
+	if layer, ok := imp.lanes[bpmnID]; ok {
+		node.Layers = []string{layer}
+	}
+
+	imp.nodeIDs[bpmnID] = node.ID
+	imp.result.Diagram.Nodes = append(imp.result.Diagram.Nodes, node)
+	return &imp.result.Diagram.Nodes[len(imp.result.Diagram.Nodes)-1]
+}
+
+// connections adds the edges of a process or collaboration, including data
+// associations nested inside activities
+func (imp *bpmnImport) connections(container *xmlElement) {
+	for i := range container.Children {
+		element := &container.Children[i]
+		switch element.XMLName.Local {
+		case "sequenceFlow":
+			edge := imp.addEdge(element, models.ConnectionTypeSequence, element.attr("sourceRef"), element.attr("targetRef"))
+			if edge == nil {
+				continue
+			}
+			if condition := element.child("conditionExpression"); condition != nil {
+				if text := strings.TrimSpace(condition.Text); text != "" {
+					edge.Type = models.ConnectionTypeConditional
+					edge.Condition = &text
+				}
+			}
+		case "messageFlow":
+			imp.addEdge(element, models.ConnectionTypeDataFlow, element.attr("sourceRef"), element.attr("targetRef"))
+		case "association":
+			imp.addEdge(element, models.ConnectionTypeAssociation, element.attr("sourceRef"), element.attr("targetRef"))
+		default:
+			if _, ok := bpmnNodeTypes[element.XMLName.Local]; ok {
+				imp.dataAssociations(element)
+			}
+		}
+	}
+}
+
+// dataAssociations links data objects read or written by an activity
+func (imp *bpmnImport) dataAssociations(activity *xmlElement) {
+	activityID := activity.attr("id")
+	for i := range activity.Children {
+		association := &activity.Children[i]
+		var from, to string
+		switch association.XMLName.Local {
+		case "dataInputAssociation":
+			if source := association.child("sourceRef"); source != nil {
+				from, to = strings.TrimSpace(source.Text), activityID
+			}
+		case "dataOutputAssociation":
+			if target := association.child("targetRef"); target != nil {
+				from, to = activityID, strings.TrimSpace(target.Text)
+			}
+		default:
+			continue
+		}
+		if from != "" && to != "" {
+			imp.addEdge(association, models.ConnectionTypeDataFlow, from, to)
+		}
+	}
+}
+
+func (imp *bpmnImport) addEdge(element *xmlElement, connectionType models.ConnectionType, sourceRef, targetRef string) *models.FlowEdge {
+	bpmnID := element.attr("id")
+	from, okFrom := imp.nodeIDs[sourceRef]
+	to, okTo := imp.nodeIDs[targetRef]
+	if !okFrom || !okTo {
+		imp.result.Warnings = append(imp.result.Warnings,
+			fmt.Sprintf("%s %s: skipped, connects %s to %s", element.XMLName.Local, bpmnID, sourceRef, targetRef))
+		return nil
+	}
+	if bpmnID == "" {
+		bpmnID = sourceRef + "_" + targetRef
+	}
+	edge := models.FlowEdge{
+		FlowEntity: models.FlowEntity{
+			ID:       imp.ids.allocate(bpmnID),
+			Name:     element.attr("name"),
+			Metadata: map[string]interface{}{"bpmnId": bpmnID, "bpmnType": element.XMLName.Local},
+		},
+		Type: connectionType,
+		From: from,
+		To:   to,
+	}
+	imp.edgeIDs[bpmnID] = edge.ID
+	imp.result.Diagram.Edges = append(imp.result.Diagram.Edges, edge)
+	return &imp.result.Diagram.Edges[len(imp.result.Diagram.Edges)-1]
+}
+
+// applyDI copies shape bounds and edge bend points from BPMN DI. It reports
+// whether every node received a position.
+func (imp *bpmnImport) applyDI(diagrams []*xmlElement) bool {
+	diagram := &imp.result.Diagram
+	nodeIndex := make(map[string]int, len(diagram.Nodes))
+	for i, node := range diagram.Nodes {
+		nodeIndex[node.ID] = i
+	}
+	edgeIndex := make(map[string]int, len(diagram.Edges))
+	for i, edge := range diagram.Edges {
+		edgeIndex[edge.ID] = i
+	}
+
+	placed := make(map[string]bool)
+	var walk func(e *xmlElement)
+	walk = func(e *xmlElement) {
+		for i := range e.Children {
+			child := &e.Children[i]
+			element := child.attr("bpmnElement")
+			switch child.XMLName.Local {
+			case "BPMNPlane":
+				walk(child)
+			case "BPMNShape":
+				id, ok := imp.nodeIDs[element]
+				bounds := child.child("Bounds")
+				if !ok || bounds == nil {
+					continue
+				}
+				node := &diagram.Nodes[nodeIndex[id]]
+				node.Position = models.Position{X: xmlFloat(bounds.attr("x")), Y: xmlFloat(bounds.attr("y"))}
+				node.Dimensions = &models.Dimensions{Width: xmlFloat(bounds.attr("width")), Height: xmlFloat(bounds.attr("height"))}
+				placed[id] = true
+			case "BPMNEdge":
+				id, ok := imp.edgeIDs[element]
+				if !ok {
+					continue
+				}
+				var points []models.Position
+				for _, waypoint := range child.Children {
+					if waypoint.XMLName.Local == "waypoint" {
+						points = append(points, models.Position{X: xmlFloat(waypoint.attr("x")), Y: xmlFloat(waypoint.attr("y"))})
+					}
+				}
+				// The first and last waypoints sit on the connected shapes
+				if len(points) > 2 {
+					diagram.Edges[edgeIndex[id]].Waypoints = points[1 : len(points)-1]
+				}
+			}
+		}
+	}
+	for _, d := range diagrams {
+		walk(d)
+	}
+
+	if len(placed) < len(diagram.Nodes) {
+		if len(placed) > 0 {
+			imp.result.Warnings = append(imp.result.Warnings,
+				fmt.Sprintf("BPMN DI covers %d of %d nodes; diagram was auto-laid out", len(placed), len(diagram.Nodes)))
+		}
+		return false
+	}
+	return true
+}
+
+func xmlFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f
+}
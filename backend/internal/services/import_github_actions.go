@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ghWorkflow is the part of a GitHub Actions workflow the importer reads
+type ghWorkflow struct {
+	Name string    `yaml:"name"`
+	On   yaml.Node `yaml:"on"`
+	Jobs yaml.Node `yaml:"jobs"` // Kept as a node to preserve job order
+}
+
+type ghJob struct {
+	Name        string      `yaml:"name"`
+	Needs       yaml.Node   `yaml:"needs"` // A job ID or a list of them
+	If          string      `yaml:"if"`
+	RunsOn      interface{} `yaml:"runs-on"`
+	Uses        string      `yaml:"uses"` // Reusable workflow
+	Environment interface{} `yaml:"environment"`
+	Strategy    struct {
+		Matrix interface{} `yaml:"matrix"`
+	} `yaml:"strategy"`
+	Steps []struct {
+		ID   string `yaml:"id"`
+		Name string `yaml:"name"`
+		Uses string `yaml:"uses"`
+		Run  string `yaml:"run"`
+		If   string `yaml:"if"`
+	} `yaml:"steps"`
+}
+
+// ImportGitHubActions builds a diagram of a GitHub Actions workflow: the
+// triggers start the flow, each job is a step linked to the jobs it needs,
+// and job conditions label the edges into the job. Jobs calling reusable
+// workflows become subprocess nodes; job steps are kept in node metadata.
+func (s *DiagramService) ImportGitHubActions(data []byte, opts ImportOptions) (*ImportResult, error) {
+	var workflow ghWorkflow
+	if err := yaml.Unmarshal(data, &workflow); err != nil {
+		return nil, fmt.Errorf("%w: invalid workflow YAML: %v", ErrInvalidOptions, err)
+	}
+	if workflow.Jobs.Kind != yaml.MappingNode || len(workflow.Jobs.Content) == 0 {
+		return nil, fmt.Errorf("%w: workflow has no jobs", ErrInvalidOptions)
+	}
+
+	name := workflow.Name
+	if name == "" {
+		name = "GitHub Actions Workflow"
+	}
+	result := &ImportResult{
+		Diagram:  newImportedDiagram(opts, "github_actions_import", name, "github-actions"),
+		Warnings: []string{},
+	}
+	diagram := &result.Diagram
+	direction := models.LayoutDirectionLeftRight
+	diagram.Layout = &models.Layout{Direction: &direction}
+
+	ids := newIDAllocator()
+	edgeIDs := newIDAllocator()
+	link := func(from, to, condition string) {
+		edge := models.FlowEdge{
+			FlowEntity: models.FlowEntity{ID: edgeIDs.allocate(from + "_" + to)},
+			Type:       models.ConnectionTypeSequence,
+			From:       from,
+			To:         to,
+		}
+		if condition != "" {
+			edge.Type = models.ConnectionTypeConditional
+			edge.Condition = &condition
+		}
+		diagram.Edges = append(diagram.Edges, edge)
+	}
+
+	triggers := ghTriggers(&workflow.On)
+	start := ids.allocate("trigger")
+	startName := "Start"
+	if len(triggers) > 0 {
+		startName = "on: " + strings.Join(triggers, ", ")
+	}
+	diagram.Nodes = append(diagram.Nodes, models.FlowNode{
+		FlowEntity: models.FlowEntity{
+			ID:       start,
+			Name:     startName,
+			Metadata: map[string]interface{}{"triggers": triggers},
+		},
+		Type: models.NodeTypeStart,
+	})
+
+	// First pass: one node per job, in workflow order
+	type jobEntry struct {
+		key   string
+		id    string
+		job   ghJob
+		needs []string
+	}
+	var jobs []*jobEntry
+	byKey := make(map[string]*jobEntry)
+	for i := 0; i+1 < len(workflow.Jobs.Content); i += 2 {
+		key := workflow.Jobs.Content[i].Value
+		var job ghJob
+		if err := workflow.Jobs.Content[i+1].Decode(&job); err != nil {
+			return nil, fmt.Errorf("%w: job %s: %v", ErrInvalidOptions, key, err)
+		}
+		entry := &jobEntry{key: key, id: ids.allocate(key), job: job, needs: ghStrings(&job.Needs)}
+		jobs = append(jobs, entry)
+		byKey[key] = entry
+		diagram.Nodes = append(diagram.Nodes, ghJobNode(entry.id, key, &job))
+	}
+
+	// Second pass: dependencies
+	needed := make(map[string]bool)
+	for _, entry := range jobs {
+		linked := false
+		for _, need := range entry.needs {
+			dependency, ok := byKey[need]
+			if !ok {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("job %s needs unknown job %s", entry.key, need))
+				continue
+			}
+			needed[need] = true
+			link(dependency.id, entry.id, entry.job.If)
+			linked = true
+		}
+		if !linked {
+			link(start, entry.id, entry.job.If)
+		}
+	}
+
+	end := ids.allocate("done")
+	diagram.Nodes = append(diagram.Nodes, models.FlowNode{
+		FlowEntity: models.FlowEntity{ID: end, Name: "Done"},
+		Type:       models.NodeTypeEnd,
+	})
+	for _, entry := range jobs {
+		if !needed[entry.key] {
+			link(entry.id, end, "")
+		}
+	}
+
+	AutoLayout(diagram)
+	return result, nil
+}
+
+// ghJobNode describes a job as a process node, or a subprocess node when
+// it calls a reusable workflow
+func ghJobNode(id, key string, job *ghJob) models.FlowNode {
+	name := job.Name
+	if name == "" {
+		name = key
+	}
+	node := models.FlowNode{
+		FlowEntity: models.FlowEntity{
+			ID:       id,
+			Name:     name,
+			Metadata: map[string]interface{}{"job": key},
+		},
+		Type: models.NodeTypeProcess,
+	}
+	if job.Uses != "" {
+		node.Type = models.NodeTypeSubprocess
+		node.Metadata["uses"] = job.Uses
+	}
+	if job.RunsOn != nil {
+		node.Metadata["runsOn"] = job.RunsOn
+	}
+	if job.Environment != nil {
+		node.Metadata["environment"] = job.Environment
+	}
+	if job.Strategy.Matrix != nil {
+		node.Metadata["matrix"] = job.Strategy.Matrix
+	}
+	if job.If != "" {
+		node.Metadata["if"] = job.If
+	}
+
+	if len(job.Steps) > 0 {
+		steps := make([]map[string]interface{}, 0, len(job.Steps))
+		for i, step := range job.Steps {
+			entry := map[string]interface{}{"name": ghStepName(i, step.Name, step.Uses, step.Run)}
+			if step.ID != "" {
+				entry["id"] = step.ID
+			}
+			if step.Uses != "" {
+				entry["uses"] = step.Uses
+			}
+			if step.If != "" {
+				entry["if"] = step.If
+			}
+			steps = append(steps, entry)
+		}
+		node.Metadata["steps"] = steps
+		description := fmt.Sprintf("%d steps", len(job.Steps))
+		if len(job.Steps) == 1 {
+			description = "1 step"
+		}
+		node.Description = &description
+	}
+	return node
+}
+
+// ghStepName labels a step by its name, action or first command line
+func ghStepName(index int, name, uses, run string) string {
+	switch {
+	case name != "":
+		return name
+	case uses != "":
+		return uses
+	case run != "":
+		line, _, _ := strings.Cut(strings.TrimSpace(run), "\n")
+		return line
+	}
+	return fmt.Sprintf("Step %d", index+1)
+}
+
+// ghTriggers lists the event names of an on: block, which may be a single
+// event, a list of events or a map of events to filters
+func ghTriggers(on *yaml.Node) []string {
+	if on.Kind == yaml.MappingNode {
+		triggers := make([]string, 0, len(on.Content)/2)
+		for i := 0; i < len(on.Content); i += 2 {
+			triggers = append(triggers, on.Content[i].Value)
+		}
+		return triggers
+	}
+	return ghStrings(on)
+}
+
+// ghStrings reads a scalar or a sequence of scalars
+func ghStrings(node *yaml.Node) []string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value != "" {
+			return []string{node.Value}
+		}
+	case yaml.SequenceNode:
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			values = append(values, item.Value)
+		}
+		return values
+	}
+	return []string{}
+}
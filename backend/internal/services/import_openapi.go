@@ -0,0 +1,435 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// OpenAPI import modes
+const (
+	OpenAPIModeEndpoints = "endpoints" // Operations and the schemas they accept and return
+	OpenAPIModeFlow      = "flow"      // Call flow described by x-flow extensions
+)
+
+// OpenAPIFlowExtension is the vendor extension describing call flows. At the
+// root it is an ordered list of operations; on an operation it lists the
+// operations called next:
+//
+//	x-flow:
+//	  - createOrder
+//	  - operation: POST /payments
+//	    name: Pay
+//
+//	paths:
+//	  /orders:
+//	    post:
+//	      operationId: createOrder
+//	      x-flow:
+//	        next: [getOrder]
+//
+// Operations are referenced by operationId or as "METHOD /path".
+const OpenAPIFlowExtension = "x-flow"
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openAPIOperation is one method of one path
+type openAPIOperation struct {
+	Method string
+	Path   string
+	Spec   map[string]interface{}
+	Shared []interface{} // Path-level parameters
+}
+
+func (op *openAPIOperation) ref() string {
+	return strings.ToUpper(op.Method) + " " + op.Path
+}
+
+// ImportOpenAPI builds a diagram from an OpenAPI 3 or Swagger 2 document in
+// JSON or YAML. By default operations become process nodes linked to the
+// component schemas they accept and return; when the spec carries x-flow
+// extensions (or opts.Mode is "flow") the call flow is drawn instead.
+func (s *DiagramService) ImportOpenAPI(data []byte, opts ImportOptions) (*ImportResult, error) {
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(data, &spec); err != nil || spec == nil {
+		return nil, fmt.Errorf("%w: not an OpenAPI document", ErrInvalidOptions)
+	}
+	if spec["openapi"] == nil && spec["swagger"] == nil {
+		return nil, fmt.Errorf("%w: missing openapi or swagger version field", ErrInvalidOptions)
+	}
+
+	var operations []*openAPIOperation
+	paths, _ := spec["paths"].(map[string]interface{})
+	pathNames := sortedKeys(paths)
+	for _, path := range pathNames {
+		item, _ := paths[path].(map[string]interface{})
+		shared, _ := item["parameters"].([]interface{})
+		for _, method := range openAPIMethods {
+			if op, ok := item[method].(map[string]interface{}); ok {
+				operations = append(operations, &openAPIOperation{Method: method, Path: path, Spec: op, Shared: shared})
+			}
+		}
+	}
+
+	mode := opts.Mode
+	if mode == "" {
+		mode = OpenAPIModeEndpoints
+		if openAPIHasFlow(spec, operations) {
+			mode = OpenAPIModeFlow
+		}
+	}
+
+	info, _ := spec["info"].(map[string]interface{})
+	title, _ := info["title"].(string)
+	if title == "" {
+		title = "API"
+	}
+	result := &ImportResult{
+		Diagram:  newImportedDiagram(opts, "openapi_import", title, "openapi"),
+		Warnings: []string{},
+	}
+	if version, ok := info["version"].(string); ok {
+		result.Diagram.Metadata["apiVersion"] = version
+	}
+	if description, ok := info["description"].(string); ok && description != "" {
+		result.Diagram.Description = &description
+	}
+
+	switch mode {
+	case OpenAPIModeEndpoints:
+		openAPIEndpoints(spec, operations, result)
+	case OpenAPIModeFlow:
+		if !openAPIHasFlow(spec, operations) {
+			result.Warnings = append(result.Warnings, "no "+OpenAPIFlowExtension+" extensions found")
+		}
+		openAPIFlow(spec, operations, result)
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %q (use %s or %s)", ErrInvalidOptions, mode, OpenAPIModeEndpoints, OpenAPIModeFlow)
+	}
+
+	AutoLayout(&result.Diagram)
+	return result, nil
+}
+
+func openAPIHasFlow(spec map[string]interface{}, operations []*openAPIOperation) bool {
+	if spec[OpenAPIFlowExtension] != nil {
+		return true
+	}
+	for _, op := range operations {
+		if op.Spec[OpenAPIFlowExtension] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIOperationNode describes an operation as a process node
+func openAPIOperationNode(id string, op *openAPIOperation) models.FlowNode {
+	node := models.FlowNode{
+		FlowEntity: models.FlowEntity{
+			ID:   id,
+			Name: op.ref(),
+			Metadata: map[string]interface{}{
+				"method": strings.ToUpper(op.Method),
+				"path":   op.Path,
+			},
+		},
+		Type: models.NodeTypeProcess,
+	}
+	if operationID, ok := op.Spec["operationId"].(string); ok && operationID != "" {
+		node.Metadata["operationId"] = operationID
+	}
+	if summary, ok := op.Spec["summary"].(string); ok && summary != "" {
+		node.Description = &summary
+	}
+	if tags, ok := op.Spec["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if tag, ok := tag.(string); ok {
+				node.Tags = append(node.Tags, tag)
+			}
+		}
+	}
+	if deprecated, ok := op.Spec["deprecated"].(bool); ok && deprecated {
+		node.Metadata["deprecated"] = true
+	}
+	return node
+}
+
+// openAPIEndpoints draws every operation and the component schemas it
+// references, with data flowing from request schemas into the operation and
+// from the operation into response schemas
+func openAPIEndpoints(spec map[string]interface{}, operations []*openAPIOperation, result *ImportResult) {
+	diagram := &result.Diagram
+	ids := newIDAllocator()
+	edgeIDs := newIDAllocator()
+	schemaNodes := make(map[string]string)
+
+	schemaNode := func(name string) string {
+		if id, ok := schemaNodes[name]; ok {
+			return id
+		}
+		id := ids.allocate("schema_" + name)
+		schemaNodes[name] = id
+		node := models.FlowNode{
+			FlowEntity: models.FlowEntity{
+				ID:       id,
+				Name:     name,
+				Metadata: map[string]interface{}{"schema": name},
+			},
+			Type: models.NodeTypeData,
+		}
+		if schema, ok := openAPISchemas(spec)[name].(map[string]interface{}); ok {
+			if description, ok := schema["description"].(string); ok && description != "" {
+				node.Description = &description
+			}
+		}
+		diagram.Nodes = append(diagram.Nodes, node)
+		return id
+	}
+	link := func(from, to, name string, typ models.ConnectionType) {
+		diagram.Edges = append(diagram.Edges, models.FlowEdge{
+			FlowEntity: models.FlowEntity{ID: edgeIDs.allocate(from + "_" + to), Name: name},
+			Type:       typ,
+			From:       from,
+			To:         to,
+		})
+	}
+
+	for _, op := range operations {
+		node := openAPIOperationNode(ids.allocate(op.Method+"_"+op.Path), op)
+		diagram.Nodes = append(diagram.Nodes, node)
+
+		inputs := openAPISchemaRefs(spec, []interface{}{op.Spec["requestBody"], op.Spec["parameters"], op.Shared}, result)
+		for _, name := range inputs {
+			link(schemaNode(name), node.ID, "accepts", models.ConnectionTypeDataFlow)
+		}
+		outputs := openAPISchemaRefs(spec, []interface{}{op.Spec["responses"]}, result)
+		for _, name := range outputs {
+			link(node.ID, schemaNode(name), "returns", models.ConnectionTypeDataFlow)
+		}
+	}
+
+	// Schemas referencing other schemas, following newly added ones too
+	schemas := openAPISchemas(spec)
+	linked := make(map[string]bool)
+	for done := false; !done; {
+		done = true
+		for _, name := range sortedKeys(schemaNodes) {
+			if linked[name] {
+				continue
+			}
+			linked[name] = true
+			done = false
+			for _, ref := range openAPISchemaRefs(spec, []interface{}{schemas[name]}, result) {
+				if ref != name {
+					link(schemaNodes[name], schemaNode(ref), "references", models.ConnectionTypeAssociation)
+				}
+			}
+		}
+	}
+}
+
+// openAPIFlow draws the call flow: the root x-flow sequence between a start
+// and an end node, plus the next operations listed on each operation
+func openAPIFlow(spec map[string]interface{}, operations []*openAPIOperation, result *ImportResult) {
+	diagram := &result.Diagram
+	ids := newIDAllocator()
+	edgeIDs := newIDAllocator()
+
+	byRef := make(map[string]*openAPIOperation)
+	for _, op := range operations {
+		byRef[op.ref()] = op
+		if operationID, ok := op.Spec["operationId"].(string); ok && operationID != "" {
+			byRef[operationID] = op
+		}
+	}
+	resolve := func(ref string) *openAPIOperation {
+		if op, ok := byRef[ref]; ok {
+			return op
+		}
+		if method, path, ok := strings.Cut(strings.TrimSpace(ref), " "); ok {
+			return byRef[strings.ToUpper(method)+" "+strings.TrimSpace(path)]
+		}
+		return nil
+	}
+
+	nodeIDs := make(map[*openAPIOperation]string)
+	nodeFor := func(op *openAPIOperation, name string) string {
+		if id, ok := nodeIDs[op]; ok {
+			return id
+		}
+		node := openAPIOperationNode(ids.allocate(op.Method+"_"+op.Path), op)
+		if name != "" {
+			node.Name = name
+		}
+		nodeIDs[op] = node.ID
+		diagram.Nodes = append(diagram.Nodes, node)
+		return node.ID
+	}
+	link := func(from, to string, label string) {
+		edge := models.FlowEdge{
+			FlowEntity: models.FlowEntity{ID: edgeIDs.allocate(from + "_" + to), Name: "calls"},
+			Type:       models.ConnectionTypeSequence,
+			From:       from,
+			To:         to,
+		}
+		if label != "" {
+			edge.Name = label
+		}
+		diagram.Edges = append(diagram.Edges, edge)
+	}
+
+	if steps, ok := spec[OpenAPIFlowExtension].([]interface{}); ok && len(steps) > 0 {
+		start := ids.allocate("start")
+		diagram.Nodes = append(diagram.Nodes, models.FlowNode{
+			FlowEntity: models.FlowEntity{ID: start, Name: "Start"},
+			Type:       models.NodeTypeStart,
+		})
+		previous := start
+		for i, step := range steps {
+			ref, name := "", ""
+			switch step := step.(type) {
+			case string:
+				ref = step
+			case map[string]interface{}:
+				ref, _ = step["operation"].(string)
+				name, _ = step["name"].(string)
+			}
+			op := resolve(ref)
+			if op == nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s[%d]: unknown operation %q", OpenAPIFlowExtension, i, ref))
+				continue
+			}
+			id := nodeFor(op, name)
+			link(previous, id, "")
+			previous = id
+		}
+		end := ids.allocate("end")
+		diagram.Nodes = append(diagram.Nodes, models.FlowNode{
+			FlowEntity: models.FlowEntity{ID: end, Name: "End"},
+			Type:       models.NodeTypeEnd,
+		})
+		link(previous, end, "")
+	}
+
+	for _, op := range operations {
+		extension, ok := op.Spec[OpenAPIFlowExtension].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		next, _ := extension["next"].([]interface{})
+		for _, ref := range next {
+			ref, _ := ref.(string)
+			target := resolve(ref)
+			if target == nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: unknown next operation %q", op.ref(), ref))
+				continue
+			}
+			link(nodeFor(op, ""), nodeFor(target, ""), "")
+		}
+	}
+}
+
+// openAPISchemas returns the named schemas of an OpenAPI 3 or Swagger 2 spec
+func openAPISchemas(spec map[string]interface{}) map[string]interface{} {
+	if components, ok := spec["components"].(map[string]interface{}); ok {
+		if schemas, ok := components["schemas"].(map[string]interface{}); ok {
+			return schemas
+		}
+	}
+	if definitions, ok := spec["definitions"].(map[string]interface{}); ok {
+		return definitions
+	}
+	return nil
+}
+
+// openAPISchemaRefs returns the named schemas referenced by the given
+// fragments, following references to shared parameters, request bodies and
+// responses but not into the schemas themselves
+func openAPISchemaRefs(spec map[string]interface{}, fragments []interface{}, result *ImportResult) []string {
+	found := make(map[string]bool)
+	followed := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				switch {
+				case strings.HasPrefix(ref, "#/components/schemas/"):
+					found[strings.TrimPrefix(ref, "#/components/schemas/")] = true
+				case strings.HasPrefix(ref, "#/definitions/"):
+					found[strings.TrimPrefix(ref, "#/definitions/")] = true
+				case strings.HasPrefix(ref, "#/"):
+					if !followed[ref] {
+						followed[ref] = true
+						target := openAPIPointer(spec, ref)
+						if target == nil {
+							result.Warnings = append(result.Warnings, fmt.Sprintf("unresolved reference %s", ref))
+						}
+						walk(target)
+					}
+				default:
+					if !followed[ref] {
+						followed[ref] = true
+						result.Warnings = append(result.Warnings, fmt.Sprintf("external reference %s is not followed", ref))
+					}
+				}
+				return
+			}
+			for _, key := range sortedKeys(v) {
+				walk(v[key])
+			}
+		case map[interface{}]interface{}:
+			walk(openAPIStringKeys(v))
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	for _, fragment := range fragments {
+		walk(fragment)
+	}
+	return sortedKeys(found)
+}
+
+// openAPIPointer resolves a local JSON pointer such as
+// #/components/responses/NotFound
+func openAPIPointer(spec map[string]interface{}, ref string) interface{} {
+	var current interface{} = spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		switch object := current.(type) {
+		case map[string]interface{}:
+			current = object[part]
+		case map[interface{}]interface{}:
+			current = openAPIStringKeys(object)[part]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// openAPIStringKeys returns a YAML mapping with keys that are not all
+// strings, such as unquoted response status codes, keyed by their text
+func openAPIStringKeys(m map[interface{}]interface{}) map[string]interface{} {
+	object := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		object[fmt.Sprint(key)] = value
+	}
+	return object
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
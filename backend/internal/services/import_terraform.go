@@ -0,0 +1,414 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// tfResource is a resource collected from any supported Terraform format
+type tfResource struct {
+	Address   string // Without instance keys, e.g. module.net.aws_vpc.main
+	Mode      string // managed or data
+	Type      string
+	Name      string
+	Provider  string
+	Action    string // Planned change, if read from a plan
+	Instances int
+	DependsOn map[string]bool
+}
+
+var tfInstanceKey = regexp.MustCompile(`\[[^\]]*\]`)
+
+// Resource types that hold state are drawn as data nodes
+var tfDataKeywords = []string{
+	"db", "database", "rds", "sql", "bucket", "s3", "storage", "dynamodb", "redis",
+	"cache", "table", "queue", "sqs", "topic", "sns", "kinesis", "disk", "volume",
+	"ebs", "efs", "bigquery", "cosmosdb", "firestore", "spanner", "bigtable",
+}
+
+// Providers treated as part of the platform; resources of any other
+// provider are drawn as external systems
+var tfPlatformProviders = map[string]bool{
+	"aws": true, "azurerm": true, "azuread": true, "google": true, "google-beta": true,
+	"kubernetes": true, "helm": true, "random": true, "null": true, "local": true,
+	"tls": true, "archive": true, "time": true, "template": true,
+}
+
+// ImportTerraform builds a diagram of resources and their dependencies from
+// `terraform show -json` output (state or plan) or a raw .tfstate file.
+// Dependencies point from the resource depended on to the dependent one.
+func (s *DiagramService) ImportTerraform(data []byte, opts ImportOptions) (*ImportResult, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: not a Terraform JSON document: %v", ErrInvalidOptions, err)
+	}
+
+	resources := make(map[string]*tfResource)
+	var err error
+	switch {
+	case doc["planned_values"] != nil:
+		err = tfFromPlan(doc, resources)
+	case doc["values"] != nil:
+		err = tfFromShow(doc["values"], resources)
+	case doc["resources"] != nil:
+		err = tfFromState(doc["resources"], resources)
+	default:
+		return nil, fmt.Errorf("%w: expected terraform show -json output or a .tfstate file", ErrInvalidOptions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+
+	result := &ImportResult{
+		Diagram:  newImportedDiagram(opts, "terraform_import", "Terraform Infrastructure", "terraform"),
+		Warnings: []string{},
+	}
+	diagram := &result.Diagram
+	direction := models.LayoutDirectionLeftRight
+	diagram.Layout = &models.Layout{Direction: &direction}
+
+	addresses := make([]string, 0, len(resources))
+	for address := range resources {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	ids := newIDAllocator()
+	nodeIDs := make(map[string]string)
+	for _, address := range addresses {
+		r := resources[address]
+		id := ids.allocate(address)
+		nodeIDs[address] = id
+
+		metadata := map[string]interface{}{
+			"address":      r.Address,
+			"resourceType": r.Type,
+		}
+		if r.Provider != "" {
+			metadata["provider"] = r.Provider
+		}
+		if r.Action != "" {
+			metadata["plannedAction"] = r.Action
+		}
+		if r.Instances > 1 {
+			metadata["instances"] = r.Instances
+		}
+		var tags []string
+		if r.Provider != "" {
+			tags = append(tags, r.Provider)
+		}
+		description := r.Address
+		diagram.Nodes = append(diagram.Nodes, models.FlowNode{
+			FlowEntity: models.FlowEntity{
+				ID:          id,
+				Name:        r.Type + "." + r.Name,
+				Description: &description,
+				Metadata:    metadata,
+				Tags:        tags,
+			},
+			Type: tfNodeType(r),
+		})
+	}
+
+	edgeIDs := newIDAllocator()
+	for _, address := range addresses {
+		r := resources[address]
+		refs := make([]string, 0, len(r.DependsOn))
+		for ref := range r.DependsOn {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+		linked := make(map[string]bool)
+		for _, ref := range refs {
+			targets := tfResolveReference(ref, addresses)
+			if len(targets) == 0 {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s depends on unknown resource %s", address, ref))
+				continue
+			}
+			for _, target := range targets {
+				if target == address || linked[target] {
+					continue
+				}
+				linked[target] = true
+				diagram.Edges = append(diagram.Edges, models.FlowEdge{
+					FlowEntity: models.FlowEntity{
+						ID:   edgeIDs.allocate("dep_" + nodeIDs[target] + "_" + nodeIDs[address]),
+						Name: "depends on",
+					},
+					Type: models.ConnectionTypeSequence,
+					From: nodeIDs[target],
+					To:   nodeIDs[address],
+				})
+			}
+		}
+	}
+
+	AutoLayout(diagram)
+	return result, nil
+}
+
+// tfNodeType maps a resource onto a node type
+func tfNodeType(r *tfResource) models.NodeType {
+	if r.Mode == "data" {
+		return models.NodeTypeData
+	}
+	provider := r.Provider
+	if provider == "" {
+		provider, _, _ = strings.Cut(r.Type, "_")
+	}
+	if !tfPlatformProviders[provider] {
+		return models.NodeTypeExternal
+	}
+	for _, part := range strings.Split(r.Type, "_")[1:] {
+		for _, keyword := range tfDataKeywords {
+			if part == keyword {
+				return models.NodeTypeData
+			}
+		}
+	}
+	return models.NodeTypeProcess
+}
+
+// tfProviderName shortens provider addresses such as
+// registry.terraform.io/hashicorp/aws or provider["registry.terraform.io/hashicorp/aws"]
+func tfProviderName(provider string) string {
+	provider = strings.TrimSuffix(strings.TrimPrefix(provider, `provider["`), `"]`)
+	if i := strings.LastIndex(provider, "/"); i >= 0 {
+		provider = provider[i+1:]
+	}
+	if i := strings.Index(provider, "."); i >= 0 {
+		provider = provider[:i]
+	}
+	return provider
+}
+
+// tfResolveReference maps a dependency or expression reference such as
+// aws_vpc.main.id or module.net.aws_subnet.a[0] to collected resource
+// addresses by matching the longest address prefix. A reference to a whole
+// module resolves to every resource inside it.
+func tfResolveReference(ref string, addresses []string) []string {
+	ref = tfInstanceKey.ReplaceAllString(ref, "")
+	for candidate := ref; candidate != ""; {
+		i := sort.SearchStrings(addresses, candidate)
+		if i < len(addresses) && addresses[i] == candidate {
+			return []string{candidate}
+		}
+		j := strings.LastIndex(candidate, ".")
+		if j < 0 {
+			break
+		}
+		candidate = candidate[:j]
+	}
+
+	var inModule []string
+	if parts := strings.Split(ref, "."); len(parts) >= 2 && parts[len(parts)-2] == "module" {
+		for _, address := range addresses {
+			if strings.HasPrefix(address, ref+".") {
+				inModule = append(inModule, address)
+			}
+		}
+	}
+	return inModule
+}
+
+func (r *tfResource) dependOn(refs ...string) {
+	for _, ref := range refs {
+		r.DependsOn[ref] = true
+	}
+}
+
+func tfCollect(resources map[string]*tfResource, address, mode, typ, name, provider string) *tfResource {
+	address = tfInstanceKey.ReplaceAllString(address, "")
+	r, ok := resources[address]
+	if !ok {
+		r = &tfResource{Address: address, Mode: mode, Type: typ, Name: name, Provider: tfProviderName(provider), DependsOn: make(map[string]bool)}
+		resources[address] = r
+	}
+	r.Instances++
+	return r
+}
+
+// tfModule is the module shape shared by state and planned values in
+// terraform show -json output
+type tfModule struct {
+	Resources []struct {
+		Address      string   `json:"address"`
+		Mode         string   `json:"mode"`
+		Type         string   `json:"type"`
+		Name         string   `json:"name"`
+		ProviderName string   `json:"provider_name"`
+		DependsOn    []string `json:"depends_on"`
+	} `json:"resources"`
+	ChildModules []tfModule `json:"child_modules"`
+}
+
+func (m tfModule) collect(resources map[string]*tfResource) {
+	for _, res := range m.Resources {
+		tfCollect(resources, res.Address, res.Mode, res.Type, res.Name, res.ProviderName).dependOn(res.DependsOn...)
+	}
+	for _, child := range m.ChildModules {
+		child.collect(resources)
+	}
+}
+
+// tfFromShow reads `terraform show -json` state output
+func tfFromShow(raw json.RawMessage, resources map[string]*tfResource) error {
+	var values struct {
+		RootModule tfModule `json:"root_module"`
+	}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return fmt.Errorf("invalid state values: %v", err)
+	}
+	values.RootModule.collect(resources)
+	return nil
+}
+
+// tfConfigModule is the configuration section of a plan, which is where
+// plans record references between resources
+type tfConfigModule struct {
+	Resources []struct {
+		Address     string                     `json:"address"`
+		DependsOn   []string                   `json:"depends_on"`
+		Expressions map[string]json.RawMessage `json:"expressions"`
+	} `json:"resources"`
+	ModuleCalls map[string]struct {
+		Module tfConfigModule `json:"module"`
+	} `json:"module_calls"`
+}
+
+func (m tfConfigModule) collect(prefix string, resources map[string]*tfResource) {
+	for _, res := range m.Resources {
+		r, ok := resources[prefix+res.Address]
+		if !ok {
+			continue
+		}
+		for _, dep := range res.DependsOn {
+			r.dependOn(prefix + dep)
+		}
+		for _, expr := range res.Expressions {
+			for _, ref := range tfReferences(expr) {
+				r.dependOn(prefix + ref)
+			}
+		}
+	}
+	for name, call := range m.ModuleCalls {
+		call.Module.collect(prefix+"module."+name+".", resources)
+	}
+}
+
+// tfReferences extracts every "references" list nested in a plan expression
+func tfReferences(raw json.RawMessage) []string {
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if list, ok := child.([]interface{}); ok && key == "references" {
+					for _, ref := range list {
+						if s, ok := ref.(string); ok && !strings.HasPrefix(s, "var.") && !strings.HasPrefix(s, "local.") {
+							refs = append(refs, s)
+						}
+					}
+					continue
+				}
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	var v interface{}
+	if json.Unmarshal(raw, &v) == nil {
+		walk(v)
+	}
+	return refs
+}
+
+// tfFromPlan reads `terraform show -json` plan output
+func tfFromPlan(doc map[string]json.RawMessage, resources map[string]*tfResource) error {
+	if err := tfFromShow(doc["planned_values"], resources); err != nil {
+		return err
+	}
+
+	if raw := doc["configuration"]; raw != nil {
+		var config struct {
+			RootModule tfConfigModule `json:"root_module"`
+		}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return fmt.Errorf("invalid plan configuration: %v", err)
+		}
+		config.RootModule.collect("", resources)
+	}
+
+	if raw := doc["resource_changes"]; raw != nil {
+		var changes []struct {
+			Address      string `json:"address"`
+			Mode         string `json:"mode"`
+			Type         string `json:"type"`
+			Name         string `json:"name"`
+			ProviderName string `json:"provider_name"`
+			Change       struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		}
+		if err := json.Unmarshal(raw, &changes); err != nil {
+			return fmt.Errorf("invalid resource changes: %v", err)
+		}
+		for _, change := range changes {
+			action := strings.Join(change.Change.Actions, ",")
+			address := tfInstanceKey.ReplaceAllString(change.Address, "")
+			r, ok := resources[address]
+			if !ok {
+				// Resources being destroyed are missing from planned values
+				if action != "delete" {
+					continue
+				}
+				r = tfCollect(resources, change.Address, change.Mode, change.Type, change.Name, change.ProviderName)
+			}
+			if action != "no-op" && action != "read" && (r.Action == "" || r.Action == "no-op") {
+				r.Action = action
+			}
+		}
+	}
+	return nil
+}
+
+// tfFromState reads a raw .tfstate file (format version 4)
+func tfFromState(raw json.RawMessage, resources map[string]*tfResource) error {
+	var stateResources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Provider  string `json:"provider"`
+		Instances []struct {
+			Dependencies []string `json:"dependencies"`
+		} `json:"instances"`
+	}
+	if err := json.Unmarshal(raw, &stateResources); err != nil {
+		return fmt.Errorf("invalid state resources: %v", err)
+	}
+	for _, res := range stateResources {
+		address := res.Type + "." + res.Name
+		if res.Mode == "data" {
+			address = "data." + address
+		}
+		if res.Module != "" {
+			address = res.Module + "." + address
+		}
+		r := tfCollect(resources, address, res.Mode, res.Type, res.Name, res.Provider)
+		r.Instances = len(res.Instances)
+		for _, instance := range res.Instances {
+			r.dependOn(instance.Dependencies...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// defaultJiraIssueType is the type of issues created without one
+const defaultJiraIssueType = "Task"
+
+// Page sizes of issue searches
+const (
+	defaultJiraPageSize = 20
+	maxJiraPageSize     = 100
+)
+
+// jiraSearchFields are the issue fields searches ask Jira for
+const jiraSearchFields = "summary,status,issuetype,priority,assignee"
+
+// ErrJiraIssueLinked is returned when a node already references a Jira
+// issue and no new one was asked for
+var ErrJiraIssueLinked = errors.New("node already linked to a Jira issue")
+
+// JiraError is a request Jira refused or failed
+type JiraError struct {
+	Status   int
+	Messages []string
+}
+
+func (e *JiraError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("jira answered with status %d", e.Status)
+	}
+	return fmt.Sprintf("jira answered with status %d: %s", e.Status, strings.Join(e.Messages, "; "))
+}
+
+// JiraIssueRequest is an issue to create
+type JiraIssueRequest struct {
+	Project     string `json:"project"`
+	IssueType   string `json:"issueType"` // Default Task
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"` // Optional
+}
+
+// JiraIssue is an issue created in Jira
+type JiraIssue struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+	URL string `json:"url"` // Browse page of the issue
+}
+
+// JiraIssueSummary is an issue found by a search, trimmed to what an issue
+// picker shows
+type JiraIssueSummary struct {
+	Key       string `json:"key"`
+	Summary   string `json:"summary"`
+	Status    string `json:"status,omitempty"`
+	IssueType string `json:"issueType,omitempty"`
+	Priority  string `json:"priority,omitempty"`
+	Assignee  string `json:"assignee,omitempty"` // Display name
+	URL       string `json:"url"`
+}
+
+// JiraSearchResult is one page of issues matching a JQL query
+type JiraSearchResult struct {
+	StartAt    int                `json:"startAt"`
+	MaxResults int                `json:"maxResults"`
+	Total      int                `json:"total"`
+	Issues     []JiraIssueSummary `json:"issues"`
+}
+
+// jiraNamed is an issue field Jira returns as an object with a name, e.g.
+// a status or a user
+type jiraNamed struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"` // Users only
+}
+
+// String is the display name of users and the name of anything else
+func (n *jiraNamed) String() string {
+	if n == nil {
+		return ""
+	}
+	if n.DisplayName != "" {
+		return n.DisplayName
+	}
+	return n.Name
+}
+
+// NodeIssue is the outcome of creating a Jira issue for a node
+type NodeIssue struct {
+	Issue       JiraIssue       `json:"issue"`
+	Node        models.FlowNode `json:"node"`
+	ContentHash string          `json:"contentHash"` // Version of the diagram saved with the issue key
+}
+
+// JiraService talks to the configured Jira
+type JiraService struct {
+	cfg            *config.Config
+	client         *http.Client
+	diagramService *DiagramService
+	site           string // Base URL of the Jira site, set by connect
+}
+
+// NewJiraService creates a new Jira service
+func NewJiraService() *JiraService {
+	return &JiraService{
+		cfg:            config.Load(),
+		client:         &http.Client{Timeout: 15 * time.Second},
+		diagramService: NewDiagramService(),
+	}
+}
+
+// Using returns a Jira service that saves diagrams through the given
+// diagram service, e.g. one acting for the request's user
+func (s *JiraService) Using(diagramService *DiagramService) *JiraService {
+	using := *s
+	using.diagramService = diagramService
+	return &using
+}
+
+// connect checks that Jira can be reached, with JIRA_BASE_URL and an API
+// token or with a stored OAuth connection, and resolves the site issues
+// are browsed at
+func (s *JiraService) connect() error {
+	if !s.oauthEnabled() {
+		if s.cfg.JiraBaseURL == "" {
+			return fmt.Errorf("%w: set JIRA_BASE_URL", ErrNotConfigured)
+		}
+		s.site = strings.TrimRight(s.cfg.JiraBaseURL, "/")
+		return nil
+	}
+	token, err := s.oauthToken(false)
+	if err != nil {
+		return err
+	}
+	s.site = token.SiteURL
+	return nil
+}
+
+// CreateIssue creates an issue in Jira
+func (s *JiraService) CreateIssue(request JiraIssueRequest) (*JiraIssue, error) {
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	if request.Project == "" || strings.TrimSpace(request.Summary) == "" {
+		return nil, fmt.Errorf("%w: an issue needs a project and a summary", ErrInvalidOptions)
+	}
+	if request.IssueType == "" {
+		request.IssueType = defaultJiraIssueType
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": request.Project},
+		"issuetype":   map[string]string{"name": request.IssueType},
+		"summary":     request.Summary,
+		"description": request.Description,
+	}
+	if request.Priority != "" {
+		fields["priority"] = map[string]string{"name": request.Priority}
+	}
+
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := s.do(http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &created); err != nil {
+		return nil, err
+	}
+	return &JiraIssue{ID: created.ID, Key: created.Key, URL: s.browseURL(created.Key)}, nil
+}
+
+// Search returns the page of issues matching a JQL query that starts at
+// startAt. maxResults defaults to 20 and is capped at 100.
+func (s *JiraService) Search(jql string, startAt, maxResults int) (*JiraSearchResult, error) {
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(jql) == "" {
+		return nil, fmt.Errorf("%w: jql is required", ErrInvalidOptions)
+	}
+	if startAt < 0 {
+		return nil, fmt.Errorf("%w: startAt must not be negative", ErrInvalidOptions)
+	}
+	if maxResults <= 0 {
+		maxResults = defaultJiraPageSize
+	} else if maxResults > maxJiraPageSize {
+		maxResults = maxJiraPageSize
+	}
+
+	var response struct {
+		StartAt    int `json:"startAt"`
+		MaxResults int `json:"maxResults"`
+		Total      int `json:"total"`
+		Issues     []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Summary   string     `json:"summary"`
+				Status    *jiraNamed `json:"status"`
+				IssueType *jiraNamed `json:"issuetype"`
+				Priority  *jiraNamed `json:"priority"`
+				Assignee  *jiraNamed `json:"assignee"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	params := url.Values{
+		"jql":        {jql},
+		"startAt":    {strconv.Itoa(startAt)},
+		"maxResults": {strconv.Itoa(maxResults)},
+		"fields":     {jiraSearchFields},
+	}
+	if err := s.do(http.MethodGet, "/rest/api/2/search?"+params.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+
+	result := &JiraSearchResult{
+		StartAt:    response.StartAt,
+		MaxResults: response.MaxResults,
+		Total:      response.Total,
+		Issues:     make([]JiraIssueSummary, 0, len(response.Issues)),
+	}
+	for _, issue := range response.Issues {
+		result.Issues = append(result.Issues, JiraIssueSummary{
+			Key:       issue.Key,
+			Summary:   issue.Fields.Summary,
+			Status:    issue.Fields.Status.String(),
+			IssueType: issue.Fields.IssueType.String(),
+			Priority:  issue.Fields.Priority.String(),
+			Assignee:  issue.Fields.Assignee.String(),
+			URL:       s.browseURL(issue.Key),
+		})
+	}
+	return result, nil
+}
+
+// CreateNodeIssue creates a Jira issue for a node, pre-filled from its
+// name, description and metadata with a link back to the diagram, and
+// saves the issue key in the node's Jira integration. Fields given in the
+// request replace the pre-filled ones; the project defaults to the node's
+// Jira project. A node already linked to an issue is refused unless
+// replace is set. The diagram is checked before the issue is created, and
+// the issue is deleted again when the diagram cannot be saved, so either
+// both happen or neither does.
+func (s *JiraService) CreateNodeIssue(diagramID, nodeID string, request JiraIssueRequest, replace bool) (*NodeIssue, error) {
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	diagram, err := s.diagramService.GetByID(diagramID)
+	if err != nil {
+		return nil, err
+	}
+	var node *models.FlowNode
+	for i := range diagram.Nodes {
+		if diagram.Nodes[i].ID == nodeID {
+			node = &diagram.Nodes[i]
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("%w: node %s not found", ErrInvalidOptions, nodeID)
+	}
+	if node.Integrations == nil {
+		node.Integrations = &models.Integrations{}
+	}
+	if node.Integrations.Jira == nil {
+		node.Integrations.Jira = &models.JiraIntegration{}
+	}
+	jira := node.Integrations.Jira
+	if jira.IssueKey != nil && *jira.IssueKey != "" && !replace {
+		return nil, fmt.Errorf("%w: node %s references %s", ErrJiraIssueLinked, nodeID, *jira.IssueKey)
+	}
+
+	if request.Project == "" {
+		request.Project = stringValue(jira.ProjectKey)
+	}
+	if request.Project == "" {
+		return nil, fmt.Errorf("%w: give a project; node %s has no Jira project", ErrInvalidOptions, nodeID)
+	}
+	if request.Summary == "" {
+		request.Summary = node.Name
+	}
+	if request.Description == "" {
+		request.Description = nodeIssueDescription(diagram, node)
+	}
+	if err := s.diagramService.checkWritable(diagram.FilePath); err != nil {
+		return nil, err
+	}
+	if err := s.diagramService.validateDiagram(diagram); err != nil {
+		return nil, err
+	}
+
+	issue, err := s.CreateIssue(request)
+	if err != nil {
+		return nil, err
+	}
+	jira.IssueKey, jira.ProjectKey = &issue.Key, &request.Project
+	saved, err := s.diagramService.save(diagram, SaveEventUpdate, fmt.Sprintf("Link node %s of %s to Jira issue %s", nodeID, diagramID, issue.Key))
+	if err != nil {
+		if deleteErr := s.do(http.MethodDelete, "/rest/api/2/issue/"+issue.Key, nil, nil); deleteErr != nil {
+			log.Printf("Deleting Jira issue %s after the diagram could not be saved failed: %v", issue.Key, deleteErr)
+		}
+		return nil, err
+	}
+	return &NodeIssue{Issue: *issue, Node: *node, ContentHash: saved.ContentHash}, nil
+}
+
+// nodeIssueDescription describes a node for a new issue: its description,
+// its metadata and where it is drawn
+func nodeIssueDescription(diagram *models.FlowDiagram, node *models.FlowNode) string {
+	var b strings.Builder
+	if node.Description != nil && *node.Description != "" {
+		b.WriteString(*node.Description)
+		b.WriteString("\n\n")
+	}
+	if len(node.Metadata) > 0 {
+		keys := make([]string, 0, len(node.Metadata))
+		for key := range node.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "* %s: %v\n", key, node.Metadata[key])
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Node %s of the FlowGen diagram %q (%s)", node.ID, diagram.Name, diagram.ID)
+	return b.String()
+}
+
+// browseURL is the page of an issue in Jira
+func (s *JiraService) browseURL(key string) string {
+	return s.site + "/browse/" + key
+}
+
+// do sends a request to the Jira REST API and decodes the response into
+// out unless it is nil. Requests go through the Atlassian API gateway with
+// the OAuth token when Jira is connected with OAuth, and to JIRA_BASE_URL
+// with JIRA_USERNAME and JIRA_API_TOKEN otherwise.
+func (s *JiraService) do(method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	token, err := s.oauthToken(false)
+	if err != nil {
+		return err
+	}
+
+	status, data, err := s.send(method, path, payload, token)
+	if err == nil && status == http.StatusUnauthorized && token != nil {
+		// The access token may have been revoked before it expired
+		if token, err = s.oauthToken(true); err != nil {
+			return err
+		}
+		status, data, err = s.send(method, path, payload, token)
+	}
+	if err != nil {
+		return err
+	}
+
+	if status < 200 || status >= 300 {
+		return jiraError(status, data)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse Jira response: %w", err)
+	}
+	return nil
+}
+
+// send makes one request to the Jira REST API, authenticated with the
+// OAuth token when given, and returns the status and body of the response
+func (s *JiraService) send(method, path string, payload []byte, token *JiraOAuthToken) (int, []byte, error) {
+	base := strings.TrimRight(s.cfg.JiraBaseURL, "/")
+	if token != nil {
+		base = strings.TrimRight(s.cfg.JiraOAuthAPIURL, "/") + "/ex/jira/" + token.CloudID
+	}
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, base+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != nil {
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	} else if s.cfg.JiraUsername != "" || s.cfg.JiraAPIToken != "" {
+		req.SetBasicAuth(s.cfg.JiraUsername, s.cfg.JiraAPIToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("jira request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, data, nil
+}
+
+// jiraError reads the error messages of a failed Jira response
+func jiraError(status int, data []byte) *JiraError {
+	var body struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	jiraErr := &JiraError{Status: status}
+	if json.Unmarshal(data, &body) != nil {
+		if text := strings.TrimSpace(string(data)); text != "" {
+			jiraErr.Messages = []string{text}
+		}
+		return jiraErr
+	}
+	jiraErr.Messages = append(jiraErr.Messages, body.ErrorMessages...)
+	for _, field := range sortedKeys(body.Errors) {
+		jiraErr.Messages = append(jiraErr.Messages, field+": "+body.Errors[field])
+	}
+	return jiraErr
+}
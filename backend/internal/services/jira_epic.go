@@ -0,0 +1,207 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// maxJiraEpicIssues bounds the issues of an epic put in a diagram
+const maxJiraEpicIssues = 500
+
+// jiraIssueKey matches issue keys such as PAY-123
+var jiraIssueKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+// jiraBlocksLink is the name of Jira's blocking link type
+const jiraBlocksLink = "Blocks"
+
+// jiraEpicIssue is the part of an epic's issue the generator reads
+type jiraEpicIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary   string     `json:"summary"`
+		Status    *jiraNamed `json:"status"`
+		IssueType *jiraNamed `json:"issuetype"`
+		Priority  *jiraNamed `json:"priority"`
+		Assignee  *jiraNamed `json:"assignee"`
+		Links     []struct {
+			Type struct {
+				Name string `json:"name"`
+			} `json:"type"`
+			Inward  *struct{ Key string } `json:"inwardIssue"`
+			Outward *struct{ Key string } `json:"outwardIssue"`
+		} `json:"issuelinks"`
+	} `json:"fields"`
+}
+
+// GenerateEpicDiagram builds a dependency diagram of a Jira epic for
+// planning reviews: each issue of the epic is a node linked back to the
+// issue, and each blocking link between two of them is an edge from the
+// blocking issue to the blocked one. Links to issues outside the epic are
+// reported as warnings. The diagram is laid out but not saved.
+func (s *JiraService) GenerateEpicDiagram(epicKey string, opts ImportOptions) (*ImportResult, error) {
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	epicKey = strings.ToUpper(strings.TrimSpace(epicKey))
+	if epicKey == "" {
+		return nil, fmt.Errorf("%w: epic is required", ErrInvalidOptions)
+	}
+	if !jiraIssueKey.MatchString(epicKey) {
+		return nil, fmt.Errorf("%w: %s is not an issue key", ErrInvalidOptions, epicKey)
+	}
+
+	var epic struct {
+		Fields struct {
+			Summary string `json:"summary"`
+		} `json:"fields"`
+	}
+	if err := s.do(http.MethodGet, "/rest/api/2/issue/"+url.PathEscape(epicKey)+"?fields=summary", nil, &epic); err != nil {
+		return nil, err
+	}
+	issues, truncated, err := s.epicIssues(epicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	name := epic.Fields.Summary
+	if name == "" {
+		name = epicKey
+	}
+	result := &ImportResult{
+		Diagram:  newImportedDiagram(opts, "jira_"+strings.ToLower(epicKey), name, "jira"),
+		Warnings: []string{},
+	}
+	diagram := &result.Diagram
+	diagram.Metadata["jiraEpic"] = epicKey
+	diagram.Metadata["jiraUrl"] = s.browseURL(epicKey)
+	direction := models.LayoutDirectionLeftRight
+	diagram.Layout = &models.Layout{Direction: &direction}
+	if truncated {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("epic %s has more than %d issues; only the first %d are shown", epicKey, maxJiraEpicIssues, maxJiraEpicIssues))
+	}
+
+	ids := newIDAllocator()
+	nodeIDs := make(map[string]string, len(issues))
+	for _, issue := range issues {
+		nodeIDs[issue.Key] = ids.allocate(issue.Key)
+		diagram.Nodes = append(diagram.Nodes, s.epicIssueNode(nodeIDs[issue.Key], issue))
+	}
+
+	// Both ends of a link report it, so edges are keyed by their ends
+	linked := make(map[[2]string]bool)
+	for _, issue := range issues {
+		for _, link := range issue.Fields.Links {
+			if link.Type.Name != jiraBlocksLink {
+				continue
+			}
+			from, to := issue.Key, ""
+			if link.Outward != nil {
+				to = link.Outward.Key
+			} else if link.Inward != nil {
+				from, to = link.Inward.Key, issue.Key
+			}
+			if to == "" || linked[[2]string{from, to}] {
+				continue
+			}
+			linked[[2]string{from, to}] = true
+			if outside := outsideIssue(nodeIDs, from, to); outside != "" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s blocks %s, but %s is not in epic %s", from, to, outside, epicKey))
+				continue
+			}
+			diagram.Edges = append(diagram.Edges, models.FlowEdge{
+				FlowEntity: models.FlowEntity{ID: ids.allocate(nodeIDs[from] + "_blocks_" + nodeIDs[to])},
+				Type:       models.ConnectionTypeSequence,
+				From:       nodeIDs[from],
+				To:         nodeIDs[to],
+			})
+		}
+	}
+
+	if data, err := json.Marshal(issues); err == nil {
+		RecordImportSource(diagram, s.browseURL(epicKey), data)
+	}
+	AutoLayout(diagram)
+	return result, nil
+}
+
+// outsideIssue returns whichever end of a link has no node, if any
+func outsideIssue(nodeIDs map[string]string, from, to string) string {
+	if nodeIDs[from] == "" {
+		return from
+	}
+	if nodeIDs[to] == "" {
+		return to
+	}
+	return ""
+}
+
+// epicIssues pages through the issues of an epic, up to maxJiraEpicIssues
+func (s *JiraService) epicIssues(epicKey string) ([]jiraEpicIssue, bool, error) {
+	jql := fmt.Sprintf(`"Epic Link" = %[1]s OR parent = %[1]s ORDER BY key`, epicKey)
+	var issues []jiraEpicIssue
+	for {
+		var page struct {
+			Total  int             `json:"total"`
+			Issues []jiraEpicIssue `json:"issues"`
+		}
+		params := url.Values{
+			"jql":        {jql},
+			"startAt":    {strconv.Itoa(len(issues))},
+			"maxResults": {strconv.Itoa(maxJiraPageSize)},
+			"fields":     {jiraSearchFields + ",issuelinks"},
+		}
+		if err := s.do(http.MethodGet, "/rest/api/2/search?"+params.Encode(), nil, &page); err != nil {
+			return nil, false, err
+		}
+		issues = append(issues, page.Issues...)
+		if len(issues) >= maxJiraEpicIssues {
+			return issues[:maxJiraEpicIssues], page.Total > maxJiraEpicIssues, nil
+		}
+		if len(page.Issues) == 0 || len(issues) >= page.Total {
+			return issues, false, nil
+		}
+	}
+}
+
+// epicIssueNode describes an issue as a process node referencing it
+func (s *JiraService) epicIssueNode(id string, issue jiraEpicIssue) models.FlowNode {
+	name := issue.Fields.Summary
+	if name == "" {
+		name = issue.Key
+	}
+	metadata := map[string]interface{}{"jiraUrl": s.browseURL(issue.Key)}
+	for key, value := range map[string]*jiraNamed{
+		"status":    issue.Fields.Status,
+		"issueType": issue.Fields.IssueType,
+		"priority":  issue.Fields.Priority,
+		"assignee":  issue.Fields.Assignee,
+	} {
+		if value := value.String(); value != "" {
+			metadata[key] = value
+		}
+	}
+	key := issue.Key
+	project := key
+	if i := strings.LastIndex(key, "-"); i > 0 {
+		project = key[:i]
+	}
+	return models.FlowNode{
+		FlowEntity: models.FlowEntity{
+			ID:       id,
+			Name:     name,
+			Metadata: metadata,
+			Tags:     []string{"jira"},
+		},
+		Type: models.NodeTypeProcess,
+		Integrations: &models.Integrations{
+			Jira: &models.JiraIntegration{IssueKey: &key, ProjectKey: &project},
+		},
+	}
+}
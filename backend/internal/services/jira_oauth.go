@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jiraOAuthStateTTL is how long a person has to grant access after being
+// sent to Atlassian
+const jiraOAuthStateTTL = 10 * time.Minute
+
+// jiraTokenRefreshMargin is how long before it expires an access token is
+// refreshed
+const jiraTokenRefreshMargin = time.Minute
+
+// Ways of authenticating to Jira
+const (
+	JiraAuthOAuth = "oauth" // Atlassian OAuth 2.0 (3LO)
+	JiraAuthToken = "token" // JIRA_USERNAME and JIRA_API_TOKEN
+	JiraAuthNone  = "none"
+)
+
+var (
+	// jiraOAuthMu serializes changes of the token file, so that a rotated
+	// refresh token is never used twice
+	jiraOAuthMu sync.Mutex
+
+	// jiraOAuthStates holds the authorizations in progress by state
+	jiraOAuthStatesMu sync.Mutex
+	jiraOAuthStates   = map[string]jiraOAuthState{}
+)
+
+// jiraOAuthState is an authorization waiting for Atlassian's callback
+type jiraOAuthState struct {
+	expires  time.Time
+	returnTo string
+	actor    string
+}
+
+// JiraOAuthToken is the stored OAuth connection to a Jira Cloud site
+type JiraOAuthToken struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Scope        string    `json:"scope"`
+	CloudID      string    `json:"cloudId"`
+	SiteURL      string    `json:"siteUrl"`
+	ConnectedBy  string    `json:"connectedBy,omitempty"`
+	ConnectedAt  time.Time `json:"connectedAt"`
+}
+
+// JiraConnection describes how the server reaches Jira, without secrets
+type JiraConnection struct {
+	Method      string     `json:"method"` // oauth, token or none
+	Connected   bool       `json:"connected"`
+	SiteURL     string     `json:"siteUrl,omitempty"`
+	CloudID     string     `json:"cloudId,omitempty"`
+	Scope       string     `json:"scope,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"` // Of the current access token
+	ConnectedBy string     `json:"connectedBy,omitempty"`
+	ConnectedAt *time.Time `json:"connectedAt,omitempty"`
+}
+
+// oauthEnabled reports whether Jira is reached with OAuth rather than an
+// API token
+func (s *JiraService) oauthEnabled() bool {
+	return s.cfg.JiraOAuthClientID != ""
+}
+
+// AuthorizeURL starts connecting Jira with OAuth: it returns the Atlassian
+// consent page to send the person to. returnTo is an optional path of this
+// server the callback redirects to; the person named in the request's
+// USER_HEADER is recorded as who connected Jira.
+func (s *JiraService) AuthorizeURL(returnTo string, header http.Header) (string, error) {
+	if !s.oauthEnabled() || s.cfg.JiraOAuthClientSecret == "" || s.cfg.JiraOAuthRedirectURL == "" {
+		return "", fmt.Errorf("%w: set JIRA_OAUTH_CLIENT_ID, JIRA_OAUTH_CLIENT_SECRET and JIRA_OAUTH_REDIRECT_URL", ErrNotConfigured)
+	}
+	if returnTo != "" && (!strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//")) {
+		return "", fmt.Errorf("%w: return must be a path on this server", ErrInvalidOptions)
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(random)
+	now := time.Now()
+	jiraOAuthStatesMu.Lock()
+	for key, pending := range jiraOAuthStates {
+		if now.After(pending.expires) {
+			delete(jiraOAuthStates, key)
+		}
+	}
+	jiraOAuthStates[state] = jiraOAuthState{expires: now.Add(jiraOAuthStateTTL), returnTo: returnTo, actor: header.Get(s.cfg.UserHeader)}
+	jiraOAuthStatesMu.Unlock()
+
+	params := url.Values{
+		"audience":      {"api.atlassian.com"},
+		"client_id":     {s.cfg.JiraOAuthClientID},
+		"scope":         {s.cfg.JiraOAuthScopes},
+		"redirect_uri":  {s.cfg.JiraOAuthRedirectURL},
+		"state":         {state},
+		"response_type": {"code"},
+		"prompt":        {"consent"},
+	}
+	return strings.TrimRight(s.cfg.JiraOAuthAuthURL, "/") + "/authorize?" + params.Encode(), nil
+}
+
+// CompleteOAuth finishes connecting Jira when Atlassian calls back with an
+// authorization code: the code is exchanged for tokens, which are stored
+// for the Jira site the app was granted (the one at JIRA_BASE_URL when
+// set). It returns the connection and the path to return to, if any.
+func (s *JiraService) CompleteOAuth(code, state string) (*JiraConnection, string, error) {
+	jiraOAuthStatesMu.Lock()
+	pending, ok := jiraOAuthStates[state]
+	delete(jiraOAuthStates, state)
+	jiraOAuthStatesMu.Unlock()
+	if !ok || time.Now().After(pending.expires) {
+		return nil, "", fmt.Errorf("%w: unknown or expired state; start again", ErrInvalidOptions)
+	}
+	if code == "" {
+		return nil, "", fmt.Errorf("%w: code is required", ErrInvalidOptions)
+	}
+
+	granted, err := s.requestToken(map[string]string{
+		"grant_type":   "authorization_code",
+		"code":         code,
+		"redirect_uri": s.cfg.JiraOAuthRedirectURL,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	site, err := s.grantedSite(granted.AccessToken)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	token := &JiraOAuthToken{
+		AccessToken:  granted.AccessToken,
+		RefreshToken: granted.RefreshToken,
+		ExpiresAt:    now.Add(time.Duration(granted.ExpiresIn) * time.Second),
+		Scope:        granted.Scope,
+		CloudID:      site.ID,
+		SiteURL:      strings.TrimRight(site.URL, "/"),
+		ConnectedBy:  pending.actor,
+		ConnectedAt:  now,
+	}
+
+	jiraOAuthMu.Lock()
+	defer jiraOAuthMu.Unlock()
+	if err := s.saveOAuthToken(token); err != nil {
+		return nil, "", err
+	}
+	return oauthConnection(token), pending.returnTo, nil
+}
+
+// Connection describes how Jira is reached
+func (s *JiraService) Connection() (*JiraConnection, error) {
+	if !s.oauthEnabled() {
+		if s.cfg.JiraBaseURL == "" {
+			return &JiraConnection{Method: JiraAuthNone}, nil
+		}
+		return &JiraConnection{Method: JiraAuthToken, Connected: true, SiteURL: strings.TrimRight(s.cfg.JiraBaseURL, "/")}, nil
+	}
+	jiraOAuthMu.Lock()
+	token, err := s.loadOAuthToken()
+	jiraOAuthMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return &JiraConnection{Method: JiraAuthOAuth}, nil
+	}
+	return oauthConnection(token), nil
+}
+
+// Disconnect forgets the stored OAuth tokens. Access stays granted in
+// Atlassian until revoked there.
+func (s *JiraService) Disconnect() error {
+	if !s.oauthEnabled() {
+		return fmt.Errorf("%w: set JIRA_OAUTH_CLIENT_ID", ErrNotConfigured)
+	}
+	jiraOAuthMu.Lock()
+	defer jiraOAuthMu.Unlock()
+	if err := os.Remove(s.cfg.JiraOAuthTokenPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove Jira OAuth token: %w", err)
+	}
+	return nil
+}
+
+func oauthConnection(token *JiraOAuthToken) *JiraConnection {
+	expiresAt, connectedAt := token.ExpiresAt, token.ConnectedAt
+	return &JiraConnection{
+		Method:      JiraAuthOAuth,
+		Connected:   true,
+		SiteURL:     token.SiteURL,
+		CloudID:     token.CloudID,
+		Scope:       token.Scope,
+		ExpiresAt:   &expiresAt,
+		ConnectedBy: token.ConnectedBy,
+		ConnectedAt: &connectedAt,
+	}
+}
+
+// oauthToken returns the stored OAuth token, refreshed first when it is
+// about to expire or refresh is set. It is nil when OAuth is not in use.
+func (s *JiraService) oauthToken(refresh bool) (*JiraOAuthToken, error) {
+	if !s.oauthEnabled() {
+		return nil, nil
+	}
+	jiraOAuthMu.Lock()
+	defer jiraOAuthMu.Unlock()
+	token, err := s.loadOAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, fmt.Errorf("%w: connect Jira at /api/v1/integrations/jira/oauth/authorize", ErrNotConfigured)
+	}
+	if !refresh && time.Until(token.ExpiresAt) > jiraTokenRefreshMargin {
+		return token, nil
+	}
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("%w: the Jira OAuth token expired and cannot be refreshed; request offline_access and connect Jira again", ErrNotConfigured)
+	}
+
+	refreshed, err := s.requestToken(map[string]string{
+		"grant_type":    "refresh_token",
+		"refresh_token": token.RefreshToken,
+	})
+	var jiraErr *JiraError
+	if errors.As(err, &jiraErr) && jiraErr.Status >= 400 && jiraErr.Status < 500 {
+		return nil, fmt.Errorf("%w: refreshing the Jira OAuth token failed (%v); connect Jira again", ErrNotConfigured, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.AccessToken = refreshed.AccessToken
+	token.ExpiresAt = time.Now().Add(time.Duration(refreshed.ExpiresIn) * time.Second)
+	if refreshed.RefreshToken != "" { // Refresh tokens rotate
+		token.RefreshToken = refreshed.RefreshToken
+	}
+	if refreshed.Scope != "" {
+		token.Scope = refreshed.Scope
+	}
+	if err := s.saveOAuthToken(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// oauthTokenResponse is the answer of Atlassian's token endpoint
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Seconds
+	Scope        string `json:"scope"`
+}
+
+// requestToken calls Atlassian's token endpoint with the app's credentials
+func (s *JiraService) requestToken(grant map[string]string) (*oauthTokenResponse, error) {
+	grant["client_id"] = s.cfg.JiraOAuthClientID
+	grant["client_secret"] = s.cfg.JiraOAuthClientSecret
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Post(strings.TrimRight(s.cfg.JiraOAuthAuthURL, "/")+"/oauth/token", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("atlassian token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		jiraErr := &JiraError{Status: resp.StatusCode}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			jiraErr.Messages = []string{strings.TrimSuffix(failure.Error+": "+failure.Description, ": ")}
+		}
+		return nil, jiraErr
+	}
+	var token oauthTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return nil, fmt.Errorf("failed to parse Atlassian token response")
+	}
+	return &token, nil
+}
+
+// jiraSite is a Jira Cloud site an OAuth token grants access to
+type jiraSite struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// grantedSite picks the site a new token is used for: the one at
+// JIRA_BASE_URL when set, otherwise the first one granted
+func (s *JiraService) grantedSite(accessToken string) (*jiraSite, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(s.cfg.JiraOAuthAPIURL, "/")+"/oauth/token/accessible-resources", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("atlassian request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, jiraError(resp.StatusCode, data)
+	}
+	var sites []jiraSite
+	if err := json.Unmarshal(data, &sites); err != nil {
+		return nil, fmt.Errorf("failed to parse Atlassian response: %w", err)
+	}
+	if len(sites) == 0 {
+		return nil, fmt.Errorf("%w: access was not granted to any Jira site", ErrInvalidOptions)
+	}
+	if s.cfg.JiraBaseURL == "" {
+		return &sites[0], nil
+	}
+	for i := range sites {
+		if strings.EqualFold(strings.TrimRight(sites[i].URL, "/"), strings.TrimRight(s.cfg.JiraBaseURL, "/")) {
+			return &sites[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: access was not granted to %s", ErrInvalidOptions, s.cfg.JiraBaseURL)
+}
+
+// loadOAuthToken reads the stored token, nil when Jira is not connected.
+// Callers hold jiraOAuthMu.
+func (s *JiraService) loadOAuthToken() (*JiraOAuthToken, error) {
+	data, err := os.ReadFile(s.cfg.JiraOAuthTokenPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Jira OAuth token: %w", err)
+	}
+	var token JiraOAuthToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse Jira OAuth token: %w", err)
+	}
+	return &token, nil
+}
+
+// saveOAuthToken stores a token readable by the server's user only.
+// Callers hold jiraOAuthMu.
+func (s *JiraService) saveOAuthToken(token *JiraOAuthToken) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.cfg.JiraOAuthTokenPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write Jira OAuth token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// FilterLayers returns a copy of the diagram restricted to the given layers.
+// When no layers are requested the diagram's visible-by-default layers are
+// used. Elements without layers are always kept, and edges whose endpoints
+// were filtered out are dropped.
+func FilterLayers(diagram *models.FlowDiagram, layers []string) (*models.FlowDiagram, error) {
+	defined := make(map[string]bool)
+	for _, layer := range diagram.Layers {
+		defined[layer.ID] = true
+	}
+
+	active := make(map[string]bool)
+	if len(layers) == 0 {
+		for _, layer := range diagram.Layers {
+			if layer.IsVisible() {
+				active[layer.ID] = true
+			}
+		}
+	} else {
+		for _, id := range layers {
+			if !defined[id] {
+				return nil, fmt.Errorf("unknown layer: %s", id)
+			}
+			active[id] = true
+		}
+	}
+
+	inActiveLayer := func(assigned []string) bool {
+		if len(assigned) == 0 {
+			return true
+		}
+		for _, id := range assigned {
+			if active[id] {
+				return true
+			}
+		}
+		return false
+	}
+
+	filtered := *diagram
+	filtered.Nodes = []models.FlowNode{}
+	filtered.Edges = []models.FlowEdge{}
+
+	kept := make(map[string]bool)
+	for _, node := range diagram.Nodes {
+		if inActiveLayer(node.Layers) {
+			filtered.Nodes = append(filtered.Nodes, node)
+			kept[node.ID] = true
+		}
+	}
+	for _, edge := range diagram.Edges {
+		if inActiveLayer(edge.Layers) && kept[edge.From] && kept[edge.To] {
+			filtered.Edges = append(filtered.Edges, edge)
+		}
+	}
+
+	return &filtered, nil
+}
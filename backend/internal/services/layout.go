@@ -0,0 +1,225 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Default spacing for auto-layout when the diagram does not set its own
+const (
+	defaultNodeSpacing = 60.0
+	defaultRankSpacing = 100.0
+)
+
+// AutoLayout positions the nodes of a diagram in layers: each node is
+// placed one rank after its furthest predecessor, and nodes within a rank
+// are ordered by the average position of their predecessors to reduce
+// crossings. Edges that close a loop are ignored for ranking. The diagram's
+// layout direction and spacing are honored. Pinned nodes keep their
+// position; waypoints are dropped except between two pinned nodes.
+func AutoLayout(diagram *models.FlowDiagram) {
+	if len(diagram.Nodes) == 0 {
+		return
+	}
+
+	index, rank, predecessors := rankNodes(diagram)
+
+	maxRank := 0
+	for _, r := range rank {
+		if r > maxRank {
+			maxRank = r
+		}
+	}
+	ranks := make([][]int, maxRank+1)
+	for i := range diagram.Nodes {
+		ranks[rank[i]] = append(ranks[rank[i]], i)
+	}
+
+	// Order each rank by the barycenter of its predecessors
+	order := make([]float64, len(diagram.Nodes))
+	for r, members := range ranks {
+		if r > 0 {
+			for _, i := range members {
+				if len(predecessors[i]) == 0 {
+					order[i] = float64(len(members))
+					continue
+				}
+				sum := 0.0
+				for _, p := range predecessors[i] {
+					sum += order[p]
+				}
+				order[i] = sum / float64(len(predecessors[i]))
+			}
+			sort.SliceStable(members, func(a, b int) bool { return order[members[a]] < order[members[b]] })
+		}
+		for pos, i := range members {
+			order[i] = float64(pos)
+		}
+	}
+
+	nodeGap, rankGap := defaultNodeSpacing, defaultRankSpacing
+	direction := models.LayoutDirectionTopBottom
+	if layout := diagram.Layout; layout != nil {
+		if layout.Direction != nil {
+			direction = *layout.Direction
+		}
+		if layout.Spacing != nil && layout.Spacing.Node != nil {
+			nodeGap = *layout.Spacing.Node
+		}
+		if layout.Spacing != nil && layout.Spacing.Rank != nil {
+			rankGap = *layout.Spacing.Rank
+		}
+	}
+	horizontal := direction == models.LayoutDirectionLeftRight || direction == models.LayoutDirectionRightLeft
+
+	// Along the rank axis every rank is as deep as its largest node; across
+	// it nodes are packed and each rank is centered on the widest one
+	rankDepth := make([]float64, len(ranks))
+	rankBreadth := make([]float64, len(ranks))
+	widest := 0.0
+	for r, members := range ranks {
+		for pos, i := range members {
+			_, _, w, h := nodeBounds(&diagram.Nodes[i])
+			depth, breadth := h, w
+			if horizontal {
+				depth, breadth = w, h
+			}
+			if depth > rankDepth[r] {
+				rankDepth[r] = depth
+			}
+			if pos > 0 {
+				rankBreadth[r] += nodeGap
+			}
+			rankBreadth[r] += breadth
+		}
+		if rankBreadth[r] > widest {
+			widest = rankBreadth[r]
+		}
+	}
+
+	offset := 0.0
+	totalDepth := 0.0
+	for r := range ranks {
+		totalDepth += rankDepth[r]
+		if r > 0 {
+			totalDepth += rankGap
+		}
+	}
+	for r, members := range ranks {
+		across := (widest - rankBreadth[r]) / 2
+		for _, i := range members {
+			node := &diagram.Nodes[i]
+			_, _, w, h := nodeBounds(node)
+			along := offset
+			switch direction {
+			case models.LayoutDirectionBottomTop, models.LayoutDirectionRightLeft:
+				along = totalDepth - offset - rankDepth[r]
+			}
+			if horizontal {
+				if !node.Pinned {
+					node.Position = models.Position{X: along + (rankDepth[r]-w)/2, Y: across}
+				}
+				across += h + nodeGap
+			} else {
+				if !node.Pinned {
+					node.Position = models.Position{X: across, Y: along + (rankDepth[r]-h)/2}
+				}
+				across += w + nodeGap
+			}
+		}
+		offset += rankDepth[r] + rankGap
+	}
+
+	for i := range diagram.Edges {
+		edge := &diagram.Edges[i]
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if okFrom && okTo && diagram.Nodes[from].Pinned && diagram.Nodes[to].Pinned {
+			continue
+		}
+		edge.Waypoints = nil
+	}
+}
+
+// rankNodes assigns each node, by index, the length of the longest path
+// that reaches it from a node without predecessors, ignoring edges that
+// close a loop. It also returns the index of each node ID and the
+// predecessors of each node along the remaining edges.
+func rankNodes(diagram *models.FlowDiagram) (map[string]int, []int, [][]int) {
+	index := make(map[string]int, len(diagram.Nodes))
+	for i, node := range diagram.Nodes {
+		index[node.ID] = i
+	}
+	outgoing := make([][]int, len(diagram.Nodes))
+	for _, edge := range diagram.Edges {
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if okFrom && okTo && from != to {
+			outgoing[from] = append(outgoing[from], to)
+		}
+	}
+
+	// Drop back edges found by a depth-first search so ranking sees a DAG
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make([]int, len(diagram.Nodes))
+	forward := make([][]int, len(diagram.Nodes))
+	var visit func(int)
+	visit = func(i int) {
+		state[i] = active
+		for _, j := range outgoing[i] {
+			switch state[j] {
+			case active:
+				continue
+			case unvisited:
+				visit(j)
+			}
+			forward[i] = append(forward[i], j)
+		}
+		state[i] = done
+	}
+	for _, id := range entryNodes(diagram) {
+		if state[index[id]] == unvisited {
+			visit(index[id])
+		}
+	}
+	for i := range diagram.Nodes {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+
+	// Longest-path ranking in topological order
+	incoming := make([]int, len(diagram.Nodes))
+	for i := range forward {
+		for _, j := range forward[i] {
+			incoming[j]++
+		}
+	}
+	var queue []int
+	for i := range diagram.Nodes {
+		if incoming[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	rank := make([]int, len(diagram.Nodes))
+	predecessors := make([][]int, len(diagram.Nodes))
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range forward[i] {
+			if rank[i]+1 > rank[j] {
+				rank[j] = rank[i] + 1
+			}
+			predecessors[j] = append(predecessors[j], i)
+			if incoming[j]--; incoming[j] == 0 {
+				queue = append(queue, j)
+			}
+		}
+	}
+	return index, rank, predecessors
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Scopes of legacy rules: the diagram itself, its nodes and its edges
+const (
+	legacyScopeDiagram = "diagram"
+	legacyScopeNode    = "node"
+	legacyScopeEdge    = "edge"
+)
+
+// legacyRule upgrades one deprecated field in the YAML of a diagram, node
+// or edge so that YAML written for older versions keeps loading. When the
+// model changes, add a rule here rather than breaking existing files.
+type legacyRule struct {
+	scope       string
+	field       string
+	replacement string
+	// upgrade rewrites the entity mapping in place; nil renames the key,
+	// dropping it when the replacement is already set
+	upgrade func(entity *yaml.Node, value *yaml.Node)
+}
+
+var legacyRules = []legacyRule{
+	{scope: legacyScopeEdge, field: "source", replacement: "from"},
+	{scope: legacyScopeEdge, field: "target", replacement: "to"},
+	{scope: legacyScopeNode, field: "size", replacement: "dimensions"},
+	{scope: legacyScopeNode, field: "layer", replacement: "layers", upgrade: upgradeSingleLayer},
+	{scope: legacyScopeEdge, field: "layer", replacement: "layers", upgrade: upgradeSingleLayer},
+}
+
+// legacyScopeTypes maps the Go types entities decode into to rule scopes
+var legacyScopeTypes = map[reflect.Type]string{
+	reflect.TypeOf(models.FlowDiagram{}): legacyScopeDiagram,
+	reflect.TypeOf(models.FlowNode{}):    legacyScopeNode,
+	reflect.TypeOf(models.FlowEdge{}):    legacyScopeEdge,
+}
+
+// findLegacyRule returns the rule for a deprecated key of an entity type
+func findLegacyRule(t reflect.Type, key string) (legacyRule, bool) {
+	scope, ok := legacyScopeTypes[t]
+	if !ok {
+		return legacyRule{}, false
+	}
+	for _, rule := range legacyRules {
+		if rule.scope == scope && rule.field == key {
+			return rule, true
+		}
+	}
+	return legacyRule{}, false
+}
+
+// upgradeLegacyFields rewrites deprecated fields of a parsed diagram to
+// their current form and returns where they were found. Diagram layers
+// given as plain IDs are upgraded to layer definitions as well.
+func upgradeLegacyFields(root *yaml.Node) []models.LegacyField {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var found []models.LegacyField
+	found = append(found, upgradeEntity(doc, legacyScopeDiagram, "")...)
+	found = append(found, upgradeLayerIDs(doc)...)
+	for _, list := range []struct{ key, scope string }{{"nodes", legacyScopeNode}, {"edges", legacyScopeEdge}} {
+		key, scope := list.key, list.scope
+		seq := mappingValue(doc, key)
+		if seq == nil || seq.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, item := range seq.Content {
+			if item.Kind == yaml.MappingNode {
+				found = append(found, upgradeEntity(item, scope, fmt.Sprintf("%s[%d].", key, i))...)
+			}
+		}
+	}
+	return found
+}
+
+// upgradeEntity applies the rules of a scope to one entity mapping
+func upgradeEntity(entity *yaml.Node, scope, prefix string) []models.LegacyField {
+	var found []models.LegacyField
+	for _, rule := range legacyRules {
+		if rule.scope != scope {
+			continue
+		}
+		index := mappingKeyIndex(entity, rule.field)
+		if index < 0 {
+			continue
+		}
+		found = append(found, models.LegacyField{Path: prefix + rule.field, Replacement: prefix + rule.replacement})
+		value := entity.Content[index+1]
+		entity.Content = append(entity.Content[:index], entity.Content[index+2:]...)
+		if rule.upgrade != nil {
+			rule.upgrade(entity, value)
+		} else if mappingValue(entity, rule.replacement) == nil {
+			entity.Content = append(entity.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: rule.replacement}, value)
+		}
+	}
+	return found
+}
+
+// upgradeSingleLayer turns a single `layer` into an entry of `layers`
+func upgradeSingleLayer(entity *yaml.Node, value *yaml.Node) {
+	if value.Kind != yaml.ScalarNode || value.Value == "" {
+		return
+	}
+	layers := mappingValue(entity, "layers")
+	if layers == nil {
+		layers = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		entity.Content = append(entity.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "layers"}, layers)
+	}
+	if layers.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, existing := range layers.Content {
+		if existing.Value == value.Value {
+			return
+		}
+	}
+	layers.Content = append(layers.Content, value)
+}
+
+// upgradeLayerIDs turns diagram layers given as plain IDs into layer
+// definitions named after their ID
+func upgradeLayerIDs(doc *yaml.Node) []models.LegacyField {
+	layers := mappingValue(doc, "layers")
+	if layers == nil || layers.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var found []models.LegacyField
+	for i, item := range layers.Content {
+		if item.Kind != yaml.ScalarNode {
+			continue
+		}
+		path := fmt.Sprintf("layers[%d]", i)
+		found = append(found, models.LegacyField{Path: path, Replacement: path + ".id"})
+		layers.Content[i] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "id"}, {Kind: yaml.ScalarNode, Tag: "!!str", Value: item.Value},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"}, {Kind: yaml.ScalarNode, Tag: "!!str", Value: item.Value},
+		}}
+	}
+	return found
+}
+
+// mappingKeyIndex returns the index of key in a mapping node's content, or
+// -1 when the key is missing
+func mappingKeyIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// validateLegacyFields warns about the deprecated fields a diagram was
+// loaded with
+func validateLegacyFields(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	for _, legacy := range diagram.Legacy {
+		result.Warnings = append(result.Warnings, models.ValidationError{
+			Path:    legacy.Path,
+			Message: fmt.Sprintf("Deprecated field; use %s (upgraded on save)", legacy.Replacement),
+			Code:    "DEPRECATED_FIELD",
+			Value:   legacy.Replacement,
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// localizedFields are the entity fields that may carry per-locale variants
+var localizedFields = []string{"name", "description"}
+
+// unmarshalDiagramYAML parses diagram YAML, accepting localized fields
+// written as locale maps (`name: {en: ..., de: ...}`) as well as plain strings
+func (s *DiagramService) unmarshalDiagramYAML(data []byte, diagram *models.FlowDiagram) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return newYAMLError(data, err)
+	}
+	if len(root.Content) == 0 {
+		return yaml.Unmarshal(data, diagram)
+	}
+	legacy := upgradeLegacyFields(&root)
+	for _, entity := range entityMappings(&root) {
+		expandLocalizedFields(entity, s.cfg.DefaultLocale)
+	}
+	if err := root.Decode(diagram); err != nil {
+		return newYAMLError(data, err)
+	}
+	diagram.Legacy = legacy
+	return nil
+}
+
+// entityMappings returns the YAML mappings of the diagram itself and of each
+// node and edge, i.e. every mapping that embeds a FlowEntity
+func entityMappings(root *yaml.Node) []*yaml.Node {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	entities := []*yaml.Node{doc}
+	for _, key := range []string{"nodes", "edges"} {
+		seq := mappingValue(doc, key)
+		if seq == nil || seq.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range seq.Content {
+			if item.Kind == yaml.MappingNode {
+				entities = append(entities, item)
+			}
+		}
+	}
+	return entities
+}
+
+// mappingValue returns the value node for key in a mapping node
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// expandLocalizedFields replaces locale maps on localized fields with the
+// default-locale string and moves the variants under `translations`
+func expandLocalizedFields(entity *yaml.Node, defaultLocale string) {
+	var translations *yaml.Node
+	for _, field := range localizedFields {
+		for i := 0; i+1 < len(entity.Content); i += 2 {
+			if entity.Content[i].Value != field || entity.Content[i+1].Kind != yaml.MappingNode {
+				continue
+			}
+			variants := entity.Content[i+1]
+			entity.Content[i+1] = &yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!!str",
+				Value: defaultVariant(variants, defaultLocale),
+			}
+
+			if translations == nil {
+				translations = mappingValue(entity, "translations")
+				if translations == nil || translations.Kind != yaml.MappingNode {
+					translations = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+					entity.Content = append(entity.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "translations"},
+						translations)
+				}
+			}
+			translations.Content = append(translations.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field},
+				variants)
+		}
+	}
+}
+
+// collapseLocalizedFields is the inverse of expandLocalizedFields: variants
+// under `translations` are written back as locale maps on their fields
+func collapseLocalizedFields(entity *yaml.Node, defaultLocale string) {
+	idx := -1
+	for i := 0; i+1 < len(entity.Content); i += 2 {
+		if entity.Content[i].Value == "translations" {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 || entity.Content[idx+1].Kind != yaml.MappingNode {
+		return
+	}
+	translations := entity.Content[idx+1]
+
+	for i := 0; i+1 < len(translations.Content); i += 2 {
+		field := translations.Content[i].Value
+		variants := translations.Content[i+1]
+		if variants.Kind != yaml.MappingNode {
+			continue
+		}
+		plain := mappingValue(entity, field)
+		if plain == nil {
+			// Variants of a field without plain text are kept as they are
+			entity.Content = append(entity.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field},
+				variants)
+			continue
+		}
+		// The plain value is the default-locale text, so edits of it win
+		// over the variant it was expanded from. Text expanded from another
+		// locale is left alone while unchanged, rather than being copied
+		// under the default locale.
+		if plain.Kind == yaml.ScalarNode {
+			if variant := localeVariant(variants, defaultLocale); variant != nil {
+				variant.Value = plain.Value
+			} else if fallback := defaultVariantNode(variants, defaultLocale); fallback == nil || fallback.Value != plain.Value {
+				variants.Content = append([]*yaml.Node{
+					{Kind: yaml.ScalarNode, Tag: "!!str", Value: defaultLocale},
+					{Kind: yaml.ScalarNode, Tag: "!!str", Value: plain.Value},
+				}, variants.Content...)
+			}
+		}
+		for j := 0; j+1 < len(entity.Content); j += 2 {
+			if entity.Content[j].Value == field {
+				entity.Content[j+1] = variants
+			}
+		}
+	}
+
+	entity.Content = append(entity.Content[:idx], entity.Content[idx+2:]...)
+}
+
+// defaultVariant picks the text used for the plain field: the default
+// locale, then English, then the first locale in sorted order
+func defaultVariant(variants *yaml.Node, defaultLocale string) string {
+	if v := defaultVariantNode(variants, defaultLocale); v != nil {
+		return v.Value
+	}
+	return ""
+}
+
+// defaultVariantNode returns the variant defaultVariant picks, or nil when
+// there are none
+func defaultVariantNode(variants *yaml.Node, defaultLocale string) *yaml.Node {
+	for _, locale := range []string{defaultLocale, "en"} {
+		if v := localeVariant(variants, locale); v != nil {
+			return v
+		}
+	}
+	var locales []string
+	for i := 0; i+1 < len(variants.Content); i += 2 {
+		locales = append(locales, variants.Content[i].Value)
+	}
+	if len(locales) == 0 {
+		return nil
+	}
+	sort.Strings(locales)
+	return mappingValue(variants, locales[0])
+}
+
+// localeVariant returns the variant for a locale, preferring an exact key
+// over the first key matching it case-insensitively
+func localeVariant(variants *yaml.Node, locale string) *yaml.Node {
+	if v := mappingValue(variants, locale); v != nil {
+		return v
+	}
+	for i := 0; i+1 < len(variants.Content); i += 2 {
+		if strings.EqualFold(variants.Content[i].Value, locale) {
+			return variants.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ResolveLocalized returns the best variant for lang. It tries the exact
+// tag (de-CH), then the base language (de), then the fallback locale,
+// each matched exactly before ignoring case.
+func ResolveLocalized(variants models.LocalizedText, lang, fallback string) (string, bool) {
+	if len(variants) == 0 {
+		return "", false
+	}
+	candidates := []string{lang}
+	if base, _, found := strings.Cut(lang, "-"); found {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, fallback)
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if text, ok := variants[candidate]; ok {
+			return text, true
+		}
+		// Keys differing only in case are tried in sorted order, so the
+		// same one wins every time
+		for _, locale := range sortedKeys(variants) {
+			if strings.EqualFold(locale, candidate) {
+				return variants[locale], true
+			}
+		}
+	}
+	return "", false
+}
+
+// LocalizeDiagram rewrites the names and descriptions of the diagram and its
+// elements to the requested language in place. Elements without a matching
+// variant keep their default text.
+func LocalizeDiagram(diagram *models.FlowDiagram, lang, fallback string) {
+	if lang == "" {
+		return
+	}
+	localizeEntity(&diagram.FlowEntity, lang, fallback)
+	for i := range diagram.Nodes {
+		localizeEntity(&diagram.Nodes[i].FlowEntity, lang, fallback)
+	}
+	for i := range diagram.Edges {
+		localizeEntity(&diagram.Edges[i].FlowEntity, lang, fallback)
+	}
+}
+
+func localizeEntity(entity *models.FlowEntity, lang, fallback string) {
+	if text, ok := ResolveLocalized(entity.Translations["name"], lang, fallback); ok {
+		entity.Name = text
+	}
+	if text, ok := ResolveLocalized(entity.Translations["description"], lang, fallback); ok {
+		entity.Description = &text
+	}
+}
+
+// Localize applies LocalizeDiagram using the configured default locale
+func (s *DiagramService) Localize(diagram *models.FlowDiagram, lang string) {
+	LocalizeDiagram(diagram, lang, s.cfg.DefaultLocale)
+}
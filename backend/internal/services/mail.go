@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+)
+
+// MailAttachment is a file attached to an outgoing message
+type MailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// MailMessage is an outgoing email. Text, HTML or both may be set.
+type MailMessage struct {
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []MailAttachment
+}
+
+// Mailer delivers mail through the configured SMTP server. Port 465 uses
+// implicit TLS; other ports upgrade with STARTTLS when the server offers it.
+type Mailer struct {
+	cfg *config.Config
+}
+
+// NewMailer creates a new mailer
+func NewMailer() *Mailer {
+	return &Mailer{cfg: config.Load()}
+}
+
+// Send delivers a message to its recipients
+func (m *Mailer) Send(msg MailMessage) error {
+	if m.cfg.SMTPHost == "" {
+		return fmt.Errorf("%w: set SMTP_HOST", ErrNotConfigured)
+	}
+	from, err := mail.ParseAddress(m.cfg.SMTPFrom)
+	if err != nil {
+		return fmt.Errorf("%w: invalid SMTP_FROM: %v", ErrNotConfigured, err)
+	}
+	if len(msg.To) == 0 {
+		return fmt.Errorf("%w: message has no recipients", ErrInvalidOptions)
+	}
+	recipients := make([]string, 0, len(msg.To))
+	for _, to := range msg.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("%w: invalid recipient %q", ErrInvalidOptions, to)
+		}
+		recipients = append(recipients, address.Address)
+	}
+
+	data, err := m.compose(from, msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := m.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if m.cfg.SMTPUsername != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.SMTPUsername, m.cfg.SMTPPassword, m.cfg.SMTPHost)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("SMTP server rejected recipient %s: %w", recipient, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return client.Quit()
+}
+
+func (m *Mailer) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.cfg.SMTPHost, strconv.Itoa(m.cfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: m.cfg.SMTPHost}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	if m.cfg.SMTPPort == 465 {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return smtp.NewClient(conn, m.cfg.SMTPHost)
+	}
+
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, m.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// compose builds the MIME message: the text and HTML bodies as
+// alternatives, followed by any attachments
+func (m *Mailer) compose(from *mail.Address, msg MailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(key, value string) { fmt.Fprintf(&buf, "%s: %s\r\n", key, value) }
+	header("From", from.String())
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/mixed; boundary="`+mixed.Boundary()+`"`)
+	buf.WriteString("\r\n")
+
+	var body bytes.Buffer
+	alternative := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64Lines(w, []byte(part.content))
+	}
+	alternative.Close()
+
+	w, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {`multipart/alternative; boundary="` + alternative.Boundary() + `"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Write(body.Bytes())
+
+	for _, attachment := range msg.Attachments {
+		w, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64Lines(w, attachment.Data)
+	}
+	mixed.Close()
+	return buf.Bytes(), nil
+}
+
+// writeBase64Lines writes data base64-encoded in 76-character lines
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%x@%s>", time.Now().UnixNano(), random, domain)
+}
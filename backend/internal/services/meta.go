@@ -0,0 +1,184 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// MetaSchema loads the configured workspace schema for diagram meta
+// sections. It returns nil without error when no schema is configured, in
+// which case meta sections are free-form.
+func (s *DiagramService) MetaSchema() (*models.MetaSchema, error) {
+	if s.cfg.MetaSchemaPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.cfg.MetaSchemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meta schema: %w", err)
+	}
+	var schema models.MetaSchema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse meta schema: %w", err)
+	}
+	if schema.Policy != "" && schema.Policy != models.MetaPolicyReject && schema.Policy != models.MetaPolicyWarn {
+		return nil, fmt.Errorf("invalid meta schema policy: %s", schema.Policy)
+	}
+	for name, field := range schema.Fields {
+		if field.Type != "" && !metaTypes[field.Type] {
+			return nil, fmt.Errorf("unknown type for meta field %s: %s", name, field.Type)
+		}
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern for meta field %s: %w", name, err)
+			}
+		}
+	}
+	return &schema, nil
+}
+
+var metaTypes = map[string]bool{
+	models.MetaTypeString: true, models.MetaTypeNumber: true, models.MetaTypeInteger: true,
+	models.MetaTypeBoolean: true, models.MetaTypeDate: true, models.MetaTypeList: true, models.MetaTypeObject: true,
+}
+
+// validateMeta checks a diagram's meta section against the schema. Under
+// the warn policy violations are reported as warnings so saving succeeds.
+func validateMeta(result *models.ValidationResult, meta map[string]interface{}, schema *models.MetaSchema) {
+	if schema == nil {
+		return
+	}
+	report := func(err models.ValidationError) {
+		if schema.Rejects() {
+			result.Errors = append(result.Errors, err)
+		} else {
+			result.Warnings = append(result.Warnings, err)
+		}
+	}
+
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := schema.Fields[name]
+		path := "meta." + name
+		value, ok := meta[name]
+		if !ok || value == nil || value == "" {
+			if field.Required {
+				report(models.ValidationError{
+					Path:    path,
+					Message: fmt.Sprintf("Meta field is required: %s", name),
+					Code:    "MISSING_META_FIELD",
+					Value:   name,
+				})
+			}
+			continue
+		}
+		if field.Type != "" && !metaTypeMatches(field.Type, value) {
+			report(models.ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("Meta field must be of type %s", field.Type),
+				Code:    "INVALID_META_TYPE",
+				Value:   field.Type,
+			})
+			continue
+		}
+		text, isString := value.(string)
+		if len(field.Enum) > 0 && isString && !containsValue(field.Enum, text) {
+			report(models.ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("Meta field value is not allowed: %s", text),
+				Code:    "INVALID_META_VALUE",
+				Value:   text,
+			})
+			continue
+		}
+		if field.Pattern != "" && isString && !regexp.MustCompile(field.Pattern).MatchString(text) {
+			report(models.ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("Meta field value is not allowed: %s", text),
+				Code:    "INVALID_META_VALUE",
+				Value:   text,
+			})
+		}
+	}
+
+	if schema.AllowAdditional != nil && !*schema.AllowAdditional {
+		keys := make([]string, 0, len(meta))
+		for key := range meta {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, ok := schema.Fields[key]; !ok {
+				report(models.ValidationError{
+					Path:    "meta." + key,
+					Message: fmt.Sprintf("Unknown meta field: %s", key),
+					Code:    "UNKNOWN_META_FIELD",
+					Value:   key,
+				})
+			}
+		}
+	}
+}
+
+// metaTypeMatches reports whether a decoded YAML or JSON value has the
+// given meta field type
+func metaTypeMatches(fieldType string, value interface{}) bool {
+	switch fieldType {
+	case models.MetaTypeString:
+		_, ok := value.(string)
+		return ok
+	case models.MetaTypeNumber:
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+	case models.MetaTypeInteger:
+		switch v := value.(type) {
+		case int, int64, uint64:
+			return true
+		case float64:
+			return v == float64(int64(v))
+		}
+	case models.MetaTypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case models.MetaTypeDate:
+		switch v := value.(type) {
+		case time.Time:
+			return true
+		case string:
+			// YAML dates round-trip through JSON as RFC 3339 timestamps
+			_, err := time.Parse("2006-01-02", v)
+			_, errTimestamp := time.Parse(time.RFC3339, v)
+			return err == nil || errTimestamp == nil
+		}
+	case models.MetaTypeList:
+		_, ok := value.([]interface{})
+		return ok
+	case models.MetaTypeObject:
+		switch value.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
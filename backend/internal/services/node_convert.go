@@ -0,0 +1,191 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// nodeTypeDimensions are the default sizes of node types whose shape
+// calls for other proportions than the default box
+var nodeTypeDimensions = map[models.NodeType]models.Dimensions{
+	models.NodeTypeDecision: {Width: 100, Height: 100},
+	models.NodeTypeStart:    {Width: 100, Height: 50},
+	models.NodeTypeEnd:      {Width: 100, Height: 50},
+}
+
+// NodeConversion is the outcome of changing a node's type
+type NodeConversion struct {
+	Diagram     models.FlowDiagram       `json:"diagram"`
+	Node        models.FlowNode          `json:"node"`
+	From        models.NodeType          `json:"from"`
+	Adjustments []string                 `json:"adjustments"` // What changed besides the type
+	Warnings    []models.ValidationError `json:"warnings"`    // Structural issues the new type raises
+	Validation  *models.ValidationResult `json:"validation,omitempty"`
+	Saved       bool                     `json:"saved"`
+}
+
+// ConvertNode changes the type of a node and adjusts what depends on it:
+// a node still at the default size of its old type gets the default size
+// of the new one, keeping its center, and fill and stroke left at the old
+// type's default colors are dropped so the new type's apply. Edges are not
+// changed; structural issues such as a decision with a single branch are
+// returned as warnings. The diagram is saved only when it validates, and
+// not at all with dryRun.
+func (s *DiagramService) ConvertNode(id, nodeID string, to models.NodeType, dryRun bool) (*NodeConversion, error) {
+	if !to.Valid() {
+		return nil, fmt.Errorf("%w: unknown node type %q", ErrInvalidOptions, to)
+	}
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	node := findNode(diagram, nodeID)
+	if node == nil {
+		return nil, fmt.Errorf("%w: node %s not found", ErrInvalidOptions, nodeID)
+	}
+	from := node.Type
+	if from == to {
+		return nil, fmt.Errorf("%w: node %s is already of type %s", ErrInvalidOptions, nodeID, to)
+	}
+
+	result := &NodeConversion{From: from, Adjustments: []string{}}
+	node.Type = to
+	if adjustNodeDimensions(node, from, to) {
+		result.Adjustments = append(result.Adjustments, "dimensions")
+	}
+	if adjustNodeColors(node, from) {
+		result.Adjustments = append(result.Adjustments, "style")
+	}
+	result.Warnings = conversionWarnings(diagram, node)
+	result.Node = *node
+
+	validation, err := s.Validate(diagram)
+	if err != nil {
+		return nil, err
+	}
+	if !validation.Valid {
+		result.Diagram, result.Validation = *diagram, validation
+		return result, nil
+	}
+	if dryRun {
+		result.Diagram = *diagram
+		return result, nil
+	}
+	updated, err := s.Update(diagram)
+	if err != nil {
+		return nil, err
+	}
+	result.Diagram, result.Saved = *updated, true
+	if converted := findNode(updated, nodeID); converted != nil {
+		result.Node = *converted
+	}
+	return result, nil
+}
+
+// typeDimensions returns the default size of a node type
+func typeDimensions(t models.NodeType) models.Dimensions {
+	if dimensions, ok := nodeTypeDimensions[t]; ok {
+		return dimensions
+	}
+	return models.Dimensions{Width: defaultNodeWidth, Height: defaultNodeHeight}
+}
+
+// adjustNodeDimensions resizes a node without a size, or left at its old
+// type's default size, to the new type's default size around the same
+// center, and reports whether it did
+func adjustNodeDimensions(node *models.FlowNode, from, to models.NodeType) bool {
+	_, _, w, h := nodeBounds(node)
+	if node.Dimensions != nil && *node.Dimensions != typeDimensions(from) {
+		return false
+	}
+	size := typeDimensions(to)
+	if size.Width == w && size.Height == h {
+		return false
+	}
+	node.Position.X += (w - size.Width) / 2
+	node.Position.Y += (h - size.Height) / 2
+	if _, ok := nodeTypeDimensions[to]; ok {
+		node.Dimensions = &size
+	} else {
+		node.Dimensions = nil
+	}
+	return true
+}
+
+// adjustNodeColors drops a fill and stroke equal to the old type's default
+// colors and reports whether it did
+func adjustNodeColors(node *models.FlowNode, from models.NodeType) bool {
+	if node.Style == nil {
+		return false
+	}
+	fill, stroke := nodeColors(from)
+	style := *node.Style
+	if style.Fill != nil && *style.Fill == fill {
+		style.Fill = nil
+	}
+	if style.Stroke != nil && *style.Stroke == stroke {
+		style.Stroke = nil
+	}
+	if sameJSON(style, *node.Style) {
+		return false
+	}
+	if sameJSON(style, models.Style{}) {
+		node.Style = nil
+	} else {
+		node.Style = &style
+	}
+	return true
+}
+
+// conversionWarnings lists structural issues of a node's edges under its
+// new type, for the user to resolve
+func conversionWarnings(diagram *models.FlowDiagram, node *models.FlowNode) []models.ValidationError {
+	warnings := []models.ValidationError{}
+	path := ""
+	for i := range diagram.Nodes {
+		if diagram.Nodes[i].ID == node.ID {
+			path = fmt.Sprintf("nodes[%d]", i)
+		}
+	}
+	warn := func(code, message string) {
+		warnings = append(warnings, models.ValidationError{Path: path, Message: message, Code: code, Value: node.ID})
+	}
+
+	var incoming, outgoing int
+	var conditional []string
+	for _, edge := range diagram.Edges {
+		if edge.To == node.ID {
+			incoming++
+		}
+		if edge.From == node.ID {
+			outgoing++
+			if edge.Type == models.ConnectionTypeConditional {
+				conditional = append(conditional, edge.ID)
+			}
+		}
+	}
+
+	switch node.Type {
+	case models.NodeTypeDecision:
+		if outgoing < 2 {
+			warn("DECISION_SINGLE_BRANCH", fmt.Sprintf("Decision %s has %d outgoing branches; add at least two", node.ID, outgoing))
+		}
+	case models.NodeTypeStart:
+		if incoming > 0 {
+			warn("START_HAS_INCOMING", fmt.Sprintf("Start node %s has %d incoming edges", node.ID, incoming))
+		}
+	case models.NodeTypeEnd:
+		if outgoing > 0 {
+			warn("END_HAS_OUTGOING", fmt.Sprintf("End node %s has %d outgoing edges", node.ID, outgoing))
+		}
+	case models.NodeTypeSubprocess:
+		if node.DrillDown == nil {
+			warn("SUBPROCESS_WITHOUT_DRILL_DOWN", fmt.Sprintf("Subprocess %s does not drill down into a diagram", node.ID))
+		}
+	}
+	if node.Type != models.NodeTypeDecision && len(conditional) > 0 {
+		warn("CONDITIONAL_WITHOUT_DECISION", fmt.Sprintf("Node %s is no longer a decision but has conditional edges: %v", node.ID, conditional))
+	}
+	return warnings
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// notificationTemplates are the built-in message templates. The first line
+// is the subject; the body follows a blank line. A file named <event>.tmpl
+// in NOTIFICATION_TEMPLATES_PATH replaces the built-in template.
+var notificationTemplates = map[string]string{
+	models.EventDiagramChanged: `Subject: {{.Diagram.Name}} was updated
+
+Hello {{.Person.Name}},
+
+the diagram "{{.Diagram.Name}}" ({{.Diagram.ID}}) you follow was updated on {{.Time.Format "2006-01-02 15:04 MST"}}.
+{{if .Message}}
+{{.Message}}
+{{end}}`,
+	models.EventDiagramDeleted: `Subject: {{.Diagram.Name}} was deleted
+
+Hello {{.Person.Name}},
+
+the diagram "{{.Diagram.Name}}" ({{.Diagram.ID}}) you follow was deleted on {{.Time.Format "2006-01-02 15:04 MST"}}.
+{{if .Message}}
+{{.Message}}
+{{end}}`,
+	models.EventReviewRequested: `Subject: Review requested: {{.Diagram.Name}}
+
+Hello {{.Person.Name}},
+
+{{if .Actor}}{{.Actor}} asks{{else}}You are asked{{end}} for your review of the diagram "{{.Diagram.Name}}" ({{.Diagram.ID}}).
+{{if .Message}}
+{{.Message}}
+{{end}}`,
+	models.EventStaleDiagram: `Subject: {{len .Diagrams}} of your diagrams may be out of date
+
+Hello {{.Person.Name}},
+
+these diagrams you own have not been updated for a while:
+{{range .Diagrams}}
+- {{.Name}} ({{.ID}}), last updated {{.Updated.Format "2006-01-02"}}{{end}}
+
+Please check that they still describe the process, and save them to confirm.
+`,
+	models.EventConsistencyChanged: `Subject: Validation errors changed in {{len .Changes}} of your diagrams
+
+Hello {{.Person.Name}},
+
+the consistency check on {{.Time.Format "2006-01-02 15:04 MST"}} found a different number of errors in these diagrams:
+{{range .Changes}}
+- {{.Name}} ({{.DiagramID}}): {{.Before}} before, {{.After}} now{{end}}
+`,
+	models.EventDiagramUnreadable: `Subject: {{.Diagram.Name}} can no longer be read
+
+Hello {{.Person.Name}},
+
+the file of the diagram "{{.Diagram.Name}}" ({{.Diagram.ID}}) you own or follow stopped parsing on {{.Time.Format "2006-01-02 15:04 MST"}}:
+
+{{.Message}}
+
+Until the file is fixed, the diagram is left out of lists and searches.
+`,
+}
+
+// NotificationData is passed to notification templates
+type NotificationData struct {
+	Event    string
+	Person   models.Person
+	Diagram  *models.FlowDiagram  // Subject of diagram events and review requests
+	Diagrams []models.FlowDiagram // Stale diagrams
+	Changes  []ConsistencyChange  // Diagrams whose error count changed
+	Actor    string               // Name of whoever caused the notification, if known
+	Message  string
+	Time     time.Time
+}
+
+// ReviewRequest asks people or teams to review a diagram
+type ReviewRequest struct {
+	Reviewers   []string // Person or team IDs
+	RequestedBy string   // Person ID
+	Message     string
+}
+
+// NotificationService sends templated email notifications to people in
+// the directory, honoring their notification preferences
+type NotificationService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+	preferences    *PreferencesService
+	mailer         *Mailer
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService() *NotificationService {
+	return &NotificationService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+		preferences:    NewPreferencesService(),
+		mailer:         NewMailer(),
+	}
+}
+
+func findPerson(directory *models.Directory, id string) *models.Person {
+	for i := range directory.People {
+		if directory.People[i].ID == id {
+			return &directory.People[i]
+		}
+	}
+	return nil
+}
+
+// expandRecipients resolves team IDs to their members and drops IDs that
+// name nobody in the directory. Order is kept and duplicates removed.
+func expandRecipients(directory *models.Directory, ids []string) []string {
+	seen := make(map[string]bool)
+	people := []string{}
+	add := func(id string) {
+		if !seen[id] && findPerson(directory, id) != nil {
+			seen[id] = true
+			people = append(people, id)
+		}
+	}
+	for _, id := range ids {
+		add(id)
+		for _, team := range directory.Teams {
+			if team.ID == id {
+				for _, member := range team.Members {
+					add(member)
+				}
+			}
+		}
+	}
+	return people
+}
+
+// SaveNotificationPreferences replaces a person's notification preferences
+// and keeps their other preferences
+func (s *NotificationService) SaveNotificationPreferences(personID string, notifications models.NotificationPreferences) (*models.UserPreferences, error) {
+	if err := s.preferences.checkNotifications(&notifications); err != nil {
+		return nil, err
+	}
+	return s.preferences.update(personID, func(preferences *models.UserPreferences) {
+		preferences.Notifications = notifications
+	})
+}
+
+// Subscribers lists the people subscribed to a diagram
+func (s *NotificationService) Subscribers(diagramID string) ([]string, error) {
+	directory, err := requireDirectory(s.diagramService)
+	if err != nil {
+		return nil, err
+	}
+	subscribers := []string{}
+	for _, person := range directory.People {
+		preferences, err := s.preferences.load(person.ID)
+		if err != nil {
+			return nil, err
+		}
+		if containsValue(preferences.Notifications.Subscriptions, diagramID) {
+			subscribers = append(subscribers, person.ID)
+		}
+	}
+	return subscribers, nil
+}
+
+// Subscribe sends a person notifications about changes to a diagram
+func (s *NotificationService) Subscribe(diagramID, personID string) error {
+	if _, err := s.diagramService.GetByID(diagramID); err != nil {
+		return err
+	}
+	_, err := s.preferences.update(personID, func(preferences *models.UserPreferences) {
+		if !containsValue(preferences.Notifications.Subscriptions, diagramID) {
+			preferences.Notifications.Subscriptions = append(preferences.Notifications.Subscriptions, diagramID)
+		}
+	})
+	return err
+}
+
+// Unsubscribe stops notifications about a diagram for a person
+func (s *NotificationService) Unsubscribe(diagramID, personID string) error {
+	_, err := s.preferences.update(personID, func(preferences *models.UserPreferences) {
+		subscriptions := []string{}
+		for _, id := range preferences.Notifications.Subscriptions {
+			if id != diagramID {
+				subscriptions = append(subscriptions, id)
+			}
+		}
+		preferences.Notifications.Subscriptions = subscriptions
+	})
+	return err
+}
+
+// Notify mails an event to people and teams, skipping people who opted
+// out of the event or have no email address. It returns the IDs of the
+// people notified.
+func (s *NotificationService) Notify(event string, recipients []string, data NotificationData) ([]string, error) {
+	directory, err := requireDirectory(s.diagramService)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.SMTPHost == "" {
+		return nil, fmt.Errorf("%w: set SMTP_HOST", ErrNotConfigured)
+	}
+	tmpl, err := s.template(event)
+	if err != nil {
+		return nil, err
+	}
+
+	notified := []string{}
+	var errs []error
+	for _, id := range expandRecipients(directory, recipients) {
+		person := findPerson(directory, id)
+		preferences, err := s.preferences.load(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if person.Email == nil || !preferences.Notifications.Email || !containsValue(preferences.Notifications.Events, event) {
+			continue
+		}
+
+		data.Event, data.Person = event, *person
+		if data.Time.IsZero() {
+			data.Time = time.Now()
+		}
+		subject, body, err := renderNotification(tmpl, data)
+		if err != nil {
+			return notified, err
+		}
+		msg := MailMessage{To: []string{(&mail.Address{Name: person.Name, Address: *person.Email}).String()}, Subject: subject, Text: body}
+		if err := s.mailer.Send(msg); err != nil {
+			errs = append(errs, fmt.Errorf("notifying %s: %w", id, err))
+			continue
+		}
+		notified = append(notified, id)
+	}
+	return notified, errors.Join(errs...)
+}
+
+// template returns the message template for an event, preferring an
+// override file when one exists
+func (s *NotificationService) template(event string) (*template.Template, error) {
+	text, ok := notificationTemplates[event]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown event %s", ErrInvalidOptions, event)
+	}
+	if s.cfg.NotificationTemplatesPath != "" {
+		data, err := os.ReadFile(filepath.Join(s.cfg.NotificationTemplatesPath, event+".tmpl"))
+		if err == nil {
+			text = string(data)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read notification template: %w", err)
+		}
+	}
+	tmpl, err := template.New(event).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template %s: %w", event, err)
+	}
+	return tmpl, nil
+}
+
+// renderNotification executes a template and splits off its subject line
+func renderNotification(tmpl *template.Template, data NotificationData) (string, string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("failed to render notification: %w", err)
+	}
+	first, body, _ := strings.Cut(buf.String(), "\n")
+	subject, ok := strings.CutPrefix(first, "Subject:")
+	if !ok {
+		return "", "", fmt.Errorf("notification template %s must start with a Subject: line", tmpl.Name())
+	}
+	return strings.TrimSpace(subject), strings.TrimLeft(body, "\r\n"), nil
+}
+
+// RequestReview notifies reviewers that a diagram awaits their review
+func (s *NotificationService) RequestReview(diagramID string, request ReviewRequest) ([]string, error) {
+	diagram, err := s.diagramService.GetByID(diagramID)
+	if err != nil {
+		return nil, err
+	}
+	directory, err := requireDirectory(s.diagramService)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Reviewers) == 0 {
+		return nil, fmt.Errorf("%w: no reviewers", ErrInvalidOptions)
+	}
+	for _, id := range request.Reviewers {
+		if !directory.Has(id) {
+			return nil, fmt.Errorf("%w: unknown reviewer %s", ErrInvalidOptions, id)
+		}
+	}
+	data := NotificationData{Diagram: diagram, Message: request.Message}
+	if request.RequestedBy != "" {
+		person := findPerson(directory, request.RequestedBy)
+		if person == nil {
+			return nil, fmt.Errorf("%w: unknown person %s", ErrInvalidOptions, request.RequestedBy)
+		}
+		data.Actor = person.Name
+	}
+	return s.Notify(models.EventReviewRequested, request.Reviewers, data)
+}
+
+// SendStaleReminders mails the owners of diagrams not updated within
+// STALE_DIAGRAM_AGE one message each listing their stale diagrams.
+// Deprecated diagrams are not included.
+func (s *NotificationService) SendStaleReminders() ([]string, error) {
+	if s.cfg.StaleAfter == 0 {
+		return nil, fmt.Errorf("%w: set STALE_DIAGRAM_AGE", ErrNotConfigured)
+	}
+	directory, err := requireDirectory(s.diagramService)
+	if err != nil {
+		return nil, err
+	}
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-s.cfg.StaleAfter)
+	stale := make(map[string][]models.FlowDiagram)
+	for _, diagram := range diagrams {
+		updated := diagram.Updated
+		if updated.IsZero() {
+			updated = diagram.Created
+		}
+		if updated.IsZero() || updated.After(cutoff) || diagram.Deprecated != nil {
+			continue
+		}
+		owners := append(diagram.Ownership.Assignments(models.RoleOwner), diagram.Ownership.Assignments(models.RoleAccountable)...)
+		for _, id := range expandRecipients(directory, owners) {
+			stale[id] = append(stale[id], diagram)
+		}
+	}
+
+	people := make([]string, 0, len(stale))
+	for id := range stale {
+		people = append(people, id)
+	}
+	sort.Strings(people)
+	notified := []string{}
+	var errs []error
+	for _, id := range people {
+		sent, err := s.Notify(models.EventStaleDiagram, []string{id}, NotificationData{Diagrams: stale[id]})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		notified = append(notified, sent...)
+	}
+	return notified, errors.Join(errs...)
+}
+
+// notifyDiagramChange mails the subscribers of a diagram that was saved or
+// deleted through the API. Like gitSyncAfterSave it runs in the
+// background, and does nothing unless SMTP and a directory are configured.
+// The change summary, if any, is the message.
+func notifyDiagramChange(cfg *config.Config, diagram *models.FlowDiagram, event, summary string) {
+	if cfg.SMTPHost == "" || cfg.DirectoryPath == "" {
+		return
+	}
+	snapshot := *diagram
+	go func() {
+		s := NewNotificationService()
+		subscribers, err := s.Subscribers(snapshot.ID)
+		if err != nil || len(subscribers) == 0 {
+			return
+		}
+		if _, err := s.Notify(event, subscribers, NotificationData{Diagram: &snapshot, Message: summary}); err != nil {
+			log.Printf("Notifying subscribers of %s failed: %v", snapshot.ID, err)
+		}
+	}()
+}
+
+// notifyDiagramUnreadable mails the owners and subscribers of a diagram
+// whose file stopped parsing, in the background like notifyDiagramChange
+func notifyDiagramUnreadable(cfg *config.Config, diagram *models.FlowDiagram, loadErr error) {
+	if cfg.SMTPHost == "" || cfg.DirectoryPath == "" {
+		return
+	}
+	snapshot := *diagram
+	go func() {
+		s := NewNotificationService()
+		recipients := append(snapshot.Ownership.Assignments(models.RoleOwner), snapshot.Ownership.Assignments(models.RoleAccountable)...)
+		if subscribers, err := s.Subscribers(snapshot.ID); err == nil {
+			recipients = append(recipients, subscribers...)
+		}
+		if len(recipients) == 0 {
+			return
+		}
+		data := NotificationData{Diagram: &snapshot, Message: loadErr.Error()}
+		if _, err := s.Notify(models.EventDiagramUnreadable, recipients, data); err != nil {
+			log.Printf("Notifying about unreadable diagram %s failed: %v", snapshot.ID, err)
+		}
+	}()
+}
+
+// StartStaleReminders sends stale-diagram reminders on the
+// STALE_REMINDER_SCHEDULE cron schedule when STALE_DIAGRAM_AGE is set
+func StartStaleReminders() {
+	s := NewNotificationService()
+	if s.cfg.StaleAfter == 0 {
+		return
+	}
+	schedule, err := parseCron(s.cfg.StaleSchedule)
+	if err != nil {
+		log.Printf("Stale reminders disabled: %v", err)
+		return
+	}
+	log.Printf("Stale reminders for diagrams older than %s on schedule %q", s.cfg.StaleAfter, s.cfg.StaleSchedule)
+
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			if !schedule.Matches(time.Now()) {
+				continue
+			}
+			if _, err := NewNotificationService().SendStaleReminders(); err != nil {
+				log.Printf("Stale reminders failed: %v", err)
+			}
+		}
+	}()
+}
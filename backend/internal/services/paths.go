@@ -0,0 +1,105 @@
+package services
+
+import "github.com/michaellanpart/flowgen/backend/internal/models"
+
+// maxFlowPaths caps path enumeration; branchy diagrams grow exponentially
+const maxFlowPaths = 1000
+
+// flowPath is one route through a diagram from an entry to an exit node
+type flowPath struct {
+	Nodes []*models.FlowNode
+	Edges []*models.FlowEdge
+}
+
+// NodeIDs returns the IDs of the nodes along the path
+func (p flowPath) NodeIDs() []string {
+	ids := make([]string, len(p.Nodes))
+	for i, node := range p.Nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
+
+// entryNodes returns the IDs of the start nodes, or of the nodes without
+// incoming edges when the diagram has no start node
+func entryNodes(diagram *models.FlowDiagram) []string {
+	var roots []string
+	for _, node := range diagram.Nodes {
+		if node.Type == models.NodeTypeStart {
+			roots = append(roots, node.ID)
+		}
+	}
+	if len(roots) > 0 {
+		return roots
+	}
+
+	incoming := make(map[string]int)
+	for _, edge := range diagram.Edges {
+		incoming[edge.To]++
+	}
+	for _, node := range diagram.Nodes {
+		if incoming[node.ID] == 0 {
+			roots = append(roots, node.ID)
+		}
+	}
+	return roots
+}
+
+// enumeratePaths lists the simple paths from each entry node to a node
+// without outgoing edges. Loops are followed once: an edge back to a node
+// already on the path ends that branch. truncated reports that the
+// maxFlowPaths limit was hit.
+func enumeratePaths(diagram *models.FlowDiagram) (paths []flowPath, truncated bool) {
+	nodes := make(map[string]*models.FlowNode)
+	for i := range diagram.Nodes {
+		nodes[diagram.Nodes[i].ID] = &diagram.Nodes[i]
+	}
+	outgoing := make(map[string][]*models.FlowEdge)
+	for i := range diagram.Edges {
+		edge := &diagram.Edges[i]
+		if nodes[edge.From] != nil && nodes[edge.To] != nil {
+			outgoing[edge.From] = append(outgoing[edge.From], edge)
+		}
+	}
+
+	onPath := make(map[string]bool)
+	var current flowPath
+	var walk func(id string)
+	walk = func(id string) {
+		if truncated {
+			return
+		}
+		current.Nodes = append(current.Nodes, nodes[id])
+		onPath[id] = true
+		defer func() {
+			current.Nodes = current.Nodes[:len(current.Nodes)-1]
+			onPath[id] = false
+		}()
+
+		extended := false
+		for _, edge := range outgoing[id] {
+			if onPath[edge.To] {
+				continue
+			}
+			extended = true
+			current.Edges = append(current.Edges, edge)
+			walk(edge.To)
+			current.Edges = current.Edges[:len(current.Edges)-1]
+		}
+		if !extended {
+			if len(paths) >= maxFlowPaths {
+				truncated = true
+				return
+			}
+			paths = append(paths, flowPath{
+				Nodes: append([]*models.FlowNode{}, current.Nodes...),
+				Edges: append([]*models.FlowEdge{}, current.Edges...),
+			})
+		}
+	}
+
+	for _, id := range entryNodes(diagram) {
+		walk(id)
+	}
+	return paths, truncated
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A minimal PDF writer: enough to draw vector shapes and text with the
+// standard Helvetica fonts, without pulling in a PDF dependency.
+
+// Paper sizes in points (portrait)
+var paperSizes = map[string][2]float64{
+	"a4":     {595.28, 841.89},
+	"a3":     {841.89, 1190.55},
+	"letter": {612, 792},
+	"legal":  {612, 1008},
+}
+
+// paperSize returns the page size in points for a paper name and orientation
+func paperSize(name string, landscape bool) (float64, float64, error) {
+	if name == "" {
+		name = "a4"
+	}
+	size, ok := paperSizes[strings.ToLower(name)]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown paper size: %s", name)
+	}
+	if landscape {
+		return size[1], size[0], nil
+	}
+	return size[0], size[1], nil
+}
+
+type pdfPage struct {
+	width, height float64
+	content       bytes.Buffer
+	links         []pdfLink
+}
+
+// pdfLink is a clickable rectangle that jumps to another page
+type pdfLink struct {
+	x, y, w, h float64
+	page       int
+}
+
+type pdfDocument struct {
+	title string
+	pages []*pdfPage
+}
+
+func newPDFDocument(title string) *pdfDocument {
+	return &pdfDocument{title: title}
+}
+
+// AddPage appends a page and returns a canvas for drawing on it
+func (d *pdfDocument) AddPage(width, height float64) *pdfCanvas {
+	page := &pdfPage{width: width, height: height}
+	d.pages = append(d.pages, page)
+	return &pdfCanvas{page: page}
+}
+
+// Bytes serializes the document
+func (d *pdfDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	writeObj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Fixed objects: 1 catalog, 2 page tree, 3 regular font, 4 bold font,
+	// 5 document info. Pages start at object 6, each followed by its content.
+	pageObj := func(i int) int { return 6 + 2*i }
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj(i))
+	}
+
+	writeObj("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	writeObj(fmt.Sprintf("<< /Title %s /Producer (FlowGen) >>", pdfString(d.title)))
+
+	for i, page := range d.pages {
+		annots := ""
+		if len(page.links) > 0 {
+			var parts []string
+			for _, link := range page.links {
+				if link.page < 0 || link.page >= len(d.pages) {
+					continue
+				}
+				target := d.pages[link.page]
+				parts = append(parts, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Border [0 0 0] /Rect [%s %s %s %s] /Dest [%d 0 R /XYZ 0 %s null] >>",
+					pdfNum(link.x), pdfNum(page.height-link.y-link.h), pdfNum(link.x+link.w), pdfNum(page.height-link.y),
+					pageObj(link.page), pdfNum(target.height)))
+			}
+			annots = " /Annots [" + strings.Join(parts, " ") + "]"
+		}
+		writeObj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R%s >>",
+			pdfNum(page.width), pdfNum(page.height), pageObj(i)+1, annots))
+		writeObj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfCanvas draws on a page using a top-left origin in points
+type pdfCanvas struct {
+	page *pdfPage
+}
+
+func (c *pdfCanvas) op(format string, args ...interface{}) {
+	fmt.Fprintf(&c.page.content, format+"\n", args...)
+}
+
+func (c *pdfCanvas) y(y float64) float64 {
+	return c.page.height - y
+}
+
+// Save pushes the graphics state
+func (c *pdfCanvas) Save() { c.op("q") }
+
+// Restore pops the graphics state
+func (c *pdfCanvas) Restore() { c.op("Q") }
+
+// Clip restricts drawing to a rectangle until the state is restored
+func (c *pdfCanvas) Clip(x, y, w, h float64) {
+	c.op("%s %s %s %s re W n", pdfNum(x), pdfNum(c.y(y+h)), pdfNum(w), pdfNum(h))
+}
+
+// SetFill sets the fill color from a CSS hex color
+func (c *pdfCanvas) SetFill(color string) {
+	r, g, b := parseHexColor(color, 1, 1, 1)
+	c.op("%s %s %s rg", pdfNum(r), pdfNum(g), pdfNum(b))
+}
+
+// SetStroke sets the stroke color from a CSS hex color
+func (c *pdfCanvas) SetStroke(color string) {
+	r, g, b := parseHexColor(color, 0, 0, 0)
+	c.op("%s %s %s RG", pdfNum(r), pdfNum(g), pdfNum(b))
+}
+
+// SetLineWidth sets the stroke width
+func (c *pdfCanvas) SetLineWidth(w float64) { c.op("%s w", pdfNum(w)) }
+
+// SetDash sets a dash pattern given in SVG dasharray syntax; empty is solid
+func (c *pdfCanvas) SetDash(dasharray string) {
+	var parts []string
+	for _, f := range strings.FieldsFunc(dasharray, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if v, err := strconv.ParseFloat(f, 64); err == nil {
+			parts = append(parts, pdfNum(v))
+		}
+	}
+	c.op("[%s] 0 d", strings.Join(parts, " "))
+}
+
+// Rect draws a rectangle; mode is "f" (fill), "S" (stroke) or "B" (both)
+func (c *pdfCanvas) Rect(x, y, w, h float64, mode string) {
+	c.op("%s %s %s %s re %s", pdfNum(x), pdfNum(c.y(y+h)), pdfNum(w), pdfNum(h), mode)
+}
+
+// Polygon draws a closed polygon
+func (c *pdfCanvas) Polygon(points [][2]float64, mode string) {
+	for i, p := range points {
+		if i == 0 {
+			c.op("%s %s m", pdfNum(p[0]), pdfNum(c.y(p[1])))
+		} else {
+			c.op("%s %s l", pdfNum(p[0]), pdfNum(c.y(p[1])))
+		}
+	}
+	c.op("h %s", mode)
+}
+
+// Polyline strokes an open path
+func (c *pdfCanvas) Polyline(points [][2]float64) {
+	for i, p := range points {
+		if i == 0 {
+			c.op("%s %s m", pdfNum(p[0]), pdfNum(c.y(p[1])))
+		} else {
+			c.op("%s %s l", pdfNum(p[0]), pdfNum(c.y(p[1])))
+		}
+	}
+	c.op("S")
+}
+
+// Ellipse draws an ellipse inscribed in the given rectangle
+func (c *pdfCanvas) Ellipse(x, y, w, h float64, mode string) {
+	const kappa = 0.5522847498
+	cx, cy := x+w/2, c.y(y+h/2)
+	rx, ry := w/2, h/2
+	ox, oy := rx*kappa, ry*kappa
+	c.op("%s %s m", pdfNum(cx-rx), pdfNum(cy))
+	c.op("%s %s %s %s %s %s c", pdfNum(cx-rx), pdfNum(cy+oy), pdfNum(cx-ox), pdfNum(cy+ry), pdfNum(cx), pdfNum(cy+ry))
+	c.op("%s %s %s %s %s %s c", pdfNum(cx+ox), pdfNum(cy+ry), pdfNum(cx+rx), pdfNum(cy+oy), pdfNum(cx+rx), pdfNum(cy))
+	c.op("%s %s %s %s %s %s c", pdfNum(cx+rx), pdfNum(cy-oy), pdfNum(cx+ox), pdfNum(cy-ry), pdfNum(cx), pdfNum(cy-ry))
+	c.op("%s %s %s %s %s %s c", pdfNum(cx-ox), pdfNum(cy-ry), pdfNum(cx-rx), pdfNum(cy-oy), pdfNum(cx-rx), pdfNum(cy))
+	c.op("h %s", mode)
+}
+
+// Text draws text with its baseline starting at x, y. align is "left",
+// "center" or "right".
+func (c *pdfCanvas) Text(x, y, size float64, bold bool, align, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	switch align {
+	case "center":
+		x -= textWidth(text, size) / 2
+	case "right":
+		x -= textWidth(text, size)
+	}
+	c.op("BT /%s %s Tf %s %s Td %s Tj ET", font, pdfNum(size), pdfNum(x), pdfNum(c.y(y)), pdfString(text))
+}
+
+// RotatedText draws text centered on x, y and rotated counter-clockwise by
+// angle degrees. dy moves the line down in the rotated frame.
+func (c *pdfCanvas) RotatedText(x, y, size, angle, dy float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	c.op("q %s %s %s %s %s %s cm", pdfNum(cos), pdfNum(sin), pdfNum(-sin), pdfNum(cos), pdfNum(x), pdfNum(c.y(y)))
+	c.op("BT /%s %s Tf %s %s Td %s Tj ET", font, pdfNum(size), pdfNum(-textWidth(text, size)/2), pdfNum(-dy), pdfString(text))
+	c.op("Q")
+}
+
+// Link makes a rectangle on the page jump to another page when clicked
+func (c *pdfCanvas) Link(x, y, w, h float64, page int) {
+	c.page.links = append(c.page.links, pdfLink{x: x, y: y, w: w, h: h, page: page})
+}
+
+// textWidth estimates the width of Helvetica text
+func textWidth(text string, size float64) float64 {
+	return float64(len([]rune(text))) * size * 0.52
+}
+
+func pdfNum(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(strconv.FormatFloat(v, 'f', 2, 64), "0"), ".")
+}
+
+// pdfString encodes text as a PDF literal string in WinAnsi encoding
+func pdfString(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20:
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// parseHexColor converts #rgb or #rrggbb into 0-1 components, falling back
+// to the given default for anything else
+func parseHexColor(color string, dr, dg, db float64) (float64, float64, float64) {
+	rgb, ok := hexColor(color)
+	if !ok {
+		return dr, dg, db
+	}
+	return rgb[0], rgb[1], rgb[2]
+}
+
+// roundUp returns ceil(v) as an int, treating tiny overshoots as exact
+func roundUp(v float64) int {
+	return int(math.Ceil(v - 1e-9))
+}
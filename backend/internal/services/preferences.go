@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// preferencesMu serializes read-modify-write cycles on preference files
+var preferencesMu sync.Mutex
+
+// PreferencesService stores per-person settings as <id>.yaml files below
+// PREFERENCES_PATH. People are identified by their directory ID.
+type PreferencesService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+}
+
+// NewPreferencesService creates a new preferences service
+func NewPreferencesService() *PreferencesService {
+	return &PreferencesService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+	}
+}
+
+// requireDirectory loads the directory, failing when none is configured
+func requireDirectory(diagramService *DiagramService) (*models.Directory, error) {
+	directory, err := diagramService.Directory()
+	if err != nil {
+		return nil, err
+	}
+	if directory == nil {
+		return nil, fmt.Errorf("%w: set DIRECTORY_PATH", ErrNotConfigured)
+	}
+	return directory, nil
+}
+
+// Identify returns the directory ID of the person making a request, taken
+// from the USER_HEADER header that an authenticating proxy sets
+func (s *PreferencesService) Identify(header http.Header) (string, error) {
+	directory, err := requireDirectory(s.diagramService)
+	if err != nil {
+		return "", err
+	}
+	id := header.Get(s.cfg.UserHeader)
+	if id == "" {
+		return "", fmt.Errorf("%w: missing %s header", ErrUnauthorized, s.cfg.UserHeader)
+	}
+	if findPerson(directory, id) == nil {
+		return "", fmt.Errorf("%w: unknown person %s", ErrUnauthorized, id)
+	}
+	return id, nil
+}
+
+// Get returns a person's stored preferences, or the defaults when none
+// were saved
+func (s *PreferencesService) Get(personID string) (*models.UserPreferences, error) {
+	if err := s.checkPerson(personID); err != nil {
+		return nil, err
+	}
+	return s.load(personID)
+}
+
+// Save replaces a person's preferences. Nil subscriptions keep the stored
+// ones, so a settings form need not resend them.
+func (s *PreferencesService) Save(personID string, preferences models.UserPreferences) (*models.UserPreferences, error) {
+	keepSubscriptions := preferences.Notifications.Subscriptions == nil
+	if preferences.LayoutDirection != nil && !preferences.LayoutDirection.Valid() {
+		return nil, fmt.Errorf("%w: unknown layout direction %s", ErrInvalidOptions, *preferences.LayoutDirection)
+	}
+	if preferences.Theme != "" && !containsValue(models.Themes, preferences.Theme) {
+		return nil, fmt.Errorf("%w: unknown theme %s", ErrInvalidOptions, preferences.Theme)
+	}
+	if preferences.DefaultWorkspace != "" {
+		if err := checkWorkspaceID(preferences.DefaultWorkspace); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkNotifications(&preferences.Notifications); err != nil {
+		return nil, err
+	}
+	return s.update(personID, func(stored *models.UserPreferences) {
+		if keepSubscriptions {
+			preferences.Notifications.Subscriptions = stored.Notifications.Subscriptions
+		}
+		*stored = preferences
+	})
+}
+
+// checkNotifications validates notification preferences and normalizes
+// empty lists
+func (s *PreferencesService) checkNotifications(notifications *models.NotificationPreferences) error {
+	for _, event := range notifications.Events {
+		if !containsValue(models.NotificationEvents, event) {
+			return fmt.Errorf("%w: unknown event %s", ErrInvalidOptions, event)
+		}
+	}
+	for _, id := range notifications.Subscriptions {
+		if _, err := s.diagramService.GetByID(id); err != nil {
+			return fmt.Errorf("%w: unknown diagram %s", ErrInvalidOptions, id)
+		}
+	}
+	if notifications.Events == nil {
+		notifications.Events = []string{}
+	}
+	if notifications.Subscriptions == nil {
+		notifications.Subscriptions = []string{}
+	}
+	return nil
+}
+
+func (s *PreferencesService) checkPerson(personID string) error {
+	directory, err := requireDirectory(s.diagramService)
+	if err != nil {
+		return err
+	}
+	if findPerson(directory, personID) == nil || filepath.Base(personID) != personID {
+		return fmt.Errorf("%w: unknown person %s", ErrInvalidOptions, personID)
+	}
+	return nil
+}
+
+func (s *PreferencesService) file(personID string) string {
+	return filepath.Join(s.cfg.PreferencesPath, personID+".yaml")
+}
+
+func (s *PreferencesService) load(personID string) (*models.UserPreferences, error) {
+	preferences := models.DefaultUserPreferences()
+	data, err := os.ReadFile(s.file(personID))
+	if errors.Is(err, os.ErrNotExist) {
+		return &preferences, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preferences: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &preferences); err != nil {
+		return nil, fmt.Errorf("failed to parse preferences of %s: %w", personID, err)
+	}
+	return &preferences, nil
+}
+
+// update applies a change to a person's stored preferences
+func (s *PreferencesService) update(personID string, change func(*models.UserPreferences)) (*models.UserPreferences, error) {
+	if err := s.checkPerson(personID); err != nil {
+		return nil, err
+	}
+
+	preferencesMu.Lock()
+	defer preferencesMu.Unlock()
+	preferences, err := s.load(personID)
+	if err != nil {
+		return nil, err
+	}
+	change(preferences)
+
+	data, err := yaml.Marshal(preferences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preferences: %w", err)
+	}
+	if err := os.MkdirAll(s.cfg.PreferencesPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create preferences directory: %w", err)
+	}
+	if err := os.WriteFile(s.file(personID), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write preferences: %w", err)
+	}
+	return preferences, nil
+}
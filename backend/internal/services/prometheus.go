@@ -0,0 +1,186 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+)
+
+// PrometheusQueryKey is the node metadata key holding a PromQL query
+const PrometheusQueryKey = "promql"
+
+// prometheusConcurrency bounds the queries in flight per overlay request
+const prometheusConcurrency = 8
+
+// PrometheusSeries is one series of an instant query result. Value is nil
+// for NaN and infinite samples, which JSON cannot carry.
+type PrometheusSeries struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  *float64          `json:"value"`
+}
+
+// PrometheusOverlay is the evaluated query of one node. Value is set when
+// the query returns a single finite series or scalar.
+type PrometheusOverlay struct {
+	Query  string             `json:"query"`
+	Value  *float64           `json:"value,omitempty"`
+	Series []PrometheusSeries `json:"series,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// PrometheusService evaluates node queries against the configured Prometheus
+type PrometheusService struct {
+	cfg            *config.Config
+	client         *http.Client
+	diagramService *DiagramService
+}
+
+// NewPrometheusService creates a new Prometheus service
+func NewPrometheusService() *PrometheusService {
+	return &PrometheusService{
+		cfg:            config.Load(),
+		client:         &http.Client{Timeout: 10 * time.Second},
+		diagramService: NewDiagramService(),
+	}
+}
+
+// Overlay evaluates the PromQL query of every node that declares one and
+// returns the results keyed by node ID. A failing query is reported on its
+// node rather than failing the whole overlay. at optionally evaluates the
+// queries at a past instant.
+func (s *PrometheusService) Overlay(diagramID string, at *time.Time) (map[string]PrometheusOverlay, error) {
+	if s.cfg.PrometheusURL == "" {
+		return nil, fmt.Errorf("%w: set PROMETHEUS_URL", ErrNotConfigured)
+	}
+	diagram, err := s.diagramService.GetByID(diagramID)
+	if err != nil {
+		return nil, err
+	}
+
+	overlays := make(map[string]PrometheusOverlay)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, prometheusConcurrency)
+
+	for _, node := range diagram.Nodes {
+		query, ok := node.Metadata[PrometheusQueryKey].(string)
+		if !ok || strings.TrimSpace(query) == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(nodeID, query string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			overlay := PrometheusOverlay{Query: query}
+			series, err := s.query(query, at)
+			if err != nil {
+				overlay.Error = err.Error()
+			} else {
+				overlay.Series = series
+				if len(series) == 1 {
+					overlay.Value = series[0].Value
+				}
+			}
+
+			mu.Lock()
+			overlays[nodeID] = overlay
+			mu.Unlock()
+		}(node.ID, query)
+	}
+	wg.Wait()
+
+	return overlays, nil
+}
+
+// query runs an instant query and flattens vector and scalar results
+func (s *PrometheusService) query(query string, at *time.Time) ([]PrometheusSeries, error) {
+	params := url.Values{"query": {query}}
+	if at != nil {
+		params.Set("time", strconv.FormatInt(at.Unix(), 10))
+	}
+	endpoint := strings.TrimRight(s.cfg.PrometheusURL, "/") + "/api/v1/query?" + params.Encode()
+
+	resp, err := s.client.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid prometheus response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed: %s", body.Error)
+	}
+
+	switch body.Data.ResultType {
+	case "scalar":
+		var sample [2]interface{}
+		if err := json.Unmarshal(body.Data.Result, &sample); err != nil {
+			return nil, fmt.Errorf("invalid scalar result: %w", err)
+		}
+		value, err := sampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		return []PrometheusSeries{{Value: value}}, nil
+	case "vector":
+		var vector []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]interface{}    `json:"value"`
+		}
+		if err := json.Unmarshal(body.Data.Result, &vector); err != nil {
+			return nil, fmt.Errorf("invalid vector result: %w", err)
+		}
+		series := []PrometheusSeries{}
+		for _, v := range vector {
+			value, err := sampleValue(v.Value)
+			if err != nil {
+				return nil, err
+			}
+			series = append(series, PrometheusSeries{Labels: v.Metric, Value: value})
+		}
+		sort.Slice(series, func(i, j int) bool {
+			return fmt.Sprint(series[i].Labels) < fmt.Sprint(series[j].Labels)
+		})
+		return series, nil
+	default:
+		return nil, fmt.Errorf("unsupported result type %q; use an instant vector or scalar query", body.Data.ResultType)
+	}
+}
+
+// sampleValue parses the value of a [timestamp, "value"] sample; nil for
+// NaN and infinite values
+func sampleValue(sample [2]interface{}) (*float64, error) {
+	text, ok := sample[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid sample value: %v", sample[1])
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, nil
+	}
+	return &value, nil
+}
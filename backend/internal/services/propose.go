@@ -0,0 +1,334 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Git hosting providers
+const (
+	GitProviderGitHub = "github"
+	GitProviderGitLab = "gitlab"
+)
+
+// ProposeOptions describes the pull request opened for a change
+type ProposeOptions struct {
+	Title       string // Defaults to "Update diagram <name>"
+	Description string
+	Branch      string // Defaults to flowgen/<id>-<timestamp>
+}
+
+// Proposal is a pull/merge request opened for a diagram change
+type Proposal struct {
+	Provider string   `json:"provider"`
+	Number   int      `json:"number"`
+	URL      string   `json:"url"`
+	Branch   string   `json:"branch"`
+	Base     string   `json:"base"`
+	Commit   string   `json:"commit"`
+	Files    []string `json:"files"`
+}
+
+// ErrBranchExists is returned when a proposal's branch already exists on
+// the remote
+var ErrBranchExists = errors.New("branch already exists")
+
+var (
+	scpRemote     = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+	invalidBranch = regexp.MustCompile(`[^a-zA-Z0-9._/-]+`)
+)
+
+// ProposalService turns diagram updates into pull requests instead of
+// writing them to the diagrams directory
+type ProposalService struct {
+	cfg            *config.Config
+	client         *http.Client
+	diagramService *DiagramService
+	gitSync        *GitSyncService
+}
+
+// NewProposalService creates a new proposal service
+func NewProposalService() *ProposalService {
+	return &ProposalService{
+		cfg:            config.Load(),
+		client:         &http.Client{Timeout: 30 * time.Second},
+		diagramService: NewDiagramService(),
+		gitSync:        NewGitSyncService(),
+	}
+}
+
+// Propose commits an updated diagram and a rendered SVG preview to a new
+// branch based on the remote's current state and opens a pull request (or
+// GitLab merge request) for it. The local diagrams directory is not
+// modified; the change lands when the request is merged and pulled.
+func (s *ProposalService) Propose(diagram *models.FlowDiagram, opts ProposeOptions) (*Proposal, error) {
+	existing, err := s.diagramService.GetByID(diagram.ID)
+	if err != nil {
+		return nil, err
+	}
+	diagram.Created = existing.Created
+	diagram.Updated = time.Now()
+	if err := s.diagramService.validateDiagram(diagram); err != nil {
+		return nil, err
+	}
+
+	if err := s.gitSync.ready(); err != nil {
+		return nil, err
+	}
+	remote, err := s.remote()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.diagramService.marshalDiagramYAML(diagram)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal diagram to YAML: %w", err)
+	}
+	styled, err := s.diagramService.withResolvedStyles(diagram)
+	if err != nil {
+		return nil, err
+	}
+	preview := renderSVG(styled)
+
+	title := opts.Title
+	if title == "" {
+		title = "Update diagram " + diagram.Name
+	}
+	branch := opts.Branch
+	if branch == "" {
+		branch = fmt.Sprintf("flowgen/%s-%s", diagram.ID, time.Now().UTC().Format("20060102150405"))
+	}
+	branch = strings.Trim(invalidBranch.ReplaceAllString(branch, "-"), "/.-")
+	if branch == "" {
+		return nil, fmt.Errorf("%w: invalid branch name %q", ErrInvalidOptions, opts.Branch)
+	}
+
+	gitMu.Lock()
+	proposal, err := s.commitBranch(existing.FilePath, branch, title, content, preview)
+	gitMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	proposal.Provider = remote.provider
+
+	description := opts.Description
+	if description != "" {
+		description += "\n\n"
+	}
+	description += fmt.Sprintf("Proposed through FlowGen for diagram `%s`.\n\n![Preview](%s)\n", diagram.ID, remote.rawURL(branch, proposal.Files[1]))
+
+	if err := s.openRequest(remote, proposal, title, description); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// commitBranch writes the diagram and preview on top of the remote base
+// branch using a temporary index, so the work tree is left untouched, and
+// pushes the commit as a new branch. The base branch and branches already
+// on the remote are refused, so a proposal never lands without review.
+func (s *ProposalService) commitBranch(filePath, branch, message string, content, preview []byte) (*Proposal, error) {
+	git := s.gitSync
+	base, err := git.branch()
+	if err != nil {
+		return nil, err
+	}
+	if branch == base {
+		return nil, fmt.Errorf("%w: branch %s is the base branch", ErrInvalidOptions, branch)
+	}
+	existing, err := git.git("ls-remote", "--heads", s.cfg.GitRemote, "refs/heads/"+branch)
+	if err != nil {
+		return nil, err
+	}
+	if existing != "" {
+		return nil, fmt.Errorf("%w: %s", ErrBranchExists, branch)
+	}
+	if _, err := git.git("fetch", s.cfg.GitRemote, base); err != nil {
+		return nil, err
+	}
+	baseCommit, err := git.git("rev-parse", s.cfg.GitRemote+"/"+base)
+	if err != nil {
+		return nil, err
+	}
+
+	// Paths are relative to the repository root
+	prefix, err := git.git("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(filePath)
+	files := []string{
+		path.Join(prefix, name),
+		path.Join(prefix, "previews", strings.TrimSuffix(name, filepath.Ext(name))+".svg"),
+	}
+
+	index, err := os.CreateTemp("", "flowgen-index-*")
+	if err != nil {
+		return nil, err
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if _, err := git.gitWith(env, nil, "read-tree", baseCommit); err != nil {
+		return nil, err
+	}
+	for i, data := range [][]byte{content, preview} {
+		blob, err := git.gitWith(nil, data, "hash-object", "-w", "--stdin")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := git.gitWith(env, nil, "update-index", "--add", "--cacheinfo", "100644,"+blob+","+files[i]); err != nil {
+			return nil, err
+		}
+	}
+	tree, err := git.gitWith(env, nil, "write-tree")
+	if err != nil {
+		return nil, err
+	}
+	commit, err := git.git("commit-tree", tree, "-p", baseCommit, "-m", message)
+	if err != nil {
+		return nil, err
+	}
+	// An empty lease fails the push if the branch appeared in the meantime
+	ref := "refs/heads/" + branch
+	if _, err := git.git("push", "--force-with-lease="+ref+":", s.cfg.GitRemote, commit+":"+ref); err != nil {
+		return nil, err
+	}
+
+	return &Proposal{Branch: branch, Base: base, Commit: commit, Files: files}, nil
+}
+
+// gitRemote is the hosting project behind the sync remote
+type gitRemote struct {
+	provider string
+	host     string
+	project  string // owner/repo or GitLab namespace/project
+	apiURL   string
+}
+
+func (s *ProposalService) remote() (*gitRemote, error) {
+	if s.cfg.GitToken == "" {
+		return nil, fmt.Errorf("%w: set GIT_PROVIDER_TOKEN", ErrNotConfigured)
+	}
+	remoteURL, err := s.gitSync.git("config", "--get", "remote."+s.cfg.GitRemote+".url")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &gitRemote{}
+	if u, err := url.Parse(remoteURL); err == nil && u.Host != "" {
+		r.host, r.project = u.Hostname(), u.Path
+	} else if m := scpRemote.FindStringSubmatch(remoteURL); m != nil {
+		r.host, r.project = m[1], m[2]
+	} else {
+		return nil, fmt.Errorf("%w: cannot determine the project of remote %s", ErrNotConfigured, remoteURL)
+	}
+	r.project = strings.TrimSuffix(strings.Trim(r.project, "/"), ".git")
+
+	r.provider = s.cfg.GitProvider
+	if r.provider == "" {
+		r.provider = GitProviderGitHub
+		if strings.Contains(r.host, "gitlab") {
+			r.provider = GitProviderGitLab
+		}
+	}
+	if r.provider != GitProviderGitHub && r.provider != GitProviderGitLab {
+		return nil, fmt.Errorf("%w: unknown GIT_PROVIDER %s", ErrNotConfigured, r.provider)
+	}
+
+	r.apiURL = strings.TrimRight(s.cfg.GitAPIURL, "/")
+	switch {
+	case r.apiURL != "":
+	case r.provider == GitProviderGitLab:
+		r.apiURL = "https://" + r.host + "/api/v4"
+	case r.host == "github.com":
+		r.apiURL = "https://api.github.com"
+	default:
+		r.apiURL = "https://" + r.host + "/api/v3"
+	}
+	return r, nil
+}
+
+// rawURL links to a file on a branch so it renders in the request body
+func (r *gitRemote) rawURL(branch, file string) string {
+	if r.provider == GitProviderGitLab {
+		return fmt.Sprintf("https://%s/%s/-/raw/%s/%s", r.host, r.project, branch, file)
+	}
+	return fmt.Sprintf("https://%s/%s/blob/%s/%s?raw=true", r.host, r.project, branch, file)
+}
+
+// openRequest opens the pull or merge request and fills in its number and URL
+func (s *ProposalService) openRequest(r *gitRemote, proposal *Proposal, title, description string) error {
+	var endpoint string
+	var payload map[string]interface{}
+	if r.provider == GitProviderGitLab {
+		endpoint = fmt.Sprintf("%s/projects/%s/merge_requests", r.apiURL, url.PathEscape(r.project))
+		payload = map[string]interface{}{
+			"source_branch": proposal.Branch,
+			"target_branch": proposal.Base,
+			"title":         title,
+			"description":   description,
+		}
+	} else {
+		endpoint = fmt.Sprintf("%s/repos/%s/pulls", r.apiURL, r.project)
+		payload = map[string]interface{}{
+			"head":  proposal.Branch,
+			"base":  proposal.Base,
+			"title": title,
+			"body":  description,
+		}
+	}
+
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.provider == GitProviderGitLab {
+		req.Header.Set("PRIVATE-TOKEN", s.cfg.GitToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.GitToken)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to open %s request: %w", r.provider, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d for branch %s: %s", r.provider, resp.StatusCode, proposal.Branch, strings.TrimSpace(string(data)))
+	}
+
+	var created struct {
+		Number  int    `json:"number"`   // GitHub
+		IID     int    `json:"iid"`      // GitLab
+		HTMLURL string `json:"html_url"` // GitHub
+		WebURL  string `json:"web_url"`  // GitLab
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return fmt.Errorf("invalid %s response: %w", r.provider, err)
+	}
+	proposal.Number, proposal.URL = created.Number, created.HTMLURL
+	if r.provider == GitProviderGitLab {
+		proposal.Number, proposal.URL = created.IID, created.WebURL
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+var provenanceMethods = []string{models.ProvenanceHandAuthored, models.ProvenanceImported, models.ProvenanceGenerated}
+
+// RecordImportSource notes the document an imported diagram was produced
+// from. origin is its URL or path, empty for uploaded documents.
+func RecordImportSource(diagram *models.FlowDiagram, origin string, data []byte) {
+	if diagram.Provenance == nil {
+		diagram.Provenance = &models.Provenance{Method: models.ProvenanceImported}
+	}
+	diagram.Provenance.Source = origin
+	diagram.Provenance.SourceChecksum = checksum(data)
+}
+
+// stampProvenance is called before a diagram is written. Diagrams created
+// without provenance are hand-authored; imported and generated ones get the
+// checksum of their content the first time they are saved after being
+// produced, which later saves keep.
+func stampProvenance(diagram *models.FlowDiagram, creating bool) error {
+	if diagram.Provenance == nil {
+		if creating {
+			diagram.Provenance = &models.Provenance{Method: models.ProvenanceHandAuthored, ProducedAt: diagram.Created}
+		}
+		return nil
+	}
+	provenance := diagram.Provenance
+	if provenance.Method == models.ProvenanceHandAuthored || provenance.ContentChecksum != "" {
+		return nil
+	}
+	sum, err := contentChecksum(diagram)
+	if err != nil {
+		return err
+	}
+	provenance.ContentChecksum = sum
+	if provenance.ProducedAt.IsZero() {
+		provenance.ProducedAt = diagram.Updated
+	}
+	return nil
+}
+
+// contentChecksum covers the elements of a diagram, which is what
+// regeneration replaces
+func contentChecksum(diagram *models.FlowDiagram) (string, error) {
+	content := struct {
+		Nodes  []models.FlowNode `json:"nodes,omitempty"`
+		Edges  []models.FlowEdge `json:"edges,omitempty"`
+		Layers []models.Layer    `json:"layers,omitempty"`
+	}{diagram.Nodes, diagram.Edges, diagram.Layers}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute content checksum: %w", err)
+	}
+	return checksum(data), nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// editedSinceProduced reports whether an imported or generated diagram was
+// changed after it was produced
+func editedSinceProduced(diagram *models.FlowDiagram) bool {
+	if diagram.Provenance == nil || diagram.Provenance.ContentChecksum == "" {
+		return false
+	}
+	sum, err := contentChecksum(diagram)
+	return err == nil && sum != diagram.Provenance.ContentChecksum
+}
+
+func validateProvenance(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	provenance := diagram.Provenance
+	if provenance == nil {
+		return
+	}
+	if !containsValue(provenanceMethods, provenance.Method) {
+		result.Errors = append(result.Errors, models.ValidationError{
+			Path:    "provenance.method",
+			Message: fmt.Sprintf("Unknown provenance method: %s", provenance.Method),
+			Code:    "INVALID_PROVENANCE",
+			Value:   provenance.Method,
+		})
+		return
+	}
+	if editedSinceProduced(diagram) {
+		result.Warnings = append(result.Warnings, models.ValidationError{
+			Path:    "provenance",
+			Message: fmt.Sprintf("Diagram was %s and has been edited since; regenerating it will overwrite the changes", provenance.Method),
+			Code:    "GENERATED_DIAGRAM_EDITED",
+			Value:   provenance.Method,
+		})
+	}
+}
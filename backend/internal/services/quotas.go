@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ErrQuotaExceeded is returned when a save would exceed one of the
+// QUOTA_* limits
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// LimitYAML wraps a request body so that at most one byte more than the
+// YAML size quota is read; SaveYAMLByID then rejects the oversized text
+// without the whole paste being buffered
+func (s *DiagramService) LimitYAML(r io.Reader) io.Reader {
+	if s.cfg.QuotaMaxYAMLBytes <= 0 {
+		return r
+	}
+	return io.LimitReader(r, int64(s.cfg.QuotaMaxYAMLBytes)+1)
+}
+
+// checkYAMLSize rejects YAML larger than QUOTA_MAX_YAML_BYTES
+func (s *DiagramService) checkYAMLSize(size int) error {
+	if limit := s.cfg.QuotaMaxYAMLBytes; limit > 0 && size > limit {
+		return fmt.Errorf("%w: YAML is larger than %d bytes", ErrQuotaExceeded, limit)
+	}
+	return nil
+}
+
+// checkQuotas enforces the node, edge and size limits of a diagram about
+// to be saved, and the diagram count limit when it is new
+func (s *DiagramService) checkQuotas(diagram *models.FlowDiagram, creating bool) error {
+	if limit := s.cfg.QuotaMaxNodes; limit > 0 && len(diagram.Nodes) > limit {
+		return fmt.Errorf("%w: diagram has %d nodes, the limit is %d", ErrQuotaExceeded, len(diagram.Nodes), limit)
+	}
+	if limit := s.cfg.QuotaMaxEdges; limit > 0 && len(diagram.Edges) > limit {
+		return fmt.Errorf("%w: diagram has %d edges, the limit is %d", ErrQuotaExceeded, len(diagram.Edges), limit)
+	}
+	if s.cfg.QuotaMaxYAMLBytes > 0 {
+		data, err := s.marshalDiagramYAML(diagram)
+		if err != nil {
+			return fmt.Errorf("failed to marshal diagram to YAML: %w", err)
+		}
+		if err := s.checkYAMLSize(len(data)); err != nil {
+			return err
+		}
+	}
+	if limit := s.cfg.QuotaMaxDiagrams; creating && limit > 0 {
+		diagrams, err := s.ListAll()
+		if err != nil {
+			return err
+		}
+		if len(diagrams) >= limit {
+			return fmt.Errorf("%w: the workspace already has %d diagrams, the limit is %d", ErrQuotaExceeded, len(diagrams), limit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ImportSource is an external document kept in sync with a diagram
+type ImportSource struct {
+	Diagram  string `yaml:"diagram" json:"diagram"` // ID of the generated diagram
+	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
+	Type     string `yaml:"type" json:"type"` // terraform, openapi, github-actions or bpmn
+	URL      string `yaml:"url,omitempty" json:"url,omitempty"`
+	Path     string `yaml:"path,omitempty" json:"path,omitempty"`
+	Mode     string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	TokenEnv string `yaml:"tokenEnv,omitempty" json:"tokenEnv,omitempty"` // Environment variable holding a bearer token for url
+}
+
+// RegenerateResult is the outcome for one source
+type RegenerateResult struct {
+	Diagram  string   `json:"diagram"`
+	Type     string   `json:"type"`
+	Status   string   `json:"status"` // created, updated or failed
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// RegenerateService re-imports configured sources into their diagrams
+type RegenerateService struct {
+	cfg            *config.Config
+	client         *http.Client
+	diagramService *DiagramService
+}
+
+// NewRegenerateService creates a new regenerate service
+func NewRegenerateService() *RegenerateService {
+	return &RegenerateService{
+		cfg:            config.Load(),
+		client:         &http.Client{Timeout: 30 * time.Second},
+		diagramService: NewDiagramService(),
+	}
+}
+
+// Authorize checks a webhook call against the configured secret, given
+// either as a plain token (X-FlowGen-Secret, X-Gitlab-Token) or as a GitHub
+// HMAC signature of the body (X-Hub-Signature-256)
+func (s *RegenerateService) Authorize(body []byte, token, signature string) error {
+	if s.cfg.HooksSecret == "" {
+		return fmt.Errorf("%w: set HOOKS_SECRET", ErrNotConfigured)
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.HooksSecret)) == 1 {
+		return nil
+	}
+	if hexDigest, ok := strings.CutPrefix(signature, "sha256="); ok {
+		mac := hmac.New(sha256.New, []byte(s.cfg.HooksSecret))
+		mac.Write(body)
+		if expected, err := hex.DecodeString(hexDigest); err == nil && hmac.Equal(expected, mac.Sum(nil)) {
+			return nil
+		}
+	}
+	return ErrUnauthorized
+}
+
+// Sources loads the configured import sources
+func (s *RegenerateService) Sources() ([]ImportSource, error) {
+	if s.cfg.SourcesPath == "" {
+		return nil, fmt.Errorf("%w: set IMPORT_SOURCES_PATH", ErrNotConfigured)
+	}
+	data, err := os.ReadFile(s.cfg.SourcesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import sources: %w", err)
+	}
+	var file struct {
+		Sources []ImportSource `yaml:"sources"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse import sources: %w", err)
+	}
+	return file.Sources, nil
+}
+
+// Regenerate re-imports the configured sources, or only those for the given
+// diagram IDs, creating or replacing their diagrams. Hierarchy links of
+// existing diagrams are kept. A failing source does not stop the others.
+func (s *RegenerateService) Regenerate(only []string) ([]RegenerateResult, error) {
+	sources, err := s.Sources()
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]bool)
+	for _, id := range only {
+		selected[id] = true
+	}
+
+	results := []RegenerateResult{}
+	for _, source := range sources {
+		if len(selected) > 0 && !selected[source.Diagram] {
+			continue
+		}
+		result := RegenerateResult{Diagram: source.Diagram, Type: source.Type}
+		warnings, status, err := s.regenerate(source)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		} else {
+			result.Status = status
+			result.Warnings = warnings
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *RegenerateService) regenerate(source ImportSource) ([]string, string, error) {
+	importer, ok := Importers[source.Type]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown source type %q", source.Type)
+	}
+	if source.Diagram == "" {
+		return nil, "", fmt.Errorf("source has no diagram ID")
+	}
+	data, err := s.fetch(source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	existing, err := s.diagramService.GetByID(source.Diagram)
+	if err != nil && err != ErrDiagramNotFound {
+		return nil, "", err
+	}
+	opts := ImportOptions{ID: source.Diagram, Name: source.Name, Mode: source.Mode}
+	if opts.Name == "" && existing != nil {
+		opts.Name = existing.Name
+	}
+
+	imported, err := importer(s.diagramService, data, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	diagram := &imported.Diagram
+	origin := source.URL
+	if origin == "" {
+		origin = source.Path
+	}
+	RecordImportSource(diagram, origin, data)
+	if existing == nil {
+		if _, err := s.diagramService.Create(diagram); err != nil {
+			return nil, "", err
+		}
+		return imported.Warnings, "created", nil
+	}
+	diagram.Parent = existing.Parent
+	diagram.Parents = existing.Parents
+	diagram.Children = existing.Children
+	keepPinnedNodes(diagram, existing)
+	if _, err := s.diagramService.Update(diagram); err != nil {
+		return nil, "", err
+	}
+	warnings := imported.Warnings
+	if editedSinceProduced(existing) {
+		warnings = append(warnings, "Manual edits to the diagram were overwritten")
+	}
+	return warnings, "updated", nil
+}
+
+// keepPinnedNodes carries the position and size of nodes pinned in the
+// existing diagram over to the regenerated nodes with the same ID
+func keepPinnedNodes(diagram, existing *models.FlowDiagram) {
+	for _, pinned := range existing.Nodes {
+		if !pinned.Pinned {
+			continue
+		}
+		if node := findNode(diagram, pinned.ID); node != nil {
+			node.Pinned = true
+			node.Position = pinned.Position
+			node.Dimensions = pinned.Dimensions
+		}
+	}
+}
+
+// fetch reads a source document from its URL or local path
+func (s *RegenerateService) fetch(source ImportSource) ([]byte, error) {
+	if source.URL == "" {
+		if source.Path == "" {
+			return nil, fmt.Errorf("source has neither url nor path")
+		}
+		return os.ReadFile(source.Path)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if source.TokenEnv != "" {
+		if token := os.Getenv(source.TokenEnv); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", source.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: HTTP %d", source.URL, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ErrRelationNotFound is returned when removing a relation a diagram does
+// not have
+var ErrRelationNotFound = errors.New("relation not found")
+
+var relationTypes = map[models.RelationType]bool{
+	models.RelationRelatedTo:  true,
+	models.RelationDependsOn:  true,
+	models.RelationSupersedes: true,
+	models.RelationVariantOf:  true,
+}
+
+// RelationLink is a relation seen from outside its source diagram
+type RelationLink struct {
+	Type        models.RelationType `json:"type"`
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Description *string             `json:"description,omitempty"`
+}
+
+// DiagramRelations lists the relations a diagram declares and those other
+// diagrams declare towards it
+type DiagramRelations struct {
+	ID       string            `json:"id"`
+	Outgoing []models.Relation `json:"outgoing"`
+	Incoming []RelationLink    `json:"incoming"`
+}
+
+// validateRelations checks relation types and targets. Targets are not
+// required to exist yet, as related diagrams may be created in any order.
+func validateRelations(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	seen := make(map[string]bool)
+	for i, relation := range diagram.Relations {
+		path := fmt.Sprintf("relations[%d]", i)
+		if !relationTypes[relation.Type] {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".type",
+				Message: fmt.Sprintf("Unknown relation type: %s", relation.Type),
+				Code:    "INVALID_RELATION_TYPE",
+				Value:   relation.Type,
+			})
+		}
+		if relation.Target == "" {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".target",
+				Message: "Relation target is required",
+				Code:    "MISSING_RELATION_TARGET",
+			})
+			continue
+		}
+		if relation.Target == diagram.ID {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".target",
+				Message: fmt.Sprintf("Diagram cannot relate to itself: %s", relation.Target),
+				Code:    "SELF_RELATION",
+				Value:   relation.Target,
+			})
+		}
+		key := string(relation.Type) + "/" + relation.Target
+		if seen[key] {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("Duplicate relation: %s", key),
+				Code:    "DUPLICATE_RELATION",
+				Value:   key,
+			})
+		}
+		seen[key] = true
+	}
+}
+
+// GetRelations returns the outgoing and incoming relations of a diagram,
+// optionally restricted to one relation type
+func (s *HierarchyService) GetRelations(id string, relationType models.RelationType) (*DiagramRelations, error) {
+	diagram, err := s.diagramService.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	relations := &DiagramRelations{
+		ID:       id,
+		Outgoing: []models.Relation{},
+		Incoming: []RelationLink{},
+	}
+	for _, relation := range diagram.Relations {
+		if relationType == "" || relation.Type == relationType {
+			relations.Outgoing = append(relations.Outgoing, relation)
+		}
+	}
+	for _, other := range diagrams {
+		for _, relation := range other.Relations {
+			if relation.Target != id || (relationType != "" && relation.Type != relationType) {
+				continue
+			}
+			relations.Incoming = append(relations.Incoming, RelationLink{
+				Type:        relation.Type,
+				From:        other.ID,
+				To:          id,
+				Description: relation.Description,
+			})
+		}
+	}
+	sort.Slice(relations.Incoming, func(i, j int) bool {
+		if relations.Incoming[i].From != relations.Incoming[j].From {
+			return relations.Incoming[i].From < relations.Incoming[j].From
+		}
+		return relations.Incoming[i].Type < relations.Incoming[j].Type
+	})
+	return relations, nil
+}
+
+// AddRelation adds a relation to a diagram. Adding a relation that already
+// exists replaces its description.
+func (s *HierarchyService) AddRelation(id string, relation models.Relation) (*models.FlowDiagram, error) {
+	if !relationTypes[relation.Type] {
+		return nil, fmt.Errorf("%w: unknown relation type %q", ErrInvalidOptions, relation.Type)
+	}
+	if relation.Target == id {
+		return nil, fmt.Errorf("%w: a diagram cannot relate to itself", ErrInvalidOptions)
+	}
+	diagram, err := s.diagramService.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.diagramService.GetByID(relation.Target); err != nil {
+		if err == ErrDiagramNotFound {
+			return nil, fmt.Errorf("%w: target diagram %s not found", ErrInvalidOptions, relation.Target)
+		}
+		return nil, err
+	}
+
+	replaced := false
+	for i, existing := range diagram.Relations {
+		if existing.Type == relation.Type && existing.Target == relation.Target {
+			diagram.Relations[i] = relation
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		diagram.Relations = append(diagram.Relations, relation)
+	}
+	return s.diagramService.Update(diagram)
+}
+
+// RemoveRelation removes a relation from a diagram
+func (s *HierarchyService) RemoveRelation(id string, relationType models.RelationType, target string) (*models.FlowDiagram, error) {
+	diagram, err := s.diagramService.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	relations := []models.Relation{}
+	for _, existing := range diagram.Relations {
+		if existing.Type != relationType || existing.Target != target {
+			relations = append(relations, existing)
+		}
+	}
+	if len(relations) == len(diagram.Relations) {
+		return nil, ErrRelationNotFound
+	}
+	diagram.Relations = relations
+	return s.diagramService.Update(diagram)
+}
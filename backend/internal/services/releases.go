@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Release errors
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrReleaseExists   = errors.New("release already exists")
+)
+
+// releaseName keeps release names usable as directory names and URL segments
+var releaseName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+const releaseManifest = "release.yaml"
+
+// ReleaseOptions describes a release to create
+type ReleaseOptions struct {
+	Name        string
+	Description string
+	Diagrams    []string // Diagram IDs to capture; empty captures all diagrams
+}
+
+// ReleaseService captures and serves named snapshots of the diagram set.
+// Each release is a directory below the releases path holding a manifest
+// and a verbatim copy of every captured diagram file.
+type ReleaseService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+	gitSync        *GitSyncService
+}
+
+// NewReleaseService creates a new release service
+func NewReleaseService() *ReleaseService {
+	return &ReleaseService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+		gitSync:        NewGitSyncService(),
+	}
+}
+
+// Create captures the current revision of all or the selected diagrams
+// under a new release name. Releases are immutable; reusing a name fails.
+func (s *ReleaseService) Create(opts ReleaseOptions) (*models.Release, error) {
+	if !releaseName.MatchString(opts.Name) {
+		return nil, fmt.Errorf("%w: release name must start with a letter or digit and contain only letters, digits, '.', '_' and '-'", ErrInvalidOptions)
+	}
+	dir := filepath.Join(s.cfg.ReleasesPath, opts.Name)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrReleaseExists, opts.Name)
+	}
+
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Diagrams) > 0 {
+		byID := make(map[string]models.FlowDiagram)
+		for _, diagram := range diagrams {
+			byID[diagram.ID] = diagram
+		}
+		selected := []models.FlowDiagram{}
+		seen := make(map[string]bool)
+		for _, id := range opts.Diagrams {
+			diagram, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("%w: diagram %s not found", ErrInvalidOptions, id)
+			}
+			if !seen[id] {
+				seen[id] = true
+				selected = append(selected, diagram)
+			}
+		}
+		diagrams = selected
+	}
+	sort.Slice(diagrams, func(i, j int) bool { return diagrams[i].ID < diagrams[j].ID })
+
+	release := &models.Release{
+		Name:     opts.Name,
+		Created:  time.Now().UTC().Truncate(time.Second),
+		Diagrams: []models.ReleaseDiagram{},
+	}
+	if opts.Description != "" {
+		release.Description = &opts.Description
+	}
+	if revision, err := s.gitSync.git("rev-parse", "HEAD"); err == nil {
+		release.Revision = revision
+		if changes, err := s.gitSync.git("status", "--porcelain", "--", "."); err == nil && changes != "" {
+			release.Modified = true
+		}
+	}
+
+	// Write into a temporary directory first so a failed capture leaves
+	// no partial release behind
+	if err := os.MkdirAll(s.cfg.ReleasesPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create releases directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(s.cfg.ReleasesPath, "."+opts.Name+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, diagram := range diagrams {
+		data, err := os.ReadFile(diagram.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read diagram %s: %w", diagram.ID, err)
+		}
+		if err := os.WriteFile(filepath.Join(tmp, diagram.ID+".yaml"), data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write diagram %s: %w", diagram.ID, err)
+		}
+		release.Diagrams = append(release.Diagrams, models.ReleaseDiagram{
+			ID:      diagram.ID,
+			Name:    diagram.Name,
+			Version: diagram.Version,
+			Updated: diagram.Updated,
+		})
+	}
+
+	manifest, err := yaml.Marshal(release)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal release: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, releaseManifest), manifest, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write release: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil, fmt.Errorf("%w: %s", ErrReleaseExists, opts.Name)
+		}
+		return nil, fmt.Errorf("failed to store release: %w", err)
+	}
+	return release, nil
+}
+
+// List returns all releases, newest first
+func (s *ReleaseService) List() ([]models.Release, error) {
+	releases := []models.Release{}
+	entries, err := os.ReadDir(s.cfg.ReleasesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return releases, nil
+		}
+		return nil, fmt.Errorf("failed to read releases directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !releaseName.MatchString(entry.Name()) {
+			continue
+		}
+		release, err := s.Get(entry.Name())
+		if err != nil {
+			// Log error but continue with other releases
+			fmt.Printf("Error loading release %s: %v\n", entry.Name(), err)
+			continue
+		}
+		releases = append(releases, *release)
+	}
+	sort.SliceStable(releases, func(i, j int) bool { return releases[i].Created.After(releases[j].Created) })
+	return releases, nil
+}
+
+// Get returns the manifest of a release
+func (s *ReleaseService) Get(name string) (*models.Release, error) {
+	if !releaseName.MatchString(name) {
+		return nil, ErrReleaseNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(s.cfg.ReleasesPath, name, releaseManifest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrReleaseNotFound
+		}
+		return nil, fmt.Errorf("failed to read release: %w", err)
+	}
+	var release models.Release
+	if err := yaml.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	return &release, nil
+}
+
+// GetDiagram returns a diagram as frozen in a release
+func (s *ReleaseService) GetDiagram(name, id string) (*models.FlowDiagram, error) {
+	release, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, captured := range release.Diagrams {
+		if captured.ID != id {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.cfg.ReleasesPath, name, id+".yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read diagram %s of release %s: %w", id, name, err)
+		}
+		var diagram models.FlowDiagram
+		if err := s.diagramService.unmarshalDiagramYAML(data, &diagram); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+		return &diagram, nil
+	}
+	return nil, ErrDiagramNotFound
+}
+
+// ReleaseDiff summarizes how the diagram set changed between two releases
+type ReleaseDiff struct {
+	From      string                  `json:"from"`
+	To        string                  `json:"to"`
+	Added     []models.ReleaseDiagram `json:"added"`
+	Removed   []models.ReleaseDiagram `json:"removed"`
+	Changed   []DiagramDiff           `json:"changed"`
+	Unchanged []string                `json:"unchanged"`
+}
+
+// Diff compares the diagrams captured in two releases. Diagrams present in
+// both are compared semantically, see DiffDiagrams.
+func (s *ReleaseService) Diff(from, to string) (*ReleaseDiff, error) {
+	fromRelease, err := s.Get(from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, from)
+	}
+	toRelease, err := s.Get(to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, to)
+	}
+
+	diff := &ReleaseDiff{
+		From:      from,
+		To:        to,
+		Added:     []models.ReleaseDiagram{},
+		Removed:   []models.ReleaseDiagram{},
+		Changed:   []DiagramDiff{},
+		Unchanged: []string{},
+	}
+	inFrom := make(map[string]bool)
+	for _, captured := range fromRelease.Diagrams {
+		inFrom[captured.ID] = true
+	}
+	inTo := make(map[string]bool)
+	for _, captured := range toRelease.Diagrams {
+		inTo[captured.ID] = true
+		if !inFrom[captured.ID] {
+			diff.Added = append(diff.Added, captured)
+			continue
+		}
+		before, err := s.GetDiagram(from, captured.ID)
+		if err != nil {
+			return nil, err
+		}
+		after, err := s.GetDiagram(to, captured.ID)
+		if err != nil {
+			return nil, err
+		}
+		if diagramDiff := DiffDiagrams(before, after); diagramDiff.Status == DiffChanged {
+			diff.Changed = append(diff.Changed, diagramDiff)
+		} else {
+			diff.Unchanged = append(diff.Unchanged, captured.ID)
+		}
+	}
+	for _, captured := range fromRelease.Diagrams {
+		if !inTo[captured.ID] {
+			diff.Removed = append(diff.Removed, captured)
+		}
+	}
+	return diff, nil
+}
+
+// Markdown renders the diff as a change report
+func (d *ReleaseDiff) Markdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Changes from %s to %s\n\n", d.From, d.To)
+	fmt.Fprintf(&buf, "%d added, %d removed, %d changed, %d unchanged.\n", len(d.Added), len(d.Removed), len(d.Changed), len(d.Unchanged))
+
+	if len(d.Added) > 0 {
+		buf.WriteString("\n## Added\n\n")
+		for _, captured := range d.Added {
+			fmt.Fprintf(&buf, "- %s (`%s`, version %s)\n", captured.Name, captured.ID, captured.Version)
+		}
+	}
+	if len(d.Removed) > 0 {
+		buf.WriteString("\n## Removed\n\n")
+		for _, captured := range d.Removed {
+			fmt.Fprintf(&buf, "- %s (`%s`, version %s)\n", captured.Name, captured.ID, captured.Version)
+		}
+	}
+	if len(d.Changed) > 0 {
+		buf.WriteString("\n## Changed\n")
+		for _, diagram := range d.Changed {
+			fmt.Fprintf(&buf, "\n### %s (`%s`)\n\n", diagram.Name, diagram.ID)
+			for _, change := range diagram.Fields {
+				fmt.Fprintf(&buf, "- `%s`: %s → %s\n", change.Field, markdownValue(change.Before), markdownValue(change.After))
+			}
+			writeElementDiff(&buf, "Nodes", diagram.Nodes)
+			writeElementDiff(&buf, "Edges", diagram.Edges)
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeElementDiff(buf *bytes.Buffer, label string, diff ElementDiff) {
+	if len(diff.Added) > 0 {
+		fmt.Fprintf(buf, "- %s added: %s\n", label, markdownIDs(diff.Added))
+	}
+	if len(diff.Removed) > 0 {
+		fmt.Fprintf(buf, "- %s removed: %s\n", label, markdownIDs(diff.Removed))
+	}
+	for _, change := range diff.Changed {
+		fields := make([]string, 0, len(change.Changes))
+		for _, field := range change.Changes {
+			fields = append(fields, field.Field)
+		}
+		fmt.Fprintf(buf, "- %s changed: `%s` (%s)\n", strings.TrimSuffix(label, "s"), change.ID, strings.Join(fields, ", "))
+	}
+}
+
+func markdownIDs(ids []string) string {
+	return "`" + strings.Join(ids, "`, `") + "`"
+}
+
+// markdownValue shows a field value compactly, or "none" when unset
+func markdownValue(v interface{}) string {
+	if v == nil {
+		return "none"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	text := string(data)
+	if len([]rune(text)) > 80 {
+		text = string([]rune(text)[:77]) + "..."
+	}
+	return "`" + text + "`"
+}
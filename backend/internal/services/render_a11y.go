@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// nodeTypeDescriptions are spoken descriptions of node types
+var nodeTypeDescriptions = map[models.NodeType]string{
+	models.NodeTypeStart:      "start point",
+	models.NodeTypeEnd:        "end point",
+	models.NodeTypeProcess:    "step",
+	models.NodeTypeDecision:   "decision",
+	models.NodeTypeSubprocess: "sub-process",
+	models.NodeTypeData:       "data store",
+	models.NodeTypeExternal:   "external system",
+	models.NodeTypeCustom:     "step",
+}
+
+// walkthroughOrder returns node IDs in reading order: a breadth-first walk
+// from the start nodes (or nodes without incoming edges), followed by any
+// nodes not reachable from them. Edges to missing nodes are not followed.
+func walkthroughOrder(diagram *models.FlowDiagram) (ordered []string, reachable int) {
+	exists := make(map[string]bool, len(diagram.Nodes))
+	for _, node := range diagram.Nodes {
+		exists[node.ID] = true
+	}
+	outgoing := make(map[string][]models.FlowEdge)
+	for _, edge := range diagram.Edges {
+		if exists[edge.To] {
+			outgoing[edge.From] = append(outgoing[edge.From], edge)
+		}
+	}
+
+	roots := entryNodes(diagram)
+
+	seen := make(map[string]bool)
+	queue := append([]string{}, roots...)
+	for _, id := range roots {
+		seen[id] = true
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		ordered = append(ordered, id)
+		for _, edge := range outgoing[id] {
+			if !seen[edge.To] {
+				seen[edge.To] = true
+				queue = append(queue, edge.To)
+			}
+		}
+	}
+	reachable = len(ordered)
+
+	for _, node := range diagram.Nodes {
+		if !seen[node.ID] {
+			ordered = append(ordered, node.ID)
+		}
+	}
+	return ordered, reachable
+}
+
+// renderAccessibleText produces a plain-text walk-through of the flow for
+// screen-reader users: numbered steps with their branches spelled out
+func renderAccessibleText(diagram *models.FlowDiagram) []byte {
+	var buf bytes.Buffer
+
+	nodesByID := make(map[string]*models.FlowNode)
+	for i := range diagram.Nodes {
+		nodesByID[diagram.Nodes[i].ID] = &diagram.Nodes[i]
+	}
+	outgoing := make(map[string][]models.FlowEdge)
+	for _, edge := range diagram.Edges {
+		outgoing[edge.From] = append(outgoing[edge.From], edge)
+	}
+
+	order, reachable := walkthroughOrder(diagram)
+	stepOf := make(map[string]int)
+	for i, id := range order {
+		stepOf[id] = i + 1
+	}
+
+	fmt.Fprintf(&buf, "%s\n", diagram.Name)
+	fmt.Fprintf(&buf, "%s\n\n", strings.Repeat("=", len([]rune(diagram.Name))))
+	if notice := deprecationNotice(diagram); notice != "" {
+		fmt.Fprintf(&buf, "%s.\n\n", strings.TrimSuffix(notice, "."))
+	}
+	if diagram.Description != nil && *diagram.Description != "" {
+		fmt.Fprintf(&buf, "%s\n\n", *diagram.Description)
+	}
+	fmt.Fprintf(&buf, "This flow has %d steps and %d connections.\n\n", len(diagram.Nodes), len(diagram.Edges))
+
+	stepRef := func(id string) string {
+		target, ok := nodesByID[id]
+		if !ok {
+			return "an unknown step"
+		}
+		return fmt.Sprintf("step %d, %s", stepOf[id], target.Name)
+	}
+
+	for i, id := range order {
+		if i == reachable {
+			buf.WriteString("The following steps are not reachable from the start of the flow.\n\n")
+		}
+		node := nodesByID[id]
+		kind := nodeTypeDescriptions[node.Type]
+		if kind == "" {
+			kind = "step"
+		}
+
+		fmt.Fprintf(&buf, "Step %d: %s (%s).\n", stepOf[id], node.Name, kind)
+		if node.Description != nil && *node.Description != "" {
+			fmt.Fprintf(&buf, "  %s\n", *node.Description)
+		}
+		if node.DrillDown != nil {
+			fmt.Fprintf(&buf, "  This step has a detailed diagram: %s.\n", *node.DrillDown)
+		}
+
+		edges := outgoing[id]
+		switch {
+		case len(edges) == 0:
+			buf.WriteString("  The flow ends here.\n")
+		case len(edges) == 1 && node.Type != models.NodeTypeDecision:
+			fmt.Fprintf(&buf, "  Next: go to %s.\n", stepRef(edges[0].To))
+		default:
+			if node.Type == models.NodeTypeDecision {
+				fmt.Fprintf(&buf, "  Decision with %d options:\n", len(edges))
+			} else {
+				fmt.Fprintf(&buf, "  Continues in %d directions:\n", len(edges))
+			}
+			for _, edge := range edges {
+				label := edge.Name
+				if edge.Condition != nil && *edge.Condition != "" {
+					if label != "" {
+						label += " (" + *edge.Condition + ")"
+					} else {
+						label = *edge.Condition
+					}
+				}
+				if label == "" {
+					label = "Otherwise"
+				}
+				fmt.Fprintf(&buf, "  - %s: go to %s.\n", label, stepRef(edge.To))
+			}
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Excalidraw scene file, see https://docs.excalidraw.com/docs/codebase/json-schema
+type excalidrawScene struct {
+	Type     string                 `json:"type"`
+	Version  int                    `json:"version"`
+	Source   string                 `json:"source"`
+	Elements []*excalidrawElement   `json:"elements"`
+	AppState map[string]interface{} `json:"appState"`
+	Files    map[string]interface{} `json:"files"`
+}
+
+type excalidrawElement struct {
+	ID              string             `json:"id"`
+	Type            string             `json:"type"` // rectangle, diamond, ellipse, arrow or text
+	X               float64            `json:"x"`
+	Y               float64            `json:"y"`
+	Width           float64            `json:"width"`
+	Height          float64            `json:"height"`
+	Angle           float64            `json:"angle"`
+	StrokeColor     string             `json:"strokeColor"`
+	BackgroundColor string             `json:"backgroundColor"`
+	FillStyle       string             `json:"fillStyle"`
+	StrokeWidth     float64            `json:"strokeWidth"`
+	StrokeStyle     string             `json:"strokeStyle"` // solid, dashed or dotted
+	Roughness       int                `json:"roughness"`
+	Opacity         int                `json:"opacity"`
+	GroupIDs        []string           `json:"groupIds"`
+	Roundness       *excalidrawRound   `json:"roundness"`
+	Seed            uint32             `json:"seed"`
+	Version         int                `json:"version"`
+	VersionNonce    uint32             `json:"versionNonce"`
+	IsDeleted       bool               `json:"isDeleted"`
+	BoundElements   []excalidrawBound  `json:"boundElements"`
+	Updated         int64              `json:"updated"`
+	Link            *string            `json:"link"`
+	Locked          bool               `json:"locked"`
+	CustomData      map[string]string  `json:"customData,omitempty"`
+	Text            string             `json:"text,omitempty"`
+	OriginalText    string             `json:"originalText,omitempty"`
+	FontSize        float64            `json:"fontSize,omitempty"`
+	FontFamily      int                `json:"fontFamily,omitempty"`
+	TextAlign       string             `json:"textAlign,omitempty"`
+	VerticalAlign   string             `json:"verticalAlign,omitempty"`
+	LineHeight      float64            `json:"lineHeight,omitempty"`
+	ContainerID     *string            `json:"containerId,omitempty"`
+	Points          [][2]float64       `json:"points,omitempty"`
+	StartBinding    *excalidrawBinding `json:"startBinding,omitempty"`
+	EndBinding      *excalidrawBinding `json:"endBinding,omitempty"`
+	StartArrowhead  *string            `json:"startArrowhead,omitempty"`
+	EndArrowhead    *string            `json:"endArrowhead,omitempty"`
+}
+
+type excalidrawRound struct {
+	Type int `json:"type"` // 2 proportional, 3 adaptive radius
+}
+
+type excalidrawBound struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type excalidrawBinding struct {
+	ElementID string  `json:"elementId"`
+	Focus     float64 `json:"focus"`
+	Gap       float64 `json:"gap"`
+}
+
+// Excalidraw font families
+const (
+	excalidrawFontHandDrawn = 1
+	excalidrawFontNormal    = 2
+)
+
+// renderExcalidraw converts the diagram into an Excalidraw scene. Nodes
+// become rectangles, diamonds or ellipses with their label bound as text,
+// and edges become arrows bound to the nodes they connect so they follow
+// when shapes are moved during a whiteboarding session. The diagram's IDs
+// are kept in customData for tracing annotations back to the source.
+func renderExcalidraw(diagram *models.FlowDiagram) []byte {
+	updated := diagram.Updated.UnixMilli()
+	element := func(id, kind string) *excalidrawElement {
+		return &excalidrawElement{
+			ID:              id,
+			Type:            kind,
+			StrokeColor:     "#1e1e1e",
+			BackgroundColor: "transparent",
+			FillStyle:       "solid",
+			StrokeWidth:     2,
+			StrokeStyle:     "solid",
+			Roughness:       1,
+			Opacity:         100,
+			GroupIDs:        []string{},
+			Seed:            excalidrawSeed(id),
+			Version:         1,
+			VersionNonce:    excalidrawSeed(id + "#nonce"),
+			BoundElements:   []excalidrawBound{},
+			Updated:         updated,
+		}
+	}
+	label := func(id, containerID, text string, size float64, color string, w float64) *excalidrawElement {
+		lines := wrapLabel(text, w, size)
+		el := element(id, "text")
+		el.Text = strings.Join(lines, "\n")
+		el.OriginalText = text
+		el.FontSize = size
+		el.FontFamily = excalidrawFontNormal
+		el.TextAlign = "center"
+		el.VerticalAlign = "middle"
+		el.LineHeight = 1.25
+		el.StrokeColor = color
+		el.ContainerID = &containerID
+		el.Width = w
+		el.Height = float64(len(lines)) * size * 1.25
+		return el
+	}
+
+	var elements []*excalidrawElement
+	shapes := make(map[string]*excalidrawElement)
+	nodesByID := make(map[string]*models.FlowNode)
+	nodes, edges := drawOrder(diagram)
+	for _, node := range nodes {
+		nodesByID[node.ID] = node
+		x, y, w, h := nodeBounds(node)
+
+		kind := "rectangle"
+		switch node.Type {
+		case models.NodeTypeStart, models.NodeTypeEnd:
+			kind = "ellipse"
+		case models.NodeTypeDecision:
+			kind = "diamond"
+		}
+		shape := element("node-"+node.ID, kind)
+		shape.X, shape.Y, shape.Width, shape.Height = x, y, w, h
+		shape.BackgroundColor, shape.StrokeColor = nodeColors(node.Type)
+		shape.CustomData = map[string]string{"flowgenId": node.ID, "flowgenType": string(node.Type)}
+		if kind == "rectangle" {
+			shape.Roundness = &excalidrawRound{Type: 3}
+		} else {
+			shape.Roundness = &excalidrawRound{Type: 2}
+		}
+
+		textColor, fontSize := "#ffffff", 14.0
+		if st := node.Style; st != nil {
+			if st.Fill != nil {
+				shape.BackgroundColor = *st.Fill
+			}
+			if st.Stroke != nil {
+				shape.StrokeColor = *st.Stroke
+			}
+			if st.StrokeWidth != nil {
+				shape.StrokeWidth = *st.StrokeWidth
+			}
+			if st.StrokeDasharray != nil {
+				shape.StrokeStyle = excalidrawStrokeStyle(*st.StrokeDasharray)
+			}
+			if st.Opacity != nil {
+				shape.Opacity = int(*st.Opacity * 100)
+			}
+			if st.TextColor != nil {
+				textColor = *st.TextColor
+			}
+			if st.FontSize != nil {
+				fontSize = *st.FontSize
+			}
+		}
+		elements = append(elements, shape)
+		shapes[node.ID] = shape
+
+		if node.Name != "" {
+			text := label("text-"+node.ID, shape.ID, node.Name, fontSize, textColor, w-10)
+			text.X = x + 5
+			text.Y = y + (h-text.Height)/2
+			shape.BoundElements = append(shape.BoundElements, excalidrawBound{ID: text.ID, Type: "text"})
+			elements = append(elements, text)
+		}
+	}
+
+	for _, edge := range edges {
+		points := edgePoints(edge, nodesByID)
+		if len(points) < 2 {
+			continue
+		}
+		arrow := element("edge-"+edge.ID, "arrow")
+		arrow.X, arrow.Y = points[0].X, points[0].Y
+		minX, minY, maxX, maxY := points[0].X, points[0].Y, points[0].X, points[0].Y
+		for _, p := range points {
+			arrow.Points = append(arrow.Points, [2]float64{p.X - arrow.X, p.Y - arrow.Y})
+			minX, minY = min(minX, p.X), min(minY, p.Y)
+			maxX, maxY = max(maxX, p.X), max(maxY, p.Y)
+		}
+		arrow.Width, arrow.Height = maxX-minX, maxY-minY
+		arrow.CustomData = map[string]string{"flowgenId": edge.ID, "flowgenType": string(edge.Type)}
+
+		arrow.StrokeColor = "#555555"
+		switch edge.Type {
+		case models.ConnectionTypeConditional:
+			arrow.StrokeStyle = "dashed"
+		case models.ConnectionTypeDataFlow:
+			arrow.StrokeStyle = "dotted"
+		case models.ConnectionTypeComposition:
+			arrow.StrokeWidth = 3
+		}
+		if st := edge.Style; st != nil {
+			if st.Stroke != nil {
+				arrow.StrokeColor = *st.Stroke
+			}
+			if st.StrokeWidth != nil {
+				arrow.StrokeWidth = *st.StrokeWidth
+			}
+			if st.StrokeDasharray != nil {
+				arrow.StrokeStyle = excalidrawStrokeStyle(*st.StrokeDasharray)
+			}
+		}
+		start, end := edgeArrowheads(edge.Style)
+		arrow.StartArrowhead = excalidrawArrowhead(start)
+		arrow.EndArrowhead = excalidrawArrowhead(end)
+
+		if from := shapes[edge.From]; from != nil {
+			arrow.StartBinding = &excalidrawBinding{ElementID: from.ID, Gap: 4}
+			from.BoundElements = append(from.BoundElements, excalidrawBound{ID: arrow.ID, Type: "arrow"})
+		}
+		if to := shapes[edge.To]; to != nil {
+			arrow.EndBinding = &excalidrawBinding{ElementID: to.ID, Gap: 4}
+			to.BoundElements = append(to.BoundElements, excalidrawBound{ID: arrow.ID, Type: "arrow"})
+		}
+		elements = append(elements, arrow)
+
+		name := edge.Name
+		if name == "" && edge.Condition != nil {
+			name = *edge.Condition
+		}
+		if name != "" {
+			a, b := points[len(points)/2-1], points[len(points)/2]
+			text := label("text-"+edge.ID, arrow.ID, name, 12, "#333333", textWidth(name, 12)+10)
+			text.X = (a.X+b.X)/2 - text.Width/2
+			text.Y = (a.Y+b.Y)/2 - text.Height/2
+			arrow.BoundElements = append(arrow.BoundElements, excalidrawBound{ID: text.ID, Type: "text"})
+			elements = append(elements, text)
+		}
+	}
+
+	if notice := deprecationNotice(diagram); notice != "" {
+		minX, minY, _, _ := diagramBounds(diagram)
+		text := element("deprecated", "text")
+		text.Text, text.OriginalText = "DEPRECATED: "+notice, "DEPRECATED: "+notice
+		text.FontSize = 20
+		text.FontFamily = excalidrawFontHandDrawn
+		text.TextAlign = "left"
+		text.VerticalAlign = "top"
+		text.LineHeight = 1.25
+		text.StrokeColor = "#c0392b"
+		text.X, text.Y = minX, minY-40
+		text.Width, text.Height = textWidth(text.Text, 20), 25
+		elements = append(elements, text)
+	}
+
+	data, _ := json.MarshalIndent(excalidrawScene{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   "flowgen",
+		Elements: elements,
+		AppState: map[string]interface{}{"viewBackgroundColor": "#ffffff", "gridSize": nil},
+		Files:    map[string]interface{}{},
+	}, "", "  ")
+	return data
+}
+
+// excalidrawStrokeStyle maps an SVG dash pattern to Excalidraw's fixed
+// stroke styles: short dashes become dotted, anything else dashed
+func excalidrawStrokeStyle(dasharray string) string {
+	parts := strings.FieldsFunc(dasharray, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(parts) == 0 {
+		return "solid"
+	}
+	if dash, err := strconv.ParseFloat(parts[0], 64); err == nil && dash <= 3 {
+		return "dotted"
+	}
+	return "dashed"
+}
+
+// excalidrawArrowhead maps an arrowhead to the closest Excalidraw one
+func excalidrawArrowhead(head models.Arrowhead) *string {
+	var name string
+	switch head.Type {
+	case models.ArrowheadTriangle:
+		name = "triangle"
+	case models.ArrowheadOpen:
+		name = "arrow"
+	case models.ArrowheadDiamond:
+		name = "diamond"
+	case models.ArrowheadCircle:
+		name = "dot"
+	case models.ArrowheadBar:
+		name = "bar"
+	default:
+		return nil
+	}
+	return &name
+}
+
+// excalidrawSeed derives a stable seed from an element ID so repeated
+// exports draw the same hand-drawn strokes
+func excalidrawSeed(id string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return h.Sum32() & 0x7fffffff
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// htmlLinks are the navigation targets of an HTML export, as relative URLs
+type htmlLinks struct {
+	Parent     string            // Page of the parent diagram
+	ParentName string            // Name shown for the parent link
+	DrillDown  map[string]string // Node ID to the page of the diagram it opens, with the focus as fragment
+}
+
+// renderHTML renders a diagram as a self-contained HTML page: the SVG is
+// inlined and a small script adds pan and zoom, so the file works offline
+// without the editor. Drill-down nodes link to the pages in links.
+func renderHTML(diagram *models.FlowDiagram, links htmlLinks) []byte {
+	if links.DrillDown == nil {
+		links.DrillDown = map[string]string{}
+	}
+	drillDown, _ := json.Marshal(links.DrillDown)
+
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	buf.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&buf, "<meta name=\"generator\" content=\"FlowGen\">\n<title>%s</title>\n", html.EscapeString(diagram.Name))
+	buf.WriteString(htmlExportStyle)
+	buf.WriteString("</head>\n<body>\n<header>\n")
+	if links.Parent != "" {
+		fmt.Fprintf(&buf, "<a class=\"up\" href=\"%s\">&uarr; %s</a>\n", html.EscapeString(links.Parent), html.EscapeString(links.ParentName))
+	}
+	fmt.Fprintf(&buf, "<h1>%s</h1>\n", html.EscapeString(diagram.Name))
+	if diagram.Version != "" {
+		fmt.Fprintf(&buf, "<span class=\"version\">v%s</span>\n", html.EscapeString(diagram.Version))
+	}
+	buf.WriteString("<span class=\"controls\"><button data-zoom=\"in\" title=\"Zoom in (+)\">+</button><button data-zoom=\"out\" title=\"Zoom out (-)\">&minus;</button><button data-zoom=\"reset\" title=\"Fit (0)\">Fit</button></span>\n")
+	buf.WriteString("</header>\n")
+	if diagram.Description != nil && *diagram.Description != "" {
+		fmt.Fprintf(&buf, "<p class=\"description\">%s</p>\n", html.EscapeString(*diagram.Description))
+	}
+	buf.WriteString("<main>\n")
+	buf.Write(renderSVG(diagram))
+	buf.WriteString("</main>\n")
+	fmt.Fprintf(&buf, "<script>\nconst drillDown = %s;\n%s</script>\n", drillDown, htmlExportScript)
+	buf.WriteString("</body>\n</html>\n")
+	return buf.Bytes()
+}
+
+const htmlExportStyle = `<style>
+body { margin: 0; font-family: Helvetica, Arial, sans-serif; display: flex; flex-direction: column; height: 100vh; }
+header { display: flex; align-items: baseline; gap: 12px; padding: 8px 16px; border-bottom: 1px solid #ddd; }
+header h1 { font-size: 18px; margin: 0; }
+header .version { color: #777; font-size: 13px; }
+header .controls { margin-left: auto; }
+header button { min-width: 32px; margin-left: 4px; }
+.up { color: #1f5fa8; text-decoration: none; font-size: 13px; }
+.description { margin: 8px 16px 0; color: #444; font-size: 14px; }
+main { flex: 1; overflow: hidden; cursor: grab; }
+main.panning { cursor: grabbing; }
+main svg { width: 100%; height: 100%; }
+.node.drilldown { cursor: pointer; }
+.node.drilldown:hover > :first-child { stroke-width: 4; }
+.node.focus > :first-child { stroke: #f5a623; stroke-width: 4; }
+</style>
+`
+
+const htmlExportScript = `(function () {
+  const main = document.querySelector('main');
+  const svg = main.querySelector('svg');
+  const initial = svg.getAttribute('viewBox').split(' ').map(Number);
+  let view = initial.slice();
+  svg.removeAttribute('width');
+  svg.removeAttribute('height');
+
+  function apply() { svg.setAttribute('viewBox', view.join(' ')); }
+  function zoom(factor, cx, cy) {
+    const rect = svg.getBoundingClientRect();
+    const scale = Math.max(view[2] / rect.width, view[3] / rect.height);
+    const offsetX = (rect.width * scale - view[2]) / 2;
+    const offsetY = (rect.height * scale - view[3]) / 2;
+    const px = view[0] - offsetX + (cx - rect.left) * scale;
+    const py = view[1] - offsetY + (cy - rect.top) * scale;
+    view = [px - (px - view[0]) * factor, py - (py - view[1]) * factor, view[2] * factor, view[3] * factor];
+    apply();
+  }
+  function zoomCenter(factor) {
+    const rect = svg.getBoundingClientRect();
+    zoom(factor, rect.left + rect.width / 2, rect.top + rect.height / 2);
+  }
+  function reset() { view = initial.slice(); apply(); }
+
+  main.addEventListener('wheel', function (e) {
+    e.preventDefault();
+    zoom(e.deltaY > 0 ? 1.1 : 1 / 1.1, e.clientX, e.clientY);
+  }, { passive: false });
+
+  let drag = null;
+  let moved = false;
+  main.addEventListener('pointerdown', function (e) {
+    drag = { x: e.clientX, y: e.clientY, view: view.slice() };
+    moved = false;
+    main.classList.add('panning');
+  });
+  window.addEventListener('pointermove', function (e) {
+    if (!drag) return;
+    const rect = svg.getBoundingClientRect();
+    const scale = Math.max(view[2] / rect.width, view[3] / rect.height);
+    const dx = e.clientX - drag.x, dy = e.clientY - drag.y;
+    if (Math.abs(dx) + Math.abs(dy) > 3) moved = true;
+    view[0] = drag.view[0] - dx * scale;
+    view[1] = drag.view[1] - dy * scale;
+    apply();
+  });
+  window.addEventListener('pointerup', function () {
+    drag = null;
+    main.classList.remove('panning');
+  });
+  main.addEventListener('dblclick', reset);
+
+  document.querySelectorAll('[data-zoom]').forEach(function (button) {
+    button.addEventListener('click', function () {
+      const action = button.getAttribute('data-zoom');
+      if (action === 'in') zoomCenter(1 / 1.25);
+      else if (action === 'out') zoomCenter(1.25);
+      else reset();
+    });
+  });
+  document.addEventListener('keydown', function (e) {
+    if (e.key === '+' || e.key === '=') zoomCenter(1 / 1.25);
+    else if (e.key === '-') zoomCenter(1.25);
+    else if (e.key === '0') reset();
+  });
+
+  svg.querySelectorAll('.node[data-id]').forEach(function (node) {
+    const href = drillDown[node.getAttribute('data-id')];
+    if (!href) return;
+    node.classList.add('drilldown');
+    const title = document.createElementNS('http://www.w3.org/2000/svg', 'title');
+    title.textContent = 'Open ' + href;
+    node.appendChild(title);
+    node.addEventListener('click', function () {
+      if (!moved) window.location.href = href;
+    });
+  });
+
+  // Drill-down links may focus a node and a viewport: #node=id&x=&y=&zoom=
+  const focus = new URLSearchParams(window.location.hash.slice(1));
+  if (focus.has('node') || focus.has('x')) {
+    const factor = Number(focus.get('zoom')) || 1;
+    let cx = Number(focus.get('x')), cy = Number(focus.get('y'));
+    const target = focus.has('node') && svg.querySelector('.node[data-id="' + CSS.escape(focus.get('node')) + '"]');
+    if (target) {
+      const box = target.getBBox();
+      cx = box.x + box.width / 2;
+      cy = box.y + box.height / 2;
+      target.classList.add('focus');
+    }
+    if (isFinite(cx) && isFinite(cy) && (target || focus.has('x'))) {
+      const w = initial[2] / factor, h = initial[3] / factor;
+      view = [cx - w / 2, cy - h / 2, w, h];
+      apply();
+    }
+  }
+})();
+`
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+var mermaidUnsafeID = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// renderMermaid converts the diagram into a Mermaid flowchart definition
+func renderMermaid(diagram *models.FlowDiagram) []byte {
+	var buf bytes.Buffer
+
+	direction := "TD"
+	if diagram.Layout != nil && diagram.Layout.Direction != nil {
+		switch *diagram.Layout.Direction {
+		case models.LayoutDirectionBottomTop:
+			direction = "BT"
+		case models.LayoutDirectionLeftRight:
+			direction = "LR"
+		case models.LayoutDirectionRightLeft:
+			direction = "RL"
+		}
+	}
+	fmt.Fprintf(&buf, "flowchart %s\n", direction)
+	if notice := deprecationNotice(diagram); notice != "" {
+		fmt.Fprintf(&buf, "%%%% %s\n", strings.ReplaceAll(notice, "\n", " "))
+	}
+
+	for _, node := range diagram.Nodes {
+		label := mermaidLabel(node.Name)
+		var shape string
+		switch node.Type {
+		case models.NodeTypeStart, models.NodeTypeEnd:
+			shape = "([" + label + "])"
+		case models.NodeTypeDecision:
+			shape = "{" + label + "}"
+		case models.NodeTypeSubprocess:
+			shape = "[[" + label + "]]"
+		case models.NodeTypeData:
+			shape = "[(" + label + ")]"
+		case models.NodeTypeExternal:
+			shape = ">" + label + "]"
+		default:
+			shape = "[" + label + "]"
+		}
+		fmt.Fprintf(&buf, "    %s%s\n", mermaidID(node.ID), shape)
+	}
+
+	for _, edge := range diagram.Edges {
+		arrow := "-->"
+		switch edge.Type {
+		case models.ConnectionTypeConditional, models.ConnectionTypeDataFlow:
+			arrow = "-.->"
+		case models.ConnectionTypeComposition:
+			arrow = "==>"
+		case models.ConnectionTypeAssociation:
+			arrow = "---"
+		}
+		label := edge.Name
+		if label == "" && edge.Condition != nil {
+			label = *edge.Condition
+		}
+		if label != "" {
+			fmt.Fprintf(&buf, "    %s %s|%s| %s\n", mermaidID(edge.From), arrow, mermaidLabel(label), mermaidID(edge.To))
+		} else {
+			fmt.Fprintf(&buf, "    %s %s %s\n", mermaidID(edge.From), arrow, mermaidID(edge.To))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func mermaidID(id string) string {
+	return mermaidUnsafeID.ReplaceAllString(id, "_")
+}
+
+func mermaidLabel(label string) string {
+	return `"` + strings.ReplaceAll(label, `"`, "#quot;") + `"`
+}
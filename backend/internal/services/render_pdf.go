@@ -0,0 +1,389 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+const (
+	pdfMargin       = 36.0
+	pdfHeaderHeight = 24.0
+	pdfFooterHeight = 16.0
+
+	// MaxPDFScale is the largest tiling scale accepted
+	MaxPDFScale = 10.0
+	// maxPDFPages bounds the pages of a PDF, index pages included
+	maxPDFPages = 500
+)
+
+// PDFOptions controls PDF page setup
+type PDFOptions struct {
+	Paper     string  // a4 (default), a3, letter or legal
+	Landscape bool    // Rotate the paper
+	Scale     float64 // Points per diagram unit when tiling (default 1)
+	Tile      bool    // Split the diagram across pages instead of fitting one page
+	Overlap   float64 // Overlap between neighboring tiles in points (default 24)
+}
+
+// renderPDF renders a diagram either fitted to a single page or tiled across
+// several pages preceded by an index page
+func renderPDF(diagram *models.FlowDiagram, opts PDFOptions) ([]byte, error) {
+	pageW, pageH, err := paperSize(opts.Paper, opts.Landscape)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newPDFDocument(diagram.Name)
+	if !opts.Tile {
+		canvas := doc.AddPage(pageW, pageH)
+		drawPDFWatermark(canvas, diagram, pageW, pageH)
+		drawPDFTitle(canvas, pageW, diagram.Name, "")
+		drawDiagramFitted(canvas, diagram, pdfMargin, pdfMargin+pdfHeaderHeight, pageW-2*pdfMargin, pageH-2*pdfMargin-pdfHeaderHeight)
+		return doc.Bytes(), nil
+	}
+
+	if err := addTiledPages(doc, diagram, pageW, pageH, opts); err != nil {
+		return nil, err
+	}
+	return doc.Bytes(), nil
+}
+
+// pdfRowName names a tile row like a spreadsheet column: A to Z, then AA,
+// AB and so on
+func pdfRowName(row int) string {
+	name := ""
+	for row++; row > 0; row = (row - 1) / 26 {
+		name = string(rune('A'+(row-1)%26)) + name
+	}
+	return name
+}
+
+// addTiledPages appends an index page and one page per tile to the document
+func addTiledPages(doc *pdfDocument, diagram *models.FlowDiagram, pageW, pageH float64, opts PDFOptions) error {
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	overlap := opts.Overlap
+	if overlap <= 0 {
+		overlap = 24
+	}
+
+	printW := pageW - 2*pdfMargin
+	printH := pageH - 2*pdfMargin - pdfHeaderHeight - pdfFooterHeight
+	if overlap >= printW/2 || overlap >= printH/2 {
+		return fmt.Errorf("overlap %.0fpt is too large for the page", overlap)
+	}
+
+	minX, minY, maxX, maxY := diagramBounds(diagram)
+	minX, minY = minX-svgPadding, minY-svgPadding
+	extentW := (maxX + svgPadding - minX) * scale
+	extentH := (maxY + svgPadding - minY) * scale
+
+	stepX, stepY := printW-overlap, printH-overlap
+	cols := max(1, roundUp((extentW-overlap)/stepX))
+	rows := max(1, roundUp((extentH-overlap)/stepY))
+	if pages := len(doc.pages) + 1 + rows*cols; pages > maxPDFPages {
+		return fmt.Errorf("tiling needs %d pages, more than the %d allowed; lower the scale or use larger paper", pages, maxPDFPages)
+	}
+
+	indexPage := len(doc.pages)
+	firstTile := indexPage + 1
+	tileName := func(row, col int) string {
+		return pdfRowName(row) + strconv.Itoa(col+1)
+	}
+
+	// Index page: the whole diagram with the tile grid drawn over it
+	index := doc.AddPage(pageW, pageH)
+	drawPDFWatermark(index, diagram, pageW, pageH)
+	drawPDFTitle(index, pageW, diagram.Name, fmt.Sprintf("Index - %d x %d pages", rows, cols))
+	areaX, areaY := pdfMargin, pdfMargin+pdfHeaderHeight
+	areaW, areaH := printW, pageH-2*pdfMargin-pdfHeaderHeight
+	fit := drawDiagramFitted(index, diagram, areaX, areaY, areaW, areaH)
+	ratio := fit / scale
+	gridX := areaX + (areaW-extentW*ratio)/2
+	gridY := areaY + (areaH-extentH*ratio)/2
+	index.Save()
+	index.SetStroke("#c0392b")
+	index.SetLineWidth(0.75)
+	index.SetDash("4,3")
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			x := gridX + float64(col)*stepX*ratio
+			y := gridY + float64(row)*stepY*ratio
+			w := math.Min(printW, extentW-float64(col)*stepX) * ratio
+			h := math.Min(printH, extentH-float64(row)*stepY) * ratio
+			index.Rect(x, y, w, h, "S")
+			index.SetFill("#c0392b")
+			index.Text(x+3, y+11, 9, true, "left", tileName(row, col))
+			index.Link(x, y, w, h, firstTile+row*cols+col)
+		}
+	}
+	index.Restore()
+
+	// One page per tile, each showing its neighbors for orientation
+	total := rows * cols
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			page := doc.AddPage(pageW, pageH)
+			drawPDFWatermark(page, diagram, pageW, pageH)
+			number := row*cols + col + 1
+			drawPDFTitle(page, pageW, diagram.Name, fmt.Sprintf("Page %s (%d of %d)", tileName(row, col), number, total))
+
+			top := pdfMargin + pdfHeaderHeight
+			page.Save()
+			page.Clip(pdfMargin, top, printW, printH)
+			originX := pdfMargin - float64(col)*stepX
+			originY := top - float64(row)*stepY
+			drawDiagramPDF(page, diagram, func(x, y float64) (float64, float64) {
+				return originX + (x-minX)*scale, originY + (y-minY)*scale
+			}, scale)
+			page.Restore()
+
+			// Overlap marks show where the neighboring page continues
+			page.Save()
+			page.SetStroke("#7f8c8d")
+			page.SetLineWidth(0.5)
+			page.SetDash("3,3")
+			if col > 0 {
+				page.Polyline([][2]float64{{pdfMargin + overlap, top}, {pdfMargin + overlap, top + printH}})
+			}
+			if col < cols-1 {
+				page.Polyline([][2]float64{{pdfMargin + printW - overlap, top}, {pdfMargin + printW - overlap, top + printH}})
+			}
+			if row > 0 {
+				page.Polyline([][2]float64{{pdfMargin, top + overlap}, {pdfMargin + printW, top + overlap}})
+			}
+			if row < rows-1 {
+				page.Polyline([][2]float64{{pdfMargin, top + printH - overlap}, {pdfMargin + printW, top + printH - overlap}})
+			}
+			page.SetDash("")
+			page.Rect(pdfMargin, top, printW, printH, "S")
+			page.Restore()
+
+			// Neighbor references in the footer
+			var neighbors string
+			add := func(label string, r, c int) {
+				if r >= 0 && r < rows && c >= 0 && c < cols {
+					if neighbors != "" {
+						neighbors += "   "
+					}
+					neighbors += label + " " + tileName(r, c)
+				}
+			}
+			add("Left:", row, col-1)
+			add("Right:", row, col+1)
+			add("Up:", row-1, col)
+			add("Down:", row+1, col)
+			page.SetFill("#555555")
+			page.Text(pdfMargin, pageH-pdfMargin+4, 8, false, "left", neighbors)
+			indexLabel := fmt.Sprintf("Index: page %d", indexPage+1)
+			page.Text(pageW-pdfMargin, pageH-pdfMargin+4, 8, false, "right", indexLabel)
+			page.Link(pageW-pdfMargin-textWidth(indexLabel, 8), pageH-pdfMargin-6, textWidth(indexLabel, 8), 12, indexPage)
+		}
+	}
+
+	return nil
+}
+
+// drawPDFTitle writes the page header
+func drawPDFTitle(c *pdfCanvas, pageW float64, title, subtitle string) {
+	c.SetFill("#222222")
+	c.Text(pdfMargin, pdfMargin+12, 14, true, "left", title)
+	if subtitle != "" {
+		c.SetFill("#555555")
+		c.Text(pageW-pdfMargin, pdfMargin+12, 10, false, "right", subtitle)
+	}
+}
+
+// drawPDFWatermark stamps a deprecated diagram across the page, beneath
+// the content drawn afterwards
+func drawPDFWatermark(c *pdfCanvas, diagram *models.FlowDiagram, pageW, pageH float64) {
+	notice := deprecationNotice(diagram)
+	if notice == "" {
+		return
+	}
+	size := math.Min(pageW, pageH) / 6
+	c.Save()
+	c.SetFill("#f2b8b5")
+	c.RotatedText(pageW/2, pageH/2, size, 30, 0, true, "DEPRECATED")
+	c.RotatedText(pageW/2, pageH/2, size/4, 30, size*0.6, false, notice)
+	c.Restore()
+}
+
+// drawDiagramFitted draws the diagram scaled to fit the given area and
+// returns the scale used
+func drawDiagramFitted(c *pdfCanvas, diagram *models.FlowDiagram, x, y, w, h float64) float64 {
+	tf, scale, ok := fitDiagram(diagram, x, y, w, h)
+	if !ok {
+		return 1
+	}
+	drawDiagramPDF(c, diagram, tf, scale)
+	return scale
+}
+
+// fitDiagram returns the transform and scale that center the diagram in the
+// given area; ok is false for an empty diagram
+func fitDiagram(diagram *models.FlowDiagram, x, y, w, h float64) (tf func(x, y float64) (float64, float64), scale float64, ok bool) {
+	minX, minY, maxX, maxY := diagramBounds(diagram)
+	minX, minY = minX-svgPadding, minY-svgPadding
+	dw, dh := maxX+svgPadding-minX, maxY+svgPadding-minY
+	if dw <= 0 || dh <= 0 {
+		return nil, 1, false
+	}
+	scale = math.Min(1.5, math.Min(w/dw, h/dh))
+	ox := x + (w-dw*scale)/2
+	oy := y + (h-dh*scale)/2
+	return func(px, py float64) (float64, float64) {
+		return ox + (px-minX)*scale, oy + (py-minY)*scale
+	}, scale, true
+}
+
+// drawDiagramPDF draws nodes and edges using the same shapes and colors as
+// the SVG renderer. tf maps diagram coordinates to page coordinates.
+func drawDiagramPDF(c *pdfCanvas, diagram *models.FlowDiagram, tf func(x, y float64) (float64, float64), scale float64) {
+	nodesByID := make(map[string]*models.FlowNode)
+	for i := range diagram.Nodes {
+		nodesByID[diagram.Nodes[i].ID] = &diagram.Nodes[i]
+	}
+
+	nodes, edges := drawOrder(diagram)
+	for _, edge := range edges {
+		points := edgePoints(edge, nodesByID)
+		if len(points) < 2 {
+			continue
+		}
+		stroke, width, dash := "#555555", 2.0, ""
+		switch edge.Type {
+		case models.ConnectionTypeConditional:
+			dash = "6,4"
+		case models.ConnectionTypeDataFlow:
+			dash = "2,3"
+		case models.ConnectionTypeComposition:
+			width = 3
+		}
+		if st := edge.Style; st != nil {
+			if st.Stroke != nil {
+				stroke = *st.Stroke
+			}
+			if st.StrokeWidth != nil {
+				width = *st.StrokeWidth
+			}
+			if st.StrokeDasharray != nil {
+				dash = *st.StrokeDasharray
+			}
+		}
+
+		var line [][2]float64
+		for _, p := range points {
+			x, y := tf(p.X, p.Y)
+			line = append(line, [2]float64{x, y})
+		}
+		c.SetStroke(stroke)
+		c.SetLineWidth(width * scale)
+		c.SetDash(scaleDash(dash, scale))
+		c.Polyline(line)
+		c.SetDash("")
+
+		start, end := edgeArrowheads(edge.Style)
+		c.SetFill(stroke)
+		for _, arrow := range []struct {
+			head      models.Arrowhead
+			tip, from [2]float64
+		}{{start, line[0], line[1]}, {end, line[len(line)-1], line[len(line)-2]}} {
+			shape, closed := arrowheadShape(arrow.head, arrow.tip, arrow.from, scale)
+			switch {
+			case len(shape) == 0:
+			case closed:
+				c.Polygon(shape, "f")
+			default:
+				c.Polyline(shape)
+			}
+		}
+
+		label := edge.Name
+		if label == "" && edge.Condition != nil {
+			label = *edge.Condition
+		}
+		if label != "" {
+			a, b := line[len(line)/2-1], line[len(line)/2]
+			c.SetFill("#333333")
+			c.Text((a[0]+b[0])/2, (a[1]+b[1])/2-4*scale, 10*scale, false, "center", label)
+		}
+	}
+
+	for _, node := range nodes {
+		nx, ny, nw, nh := nodeBounds(node)
+		x, y := tf(nx, ny)
+		w, h := nw*scale, nh*scale
+
+		fill, stroke := nodeColors(node.Type)
+		width, dash, textColor, fontSize := 2.0, "", "#ffffff", 14.0
+		bold := false
+		if st := node.Style; st != nil {
+			if st.Fill != nil {
+				fill = *st.Fill
+			}
+			if st.Stroke != nil {
+				stroke = *st.Stroke
+			}
+			if st.StrokeWidth != nil {
+				width = *st.StrokeWidth
+			}
+			if st.StrokeDasharray != nil {
+				dash = *st.StrokeDasharray
+			}
+			if st.TextColor != nil {
+				textColor = *st.TextColor
+			}
+			if st.FontSize != nil {
+				fontSize = *st.FontSize
+			}
+			bold = st.FontWeight != nil && *st.FontWeight == "bold"
+		}
+
+		c.SetFill(fill)
+		c.SetStroke(stroke)
+		c.SetLineWidth(width * scale)
+		c.SetDash(scaleDash(dash, scale))
+		switch node.Type {
+		case models.NodeTypeStart, models.NodeTypeEnd:
+			c.Ellipse(x, y, w, h, "B")
+		case models.NodeTypeDecision:
+			c.Polygon([][2]float64{{x + w/2, y}, {x + w, y + h/2}, {x + w/2, y + h}, {x, y + h/2}}, "B")
+		case models.NodeTypeSubprocess:
+			c.Rect(x, y, w, h, "B")
+			c.Rect(x+4*scale, y+4*scale, w-8*scale, h-8*scale, "S")
+		default:
+			c.Rect(x, y, w, h, "B")
+		}
+		c.SetDash("")
+
+		lines := wrapLabel(node.Name, nw, fontSize)
+		size := fontSize * scale
+		lineHeight := size * 1.2
+		top := y + h/2 - lineHeight*float64(len(lines)-1)/2 + size*0.35
+		c.SetFill(textColor)
+		for j, text := range lines {
+			c.Text(x+w/2, top+float64(j)*lineHeight, size, bold, "center", text)
+		}
+	}
+}
+
+// scaleDash scales an SVG dash pattern for the PDF output scale
+func scaleDash(dash string, scale float64) string {
+	if dash == "" || scale == 1 {
+		return dash
+	}
+	var parts []string
+	for _, f := range strings.FieldsFunc(dash, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if v, err := strconv.ParseFloat(f, 64); err == nil {
+			parts = append(parts, pdfNum(v*scale))
+		}
+	}
+	return strings.Join(parts, ",")
+}
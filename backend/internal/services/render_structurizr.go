@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ArchitectureTag marks diagrams that describe an architecture view rather
+// than a process; only those can be exported as a C4 model
+const ArchitectureTag = "architecture"
+
+// C4 element kinds a node can be exported as. A node's "c4" metadata
+// overrides the kind derived from its type.
+const (
+	c4Person         = "person"
+	c4SoftwareSystem = "softwareSystem"
+	c4Container      = "container"
+)
+
+// c4Element is a node exported to the C4 model
+type c4Element struct {
+	ident string
+	kind  string
+	node  *models.FlowNode
+}
+
+// isArchitectureView reports whether the diagram is tagged as an
+// architecture view
+func isArchitectureView(diagram *models.FlowDiagram) bool {
+	for _, tag := range diagram.Tags {
+		if strings.EqualFold(tag, ArchitectureTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderStructurizr converts an architecture view into a Structurizr DSL
+// workspace. The diagram becomes a software system; external nodes become
+// people, and process, subprocess, data and custom nodes become its
+// containers, with data nodes tagged as databases. Start, end and decision
+// nodes describe control flow rather than architecture and are left out
+// along with their edges. Nodes may set "c4" metadata to person,
+// softwareSystem or container to choose the element kind, and "technology"
+// metadata on nodes and edges is carried over.
+func renderStructurizr(diagram *models.FlowDiagram) ([]byte, error) {
+	if !isArchitectureView(diagram) {
+		return nil, fmt.Errorf("%w: structurizr export needs a diagram tagged %q", ErrUnsupportedFormat, ArchitectureTag)
+	}
+
+	ids := newIDAllocator()
+	system := ids.allocate(diagram.ID)
+	elements := make(map[string]*c4Element)
+	var people, systems, containers []*c4Element
+	for i := range diagram.Nodes {
+		node := &diagram.Nodes[i]
+		kind := c4Kind(node)
+		if kind == "" {
+			continue
+		}
+		element := &c4Element{ident: ids.allocate(node.ID), kind: kind, node: node}
+		elements[node.ID] = element
+		switch kind {
+		case c4Person:
+			people = append(people, element)
+		case c4SoftwareSystem:
+			systems = append(systems, element)
+		default:
+			containers = append(containers, element)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "workspace %s %s {\n\n", dslString(diagram.Name), dslText(diagram.Description))
+	buf.WriteString("    model {\n")
+	for _, element := range people {
+		fmt.Fprintf(&buf, "        %s = person %s %s%s\n", element.ident, dslString(element.node.Name), dslText(element.node.Description), dslTags(element.node.Tags))
+	}
+	for _, element := range systems {
+		tags := append([]string{"External"}, element.node.Tags...)
+		fmt.Fprintf(&buf, "        %s = softwareSystem %s %s%s\n", element.ident, dslString(element.node.Name), dslText(element.node.Description), dslTags(tags))
+	}
+	fmt.Fprintf(&buf, "        %s = softwareSystem %s %s {\n", system, dslString(diagram.Name), dslText(diagram.Description))
+	for _, element := range containers {
+		tags := element.node.Tags
+		if element.node.Type == models.NodeTypeData {
+			tags = append([]string{"Database"}, tags...)
+		}
+		fmt.Fprintf(&buf, "            %s = container %s %s %s%s\n", element.ident, dslString(element.node.Name),
+			dslText(element.node.Description), dslString(metadataText(element.node.Metadata, "technology")), dslTags(tags))
+	}
+	buf.WriteString("        }\n\n")
+
+	for _, edge := range diagram.Edges {
+		from, to := elements[edge.From], elements[edge.To]
+		if from == nil || to == nil {
+			continue
+		}
+		label := edge.Name
+		if label == "" && edge.Condition != nil {
+			label = *edge.Condition
+		}
+		if label == "" {
+			label = "Uses"
+		}
+		fmt.Fprintf(&buf, "        %s -> %s %s", from.ident, to.ident, dslString(label))
+		if technology := metadataText(edge.Metadata, "technology"); technology != "" {
+			fmt.Fprintf(&buf, " %s", dslString(technology))
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("    }\n\n")
+
+	buf.WriteString("    views {\n")
+	fmt.Fprintf(&buf, "        systemContext %s %s {\n            include *\n            autoLayout\n        }\n", system, dslString(system+"-context"))
+	fmt.Fprintf(&buf, "        container %s %s {\n            include *\n            autoLayout\n        }\n", system, dslString(system+"-containers"))
+	buf.WriteString("        styles {\n")
+	buf.WriteString("            element \"Person\" {\n                shape Person\n            }\n")
+	buf.WriteString("            element \"Database\" {\n                shape Cylinder\n            }\n")
+	buf.WriteString("            element \"External\" {\n                background #999999\n            }\n")
+	buf.WriteString("        }\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
+
+// c4Kind returns the C4 element kind for a node, or "" to leave it out
+func c4Kind(node *models.FlowNode) string {
+	switch kind := metadataText(node.Metadata, "c4"); kind {
+	case c4Person, c4SoftwareSystem, c4Container:
+		return kind
+	}
+	switch node.Type {
+	case models.NodeTypeExternal:
+		return c4Person
+	case models.NodeTypeProcess, models.NodeTypeSubprocess, models.NodeTypeData, models.NodeTypeCustom:
+		return c4Container
+	default:
+		return ""
+	}
+}
+
+// metadataText returns a metadata value as text, or "" if it is unset
+func metadataText(metadata map[string]interface{}, key string) string {
+	if value, ok := metadata[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// dslText quotes optional text for the Structurizr DSL
+func dslText(text *string) string {
+	if text == nil {
+		return `""`
+	}
+	return dslString(*text)
+}
+
+// dslString quotes text for the Structurizr DSL
+func dslString(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(text, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// dslTags formats the trailing tags argument, empty when there are none
+func dslTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return " " + dslString(strings.Join(tags, ","))
+}
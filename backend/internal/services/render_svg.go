@@ -0,0 +1,353 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Default node size used when a node has no explicit dimensions
+const (
+	defaultNodeWidth  = 120.0
+	defaultNodeHeight = 60.0
+	svgPadding        = 40.0
+)
+
+// nodeBounds returns the rectangle occupied by a node. Positions are the
+// top-left corner of the node.
+func nodeBounds(node *models.FlowNode) (x, y, w, h float64) {
+	w, h = defaultNodeWidth, defaultNodeHeight
+	if node.Dimensions != nil && node.Dimensions.Width > 0 && node.Dimensions.Height > 0 {
+		w, h = node.Dimensions.Width, node.Dimensions.Height
+	}
+	return node.Position.X, node.Position.Y, w, h
+}
+
+// diagramBounds returns the bounding box of all nodes and edge waypoints
+func diagramBounds(diagram *models.FlowDiagram) (minX, minY, maxX, maxY float64) {
+	first := true
+	extend := func(x1, y1, x2, y2 float64) {
+		if first {
+			minX, minY, maxX, maxY = x1, y1, x2, y2
+			first = false
+			return
+		}
+		minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+		maxX, maxY = math.Max(maxX, x2), math.Max(maxY, y2)
+	}
+	for i := range diagram.Nodes {
+		x, y, w, h := nodeBounds(&diagram.Nodes[i])
+		extend(x, y, x+w, y+h)
+	}
+	for _, edge := range diagram.Edges {
+		for _, p := range edge.Waypoints {
+			extend(p.X, p.Y, p.X, p.Y)
+		}
+	}
+	return minX, minY, maxX, maxY
+}
+
+// nodeColors returns the default fill and stroke for a node type
+func nodeColors(nodeType models.NodeType) (fill, stroke string) {
+	switch nodeType {
+	case models.NodeTypeStart:
+		return "#2ecc71", "#27ae60"
+	case models.NodeTypeEnd:
+		return "#e74c3c", "#c0392b"
+	case models.NodeTypeDecision:
+		return "#f1c40f", "#e67e22"
+	case models.NodeTypeSubprocess:
+		return "#9b59b6", "#8e44ad"
+	case models.NodeTypeData:
+		return "#1abc9c", "#16a085"
+	case models.NodeTypeExternal:
+		return "#95a5a6", "#7f8c8d"
+	default:
+		return "#3498db", "#2980b9"
+	}
+}
+
+// renderSVG draws the diagram as a standalone SVG document
+func renderSVG(diagram *models.FlowDiagram) []byte {
+	minX, minY, maxX, maxY := diagramBounds(diagram)
+	return renderSVGViewport(diagram, minX-svgPadding, minY-svgPadding, maxX-minX+2*svgPadding, maxY-minY+2*svgPadding)
+}
+
+// renderSVGViewport draws the diagram clipped to the given viewport
+func renderSVGViewport(diagram *models.FlowDiagram, vx, vy, vw, vh float64) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="%s %s %s %s">`+"\n",
+		num(vw), num(vh), num(vx), num(vy), num(vw), num(vh))
+	fmt.Fprintf(&buf, "<title>%s</title>\n", html.EscapeString(diagram.Name))
+	fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="#ffffff"/>`+"\n", num(vx), num(vy), num(vw), num(vh))
+
+	nodesByID := make(map[string]*models.FlowNode)
+	for i := range diagram.Nodes {
+		nodesByID[diagram.Nodes[i].ID] = &diagram.Nodes[i]
+	}
+
+	nodes, edges := drawOrder(diagram)
+	buf.WriteString(`<g class="edges">` + "\n")
+	for _, edge := range edges {
+		writeSVGEdge(&buf, edge, nodesByID)
+	}
+	buf.WriteString("</g>\n")
+
+	buf.WriteString(`<g class="nodes">` + "\n")
+	for _, node := range nodes {
+		writeSVGNode(&buf, node)
+	}
+	buf.WriteString("</g>\n")
+
+	if notice := deprecationNotice(diagram); notice != "" {
+		writeSVGWatermark(&buf, notice, vx, vy, vw, vh)
+	}
+	buf.WriteString("</svg>\n")
+
+	return buf.Bytes()
+}
+
+// writeSVGWatermark stamps a deprecated diagram across the viewport
+func writeSVGWatermark(buf *bytes.Buffer, notice string, vx, vy, vw, vh float64) {
+	cx, cy := vx+vw/2, vy+vh/2
+	size := math.Max(24, math.Min(vw, vh)/5)
+	fmt.Fprintf(buf, `<g class="watermark" transform="rotate(-30 %s %s)" fill="#c0392b" fill-opacity="0.25" text-anchor="middle" font-family="Helvetica, Arial, sans-serif" pointer-events="none">`+"\n", num(cx), num(cy))
+	fmt.Fprintf(buf, `<text x="%s" y="%s" font-size="%s" font-weight="bold">DEPRECATED</text>`+"\n", num(cx), num(cy), num(size))
+	fmt.Fprintf(buf, `<text x="%s" y="%s" font-size="%s">%s</text>`+"\n", num(cx), num(cy+size*0.6), num(size/4), html.EscapeString(notice))
+	buf.WriteString("</g>\n")
+}
+
+func writeSVGNode(buf *bytes.Buffer, node *models.FlowNode) {
+	x, y, w, h := nodeBounds(node)
+	fill, stroke := nodeColors(node.Type)
+	strokeWidth := 2.0
+	dash := ""
+	opacity := 1.0
+	fontSize := 14.0
+	fontFamily := "Helvetica, Arial, sans-serif"
+	fontWeight := "normal"
+	textColor := "#ffffff"
+	if st := node.Style; st != nil {
+		if st.Fill != nil {
+			fill = *st.Fill
+		}
+		if st.Stroke != nil {
+			stroke = *st.Stroke
+		}
+		if st.StrokeWidth != nil {
+			strokeWidth = *st.StrokeWidth
+		}
+		if st.StrokeDasharray != nil {
+			dash = *st.StrokeDasharray
+		}
+		if st.Opacity != nil {
+			opacity = *st.Opacity
+		}
+		if st.FontSize != nil {
+			fontSize = *st.FontSize
+		}
+		if st.FontFamily != nil {
+			fontFamily = *st.FontFamily
+		}
+		if st.FontWeight != nil {
+			fontWeight = *st.FontWeight
+		}
+		if st.TextColor != nil {
+			textColor = *st.TextColor
+		}
+	}
+
+	paint := fmt.Sprintf(`fill="%s" stroke="%s" stroke-width="%s" opacity="%s"`,
+		html.EscapeString(fill), html.EscapeString(stroke), num(strokeWidth), num(opacity))
+	if dash != "" {
+		paint += fmt.Sprintf(` stroke-dasharray="%s"`, html.EscapeString(dash))
+	}
+
+	fmt.Fprintf(buf, `<g class="node node-%s" data-id="%s">`, html.EscapeString(string(node.Type)), html.EscapeString(node.ID))
+	switch node.Type {
+	case models.NodeTypeStart, models.NodeTypeEnd:
+		fmt.Fprintf(buf, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" %s/>`, num(x+w/2), num(y+h/2), num(w/2), num(h/2), paint)
+	case models.NodeTypeDecision:
+		fmt.Fprintf(buf, `<polygon points="%s,%s %s,%s %s,%s %s,%s" %s/>`,
+			num(x+w/2), num(y), num(x+w), num(y+h/2), num(x+w/2), num(y+h), num(x), num(y+h/2), paint)
+	case models.NodeTypeData:
+		fmt.Fprintf(buf, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" ry="%s" %s/>`, num(x), num(y), num(w), num(h), num(w/2), num(h/6), paint)
+	case models.NodeTypeSubprocess:
+		fmt.Fprintf(buf, `<rect x="%s" y="%s" width="%s" height="%s" rx="4" %s/>`, num(x), num(y), num(w), num(h), paint)
+		fmt.Fprintf(buf, `<rect x="%s" y="%s" width="%s" height="%s" rx="2" fill="none" stroke="%s"/>`, num(x+4), num(y+4), num(w-8), num(h-8), html.EscapeString(stroke))
+	default:
+		fmt.Fprintf(buf, `<rect x="%s" y="%s" width="%s" height="%s" rx="4" %s/>`, num(x), num(y), num(w), num(h), paint)
+	}
+
+	lines := wrapLabel(node.Name, w, fontSize)
+	lineHeight := fontSize * 1.2
+	top := y + h/2 - lineHeight*float64(len(lines)-1)/2
+	fmt.Fprintf(buf, `<text x="%s" y="%s" text-anchor="middle" dominant-baseline="middle" font-family="%s" font-size="%s" font-weight="%s" fill="%s">`,
+		num(x+w/2), num(top), html.EscapeString(fontFamily), num(fontSize), html.EscapeString(fontWeight), html.EscapeString(textColor))
+	for i, line := range lines {
+		if i == 0 {
+			fmt.Fprintf(buf, `<tspan x="%s">%s</tspan>`, num(x+w/2), html.EscapeString(line))
+		} else {
+			fmt.Fprintf(buf, `<tspan x="%s" dy="%s">%s</tspan>`, num(x+w/2), num(lineHeight), html.EscapeString(line))
+		}
+	}
+	buf.WriteString("</text></g>\n")
+}
+
+func writeSVGEdge(buf *bytes.Buffer, edge *models.FlowEdge, nodesByID map[string]*models.FlowNode) {
+	points := edgePoints(edge, nodesByID)
+	if len(points) < 2 {
+		return
+	}
+
+	stroke := "#555555"
+	strokeWidth := 2.0
+	dash := ""
+	switch edge.Type {
+	case models.ConnectionTypeConditional:
+		dash = "6,4"
+	case models.ConnectionTypeDataFlow:
+		dash = "2,3"
+	case models.ConnectionTypeComposition:
+		strokeWidth = 3
+	}
+	if st := edge.Style; st != nil {
+		if st.Stroke != nil {
+			stroke = *st.Stroke
+		}
+		if st.StrokeWidth != nil {
+			strokeWidth = *st.StrokeWidth
+		}
+		if st.StrokeDasharray != nil {
+			dash = *st.StrokeDasharray
+		}
+	}
+
+	var path strings.Builder
+	for i, p := range points {
+		if i == 0 {
+			fmt.Fprintf(&path, "M %s %s", num(p.X), num(p.Y))
+		} else {
+			fmt.Fprintf(&path, " L %s %s", num(p.X), num(p.Y))
+		}
+	}
+
+	fmt.Fprintf(buf, `<g class="edge edge-%s" data-id="%s"><path d="%s" fill="none" stroke="%s" stroke-width="%s"`,
+		html.EscapeString(string(edge.Type)), html.EscapeString(edge.ID), path.String(), html.EscapeString(stroke), num(strokeWidth))
+	if dash != "" {
+		fmt.Fprintf(buf, ` stroke-dasharray="%s"`, html.EscapeString(dash))
+	}
+	buf.WriteString(`/>`)
+
+	start, end := edgeArrowheads(edge.Style)
+	last := len(points) - 1
+	writeSVGArrowhead(buf, start, points[0], points[1], stroke, strokeWidth)
+	writeSVGArrowhead(buf, end, points[last], points[last-1], stroke, strokeWidth)
+
+	label := edge.Name
+	if edge.Condition != nil && *edge.Condition != "" && label == "" {
+		label = *edge.Condition
+	}
+	if label != "" {
+		mid := points[len(points)/2-1]
+		next := points[len(points)/2]
+		fmt.Fprintf(buf, `<text x="%s" y="%s" text-anchor="middle" font-family="Helvetica, Arial, sans-serif" font-size="12" fill="#333333">%s</text>`,
+			num((mid.X+next.X)/2), num((mid.Y+next.Y)/2-4), html.EscapeString(label))
+	}
+	buf.WriteString("</g>\n")
+}
+
+// writeSVGArrowhead draws an arrowhead at tip, pointing away from from
+func writeSVGArrowhead(buf *bytes.Buffer, arrow models.Arrowhead, tip, from models.Position, stroke string, strokeWidth float64) {
+	shape, closed := arrowheadShape(arrow, [2]float64{tip.X, tip.Y}, [2]float64{from.X, from.Y}, 1)
+	if len(shape) == 0 {
+		return
+	}
+	coords := make([]string, len(shape))
+	for i, p := range shape {
+		coords[i] = num(p[0]) + "," + num(p[1])
+	}
+	if closed {
+		fmt.Fprintf(buf, `<polygon points="%s" fill="%s"/>`, strings.Join(coords, " "), html.EscapeString(stroke))
+	} else {
+		fmt.Fprintf(buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s"/>`, strings.Join(coords, " "), html.EscapeString(stroke), num(strokeWidth))
+	}
+}
+
+// edgePoints returns the polyline of an edge from the border of its source
+// node, through its waypoints, to the border of its target node
+func edgePoints(edge *models.FlowEdge, nodesByID map[string]*models.FlowNode) []models.Position {
+	from, okFrom := nodesByID[edge.From]
+	to, okTo := nodesByID[edge.To]
+	if !okFrom || !okTo {
+		return nil
+	}
+
+	fx, fy, fw, fh := nodeBounds(from)
+	tx, ty, tw, th := nodeBounds(to)
+	fromCenter := models.Position{X: fx + fw/2, Y: fy + fh/2}
+	toCenter := models.Position{X: tx + tw/2, Y: ty + th/2}
+
+	firstTarget, lastSource := toCenter, fromCenter
+	if len(edge.Waypoints) > 0 {
+		firstTarget = edge.Waypoints[0]
+		lastSource = edge.Waypoints[len(edge.Waypoints)-1]
+	}
+
+	points := []models.Position{clipToBox(fromCenter, firstTarget, fw, fh)}
+	points = append(points, edge.Waypoints...)
+	points = append(points, clipToBox(toCenter, lastSource, tw, th))
+	return points
+}
+
+// clipToBox returns the point where the segment from the center of a box
+// towards target leaves the box
+func clipToBox(center, target models.Position, w, h float64) models.Position {
+	dx, dy := target.X-center.X, target.Y-center.Y
+	if dx == 0 && dy == 0 {
+		return center
+	}
+	scale := math.Inf(1)
+	if dx != 0 {
+		scale = math.Min(scale, (w/2)/math.Abs(dx))
+	}
+	if dy != 0 {
+		scale = math.Min(scale, (h/2)/math.Abs(dy))
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	return models.Position{X: center.X + dx*scale, Y: center.Y + dy*scale}
+}
+
+// wrapLabel splits a label into lines that roughly fit the given width
+func wrapLabel(label string, width, fontSize float64) []string {
+	maxChars := int(width / (fontSize * 0.55))
+	if maxChars < 4 {
+		maxChars = 4
+	}
+	words := strings.Fields(label)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	current := words[0]
+	for _, word := range words[1:] {
+		if len(current)+1+len(word) > maxChars {
+			lines = append(lines, current)
+			current = word
+			continue
+		}
+		current += " " + word
+	}
+	return append(lines, current)
+}
+
+// num formats a float compactly for SVG attributes
+func num(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
@@ -0,0 +1,474 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ErrReportNotFound is returned for an unknown scheduled report name
+var ErrReportNotFound = errors.New("report not found")
+
+// Scheduled report formats
+const (
+	ReportFormatHTML = "html"
+	ReportFormatPDF  = "pdf"
+)
+
+// Scheduled report sections
+const (
+	ReportSectionDiagram    = "diagram"    // Rendered picture of each diagram
+	ReportSectionValidation = "validation" // Error and warning counts
+	ReportSectionTiming     = "timing"     // End-to-end durations and SLA breaches
+	ReportSectionCosts      = "costs"      // Expected cost per execution
+)
+
+var reportSections = []string{ReportSectionDiagram, ReportSectionValidation, ReportSectionTiming, ReportSectionCosts}
+
+// ScheduledReport is a digest of diagrams mailed on a cron schedule
+type ScheduledReport struct {
+	Name       string   `yaml:"name" json:"name"`
+	Schedule   string   `yaml:"schedule" json:"schedule"`                     // Cron expression, e.g. "0 8 * * 1"
+	Timezone   string   `yaml:"timezone,omitempty" json:"timezone,omitempty"` // IANA zone for the schedule; server local time when empty
+	Format     string   `yaml:"format,omitempty" json:"format,omitempty"`     // html (default) or pdf
+	Subject    string   `yaml:"subject,omitempty" json:"subject,omitempty"`
+	Recipients []string `yaml:"recipients" json:"recipients"`
+	Diagrams   []string `yaml:"diagrams,omitempty" json:"diagrams,omitempty"` // Diagram IDs
+	Tags       []string `yaml:"tags,omitempty" json:"tags,omitempty"`         // Diagrams with any of these tags
+	Sections   []string `yaml:"sections,omitempty" json:"sections,omitempty"` // Defaults to all sections
+	Lang       string   `yaml:"lang,omitempty" json:"lang,omitempty"`
+}
+
+// ScheduledReportStatus is a scheduled report with its run history
+type ScheduledReportStatus struct {
+	ScheduledReport
+	NextRun   *time.Time `json:"nextRun,omitempty"`
+	LastRun   *time.Time `json:"lastRun,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+type reportRun struct {
+	at  time.Time
+	err string
+}
+
+var (
+	reportRunsMu sync.Mutex
+	reportRuns   = make(map[string]reportRun)
+)
+
+// ReportScheduleService renders and mails scheduled digests
+type ReportScheduleService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+	mailer         *Mailer
+}
+
+// NewReportScheduleService creates a new report schedule service
+func NewReportScheduleService() *ReportScheduleService {
+	return &ReportScheduleService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+		mailer:         NewMailer(),
+	}
+}
+
+// Schedules loads and checks the scheduled report definitions
+func (s *ReportScheduleService) Schedules() ([]ScheduledReport, error) {
+	if s.cfg.ReportsPath == "" {
+		return nil, fmt.Errorf("%w: set REPORT_SCHEDULES_PATH", ErrNotConfigured)
+	}
+	data, err := os.ReadFile(s.cfg.ReportsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report schedules: %w", err)
+	}
+	var file struct {
+		Reports []ScheduledReport `yaml:"reports"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse report schedules: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range file.Reports {
+		report := &file.Reports[i]
+		if report.Name == "" {
+			return nil, fmt.Errorf("report %d has no name", i+1)
+		}
+		if names[report.Name] {
+			return nil, fmt.Errorf("duplicate report name %s", report.Name)
+		}
+		names[report.Name] = true
+		if _, _, err := report.cron(); err != nil {
+			return nil, fmt.Errorf("report %s: %w", report.Name, err)
+		}
+		if report.Format == "" {
+			report.Format = ReportFormatHTML
+		}
+		if report.Format != ReportFormatHTML && report.Format != ReportFormatPDF {
+			return nil, fmt.Errorf("report %s: unknown format %s", report.Name, report.Format)
+		}
+		if len(report.Sections) == 0 {
+			report.Sections = reportSections
+		}
+		for _, section := range report.Sections {
+			if !containsValue(reportSections, section) {
+				return nil, fmt.Errorf("report %s: unknown section %s", report.Name, section)
+			}
+		}
+		if len(report.Recipients) == 0 {
+			return nil, fmt.Errorf("report %s has no recipients", report.Name)
+		}
+		if report.Subject == "" {
+			report.Subject = "FlowGen report: " + report.Name
+		}
+	}
+	return file.Reports, nil
+}
+
+// cron parses the schedule and its time zone
+func (r *ScheduledReport) cron() (*cronSchedule, *time.Location, error) {
+	schedule, err := parseCron(r.Schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+	location := time.Local
+	if r.Timezone != "" {
+		if location, err = time.LoadLocation(r.Timezone); err != nil {
+			return nil, nil, fmt.Errorf("unknown timezone %s", r.Timezone)
+		}
+	}
+	return schedule, location, nil
+}
+
+// Status lists the scheduled reports with their next and last runs
+func (s *ReportScheduleService) Status() ([]ScheduledReportStatus, error) {
+	reports, err := s.Schedules()
+	if err != nil {
+		return nil, err
+	}
+	reportRunsMu.Lock()
+	defer reportRunsMu.Unlock()
+
+	statuses := []ScheduledReportStatus{}
+	for _, report := range reports {
+		status := ScheduledReportStatus{ScheduledReport: report}
+		schedule, location, _ := report.cron()
+		if next := schedule.Next(time.Now().In(location)); !next.IsZero() {
+			status.NextRun = &next
+		}
+		if run, ok := reportRuns[report.Name]; ok {
+			at := run.at
+			status.LastRun, status.LastError = &at, run.err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Find returns the scheduled report with the given name
+func (s *ReportScheduleService) Find(name string) (*ScheduledReport, error) {
+	reports, err := s.Schedules()
+	if err != nil {
+		return nil, err
+	}
+	for i := range reports {
+		if reports[i].Name == name {
+			return &reports[i], nil
+		}
+	}
+	return nil, ErrReportNotFound
+}
+
+// Send renders a report and mails it to its recipients now
+func (s *ReportScheduleService) Send(report *ScheduledReport) error {
+	err := s.send(report)
+	run := reportRun{at: time.Now()}
+	if err != nil {
+		run.err = err.Error()
+	}
+	reportRunsMu.Lock()
+	reportRuns[report.Name] = run
+	reportRunsMu.Unlock()
+	return err
+}
+
+func (s *ReportScheduleService) send(report *ScheduledReport) error {
+	export, err := s.Render(report)
+	if err != nil {
+		return err
+	}
+	msg := MailMessage{To: report.Recipients, Subject: report.Subject}
+	if report.Format == ReportFormatPDF {
+		msg.Text = fmt.Sprintf("The %s report is attached.\n", report.Name)
+		msg.Attachments = []MailAttachment{{
+			Filename:    report.Name + "-" + time.Now().Format("2006-01-02") + ".pdf",
+			ContentType: export.ContentType,
+			Data:        export.Data,
+		}}
+	} else {
+		msg.Text = fmt.Sprintf("The %s report needs an HTML-capable mail client.\n", report.Name)
+		msg.HTML = string(export.Data)
+	}
+	return s.mailer.Send(msg)
+}
+
+// digestEntry is one diagram in a report with its summary lines
+type digestEntry struct {
+	Diagram *models.FlowDiagram
+	Lines   []string
+}
+
+// Render builds the report document without sending it
+func (s *ReportScheduleService) Render(report *ScheduledReport) (*Export, error) {
+	entries, missing, err := s.digest(report)
+	if err != nil {
+		return nil, err
+	}
+	generated := time.Now()
+	if report.Format == ReportFormatPDF {
+		return &Export{Data: renderDigestPDF(report, entries, missing, generated), ContentType: "application/pdf", Extension: "pdf"}, nil
+	}
+	data, err := renderDigestHTML(report, entries, missing, generated)
+	if err != nil {
+		return nil, err
+	}
+	return &Export{Data: data, ContentType: "text/html; charset=utf-8", Extension: "html"}, nil
+}
+
+// digest selects the report's diagrams and summarizes them. IDs that no
+// longer exist are returned separately so the report can mention them.
+func (s *ReportScheduleService) digest(report *ScheduledReport) ([]digestEntry, []string, error) {
+	diagrams, err := s.diagramService.ListAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selected := []models.FlowDiagram{}
+	missing := []string{}
+	if len(report.Diagrams) == 0 && len(report.Tags) == 0 {
+		selected = diagrams
+	} else {
+		found := make(map[string]bool)
+		for _, diagram := range diagrams {
+			hasTag := false
+			for _, tag := range diagram.Tags {
+				if containsValue(report.Tags, tag) {
+					hasTag = true
+				}
+			}
+			if containsValue(report.Diagrams, diagram.ID) || hasTag {
+				selected = append(selected, diagram)
+				found[diagram.ID] = true
+			}
+		}
+		for _, id := range report.Diagrams {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+	}
+
+	entries := []digestEntry{}
+	for i := range selected {
+		diagram := &selected[i]
+		entry := digestEntry{Diagram: diagram, Lines: s.summaryLines(diagram, report.Sections)}
+		s.diagramService.Localize(diagram, report.Lang)
+		if styled, err := s.diagramService.withResolvedStyles(diagram); err == nil {
+			entry.Diagram = styled
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Diagram.Name < entries[j].Diagram.Name })
+	return entries, missing, nil
+}
+
+// summaryLines describes a diagram's validation, timing and costs in a few
+// plain sentences
+func (s *ReportScheduleService) summaryLines(diagram *models.FlowDiagram, sections []string) []string {
+	lines := []string{}
+	if notice := deprecationNotice(diagram); notice != "" {
+		lines = append(lines, notice)
+	}
+	if containsValue(sections, ReportSectionValidation) {
+		if result, err := s.diagramService.Validate(diagram); err != nil {
+			lines = append(lines, "Validation unavailable: "+err.Error())
+		} else if result.Valid && len(result.Warnings) == 0 {
+			lines = append(lines, "Validation: no problems")
+		} else {
+			lines = append(lines, fmt.Sprintf("Validation: %s, %s", plural(len(result.Errors), "error"), plural(len(result.Warnings), "warning")))
+		}
+	}
+	if containsValue(sections, ReportSectionTiming) {
+		if timing, err := s.diagramService.Timing(diagram.ID); err != nil {
+			lines = append(lines, "Timing unavailable: "+err.Error())
+		} else if timing.Overall.WorstSeconds > 0 {
+			lines = append(lines, fmt.Sprintf("Duration: %s to %s end to end, %s",
+				timing.Overall.Best, timing.Overall.Worst, plural(len(timing.Breaches), "SLA breach")))
+		}
+	}
+	if containsValue(sections, ReportSectionCosts) {
+		if costs, err := s.diagramService.Costs(diagram.ID); err != nil {
+			lines = append(lines, "Costs unavailable: "+err.Error())
+		} else if costs.MaxPathCost > 0 {
+			lines = append(lines, strings.TrimSpace(fmt.Sprintf("Expected cost: %.2f %s per execution (%.2f to %.2f per path)",
+				costs.ExpectedCost, costs.Currency, costs.MinPathCost, costs.MaxPathCost)))
+		}
+	}
+	return lines
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if strings.HasSuffix(noun, "ch") {
+		return fmt.Sprintf("%d %ses", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 960px;">
+<h1 style="font-size: 22px;">{{.Subject}}</h1>
+<p style="color: #555;">Generated {{.Generated}} &middot; {{.Count}}</p>
+{{range .Missing}}<p style="color: #c0392b;">Diagram {{.}} was not found.</p>
+{{end}}{{range .Entries}}<h2 style="font-size: 18px; border-top: 1px solid #ddd; padding-top: 12px;">{{.Name}}</h2>
+{{if .Description}}<p>{{.Description}}</p>
+{{end}}{{if .Lines}}<ul>{{range .Lines}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{if .SVG}}<div style="overflow-x: auto;">{{.SVG}}</div>
+{{end}}{{end}}</body>
+</html>
+`))
+
+func renderDigestHTML(report *ScheduledReport, entries []digestEntry, missing []string, generated time.Time) ([]byte, error) {
+	type htmlEntry struct {
+		Name        string
+		Description string
+		Lines       []string
+		SVG         template.HTML
+	}
+	data := struct {
+		Subject   string
+		Generated string
+		Count     string
+		Missing   []string
+		Entries   []htmlEntry
+	}{Subject: report.Subject, Generated: generated.Format("2006-01-02 15:04 MST"), Count: plural(len(entries), "diagram"), Missing: missing}
+
+	for _, entry := range entries {
+		item := htmlEntry{Name: entry.Diagram.Name, Lines: entry.Lines}
+		if entry.Diagram.Description != nil {
+			item.Description = *entry.Diagram.Description
+		}
+		if containsValue(report.Sections, ReportSectionDiagram) {
+			item.SVG = template.HTML(renderSVG(entry.Diagram))
+		}
+		data.Entries = append(data.Entries, item)
+	}
+
+	var buf bytes.Buffer
+	if err := digestTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderDigestPDF writes a summary of all diagrams followed by one page
+// per diagram when the diagram section is selected
+func renderDigestPDF(report *ScheduledReport, entries []digestEntry, missing []string, generated time.Time) []byte {
+	pageW, pageH, _ := paperSize("a4", false)
+	doc := newPDFDocument(report.Subject)
+
+	const lineHeight = 14.0
+	page := doc.AddPage(pageW, pageH)
+	drawPDFTitle(page, pageW, report.Subject, generated.Format("2006-01-02 15:04 MST"))
+	y := pdfMargin + pdfHeaderHeight + lineHeight
+	line := func(text string, size float64, bold bool, indent float64) {
+		if y > pageH-pdfMargin {
+			page = doc.AddPage(pageW, pageH)
+			drawPDFTitle(page, pageW, report.Subject, "")
+			y = pdfMargin + pdfHeaderHeight + lineHeight
+		}
+		page.SetFill("#222222")
+		page.Text(pdfMargin+indent, y, size, bold, "left", text)
+		y += lineHeight
+	}
+
+	for _, id := range missing {
+		line(fmt.Sprintf("Diagram %s was not found.", id), 10, false, 0)
+	}
+	for _, entry := range entries {
+		y += lineHeight / 2
+		line(entry.Diagram.Name, 12, true, 0)
+		for _, text := range entry.Lines {
+			line(text, 10, false, 12)
+		}
+	}
+
+	if containsValue(report.Sections, ReportSectionDiagram) {
+		for _, entry := range entries {
+			canvas := doc.AddPage(pageH, pageW)
+			drawPDFWatermark(canvas, entry.Diagram, pageH, pageW)
+			drawPDFTitle(canvas, pageH, entry.Diagram.Name, report.Subject)
+			drawDiagramFitted(canvas, entry.Diagram, pdfMargin, pdfMargin+pdfHeaderHeight, pageH-2*pdfMargin, pageW-2*pdfMargin-pdfHeaderHeight)
+		}
+	}
+	return doc.Bytes()
+}
+
+// StartReportScheduler mails scheduled reports when their cron schedule
+// fires. Definitions are reloaded every minute so edits apply without a
+// restart.
+func StartReportScheduler() {
+	s := NewReportScheduleService()
+	if s.cfg.ReportsPath == "" {
+		return
+	}
+	reports, err := s.Schedules()
+	if err != nil {
+		log.Printf("Report scheduler: %v", err)
+	} else {
+		log.Printf("Report scheduler started with %d reports", len(reports))
+	}
+
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			tick := time.Now().Truncate(time.Minute)
+
+			reports, err := s.Schedules()
+			if err != nil {
+				log.Printf("Report scheduler: %v", err)
+				continue
+			}
+			for i := range reports {
+				report := &reports[i]
+				schedule, location, _ := report.cron()
+				if !schedule.Matches(tick.In(location)) {
+					continue
+				}
+				go func() {
+					if err := NewReportScheduleService().Send(report); err != nil {
+						log.Printf("Scheduled report %s failed: %v", report.Name, err)
+					}
+				}()
+			}
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"math"
+	"sort"
+)
+
+// rtreeFanout is the most entries an R-tree node holds
+const rtreeFanout = 16
+
+// rtreeEntry is an indexed box with the element it belongs to
+type rtreeEntry struct {
+	box     Rect
+	node    int // Position in the diagram's Nodes, or -1
+	edge    int // Position in the diagram's Edges, or -1
+	segment int // Segment of the edge's drawn polyline
+}
+
+// rtree is a static R-tree. Diagrams are re-indexed as a whole when they
+// change, so it is bulk-loaded with Sort-Tile-Recursive packing instead of
+// supporting inserts.
+type rtree struct {
+	root *rtreeNode
+}
+
+type rtreeNode struct {
+	box      Rect
+	children []*rtreeNode // Empty in leaves
+	entries  []rtreeEntry // Only in leaves
+}
+
+func newRTree(entries []rtreeEntry) *rtree {
+	if len(entries) == 0 {
+		return &rtree{}
+	}
+	level := make([]*rtreeNode, 0, len(entries)/rtreeFanout+1)
+	for _, group := range strPack(len(entries), func(i int) Rect { return entries[i].box }) {
+		leaf := &rtreeNode{entries: make([]rtreeEntry, len(group))}
+		for j, i := range group {
+			leaf.entries[j] = entries[i]
+		}
+		leaf.box = leaf.entries[0].box
+		for _, entry := range leaf.entries[1:] {
+			leaf.box = leaf.box.union(entry.box)
+		}
+		level = append(level, leaf)
+	}
+	for len(level) > 1 {
+		nodes := level
+		level = make([]*rtreeNode, 0, len(nodes)/rtreeFanout+1)
+		for _, group := range strPack(len(nodes), func(i int) Rect { return nodes[i].box }) {
+			parent := &rtreeNode{children: make([]*rtreeNode, len(group))}
+			for j, i := range group {
+				parent.children[j] = nodes[i]
+			}
+			parent.box = parent.children[0].box
+			for _, child := range parent.children[1:] {
+				parent.box = parent.box.union(child.box)
+			}
+			level = append(level, parent)
+		}
+	}
+	return &rtree{root: level[0]}
+}
+
+// strPack groups n boxes into runs of at most rtreeFanout that lie close
+// together: sorted into vertical slices by center x, then by center y
+// within each slice
+func strPack(n int, box func(int) Rect) [][]int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	centerX := func(i int) float64 { b := box(i); return b.X + b.W/2 }
+	centerY := func(i int) float64 { b := box(i); return b.Y + b.H/2 }
+	sort.SliceStable(order, func(a, b int) bool { return centerX(order[a]) < centerX(order[b]) })
+
+	leaves := int(math.Ceil(float64(n) / rtreeFanout))
+	sliceSize := int(math.Ceil(math.Sqrt(float64(leaves)))) * rtreeFanout
+	var groups [][]int
+	for start := 0; start < n; start += sliceSize {
+		slice := order[start:min(start+sliceSize, n)]
+		sort.SliceStable(slice, func(a, b int) bool { return centerY(slice[a]) < centerY(slice[b]) })
+		for i := 0; i < len(slice); i += rtreeFanout {
+			groups = append(groups, slice[i:min(i+rtreeFanout, len(slice))])
+		}
+	}
+	return groups
+}
+
+// search calls fn for every entry whose box intersects r
+func (t *rtree) search(r Rect, fn func(rtreeEntry)) {
+	if t.root != nil {
+		t.root.search(r, fn)
+	}
+}
+
+func (n *rtreeNode) search(r Rect, fn func(rtreeEntry)) {
+	if !n.box.intersects(r) {
+		return
+	}
+	for _, child := range n.children {
+		child.search(r, fn)
+	}
+	for _, entry := range n.entries {
+		if entry.box.intersects(r) {
+			fn(entry)
+		}
+	}
+}
+
+// union returns the smallest rectangle containing both
+func (r Rect) union(o Rect) Rect {
+	x, y := math.Min(r.X, o.X), math.Min(r.Y, o.Y)
+	return Rect{X: x, Y: y, W: math.Max(r.X+r.W, o.X+o.W) - x, H: math.Max(r.Y+r.H, o.Y+o.H) - y}
+}
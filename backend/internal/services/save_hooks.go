@@ -0,0 +1,256 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Save hook stages
+const (
+	HookPreSave  = "pre-save"  // Runs before a diagram is written; can veto the save
+	HookPostSave = "post-save" // Runs in the background after a diagram was written or deleted
+)
+
+// Save hook events
+const (
+	SaveEventCreate = "create"
+	SaveEventUpdate = "update"
+	SaveEventDelete = "delete"
+)
+
+const defaultHookTimeout = 10 * time.Second
+
+// SaveHook is an external command or HTTP endpoint called around saves.
+// Both receive {"event": ..., "diagram": ...} as JSON, on stdin or as the
+// POST body. A pre-save hook vetoes by exiting non-zero or answering with
+// a 4xx status, optionally with {"errors": [{"path", "message", "code"}]}.
+type SaveHook struct {
+	Name     string   `yaml:"name"`
+	Stage    string   `yaml:"stage"`
+	Command  []string `yaml:"command,omitempty"` // Program and arguments
+	URL      string   `yaml:"url,omitempty"`
+	Timeout  string   `yaml:"timeout,omitempty"`  // Default 10s
+	Optional bool     `yaml:"optional,omitempty"` // Allow the save when a pre-save hook itself fails
+}
+
+// SaveVetoError is returned when a pre-save hook rejects a diagram. Hook
+// messages are passed through as given and are not translated.
+type SaveVetoError struct {
+	Hook   string
+	Errors []models.ValidationError
+}
+
+func (e *SaveVetoError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Message
+	}
+	return fmt.Sprintf("save rejected by hook %s: %s", e.Hook, strings.Join(messages, "; "))
+}
+
+// saveHookPayload is what hooks receive
+type saveHookPayload struct {
+	Event   string              `json:"event"`
+	Diagram *models.FlowDiagram `json:"diagram"`
+	Summary string              `json:"summary,omitempty"` // Change summary given by the client, post-save only
+}
+
+// loadSaveHooks reads the hooks of a stage from SAVE_HOOKS_PATH. No hooks
+// run when it is unset.
+func loadSaveHooks(cfg *config.Config, stage string) ([]SaveHook, error) {
+	if cfg.SaveHooksPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(cfg.SaveHooksPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read save hooks: %w", err)
+	}
+	var file struct {
+		Hooks []SaveHook `yaml:"hooks"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse save hooks: %w", err)
+	}
+
+	hooks := []SaveHook{}
+	for _, hook := range file.Hooks {
+		if hook.Stage != HookPreSave && hook.Stage != HookPostSave {
+			return nil, fmt.Errorf("save hook %s: unknown stage %q", hook.Name, hook.Stage)
+		}
+		if (len(hook.Command) == 0) == (hook.URL == "") {
+			return nil, fmt.Errorf("save hook %s: set either command or url", hook.Name)
+		}
+		if hook.Stage == stage {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+// runPreSaveHooks runs the pre-save hooks in order and stops at the first
+// veto, which is returned as a *SaveVetoError
+func runPreSaveHooks(cfg *config.Config, diagram *models.FlowDiagram, event string) error {
+	hooks, err := loadSaveHooks(cfg, HookPreSave)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		veto, err := hook.run(saveHookPayload{Event: event, Diagram: diagram})
+		if err != nil {
+			if hook.Optional {
+				log.Printf("Optional save hook %s failed: %v", hook.Name, err)
+				continue
+			}
+			return fmt.Errorf("save hook %s failed: %w", hook.Name, err)
+		}
+		if veto != nil {
+			return veto
+		}
+	}
+	return nil
+}
+
+// runPostSaveHooks runs the post-save hooks in the background, like
+// gitSyncAfterSave, logging failures
+func runPostSaveHooks(cfg *config.Config, diagram *models.FlowDiagram, event, summary string) {
+	if cfg.SaveHooksPath == "" {
+		return
+	}
+	snapshot := *diagram
+	go func() {
+		hooks, err := loadSaveHooks(cfg, HookPostSave)
+		if err != nil {
+			log.Printf("Post-save hooks: %v", err)
+			return
+		}
+		for _, hook := range hooks {
+			if _, err := hook.run(saveHookPayload{Event: event, Diagram: &snapshot, Summary: summary}); err != nil {
+				log.Printf("Post-save hook %s failed for %s: %v", hook.Name, snapshot.ID, err)
+			}
+		}
+	}()
+}
+
+// run calls the hook. It returns a veto when the hook rejected the payload
+// and an error when the hook could not be run.
+func (h *SaveHook) run(payload saveHookPayload) (*SaveVetoError, error) {
+	timeout := defaultHookTimeout
+	if h.Timeout != "" {
+		d, err := time.ParseDuration(h.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q", h.Timeout)
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.Command) > 0 {
+		return h.runCommand(ctx, body, payload)
+	}
+	return h.runHTTP(ctx, body)
+}
+
+func (h *SaveHook) runCommand(ctx context.Context, body []byte, payload saveHookPayload) (*SaveVetoError, error) {
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "FLOWGEN_EVENT="+payload.Event, "FLOWGEN_DIAGRAM_ID="+payload.Diagram.ID)
+	stdout, stderr := &limitedBuffer{limit: 1 << 20}, &limitedBuffer{limit: 1 << 20}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || ctx.Err() != nil) {
+		return nil, err
+	}
+	if err == nil {
+		return nil, nil
+	}
+	message := strings.TrimSpace(stderr.String())
+	if message == "" {
+		message = strings.TrimSpace(stdout.String())
+	}
+	return h.veto(stdout.Bytes(), message), nil
+}
+
+func (h *SaveHook) runHTTP(ctx context.Context, body []byte) (*SaveVetoError, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return h.veto(data, strings.TrimSpace(string(data))), nil
+	}
+	return nil, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards
+// the rest, so a chatty process cannot exhaust memory. Writes never fail,
+// which keeps the process from dying on a broken pipe.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// veto builds the rejection from a hook's {"errors": [...]} output, or
+// from its plain-text output when that is not JSON
+func (h *SaveHook) veto(output []byte, message string) *SaveVetoError {
+	var response struct {
+		Errors []models.ValidationError `json:"errors"`
+	}
+	veto := &SaveVetoError{Hook: h.Name}
+	if json.Unmarshal(output, &response) == nil && len(response.Errors) > 0 {
+		veto.Errors = response.Errors
+	} else {
+		if message == "" {
+			message = "rejected by save hook " + h.Name
+		}
+		veto.Errors = []models.ValidationError{{Message: message}}
+	}
+	for i := range veto.Errors {
+		if veto.Errors[i].Code == "" {
+			veto.Errors[i].Code = "SAVE_HOOK_REJECTED"
+		}
+	}
+	return veto
+}
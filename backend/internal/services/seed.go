@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ErrSeedingDisabled is returned when seeding is attempted in production
+var ErrSeedingDisabled = errors.New("seeding is disabled in production")
+
+// seedTime stands in for timestamps a seed file leaves unset, so that
+// repeated runs write the same files
+var seedTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SeedResult reports what a seed run changed
+type SeedResult struct {
+	Dir    string   `json:"dir"`
+	Wiped  int      `json:"wiped"`  // Diagram files removed before seeding
+	Seeded []string `json:"seeded"` // IDs of the diagrams written
+}
+
+// SeedDevelopmentDiagrams loads the diagrams in FLOWGEN_SEED_DIR when
+// running in development, keeping diagrams that are not in the seed set
+func SeedDevelopmentDiagrams() {
+	cfg := config.Load()
+	if cfg.SeedDir == "" || cfg.Environment != "development" {
+		return
+	}
+	result, err := NewDiagramService().Seed(false)
+	if err != nil {
+		log.Printf("Seeding diagrams failed: %v", err)
+		return
+	}
+	log.Printf("Seeded %d diagrams from %s", len(result.Seeded), result.Dir)
+}
+
+// Seed writes the diagrams in FLOWGEN_SEED_DIR to the diagrams path, over
+// any diagram with the same ID. With wipe every other diagram file,
+// archived ones included, is removed first, leaving exactly the seed set
+// for e2e runs; files in read-only roots are kept. Seeds are validated before anything changes and keep the
+// timestamps in their files, so repeated runs produce the same files.
+// Seeding is refused in production.
+func (s *DiagramService) Seed(wipe bool) (*SeedResult, error) {
+	if s.cfg.SeedDir == "" {
+		return nil, fmt.Errorf("%w: set FLOWGEN_SEED_DIR", ErrNotConfigured)
+	}
+	if s.cfg.Environment == "production" {
+		return nil, ErrSeedingDisabled
+	}
+	seeds, err := s.loadSeeds()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SeedResult{Dir: s.cfg.SeedDir, Seeded: []string{}}
+	existing := make(map[string]string)
+	var files []string
+	err = s.walkDiagramFiles(func(path string, diagram *models.FlowDiagram, err error) {
+		files = append(files, path)
+		if err == nil {
+			existing[diagram.ID] = path
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if wipe {
+		for _, path := range files {
+			if s.checkWritable(path) != nil {
+				continue
+			}
+			if err := removeDiagramFile(path); err != nil {
+				return nil, fmt.Errorf("failed to delete diagram file: %w", err)
+			}
+			result.Wiped++
+		}
+		for id, path := range existing {
+			if s.checkWritable(path) == nil {
+				delete(existing, id)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(s.cfg.DiagramsPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create diagrams directory: %w", err)
+	}
+	for i := range seeds {
+		seed := &seeds[i]
+		seed.FilePath = filepath.Join(s.cfg.DiagramsPath, seed.ID+".yaml")
+		if path, ok := existing[seed.ID]; ok {
+			seed.FilePath = path
+		}
+		if err := s.checkWritable(seed.FilePath); err != nil {
+			return nil, err
+		}
+		if err := s.saveDiagramToFile(seed, seed.FilePath); err != nil {
+			return nil, err
+		}
+		result.Seeded = append(result.Seeded, seed.ID)
+	}
+	gitSyncAfterSave(s.cfg, "Seed diagrams from "+s.cfg.SeedDir)
+	return result, nil
+}
+
+// loadSeeds reads and validates the diagrams of the seed directory in file
+// name order
+func (s *DiagramService) loadSeeds() ([]models.FlowDiagram, error) {
+	entries, err := os.ReadDir(s.cfg.SeedDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".yaml") || strings.HasSuffix(entry.Name(), ".yml")) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	seeds := []models.FlowDiagram{}
+	seen := make(map[string]string)
+	for _, name := range names {
+		seed, err := s.loadDiagramFromFile(filepath.Join(s.cfg.SeedDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: seed %s: %v", ErrInvalidDiagram, name, err)
+		}
+		if other, ok := seen[seed.ID]; ok {
+			return nil, fmt.Errorf("%w: seeds %s and %s both define %s", ErrInvalidDiagram, other, name, seed.ID)
+		}
+		seen[seed.ID] = name
+		if seed.Created.IsZero() {
+			seed.Created = seedTime
+		}
+		if seed.Updated.IsZero() {
+			seed.Updated = seed.Created
+		}
+		validation, err := s.Validate(seed)
+		if err != nil {
+			return nil, err
+		}
+		if !validation.Valid {
+			return nil, fmt.Errorf("%w: seed %s: %s", ErrInvalidDiagram, name, validation.Errors[0].Message)
+		}
+		seeds = append(seeds, *seed)
+	}
+	return seeds, nil
+}
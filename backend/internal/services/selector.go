@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Selector element scopes
+const (
+	SelectNodes = "nodes"
+	SelectEdges = "edges"
+	SelectAll   = "all"
+)
+
+// ElementSelector matches nodes and edges within a diagram. All populated
+// criteria must match; an empty selector matches every element in scope.
+type ElementSelector struct {
+	Elements  string                 `json:"elements"`  // nodes, edges or all (default)
+	NodeTypes []string               `json:"nodeTypes"` // Node types to match
+	EdgeTypes []string               `json:"edgeTypes"` // Edge types to match
+	Tags      []string               `json:"tags"`      // Element must carry every tag
+	Metadata  map[string]interface{} `json:"metadata"`  // Key/value predicates; "*" matches any value
+	Owners    []string               `json:"owners"`    // Element must have one of these in any ownership role
+}
+
+// Validate checks that the selector scope is known
+func (sel ElementSelector) Validate() error {
+	switch sel.Elements {
+	case "", SelectNodes, SelectEdges, SelectAll:
+		return nil
+	default:
+		return fmt.Errorf("unknown selector elements: %s", sel.Elements)
+	}
+}
+
+// MatchesNode reports whether the node is selected
+func (sel ElementSelector) MatchesNode(node *models.FlowNode) bool {
+	if sel.Elements == SelectEdges {
+		return false
+	}
+	// Edge type criteria only make sense for edges
+	if len(sel.EdgeTypes) > 0 && sel.Elements != SelectNodes {
+		return false
+	}
+	if len(sel.NodeTypes) > 0 && !containsFold(sel.NodeTypes, string(node.Type)) {
+		return false
+	}
+	return sel.matchesEntity(&node.FlowEntity)
+}
+
+// MatchesEdge reports whether the edge is selected
+func (sel ElementSelector) MatchesEdge(edge *models.FlowEdge) bool {
+	if sel.Elements == SelectNodes {
+		return false
+	}
+	// Node type criteria only make sense for nodes
+	if len(sel.NodeTypes) > 0 && sel.Elements != SelectEdges {
+		return false
+	}
+	if len(sel.EdgeTypes) > 0 && !containsFold(sel.EdgeTypes, string(edge.Type)) {
+		return false
+	}
+	return sel.matchesEntity(&edge.FlowEntity)
+}
+
+func (sel ElementSelector) matchesEntity(entity *models.FlowEntity) bool {
+	for _, tag := range sel.Tags {
+		if !containsFold(entity.Tags, tag) {
+			return false
+		}
+	}
+	if len(sel.Owners) > 0 {
+		owned := false
+		for _, owner := range sel.Owners {
+			if ownedBy(entity.Ownership, owner, "") {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			return false
+		}
+	}
+	for key, want := range sel.Metadata {
+		got, ok := entity.Metadata[key]
+		if !ok {
+			return false
+		}
+		if want == "*" {
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+)
+
+// Startup check results
+const (
+	CheckOK      = "ok"
+	CheckWarning = "warning"
+	CheckError   = "error"
+)
+
+// StartupCheck is one check of the configuration made on startup
+type StartupCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok, warning or error
+	Message string `json:"message,omitempty"`
+}
+
+// ServerStatus summarizes the state of the server for operators
+type ServerStatus struct {
+	StartedAt   time.Time          `json:"startedAt"`
+	Uptime      string             `json:"uptime"`
+	GoVersion   string             `json:"goVersion"`
+	Environment string             `json:"environment"`
+	Roots       []DiagramRoot      `json:"roots"`
+	Index       IndexStatus        `json:"index"`
+	Checks      []StartupCheck     `json:"checks"`
+	LoadErrors  []DiagramLoadError `json:"loadErrors"`
+}
+
+var (
+	startupMu     sync.Mutex
+	startedAt     = time.Now()
+	startupChecks = []StartupCheck{}
+)
+
+// RunStartupChecks checks the diagrams path and the configured files once
+// on startup, logging problems; the results are kept for the status
+func RunStartupChecks() {
+	cfg := config.Load()
+	checks := []StartupCheck{checkDiagramsPath(cfg.DiagramsPath)}
+	roots := NewDiagramService().diagramRoots()
+	for _, root := range roots[1:] {
+		check := StartupCheck{Name: "DIAGRAM_ROOTS", Status: CheckOK, Message: root.Name + "=" + root.Path}
+		if info, err := os.Stat(root.Path); err != nil || !info.IsDir() {
+			check.Status, check.Message = CheckError, fmt.Sprintf("%s is not a directory", root.Path)
+		}
+		checks = append(checks, check)
+	}
+	for _, name := range cfg.DiagramReadOnlyRoots {
+		found := false
+		for _, root := range roots {
+			found = found || root.Name == name
+		}
+		if !found {
+			checks = append(checks, StartupCheck{Name: "DIAGRAM_READONLY_ROOTS", Status: CheckWarning,
+				Message: fmt.Sprintf("no diagram root is named %s", name)})
+		}
+	}
+	optional := []struct{ variable, path string }{
+		{"DIRECTORY_PATH", cfg.DirectoryPath},
+		{"CONTROLS_PATH", cfg.ControlsPath},
+		{"IMPORT_SOURCES_PATH", cfg.SourcesPath},
+		{"META_SCHEMA_PATH", cfg.MetaSchemaPath},
+		{"STYLE_THEME_PATH", cfg.StyleThemePath},
+		{"SAVE_HOOKS_PATH", cfg.SaveHooksPath},
+		{"REPORT_SCHEDULES_PATH", cfg.ReportsPath},
+		{"NOTIFICATION_TEMPLATES_PATH", cfg.NotificationTemplatesPath},
+		{"WASM_PLUGINS_PATH", cfg.WasmPluginsPath},
+		{"NODE_CATALOG_PATH", cfg.NodeCatalogPath},
+		{"TERMINOLOGY_PATH", cfg.TerminologyPath},
+	}
+	if cfg.ValidationWebhookURL != "" && cfg.ValidationWebhookFailure != WebhookFailOpen && cfg.ValidationWebhookFailure != WebhookFailClosed {
+		checks = append(checks, StartupCheck{Name: "VALIDATION_WEBHOOK_FAILURE", Status: CheckWarning,
+			Message: fmt.Sprintf("unknown policy %s; saves are refused when the webhook fails", cfg.ValidationWebhookFailure)})
+	}
+	if cfg.JiraOAuthClientID != "" && (cfg.JiraOAuthClientSecret == "" || cfg.JiraOAuthRedirectURL == "") {
+		checks = append(checks, StartupCheck{Name: "JIRA_OAUTH_CLIENT_ID", Status: CheckWarning,
+			Message: "JIRA_OAUTH_CLIENT_SECRET and JIRA_OAUTH_REDIRECT_URL are needed to connect Jira"})
+	}
+	if cfg.DiagramLoading != LoadingLenient && cfg.DiagramLoading != LoadingStrict {
+		checks = append(checks, StartupCheck{Name: "DIAGRAM_LOADING", Status: CheckWarning,
+			Message: fmt.Sprintf("unknown mode %s; files that fail to load are skipped", cfg.DiagramLoading)})
+	}
+	for _, file := range optional {
+		if file.path == "" {
+			continue
+		}
+		check := StartupCheck{Name: file.variable, Status: CheckOK}
+		if _, err := os.Stat(file.path); err != nil {
+			check.Status, check.Message = CheckWarning, fmt.Sprintf("%s does not exist", file.path)
+		}
+		checks = append(checks, check)
+	}
+
+	for _, check := range checks {
+		if check.Status != CheckOK {
+			log.Printf("Startup check %s: %s: %s", check.Name, check.Status, check.Message)
+		}
+	}
+	startupMu.Lock()
+	startupChecks = checks
+	startupMu.Unlock()
+}
+
+// checkDiagramsPath checks that diagrams can be read and written
+func checkDiagramsPath(path string) StartupCheck {
+	check := StartupCheck{Name: "DIAGRAMS_PATH", Status: CheckOK}
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		check.Status, check.Message = CheckError, fmt.Sprintf("%s is not a directory", path)
+		return check
+	}
+	probe, err := os.CreateTemp(path, ".flowgen-write-check-*")
+	if err != nil {
+		check.Status, check.Message = CheckError, fmt.Sprintf("%s is not writable: %v", path, err)
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return check
+}
+
+// Status returns the startup checks, the progress of the diagram index
+// and the diagram files that could not be parsed
+func (s *DiagramService) Status() *ServerStatus {
+	startupMu.Lock()
+	checks := append([]StartupCheck(nil), startupChecks...)
+	startupMu.Unlock()
+
+	return &ServerStatus{
+		StartedAt:   startedAt,
+		Uptime:      time.Since(startedAt).Round(time.Second).String(),
+		GoVersion:   runtime.Version(),
+		Environment: s.cfg.Environment,
+		Roots:       s.diagramRoots(),
+		Index:       DiagramIndexStatus(),
+		Checks:      checks,
+		LoadErrors:  s.quarantined(),
+	}
+}
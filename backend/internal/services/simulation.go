@@ -0,0 +1,272 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Monte Carlo limits
+const (
+	defaultSimulationRuns  = 1000
+	maxSimulationRuns      = 100000
+	defaultSimulationSteps = 1000
+	maxSimulationSteps     = 10000
+	maxSimulationWork      = maxSimulationRuns * defaultSimulationSteps // Bounds runs x maxSteps
+	simulationTopPaths     = 20
+)
+
+// MonteCarloOptions configures a Monte Carlo simulation
+type MonteCarloOptions struct {
+	Runs     int    `json:"runs"`     // Number of executions to simulate (default 1000)
+	Seed     *int64 `json:"seed"`     // Fixes the random sequence for reproducible results
+	MaxSteps int    `json:"maxSteps"` // Nodes visited before a run is abandoned as looping (default 1000)
+}
+
+// DistributionStats summarizes a sampled quantity
+type DistributionStats struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	Max  float64 `json:"max"`
+}
+
+// PathFrequency counts how often a path was taken
+type PathFrequency struct {
+	Nodes     []string `json:"nodes"`
+	Count     int      `json:"count"`
+	Frequency float64  `json:"frequency"`
+}
+
+// MonteCarloResult is the outcome of a Monte Carlo simulation. Durations are
+// in seconds; cost is in the diagram's currency.
+type MonteCarloResult struct {
+	DiagramID     string            `json:"diagramId"`
+	Runs          int               `json:"runs"`
+	Seed          int64             `json:"seed"`
+	Duration      DistributionStats `json:"duration"`
+	Cost          DistributionStats `json:"cost"`
+	Paths         []PathFrequency   `json:"paths"`         // Most frequent paths first
+	DistinctPaths int               `json:"distinctPaths"` // Including paths beyond the top list
+	Abandoned     int               `json:"abandoned"`     // Runs stopped after MaxSteps
+}
+
+// sampler draws step durations from a node or edge duration
+type sampler struct {
+	min, max, mode time.Duration
+	distribution   string
+}
+
+func newSampler(d *models.Duration) (sampler, error) {
+	min, max, err := durationBounds(d)
+	if err != nil {
+		return sampler{}, err
+	}
+	s := sampler{min: min, max: max, mode: (min + max) / 2}
+	if d == nil {
+		return s, nil
+	}
+	s.distribution = d.Distribution
+	if d.Mode != "" {
+		if s.mode, err = ParseDuration(d.Mode); err != nil {
+			return sampler{}, err
+		}
+	}
+	return s, nil
+}
+
+func (s sampler) sample(rng *rand.Rand) time.Duration {
+	if s.max <= s.min || s.distribution == models.DistributionFixed {
+		return s.max
+	}
+	a, b, c := float64(s.min), float64(s.max), float64(s.mode)
+	u := rng.Float64()
+	if s.distribution == models.DistributionTriangular {
+		if u < (c-a)/(b-a) {
+			return time.Duration(a + math.Sqrt(u*(b-a)*(c-a)))
+		}
+		return time.Duration(b - math.Sqrt((1-u)*(b-a)*(b-c)))
+	}
+	return time.Duration(a + u*(b-a))
+}
+
+// MonteCarlo simulates executions of a diagram, following branches by their
+// probabilities and sampling node and edge durations, and reports the
+// distribution of end-to-end duration and cost along with path frequencies.
+// A run ends at a node without outgoing edges.
+func (s *DiagramService) MonteCarlo(id string, opts MonteCarloOptions) (*MonteCarloResult, error) {
+	if opts.Runs == 0 {
+		opts.Runs = defaultSimulationRuns
+	}
+	if opts.Runs < 0 || opts.Runs > maxSimulationRuns {
+		return nil, fmt.Errorf("%w: runs must be between 1 and %d", ErrInvalidOptions, maxSimulationRuns)
+	}
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = defaultSimulationSteps
+	}
+	if opts.MaxSteps > maxSimulationSteps {
+		return nil, fmt.Errorf("%w: maxSteps must be at most %d", ErrInvalidOptions, maxSimulationSteps)
+	}
+	if opts.Runs*opts.MaxSteps > maxSimulationWork {
+		return nil, fmt.Errorf("%w: runs x maxSteps must be at most %d", ErrInvalidOptions, maxSimulationWork)
+	}
+
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	costs, _, err := s.nodeCosts(diagram, map[string]bool{diagram.ID: true})
+	if err != nil {
+		return nil, err
+	}
+
+	nodeCost := make(map[string]float64)
+	for _, entry := range costs {
+		nodeCost[entry.NodeID] = entry.Cost
+	}
+	nodeSamplers := make(map[string]sampler)
+	for _, node := range diagram.Nodes {
+		if nodeSamplers[node.ID], err = newSampler(node.Duration); err != nil {
+			return nil, fmt.Errorf("%w: node %s: %v", ErrInvalidDiagram, node.ID, err)
+		}
+	}
+
+	type branch struct {
+		to      string
+		weight  float64
+		sampler sampler
+	}
+	probs := branchProbabilities(diagram)
+	outgoing := make(map[string][]branch)
+	for i, edge := range diagram.Edges {
+		if _, ok := nodeSamplers[edge.To]; !ok {
+			continue
+		}
+		edgeSampler, err := newSampler(edge.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("%w: edge %s: %v", ErrInvalidDiagram, edge.ID, err)
+		}
+		outgoing[edge.From] = append(outgoing[edge.From], branch{to: edge.To, weight: probs[i], sampler: edgeSampler})
+	}
+
+	entries := entryNodes(diagram)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: diagram has no entry node", ErrInvalidDiagram)
+	}
+
+	seed := time.Now().UnixNano()
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	result := &MonteCarloResult{DiagramID: diagram.ID, Runs: opts.Runs, Seed: seed}
+	durations := make([]float64, 0, opts.Runs)
+	totals := make([]float64, 0, opts.Runs)
+	pathCounts := make(map[string]int)
+
+	for run := 0; run < opts.Runs; run++ {
+		current := entries[rng.Intn(len(entries))]
+		path := []string{current}
+		var elapsed time.Duration
+		cost := 0.0
+
+		for steps := 1; ; steps++ {
+			elapsed += nodeSamplers[current].sample(rng)
+			cost += nodeCost[current]
+
+			branches := outgoing[current]
+			if len(branches) == 0 {
+				break
+			}
+			if steps >= opts.MaxSteps {
+				result.Abandoned++
+				break
+			}
+
+			total := 0.0
+			for _, b := range branches {
+				total += b.weight
+			}
+			next := branches[len(branches)-1]
+			if total > 0 {
+				pick := rng.Float64() * total
+				for _, b := range branches {
+					if pick < b.weight {
+						next = b
+						break
+					}
+					pick -= b.weight
+				}
+			} else {
+				next = branches[rng.Intn(len(branches))]
+			}
+
+			elapsed += next.sampler.sample(rng)
+			current = next.to
+			path = append(path, current)
+		}
+
+		durations = append(durations, elapsed.Seconds())
+		totals = append(totals, cost)
+		pathCounts[strings.Join(path, "\x00")]++
+	}
+
+	result.Duration = distributionStats(durations)
+	result.Cost = distributionStats(totals)
+
+	result.DistinctPaths = len(pathCounts)
+	for key, count := range pathCounts {
+		result.Paths = append(result.Paths, PathFrequency{
+			Nodes:     strings.Split(key, "\x00"),
+			Count:     count,
+			Frequency: float64(count) / float64(opts.Runs),
+		})
+	}
+	sort.Slice(result.Paths, func(i, j int) bool {
+		if result.Paths[i].Count != result.Paths[j].Count {
+			return result.Paths[i].Count > result.Paths[j].Count
+		}
+		return strings.Join(result.Paths[i].Nodes, ",") < strings.Join(result.Paths[j].Nodes, ",")
+	})
+	if len(result.Paths) > simulationTopPaths {
+		result.Paths = result.Paths[:simulationTopPaths]
+	}
+
+	return result, nil
+}
+
+// distributionStats computes summary statistics using nearest-rank
+// percentiles
+func distributionStats(samples []float64) DistributionStats {
+	if len(samples) == 0 {
+		return DistributionStats{}
+	}
+	sorted := append([]float64{}, samples...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return sorted[rank]
+	}
+	return DistributionStats{
+		Mean: sum / float64(len(sorted)),
+		Min:  sorted[0],
+		P50:  percentile(0.5),
+		P95:  percentile(0.95),
+		Max:  sorted[len(sorted)-1],
+	}
+}
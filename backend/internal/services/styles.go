@@ -0,0 +1,244 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Arrowhead sizes in diagram units
+const (
+	defaultArrowSize = 10.0
+	maxArrowSize     = 100.0
+)
+
+var errStyleCycle = errors.New("style reference cycle")
+
+// StyleTheme loads the configured style theme. It returns nil without
+// error when no theme is configured, in which case only styles defined in
+// the diagram can be referenced.
+func (s *DiagramService) StyleTheme() (*models.StyleTheme, error) {
+	if s.cfg.StyleThemePath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.cfg.StyleThemePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read style theme: %w", err)
+	}
+	var theme models.StyleTheme
+	if err := yaml.Unmarshal(data, &theme); err != nil {
+		return nil, fmt.Errorf("failed to parse style theme: %w", err)
+	}
+	return &theme, nil
+}
+
+// namedStyles returns the styles a diagram can reference: the theme's,
+// overridden by the diagram's own
+func namedStyles(diagram *models.FlowDiagram, theme *models.StyleTheme) map[string]models.Style {
+	named := make(map[string]models.Style)
+	if theme != nil {
+		for name, style := range theme.Styles {
+			named[name] = style
+		}
+	}
+	for name, style := range diagram.Styles {
+		named[name] = style
+	}
+	return named
+}
+
+// withResolvedStyles returns a copy of the diagram for renderers in which
+// the style references of nodes and edges are replaced by the styles they
+// name, and nodes referencing the node catalog pick up its default style.
+// References that do not resolve are left for validation to report.
+func (s *DiagramService) withResolvedStyles(diagram *models.FlowDiagram) (*models.FlowDiagram, error) {
+	theme, err := s.StyleTheme()
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := s.NodeCatalog()
+	if err != nil {
+		return nil, err
+	}
+	named := namedStyles(diagram, theme)
+	resolved := *diagram
+	resolved.Nodes = append([]models.FlowNode(nil), diagram.Nodes...)
+	resolved.Edges = append([]models.FlowEdge(nil), diagram.Edges...)
+	for i := range resolved.Nodes {
+		if style, err := resolveStyle(resolved.Nodes[i].Style, named); err == nil {
+			resolved.Nodes[i].Style = style
+		}
+		resolved.Nodes[i].Style = withCatalogStyle(&resolved.Nodes[i], catalog, named)
+	}
+	for i := range resolved.Edges {
+		if style, err := resolveStyle(resolved.Edges[i].Style, named); err == nil {
+			resolved.Edges[i].Style = style
+		}
+	}
+	return &resolved, nil
+}
+
+// resolveStyle follows a style's chain of references and returns the
+// merged style, in which fields set closer to the element win
+func resolveStyle(style *models.Style, named map[string]models.Style) (*models.Style, error) {
+	if style == nil || style.Ref == "" {
+		return style, nil
+	}
+	chain := []*models.Style{style}
+	seen := map[string]bool{}
+	for ref := style.Ref; ref != ""; {
+		if seen[ref] {
+			return nil, fmt.Errorf("%w through %s", errStyleCycle, ref)
+		}
+		seen[ref] = true
+		base, ok := named[ref]
+		if !ok {
+			return nil, fmt.Errorf("unknown style %s", ref)
+		}
+		chain = append(chain, &base)
+		ref = base.Ref
+	}
+
+	resolved := &models.Style{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		overlayStyle(resolved, chain[i])
+	}
+	return resolved, nil
+}
+
+// overlayStyle copies the fields set in source onto target
+func overlayStyle(target, source *models.Style) {
+	t := reflect.ValueOf(target).Elem()
+	src := reflect.ValueOf(source).Elem()
+	for f := 0; f < src.NumField(); f++ {
+		if field := src.Field(f); field.Kind() == reflect.Ptr && !field.IsNil() {
+			t.Field(f).Set(field)
+		}
+	}
+}
+
+// validateStyles checks style references and arrowheads of the diagram's
+// named styles, nodes and edges
+func validateStyles(result *models.ValidationResult, diagram *models.FlowDiagram, theme *models.StyleTheme) {
+	named := namedStyles(diagram, theme)
+	check := func(path string, style *models.Style) {
+		if style == nil {
+			return
+		}
+		if _, err := resolveStyle(style, named); err != nil {
+			code := "UNKNOWN_STYLE"
+			if errors.Is(err, errStyleCycle) {
+				code = "STYLE_CYCLE"
+			}
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".ref",
+				Message: fmt.Sprintf("Invalid style reference: %v", err),
+				Code:    code,
+				Value:   style.Ref,
+			})
+		}
+		for i, arrow := range []*models.Arrowhead{style.ArrowStart, style.ArrowEnd} {
+			if arrow == nil {
+				continue
+			}
+			end := "arrowStart"
+			if i == 1 {
+				end = "arrowEnd"
+			}
+			if !containsValue(models.ArrowheadTypes, arrow.Type) {
+				result.Errors = append(result.Errors, models.ValidationError{
+					Path:    fmt.Sprintf("%s.%s.type", path, end),
+					Message: fmt.Sprintf("Unknown arrowhead type: %s", arrow.Type),
+					Code:    "INVALID_ARROWHEAD",
+					Value:   arrow.Type,
+				})
+			}
+			if arrow.Size != nil && (*arrow.Size <= 0 || *arrow.Size > maxArrowSize) {
+				result.Errors = append(result.Errors, models.ValidationError{
+					Path:    fmt.Sprintf("%s.%s.size", path, end),
+					Message: fmt.Sprintf("Arrowhead size must be greater than 0 and at most %g: %g", maxArrowSize, *arrow.Size),
+					Code:    "INVALID_ARROWHEAD",
+					Value:   *arrow.Size,
+				})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(diagram.Styles))
+	for name := range diagram.Styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		style := diagram.Styles[name]
+		check("styles."+name, &style)
+	}
+	for i := range diagram.Nodes {
+		check(fmt.Sprintf("nodes[%d].style", i), diagram.Nodes[i].Style)
+	}
+	for i := range diagram.Edges {
+		check(fmt.Sprintf("edges[%d].style", i), diagram.Edges[i].Style)
+	}
+}
+
+// edgeArrowheads returns the arrowheads of an edge with a resolved style:
+// none at the start and a triangle at the end unless configured otherwise
+func edgeArrowheads(style *models.Style) (start, end models.Arrowhead) {
+	start = models.Arrowhead{Type: models.ArrowheadNone}
+	end = models.Arrowhead{Type: models.ArrowheadTriangle}
+	if style != nil && style.ArrowStart != nil {
+		start = *style.ArrowStart
+	}
+	if style != nil && style.ArrowEnd != nil {
+		end = *style.ArrowEnd
+	}
+	return start, end
+}
+
+// arrowheadShape returns the outline of an arrowhead whose tip is at tip
+// and which points away from from, in the coordinates of both points.
+// Closed shapes are filled with the edge's stroke color; open ones are
+// stroked. No points means nothing is drawn.
+func arrowheadShape(arrow models.Arrowhead, tip, from [2]float64, scale float64) (points [][2]float64, closed bool) {
+	size := defaultArrowSize
+	if arrow.Size != nil {
+		size = *arrow.Size
+	}
+	size *= scale
+	angle := math.Atan2(tip[1]-from[1], tip[0]-from[0])
+	// at is the point back along the edge by along and off to its side by side
+	at := func(along, side float64) [2]float64 {
+		return [2]float64{
+			tip[0] - along*math.Cos(angle) - side*math.Sin(angle),
+			tip[1] - along*math.Sin(angle) + side*math.Cos(angle),
+		}
+	}
+	wing := size * math.Tan(0.4)
+
+	switch arrow.Type {
+	case models.ArrowheadTriangle:
+		return [][2]float64{tip, at(size, wing), at(size, -wing)}, true
+	case models.ArrowheadOpen:
+		return [][2]float64{at(size, wing), tip, at(size, -wing)}, false
+	case models.ArrowheadDiamond:
+		return [][2]float64{tip, at(size/2, size/3), at(size, 0), at(size/2, -size/3)}, true
+	case models.ArrowheadCircle:
+		radius := size / 2
+		for i := 0; i < 12; i++ {
+			a := float64(i) * math.Pi / 6
+			points = append(points, at(radius+radius*math.Cos(a), radius*math.Sin(a)))
+		}
+		return points, true
+	case models.ArrowheadBar:
+		return [][2]float64{at(0, size/2), at(0, -size/2)}, false
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+)
+
+// NodeTelemetry is a runtime measurement for one node over a batch
+type NodeTelemetry struct {
+	Count     int64    `json:"count"`               // Executions in the batch
+	LatencyMs *float64 `json:"latencyMs,omitempty"` // Mean latency over those executions
+}
+
+// TelemetryBatch is one push of runtime counters, keyed by node ID
+type TelemetryBatch struct {
+	Timestamp time.Time                `json:"timestamp"`
+	Nodes     map[string]NodeTelemetry `json:"nodes"`
+}
+
+// NodeTelemetrySummary aggregates the buffered batches for one node
+type NodeTelemetrySummary struct {
+	Count        int64     `json:"count"`
+	MeanLatency  *float64  `json:"meanLatencyMs,omitempty"` // Weighted by count
+	MaxLatency   *float64  `json:"maxLatencyMs,omitempty"`  // Highest batch mean
+	Heat         float64   `json:"heat"`                    // Count relative to the busiest node (0-1)
+	LastReported time.Time `json:"lastReported"`
+}
+
+// TelemetrySummary is the runtime overlay for a diagram
+type TelemetrySummary struct {
+	DiagramID string                          `json:"diagramId"`
+	Batches   int                             `json:"batches"`
+	From      *time.Time                      `json:"from,omitempty"`
+	To        *time.Time                      `json:"to,omitempty"`
+	Nodes     map[string]NodeTelemetrySummary `json:"nodes"`
+}
+
+// telemetryRing keeps the most recent batches of one diagram
+type telemetryRing struct {
+	batches []TelemetryBatch
+	next    int
+}
+
+func (r *telemetryRing) add(batch TelemetryBatch, size int) {
+	if len(r.batches) < size {
+		r.batches = append(r.batches, batch)
+		return
+	}
+	r.batches[r.next] = batch
+	r.next = (r.next + 1) % size
+}
+
+// ordered returns the buffered batches oldest first
+func (r *telemetryRing) ordered() []TelemetryBatch {
+	return append(append([]TelemetryBatch{}, r.batches[r.next:]...), r.batches[:r.next]...)
+}
+
+// Telemetry is held in memory and shared by all requests; it is a live
+// overlay, not a history, so it is not persisted across restarts
+var (
+	telemetryMu    sync.Mutex
+	telemetryRings = make(map[string]*telemetryRing)
+)
+
+// TelemetryService records and summarizes runtime telemetry
+type TelemetryService struct {
+	cfg            *config.Config
+	diagramService *DiagramService
+}
+
+// NewTelemetryService creates a new telemetry service
+func NewTelemetryService() *TelemetryService {
+	return &TelemetryService{
+		cfg:            config.Load(),
+		diagramService: NewDiagramService(),
+	}
+}
+
+// Record buffers a telemetry batch for a diagram. Entries for nodes the
+// diagram does not contain are dropped and returned so the sender can spot
+// mapping mistakes.
+func (s *TelemetryService) Record(diagramID string, batch TelemetryBatch) (ignored []string, err error) {
+	diagram, err := s.diagramService.GetByID(diagramID)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, node := range diagram.Nodes {
+		known[node.ID] = true
+	}
+	ignored = []string{}
+	nodes := make(map[string]NodeTelemetry)
+	for id, measurement := range batch.Nodes {
+		if !known[id] {
+			ignored = append(ignored, id)
+			continue
+		}
+		if measurement.Count < 0 || (measurement.LatencyMs != nil && *measurement.LatencyMs < 0) {
+			return nil, fmt.Errorf("%w: negative telemetry for node %s", ErrInvalidOptions, id)
+		}
+		nodes[id] = measurement
+	}
+	batch.Nodes = nodes
+	if batch.Timestamp.IsZero() {
+		batch.Timestamp = time.Now().UTC()
+	}
+
+	telemetryMu.Lock()
+	defer telemetryMu.Unlock()
+	ring, ok := telemetryRings[diagram.ID]
+	if !ok {
+		ring = &telemetryRing{}
+		telemetryRings[diagram.ID] = ring
+	}
+	ring.add(batch, s.cfg.TelemetrySize)
+
+	return ignored, nil
+}
+
+// Summary aggregates the buffered telemetry of a diagram, optionally only
+// batches at or after since
+func (s *TelemetryService) Summary(diagramID string, since time.Time) (*TelemetrySummary, error) {
+	if _, err := s.diagramService.GetByID(diagramID); err != nil {
+		return nil, err
+	}
+
+	telemetryMu.Lock()
+	var batches []TelemetryBatch
+	if ring, ok := telemetryRings[diagramID]; ok {
+		batches = ring.ordered()
+	}
+	telemetryMu.Unlock()
+
+	summary := &TelemetrySummary{
+		DiagramID: diagramID,
+		Nodes:     make(map[string]NodeTelemetrySummary),
+	}
+	latencyWeight := make(map[string]float64)
+	latencySum := make(map[string]float64)
+
+	for _, batch := range batches {
+		if batch.Timestamp.Before(since) {
+			continue
+		}
+		timestamp := batch.Timestamp
+		if summary.From == nil || timestamp.Before(*summary.From) {
+			summary.From = &timestamp
+		}
+		if summary.To == nil || timestamp.After(*summary.To) {
+			summary.To = &timestamp
+		}
+		summary.Batches++
+
+		for id, measurement := range batch.Nodes {
+			node := summary.Nodes[id]
+			node.Count += measurement.Count
+			if measurement.LatencyMs != nil {
+				weight := float64(measurement.Count)
+				if weight == 0 {
+					weight = 1
+				}
+				latencySum[id] += *measurement.LatencyMs * weight
+				latencyWeight[id] += weight
+				if node.MaxLatency == nil || *measurement.LatencyMs > *node.MaxLatency {
+					latency := *measurement.LatencyMs
+					node.MaxLatency = &latency
+				}
+			}
+			if timestamp.After(node.LastReported) {
+				node.LastReported = timestamp
+			}
+			summary.Nodes[id] = node
+		}
+	}
+
+	var busiest int64
+	for _, node := range summary.Nodes {
+		if node.Count > busiest {
+			busiest = node.Count
+		}
+	}
+	for id, node := range summary.Nodes {
+		if latencyWeight[id] > 0 {
+			mean := latencySum[id] / latencyWeight[id]
+			node.MeanLatency = &mean
+		}
+		if busiest > 0 {
+			node.Heat = float64(node.Count) / float64(busiest)
+		}
+		summary.Nodes[id] = node
+	}
+
+	return summary, nil
+}
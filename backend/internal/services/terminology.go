@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// maxSpellingSuggestions caps the suggestions listed for a misspelled word
+const maxSpellingSuggestions = 3
+
+// labelWord matches a word of a label; apostrophes may join its parts
+var labelWord = regexp.MustCompile(`[\p{L}\p{N}]+(?:['’][\p{L}]+)*`)
+
+// termChecker checks label text against the configured terminology
+type termChecker struct {
+	terms       *regexp.Regexp
+	banned      map[string]models.BannedTerm // Lower-cased term
+	preferred   map[string]string            // Lower-cased variant to the preferred term
+	dictionary  map[string]bool              // Lower-cased known words; empty disables spelling
+	words       []string                     // Dictionary words, for suggestions
+	defaultLang string
+}
+
+// Terminology loads the configured terminology lint settings. It returns
+// nil without error when none are configured.
+func (s *DiagramService) Terminology() (*models.Terminology, error) {
+	if s.cfg.TerminologyPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.cfg.TerminologyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read terminology: %w", err)
+	}
+	var terminology models.Terminology
+	if err := yaml.Unmarshal(data, &terminology); err != nil {
+		return nil, fmt.Errorf("failed to parse terminology: %w", err)
+	}
+	return &terminology, nil
+}
+
+// lintTerminology flags banned terms, non-preferred variants and words
+// missing from the dictionaries in node names and descriptions
+func (s *DiagramService) lintTerminology(doc *yaml.Node) ([]LintDiagnostic, error) {
+	terminology, err := s.Terminology()
+	if err != nil || terminology == nil {
+		return nil, err
+	}
+	checker, err := newTermChecker(terminology, filepath.Dir(s.cfg.TerminologyPath))
+	if err != nil {
+		return nil, err
+	}
+	checker.defaultLang = s.cfg.DefaultLocale
+
+	diagnostics := []LintDiagnostic{}
+	if doc.Kind != yaml.MappingNode {
+		return diagnostics, nil
+	}
+	nodes := mappingValue(doc, "nodes")
+	if nodes == nil || nodes.Kind != yaml.SequenceNode {
+		return diagnostics, nil
+	}
+	for i, node := range nodes.Content {
+		if node.Kind != yaml.MappingNode {
+			continue
+		}
+		for _, field := range []string{"name", "description"} {
+			if value := mappingValue(node, field); value != nil {
+				checker.check(value, fmt.Sprintf("nodes[%d].%s", i, field), &diagnostics)
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+func newTermChecker(terminology *models.Terminology, dir string) (*termChecker, error) {
+	checker := &termChecker{
+		banned:     make(map[string]models.BannedTerm),
+		preferred:  make(map[string]string),
+		dictionary: make(map[string]bool),
+	}
+	for _, term := range terminology.Banned {
+		checker.banned[strings.ToLower(term.Term)] = term
+	}
+	for variant, term := range terminology.Preferred {
+		checker.preferred[strings.ToLower(variant)] = term
+	}
+	checker.terms = termPattern(append(sortedKeys(checker.banned), sortedKeys(checker.preferred)...))
+
+	for _, path := range terminology.Dictionaries {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if err := checker.loadDictionary(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(checker.dictionary) > 0 || len(terminology.Words) > 0 {
+		// Words the terminology itself recommends are always accepted
+		accepted := append([]string(nil), terminology.Words...)
+		for _, term := range terminology.Preferred {
+			accepted = append(accepted, term)
+		}
+		for _, term := range terminology.Banned {
+			accepted = append(accepted, term.Suggestions...)
+		}
+		for _, text := range accepted {
+			for _, word := range labelWord.FindAllString(text, -1) {
+				checker.dictionary[strings.ToLower(word)] = true
+			}
+		}
+	}
+	checker.words = sortedKeys(checker.dictionary)
+	return checker, nil
+}
+
+// loadDictionary adds the words of a word list. Comment lines starting
+// with #, hunspell affix flags and the word count line of .dic files are
+// skipped.
+func (c *termChecker) loadDictionary(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read dictionary: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, _, _ := strings.Cut(line, "/")
+		if strings.IndexFunc(word, unicode.IsLetter) >= 0 {
+			c.dictionary[strings.ToLower(word)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read dictionary %s: %w", path, err)
+	}
+	return nil
+}
+
+// check lints one label. Localized labels are checked in the default
+// locale, which the dictionaries are expected to cover.
+func (c *termChecker) check(value *yaml.Node, path string, diagnostics *[]LintDiagnostic) {
+	if value.Kind == yaml.MappingNode {
+		value = mappingValue(value, c.defaultLang)
+		if value == nil {
+			return
+		}
+	}
+	if value.Kind != yaml.ScalarNode {
+		return
+	}
+	text := value.Value
+
+	var flagged [][]int
+	if c.terms != nil {
+		flagged = c.terms.FindAllStringIndex(text, -1)
+	}
+	for _, match := range flagged {
+		found := text[match[0]:match[1]]
+		line, column := scalarPosition(value, match[0])
+		if term, ok := c.banned[strings.ToLower(found)]; ok {
+			message := fmt.Sprintf("%s uses the banned term %q", path, found)
+			if term.Reason != "" {
+				message += ": " + term.Reason
+			}
+			*diagnostics = append(*diagnostics, LintDiagnostic{
+				Line: line, Column: column, Severity: LintWarning, Code: "BANNED_TERM", Message: message, Suggestions: term.Suggestions,
+			})
+			continue
+		}
+		preferred := c.preferred[strings.ToLower(found)]
+		*diagnostics = append(*diagnostics, LintDiagnostic{
+			Line: line, Column: column, Severity: LintInfo, Code: "PREFERRED_TERM",
+			Message:     fmt.Sprintf("%s uses %q; the preferred term is %q", path, found, preferred),
+			Suggestions: []string{preferred},
+		})
+	}
+
+	if len(c.dictionary) == 0 {
+		return
+	}
+	for _, match := range labelWord.FindAllStringIndex(text, -1) {
+		word := text[match[0]:match[1]]
+		if overlaps(match, flagged) || !spellCheckable(word) || c.dictionary[strings.ToLower(word)] {
+			continue
+		}
+		line, column := scalarPosition(value, match[0])
+		*diagnostics = append(*diagnostics, LintDiagnostic{
+			Line: line, Column: column, Severity: LintInfo, Code: "SPELLING",
+			Message:     fmt.Sprintf("%s: %q is not in the dictionary", path, word),
+			Suggestions: c.suggest(word),
+		})
+	}
+}
+
+// spellCheckable reports whether a word should be spell checked. Numbers,
+// acronyms and identifiers such as camelCase names are left alone.
+func spellCheckable(word string) bool {
+	if utf8.RuneCountInString(word) < 2 {
+		return false
+	}
+	for i, r := range word {
+		if unicode.IsDigit(r) || (i > 0 && unicode.IsUpper(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// suggest returns the dictionary words closest to a misspelled word,
+// written in the word's case
+func (c *termChecker) suggest(word string) []string {
+	lower := strings.ToLower(word)
+	type candidate struct {
+		word     string
+		distance int
+	}
+	var candidates []candidate
+	length := utf8.RuneCountInString(lower)
+	for _, known := range c.words {
+		if diff := utf8.RuneCountInString(known) - length; diff > 2 || diff < -2 {
+			continue
+		}
+		if distance := editDistance(lower, known); distance <= 2 {
+			candidates = append(candidates, candidate{known, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxSpellingSuggestions; i++ {
+		suggestion := candidates[i].word
+		if first, _ := utf8.DecodeRuneInString(word); unicode.IsUpper(first) {
+			r, size := utf8.DecodeRuneInString(suggestion)
+			suggestion = string(unicode.ToUpper(r)) + suggestion[size:]
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between two words
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// overlaps reports whether a match overlaps any of the ranges
+func overlaps(match []int, ranges [][]int) bool {
+	for _, r := range ranges {
+		if match[0] < r[1] && r[0] < match[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// scalarPosition returns the line and column of a byte offset into a
+// scalar's value. Positions are exact in single-line plain and quoted
+// scalars without escapes, and literal block scalars get the line only;
+// otherwise the scalar's start is returned.
+func scalarPosition(value *yaml.Node, offset int) (int, int) {
+	before := value.Value[:offset]
+	switch value.Style {
+	case 0, yaml.SingleQuotedStyle, yaml.DoubleQuotedStyle:
+		if strings.Contains(value.Value, "\n") || (value.Style == yaml.DoubleQuotedStyle && strings.ContainsAny(value.Value, `"\`)) ||
+			(value.Style == yaml.SingleQuotedStyle && strings.Contains(value.Value, "'")) {
+			return value.Line, value.Column
+		}
+		column := value.Column + utf8.RuneCountInString(before)
+		if value.Style != 0 {
+			column++ // Opening quote
+		}
+		return value.Line, column
+	case yaml.LiteralStyle:
+		line := value.Line + 1 + strings.Count(before, "\n")
+		return line, 0
+	}
+	return value.Line, value.Column
+}
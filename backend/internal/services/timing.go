@@ -0,0 +1,263 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ParseDuration parses a duration such as "90s", "2h30m" or "1w2d4h". On
+// top of the Go duration units it accepts d (24h) and w (7d).
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var total time.Duration
+	rest := value
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		idx := strings.Index(rest, unit.suffix)
+		if idx < 0 {
+			continue
+		}
+		n, err := strconv.ParseFloat(rest[:idx], 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		total += time.Duration(n * float64(unit.size))
+		rest = rest[idx+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// durationBounds returns the best and worst case of a duration
+func durationBounds(d *models.Duration) (min, max time.Duration, err error) {
+	if d == nil {
+		return 0, 0, nil
+	}
+	if d.Min != "" {
+		if min, err = ParseDuration(d.Min); err != nil {
+			return 0, 0, err
+		}
+	}
+	if d.Max != "" {
+		if max, err = ParseDuration(d.Max); err != nil {
+			return 0, 0, err
+		}
+	}
+	switch {
+	case d.Min == "":
+		min = max
+	case d.Max == "":
+		max = min
+	}
+	return min, max, nil
+}
+
+// validateTiming checks the duration and SLA formats of a node or edge
+func validateTiming(result *models.ValidationResult, path string, duration *models.Duration, sla *string) {
+	if duration != nil {
+		for _, bound := range [][2]string{{"min", duration.Min}, {"max", duration.Max}} {
+			field, value := bound[0], bound[1]
+			if value == "" {
+				continue
+			}
+			if _, err := ParseDuration(value); err != nil {
+				result.Errors = append(result.Errors, models.ValidationError{
+					Path:    fmt.Sprintf("%s.duration.%s", path, field),
+					Message: fmt.Sprintf("Invalid duration: %s", value),
+					Code:    "INVALID_DURATION",
+					Value:   value,
+				})
+			}
+		}
+		min, max, err := durationBounds(duration)
+		if err == nil && min > max {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".duration",
+				Message: "Minimum duration exceeds maximum",
+				Code:    "INVALID_DURATION_RANGE",
+			})
+		}
+		switch duration.Distribution {
+		case "", models.DistributionUniform, models.DistributionFixed:
+		case models.DistributionTriangular:
+			mode, modeErr := ParseDuration(duration.Mode)
+			if duration.Mode != "" && modeErr != nil {
+				result.Errors = append(result.Errors, models.ValidationError{
+					Path:    path + ".duration.mode",
+					Message: fmt.Sprintf("Invalid duration: %s", duration.Mode),
+					Code:    "INVALID_DURATION",
+					Value:   duration.Mode,
+				})
+			} else if duration.Mode != "" && err == nil && (mode < min || mode > max) {
+				result.Errors = append(result.Errors, models.ValidationError{
+					Path:    path + ".duration.mode",
+					Message: "Mode must lie between the minimum and maximum duration",
+					Code:    "INVALID_DURATION_MODE",
+				})
+			}
+		default:
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".duration.distribution",
+				Message: fmt.Sprintf("Unknown duration distribution: %s", duration.Distribution),
+				Code:    "INVALID_DISTRIBUTION",
+				Value:   duration.Distribution,
+			})
+		}
+	}
+	if sla != nil {
+		if _, err := ParseDuration(*sla); err != nil {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    path + ".sla",
+				Message: fmt.Sprintf("Invalid duration: %s", *sla),
+				Code:    "INVALID_DURATION",
+				Value:   *sla,
+			})
+		}
+	}
+}
+
+// TimingSpan is a best/worst-case duration, rendered both as text and in
+// seconds
+type TimingSpan struct {
+	Best         string  `json:"best"`
+	Worst        string  `json:"worst"`
+	BestSeconds  float64 `json:"bestSeconds"`
+	WorstSeconds float64 `json:"worstSeconds"`
+}
+
+func newTimingSpan(best, worst time.Duration) TimingSpan {
+	return TimingSpan{
+		Best:         best.String(),
+		Worst:        worst.String(),
+		BestSeconds:  best.Seconds(),
+		WorstSeconds: worst.Seconds(),
+	}
+}
+
+// SLABreach is a step or transition whose worst case exceeds its SLA
+type SLABreach struct {
+	ElementID string `json:"elementId"`
+	Element   string `json:"element"` // node or edge
+	SLA       string `json:"sla"`
+	Worst     string `json:"worst"`
+}
+
+// PathTiming is the end-to-end duration of one path through the diagram
+type PathTiming struct {
+	TimingSpan
+	Nodes []string `json:"nodes"`
+}
+
+// TimingReport summarizes the end-to-end durations of a diagram
+type TimingReport struct {
+	DiagramID string       `json:"diagramId"`
+	Overall   TimingSpan   `json:"overall"` // Fastest best case and slowest worst case over all paths
+	Paths     []PathTiming `json:"paths"`
+	Breaches  []SLABreach  `json:"breaches"`
+	Untimed   []string     `json:"untimed,omitempty"` // Nodes without a duration
+	Truncated bool         `json:"truncated,omitempty"`
+}
+
+// Timing computes best and worst-case durations for every path through the
+// diagram and lists the elements whose worst case exceeds their SLA.
+// Elements without a duration count as zero.
+func (s *DiagramService) Timing(id string) (*TimingReport, error) {
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TimingReport{
+		DiagramID: diagram.ID,
+		Paths:     []PathTiming{},
+		Breaches:  []SLABreach{},
+	}
+
+	type bounds struct{ min, max time.Duration }
+	nodeBounds := make(map[string]bounds)
+	edgeBounds := make(map[string]bounds)
+	check := func(elementID, element string, duration *models.Duration, sla *string) (bounds, error) {
+		min, max, err := durationBounds(duration)
+		if err != nil {
+			return bounds{}, fmt.Errorf("%s %s: %w", element, elementID, err)
+		}
+		if sla != nil {
+			limit, err := ParseDuration(*sla)
+			if err != nil {
+				return bounds{}, fmt.Errorf("%s %s: %w", element, elementID, err)
+			}
+			if max > limit {
+				report.Breaches = append(report.Breaches, SLABreach{
+					ElementID: elementID,
+					Element:   element,
+					SLA:       *sla,
+					Worst:     max.String(),
+				})
+			}
+		}
+		return bounds{min, max}, nil
+	}
+
+	for _, node := range diagram.Nodes {
+		b, err := check(node.ID, "node", node.Duration, node.SLA)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+		}
+		nodeBounds[node.ID] = b
+		if node.Duration == nil && node.Type != models.NodeTypeStart && node.Type != models.NodeTypeEnd {
+			report.Untimed = append(report.Untimed, node.ID)
+		}
+	}
+	for _, edge := range diagram.Edges {
+		b, err := check(edge.ID, "edge", edge.Duration, edge.SLA)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+		}
+		edgeBounds[edge.ID] = b
+	}
+
+	paths, truncated := enumeratePaths(diagram)
+	report.Truncated = truncated
+
+	var overallBest, overallWorst time.Duration
+	for i, path := range paths {
+		var best, worst time.Duration
+		for _, node := range path.Nodes {
+			best += nodeBounds[node.ID].min
+			worst += nodeBounds[node.ID].max
+		}
+		for _, edge := range path.Edges {
+			best += edgeBounds[edge.ID].min
+			worst += edgeBounds[edge.ID].max
+		}
+		report.Paths = append(report.Paths, PathTiming{
+			TimingSpan: newTimingSpan(best, worst),
+			Nodes:      path.NodeIDs(),
+		})
+		if i == 0 || best < overallBest {
+			overallBest = best
+		}
+		if worst > overallWorst {
+			overallWorst = worst
+		}
+	}
+	report.Overall = newTimingSpan(overallBest, overallWorst)
+
+	return report, nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// validationMessages holds translated validation messages keyed by locale
+// and error code. A %v verb is replaced with the error's value. English is
+// the language Validate already produces, so it needs no catalog entry.
+var validationMessages = map[string]map[string]string{
+	"de": {
+		"MISSING_ID":                 "Diagramm-ID ist erforderlich",
+		"MISSING_NAME":               "Diagrammname ist erforderlich",
+		"MISSING_VERSION":            "Diagrammversion ist erforderlich",
+		"MISSING_LAYER_ID":           "Ebenen-ID ist erforderlich",
+		"DUPLICATE_LAYER_ID":         "Doppelte Ebenen-ID: %v",
+		"MISSING_NODE_ID":            "Knoten-ID ist erforderlich",
+		"DUPLICATE_NODE_ID":          "Doppelte Knoten-ID: %v",
+		"MISSING_NODE_NAME":          "Knotenname ist erforderlich",
+		"INVALID_LAYER":              "Verweis auf nicht definierte Ebene: %v",
+		"MISSING_EDGE_ID":            "Kanten-ID ist erforderlich",
+		"DUPLICATE_EDGE_ID":          "Doppelte Kanten-ID: %v",
+		"INVALID_FROM_NODE":          "Kante verweist auf nicht vorhandenen Startknoten: %v",
+		"INVALID_TO_NODE":            "Kante verweist auf nicht vorhandenen Zielknoten: %v",
+		"UNKNOWN_OWNER":              "Unbekannte Person oder unbekanntes Team: %v",
+		"MISSING_CONTROL_ID":         "Kontroll-ID ist erforderlich",
+		"UNKNOWN_CONTROL":            "Unbekannte Kontrolle: %v",
+		"CONTROL_FRAMEWORK_MISMATCH": "Kontrolle gehört nicht zum Framework %v",
+		"INVALID_DURATION":           "Ungültige Dauer: %v",
+		"INVALID_DURATION_RANGE":     "Mindestdauer ist größer als Höchstdauer",
+		"INVALID_COST":               "Kosten dürfen nicht negativ sein: %v",
+		"INVALID_PROBABILITY":        "Wahrscheinlichkeit muss zwischen 0 und 1 liegen: %v",
+		"PROBABILITY_SUM_EXCEEDED":   "Ausgehende Wahrscheinlichkeiten ergeben zusammen mehr als 1: %v",
+		"INVALID_DISTRIBUTION":       "Unbekannte Verteilung: %v",
+		"INVALID_DURATION_MODE":      "Modalwert muss zwischen Mindest- und Höchstdauer liegen",
+		"INVALID_HEALTH_URL":         "Health-URL muss eine http(s)-URL sein: %v",
+		"MISSING_DEPLOYMENT":         "Name des Kubernetes-Deployments ist erforderlich",
+		"MISSING_META_FIELD":         "Metadatenfeld ist erforderlich: %v",
+		"INVALID_META_TYPE":          "Metadatenfeld muss vom Typ %v sein",
+		"INVALID_META_VALUE":         "Wert des Metadatenfelds ist nicht zulässig: %v",
+		"UNKNOWN_META_FIELD":         "Unbekanntes Metadatenfeld: %v",
+		"INVALID_RELATION_TYPE":      "Unbekannter Beziehungstyp: %v",
+		"MISSING_RELATION_TARGET":    "Ziel der Beziehung ist erforderlich",
+		"SELF_RELATION":              "Diagramm kann nicht mit sich selbst in Beziehung stehen: %v",
+		"DUPLICATE_RELATION":         "Doppelte Beziehung: %v",
+		"INVALID_SUCCESSOR":          "Diagramm kann sich nicht selbst ablösen: %v",
+		"PLUGIN_FAILED":              "Validierungs-Plugin fehlgeschlagen: %v",
+		"INVALID_PROVENANCE":         "Unbekannte Herkunftsart: %v",
+		"GENERATED_DIAGRAM_EDITED":   "Diagramm wurde seit dem Erzeugen (%v) bearbeitet; beim Neugenerieren gehen die Änderungen verloren",
+		"INVALID_Z_INDEX":            "Z-Index muss zwischen -1000 und 1000 liegen: %v",
+		"INVALID_ROUTING_PRIORITY":   "Routing-Priorität muss zwischen -1000 und 1000 liegen: %v",
+		"UNKNOWN_STYLE":              "Unbekannter Stilverweis: %v",
+		"STYLE_CYCLE":                "Stilverweis bildet einen Zyklus: %v",
+		"INVALID_ARROWHEAD":          "Ungültige Pfeilspitze: %v",
+		"LOW_CONTRAST":               "Zu geringer Kontrast zwischen Text- und Füllfarbe: %v",
+		"UNKNOWN_CATALOG_NODE":       "Unbekannter Katalogknoten: %v",
+		"CATALOG_DRIFT":              "Weicht vom Katalogknoten %v ab",
+		"DEPRECATED_FIELD":           "Veraltetes Feld; stattdessen %v verwenden (wird beim Speichern umgestellt)",
+		"SELF_PARENT":                "Diagramm kann nicht sein eigener Elternteil sein: %v",
+		"DUPLICATE_PARENT":           "Doppelter Elternteil: %v",
+		"DRILL_DOWN_FOCUS_NO_TARGET": "Drill-down-Fokus erfordert ein drillDown-Ziel",
+		"INVALID_VIEWPORT":           "Ansichtsbereich benötigt endliche Koordinaten und einen positiven Zoom",
+	},
+	"fr": {
+		"MISSING_ID":                 "L'identifiant du diagramme est obligatoire",
+		"MISSING_NAME":               "Le nom du diagramme est obligatoire",
+		"MISSING_VERSION":            "La version du diagramme est obligatoire",
+		"MISSING_LAYER_ID":           "L'identifiant du calque est obligatoire",
+		"DUPLICATE_LAYER_ID":         "Identifiant de calque en double : %v",
+		"MISSING_NODE_ID":            "L'identifiant du nœud est obligatoire",
+		"DUPLICATE_NODE_ID":          "Identifiant de nœud en double : %v",
+		"MISSING_NODE_NAME":          "Le nom du nœud est obligatoire",
+		"INVALID_LAYER":              "Référence à un calque non défini : %v",
+		"MISSING_EDGE_ID":            "L'identifiant du lien est obligatoire",
+		"DUPLICATE_EDGE_ID":          "Identifiant de lien en double : %v",
+		"INVALID_FROM_NODE":          "Le lien référence un nœud source inexistant : %v",
+		"INVALID_TO_NODE":            "Le lien référence un nœud cible inexistant : %v",
+		"UNKNOWN_OWNER":              "Personne ou équipe inconnue : %v",
+		"MISSING_CONTROL_ID":         "L'identifiant du contrôle est obligatoire",
+		"UNKNOWN_CONTROL":            "Contrôle inconnu : %v",
+		"CONTROL_FRAMEWORK_MISMATCH": "Le contrôle n'appartient pas au référentiel %v",
+		"INVALID_DURATION":           "Durée invalide : %v",
+		"INVALID_DURATION_RANGE":     "La durée minimale dépasse la durée maximale",
+		"INVALID_COST":               "Le coût ne peut pas être négatif : %v",
+		"INVALID_PROBABILITY":        "La probabilité doit être comprise entre 0 et 1 : %v",
+		"PROBABILITY_SUM_EXCEEDED":   "La somme des probabilités sortantes dépasse 1 : %v",
+		"INVALID_DISTRIBUTION":       "Distribution inconnue : %v",
+		"INVALID_DURATION_MODE":      "Le mode doit être compris entre la durée minimale et maximale",
+		"INVALID_HEALTH_URL":         "L'URL de santé doit être une URL http(s) : %v",
+		"MISSING_DEPLOYMENT":         "Le nom du déploiement Kubernetes est obligatoire",
+		"MISSING_META_FIELD":         "Le champ de métadonnées est obligatoire : %v",
+		"INVALID_META_TYPE":          "Le champ de métadonnées doit être de type %v",
+		"INVALID_META_VALUE":         "Valeur du champ de métadonnées non autorisée : %v",
+		"UNKNOWN_META_FIELD":         "Champ de métadonnées inconnu : %v",
+		"INVALID_RELATION_TYPE":      "Type de relation inconnu : %v",
+		"MISSING_RELATION_TARGET":    "La cible de la relation est obligatoire",
+		"SELF_RELATION":              "Un diagramme ne peut pas être en relation avec lui-même : %v",
+		"DUPLICATE_RELATION":         "Relation en double : %v",
+		"INVALID_SUCCESSOR":          "Un diagramme ne peut pas se remplacer lui-même : %v",
+		"PLUGIN_FAILED":              "Échec du plugin de validation : %v",
+		"INVALID_PROVENANCE":         "Méthode de provenance inconnue : %v",
+		"GENERATED_DIAGRAM_EDITED":   "Le diagramme a été modifié depuis sa production (%v) ; une régénération écrasera les modifications",
+		"INVALID_Z_INDEX":            "Le z-index doit être compris entre -1000 et 1000 : %v",
+		"INVALID_ROUTING_PRIORITY":   "La priorité de routage doit être comprise entre -1000 et 1000 : %v",
+		"UNKNOWN_STYLE":              "Référence de style inconnue : %v",
+		"STYLE_CYCLE":                "La référence de style forme un cycle : %v",
+		"INVALID_ARROWHEAD":          "Pointe de flèche invalide : %v",
+		"LOW_CONTRAST":               "Contraste insuffisant entre le texte et le remplissage : %v",
+		"UNKNOWN_CATALOG_NODE":       "Nœud de catalogue inconnu : %v",
+		"CATALOG_DRIFT":              "Diffère du nœud de catalogue %v",
+		"DEPRECATED_FIELD":           "Champ obsolète ; utilisez %v (mis à jour à l'enregistrement)",
+		"SELF_PARENT":                "Un diagramme ne peut pas être son propre parent : %v",
+		"DUPLICATE_PARENT":           "Parent en double : %v",
+		"DRILL_DOWN_FOCUS_NO_TARGET": "Le focus d'exploration nécessite une cible drillDown",
+		"INVALID_VIEWPORT":           "La zone d'affichage nécessite des coordonnées finies et un zoom positif",
+	},
+	"es": {
+		"MISSING_ID":                 "El ID del diagrama es obligatorio",
+		"MISSING_NAME":               "El nombre del diagrama es obligatorio",
+		"MISSING_VERSION":            "La versión del diagrama es obligatoria",
+		"MISSING_LAYER_ID":           "El ID de la capa es obligatorio",
+		"DUPLICATE_LAYER_ID":         "ID de capa duplicado: %v",
+		"MISSING_NODE_ID":            "El ID del nodo es obligatorio",
+		"DUPLICATE_NODE_ID":          "ID de nodo duplicado: %v",
+		"MISSING_NODE_NAME":          "El nombre del nodo es obligatorio",
+		"INVALID_LAYER":              "Referencia a una capa no definida: %v",
+		"MISSING_EDGE_ID":            "El ID de la conexión es obligatorio",
+		"DUPLICATE_EDGE_ID":          "ID de conexión duplicado: %v",
+		"INVALID_FROM_NODE":          "La conexión hace referencia a un nodo de origen inexistente: %v",
+		"INVALID_TO_NODE":            "La conexión hace referencia a un nodo de destino inexistente: %v",
+		"UNKNOWN_OWNER":              "Persona o equipo desconocido: %v",
+		"MISSING_CONTROL_ID":         "El ID del control es obligatorio",
+		"UNKNOWN_CONTROL":            "Control desconocido: %v",
+		"CONTROL_FRAMEWORK_MISMATCH": "El control no pertenece al marco %v",
+		"INVALID_DURATION":           "Duración no válida: %v",
+		"INVALID_DURATION_RANGE":     "La duración mínima supera la máxima",
+		"INVALID_COST":               "El coste no puede ser negativo: %v",
+		"INVALID_PROBABILITY":        "La probabilidad debe estar entre 0 y 1: %v",
+		"PROBABILITY_SUM_EXCEEDED":   "Las probabilidades salientes suman más de 1: %v",
+		"INVALID_DISTRIBUTION":       "Distribución desconocida: %v",
+		"INVALID_DURATION_MODE":      "La moda debe estar entre la duración mínima y la máxima",
+		"INVALID_HEALTH_URL":         "La URL de estado debe ser una URL http(s): %v",
+		"MISSING_DEPLOYMENT":         "El nombre del deployment de Kubernetes es obligatorio",
+		"MISSING_META_FIELD":         "El campo de metadatos es obligatorio: %v",
+		"INVALID_META_TYPE":          "El campo de metadatos debe ser de tipo %v",
+		"INVALID_META_VALUE":         "Valor no permitido para el campo de metadatos: %v",
+		"UNKNOWN_META_FIELD":         "Campo de metadatos desconocido: %v",
+		"INVALID_RELATION_TYPE":      "Tipo de relación desconocido: %v",
+		"MISSING_RELATION_TARGET":    "El destino de la relación es obligatorio",
+		"SELF_RELATION":              "Un diagrama no puede relacionarse consigo mismo: %v",
+		"DUPLICATE_RELATION":         "Relación duplicada: %v",
+		"INVALID_SUCCESSOR":          "Un diagrama no puede sustituirse a sí mismo: %v",
+		"PLUGIN_FAILED":              "Error en el plugin de validación: %v",
+		"INVALID_PROVENANCE":         "Método de procedencia desconocido: %v",
+		"GENERATED_DIAGRAM_EDITED":   "El diagrama se ha editado desde que se produjo (%v); al regenerarlo se perderán los cambios",
+		"INVALID_Z_INDEX":            "El índice z debe estar entre -1000 y 1000: %v",
+		"INVALID_ROUTING_PRIORITY":   "La prioridad de enrutamiento debe estar entre -1000 y 1000: %v",
+		"UNKNOWN_STYLE":              "Referencia de estilo desconocida: %v",
+		"STYLE_CYCLE":                "La referencia de estilo forma un ciclo: %v",
+		"INVALID_ARROWHEAD":          "Punta de flecha no válida: %v",
+		"LOW_CONTRAST":               "Contraste insuficiente entre el texto y el relleno: %v",
+		"UNKNOWN_CATALOG_NODE":       "Nodo de catálogo desconocido: %v",
+		"CATALOG_DRIFT":              "Difiere del nodo de catálogo %v",
+		"DEPRECATED_FIELD":           "Campo obsoleto; use %v (se actualiza al guardar)",
+		"SELF_PARENT":                "Un diagrama no puede ser su propio padre: %v",
+		"DUPLICATE_PARENT":           "Padre duplicado: %v",
+		"DRILL_DOWN_FOCUS_NO_TARGET": "El foco de exploración requiere un destino drillDown",
+		"INVALID_VIEWPORT":           "La vista necesita coordenadas finitas y un zoom positivo",
+	},
+}
+
+// NegotiateValidationLocale picks the best supported locale for an
+// Accept-Language header, returning "en" when nothing else matches
+func NegotiateValidationLocale(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: strings.ToLower(tag), q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		base, _, _ := strings.Cut(c.tag, "-")
+		if base == "en" {
+			return "en"
+		}
+		if _, ok := validationMessages[base]; ok {
+			return base
+		}
+	}
+	return "en"
+}
+
+// LocalizeValidation rewrites the messages of a validation result into the
+// given locale. Codes, paths and values are left untouched; errors without
+// a translation keep their English message.
+func LocalizeValidation(result *models.ValidationResult, locale string) {
+	catalog, ok := validationMessages[locale]
+	if !ok {
+		return
+	}
+	localize := func(errs []models.ValidationError) {
+		for i := range errs {
+			template, ok := catalog[errs[i].Code]
+			if !ok {
+				continue
+			}
+			if strings.Contains(template, "%v") {
+				errs[i].Message = fmt.Sprintf(template, errs[i].Value)
+			} else {
+				errs[i].Message = template
+			}
+		}
+	}
+	localize(result.Errors)
+	localize(result.Warnings)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ValidationRulesVersion identifies the built-in validation rules; bump it
+// when rules are added or changed so that recorded outcomes show their age
+const ValidationRulesVersion = 1
+
+var validationStatuses = []string{models.ValidationValid, models.ValidationWarnings, models.ValidationErrors}
+
+// validationStatus summarizes a validation result
+func validationStatus(result *models.ValidationResult) *models.ValidationStatus {
+	status := &models.ValidationStatus{
+		Status:       models.ValidationValid,
+		Errors:       len(result.Errors),
+		Warnings:     len(result.Warnings),
+		CheckedAt:    time.Now().UTC(),
+		RulesVersion: ValidationRulesVersion,
+	}
+	if status.Errors > 0 {
+		status.Status = models.ValidationErrors
+	} else if status.Warnings > 0 {
+		status.Status = models.ValidationWarnings
+	}
+	return status
+}
+
+// RecordValidation stores the outcome of validating a stored diagram with
+// it. The file is only rewritten when the outcome differs from the recorded
+// one, and then only its validation section changes, so comments and
+// formatting of hand-edited files survive and the update time is kept.
+// Files in read-only roots are left as they are.
+func (s *DiagramService) RecordValidation(diagram *models.FlowDiagram, result *models.ValidationResult) error {
+	status := validationStatus(result)
+	if recorded := diagram.Validation; recorded != nil && recorded.Status == status.Status &&
+		recorded.Errors == status.Errors && recorded.Warnings == status.Warnings && recorded.RulesVersion == status.RulesVersion {
+		return nil
+	}
+	diagram.Validation = status
+	if s.checkWritable(diagram.FilePath) != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(diagram.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read diagram file: %w", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse diagram file: %w", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("diagram file %s is not a mapping", diagram.FilePath)
+	}
+	var value yaml.Node
+	if err := value.Encode(status); err != nil {
+		return err
+	}
+	mapping := root.Content[0]
+	replaced := false
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == "validation" {
+			mapping.Content[i+1] = &value
+			replaced = true
+		}
+	}
+	if !replaced {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "validation"}, &value)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return fmt.Errorf("failed to marshal diagram file: %w", err)
+	}
+	_ = enc.Close()
+	if err := writeDiagramFile(diagram.FilePath, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write diagram file: %w", err)
+	}
+	return nil
+}
+
+// FilterByValidation keeps the diagrams whose current validation status is
+// the given one. The status comes from the diagram stats, which are
+// recomputed whenever a diagram's file changes, so diagrams edited outside
+// the API are filtered by what they contain now.
+func (s *DiagramService) FilterByValidation(diagrams []models.FlowDiagram, status string) ([]models.FlowDiagram, error) {
+	if !containsValue(validationStatuses, status) {
+		return nil, fmt.Errorf("%w: validation must be one of %s", ErrInvalidOptions, strings.Join(validationStatuses, ", "))
+	}
+	filtered := []models.FlowDiagram{}
+	for i := range diagrams {
+		stats, err := s.Stats(&diagrams[i])
+		if err != nil {
+			return nil, err
+		}
+		if stats.Validation == status {
+			filtered = append(filtered, diagrams[i])
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// What saves do when the validation webhook cannot be reached or fails
+const (
+	WebhookFailOpen   = "open"   // Save without the external checks
+	WebhookFailClosed = "closed" // Refuse the save
+)
+
+// validationWebhookName is how the webhook is named in rejections
+const validationWebhookName = "validation-webhook"
+
+// ErrValidationUnavailable is returned when the validation webhook fails
+// and the failure policy is closed
+var ErrValidationUnavailable = errors.New("validation webhook unavailable")
+
+// runValidationWebhook sends a diagram about to be saved to
+// VALIDATION_WEBHOOK_URL, as save hooks receive it. The webhook rejects it
+// by answering {"errors": [...]}, with a 2xx or a 4xx status; a 4xx without
+// errors rejects with its body as the message. The rejection is returned
+// as a *SaveVetoError.
+func runValidationWebhook(cfg *config.Config, diagram *models.FlowDiagram, event string) error {
+	if cfg.ValidationWebhookURL == "" {
+		return nil
+	}
+	validationErrors, err := callValidationWebhook(cfg, saveHookPayload{Event: event, Diagram: diagram})
+	if err != nil {
+		if cfg.ValidationWebhookFailure == WebhookFailOpen {
+			log.Printf("Validation webhook failed, saving %s without it: %v", diagram.ID, err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrValidationUnavailable, err)
+	}
+	if len(validationErrors) > 0 {
+		return &SaveVetoError{Hook: validationWebhookName, Errors: validationErrors}
+	}
+	return nil
+}
+
+func callValidationWebhook(cfg *config.Config, payload saveHookPayload) ([]models.ValidationError, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ValidationWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.ValidationWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var response struct {
+		Errors []models.ValidationError `json:"errors"`
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if len(bytes.TrimSpace(data)) > 0 && json.Unmarshal(data, &response) != nil {
+			return nil, fmt.Errorf("unreadable response: %s", strings.TrimSpace(string(data)))
+		}
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		if json.Unmarshal(data, &response) != nil || len(response.Errors) == 0 {
+			message := strings.TrimSpace(string(data))
+			if message == "" {
+				message = "rejected by the validation webhook"
+			}
+			response.Errors = []models.ValidationError{{Message: message}}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	for i := range response.Errors {
+		if response.Errors[i].Code == "" {
+			response.Errors[i].Code = "EXTERNAL_VALIDATION_FAILED"
+		}
+	}
+	return response.Errors, nil
+}
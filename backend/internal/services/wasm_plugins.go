@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// WASM plugin stages
+const (
+	PluginValidate  = "validate"  // Returns extra validation errors and warnings
+	PluginTransform = "transform" // Returns a modified diagram before it is validated and saved
+)
+
+const (
+	defaultPluginTimeout = 5 * time.Second
+	wasmMemoryLimitPages = 1024     // 64 MiB per module instance
+	wasmMaxOutputBytes   = 16 << 20 // Stdout kept per run; longer output is an error
+)
+
+// WasmPlugin is a WASI module declared in WASM_PLUGINS_PATH. It runs as a
+// command: it reads {"stage": ..., "diagram": ...} from stdin and writes
+// {"errors": [...], "warnings": [...]} (validate) or {"diagram": ...}
+// (transform) to stdout. Modules get no filesystem, network or clock
+// beyond what WASI stdio needs, and are stopped after the timeout.
+type WasmPlugin struct {
+	Name     string `yaml:"name"`
+	Stage    string `yaml:"stage"`
+	Module   string `yaml:"module"`             // Path to the .wasm file
+	Timeout  string `yaml:"timeout,omitempty"`  // Default 5s
+	Optional bool   `yaml:"optional,omitempty"` // A failing run is a warning, not an error
+}
+
+type wasmPluginInput struct {
+	Stage   string              `json:"stage"`
+	Diagram *models.FlowDiagram `json:"diagram"`
+}
+
+type wasmPluginOutput struct {
+	Errors   []models.ValidationError `json:"errors"`
+	Warnings []models.ValidationError `json:"warnings"`
+	Diagram  *models.FlowDiagram      `json:"diagram"`
+}
+
+// wasmRuntime compiles and instantiates plugin modules. Compiled modules
+// are cached until the file changes.
+var wasmRuntime struct {
+	sync.Mutex
+	runtime  wazero.Runtime
+	compiled map[string]*wasmCompiled
+}
+
+// wasmCompiled is a cached module. A module replaced after its file
+// changed is closed once the last run using it has released it.
+type wasmCompiled struct {
+	modTime time.Time
+	module  wazero.CompiledModule
+	users   int
+	stale   bool
+}
+
+// release ends a run's use of the module
+func (c *wasmCompiled) release() {
+	wasmRuntime.Lock()
+	defer wasmRuntime.Unlock()
+	c.users--
+	if c.stale && c.users == 0 {
+		c.module.Close(context.Background())
+	}
+}
+
+// loadWasmPlugins reads the plugins of a stage from WASM_PLUGINS_PATH. No
+// plugins run when it is unset.
+func loadWasmPlugins(cfg *config.Config, stage string) ([]WasmPlugin, error) {
+	if cfg.WasmPluginsPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(cfg.WasmPluginsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WASM plugins: %w", err)
+	}
+	var file struct {
+		Plugins []WasmPlugin `yaml:"plugins"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse WASM plugins: %w", err)
+	}
+
+	plugins := []WasmPlugin{}
+	for _, plugin := range file.Plugins {
+		if plugin.Stage != PluginValidate && plugin.Stage != PluginTransform {
+			return nil, fmt.Errorf("WASM plugin %s: unknown stage %q", plugin.Name, plugin.Stage)
+		}
+		if plugin.Module == "" {
+			return nil, fmt.Errorf("WASM plugin %s: module is required", plugin.Name)
+		}
+		if plugin.Stage == stage {
+			plugins = append(plugins, plugin)
+		}
+	}
+	return plugins, nil
+}
+
+// runValidationPlugins adds the findings of the validation plugins. A
+// plugin that cannot run is reported as PLUGIN_FAILED, as a warning when
+// it is optional.
+func runValidationPlugins(cfg *config.Config, result *models.ValidationResult, diagram *models.FlowDiagram) {
+	plugins, err := loadWasmPlugins(cfg, PluginValidate)
+	if err != nil {
+		log.Printf("Validation plugins: %v", err)
+		return
+	}
+	for _, plugin := range plugins {
+		output, err := plugin.run(diagram)
+		if err != nil {
+			failure := models.ValidationError{
+				Message: fmt.Sprintf("Validation plugin %s failed: %v", plugin.Name, err),
+				Code:    "PLUGIN_FAILED",
+				Value:   plugin.Name,
+			}
+			if plugin.Optional {
+				result.Warnings = append(result.Warnings, failure)
+			} else {
+				result.Errors = append(result.Errors, failure)
+			}
+			continue
+		}
+		result.Errors = append(result.Errors, output.Errors...)
+		result.Warnings = append(result.Warnings, output.Warnings...)
+	}
+}
+
+// applyTransformPlugins lets transform plugins rewrite a diagram before it
+// is validated and saved. Plugins cannot change the ID or the bookkeeping
+// fields.
+func applyTransformPlugins(cfg *config.Config, diagram *models.FlowDiagram) error {
+	plugins, err := loadWasmPlugins(cfg, PluginTransform)
+	if err != nil {
+		return err
+	}
+	for _, plugin := range plugins {
+		output, err := plugin.run(diagram)
+		if err != nil {
+			if plugin.Optional {
+				log.Printf("Optional transform plugin %s failed: %v", plugin.Name, err)
+				continue
+			}
+			return fmt.Errorf("transform plugin %s failed: %w", plugin.Name, err)
+		}
+		if output.Diagram == nil {
+			continue
+		}
+		if output.Diagram.ID != diagram.ID {
+			return fmt.Errorf("transform plugin %s changed the diagram ID to %q", plugin.Name, output.Diagram.ID)
+		}
+		output.Diagram.Created, output.Diagram.Updated, output.Diagram.FilePath = diagram.Created, diagram.Updated, diagram.FilePath
+		*diagram = *output.Diagram
+	}
+	return nil
+}
+
+// run executes the plugin module once with the diagram on stdin
+func (p *WasmPlugin) run(diagram *models.FlowDiagram) (*wasmPluginOutput, error) {
+	timeout := defaultPluginTimeout
+	if p.Timeout != "" {
+		d, err := time.ParseDuration(p.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q", p.Timeout)
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	runtime, compiled, err := compileWasmModule(p.Module)
+	if err != nil {
+		return nil, err
+	}
+	defer compiled.release()
+	input, err := json.Marshal(wasmPluginInput{Stage: p.Stage, Diagram: diagram})
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr := &limitedBuffer{limit: wasmMaxOutputBytes}, &limitedBuffer{limit: 64 << 10}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(p.Name).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr)
+
+	module, err := runtime.InstantiateModule(ctx, compiled.module, moduleConfig)
+	if module != nil {
+		module.Close(ctx)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %s", timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%v: %s", err, message)
+		}
+		return nil, err
+	}
+
+	if stdout.truncated {
+		return nil, fmt.Errorf("output exceeds %d bytes", wasmMaxOutputBytes)
+	}
+	output := &wasmPluginOutput{}
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return output, nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), output); err != nil {
+		return nil, fmt.Errorf("invalid output: %w", err)
+	}
+	return output, nil
+}
+
+// compileWasmModule returns the shared runtime and the compiled module,
+// compiling it on first use or after the file changed. The caller must
+// release the module when done with it.
+func compileWasmModule(path string) (wazero.Runtime, *wasmCompiled, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read module: %w", err)
+	}
+
+	wasmRuntime.Lock()
+	defer wasmRuntime.Unlock()
+	ctx := context.Background()
+	if wasmRuntime.runtime == nil {
+		wasmRuntime.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+			WithCloseOnContextDone(true).
+			WithMemoryLimitPages(wasmMemoryLimitPages))
+		wasi_snapshot_preview1.MustInstantiate(ctx, wasmRuntime.runtime)
+		wasmRuntime.compiled = make(map[string]*wasmCompiled)
+	}
+
+	if cached, ok := wasmRuntime.compiled[path]; ok {
+		if cached.modTime.Equal(info.ModTime()) {
+			cached.users++
+			return wasmRuntime.runtime, cached, nil
+		}
+		cached.stale = true
+		if cached.users == 0 {
+			cached.module.Close(ctx)
+		}
+		delete(wasmRuntime.compiled, path)
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read module: %w", err)
+	}
+	module, err := wasmRuntime.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compile module: %w", err)
+	}
+	compiled := &wasmCompiled{modTime: info.ModTime(), module: module, users: 1}
+	wasmRuntime.compiled[path] = compiled
+	return wasmRuntime.runtime, compiled, nil
+}
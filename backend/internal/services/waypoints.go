@@ -0,0 +1,231 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// DefaultSimplifyTolerance is how far, in diagram units, a waypoint may lie
+// off the simplified route before it is kept
+const DefaultSimplifyTolerance = 2.0
+
+// Waypoint simplification modes
+const (
+	SimplifyAuto           = "auto"            // orthogonal for right-angled routes, else douglas-peucker
+	SimplifyDouglasPeucker = "douglas-peucker" // drop points close to the line through their neighbours
+	SimplifyOrthogonal     = "orthogonal"      // merge runs of horizontal or vertical segments only
+)
+
+// SimplifyOptions selects the edges to simplify and how
+type SimplifyOptions struct {
+	Tolerance float64  `json:"tolerance"` // Defaults to DefaultSimplifyTolerance
+	Mode      string   `json:"mode"`      // Defaults to auto
+	EdgeIDs   []string `json:"edgeIds"`   // Empty simplifies every edge
+	DryRun    bool     `json:"dryRun"`
+}
+
+// SimplifiedEdge reports the waypoints of an edge before and after
+type SimplifiedEdge struct {
+	ID     string `json:"id"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+// SimplifyResult reports the edges a simplification changed
+type SimplifyResult struct {
+	Diagram models.FlowDiagram `json:"diagram"`
+	Edges   []SimplifiedEdge   `json:"edges"`
+	Removed int                `json:"removed"` // Waypoints removed in total
+	Saved   bool               `json:"saved"`
+}
+
+// Simplify removes redundant waypoints from the edges of a diagram, such as
+// the many points left by hand-dragging or by imports, without visibly
+// changing their routes. The diagram is saved only when an edge changed,
+// and not at all with DryRun.
+func (s *DiagramService) Simplify(id string, opts SimplifyOptions) (*SimplifyResult, error) {
+	if opts.Tolerance < 0 {
+		return nil, fmt.Errorf("%w: tolerance must not be negative", ErrInvalidOptions)
+	}
+	if opts.Tolerance == 0 {
+		opts.Tolerance = DefaultSimplifyTolerance
+	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = SimplifyAuto
+	case SimplifyAuto, SimplifyDouglasPeucker, SimplifyOrthogonal:
+	default:
+		return nil, fmt.Errorf("%w: unknown simplify mode %q", ErrInvalidOptions, opts.Mode)
+	}
+
+	diagram, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]bool, len(opts.EdgeIDs))
+	for _, edgeID := range opts.EdgeIDs {
+		if findEdge(diagram, edgeID) == nil {
+			return nil, fmt.Errorf("%w: edge %s not found", ErrInvalidOptions, edgeID)
+		}
+		selected[edgeID] = true
+	}
+
+	result := &SimplifyResult{Edges: []SimplifiedEdge{}}
+	for i := range diagram.Edges {
+		edge := &diagram.Edges[i]
+		if len(selected) > 0 && !selected[edge.ID] {
+			continue
+		}
+		before := len(edge.Waypoints)
+		if !simplifyEdge(diagram, edge, opts.Tolerance, opts.Mode) {
+			continue
+		}
+		result.Edges = append(result.Edges, SimplifiedEdge{ID: edge.ID, Before: before, After: len(edge.Waypoints)})
+		result.Removed += before - len(edge.Waypoints)
+	}
+
+	if len(result.Edges) == 0 || opts.DryRun {
+		result.Diagram = *diagram
+		return result, nil
+	}
+	updated, err := s.Update(diagram)
+	if err != nil {
+		return nil, err
+	}
+	result.Diagram, result.Saved = *updated, true
+	return result, nil
+}
+
+// simplifyCrowdedEdges simplifies, on save, the edges with more waypoints
+// than the configured threshold
+func (s *DiagramService) simplifyCrowdedEdges(diagram *models.FlowDiagram) {
+	if s.cfg.SimplifyWaypointsAbove <= 0 {
+		return
+	}
+	for i := range diagram.Edges {
+		if len(diagram.Edges[i].Waypoints) > s.cfg.SimplifyWaypointsAbove {
+			simplifyEdge(diagram, &diagram.Edges[i], DefaultSimplifyTolerance, SimplifyAuto)
+		}
+	}
+}
+
+// simplifyEdge simplifies the waypoints of an edge and reports whether any
+// were removed. Routes are drawn from node centers, so those anchor the
+// ends of the route.
+func simplifyEdge(diagram *models.FlowDiagram, edge *models.FlowEdge, tolerance float64, mode string) bool {
+	if len(edge.Waypoints) == 0 {
+		return false
+	}
+	from, to := findNode(diagram, edge.From), findNode(diagram, edge.To)
+	if from == nil || to == nil {
+		return false
+	}
+	route := make([]models.Position, 0, len(edge.Waypoints)+2)
+	route = append(route, nodeCenter(from))
+	route = append(route, edge.Waypoints...)
+	route = append(route, nodeCenter(to))
+
+	route = dedupePoints(route, tolerance)
+	if mode == SimplifyOrthogonal || (mode == SimplifyAuto && isOrthogonal(edge.Waypoints, tolerance)) {
+		route = mergeOrthogonal(route, tolerance)
+	} else {
+		route = douglasPeucker(route, tolerance)
+	}
+
+	waypoints := route[1 : len(route)-1]
+	if len(waypoints) == len(edge.Waypoints) {
+		return false
+	}
+	if len(waypoints) == 0 {
+		edge.Waypoints = nil
+	} else {
+		edge.Waypoints = append([]models.Position(nil), waypoints...)
+	}
+	return true
+}
+
+// nodeCenter returns the center of a node's box
+func nodeCenter(node *models.FlowNode) models.Position {
+	x, y, w, h := nodeBounds(node)
+	return models.Position{X: x + w/2, Y: y + h/2}
+}
+
+// dedupePoints drops points within tolerance of the point before them. The
+// ends of the route are always kept.
+func dedupePoints(points []models.Position, tolerance float64) []models.Position {
+	kept := []models.Position{points[0]}
+	for i := 1; i < len(points)-1; i++ {
+		if distance(points[i], kept[len(kept)-1]) > tolerance {
+			kept = append(kept, points[i])
+		}
+	}
+	return append(kept, points[len(points)-1])
+}
+
+// isOrthogonal reports whether consecutive waypoints are all aligned
+// horizontally or vertically
+func isOrthogonal(points []models.Position, tolerance float64) bool {
+	if len(points) < 2 {
+		return false
+	}
+	for i := 1; i < len(points); i++ {
+		dx, dy := math.Abs(points[i].X-points[i-1].X), math.Abs(points[i].Y-points[i-1].Y)
+		if dx > tolerance && dy > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeOrthogonal drops the points in the middle of a horizontal or
+// vertical run, keeping every corner of the route
+func mergeOrthogonal(points []models.Position, tolerance float64) []models.Position {
+	kept := []models.Position{points[0]}
+	for i := 1; i < len(points)-1; i++ {
+		prev, next := kept[len(kept)-1], points[i+1]
+		vertical := math.Abs(prev.X-points[i].X) <= tolerance && math.Abs(points[i].X-next.X) <= tolerance
+		horizontal := math.Abs(prev.Y-points[i].Y) <= tolerance && math.Abs(points[i].Y-next.Y) <= tolerance
+		if !vertical && !horizontal {
+			kept = append(kept, points[i])
+		}
+	}
+	return append(kept, points[len(points)-1])
+}
+
+// douglasPeucker keeps the points of a route lying further than tolerance
+// from the line between the points kept around them
+func douglasPeucker(points []models.Position, tolerance float64) []models.Position {
+	if len(points) < 3 {
+		return points
+	}
+	first, last := points[0], points[len(points)-1]
+	farthest, maxDistance := 0, 0.0
+	for i := 1; i < len(points)-1; i++ {
+		if d := segmentDistance(points[i], first, last); d > maxDistance {
+			farthest, maxDistance = i, d
+		}
+	}
+	if maxDistance <= tolerance {
+		return []models.Position{first, last}
+	}
+	left := douglasPeucker(points[:farthest+1], tolerance)
+	right := douglasPeucker(points[farthest:], tolerance)
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
+
+// segmentDistance is the distance from p to the segment from a to b
+func segmentDistance(p, a, b models.Position) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := dx*dx + dy*dy
+	if length == 0 {
+		return distance(p, a)
+	}
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/length))
+	return distance(p, models.Position{X: a.X + t*dx, Y: a.Y + t*dy})
+}
+
+func distance(a, b models.Position) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/config"
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// ErrFeatureDisabled is returned when a feature is switched off in the
+// workspace of a request
+var ErrFeatureDisabled = errors.New("feature disabled")
+
+var workspaceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// WorkspaceService stores workspace settings as <id>.yaml files below
+// WORKSPACES_PATH
+type WorkspaceService struct {
+	cfg *config.Config
+}
+
+// NewWorkspaceService creates a new workspace service
+func NewWorkspaceService() *WorkspaceService {
+	return &WorkspaceService{
+		cfg: config.Load(),
+	}
+}
+
+// Resolve returns the workspace of a request: the WORKSPACE_HEADER header,
+// else the signed-in person's default workspace, else DEFAULT_WORKSPACE
+func (s *WorkspaceService) Resolve(header http.Header) string {
+	if id := header.Get(s.cfg.WorkspaceHeader); id != "" {
+		return id
+	}
+	if header.Get(s.cfg.UserHeader) != "" {
+		preferencesService := NewPreferencesService()
+		if person, err := preferencesService.Identify(header); err == nil {
+			if preferences, err := preferencesService.Get(person); err == nil && preferences.DefaultWorkspace != "" {
+				return preferences.DefaultWorkspace
+			}
+		}
+	}
+	return s.cfg.DefaultWorkspace
+}
+
+// RequireFeature fails with ErrFeatureDisabled when a feature is switched
+// off in the workspace of a request
+func (s *WorkspaceService) RequireFeature(header http.Header, feature string) error {
+	settings, err := s.Settings(s.Resolve(header))
+	if err != nil {
+		return err
+	}
+	if !settings.Features.Enabled(feature) {
+		return fmt.Errorf("%w: %s is disabled in workspace %s", ErrFeatureDisabled, feature, settings.ID)
+	}
+	return nil
+}
+
+// Settings returns a workspace's stored settings, or the defaults when
+// none were saved
+func (s *WorkspaceService) Settings(id string) (*models.WorkspaceSettings, error) {
+	if err := checkWorkspaceID(id); err != nil {
+		return nil, err
+	}
+	settings := models.DefaultWorkspaceSettings()
+	data, err := os.ReadFile(s.file(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read workspace settings: %w", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &settings); err != nil {
+			return nil, fmt.Errorf("failed to parse settings of workspace %s: %w", id, err)
+		}
+	}
+	settings.ID = id
+	return &settings, nil
+}
+
+// SaveSettings replaces a workspace's settings
+func (s *WorkspaceService) SaveSettings(id string, settings models.WorkspaceSettings) (*models.WorkspaceSettings, error) {
+	if err := checkWorkspaceID(id); err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode workspace settings: %w", err)
+	}
+	if err := os.MkdirAll(s.cfg.WorkspacesPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create workspaces directory: %w", err)
+	}
+	if err := os.WriteFile(s.file(id), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write workspace settings: %w", err)
+	}
+	settings.ID = id
+	return &settings, nil
+}
+
+func (s *WorkspaceService) file(id string) string {
+	return filepath.Join(s.cfg.WorkspacesPath, id+".yaml")
+}
+
+func checkWorkspaceID(id string) error {
+	if !workspaceIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: invalid workspace id %q", ErrInvalidOptions, id)
+	}
+	return nil
+}
+
+// checkAIGeneration refuses to save an AI-generated diagram, whether new
+// or an existing diagram turned into one, in a workspace that switched AI
+// generation off. Hand edits of diagrams generated before stay allowed.
+func (s *DiagramService) checkAIGeneration(diagram, existing *models.FlowDiagram) error {
+	if diagram.Provenance == nil || diagram.Provenance.Method != models.ProvenanceGenerated {
+		return nil
+	}
+	if existing != nil && existing.Provenance != nil && existing.Provenance.Method == models.ProvenanceGenerated {
+		return nil
+	}
+	return NewWorkspaceService().RequireFeature(s.header, models.FeatureAIGeneration)
+}
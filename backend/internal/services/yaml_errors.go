@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlSnippetContext is the number of lines shown around a problem
+const yamlSnippetContext = 2
+
+var (
+	yamlSyntaxErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	yamlTypeErrorPattern   = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlQuotedValuePattern = regexp.MustCompile("`([^`]*)`")
+	yamlQuotedKeyPattern   = regexp.MustCompile(`mapping key "([^"]*)"`)
+)
+
+// YAMLError is a YAML document that could not be parsed or decoded, with
+// the position of each problem so that editors can jump to it
+type YAMLError struct {
+	Err      error         `json:"-"`
+	Problems []YAMLProblem `json:"problems"`
+}
+
+func (e *YAMLError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *YAMLError) Unwrap() error {
+	return e.Err
+}
+
+// YAMLProblem is one parse or decode problem
+type YAMLProblem struct {
+	Line    int           `json:"line"`             // 1-based
+	Column  int           `json:"column,omitempty"` // 1-based; unknown for syntax errors
+	Message string        `json:"message"`
+	Snippet []SnippetLine `json:"snippet"`
+}
+
+// SnippetLine is a line of the YAML around a problem
+type SnippetLine struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Problem bool   `json:"problem,omitempty"` // The line the problem is on
+}
+
+// newYAMLError locates the problems of a yaml.v3 error in data. Errors
+// without positions are returned unchanged.
+func newYAMLError(data []byte, err error) error {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	yamlErr := &YAMLError{Err: err}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		var root yaml.Node
+		if yaml.Unmarshal(data, &root) != nil {
+			return err
+		}
+		for _, message := range typeErr.Errors {
+			match := yamlTypeErrorPattern.FindStringSubmatch(message)
+			if match == nil {
+				continue
+			}
+			line, _ := strconv.Atoi(match[1])
+			problem := YAMLProblem{Line: line, Message: match[2], Snippet: yamlSnippet(lines, line)}
+			if node := findProblemNode(&root, line, match[2]); node != nil {
+				problem.Column = node.Column
+			}
+			yamlErr.Problems = append(yamlErr.Problems, problem)
+		}
+	} else if match := yamlSyntaxErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		yamlErr.Problems = append(yamlErr.Problems, YAMLProblem{Line: line, Message: match[2], Snippet: yamlSnippet(lines, line)})
+	}
+
+	if len(yamlErr.Problems) == 0 {
+		return err
+	}
+	return yamlErr
+}
+
+// findProblemNode finds the node a decode error refers to: the scalar
+// quoted in the message, the duplicated key, or else the first value on
+// the line
+func findProblemNode(root *yaml.Node, line int, message string) *yaml.Node {
+	var keys, values []*yaml.Node
+	var walk func(node *yaml.Node, isKey bool)
+	walk = func(node *yaml.Node, isKey bool) {
+		if node.Line == line && node.Kind != yaml.DocumentNode {
+			if isKey {
+				keys = append(keys, node)
+			} else {
+				values = append(values, node)
+			}
+		}
+		for i, child := range node.Content {
+			walk(child, node.Kind == yaml.MappingNode && i%2 == 0)
+		}
+	}
+	walk(root, false)
+
+	if match := yamlQuotedKeyPattern.FindStringSubmatch(message); match != nil {
+		for _, node := range keys {
+			if node.Value == match[1] {
+				return node
+			}
+		}
+	}
+	if match := yamlQuotedValuePattern.FindStringSubmatch(message); match != nil {
+		for _, node := range values {
+			if node.Value == match[1] {
+				return node
+			}
+		}
+	}
+	if len(values) > 0 {
+		return values[0]
+	}
+	return nil
+}
+
+// yamlSnippet returns the problem line with the lines around it
+func yamlSnippet(lines []string, line int) []SnippetLine {
+	snippet := []SnippetLine{}
+	for n := line - yamlSnippetContext; n <= line+yamlSnippetContext; n++ {
+		if n < 1 || n > len(lines) {
+			continue
+		}
+		snippet = append(snippet, SnippetLine{Line: n, Text: lines[n-1], Problem: n == line})
+	}
+	return snippet
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// Lint severities
+const (
+	LintError   = "error"   // The YAML cannot be parsed
+	LintWarning = "warning" // Parses, but is likely a mistake
+	LintInfo    = "info"    // Differs from the canonical style
+)
+
+// blockScalarPattern matches a line that opens a literal or folded block
+// scalar, whose content lines are exempt from indentation checks
+var blockScalarPattern = regexp.MustCompile(`:\s+[|>][-+0-9]*\s*(#.*)?$|^\s*-\s+[|>][-+0-9]*\s*(#.*)?$`)
+
+// anyType stands for free-form values, whose keys are not checked
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// LintDiagnostic is a style problem found in diagram YAML. Lint results
+// never block a save; semantic checks are left to Validate.
+type LintDiagnostic struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	// Replacements for the flagged text, best first
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// LintYAML checks diagram YAML for tabs, inconsistent indentation,
+// duplicate keys, fields the server does not know and deprecated key
+// styles, and the spelling and terminology of node labels when
+// TERMINOLOGY_PATH is set. Without yamlText the stored YAML of the diagram
+// is linted.
+func (s *DiagramService) LintYAML(id, yamlText string) ([]LintDiagnostic, error) {
+	if strings.TrimSpace(yamlText) == "" {
+		stored, err := s.LoadYAMLByID(id)
+		if err != nil {
+			return nil, err
+		}
+		yamlText = stored
+	}
+	if err := s.checkYAMLSize(len(yamlText)); err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(yamlText, "\n"), "\n")
+	diagnostics := lintTabs(lines)
+	diagnostics = append(diagnostics, lintIndentation(lines)...)
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlText), &root); err != nil {
+		var yamlErr *YAMLError
+		if errors.As(newYAMLError([]byte(yamlText), err), &yamlErr) {
+			for _, problem := range yamlErr.Problems {
+				diagnostics = append(diagnostics, LintDiagnostic{
+					Line: problem.Line, Column: problem.Column, Severity: LintError, Code: "YAML_SYNTAX", Message: problem.Message,
+				})
+			}
+		} else {
+			diagnostics = append(diagnostics, LintDiagnostic{Line: 1, Severity: LintError, Code: "YAML_SYNTAX", Message: err.Error()})
+		}
+	} else if len(root.Content) > 0 {
+		lintNode(root.Content[0], reflect.TypeOf(models.FlowDiagram{}), "", &diagnostics)
+		terminology, err := s.lintTerminology(root.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, terminology...)
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics, nil
+}
+
+// lintTabs reports the first tab character of each line
+func lintTabs(lines []string) []LintDiagnostic {
+	diagnostics := []LintDiagnostic{}
+	for i, line := range lines {
+		column := strings.IndexByte(line, '\t')
+		if column < 0 {
+			continue
+		}
+		message := "Tab character; YAML values should not contain raw tabs"
+		if strings.TrimLeft(line[:column], " ") == "" {
+			message = "Tab character in indentation; YAML indentation must use spaces"
+		}
+		diagnostics = append(diagnostics, LintDiagnostic{Line: i + 1, Column: column + 1, Severity: LintWarning, Code: "TAB_CHARACTER", Message: message})
+	}
+	return diagnostics
+}
+
+// lintIndentation reports lines that are nested by a different number of
+// spaces than most nested lines of their kind, or that do not line up with
+// any enclosing level
+func lintIndentation(lines []string) []LintDiagnostic {
+	type nesting struct {
+		line, column, step int
+		sequence           bool
+	}
+	diagnostics := []LintDiagnostic{}
+	nestings := []nesting{}
+	levels := []int{0}
+	blockIndent := -1
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "\t") {
+			continue
+		}
+		if blockIndent >= 0 {
+			if indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if trimmed == "---" || trimmed == "..." {
+			levels = []int{0}
+			continue
+		}
+
+		top := levels[len(levels)-1]
+		switch {
+		case indent > top:
+			nestings = append(nestings, nesting{line: i + 1, column: indent + 1, step: indent - top, sequence: strings.HasPrefix(trimmed, "- ")})
+			levels = append(levels, indent)
+		case indent < top:
+			for len(levels) > 1 && levels[len(levels)-1] > indent {
+				levels = levels[:len(levels)-1]
+			}
+			if levels[len(levels)-1] != indent {
+				diagnostics = append(diagnostics, LintDiagnostic{
+					Line: i + 1, Column: indent + 1, Severity: LintWarning, Code: "INCONSISTENT_INDENT",
+					Message: "Indentation does not line up with an enclosing level",
+				})
+				levels = append(levels, indent)
+			}
+		}
+
+		// The content of a sequence item is a level of its own
+		if strings.HasPrefix(trimmed, "- ") {
+			content := indent + 1 + len(trimmed[1:]) - len(strings.TrimLeft(trimmed[1:], " "))
+			levels = append(levels, content)
+		}
+		if blockScalarPattern.MatchString(line) {
+			blockIndent = indent
+		}
+	}
+
+	// Sequences may be indented under their key differently from nested
+	// mappings, so each kind has its own usual step
+	for _, sequence := range []bool{false, true} {
+		counts := map[int]int{}
+		usual := 0
+		for _, n := range nestings {
+			if n.sequence != sequence {
+				continue
+			}
+			counts[n.step]++
+			if usual == 0 || counts[n.step] > counts[usual] {
+				usual = n.step
+			}
+		}
+		kind := "mappings"
+		if sequence {
+			kind = "sequences"
+		}
+		for _, n := range nestings {
+			if n.sequence == sequence && n.step != usual {
+				diagnostics = append(diagnostics, LintDiagnostic{
+					Line: n.line, Column: n.column, Severity: LintWarning, Code: "INCONSISTENT_INDENT",
+					Message: fmt.Sprintf("Indented by %d spaces; the rest of the document indents %s by %d", n.step, kind, usual),
+				})
+			}
+		}
+	}
+	return diagnostics
+}
+
+// lintNode walks a YAML node alongside the Go type it decodes into and
+// reports duplicate keys, unknown fields, deprecated fields and deprecated
+// key styles
+func lintNode(node *yaml.Node, t reflect.Type, path string, diagnostics *[]LintDiagnostic) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		fields := map[string]reflect.Type(nil)
+		if t.Kind() == reflect.Struct {
+			fields = yamlFields(t)
+		}
+		seen := map[string]int{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinYAMLPath(path, key.Value)
+			if line, ok := seen[key.Value]; ok {
+				*diagnostics = append(*diagnostics, LintDiagnostic{
+					Line: key.Line, Column: key.Column, Severity: LintWarning, Code: "DUPLICATE_KEY",
+					Message: fmt.Sprintf("Key %s is already defined at line %d", keyPath, line),
+				})
+			}
+			seen[key.Value] = key.Line
+			if (key.Value == "x" || key.Value == "y") && (key.Style == yaml.DoubleQuotedStyle || key.Style == yaml.SingleQuotedStyle) {
+				*diagnostics = append(*diagnostics, LintDiagnostic{
+					Line: key.Line, Column: key.Column, Severity: LintInfo, Code: "DEPRECATED_QUOTED_KEY",
+					Message: fmt.Sprintf("Quoted coordinate key %s is deprecated; write it unquoted", keyPath),
+				})
+			}
+
+			switch {
+			case fields != nil:
+				fieldType, ok := fields[key.Value]
+				if rule, legacy := findLegacyRule(t, key.Value); !ok && legacy {
+					*diagnostics = append(*diagnostics, LintDiagnostic{
+						Line: key.Line, Column: key.Column, Severity: LintInfo, Code: "DEPRECATED_FIELD",
+						Message: fmt.Sprintf("Field %s is deprecated; write %s instead. It is upgraded when the diagram is saved", keyPath, joinYAMLPath(path, rule.replacement)),
+					})
+					continue
+				}
+				if !ok {
+					*diagnostics = append(*diagnostics, LintDiagnostic{
+						Line: key.Line, Column: key.Column, Severity: LintWarning, Code: "UNKNOWN_FIELD",
+						Message: fmt.Sprintf("Unknown field %s is ignored and dropped when the diagram is saved", keyPath),
+					})
+					lintNode(value, anyType, keyPath, diagnostics)
+					continue
+				}
+				lintNode(value, fieldType, keyPath, diagnostics)
+			case t.Kind() == reflect.Map:
+				lintNode(value, t.Elem(), keyPath, diagnostics)
+			default:
+				lintNode(value, anyType, keyPath, diagnostics)
+			}
+		}
+	case yaml.ScalarNode:
+		if t == reflect.TypeOf(models.Layer{}) {
+			*diagnostics = append(*diagnostics, LintDiagnostic{
+				Line: node.Line, Column: node.Column, Severity: LintInfo, Code: "DEPRECATED_FIELD",
+				Message: fmt.Sprintf("Layer %s is given by ID only, which is deprecated; write it as a mapping with id and name. It is upgraded when the diagram is saved", path),
+			})
+		}
+	case yaml.SequenceNode:
+		elem := anyType
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			elem = t.Elem()
+		}
+		for i, item := range node.Content {
+			lintNode(item, elem, fmt.Sprintf("%s[%d]", path, i), diagnostics)
+		}
+	}
+}
+
+// yamlFields returns the YAML keys of a struct's fields, including inlined
+// ones
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			inlined := field.Type
+			for inlined.Kind() == reflect.Ptr {
+				inlined = inlined.Elem()
+			}
+			if inlined.Kind() == reflect.Struct {
+				for key, keyType := range yamlFields(inlined) {
+					fields[key] = keyType
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func joinYAMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/michaellanpart/flowgen/backend/internal/models"
+)
+
+// maxZOrder bounds z-indexes and routing priorities in either direction;
+// larger values are almost always typos, and normalize-order compacts the
+// values back to small ones
+const maxZOrder = 1000
+
+// drawOrder returns the nodes and edges in the order renderers draw them:
+// by z-index and routing priority, lowest first so that higher values end
+// up on top, and in document order among equal values
+func drawOrder(diagram *models.FlowDiagram) ([]*models.FlowNode, []*models.FlowEdge) {
+	nodes := make([]*models.FlowNode, len(diagram.Nodes))
+	for i := range diagram.Nodes {
+		nodes[i] = &diagram.Nodes[i]
+	}
+	sort.SliceStable(nodes, func(a, b int) bool { return nodes[a].ZIndex < nodes[b].ZIndex })
+
+	edges := make([]*models.FlowEdge, len(diagram.Edges))
+	for i := range diagram.Edges {
+		edges[i] = &diagram.Edges[i]
+	}
+	sort.SliceStable(edges, func(a, b int) bool { return edges[a].RoutingPriority < edges[b].RoutingPriority })
+	return nodes, edges
+}
+
+// normalizeZOrder compacts z-indexes and routing priorities to consecutive
+// values while keeping their order. Zero stays zero so that elements
+// without an explicit value are unaffected; positive values become 1, 2,
+// ... and negative ones -1, -2, ...
+func normalizeZOrder(diagram *models.FlowDiagram) {
+	zIndexes := make([]*int, len(diagram.Nodes))
+	for i := range diagram.Nodes {
+		zIndexes[i] = &diagram.Nodes[i].ZIndex
+	}
+	compactOrder(zIndexes)
+
+	priorities := make([]*int, len(diagram.Edges))
+	for i := range diagram.Edges {
+		priorities[i] = &diagram.Edges[i].RoutingPriority
+	}
+	compactOrder(priorities)
+}
+
+func compactOrder(values []*int) {
+	distinct := map[int]bool{}
+	for _, v := range values {
+		distinct[*v] = true
+	}
+	var positive, negative []int
+	for v := range distinct {
+		if v > 0 {
+			positive = append(positive, v)
+		} else if v < 0 {
+			negative = append(negative, v)
+		}
+	}
+	sort.Ints(positive)
+	sort.Sort(sort.Reverse(sort.IntSlice(negative)))
+
+	compacted := map[int]int{0: 0}
+	for i, v := range positive {
+		compacted[v] = i + 1
+	}
+	for i, v := range negative {
+		compacted[v] = -(i + 1)
+	}
+	for _, v := range values {
+		*v = compacted[*v]
+	}
+}
+
+func validateZOrder(result *models.ValidationResult, diagram *models.FlowDiagram) {
+	for i, node := range diagram.Nodes {
+		if node.ZIndex > maxZOrder || node.ZIndex < -maxZOrder {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    fmt.Sprintf("nodes[%d].zIndex", i),
+				Message: fmt.Sprintf("Z-index must be between -%d and %d: %d", maxZOrder, maxZOrder, node.ZIndex),
+				Code:    "INVALID_Z_INDEX",
+				Value:   node.ZIndex,
+			})
+		}
+	}
+	for i, edge := range diagram.Edges {
+		if edge.RoutingPriority > maxZOrder || edge.RoutingPriority < -maxZOrder {
+			result.Errors = append(result.Errors, models.ValidationError{
+				Path:    fmt.Sprintf("edges[%d].routingPriority", i),
+				Message: fmt.Sprintf("Routing priority must be between -%d and %d: %d", maxZOrder, maxZOrder, edge.RoutingPriority),
+				Code:    "INVALID_ROUTING_PRIORITY",
+				Value:   edge.RoutingPriority,
+			})
+		}
+	}
+}